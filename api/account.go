@@ -0,0 +1,221 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+	"sealdice-core/logger"
+)
+
+const (
+	identityContextKey      = "webuiIdentity"
+	auditRecordedContextKey = "webuiAuditRecorded"
+)
+
+// requirePermission 路由级权限中间件。
+// 未登录的请求交给处理函数自己的 doAuth 拒绝，这里只拦截已登录但权限不足的身份，并记录所有修改类请求
+func requirePermission(perm dice.WebUIPermission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			identity, ok := getIdentity(c)
+			if !ok {
+				return next(c)
+			}
+			if !identity.Can(perm) {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"err":        "权限不足",
+					"permission": string(perm),
+				})
+			}
+			err := next(c)
+			if c.Request().Method != http.MethodGet {
+				auditWebUIRequest(c, identity, err)
+			}
+			return err
+		}
+	}
+}
+
+func auditWebUIRequest(c echo.Context, identity *dice.WebUIIdentity, err error) {
+	log := logger.M()
+	if err != nil {
+		log.Infof("WebUI 操作: <%s> %s %s 状态 %d 错误: %v", identity.AuditActorName(), c.Request().Method, c.Path(), c.Response().Status, err)
+	} else {
+		log.Infof("WebUI 操作: <%s> %s %s 状态 %d", identity.AuditActorName(), c.Request().Method, c.Path(), c.Response().Status)
+	}
+
+	// 处理函数已经写过更具体的审计记录时，不再重复记录
+	if recorded, _ := c.Get(auditRecordedContextKey).(bool); recorded {
+		return
+	}
+	after := map[string]interface{}{"status": c.Response().Status}
+	if err != nil {
+		after["err"] = err.Error()
+	}
+	myDice.AuditRecord(dice.AuditEntry{
+		ActorType: dice.AuditActorWebUI,
+		ActorID:   identity.Username,
+		ActorName: identity.AuditActorName(),
+		Action:    dice.AuditActionWebUIRequest,
+		Target:    c.Request().Method + " " + c.Path(),
+		After:     after,
+		Place:     c.Request().URL.RequestURI(),
+	})
+}
+
+// auditWebUI 由处理函数调用，以当前 WebUI 身份写入一条具体的审计记录
+func auditWebUI(c echo.Context, action string, target string, before interface{}, after interface{}) {
+	identity, ok := getIdentity(c)
+	if !ok {
+		return
+	}
+	c.Set(auditRecordedContextKey, true)
+	myDice.AuditRecord(dice.AuditEntry{
+		ActorType: dice.AuditActorWebUI,
+		ActorID:   identity.Username,
+		ActorName: identity.AuditActorName(),
+		Action:    action,
+		Target:    target,
+		Before:    before,
+		After:     after,
+		Place:     c.Request().Method + " " + c.Path(),
+	})
+}
+
+func accountErrorStatus(err error) int {
+	if errors.Is(err, dice.ErrWebUIAccountNotFound) || errors.Is(err, dice.ErrWebUIAPITokenNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func accountMe(c echo.Context) error {
+	identity, ok := getIdentity(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"username":    identity.Username,
+		"role":        identity.Role,
+		"viaApiToken": identity.ViaAPIToken,
+		"permissions": identity.Role.Permissions(),
+	})
+}
+
+func accountRoles(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"roles":       dice.WebUIRolePermissions,
+		"permissions": dice.WebUIAllPermissions,
+	})
+}
+
+func accountList(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"items": myDice.Parent.WebUIAccountList(),
+	})
+}
+
+func accountAdd(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		Username string         `json:"username"`
+		Role     dice.WebUIRole `json:"role"`
+		Password string         `json:"password"` //nolint:gosec // 前端加盐哈希
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"err": err.Error()})
+	}
+	if err := myDice.Parent.WebUIAccountAdd(v.Username, v.Role, v.Password); err != nil {
+		return c.JSON(accountErrorStatus(err), map[string]string{"err": err.Error()})
+	}
+	myDice.Parent.Save()
+	return c.JSON(http.StatusOK, nil)
+}
+
+func accountUpdate(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		Username string          `json:"username"`
+		Role     *dice.WebUIRole `json:"role"`
+		Password *string         `json:"password"` //nolint:gosec // 前端加盐哈希
+		Disabled *bool           `json:"disabled"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"err": err.Error()})
+	}
+	if err := myDice.Parent.WebUIAccountUpdate(v.Username, v.Role, v.Password, v.Disabled); err != nil {
+		return c.JSON(accountErrorStatus(err), map[string]string{"err": err.Error()})
+	}
+	myDice.Parent.Save()
+	return c.JSON(http.StatusOK, nil)
+}
+
+func accountDelete(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		Username string `json:"username"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"err": err.Error()})
+	}
+	if err := myDice.Parent.WebUIAccountDelete(v.Username); err != nil {
+		return c.JSON(accountErrorStatus(err), map[string]string{"err": err.Error()})
+	}
+	myDice.Parent.Save()
+	return c.JSON(http.StatusOK, nil)
+}
+
+func accountTokenCreate(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"err": err.Error()})
+	}
+	plain, token, err := myDice.Parent.WebUIAccountCreateAPIToken(v.Username, v.Name)
+	if err != nil {
+		return c.JSON(accountErrorStatus(err), map[string]string{"err": err.Error()})
+	}
+	myDice.Parent.Save()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token": plain, // 明文只返回这一次
+		"item":  token,
+	})
+}
+
+func accountTokenRevoke(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		Username string `json:"username"`
+		ID       string `json:"id"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"err": err.Error()})
+	}
+	if err := myDice.Parent.WebUIAccountRevokeAPIToken(v.Username, v.ID); err != nil {
+		return c.JSON(accountErrorStatus(err), map[string]string{"err": err.Error()})
+	}
+	myDice.Parent.Save()
+	return c.JSON(http.StatusOK, nil)
+}
@@ -0,0 +1,95 @@
+package api //nolint:testpackage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+)
+
+func TestRequirePermissionRejectsInsufficientRole(t *testing.T) {
+	manager := &dice.DiceManager{UIPasswordHash: "ui-hash"}
+	testDice := &dice.Dice{Parent: manager}
+	manager.Dice = []*dice.Dice{testDice}
+	myDice = testDice
+	dm = manager
+	t.Cleanup(func() {
+		myDice = nil
+		dm = nil
+	})
+
+	if err := manager.WebUIAccountAdd("gm", dice.WebUIRoleContentEditor, "gm-hash"); err != nil {
+		t.Fatalf("WebUIAccountAdd() error = %v", err)
+	}
+	token, err := manager.WebUISignIn("gm", "gm-hash")
+	if err != nil {
+		t.Fatalf("WebUISignIn() error = %v", err)
+	}
+
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	e.POST("/js/execute", ok, requirePermission(dice.WebUIPermExtensionManage))
+	e.POST("/deck/upload", ok, requirePermission(dice.WebUIPermContentEdit))
+
+	cases := map[string]int{
+		"/js/execute":  http.StatusForbidden,
+		"/deck/upload": http.StatusOK,
+	}
+	for path, want := range cases {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("token", token) //nolint:canonicalheader // private header
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s status = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestImConnectionsRedactsSecretsForViewer(t *testing.T) {
+	manager := &dice.DiceManager{UIPasswordHash: "ui-hash"}
+	testDice := &dice.Dice{Parent: manager}
+	testDice.ImSession = &dice.IMSession{Parent: testDice, EndPoints: []*dice.EndPointInfo{{
+		EndPointInfoBase: dice.EndPointInfoBase{ID: "conn-1", Platform: "QQ"},
+		Adapter:          &dice.PlatformAdapterGocq{AccessToken: "onebot-secret"},
+	}}}
+	manager.Dice = []*dice.Dice{testDice}
+	myDice = testDice
+	dm = manager
+	t.Cleanup(func() {
+		myDice = nil
+		dm = nil
+	})
+
+	tokens := map[dice.WebUIRole]string{}
+	for name, role := range map[string]dice.WebUIRole{"viewer": dice.WebUIRoleViewer, "admin": dice.WebUIRoleAdmin} {
+		if err := manager.WebUIAccountAdd(name, role, name+"-hash"); err != nil {
+			t.Fatalf("WebUIAccountAdd() error = %v", err)
+		}
+		token, err := manager.WebUISignIn(name, name+"-hash")
+		if err != nil {
+			t.Fatalf("WebUISignIn() error = %v", err)
+		}
+		tokens[role] = token
+	}
+
+	e := echo.New()
+	e.GET("/im_connections/list", ImConnections, requirePermission(dice.WebUIPermView))
+	for role, wantSecret := range map[dice.WebUIRole]bool{dice.WebUIRoleViewer: false, dice.WebUIRoleAdmin: true} {
+		req := httptest.NewRequest(http.MethodGet, "/im_connections/list", nil)
+		req.Header.Set("token", tokens[role]) //nolint:canonicalheader // private header
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list as %s status = %d", role, rec.Code)
+		}
+		body := rec.Body.String()
+		if strings.Contains(body, "onebot-secret") != wantSecret || !strings.Contains(body, "conn-1") {
+			t.Fatalf("list as %s body = %s", role, body)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,8 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/danielgtaylor/huma/v2"
-	"github.com/danielgtaylor/huma/v2/adapters/humaecho"
 	"github.com/labstack/echo/v4"
 	"github.com/monaco-io/request"
 	"github.com/robfig/cron/v3"
@@ -182,6 +179,9 @@ func forceStop(c echo.Context) error {
 						}
 					}
 				}
+				if i.ExtDocs != nil {
+					i.ExtDocs.CloseAll()
+				}
 				i.IsAlreadyLoadConfig = false
 			}
 		}
@@ -226,7 +226,7 @@ func checkSecurity(c echo.Context) error {
 		return c.JSON(http.StatusForbidden, nil)
 	}
 	isPublicService := strings.HasPrefix(myDice.Parent.ServeAddress, "0.0.0.0") || myDice.Parent.ServeAddress == ":3211"
-	isEmptyPassword := myDice.Parent.UIPasswordHash == ""
+	isEmptyPassword := myDice.Parent.UIPasswordHash == "" && len(myDice.Parent.WebUIAccountList()) == 0
 	return c.JSON(200, map[string]bool{
 		"isOk": !isEmptyPassword || !isPublicService,
 	})
@@ -234,6 +234,7 @@ func checkSecurity(c echo.Context) error {
 
 func doSignIn(c echo.Context) error {
 	v := struct {
+		Username string `json:"username"`
 		Password string `json:"password"` //nolint:gosec
 	}{}
 
@@ -242,28 +243,15 @@ func doSignIn(c echo.Context) error {
 		return c.JSON(400, nil)
 	}
 
-	generateToken := func() error {
-		now := time.Now().Unix()
-		head := hex.EncodeToString(Int64ToBytes(now))
-		token := dice.RandStringBytesMaskImprSrcSB2(64) + ":" + head
-
-		myDice.Parent.AccessTokens.Store(token, true)
-		myDice.LastUpdatedTime = time.Now().Unix()
-		myDice.Parent.Save()
-		return c.JSON(http.StatusOK, map[string]string{
-			"token": token,
-		})
-	}
-
-	if myDice.Parent.UIPasswordHash == "" {
-		return generateToken()
-	}
-
-	if myDice.Parent.UIPasswordHash == v.Password {
-		return generateToken()
+	token, err := myDice.Parent.WebUISignIn(v.Username, v.Password)
+	if err != nil {
+		return c.JSON(400, nil)
 	}
-
-	return c.JSON(400, nil)
+	myDice.LastUpdatedTime = time.Now().Unix()
+	myDice.Parent.Save()
+	return c.JSON(http.StatusOK, map[string]string{
+		"token": token,
+	})
 }
 
 func logFetchAndClear(c echo.Context) error {
@@ -328,6 +316,8 @@ func DiceExec(c echo.Context) error {
 		Message         string `form:"message"`
 		MessageType     string `form:"messageType"`
 		MessageSplitLen *int   `form:"messageSplitLen" json:"messageSplitLen"`
+		// Explain 不执行指令，而是将 Message 作为表达式计算，返回逐步计算过程
+		Explain bool `form:"explain" json:"explain"`
 	}{}
 	err := c.Bind(&v)
 	if err != nil {
@@ -380,19 +370,33 @@ func DiceExec(c echo.Context) error {
 		GroupID:   groupID,
 		GroupName: groupName,
 	}
+	if v.Explain {
+		return diceExecExplain(c, msg)
+	}
 	myDice.ImSession.Execute(myDice.UIEndpoint, msg, false)
 	return c.JSON(200, "ok")
 }
 
+// diceExecExplain 以测试窗口的身份计算表达式，同步返回计算过程
+func diceExecExplain(c echo.Context, msg *dice.Message) error {
+	ctx := dice.CreateTempCtx(myDice.UIEndpoint, msg)
+	r, _, err := dice.DiceExprEvalBase(ctx, msg.Message, dice.RollExtraFlags{V2Only: true, DisableBlock: true})
+	if err != nil {
+		return c.JSON(400, err.Error())
+	}
+	ret := r.Explain()
+	if ret == nil {
+		return c.JSON(400, "仅支持新版骰点表达式")
+	}
+	return c.JSON(200, ret)
+}
+
 func DiceRecentMessage(c echo.Context) error {
 	if !doAuth(c) {
 		return c.JSON(http.StatusForbidden, nil)
 	}
 	pa := myDice.UIEndpoint.Adapter.(*dice.PlatformAdapterHTTP)
-	defer func() {
-		pa.RecentMessage = []dice.HTTPSimpleMessage{}
-	}()
-	return c.JSON(200, pa.RecentMessage)
+	return c.JSON(200, pa.TakeRecentMessages())
 }
 
 func DiceAllCommand(c echo.Context) error {
@@ -582,223 +586,270 @@ func Bind(e *echo.Echo, _myDice *dice.DiceManager) {
 
 	prefix := "/sd-api"
 
-	// 挂载 humaecho 到 echo 实例
-	_ = humaecho.New(e, huma.DefaultConfig("Sealdiciapi", "1.0.0"))
+	// 各路由所需的 WebUI 权限，见 dice.WebUIRolePermissions
+	view := requirePermission(dice.WebUIPermView)
+	logRead := requirePermission(dice.WebUIPermLogRead)
+	contentEdit := requirePermission(dice.WebUIPermContentEdit)
+	configEdit := requirePermission(dice.WebUIPermConfigEdit)
+	connManage := requirePermission(dice.WebUIPermConnectionManage)
+	extManage := requirePermission(dice.WebUIPermExtensionManage)
+	systemManage := requirePermission(dice.WebUIPermSystemManage)
+	accountManage := requirePermission(dice.WebUIPermAccountManage)
+	auditRead := requirePermission(dice.WebUIPermAuditRead)
+
+	// 版本化开放接口，见 v1.go
+	bindV1(e, prefix)
 
 	e.GET(prefix+"/preInfo", preInfo)
-	e.GET(prefix+"/baseInfo", baseInfo)
-	e.GET(prefix+"/hello", hello2)
-	e.GET(prefix+"/log/fetchAndClear", logFetchAndClear)
-	e.GET(prefix+"/im_connections/list", ImConnections)
-	e.GET(prefix+"/im_connections/get", ImConnectionsGet)
-
-	e.GET(prefix+"/im_connections/qq/get_versions", ImConnectionsGetQQVersions)
-	e.POST(prefix+"/im_connections/qrcode", ImConnectionsQrcodeGet)
-	e.POST(prefix+"/im_connections/sms_code_get", ImConnectionsSmsCodeGet)
-	e.POST(prefix+"/im_connections/sms_code_set", ImConnectionsSmsCodeSet)
-	e.POST(prefix+"/im_connections/gocq_captcha_set", ImConnectionsCaptchaSet)
+	e.GET(prefix+"/baseInfo", baseInfo, view)
+	e.GET(prefix+"/hello", hello2, view)
+	e.GET(prefix+"/log/fetchAndClear", logFetchAndClear, view)
+	e.GET(prefix+"/im_connections/list", ImConnections, view)
+	e.GET(prefix+"/im_connections/get", ImConnectionsGet, view)
+
+	e.GET(prefix+"/im_connections/qq/get_versions", ImConnectionsGetQQVersions, view)
+	e.POST(prefix+"/im_connections/qrcode", ImConnectionsQrcodeGet, connManage)
+	e.POST(prefix+"/im_connections/sms_code_get", ImConnectionsSmsCodeGet, connManage)
+	e.POST(prefix+"/im_connections/sms_code_set", ImConnectionsSmsCodeSet, connManage)
+	e.POST(prefix+"/im_connections/gocq_captcha_set", ImConnectionsCaptchaSet, connManage)
 
 	// 这些都是与QQ/OneBot直接相关
-	e.POST(prefix+"/im_connections/add", ImConnectionsAddBuiltinGocq) // 逐步弃用此链接
-	e.POST(prefix+"/im_connections/addGocq", ImConnectionsAddBuiltinGocq)
-	e.POST(prefix+"/im_connections/addOnebot11ReverseWs", ImConnectionsAddReverseWs)
-	e.POST(prefix+"/im_connections/addGocqSeparate", ImConnectionsAddGocqSeparate)
-	e.POST(prefix+"/im_connections/addLagrange", ImConnectionsAddBuiltinLagrange)
+	e.POST(prefix+"/im_connections/add", ImConnectionsAddBuiltinGocq, connManage) // 逐步弃用此链接
+	e.POST(prefix+"/im_connections/addGocq", ImConnectionsAddBuiltinGocq, connManage)
+	e.POST(prefix+"/im_connections/addOnebot11ReverseWs", ImConnectionsAddReverseWs, connManage)
+	e.POST(prefix+"/im_connections/addGocqSeparate", ImConnectionsAddGocqSeparate, connManage)
+	e.POST(prefix+"/im_connections/addLagrange", ImConnectionsAddBuiltinLagrange, connManage)
 	// e.POST(prefix+"/im_connections/addLagrangeGo", ImConnectionsAddLagrangeGO)
-	e.POST(prefix+"/im_connections/addRed", ImConnectionsAddRed)
-	e.POST(prefix+"/im_connections/addOfficialQQ", ImConnectionsAddOfficialQQ)
-
-	e.POST(prefix+"/im_connections/addDiscord", ImConnectionsAddDiscord)
-	e.POST(prefix+"/im_connections/addKook", ImConnectionsAddKook)
-	e.POST(prefix+"/im_connections/addTelegram", ImConnectionsAddTelegram)
-	e.POST(prefix+"/im_connections/addMinecraft", ImConnectionsAddMinecraft)
-	e.POST(prefix+"/im_connections/addDodo", ImConnectionsAddDodo)
-	e.POST(prefix+"/im_connections/addDingtalk", ImConnectionsAddDingTalk)
-	e.POST(prefix+"/im_connections/addSlack", ImConnectionsAddSlack)
-	e.POST(prefix+"/im_connections/addSealChat", ImConnectionsAddSealChat)
-	e.POST(prefix+"/im_connections/addSatori", ImConnectionsAddSatori)
-	e.POST(prefix+"/im_connections/addMilky", ImConnectionsAddMilky)
-	e.POST(prefix+"/im_connections/addMilkyInternal", ImConnectionsAddMilkyInternal)
-
-	e.POST(prefix+"/im_connections/del", ImConnectionsDel)
-	e.POST(prefix+"/im_connections/set_enable", ImConnectionsSetEnable)
-	e.POST(prefix+"/im_connections/set_data", ImConnectionsSetData)
-	e.GET(prefix+"/im_connections/get_lgr_signinfo", ImConnectionsGetSignInfo)
-	e.POST(prefix+"/im_connections/gocqhttpRelogin", ImConnectionsGocqhttpRelogin)
-	e.POST(prefix+"/im_connections/walleQRelogin", ImConnectionsWalleQRelogin)
-	e.GET(prefix+"/im_connections/gocq_config_download.zip", ImConnectionsGocqConfigDownload)
-
-	e.GET(prefix+"/configs/customText", customText)
-	e.POST(prefix+"/configs/customText/save", customTextSave)
-	e.POST(prefix+"/configs/customText/preview-refresh", customTextPreviewRefresh)
-
-	e.GET(prefix+"/configs/custom_reply", customReplyGet)
-	e.POST(prefix+"/configs/custom_reply/save", customReplySave)
-	e.GET(prefix+"/configs/custom_reply/file_list", customReplyFileList)
-	e.POST(prefix+"/configs/custom_reply/file_new", customReplyFileNew)
-	e.POST(prefix+"/configs/custom_reply/file_delete", customReplyFileDelete)
-	e.GET(prefix+"/configs/custom_reply/file_download", customReplyFileDownload)
-	e.POST(prefix+"/configs/custom_reply/file_upload", customReplyFileUpload)
-	e.GET(prefix+"/configs/custom_reply/debug_mode", customReplyDebugModeGet)
-	e.POST(prefix+"/configs/custom_reply/debug_mode", customReplyDebugModeSet)
-
-	e.GET(prefix+"/dice/config/get", DiceConfig)
-	e.POST(prefix+"/dice/config/set", DiceConfigSet)
-	e.GET(prefix+"/dice/config/advanced/get", DiceAdvancedConfigGet)
-	e.POST(prefix+"/dice/config/advanced/set", DiceAdvancedConfigSet)
-	e.POST(prefix+"/dice/config/mail_test", DiceMailTest)
-	e.GET(prefix+"/dice/exec/split_options", DiceExecSplitOptions)
-	e.POST(prefix+"/dice/exec", DiceExec)
-	e.GET(prefix+"/dice/recentMessage", DiceRecentMessage)
-	e.GET(prefix+"/dice/cmdList", DiceAllCommand)
-	e.POST(prefix+"/dice/upload_to_upgrade", DiceNewVersionUpload)
-
-	e.GET(prefix+"/dice/public/info", dicePublicInfo)
-	e.POST(prefix+"/dice/public/set", dicePublicSet)
-
-	e.POST(prefix+"/dice/config/vm-version-set", vmVersionSet)
+	e.POST(prefix+"/im_connections/addRed", ImConnectionsAddRed, connManage)
+	e.POST(prefix+"/im_connections/addOfficialQQ", ImConnectionsAddOfficialQQ, connManage)
+
+	e.POST(prefix+"/im_connections/addDiscord", ImConnectionsAddDiscord, connManage)
+	e.POST(prefix+"/im_connections/addKook", ImConnectionsAddKook, connManage)
+	e.POST(prefix+"/im_connections/addTelegram", ImConnectionsAddTelegram, connManage)
+	e.POST(prefix+"/im_connections/addMinecraft", ImConnectionsAddMinecraft, connManage)
+	e.POST(prefix+"/im_connections/addDodo", ImConnectionsAddDodo, connManage)
+	e.POST(prefix+"/im_connections/addDingtalk", ImConnectionsAddDingTalk, connManage)
+	e.POST(prefix+"/im_connections/addSlack", ImConnectionsAddSlack, connManage)
+	e.POST(prefix+"/im_connections/addSealChat", ImConnectionsAddSealChat, connManage)
+	e.POST(prefix+"/im_connections/addSatori", ImConnectionsAddSatori, connManage)
+	e.POST(prefix+"/im_connections/addMilky", ImConnectionsAddMilky, connManage)
+	e.POST(prefix+"/im_connections/addMilkyInternal", ImConnectionsAddMilkyInternal, connManage)
+
+	e.POST(prefix+"/im_connections/del", ImConnectionsDel, connManage)
+	e.POST(prefix+"/im_connections/set_enable", ImConnectionsSetEnable, connManage)
+	e.POST(prefix+"/im_connections/set_data", ImConnectionsSetData, connManage)
+	e.GET(prefix+"/im_connections/get_lgr_signinfo", ImConnectionsGetSignInfo, view)
+	e.POST(prefix+"/im_connections/gocqhttpRelogin", ImConnectionsGocqhttpRelogin, connManage)
+	e.POST(prefix+"/im_connections/walleQRelogin", ImConnectionsWalleQRelogin, connManage)
+	e.GET(prefix+"/im_connections/gocq_config_download.zip", ImConnectionsGocqConfigDownload, connManage)
+
+	e.GET(prefix+"/configs/customText", customText, view)
+	e.POST(prefix+"/configs/customText/save", customTextSave, contentEdit)
+	e.POST(prefix+"/configs/customText/preview-refresh", customTextPreviewRefresh, contentEdit)
+
+	e.GET(prefix+"/configs/custom_reply", customReplyGet, view)
+	e.POST(prefix+"/configs/custom_reply/save", customReplySave, contentEdit)
+	e.GET(prefix+"/configs/custom_reply/file_list", customReplyFileList, view)
+	e.POST(prefix+"/configs/custom_reply/file_new", customReplyFileNew, contentEdit)
+	e.POST(prefix+"/configs/custom_reply/file_delete", customReplyFileDelete, contentEdit)
+	e.GET(prefix+"/configs/custom_reply/file_download", customReplyFileDownload, view)
+	e.POST(prefix+"/configs/custom_reply/file_upload", customReplyFileUpload, contentEdit)
+	e.GET(prefix+"/configs/custom_reply/debug_mode", customReplyDebugModeGet, view)
+	e.POST(prefix+"/configs/custom_reply/debug_mode", customReplyDebugModeSet, contentEdit)
+
+	e.GET(prefix+"/dice/config/get", DiceConfig, view)
+	e.POST(prefix+"/dice/config/set", DiceConfigSet, configEdit)
+	e.GET(prefix+"/dice/config/advanced/get", DiceAdvancedConfigGet, view)
+	e.POST(prefix+"/dice/config/advanced/set", DiceAdvancedConfigSet, configEdit)
+	e.POST(prefix+"/dice/config/mail_test", DiceMailTest, configEdit)
+	e.GET(prefix+"/dice/exec/split_options", DiceExecSplitOptions, view)
+	e.POST(prefix+"/dice/exec", DiceExec, systemManage)
+	e.GET(prefix+"/dice/recentMessage", DiceRecentMessage, systemManage)
+	e.GET(prefix+"/dice/cmdList", DiceAllCommand, view)
+	e.POST(prefix+"/dice/upload_to_upgrade", DiceNewVersionUpload, systemManage)
+
+	e.GET(prefix+"/dice/public/info", dicePublicInfo, view)
+	e.POST(prefix+"/dice/public/set", dicePublicSet, configEdit)
+
+	e.POST(prefix+"/dice/config/vm-version-set", vmVersionSet, configEdit)
+	e.GET(prefix+"/dice/config/vm-migrate", vmMigrateAnalyze, configEdit)
+	e.POST(prefix+"/dice/config/vm-migrate/apply", vmMigrateApply, configEdit)
 
 	e.POST(prefix+"/signin", doSignIn)
 	e.GET(prefix+"/signin/salt", doSignInGetSalt)
-	e.GET(prefix+"/checkSecurity", checkSecurity)
-
-	e.GET(prefix+"/backup/list", backupGetList)
-	e.POST(prefix+"/backup/do_backup", backupExec)
-	e.GET(prefix+"/backup/config_get", backupConfigGet)
-	e.POST(prefix+"/backup/config_set", backupConfigSave)
-	e.GET(prefix+"/backup/download", backupDownload)
-	e.POST(prefix+"/backup/delete", backupDelete)
-	e.POST(prefix+"/backup/batch_delete", backupBatchDelete)
-
-	e.GET(prefix+"/group/list", groupList)
-	e.POST(prefix+"/group/set_one", groupSetOne)
-	e.POST(prefix+"/group/quit_one", groupQuit)
-
-	e.GET(prefix+"/banconfig/list", banMapList)
-	e.GET(prefix+"/banconfig/get", banConfigGet)
-	e.POST(prefix+"/banconfig/set", banConfigSet)
+	e.GET(prefix+"/checkSecurity", checkSecurity, view)
+
+	e.GET(prefix+"/account/me", accountMe)
+	e.GET(prefix+"/account/roles", accountRoles, view)
+	e.GET(prefix+"/account/list", accountList, accountManage)
+	e.POST(prefix+"/account/add", accountAdd, accountManage)
+	e.POST(prefix+"/account/update", accountUpdate, accountManage)
+	e.POST(prefix+"/account/delete", accountDelete, accountManage)
+	e.POST(prefix+"/account/token/create", accountTokenCreate, accountManage)
+	e.POST(prefix+"/account/token/revoke", accountTokenRevoke, accountManage)
+
+	e.GET(prefix+"/audit/page", auditGetLogPage, auditRead)
+	e.GET(prefix+"/audit/export", auditExport, auditRead)
+
+	e.GET(prefix+"/backup/list", backupGetList, systemManage)
+	e.POST(prefix+"/backup/do_backup", backupExec, systemManage)
+	e.GET(prefix+"/backup/config_get", backupConfigGet, systemManage)
+	e.POST(prefix+"/backup/config_set", backupConfigSave, systemManage)
+	e.GET(prefix+"/backup/download", backupDownload, systemManage)
+	e.POST(prefix+"/backup/delete", backupDelete, systemManage)
+	e.POST(prefix+"/backup/batch_delete", backupBatchDelete, systemManage)
+
+	e.GET(prefix+"/group/list", groupList, view)
+	e.POST(prefix+"/group/set_one", groupSetOne, configEdit)
+	e.POST(prefix+"/group/quit_one", groupQuit, configEdit)
+
+	e.GET(prefix+"/banconfig/list", banMapList, view)
+	e.GET(prefix+"/banconfig/get", banConfigGet, view)
+	e.POST(prefix+"/banconfig/set", banConfigSet, configEdit)
 	// e.GET(prefix+"/banconfig/map_get", banMapGet)
-	e.POST(prefix+"/banconfig/map_delete_one", banMapDeleteOne)
-	e.POST(prefix+"/banconfig/map_add_one", banMapAddOne)
+	e.POST(prefix+"/banconfig/map_delete_one", banMapDeleteOne, configEdit)
+	e.POST(prefix+"/banconfig/map_add_one", banMapAddOne, configEdit)
 	// e.POST(prefix+"/banconfig/map_set", banMapSet)
-	e.GET(prefix+"/banconfig/export", banExport)
-	e.POST(prefix+"/banconfig/import", banImport)
-
-	e.GET(prefix+"/deck/list", deckList)
-	e.POST(prefix+"/deck/reload", deckReload)
-	e.POST(prefix+"/deck/upload", deckUpload)
-	e.POST(prefix+"/deck/enable", deckEnable)
-	e.POST(prefix+"/deck/delete", deckDelete)
-	e.POST(prefix+"/deck/check_update", deckCheckUpdate)
-	e.POST(prefix+"/deck/update", deckUpdate)
-
-	e.POST(prefix+"/dice/upgrade", upgrade)
-
-	e.POST(prefix+"/force_stop", forceStop)
-
-	e.POST(prefix+"/js/reload", jsReload)
-	e.POST(prefix+"/js/execute", jsExec)
-	e.POST(prefix+"/js/upload", jsUpload)
-	e.GET(prefix+"/js/list", jsList)
-	e.POST(prefix+"/js/delete", jsDelete)
-	e.GET(prefix+"/js/get_record", jsGetRecord)
-	e.POST(prefix+"/js/shutdown", jsShutdown)
-	e.GET(prefix+"/js/status", jsStatus)
-	e.POST(prefix+"/js/enable", jsEnable)
-	e.POST(prefix+"/js/disable", jsDisable)
-	e.POST(prefix+"/js/check_update", jsCheckUpdate)
-	e.POST(prefix+"/js/update", jsUpdate)
-	e.GET(prefix+"/js/get_configs", handleGetConfigs)
-	e.POST(prefix+"/js/set_configs", handleSetConfigs)
-	e.POST(prefix+"/js/delete_unused_configs", handleDeleteUnusedConfigs)
-	e.POST(prefix+"/js/reset_config", handleResetConfig)
-
-	e.GET(prefix+"/helpdoc/status", helpDocStatus)
-	e.GET(prefix+"/helpdoc/tree", helpDocTree)
-	e.POST(prefix+"/helpdoc/reload", helpDocReload)
-	e.POST(prefix+"/helpdoc/upload", helpDocUpload)
-	e.POST(prefix+"/helpdoc/delete", helpDocDelete)
-	e.POST(prefix+"/helpdoc/textitem/get_page", helpGetTextItemPage)
-	e.GET(prefix+"/helpdoc/config", helpGetConfig)
-	e.POST(prefix+"/helpdoc/config", helpSetConfig)
-
-	e.GET(prefix+"/story/info", storyGetInfo)
-	e.GET(prefix+"/story/logs", storyGetLogs)
-	e.GET(prefix+"/story/logs/page", storyGetLogPage)
-	e.GET(prefix+"/story/items", storyGetItems)
-	e.GET(prefix+"/story/items/page", storyGetItemPage)
-	e.DELETE(prefix+"/story/log", storyDelLog)
-	e.POST(prefix+"/story/uploadLog", storyUploadLog)
-	e.GET(prefix+"/story/backup/list", storyGetLogBackupList)
-	e.GET(prefix+"/story/backup/download", storyDownloadLogBackup)
-	e.POST(prefix+"/story/backup/batch_delete", storyBatchDeleteLogBackup)
-
-	e.POST(prefix+"/tool/onebot", onebotTool)
+	e.GET(prefix+"/banconfig/export", banExport, view)
+	e.POST(prefix+"/banconfig/import", banImport, configEdit)
+
+	e.GET(prefix+"/deck/list", deckList, view)
+	e.POST(prefix+"/deck/reload", deckReload, contentEdit)
+	e.POST(prefix+"/deck/upload", deckUpload, contentEdit)
+	e.POST(prefix+"/deck/enable", deckEnable, contentEdit)
+	e.POST(prefix+"/deck/delete", deckDelete, contentEdit)
+	e.POST(prefix+"/deck/check_update", deckCheckUpdate, contentEdit)
+	e.POST(prefix+"/deck/update", deckUpdate, contentEdit)
+
+	e.POST(prefix+"/dice/upgrade", upgrade, systemManage)
+
+	e.POST(prefix+"/force_stop", forceStop, systemManage)
+
+	e.POST(prefix+"/js/reload", jsReload, extManage)
+	e.POST(prefix+"/js/execute", jsExec, extManage)
+	e.POST(prefix+"/js/upload", jsUpload, extManage)
+	e.GET(prefix+"/js/list", jsList, view)
+	e.POST(prefix+"/js/delete", jsDelete, extManage)
+	e.GET(prefix+"/js/get_record", jsGetRecord, extManage)
+	e.POST(prefix+"/js/shutdown", jsShutdown, extManage)
+	e.GET(prefix+"/js/status", jsStatus, view)
+	e.POST(prefix+"/js/enable", jsEnable, extManage)
+	e.POST(prefix+"/js/disable", jsDisable, extManage)
+	e.POST(prefix+"/js/check_update", jsCheckUpdate, extManage)
+	e.POST(prefix+"/js/update", jsUpdate, extManage)
+	e.GET(prefix+"/js/get_configs", handleGetConfigs, extManage)
+	e.POST(prefix+"/js/set_configs", handleSetConfigs, extManage)
+	e.POST(prefix+"/js/delete_unused_configs", handleDeleteUnusedConfigs, extManage)
+	e.POST(prefix+"/js/reset_config", handleResetConfig, extManage)
+	// 扩展自行注册的路由，鉴权由路由自身决定
+	e.Any(prefix+"/ext/:ext/*", jsExtHTTP)
+
+	e.GET(prefix+"/ext_docs/list", extDocsList, extManage)
+	e.GET(prefix+"/ext_docs/collections", extDocsCollections, extManage)
+	e.GET(prefix+"/ext_docs/docs", extDocsBrowse, extManage)
+	e.POST(prefix+"/ext_docs/delete", extDocsDelete, extManage)
+	e.GET(prefix+"/ext_docs/export", extDocsExport, extManage)
+	e.POST(prefix+"/ext_docs/import", extDocsImport, extManage)
+	e.POST(prefix+"/ext_docs/migrate", extDocsMigrate, extManage)
+
+	e.GET(prefix+"/helpdoc/status", helpDocStatus, view)
+	e.GET(prefix+"/helpdoc/tree", helpDocTree, view)
+	e.POST(prefix+"/helpdoc/reload", helpDocReload, contentEdit)
+	e.POST(prefix+"/helpdoc/upload", helpDocUpload, contentEdit)
+	e.POST(prefix+"/helpdoc/delete", helpDocDelete, contentEdit)
+	e.POST(prefix+"/helpdoc/textitem/get_page", helpGetTextItemPage, view)
+	e.GET(prefix+"/helpdoc/config", helpGetConfig, view)
+	e.POST(prefix+"/helpdoc/config", helpSetConfig, contentEdit)
+
+	e.GET(prefix+"/story/info", storyGetInfo, logRead)
+	e.GET(prefix+"/story/logs", storyGetLogs, logRead)
+	e.GET(prefix+"/story/logs/page", storyGetLogPage, logRead)
+	e.GET(prefix+"/story/items", storyGetItems, logRead)
+	e.GET(prefix+"/story/items/page", storyGetItemPage, logRead)
+	e.GET(prefix+"/story/stats", storyGetStats, logRead)
+	e.DELETE(prefix+"/story/log", storyDelLog, configEdit)
+	e.POST(prefix+"/story/uploadLog", storyUploadLog, logRead)
+	e.GET(prefix+"/story/backup/list", storyGetLogBackupList, logRead)
+	e.GET(prefix+"/story/backup/download", storyDownloadLogBackup, logRead)
+	e.POST(prefix+"/story/backup/batch_delete", storyBatchDeleteLogBackup, configEdit)
+
+	e.GET(prefix+"/campaign/list", campaignList, logRead)
+	e.GET(prefix+"/campaign/get", campaignGet, logRead)
+	e.GET(prefix+"/campaign/stats", campaignGetStats, logRead)
+
+	e.POST(prefix+"/tool/onebot", onebotTool, systemManage)
 	e.GET(prefix+"/utils/ga/:uid", getGithubAvatar)
-	e.GET(prefix+"/utils/news", getNews)
-	e.POST(prefix+"/utils/check_news", checkNews)
-	e.GET(prefix+"/utils/get_token", getToken)
-	e.POST(prefix+"/utils/check_cron_expr", checkCronExpr)
-	e.GET(prefix+"/utils/check_network_health", checkNetworkHealth)
-
-	e.POST(prefix+"/censor/restart", censorRestart)
-	e.POST(prefix+"/censor/stop", censorStop)
-	e.GET(prefix+"/censor/status", censorGetStatus)
-	e.GET(prefix+"/censor/config", censorGetConfig)
-	e.POST(prefix+"/censor/config", censorSetConfig)
-	e.GET(prefix+"/censor/words", censorGetWords)
-	e.GET(prefix+"/censor/files", censorGetWordFiles)
-	e.POST(prefix+"/censor/files/upload", censorUploadWordFiles)
-	e.DELETE(prefix+"/censor/files", censorDeleteWordFiles)
-	e.GET(prefix+"/censor/files/template/toml", censorGetTomlFileTemplate)
-	e.GET(prefix+"/censor/files/template/txt", censorGetTxtFileTemplate)
-	e.GET(prefix+"/censor/logs/page", censorGetLogPage)
-
-	e.GET(prefix+"/resource/page", resourceGetList)
-	e.GET(prefix+"/resource/download", resourceDownload)
-	e.POST(prefix+"/resource", resourceUpload)
-	e.DELETE(prefix+"/resource", resourceDelete)
-	e.GET(prefix+"/resource/data", resourceGetData)
-
-	e.GET(prefix+"/verify/generate_code", verifyGenerateCode)
-
-	e.GET(prefix+"/store/backend/list", storeBackendList)
-	e.POST(prefix+"/store/backend/add", storeAddBackend)
-	e.POST(prefix+"/store/backend/enable", storeEnableBackend)
-	e.POST(prefix+"/store/backend/disable", storeDisableBackend)
-	e.DELETE(prefix+"/store/backend/remove", storeRemoveBackend)
-	e.GET(prefix+"/store/recommend", storeRecommend)
-	e.GET(prefix+"/store/page", storeGetPage)
-	e.GET(prefix+"/store/files/:namespace/:package/:version", storePackageFiles)
-	e.GET(prefix+"/store/file/:namespace/:package/:version", storePackageFilePreview)
-	e.GET("/dice/api/store/files/:namespace/:package/:version", storePackageFiles)
-	e.GET("/dice/api/store/file/:namespace/:package/:version", storePackageFilePreview)
-	e.POST(prefix+"/store/preview-download", storePreviewDownload)
-	e.POST(prefix+"/store/download", storeDownload)
-	e.POST(prefix+"/store/package-info-list", storePackageInfoList)
-	e.POST(prefix+"/store/install-list", storeInstallList)
-	e.POST(prefix+"/store/rating", storeRating)
+	e.GET(prefix+"/utils/news", getNews, view)
+	e.POST(prefix+"/utils/check_news", checkNews, view)
+	e.GET(prefix+"/utils/get_token", getToken, view)
+	e.POST(prefix+"/utils/check_cron_expr", checkCronExpr, view)
+	e.GET(prefix+"/utils/check_network_health", checkNetworkHealth, view)
+
+	e.POST(prefix+"/censor/restart", censorRestart, configEdit)
+	e.POST(prefix+"/censor/stop", censorStop, configEdit)
+	e.GET(prefix+"/censor/status", censorGetStatus, view)
+	e.GET(prefix+"/censor/config", censorGetConfig, view)
+	e.POST(prefix+"/censor/config", censorSetConfig, configEdit)
+	e.GET(prefix+"/censor/words", censorGetWords, view)
+	e.GET(prefix+"/censor/files", censorGetWordFiles, view)
+	e.POST(prefix+"/censor/files/upload", censorUploadWordFiles, configEdit)
+	e.DELETE(prefix+"/censor/files", censorDeleteWordFiles, configEdit)
+	e.GET(prefix+"/censor/files/template/toml", censorGetTomlFileTemplate, view)
+	e.GET(prefix+"/censor/files/template/txt", censorGetTxtFileTemplate, view)
+	e.GET(prefix+"/censor/logs/page", censorGetLogPage, logRead)
+
+	e.GET(prefix+"/resource/page", resourceGetList, view)
+	e.GET(prefix+"/resource/download", resourceDownload, view)
+	e.POST(prefix+"/resource", resourceUpload, contentEdit)
+	e.DELETE(prefix+"/resource", resourceDelete, contentEdit)
+	e.GET(prefix+"/resource/data", resourceGetData, view)
+
+	e.GET(prefix+"/verify/generate_code", verifyGenerateCode, view)
+
+	e.GET(prefix+"/store/backend/list", storeBackendList, view)
+	e.POST(prefix+"/store/backend/add", storeAddBackend, extManage)
+	e.POST(prefix+"/store/backend/enable", storeEnableBackend, extManage)
+	e.POST(prefix+"/store/backend/disable", storeDisableBackend, extManage)
+	e.DELETE(prefix+"/store/backend/remove", storeRemoveBackend, extManage)
+	e.GET(prefix+"/store/recommend", storeRecommend, view)
+	e.GET(prefix+"/store/page", storeGetPage, view)
+	e.GET(prefix+"/store/files/:namespace/:package/:version", storePackageFiles, view)
+	e.GET(prefix+"/store/file/:namespace/:package/:version", storePackageFilePreview, view)
+	e.GET("/dice/api/store/files/:namespace/:package/:version", storePackageFiles, view)
+	e.GET("/dice/api/store/file/:namespace/:package/:version", storePackageFilePreview, view)
+	e.POST(prefix+"/store/preview-download", storePreviewDownload, extManage)
+	e.POST(prefix+"/store/download", storeDownload, extManage)
+	e.POST(prefix+"/store/package-info-list", storePackageInfoList, extManage)
+	e.POST(prefix+"/store/install-list", storeInstallList, extManage)
+	e.POST(prefix+"/store/rating", storeRating, extManage)
 
 	// 扩展包管理
-	e.GET(prefix+"/package/list", packageList)
-	e.POST(prefix+"/package/refresh", packageRefresh)
-	e.GET(prefix+"/package/asset", packageAsset)
-	e.GET(prefix+"/package/:id", packageGet)
-	e.POST(prefix+"/package/preview-upload", packagePreviewFromUpload)
-	e.POST(prefix+"/package/upload-preview", packagePreviewFromUpload)
-	e.POST(prefix+"/package/install-upload", packageInstallFromUpload)
-	e.POST(prefix+"/package/upload-install", packageInstallFromUpload)
-	e.POST(prefix+"/package/install-url", packageInstallFromURL)
-	e.POST(prefix+"/package/install-from-url", packageInstallFromURL)
-	e.POST(prefix+"/package/uninstall", packageUninstall)
-	e.POST(prefix+"/package/enable", packageEnable)
-	e.POST(prefix+"/package/disable", packageDisable)
-	e.POST(prefix+"/package/reload", packageReload)
-	e.POST(prefix+"/package/reload-content", packageReloadContent)
-	e.POST(prefix+"/package/reload-all", packageReloadAll)
-	e.GET(prefix+"/package/:id/config", packageGetConfig)
-	e.POST(prefix+"/package/:id/config", packageSetConfig)
-	e.GET(prefix+"/package/:id/config-schema", packageGetConfigSchema)
-
-	bindPProfAPIs(e, prefix)
+	e.GET(prefix+"/package/list", packageList, view)
+	e.POST(prefix+"/package/refresh", packageRefresh, extManage)
+	e.GET(prefix+"/package/asset", packageAsset, view)
+	e.GET(prefix+"/package/updates", packageUpdates, view)
+	e.POST(prefix+"/package/update", packageUpdate, extManage)
+	e.POST(prefix+"/package/resolve", packageResolve, extManage)
+	e.GET(prefix+"/package/:id", packageGet, view)
+	e.POST(prefix+"/package/preview-upload", packagePreviewFromUpload, extManage)
+	e.POST(prefix+"/package/upload-preview", packagePreviewFromUpload, extManage)
+	e.POST(prefix+"/package/install-upload", packageInstallFromUpload, extManage)
+	e.POST(prefix+"/package/upload-install", packageInstallFromUpload, extManage)
+	e.POST(prefix+"/package/install-url", packageInstallFromURL, extManage)
+	e.POST(prefix+"/package/install-from-url", packageInstallFromURL, extManage)
+	e.POST(prefix+"/package/uninstall", packageUninstall, extManage)
+	e.POST(prefix+"/package/enable", packageEnable, extManage)
+	e.POST(prefix+"/package/disable", packageDisable, extManage)
+	e.POST(prefix+"/package/reload", packageReload, extManage)
+	e.POST(prefix+"/package/reload-content", packageReloadContent, extManage)
+	e.POST(prefix+"/package/reload-all", packageReloadAll, extManage)
+	e.GET(prefix+"/package/:id/config", packageGetConfig, extManage)
+	e.POST(prefix+"/package/:id/config", packageSetConfig, extManage)
+	e.GET(prefix+"/package/:id/config-schema", packageGetConfigSchema, view)
+	e.GET(prefix+"/package/trust/list", packageTrustList, view)
+	e.POST(prefix+"/package/trust/add", packageTrustAdd, extManage)
+	e.POST(prefix+"/package/trust/remove", packageTrustRemove, extManage)
+	e.POST(prefix+"/package/trust/policy", packageTrustSetPolicy, extManage)
+
+	bindPProfAPIs(e, prefix, systemManage)
 }
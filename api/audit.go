@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice/service"
+)
+
+func auditGetLogPage(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+
+	v := service.QueryAuditLog{}
+	if err := c.Bind(&v); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"err": err.Error()})
+	}
+	if v.PageNum < 1 {
+		v.PageNum = 1
+	}
+	if v.PageSize < 1 {
+		v.PageSize = 20
+	}
+
+	total, page, err := service.AuditGetLogPage(myDice.DBOperator, v)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data":     page,
+		"total":    total,
+		"pageNum":  v.PageNum,
+		"pageSize": len(page),
+	})
+}
+
+// auditExport 按筛选条件导出审计记录，format 为 json（默认）或 csv
+func auditExport(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+
+	v := service.QueryAuditLog{}
+	if err := c.Bind(&v); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"err": err.Error()})
+	}
+	logs, err := service.AuditExport(myDice.DBOperator, v)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+
+	name := "audit-" + time.Now().Format("20060102-150405")
+	switch c.QueryParam("format") {
+	case "csv":
+		buf := &bytes.Buffer{}
+		w := csv.NewWriter(buf)
+		_ = w.Write([]string{"id", "time", "actorType", "actorId", "actorName", "action", "target", "place", "before", "after"})
+		for _, item := range logs {
+			_ = w.Write([]string{
+				strconv.FormatUint(item.ID, 10),
+				time.Unix(item.CreatedAt, 0).Format(time.RFC3339),
+				item.ActorType,
+				item.ActorID,
+				item.ActorName,
+				item.Action,
+				item.Target,
+				item.Place,
+				item.Before,
+				item.After,
+			})
+		}
+		w.Flush()
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name+".csv"))
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	default:
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name+".json"))
+		return c.JSON(http.StatusOK, logs)
+	}
+}
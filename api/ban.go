@@ -94,31 +94,35 @@ func banMapAddOne(c echo.Context) error {
 	if err != nil {
 		return c.String(430, err.Error())
 	}
-	if v.Rank == dice.BanRankBanned {
+	banListSetOne(v.ID, v.Name, v.Rank, v.Reasons)
+	return c.JSON(http.StatusOK, nil)
+}
+
+// banListSetOne 由后台将用户或群组设为禁止或信任，其他等级不做处理
+func banListSetOne(id string, name string, rank dice.BanRankType, reasons []string) {
+	if rank == dice.BanRankBanned {
 		score := myDice.Config.BanList.ThresholdBan
 		reason := "骰主后台设置"
-		if len(v.Reasons) > 0 {
-			reason = v.Reasons[0]
+		if len(reasons) > 0 {
+			reason = reasons[0]
 		}
 
-		prefix := strings.Split(v.ID, ":")[0]
+		prefix := strings.Split(id, ":")[0]
 		platform := strings.Replace(prefix, "-Group", "", 1)
 		for _, i := range myDice.ImSession.EndPoints {
 			if i.Platform == platform && i.Enable {
-				v2 := (&myDice.Config).BanList.AddScoreBase(v.ID, score, "海豹后台", reason, &dice.MsgContext{Dice: myDice, EndPoint: i})
+				v2 := (&myDice.Config).BanList.AddScoreBase(id, score, "海豹后台", reason, &dice.MsgContext{Dice: myDice, EndPoint: i})
 				if v2 != nil {
-					if v.Name != "" {
-						v2.Name = v.Name
+					if name != "" {
+						v2.Name = name
 					}
 				}
 			}
 		}
 	}
-	if v.Rank == dice.BanRankTrusted {
-		(&myDice.Config).BanList.SetTrustByID(v.ID, "海豹后台", "骰主后台设置")
+	if rank == dice.BanRankTrusted {
+		(&myDice.Config).BanList.SetTrustByID(id, "海豹后台", "骰主后台设置")
 	}
-
-	return c.JSON(http.StatusOK, nil)
 }
 
 //
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice/service"
+)
+
+func campaignQueryID(c echo.Context) (uint64, error) {
+	id, err := strconv.ParseUint(c.QueryParam("id"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("无效的战役ID")
+	}
+	return id, nil
+}
+
+// campaignList 列出全部战役
+func campaignList(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	items, err := service.CampaignList(myDice.DBOperator)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{"data": items})
+}
+
+// campaignGet 战役详情，包括成员、关联群、各团次的日志与全部笔记
+func campaignGet(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	id, err := campaignQueryID(c)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	detail, err := service.CampaignGetDetail(myDice.DBOperator, id, 0)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{"data": detail})
+}
+
+// campaignGetStats 战役全部团次日志的检定统计，by=user 时按用户汇总，否则按角色汇总
+func campaignGetStats(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	id, err := campaignQueryID(c)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if _, err = service.CampaignGet(myDice.DBOperator, id); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	summaries, err := myDice.CampaignStats(id, c.QueryParam("by") != "user")
+	if err != nil {
+		myDice.Logger.Error("campaignGetStats", err)
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{"data": summaries})
+}
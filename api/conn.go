@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
@@ -20,7 +21,54 @@ func ImConnections(c echo.Context) error {
 		return c.JSON(http.StatusForbidden, nil)
 	}
 
-	return c.JSON(http.StatusOK, myDice.ImSession.EndPoints)
+	return c.JSON(http.StatusOK, connectionsForViewer(c, myDice.ImSession.EndPoints))
+}
+
+// connectionSecretKeys 连接信息中需要对无连接管理权限的账号隐藏的字段
+var connectionSecretKeys = map[string]bool{
+	"accessToken":   true,
+	"token":         true,
+	"key":           true,
+	"authorization": true,
+	"password":      true,
+	"secret":        true,
+	"appSecret":     true,
+}
+
+// connectionsForViewer 没有连接管理权限时，返回去掉令牌等凭据的连接信息
+func connectionsForViewer(c echo.Context, v interface{}) interface{} {
+	if identity, ok := getIdentity(c); !ok || identity.Can(dice.WebUIPermConnectionManage) {
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	if err = json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	redactConnectionSecrets(out)
+	return out
+}
+
+func redactConnectionSecrets(v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if str, ok := item.(string); ok && connectionSecretKeys[k] {
+				if str != "" {
+					val[k] = "******"
+				}
+				continue
+			}
+			redactConnectionSecrets(item)
+		}
+	case []interface{}:
+		for _, item := range val {
+			redactConnectionSecrets(item)
+		}
+	}
 }
 
 func ImConnectionsGet(c echo.Context) error {
@@ -35,7 +83,7 @@ func ImConnectionsGet(c echo.Context) error {
 	if err == nil {
 		for _, i := range myDice.ImSession.EndPoints {
 			if i.ID == v.ID {
-				return c.JSON(http.StatusOK, i)
+				return c.JSON(http.StatusOK, connectionsForViewer(c, i))
 			}
 		}
 	}
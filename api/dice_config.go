@@ -32,7 +32,11 @@ func DiceConfig(c echo.Context) error {
 	if !doAuth(c) {
 		return c.JSON(http.StatusForbidden, nil)
 	}
+	myDice.UnlockCodeUpdate(false)
+	return c.JSON(http.StatusOK, getDiceConfigInfo())
+}
 
+func getDiceConfigInfo() DiceConfigInfo {
 	password := ""
 	if myDice.Parent.UIPasswordHash != "" {
 		password = "------"
@@ -42,7 +46,6 @@ func DiceConfig(c echo.Context) error {
 	if limit == 0 {
 		limit = 100
 	}
-	myDice.UnlockCodeUpdate(false)
 
 	cocRule := strconv.FormatInt(myDice.Config.DefaultCocRuleIndex, 10)
 	if myDice.Config.DefaultCocRuleIndex == 11 {
@@ -84,8 +87,24 @@ func DiceConfig(c echo.Context) error {
 	info.DefaultCocRuleIndex = cocRule
 	info.MailPassword = emailPasswordMasked
 	info.QuitInactiveThresholdDays = info.QuitInactiveThreshold.Hours() / 24
+	return info
+}
 
-	return c.JSON(http.StatusOK, info)
+// diceConfigSnapshot 取出配置中指定键的当前值，用于审计记录。敏感项已在 getDiceConfigInfo 中打码
+func diceConfigSnapshot(keys map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(getDiceConfigInfo())
+	if err != nil {
+		return nil
+	}
+	all := map[string]interface{}{}
+	if err = json.Unmarshal(data, &all); err != nil {
+		return nil
+	}
+	ret := make(map[string]interface{}, len(keys))
+	for k := range keys {
+		ret[k] = all[k]
+	}
+	return ret
 }
 
 func DiceConfigSet(c echo.Context) error {
@@ -111,6 +130,8 @@ func DiceConfigSet(c echo.Context) error {
 		myDice.Logger.Error("DiceConfigSet", err)
 		return c.JSON(http.StatusOK, nil)
 	}
+	before := diceConfigSnapshot(jsonMap)
+
 	if val, ok := jsonMap["commandPrefix"]; ok {
 		myDice.CommandPrefix = stringConvert(val)
 	}
@@ -326,7 +347,7 @@ func DiceConfigSet(c echo.Context) error {
 		if !dm.JustForTest {
 			myDice.Parent.UIPasswordHash = val.(string)
 			// 清空所有现有的访问令牌，强制重新登录
-			myDice.Parent.AccessTokens = dice.SyncMap[string, bool]{}
+			myDice.Parent.ResetAccessTokens()
 		}
 	}
 
@@ -381,6 +402,36 @@ func DiceConfigSet(c echo.Context) error {
 		}
 	}
 
+	if val, ok := jsonMap["attrsRevisionMaxCount"]; ok {
+		if v, ok := val.(float64); ok && v >= 0 {
+			config.AttrsRevisionMaxCount = int(v)
+		}
+	}
+
+	if val, ok := jsonMap["attrsRevisionMaxDays"]; ok {
+		if v, ok := val.(float64); ok && v >= 0 {
+			config.AttrsRevisionMaxDays = int(v)
+		}
+	}
+
+	if val, ok := jsonMap["jsExecTimeout"]; ok {
+		if v, ok := val.(float64); ok && v >= 0 {
+			config.JsExecTimeout = int64(v)
+		}
+	}
+
+	if val, ok := jsonMap["jsTimeoutDisableCount"]; ok {
+		if v, ok := val.(float64); ok && v >= 0 {
+			config.JsTimeoutDisableCount = int(v)
+		}
+	}
+
+	if val, ok := jsonMap["jsDocStoreQuota"]; ok {
+		if v, ok := val.(float64); ok && v >= 0 {
+			config.JsDocStoreQuota = int64(v)
+		}
+	}
+
 	if val, ok := jsonMap["customReplyConfigEnable"]; ok {
 		config.CustomReplyConfigEnable = val.(bool)
 	}
@@ -473,6 +524,7 @@ func DiceConfigSet(c echo.Context) error {
 	// 统一标记为修改
 	myDice.MarkModified()
 	myDice.Parent.Save()
+	auditWebUI(c, dice.AuditActionWebUIConfigSet, "dice.config", before, diceConfigSnapshot(jsonMap))
 	return c.JSON(http.StatusOK, nil)
 }
 
@@ -538,3 +590,45 @@ func vmVersionSet(c echo.Context) error {
 		"failTypes": failTypes,
 	})
 }
+
+// vmMigrateAnalyze 分析自定义回复、牌堆和自定义文案中的V1表达式，给出V2改写方案
+func vmMigrateAnalyze(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	kind := c.QueryParam("kind")
+	switch kind {
+	case "", dice.V1MigrateKindReply, dice.V1MigrateKindDeck, dice.V1MigrateKindCustomText:
+	default:
+		return Error(&c, "未知的类型: "+kind, Response{})
+	}
+	return Success(&c, Response{
+		"files": myDice.V1MigrateAnalyze(kind),
+	})
+}
+
+// vmMigrateApply 将一个文件中可自动改写的V1表达式写回
+func vmMigrateApply(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+
+	var req struct {
+		Kind     string `json:"kind"`
+		Filename string `json:"filename"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	applied, skipped, err := myDice.V1MigrateApply(req.Kind, req.Filename)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"applied": applied,
+		"skipped": skipped,
+	})
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+)
+
+// extDocsLimitMax 浏览时单页最多返回的文档数
+const extDocsLimitMax = 200
+
+func extDocsOpen(c echo.Context, extName string) (*dice.ExtDocStore, error) {
+	if myDice.ExtDocs == nil {
+		return nil, Error(&c, "结构化存储未初始化", Response{})
+	}
+	s, err := myDice.ExtDocs.Open(extName)
+	if err != nil {
+		return nil, Error(&c, err.Error(), Response{})
+	}
+	return s, nil
+}
+
+func extDocsList(c echo.Context) error {
+	if !doAuth(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	items := []dice.ExtDocStoreInfo{}
+	if myDice.ExtDocs != nil {
+		items = myDice.ExtDocs.List()
+	}
+	return Success(&c, Response{"items": items, "quota": myDice.Config.JsDocStoreQuota})
+}
+
+func extDocsCollections(c echo.Context) error {
+	if !doAuth(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	s, err := extDocsOpen(c, c.QueryParam("ext"))
+	if s == nil {
+		return err
+	}
+	collections, err := s.Collections()
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{"collections": collections, "size": s.Size(), "quota": s.Quota})
+}
+
+// extDocsBrowse 分页浏览集合，filter 为 JSON 格式的查询条件
+func extDocsBrowse(c echo.Context) error {
+	if !doAuth(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	s, err := extDocsOpen(c, c.QueryParam("ext"))
+	if s == nil {
+		return err
+	}
+	q := dice.ExtDocQuery{Sort: c.QueryParam("sort")}
+	q.Offset, _ = strconv.Atoi(c.QueryParam("offset"))
+	q.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	if q.Limit <= 0 || q.Limit > extDocsLimitMax {
+		q.Limit = extDocsLimitMax
+	}
+	if filter := c.QueryParam("filter"); filter != "" {
+		if err = json.Unmarshal([]byte(filter), &q.Filter); err != nil {
+			return Error(&c, "查询条件格式错误: "+err.Error(), Response{})
+		}
+	}
+	collection := c.QueryParam("collection")
+	total, err := s.Count(collection, q.Filter)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	docs, err := s.Find(collection, q)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{"docs": docs, "total": total})
+}
+
+func extDocsDelete(c echo.Context) error {
+	if !doAuth(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	v := struct {
+		Ext        string `json:"ext"`
+		Collection string `json:"collection"`
+		ID         string `json:"id"` // 为空时删除整个集合
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	s, err := extDocsOpen(c, v.Ext)
+	if s == nil {
+		return err
+	}
+	n := 0
+	if v.ID == "" {
+		n, err = s.Drop(v.Collection)
+	} else {
+		var deleted bool
+		if deleted, err = s.Delete(v.Collection, v.ID); deleted {
+			n = 1
+		}
+	}
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{"deleted": n})
+}
+
+func extDocsExport(c echo.Context) error {
+	if !doAuth(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	extName := c.QueryParam("ext")
+	s, err := extDocsOpen(c, extName)
+	if s == nil {
+		return err
+	}
+	dump, err := s.Export()
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	data, err := json.Marshal(dump)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	c.Response().Header().Add("Cache-Control", "no-store")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+extName+`-docs.json"`)
+	return c.JSONBlob(http.StatusOK, data)
+}
+
+// extDocsImport 导入 extDocsExport 导出的文件，replace 为 true 时先清空
+func extDocsImport(c echo.Context) error {
+	if !doAuth(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	extName := c.FormValue("ext")
+	replace := c.FormValue("replace") == "true"
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	src, err := file.Open()
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	defer func(src multipart.File) {
+		_ = src.Close()
+	}(src)
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	dump := &dice.ExtDocDump{}
+	if err = json.Unmarshal(data, dump); err != nil {
+		return Error(&c, "文件格式错误: "+err.Error(), Response{})
+	}
+
+	s, err := extDocsOpen(c, extName)
+	if s == nil {
+		return err
+	}
+	before := s.Size()
+	n, err := s.Import(dump, replace)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	auditWebUI(c, dice.AuditActionWebUIExtDocsImport, extName,
+		map[string]interface{}{"size": before},
+		map[string]interface{}{"size": s.Size(), "imported": n, "replace": replace})
+	return Success(&c, Response{"imported": n})
+}
+
+// extDocsMigrate 把扩展旧版 storage.db 的内容复制到结构化存储
+func extDocsMigrate(c echo.Context) error {
+	if !doAuth(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	v := struct {
+		Ext        string `json:"ext"`
+		Collection string `json:"collection"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if myDice.ExtDocs == nil {
+		return Error(&c, "结构化存储未初始化", Response{})
+	}
+	n, err := myDice.ExtDocs.MigrateLegacy(v.Ext, v.Collection)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	auditWebUI(c, dice.AuditActionWebUIExtDocsImport, v.Ext, nil, map[string]interface{}{"migrated": n, "collection": v.Collection})
+	return Success(&c, Response{"migrated": n})
+}
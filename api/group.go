@@ -13,7 +13,7 @@ import (
 )
 
 func groupList(c echo.Context) error {
-	var items []*dice.GroupInfo
+	var items []dice.GroupInfoView
 	// Pinenutn: Range模板 ServiceAtNew重构代码
 	myDice.ImSession.ServiceAtNew.Range(func(groupID string, item *dice.GroupInfo) bool {
 		// Pinenutn: ServiceAtNew重构
@@ -31,7 +31,7 @@ func groupList(c echo.Context) error {
 				item.TmpExtList = exts
 
 				if item.DiceIDExistsMap.Len() > 0 {
-					items = append(items, item)
+					items = append(items, dice.GroupInfoView{GroupInfo: item})
 				}
 			}
 		}
@@ -61,24 +61,31 @@ func groupSetOne(c echo.Context) error {
 	err := c.Bind(&v)
 
 	if err == nil {
-		_, exists := myDice.ImSession.ServiceAtNew.Load(v.GroupID)
-		if exists {
-			for _, ep := range myDice.ImSession.EndPoints {
-				// if ep.UserId == v.DiceId {
-				ctx := &dice.MsgContext{Dice: myDice, EndPoint: ep, Session: myDice.ImSession}
-				if v.Active {
-					dice.SetBotOnAtGroup(ctx, v.GroupID)
-				} else {
-					dice.SetBotOffAtGroup(ctx, v.GroupID)
-				}
-				//}
-			}
-		}
+		groupSetActive(v.GroupID, v.Active)
 		return c.String(http.StatusOK, "")
 	}
 	return c.String(430, "")
 }
 
+// groupSetActive 在群组中开启或关闭骰子，群组不存在时返回 false
+func groupSetActive(groupID string, active bool) bool {
+	_, exists := myDice.ImSession.ServiceAtNew.Load(groupID)
+	if !exists {
+		return false
+	}
+	for _, ep := range myDice.ImSession.EndPoints {
+		// if ep.UserId == v.DiceId {
+		ctx := &dice.MsgContext{Dice: myDice, EndPoint: ep, Session: myDice.ImSession}
+		if active {
+			dice.SetBotOnAtGroup(ctx, groupID)
+		} else {
+			dice.SetBotOffAtGroup(ctx, groupID)
+		}
+		//}
+	}
+	return true
+}
+
 func groupQuit(c echo.Context) error {
 	if !doAuth(c) {
 		return c.JSON(http.StatusForbidden, nil)
@@ -99,14 +106,22 @@ func groupQuit(c echo.Context) error {
 		return c.String(430, "")
 	}
 
+	if !groupQuitOne(v.GroupID, v.DiceID, v.Silence, v.ExtraText) {
+		return c.String(430, "")
+	}
+	return c.String(http.StatusOK, "")
+}
+
+// groupQuitOne 让指定骰子账号退出群组，群组或账号不存在时返回 false
+func groupQuitOne(groupID string, diceID string, silence bool, extraText string) bool {
 	// 不太好弄，主要会出现多个帐号在群的情况
-	group, exists := myDice.ImSession.ServiceAtNew.Load(v.GroupID)
+	group, exists := myDice.ImSession.ServiceAtNew.Load(groupID)
 	if !exists {
-		return c.String(430, "")
+		return false
 	}
 
 	for _, ep := range myDice.ImSession.EndPoints {
-		if ep.UserID != v.DiceID {
+		if ep.UserID != diceID {
 			continue
 		}
 		// 就是这个
@@ -117,20 +132,20 @@ func groupQuit(c echo.Context) error {
 		ctx.Notice(_txt)
 		// dice.SetBotOffAtGroup(ctx, group.GroupId)
 
-		if !v.Silence {
+		if !silence {
 			txtPost := dice.DiceFormatTmpl(ctx, "核心:提示_手动退群前缀")
-			if v.ExtraText != "" {
-				txtPost += "\n骰主留言: " + v.ExtraText
+			if extraText != "" {
+				txtPost += "\n骰主留言: " + extraText
 			}
-			dice.ReplyGroup(ctx, &dice.Message{GroupID: v.GroupID}, txtPost)
+			dice.ReplyGroup(ctx, &dice.Message{GroupID: groupID}, txtPost)
 		}
 
-		group.DiceIDExistsMap.Delete(v.DiceID)
+		group.DiceIDExistsMap.Delete(diceID)
 		time.Sleep(6 * time.Second)
 		group.MarkDirty(myDice)
 
-		ep.Adapter.QuitGroup(ctx, v.GroupID)
-		return c.String(http.StatusOK, "")
+		ep.Adapter.QuitGroup(ctx, groupID)
+		return true
 	}
-	return c.String(430, "")
+	return false
 }
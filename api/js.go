@@ -1,6 +1,8 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -121,7 +123,7 @@ func jsDelete(c echo.Context) error {
 	err := c.Bind(&v)
 
 	if err == nil && v.Filename != "" {
-		for _, js := range myDice.JsScriptList {
+		for _, js := range myDice.JsScripts() {
 			if js.Filename == v.Filename {
 				dice.JsDelete(myDice, js)
 				break
@@ -141,6 +143,11 @@ func jsReload(c echo.Context) error {
 			"testMode": true,
 		})
 	}
+	// 指定 name 时只重载该脚本，其余脚本保持运行
+	v := struct {
+		Name string `form:"name" json:"name"`
+	}{}
+	_ = c.Bind(&v)
 	// 尝试取锁，如果取不到，说明正在后台重载中
 	// TODO:用户提示模式？
 	locked := myDice.JsReloadLock.TryLock()
@@ -148,6 +155,12 @@ func jsReload(c echo.Context) error {
 		return c.NoContent(400)
 	}
 	defer myDice.JsReloadLock.Unlock()
+	if v.Name != "" {
+		if err := myDice.JsReloadScript(v.Name); err != nil {
+			return Error(&c, err.Error(), Response{})
+		}
+		return Success(&c, Response{"name": v.Name})
+	}
 	myDice.JsReload()
 	return c.NoContent(200)
 }
@@ -183,7 +196,12 @@ func jsUpload(c echo.Context) error {
 	// fmt.Println("????", filepath.Join("./data/decks", file.Filename))
 	file.Filename = strings.ReplaceAll(file.Filename, "/", "_")
 	file.Filename = strings.ReplaceAll(file.Filename, "\\", "_")
-	dst, err := os.Create(filepath.Join(myDice.BaseConfig.DataDir, "scripts", file.Filename))
+	if strings.EqualFold(filepath.Ext(file.Filename), ".zip") {
+		return jsUploadProject(c, src, file.Filename)
+	}
+	dstPath := filepath.Join(myDice.BaseConfig.DataDir, "scripts", file.Filename)
+	before := fileDigest(dstPath)
+	dst, err := os.Create(dstPath)
 	if err != nil {
 		return err
 	}
@@ -195,10 +213,48 @@ func jsUpload(c echo.Context) error {
 	if _, err = io.Copy(dst, src); err != nil {
 		return err
 	}
+	_ = dst.Sync()
+	auditWebUI(c, dice.AuditActionWebUIJsUpload, file.Filename, before, fileDigest(dstPath))
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// jsUploadProject 安装打包为 zip 的脚本工程，工程目录名取压缩包文件名
+func jsUploadProject(c echo.Context, src io.Reader, filename string) error {
+	temp, err := os.CreateTemp("", "js-project-*.zip")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+	}()
+	if _, err = io.Copy(temp, src); err != nil {
+		return err
+	}
+	_ = temp.Sync()
 
+	name := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if _, err = myDice.JsInstallProject(temp.Name(), name); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	auditWebUI(c, dice.AuditActionWebUIJsUpload, filename, nil, fileDigest(temp.Name()))
 	return c.JSON(http.StatusOK, nil)
 }
 
+// fileDigest 文件的大小与 sha256 摘要，用于审计记录中比较上传前后的差异
+func fileDigest(path string) map[string]interface{} {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return map[string]interface{}{
+		"size":   len(data),
+		"sha256": hex.EncodeToString(sum[:]),
+	}
+}
+
 func jsList(c echo.Context) error {
 	if !doAuth(c) {
 		return c.JSON(http.StatusForbidden, nil)
@@ -212,8 +268,9 @@ func jsList(c echo.Context) error {
 		dice.JsScriptInfo
 		BuiltinUpdated bool `json:"builtinUpdated"`
 	}
-	scripts := make([]*script, 0, len(myDice.JsScriptList))
-	for _, info := range myDice.JsScriptList {
+	list := myDice.JsScripts()
+	scripts := make([]*script, 0, len(list))
+	for _, info := range list {
 		temp := script{
 			JsScriptInfo:   *info,
 			BuiltinUpdated: info.Builtin && !myDice.JsBuiltinDigestSet[info.Digest],
@@ -244,12 +301,49 @@ func jsShutdown(c echo.Context) error {
 }
 
 func jsStatus(c echo.Context) error {
+	scripts := []dice.JsScriptStat{}
+	if myDice.JsWatchdog != nil {
+		scripts = myDice.JsWatchdog.Stats()
+	}
+	routes := []dice.JsHTTPRoute{}
+	if myDice.JsHTTPRouter != nil {
+		routes = myDice.JsHTTPRouter.Routes()
+	}
+	ipcServices := []dice.IPCService{}
+	if myDice.IPC != nil {
+		ipcServices = myDice.IPC.Services()
+	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"result": true,
-		"status": myDice.Config.JsEnable,
+		"result":              true,
+		"status":              myDice.Config.JsEnable,
+		"execTimeout":         myDice.Config.JsExecTimeout,
+		"timeoutDisableCount": myDice.Config.JsTimeoutDisableCount,
+		"scripts":             scripts,
+		"routes":              routes,
+		"ipcServices":         ipcServices,
 	})
 }
 
+// jsExtHTTP 转发到扩展通过 seal.http 注册的路由，路由声明 auth 时要求已登录 WebUI
+func jsExtHTTP(c echo.Context) error {
+	if myDice.JsHTTPRouter == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	route, params := myDice.JsHTTPRouter.Match(c.Param("ext"), c.Request().Method, c.Param("*"))
+	if route == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	user := ""
+	identity, ok := getIdentity(c)
+	if ok {
+		user = identity.Username
+	} else if route.Auth {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	myDice.JsHTTPServe(route, params, user, c.Response(), c.Request())
+	return nil
+}
+
 func jsEnable(c echo.Context) error {
 	if !doAuth(c) {
 		return c.JSON(http.StatusForbidden, nil)
@@ -312,7 +406,7 @@ func jsCheckUpdate(c echo.Context) error {
 	err := c.Bind(&v)
 
 	if err == nil && v.Filename != "" {
-		for _, jsScript := range myDice.JsScriptList {
+		for _, jsScript := range myDice.JsScripts() {
 			if jsScript.Filename == v.Filename {
 				oldJs, newJs, tempFileName, errUpdate := myDice.JsCheckUpdate(jsScript)
 				if errUpdate != nil {
@@ -350,7 +444,7 @@ func jsUpdate(c echo.Context) error {
 	err := c.Bind(&v)
 
 	if err == nil && v.Filename != "" {
-		for _, jsScript := range myDice.JsScriptList {
+		for _, jsScript := range myDice.JsScripts() {
 			if jsScript.Filename == v.Filename {
 				err = myDice.JsUpdate(jsScript, v.TempFileName)
 				if err != nil {
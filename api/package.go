@@ -8,6 +8,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"sealdice-core/dice"
 	"sealdice-core/dice/sealpack"
 )
 
@@ -170,9 +171,11 @@ func packageInstallFromUpload(c echo.Context) error {
 	}
 	defer req.Body.Close()
 
+	before := packageVersionSnapshot()
 	if err := myDice.PackageManager.InstallFromStream(req.Body); err != nil {
 		return Error(&c, err.Error(), Response{})
 	}
+	auditPackageInstall(c, before, "upload")
 
 	return Success(&c, Response{
 		"message": "扩展包安装成功",
@@ -231,10 +234,12 @@ func packageInstallFromURL(c echo.Context) error {
 		return Error(&c, err.Error(), Response{})
 	}
 
+	before := packageVersionSnapshot()
 	err = myDice.PackageManager.InstallFromURL(params.URL, nil)
 	if err != nil {
 		return Error(&c, err.Error(), Response{})
 	}
+	auditPackageInstall(c, before, params.URL)
 
 	return Success(&c, Response{
 		"message": "扩展包安装成功",
@@ -522,3 +527,33 @@ func packageGetConfigSchema(c echo.Context) error {
 		"data": pkg.Manifest.Config,
 	})
 }
+
+// packageVersionSnapshot 当前已安装扩展包的 ID 与版本
+func packageVersionSnapshot() map[string]string {
+	result := map[string]string{}
+	for _, inst := range myDice.PackageManager.List() {
+		if inst == nil || inst.Manifest == nil {
+			continue
+		}
+		result[inst.Manifest.Package.ID] = inst.Manifest.Package.Version
+	}
+	return result
+}
+
+// auditPackageInstall 对比安装前后的版本，为每个新装或变更的扩展包写入审计记录
+func auditPackageInstall(c echo.Context, before map[string]string, source string) {
+	for id, version := range packageVersionSnapshot() {
+		old, ok := before[id]
+		if ok && old == version {
+			continue
+		}
+		var beforeInfo interface{}
+		if ok {
+			beforeInfo = map[string]string{"version": old}
+		}
+		auditWebUI(c, dice.AuditActionWebUIPackageInstall, id, beforeInfo, map[string]string{
+			"version": version,
+			"source":  source,
+		})
+	}
+}
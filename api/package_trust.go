@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+)
+
+// packageTrustList 获取扩展包信任库与签名策略
+// GET /package/trust/list
+// 返回: { keys: []PackageTrustedKey, policy: string, result: true }
+func packageTrustList(c echo.Context) error {
+	return Success(&c, Response{
+		"keys":   myDice.PackageManager.TrustedKeys(),
+		"policy": myDice.PackageManager.SignPolicy(),
+	})
+}
+
+// packageTrustAdd 把发布者公钥加入信任库
+// POST /package/trust/add
+// 参数: { name: string, publicKey: string } - publicKey 为 PEM 格式的 RSA 公钥
+func packageTrustAdd(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	var params struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"publicKey"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	key, err := myDice.PackageManager.AddTrustedKey(params.Name, params.PublicKey)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	auditWebUI(c, dice.AuditActionWebUIPackageTrust, key.KeyID, nil, map[string]string{"name": key.Name})
+	return Success(&c, Response{"data": key})
+}
+
+// packageTrustRemove 从信任库删除公钥
+// POST /package/trust/remove
+// 参数: { keyId: string }
+func packageTrustRemove(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	var params struct {
+		KeyID string `json:"keyId"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if err := myDice.PackageManager.RemoveTrustedKey(params.KeyID); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	auditWebUI(c, dice.AuditActionWebUIPackageTrust, params.KeyID, map[string]string{"keyId": params.KeyID}, nil)
+	return Success(&c, Response{})
+}
+
+// packageTrustSetPolicy 修改签名策略
+// POST /package/trust/policy
+// 参数: { policy: "allow" | "warn" | "require" }
+func packageTrustSetPolicy(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	var params struct {
+		Policy string `json:"policy"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	before := myDice.PackageManager.SignPolicy()
+	if err := myDice.PackageManager.SetSignPolicy(params.Policy); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	auditWebUI(c, dice.AuditActionWebUIPackageTrust, "policy", before, myDice.PackageManager.SignPolicy())
+	return Success(&c, Response{})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// packageUpdates 检查已安装扩展包在当前商店中的更新
+// GET /package/updates
+// 返回: { data: []PackageUpdateInfo, plan: []PackagePlanItem, error?: string, result: true } - plan 为一并更新全部包时的安装顺序，无法求解时为空并给出 error
+func packageUpdates(c echo.Context) error {
+	updates, plan, err := myDice.PackageManager.CheckUpdates(myDice.StoreManager)
+	resp := Response{"data": updates, "plan": plan}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	return Success(&c, resp)
+}
+
+// packageResolve 预览从商店安装扩展包时需要一并安装或升级的依赖
+// POST /package/resolve
+// 参数: { id: string, version?: string } - version 为空时选择可用的最新版本
+func packageResolve(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	var params struct {
+		ID      string `json:"id"`
+		Version string `json:"version"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	plan, err := myDice.PackageManager.ResolveInstall(myDice.StoreManager, params.ID, params.Version)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{"plan": plan})
+}
+
+// packageUpdate 把扩展包连同依赖升级到满足全部约束的最新版本，任何一步失败都会回滚
+// POST /package/update
+// 参数: { ids?: []string } - 为空时更新全部可更新的包
+func packageUpdate(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	if dm.JustForTest {
+		return Error(&c, "展示模式不支持该操作", Response{"testMode": true})
+	}
+	var params struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	plan, err := myDice.PackageManager.ResolveUpdates(myDice.StoreManager, params.IDs)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	before := packageVersionSnapshot()
+	err = myDice.PackageManager.ApplyPlan(plan)
+	auditPackageInstall(c, before, "store")
+	if err != nil {
+		return Error(&c, err.Error(), Response{"plan": plan})
+	}
+	return Success(&c, Response{"plan": plan})
+}
@@ -16,8 +16,8 @@ func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
-func bindPProfAPIs(e *echo.Echo, prefix string) {
-	g := e.Group(prefix+"/debug/pprof", AuthMiddleware)
+func bindPProfAPIs(e *echo.Echo, prefix string, perm echo.MiddlewareFunc) {
+	g := e.Group(prefix+"/debug/pprof", AuthMiddleware, perm)
 
 	g.GET("", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
 	g.GET("/", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
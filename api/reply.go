@@ -43,16 +43,22 @@ func customReplySave(c echo.Context) error {
 		v.Filename = filepath.Base(replyFile.Path)
 		v.CacheBacked = true
 		v.Warning = customReplyPackageWarning
+		before, _ := dice.CustomReplyConfigReadFromPath(myDice, replyFile.Path, v.Filename)
 		if err := v.SaveToPath(replyFile.Path); err != nil {
 			return c.String(http.StatusInternalServerError, err.Error())
 		}
 		upsertCustomReplyConfig(v)
+		auditWebUI(c, dice.AuditActionWebUIReplySave, v.PackageID+"/"+v.Filename, before, v)
 		return c.JSON(http.StatusOK, customReplySaveResponse{
 			Success: true,
 			Warning: customReplyPackageWarning,
 		})
 	}
 
+	var before *dice.ReplyConfig
+	if dice.CustomReplyConfigCheckExists(myDice, v.Filename) {
+		before, _ = dice.CustomReplyConfigRead(myDice, v.Filename)
+	}
 	for index, i := range myDice.CustomReplyConfig {
 		if i != nil && i.PackageID == "" && strings.EqualFold(i.Filename, v.Filename) {
 			myDice.CustomReplyConfig[index].Enable = v.Enable
@@ -64,6 +70,7 @@ func customReplySave(c echo.Context) error {
 		}
 	}
 	v.Save(myDice)
+	auditWebUI(c, dice.AuditActionWebUIReplySave, v.Filename, before, v)
 	return c.JSON(http.StatusOK, customReplySaveResponse{Success: true})
 }
 
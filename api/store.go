@@ -194,6 +194,20 @@ func storeDownload(c echo.Context) error {
 		return Error(&c, err.Error(), Response{})
 	}
 
+	// 未安装或需要升级时按完整的依赖关系求解，依赖一并安装或升级
+	if installedPkg, exists := myDice.PackageManager.Get(target.ID); !exists || installedPkg == nil || installedPkg.Manifest == nil ||
+		storePackageNewer(target.Version, installedPkg.Manifest.Package.Version) {
+		plan, resolveErr := myDice.PackageManager.ResolveInstall(myDice.StoreManager, target.ID, target.Version)
+		if resolveErr != nil {
+			return Error(&c, resolveErr.Error(), Response{})
+		}
+		if err = myDice.PackageManager.ApplyPlan(plan); err != nil {
+			return Error(&c, err.Error(), Response{})
+		}
+		myDice.StoreManager.RefreshInstalled([]*dice.StorePackage{target})
+		return Success(&c, Response{"plan": plan})
+	}
+
 	if _, err := installStorePackage(target, true); err != nil {
 		return Error(&c, err.Error(), Response{})
 	}
@@ -234,6 +248,12 @@ func sameStorePackageVersion(left, right string) bool {
 	return leftErr == nil && rightErr == nil && leftVersion.Equal(rightVersion)
 }
 
+func storePackageNewer(version, installed string) bool {
+	targetVersion, targetErr := semver.NewVersion(strings.TrimSpace(version))
+	installedVersion, installedErr := semver.NewVersion(strings.TrimSpace(installed))
+	return targetErr == nil && installedErr == nil && targetVersion.GreaterThan(installedVersion)
+}
+
 func storePackageInfoList(c echo.Context) error {
 	if !doAuth(c) {
 		return c.JSON(http.StatusForbidden, "auth")
@@ -198,3 +198,25 @@ func logSendToBackend(groupID string, logName string) (bool, string, string, err
 
 	return dice.LogSendToBackend(ctx, groupID, logName)
 }
+
+// storyGetStats 跨日志检定统计，by=user 时按用户汇总，否则按角色汇总
+func storyGetStats(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := service.QueryLogStat{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if err := myDice.LogStatsUpdate(); err != nil {
+		myDice.Logger.Error("storyGetStats", err)
+		return Error(&c, err.Error(), Response{})
+	}
+	rows, err := service.LogStatsQuery(myDice.DBOperator, &v)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": service.LogStatsSummarize(rows, c.QueryParam("by") != "user"),
+	})
+}
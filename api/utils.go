@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -42,12 +43,34 @@ func Int64ToBytes(i int64) []byte {
 	return buf
 }
 
-func doAuth(c echo.Context) bool {
+func getRequestToken(c echo.Context) string {
 	token := c.Request().Header.Get("token") //nolint:canonicalheader // private header
+	if token == "" {
+		if auth := c.Request().Header.Get(echo.HeaderAuthorization); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
 	if token == "" {
 		token = c.QueryParam("token")
 	}
-	return myDice.Parent.AccessTokens.Exists(token)
+	return token
+}
+
+// getIdentity 解析请求的 WebUI 身份，结果缓存在 context 中
+func getIdentity(c echo.Context) (*dice.WebUIIdentity, bool) {
+	if v, ok := c.Get(identityContextKey).(*dice.WebUIIdentity); ok {
+		return v, true
+	}
+	identity, ok := myDice.Parent.WebUIResolveToken(getRequestToken(c))
+	if ok {
+		c.Set(identityContextKey, identity)
+	}
+	return identity, ok
+}
+
+func doAuth(c echo.Context) bool {
+	_, ok := getIdentity(c)
+	return ok
 }
 
 func GetHexData(c echo.Context, method string, name string) (value []byte, finished bool) {
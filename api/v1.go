@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humaecho"
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+	"sealdice-core/dice/service"
+)
+
+// ================== /api/v1 开放接口 ==================
+//
+// 面向自动化脚本和第三方面板的版本化接口，由 huma 生成 OpenAPI 文档：
+//   - 文档: <prefix>/v1/docs
+//   - 规范: <prefix>/v1/openapi.json / openapi.yaml
+//
+// 鉴权与 WebUI 共用一套身份：请求头 Authorization: Bearer <token> 或 token: <token>，
+// token 可以是登录会话，也可以是在账号管理中创建的 API 令牌（sdat_ 开头）。
+// 每个操作都声明了所需的 WebUI 权限，错误统一为 RFC 9457 problem+json。
+// 已有的 /sd-api/* 接口保持不变，仍供 WebUI 使用。
+
+const v1PermissionKey = "permission"
+
+var errV1TestMode = errors.New("测试模式下不可执行此操作")
+
+func bindV1(e *echo.Echo, prefix string) huma.API {
+	config := huma.DefaultConfig("SealDice API", dice.VERSION.String())
+	config.Info.Description = "海豹核心开放接口。鉴权使用 WebUI 会话或 API 令牌，各操作所需权限见 x-permission。"
+	config.Servers = []*huma.Server{{URL: prefix + "/v1"}}
+	config.Components.SecuritySchemes = map[string]*huma.SecurityScheme{
+		"bearer": {Type: "http", Scheme: "bearer", Description: "WebUI 会话 token 或 API 令牌"},
+		"token":  {Type: "apiKey", In: "header", Name: "token", Description: "与 WebUI 相同的 token 请求头"},
+	}
+
+	api := humaecho.NewWithGroup(e, e.Group(prefix+"/v1"), config)
+	api.UseMiddleware(v1AuthMiddleware(api))
+
+	registerV1Groups(api)
+	registerV1Bans(api)
+	registerV1Characters(api)
+	registerV1Logs(api)
+	registerV1Packages(api)
+	registerV1Decks(api)
+	registerV1Endpoints(api)
+	return api
+}
+
+// v1AuthMiddleware 校验身份与操作声明的权限，并与 WebUI 一样记录修改类请求
+func v1AuthMiddleware(api huma.API) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		perm, ok := ctx.Operation().Metadata[v1PermissionKey].(dice.WebUIPermission)
+		if !ok {
+			next(ctx)
+			return
+		}
+		c := humaecho.Unwrap(ctx)
+		identity, ok := getIdentity(c)
+		if !ok {
+			_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, "未登录或令牌无效")
+			return
+		}
+		if !identity.Can(perm) {
+			_ = huma.WriteErr(api, ctx, http.StatusForbidden, "权限不足: "+string(perm))
+			return
+		}
+		next(huma.WithValue(ctx, v1IdentityKey{}, identity))
+		if ctx.Method() != http.MethodGet {
+			auditWebUIRequest(c, identity, nil)
+		}
+	}
+}
+
+type v1IdentityKey struct{}
+
+// v1Identity 取得当前请求的 WebUI 身份，仅在注册时声明了权限的操作中可用
+func v1Identity(ctx context.Context) *dice.WebUIIdentity {
+	identity, _ := ctx.Value(v1IdentityKey{}).(*dice.WebUIIdentity)
+	return identity
+}
+
+// v1Register 注册一个需要指定权限的操作
+func v1Register[I, O any](api huma.API, perm dice.WebUIPermission, op huma.Operation, handler func(context.Context, *I) (*O, error)) {
+	op.Metadata = map[string]any{v1PermissionKey: perm}
+	op.Extensions = map[string]any{"x-permission": string(perm)}
+	op.Security = []map[string][]string{{"bearer": {}}, {"token": {}}}
+	op.Errors = append(op.Errors, http.StatusUnauthorized, http.StatusForbidden)
+	huma.Register(api, op, handler)
+}
+
+// v1Error 将内部错误转换为带状态码的错误响应
+func v1Error(err error) error {
+	var statusErr huma.StatusError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &statusErr):
+		return err
+	case errors.Is(err, errV1TestMode):
+		return huma.Error409Conflict(err.Error())
+	case errors.Is(err, service.ErrLogNotFound), errors.Is(err, dice.ErrCharacterNotFound),
+		errors.Is(err, service.ErrAttrsRevisionNotFound):
+		return huma.Error404NotFound(err.Error())
+	case errors.Is(err, dice.ErrCharacterExists):
+		return huma.Error409Conflict(err.Error())
+	case errors.Is(err, dice.ErrCharacterSheetFormat), errors.Is(err, dice.ErrCharacterSheetEmpty),
+		errors.Is(err, dice.ErrCharacterNameEmpty):
+		return huma.Error422UnprocessableEntity(err.Error())
+	default:
+		return huma.Error500InternalServerError(err.Error())
+	}
+}
+
+func v1CheckWritable() error {
+	if dm.JustForTest {
+		return v1Error(errV1TestMode)
+	}
+	return nil
+}
+
+// v1Empty 无响应体的操作输出
+type v1Empty struct{}
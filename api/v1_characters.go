@@ -0,0 +1,359 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+
+	"github.com/danielgtaylor/huma/v2"
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/dice"
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+type V1CharacterSummary struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	OwnerID       string   `json:"ownerId"`
+	SheetType     string   `json:"sheetType"     doc:"卡片类型，如 coc7、dnd5e"`
+	BindingGroups []string `json:"bindingGroups" doc:"当前绑定此卡的群组"`
+	CreatedAt     int64    `json:"createdAt"`
+	UpdatedAt     int64    `json:"updatedAt"`
+}
+
+type V1Character struct {
+	V1CharacterSummary
+	Attrs map[string]any `json:"attrs" doc:"属性值，数字保持数字，其余为字符串形式"`
+}
+
+func v1CharacterSummaryFrom(item *model.AttributesItemModel) V1CharacterSummary {
+	groups := myDice.AttrsManager.CharGetBindingGroupIdList(item.Id)
+	if groups == nil {
+		groups = []string{}
+	}
+	return V1CharacterSummary{
+		ID:            item.Id,
+		Name:          item.Name,
+		OwnerID:       item.OwnerId,
+		SheetType:     item.SheetType,
+		BindingGroups: groups,
+		CreatedAt:     item.CreatedAt,
+		UpdatedAt:     item.UpdatedAt,
+	}
+}
+
+func v1CharacterFrom(item *model.AttributesItemModel) (V1Character, error) {
+	char := V1Character{V1CharacterSummary: v1CharacterSummaryFrom(item), Attrs: map[string]any{}}
+	attrs, err := myDice.AttrsManager.LoadById(item.Id)
+	if err != nil {
+		return char, err
+	}
+	keys := make([]string, 0, attrs.Len())
+	attrs.Range(func(key string, _ *ds.VMValue) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	for _, key := range keys {
+		if v, ok := attrs.LoadX(key); ok && v != nil {
+			char.Attrs[key] = v1AttrValue(v)
+		}
+	}
+	return char, nil
+}
+
+// v1AttrValue 属性值在接口中的表示
+func v1AttrValue(v *ds.VMValue) any {
+	switch v.TypeId {
+	case ds.VMTypeInt:
+		if n, ok := v.ReadInt(); ok {
+			return int64(n)
+		}
+	case ds.VMTypeFloat:
+		if n, ok := v.ReadFloat(); ok {
+			return n
+		}
+	}
+	return v.ToString()
+}
+
+// v1LoadCharacter 读取一张角色卡，不是角色卡的属性数据视为不存在
+func v1LoadCharacter(id string) (*model.AttributesItemModel, error) {
+	item, err := service.AttrsGetById(myDice.DBOperator, id)
+	if err != nil || item == nil || item.AttrsType != "character" {
+		return nil, huma.Error404NotFound("角色卡不存在")
+	}
+	return item, nil
+}
+
+type V1CharacterListInput struct {
+	UserID string `query:"userId" required:"true" doc:"卡片所有者，如 QQ:12345"`
+}
+
+type V1CharacterListOutput struct {
+	Body struct {
+		Items []V1CharacterSummary `json:"items"`
+	}
+}
+
+type V1CharacterPathInput struct {
+	ID string `path:"id"`
+}
+
+type V1CharacterOutput struct {
+	Body V1Character
+}
+
+type V1CharacterExportInput struct {
+	ID     string `path:"id"`
+	Format string `query:"format" enum:"json,yaml,csv" default:"json"`
+}
+
+type V1CharacterExportOutput struct {
+	ContentType        string `header:"Content-Type"`
+	ContentDisposition string `header:"Content-Disposition"`
+	Body               []byte
+}
+
+type V1CharacterImportInput struct {
+	UserID    string `query:"userId"    required:"true" doc:"卡片所有者，如 QQ:12345"`
+	Name      string `query:"name"      doc:"角色名，不填则使用卡片中的名字"`
+	Format    string `query:"format"    enum:"json,yaml,csv,coc7xlsx,dnd5e" doc:"不填时根据 filename 与内容自动识别"`
+	Filename  string `query:"filename"  doc:"原始文件名，用于识别格式"`
+	SheetType string `query:"sheetType" doc:"卡片未注明类型时使用的规则，如 coc7"`
+	Overwrite bool   `query:"overwrite" doc:"同名角色已存在时覆盖其属性"`
+	RawBody   []byte `contentType:"application/octet-stream"`
+}
+
+type V1CharacterRevision struct {
+	ID         uint64                      `json:"id"`
+	EditorType string                      `json:"editorType" doc:"im / webui / system"`
+	EditorID   string                      `json:"editorId"`
+	EditorName string                      `json:"editorName"`
+	Source     string                      `json:"source"     doc:"触发修改的指令原文或接口"`
+	SheetType  string                      `json:"sheetType"  doc:"修改前的卡片类型"`
+	Changes    []model.AttrsRevisionChange `json:"changes"    doc:"属性变化，before/after 为 null 表示新增/删除"`
+	CreatedAt  int64                       `json:"createdAt"`
+}
+
+func v1CharacterRevisionFrom(rev *model.AttrsRevision) V1CharacterRevision {
+	changes := dice.RevisionChanges(rev)
+	if changes == nil {
+		changes = []model.AttrsRevisionChange{}
+	}
+	return V1CharacterRevision{
+		ID:         rev.ID,
+		EditorType: rev.EditorType,
+		EditorID:   rev.EditorID,
+		EditorName: rev.EditorName,
+		Source:     rev.Source,
+		SheetType:  rev.SheetType,
+		Changes:    changes,
+		CreatedAt:  rev.CreatedAt,
+	}
+}
+
+type V1CharacterRevisionListInput struct {
+	ID     string `path:"id"`
+	Offset int    `query:"offset" minimum:"0"`
+	Limit  int    `query:"limit"  minimum:"1" maximum:"100" default:"20"`
+}
+
+type V1CharacterRevisionListOutput struct {
+	Body struct {
+		Total int64                 `json:"total"`
+		Items []V1CharacterRevision `json:"items" doc:"按时间倒序"`
+	}
+}
+
+type V1CharacterRevisionRestoreInput struct {
+	ID         string `path:"id"`
+	RevisionID uint64 `path:"revisionId"`
+}
+
+var v1CharacterContentTypes = map[dice.CharacterSheetFormat]string{
+	dice.CharacterSheetJSON: "application/json",
+	dice.CharacterSheetYAML: "application/yaml",
+	dice.CharacterSheetCSV:  "text/csv",
+}
+
+func registerV1Characters(api huma.API) {
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "list-characters",
+		Method:      http.MethodGet,
+		Path:        "/characters",
+		Summary:     "列出用户的角色卡",
+		Tags:        []string{"characters"},
+	}, func(_ context.Context, input *V1CharacterListInput) (*V1CharacterListOutput, error) {
+		items, err := myDice.AttrsManager.GetCharacterList(input.UserID)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		out := &V1CharacterListOutput{}
+		out.Body.Items = make([]V1CharacterSummary, 0, len(items))
+		for _, item := range items {
+			out.Body.Items = append(out.Body.Items, v1CharacterSummaryFrom(item))
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "get-character",
+		Method:      http.MethodGet,
+		Path:        "/characters/{id}",
+		Summary:     "获取角色卡及其属性",
+		Tags:        []string{"characters"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1CharacterPathInput) (*V1CharacterOutput, error) {
+		item, err := v1LoadCharacter(input.ID)
+		if err != nil {
+			return nil, err
+		}
+		char, err := v1CharacterFrom(item)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		return &V1CharacterOutput{Body: char}, nil
+	})
+
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "export-character",
+		Method:      http.MethodGet,
+		Path:        "/characters/{id}/export",
+		Summary:     "导出角色卡文件",
+		Tags:        []string{"characters"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1CharacterExportInput) (*V1CharacterExportOutput, error) {
+		format, ok := dice.ParseCharacterSheetFormat(input.Format)
+		if !ok {
+			return nil, v1Error(dice.ErrCharacterSheetFormat)
+		}
+		data, item, err := myDice.AttrsManager.CharExport(input.ID, format)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		return &V1CharacterExportOutput{
+			ContentType:        v1CharacterContentTypes[format],
+			ContentDisposition: mime.FormatMediaType("attachment", map[string]string{"filename": item.Name + "." + string(format)}),
+			Body:               data,
+		}, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "import-character",
+		Method:      http.MethodPost,
+		Path:        "/characters/import",
+		Summary:     "导入角色卡文件",
+		Description: "支持本接口导出的 JSON/YAML/CSV，常见的 COC7 Excel 人物卡，以及 Foundry VTT、D&D Beyond 的 D&D 5e 角色 JSON。",
+		Tags:        []string{"characters"},
+		Errors:      []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	}, func(ctx context.Context, input *V1CharacterImportInput) (*V1CharacterOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		format := dice.DetectCharacterSheetFormat(input.Filename, input.RawBody)
+		if input.Format != "" {
+			format, _ = dice.ParseCharacterSheetFormat(input.Format)
+		}
+		sheet, err := dice.DecodeCharacterSheet(input.RawBody, format)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity("角色卡解析失败: " + err.Error())
+		}
+		item, err := myDice.AttrsManager.CharImport(myDice, input.UserID, input.Name, sheet, input.SheetType, input.Overwrite)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		editor := dice.AttrsRevisionEditor{Type: dice.AttrsRevisionEditorWebUI, Source: "导入角色卡"}
+		if identity := v1Identity(ctx); identity != nil {
+			editor.ID = identity.Username
+		}
+		myDice.AttrsManager.CommitRevisions(editor)
+		char, err := v1CharacterFrom(item)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		return &V1CharacterOutput{Body: char}, nil
+	})
+
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "list-character-revisions",
+		Method:      http.MethodGet,
+		Path:        "/characters/{id}/revisions",
+		Summary:     "列出角色卡的修改记录",
+		Tags:        []string{"characters"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1CharacterRevisionListInput) (*V1CharacterRevisionListOutput, error) {
+		if _, err := v1LoadCharacter(input.ID); err != nil {
+			return nil, err
+		}
+		total, revs, err := myDice.AttrsManager.RevisionList(input.ID, input.Offset, input.Limit)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		out := &V1CharacterRevisionListOutput{}
+		out.Body.Total = total
+		out.Body.Items = make([]V1CharacterRevision, 0, len(revs))
+		for _, rev := range revs {
+			out.Body.Items = append(out.Body.Items, v1CharacterRevisionFrom(rev))
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "restore-character-revision",
+		Method:      http.MethodPost,
+		Path:        "/characters/{id}/revisions/{revisionId}/restore",
+		Summary:     "将角色卡恢复到某条修改之前",
+		Description: "该记录之后的修改一并撤销，$ 开头的内部字段(物品栏、成长标记等)保留当前值。恢复操作本身也会生成一条修改记录，可以再次恢复。",
+		Tags:        []string{"characters"},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	}, func(ctx context.Context, input *V1CharacterRevisionRestoreInput) (*V1CharacterOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		item, err := v1LoadCharacter(input.ID)
+		if err != nil {
+			return nil, err
+		}
+		editor := dice.AttrsRevisionEditor{
+			Type:   dice.AttrsRevisionEditorWebUI,
+			Source: fmt.Sprintf("恢复修改记录 #%d", input.RevisionID),
+		}
+		if identity := v1Identity(ctx); identity != nil {
+			editor.ID = identity.Username
+		}
+		if _, _, err = myDice.AttrsManager.RevisionRestore(input.ID, input.RevisionID, editor); err != nil {
+			return nil, v1Error(err)
+		}
+		char, err := v1CharacterFrom(item)
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		return &V1CharacterOutput{Body: char}, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "delete-character",
+		Method:      http.MethodDelete,
+		Path:        "/characters/{id}",
+		Summary:     "删除角色卡并解除所有绑定",
+		Tags:        []string{"characters"},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	}, func(_ context.Context, input *V1CharacterPathInput) (*v1Empty, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		if _, err := v1LoadCharacter(input.ID); err != nil {
+			return nil, err
+		}
+		myDice.AttrsManager.CharUnbindAll(input.ID)
+		if err := myDice.AttrsManager.CharDelete(input.ID); err != nil {
+			return nil, v1Error(err)
+		}
+		return &v1Empty{}, nil
+	})
+}
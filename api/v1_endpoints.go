@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"sealdice-core/dice"
+)
+
+type V1Endpoint struct {
+	ID              string `json:"id"`
+	Nickname        string `json:"nickname"`
+	UserID          string `json:"userId"`
+	Platform        string `json:"platform"`
+	ProtocolType    string `json:"protocolType"`
+	Enable          bool   `json:"enable"`
+	State           int    `json:"state"           doc:"0 断开，1 已连接，2 连接中，3 连接失败"`
+	GroupNum        int64  `json:"groupNum"`
+	CmdExecutedNum  int64  `json:"cmdExecutedNum"`
+	OnlineTotalTime int64  `json:"onlineTotalTime"`
+}
+
+func v1EndpointFrom(ep *dice.EndPointInfo) V1Endpoint {
+	return V1Endpoint{
+		ID:              ep.ID,
+		Nickname:        ep.Nickname,
+		UserID:          ep.UserID,
+		Platform:        ep.Platform,
+		ProtocolType:    ep.ProtocolType,
+		Enable:          ep.Enable,
+		State:           int(ep.State),
+		GroupNum:        ep.GroupNum,
+		CmdExecutedNum:  ep.CmdExecutedNum,
+		OnlineTotalTime: ep.OnlineTotalTime,
+	}
+}
+
+func v1FindEndpoint(id string) (*dice.EndPointInfo, error) {
+	for _, ep := range myDice.ImSession.EndPoints {
+		if ep.ID == id {
+			return ep, nil
+		}
+	}
+	return nil, huma.Error404NotFound("账号不存在")
+}
+
+type V1EndpointListOutput struct {
+	Body struct {
+		Items []V1Endpoint `json:"items"`
+	}
+}
+
+type V1EndpointPathInput struct {
+	ID string `path:"id"`
+}
+
+type V1EndpointUpdateInput struct {
+	ID   string `path:"id"`
+	Body struct {
+		Enable bool `json:"enable"`
+	}
+}
+
+type V1EndpointOutput struct {
+	Body V1Endpoint
+}
+
+func registerV1Endpoints(api huma.API) {
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "list-endpoints",
+		Method:      http.MethodGet,
+		Path:        "/endpoints",
+		Summary:     "列出骰子账号连接",
+		Tags:        []string{"endpoints"},
+	}, func(_ context.Context, _ *struct{}) (*V1EndpointListOutput, error) {
+		out := &V1EndpointListOutput{}
+		out.Body.Items = make([]V1Endpoint, 0, len(myDice.ImSession.EndPoints))
+		for _, ep := range myDice.ImSession.EndPoints {
+			out.Body.Items = append(out.Body.Items, v1EndpointFrom(ep))
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "get-endpoint",
+		Method:      http.MethodGet,
+		Path:        "/endpoints/{id}",
+		Summary:     "获取账号连接信息",
+		Tags:        []string{"endpoints"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1EndpointPathInput) (*V1EndpointOutput, error) {
+		ep, err := v1FindEndpoint(input.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &V1EndpointOutput{Body: v1EndpointFrom(ep)}, nil
+	})
+
+	v1Register(api, dice.WebUIPermConnectionManage, huma.Operation{
+		OperationID: "update-endpoint",
+		Method:      http.MethodPatch,
+		Path:        "/endpoints/{id}",
+		Summary:     "启用或停用账号连接",
+		Tags:        []string{"endpoints"},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	}, func(_ context.Context, input *V1EndpointUpdateInput) (*V1EndpointOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		ep, err := v1FindEndpoint(input.ID)
+		if err != nil {
+			return nil, err
+		}
+		ep.SetEnable(myDice, input.Body.Enable)
+		myDice.LastUpdatedTime = time.Now().Unix()
+		myDice.Save(false)
+		return &V1EndpointOutput{Body: v1EndpointFrom(ep)}, nil
+	})
+}
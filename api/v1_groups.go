@@ -0,0 +1,316 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"sealdice-core/dice"
+	"sealdice-core/dice/service"
+)
+
+type V1Group struct {
+	GroupID            string   `json:"groupId"`
+	GroupName          string   `json:"groupName"`
+	Active             bool     `json:"active"`
+	System             string   `json:"system"`
+	LogOn              bool     `json:"logOn"`
+	LogCurName         string   `json:"logCurName"`
+	DiceIDs            []string `json:"diceIds"            doc:"在群内的骰子账号"`
+	Extensions         []string `json:"extensions"         doc:"已开启的扩展"`
+	PlayerNum          int64    `json:"playerNum"`
+	InviteUserID       string   `json:"inviteUserId"`
+	EnteredTime        int64    `json:"enteredTime"`
+	RecentDiceSendTime int64    `json:"recentDiceSendTime"`
+}
+
+func v1GroupFrom(groupID string, item *dice.GroupInfo) V1Group {
+	g := V1Group{
+		GroupID:            groupID,
+		GroupName:          item.GroupName,
+		Active:             item.Active,
+		System:             item.System,
+		LogOn:              item.LogOn,
+		LogCurName:         item.LogCurName,
+		DiceIDs:            []string{},
+		Extensions:         []string{},
+		InviteUserID:       item.InviteUserID,
+		EnteredTime:        item.EnteredTime,
+		RecentDiceSendTime: item.RecentDiceSendTime,
+	}
+	if item.DiceIDExistsMap != nil {
+		item.DiceIDExistsMap.Range(func(diceID string, exists bool) bool {
+			if exists {
+				g.DiceIDs = append(g.DiceIDs, diceID)
+			}
+			return true
+		})
+		sort.Strings(g.DiceIDs)
+	}
+	// 使用 Raw 版本避免触发全量初始化
+	for _, ext := range item.GetActivatedExtListRaw() {
+		if ext != nil {
+			g.Extensions = append(g.Extensions, ext.Name)
+		}
+	}
+	g.PlayerNum, _ = service.GroupPlayerNumGet(myDice.DBOperator, groupID)
+	return g
+}
+
+type V1GroupListInput struct {
+	Active string `query:"active" enum:"true,false" doc:"只列出开启/关闭的群组，留空为全部"`
+}
+
+type V1GroupListOutput struct {
+	Body struct {
+		Items []V1Group `json:"items"`
+	}
+}
+
+type V1GroupPathInput struct {
+	GroupID string `path:"groupId" doc:"群组 ID，如 QQ-Group:12345"`
+}
+
+type V1GroupOutput struct {
+	Body V1Group
+}
+
+type V1GroupUpdateInput struct {
+	GroupID string `path:"groupId"`
+	Body    struct {
+		Active bool `json:"active" doc:"是否在群内开启骰子"`
+	}
+}
+
+type V1GroupQuitInput struct {
+	GroupID string `path:"groupId"`
+	Body    struct {
+		DiceID    string `json:"diceId"              doc:"退群的骰子账号，如 QQ:10001"`
+		Silence   bool   `json:"silence,omitempty"   doc:"不发送告别消息"`
+		ExtraText string `json:"extraText,omitempty" doc:"附加在告别消息后的骰主留言"`
+	}
+}
+
+func registerV1Groups(api huma.API) {
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "list-groups",
+		Method:      http.MethodGet,
+		Path:        "/groups",
+		Summary:     "列出骰子所在的群组",
+		Tags:        []string{"groups"},
+	}, func(_ context.Context, input *V1GroupListInput) (*V1GroupListOutput, error) {
+		out := &V1GroupListOutput{}
+		out.Body.Items = []V1Group{}
+		myDice.ImSession.ServiceAtNew.Range(func(groupID string, item *dice.GroupInfo) bool {
+			if item == nil || strings.HasPrefix(groupID, "PG-") || item.DiceIDExistsMap.Len() == 0 {
+				return true
+			}
+			if input.Active != "" && (input.Active == "true") != item.Active {
+				return true
+			}
+			out.Body.Items = append(out.Body.Items, v1GroupFrom(groupID, item))
+			return true
+		})
+		sort.Slice(out.Body.Items, func(i, j int) bool {
+			return out.Body.Items[i].GroupID < out.Body.Items[j].GroupID
+		})
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "get-group",
+		Method:      http.MethodGet,
+		Path:        "/groups/{groupId}",
+		Summary:     "获取群组信息",
+		Tags:        []string{"groups"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1GroupPathInput) (*V1GroupOutput, error) {
+		item, ok := myDice.ImSession.ServiceAtNew.Load(input.GroupID)
+		if !ok || item == nil {
+			return nil, huma.Error404NotFound("群组不存在")
+		}
+		return &V1GroupOutput{Body: v1GroupFrom(input.GroupID, item)}, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "update-group",
+		Method:      http.MethodPatch,
+		Path:        "/groups/{groupId}",
+		Summary:     "在群组中开启或关闭骰子",
+		Tags:        []string{"groups"},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	}, func(_ context.Context, input *V1GroupUpdateInput) (*V1GroupOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		if !groupSetActive(input.GroupID, input.Body.Active) {
+			return nil, huma.Error404NotFound("群组不存在")
+		}
+		item, _ := myDice.ImSession.ServiceAtNew.Load(input.GroupID)
+		return &V1GroupOutput{Body: v1GroupFrom(input.GroupID, item)}, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "quit-group",
+		Method:      http.MethodPost,
+		Path:        "/groups/{groupId}/quit",
+		Summary:     "让指定骰子账号退出群组",
+		Tags:        []string{"groups"},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	}, func(_ context.Context, input *V1GroupQuitInput) (*v1Empty, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		if !groupQuitOne(input.GroupID, input.Body.DiceID, input.Body.Silence, input.Body.ExtraText) {
+			return nil, huma.Error404NotFound("群组或骰子账号不存在")
+		}
+		return &v1Empty{}, nil
+	})
+}
+
+// ---------- 黑白名单 ----------
+
+type V1Ban struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Score    int64    `json:"score"    doc:"怒气值"`
+	Rank     string   `json:"rank"     enum:"banned,warn,normal,trusted"`
+	RankText string   `json:"rankText"`
+	Reasons  []string `json:"reasons"`
+	Places   []string `json:"places"`
+	Times    []int64  `json:"times"`
+	BanTime  int64    `json:"banTime"`
+}
+
+var v1BanRankNames = map[dice.BanRankType]string{
+	dice.BanRankBanned:  "banned",
+	dice.BanRankWarn:    "warn",
+	dice.BanRankNormal:  "normal",
+	dice.BanRankTrusted: "trusted",
+}
+
+func v1BanFrom(item *dice.BanListInfoItem) V1Ban {
+	return V1Ban{
+		ID:       item.ID,
+		Name:     item.Name,
+		Score:    item.Score,
+		Rank:     v1BanRankNames[item.Rank],
+		RankText: dice.BanRankText[item.Rank],
+		Reasons:  item.Reasons,
+		Places:   item.Places,
+		Times:    item.Times,
+		BanTime:  item.BanTime,
+	}
+}
+
+type V1BanListInput struct {
+	Rank string `query:"rank" enum:"banned,warn,normal,trusted" doc:"按等级筛选，留空为全部"`
+}
+
+type V1BanListOutput struct {
+	Body struct {
+		Items []V1Ban `json:"items"`
+	}
+}
+
+type V1BanPathInput struct {
+	ID string `path:"id" doc:"用户或群组 ID，如 QQ:12345、QQ-Group:12345"`
+}
+
+type V1BanOutput struct {
+	Body V1Ban
+}
+
+type V1BanSetInput struct {
+	ID   string `path:"id"`
+	Body struct {
+		Rank   string `json:"rank"             enum:"banned,trusted"`
+		Name   string `json:"name,omitempty"`
+		Reason string `json:"reason,omitempty" doc:"拉黑原因，默认为“骰主后台设置”"`
+	}
+}
+
+func registerV1Bans(api huma.API) {
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "list-bans",
+		Method:      http.MethodGet,
+		Path:        "/bans",
+		Summary:     "列出黑白名单",
+		Tags:        []string{"bans"},
+	}, func(_ context.Context, input *V1BanListInput) (*V1BanListOutput, error) {
+		out := &V1BanListOutput{}
+		out.Body.Items = []V1Ban{}
+		for _, item := range myDice.GetBanList() {
+			ban := v1BanFrom(item)
+			if input.Rank != "" && ban.Rank != input.Rank {
+				continue
+			}
+			out.Body.Items = append(out.Body.Items, ban)
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "get-ban",
+		Method:      http.MethodGet,
+		Path:        "/bans/{id}",
+		Summary:     "获取黑白名单条目",
+		Tags:        []string{"bans"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1BanPathInput) (*V1BanOutput, error) {
+		item, ok := myDice.Config.BanList.GetByID(input.ID)
+		if !ok || item == nil {
+			return nil, huma.Error404NotFound("条目不存在")
+		}
+		return &V1BanOutput{Body: v1BanFrom(item)}, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "set-ban",
+		Method:      http.MethodPut,
+		Path:        "/bans/{id}",
+		Summary:     "拉黑或信任用户/群组",
+		Tags:        []string{"bans"},
+		Errors:      []int{http.StatusConflict},
+	}, func(_ context.Context, input *V1BanSetInput) (*V1BanOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		rank := dice.BanRankTrusted
+		if input.Body.Rank == "banned" {
+			rank = dice.BanRankBanned
+		}
+		var reasons []string
+		if input.Body.Reason != "" {
+			reasons = []string{input.Body.Reason}
+		}
+		banListSetOne(input.ID, input.Body.Name, rank, reasons)
+		item, ok := myDice.Config.BanList.GetByID(input.ID)
+		if !ok || item == nil {
+			// 拉黑需要对应平台有启用的账号
+			return nil, huma.Error409Conflict("没有可用于该平台的骰子账号")
+		}
+		return &V1BanOutput{Body: v1BanFrom(item)}, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "delete-ban",
+		Method:      http.MethodDelete,
+		Path:        "/bans/{id}",
+		Summary:     "移出黑白名单",
+		Tags:        []string{"bans"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1BanPathInput) (*v1Empty, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		if _, ok := myDice.Config.BanList.GetByID(input.ID); !ok {
+			return nil, huma.Error404NotFound("条目不存在")
+		}
+		(&myDice.Config).BanList.DeleteByID(myDice, input.ID)
+		return &v1Empty{}, nil
+	})
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"sealdice-core/dice"
+	"sealdice-core/dice/service"
+)
+
+type V1Log struct {
+	ID        uint64 `json:"id"`
+	Name      string `json:"name"`
+	GroupID   string `json:"groupId"`
+	Size      int    `json:"size"      doc:"行数"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+type V1LogItem struct {
+	ID          uint64 `json:"id"`
+	Nickname    string `json:"nickname"`
+	IMUserID    string `json:"imUserId"`
+	UniformID   string `json:"uniformId"`
+	Time        int64  `json:"time"`
+	Message     string `json:"message"`
+	IsDice      bool   `json:"isDice"`
+	CommandID   int64  `json:"commandId"`
+	CommandInfo any    `json:"commandInfo,omitempty" doc:"检定指令的结构化结果"`
+}
+
+type V1LogListInput struct {
+	PageNum          int    `query:"pageNum"          default:"1"  minimum:"1"`
+	PageSize         int    `query:"pageSize"         default:"20" minimum:"1" maximum:"500"`
+	Name             string `query:"name"             doc:"按日志名筛选（模糊匹配）"`
+	GroupID          string `query:"groupId"          doc:"按群组筛选（模糊匹配）"`
+	CreatedTimeBegin string `query:"createdTimeBegin" doc:"创建时间起点，Unix 秒"`
+	CreatedTimeEnd   string `query:"createdTimeEnd"   doc:"创建时间终点，Unix 秒"`
+}
+
+type V1LogListOutput struct {
+	Body struct {
+		Total    int     `json:"total"`
+		PageNum  int     `json:"pageNum"`
+		PageSize int     `json:"pageSize"`
+		Items    []V1Log `json:"items"`
+	}
+}
+
+type V1LogRefInput struct {
+	GroupID string `query:"groupId" required:"true"`
+	Name    string `query:"name"    required:"true"`
+}
+
+type V1LogItemsInput struct {
+	V1LogRefInput
+	PageNum  int `query:"pageNum"  default:"1"   minimum:"1"`
+	PageSize int `query:"pageSize" default:"100" minimum:"1" maximum:"1000"`
+}
+
+type V1LogItemsOutput struct {
+	Body struct {
+		PageNum int         `json:"pageNum"`
+		Items   []V1LogItem `json:"items"`
+	}
+}
+
+func registerV1Logs(api huma.API) {
+	v1Register(api, dice.WebUIPermLogRead, huma.Operation{
+		OperationID: "list-logs",
+		Method:      http.MethodGet,
+		Path:        "/logs",
+		Summary:     "分页列出跑团日志",
+		Tags:        []string{"logs"},
+	}, func(_ context.Context, input *V1LogListInput) (*V1LogListOutput, error) {
+		total, page, err := service.LogGetLogPage(myDice.DBOperator, &service.QueryLogPage{
+			PageNum:          input.PageNum,
+			PageSize:         input.PageSize,
+			Name:             input.Name,
+			GroupID:          input.GroupID,
+			CreatedTimeBegin: input.CreatedTimeBegin,
+			CreatedTimeEnd:   input.CreatedTimeEnd,
+		})
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		out := &V1LogListOutput{}
+		out.Body.Total = total
+		out.Body.PageNum = input.PageNum
+		out.Body.PageSize = input.PageSize
+		out.Body.Items = make([]V1Log, 0, len(page))
+		for _, item := range page {
+			log := V1Log{
+				ID:        item.ID,
+				Name:      item.Name,
+				GroupID:   item.GroupID,
+				CreatedAt: item.CreatedAt,
+				UpdatedAt: item.UpdatedAt,
+			}
+			if item.Size != nil {
+				log.Size = *item.Size
+			}
+			out.Body.Items = append(out.Body.Items, log)
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermLogRead, huma.Operation{
+		OperationID: "list-log-items",
+		Method:      http.MethodGet,
+		Path:        "/logs/items",
+		Summary:     "分页获取日志内容",
+		Tags:        []string{"logs"},
+	}, func(_ context.Context, input *V1LogItemsInput) (*V1LogItemsOutput, error) {
+		lines, err := service.LogGetLinePage(myDice.DBOperator, &service.QueryLogLinePage{
+			PageNum:  input.PageNum,
+			PageSize: input.PageSize,
+			GroupID:  input.GroupID,
+			LogName:  input.Name,
+		})
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		out := &V1LogItemsOutput{}
+		out.Body.PageNum = input.PageNum
+		out.Body.Items = make([]V1LogItem, 0, len(lines))
+		for _, line := range lines {
+			out.Body.Items = append(out.Body.Items, V1LogItem{
+				ID:          line.ID,
+				Nickname:    line.Nickname,
+				IMUserID:    line.IMUserID,
+				UniformID:   line.UniformID,
+				Time:        line.Time,
+				Message:     line.Message,
+				IsDice:      line.IsDice,
+				CommandID:   line.CommandID,
+				CommandInfo: line.CommandInfo,
+			})
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermConfigEdit, huma.Operation{
+		OperationID: "delete-log",
+		Method:      http.MethodDelete,
+		Path:        "/logs",
+		Summary:     "删除一份跑团日志",
+		Tags:        []string{"logs"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1LogRefInput) (*v1Empty, error) {
+		err := service.LogDelete(myDice.DBOperator, input.GroupID, input.Name)
+		if errors.Is(err, service.ErrLogNotFound) {
+			return nil, huma.Error404NotFound("日志不存在")
+		}
+		if err != nil {
+			return nil, v1Error(err)
+		}
+		return &v1Empty{}, nil
+	})
+}
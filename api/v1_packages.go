@@ -0,0 +1,351 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+
+	"sealdice-core/dice"
+	"sealdice-core/dice/sealpack"
+)
+
+type V1Package struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Version       string    `json:"version"`
+	Authors       []string  `json:"authors"`
+	Description   string    `json:"description"`
+	State         string    `json:"state"         enum:"installed,enabled,disabled,error"`
+	InstallTime   time.Time `json:"installTime"`
+	ErrText       string    `json:"errText,omitempty"`
+	PendingReload []string  `json:"pendingReload,omitempty" doc:"状态变更后待重载的内容类型"`
+}
+
+func v1PackageFrom(inst *sealpack.Instance) V1Package {
+	p := V1Package{
+		State:         string(inst.State),
+		InstallTime:   inst.InstallTime,
+		ErrText:       inst.ErrText,
+		PendingReload: inst.PendingReload,
+	}
+	if inst.Manifest != nil {
+		p.ID = inst.Manifest.Package.ID
+		p.Name = inst.Manifest.Package.Name
+		p.Version = inst.Manifest.Package.Version
+		p.Authors = inst.Manifest.Package.Authors
+		p.Description = inst.Manifest.Package.Description
+	}
+	return p
+}
+
+func v1FindPackage(id string) (*sealpack.Instance, error) {
+	for _, inst := range myDice.PackageManager.List() {
+		if inst != nil && inst.Manifest != nil && inst.Manifest.Package.ID == id {
+			return inst, nil
+		}
+	}
+	return nil, huma.Error404NotFound("扩展包不存在")
+}
+
+type V1PackageListOutput struct {
+	Body struct {
+		Items []V1Package `json:"items"`
+	}
+}
+
+type V1PackagePathInput struct {
+	ID string `path:"id"`
+}
+
+type V1PackageOutput struct {
+	Body V1Package
+}
+
+type V1PackageInstallInput struct {
+	Body struct {
+		URL string `json:"url" format:"uri" doc:"远程 .sealpack 文件地址"`
+	}
+}
+
+type V1PackageInstallOutput struct {
+	Body struct {
+		Installed []V1Package `json:"installed" doc:"新安装或版本变化的扩展包"`
+	}
+}
+
+type V1PackageUninstallInput struct {
+	ID   string `path:"id"`
+	Mode string `query:"mode" enum:"full,keep_data,disable_only" default:"full"`
+}
+
+type V1PackageOperationOutput struct {
+	Body *sealpack.OperationResult
+}
+
+func registerV1Packages(api huma.API) {
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "list-packages",
+		Method:      http.MethodGet,
+		Path:        "/packages",
+		Summary:     "列出已安装的扩展包",
+		Tags:        []string{"packages"},
+	}, func(_ context.Context, _ *struct{}) (*V1PackageListOutput, error) {
+		out := &V1PackageListOutput{}
+		out.Body.Items = []V1Package{}
+		for _, inst := range myDice.PackageManager.List() {
+			if inst != nil {
+				out.Body.Items = append(out.Body.Items, v1PackageFrom(inst))
+			}
+		}
+		sort.Slice(out.Body.Items, func(i, j int) bool {
+			return out.Body.Items[i].ID < out.Body.Items[j].ID
+		})
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "get-package",
+		Method:      http.MethodGet,
+		Path:        "/packages/{id}",
+		Summary:     "获取扩展包信息",
+		Tags:        []string{"packages"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1PackagePathInput) (*V1PackageOutput, error) {
+		inst, err := v1FindPackage(input.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &V1PackageOutput{Body: v1PackageFrom(inst)}, nil
+	})
+
+	v1Register(api, dice.WebUIPermExtensionManage, huma.Operation{
+		OperationID: "install-package",
+		Method:      http.MethodPost,
+		Path:        "/packages/install",
+		Summary:     "从 URL 安装扩展包",
+		Tags:        []string{"packages"},
+		Errors:      []int{http.StatusUnprocessableEntity, http.StatusConflict},
+	}, func(_ context.Context, input *V1PackageInstallInput) (*V1PackageInstallOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		before := packageVersionSnapshot()
+		if err := myDice.PackageManager.InstallFromURL(input.Body.URL, nil); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		out := &V1PackageInstallOutput{}
+		out.Body.Installed = []V1Package{}
+		for id, version := range packageVersionSnapshot() {
+			if old, ok := before[id]; ok && old == version {
+				continue
+			}
+			if inst, err := v1FindPackage(id); err == nil {
+				out.Body.Installed = append(out.Body.Installed, v1PackageFrom(inst))
+			}
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermExtensionManage, huma.Operation{
+		OperationID: "enable-package",
+		Method:      http.MethodPost,
+		Path:        "/packages/{id}/enable",
+		Summary:     "启用扩展包",
+		Tags:        []string{"packages"},
+		Errors:      []int{http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusConflict},
+	}, func(_ context.Context, input *V1PackagePathInput) (*V1PackageOperationOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		if _, err := v1FindPackage(input.ID); err != nil {
+			return nil, err
+		}
+		result, err := myDice.PackageManager.Enable(input.ID)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		return &V1PackageOperationOutput{Body: result}, nil
+	})
+
+	v1Register(api, dice.WebUIPermExtensionManage, huma.Operation{
+		OperationID: "disable-package",
+		Method:      http.MethodPost,
+		Path:        "/packages/{id}/disable",
+		Summary:     "禁用扩展包",
+		Tags:        []string{"packages"},
+		Errors:      []int{http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusConflict},
+	}, func(_ context.Context, input *V1PackagePathInput) (*V1PackageOperationOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		if _, err := v1FindPackage(input.ID); err != nil {
+			return nil, err
+		}
+		result, err := myDice.PackageManager.Disable(input.ID)
+		if err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		return &V1PackageOperationOutput{Body: result}, nil
+	})
+
+	v1Register(api, dice.WebUIPermExtensionManage, huma.Operation{
+		OperationID: "uninstall-package",
+		Method:      http.MethodDelete,
+		Path:        "/packages/{id}",
+		Summary:     "卸载扩展包",
+		Description: "卸载后 JS 扩展仍留在内存中，需要重载 JS 才会完全移除。",
+		Tags:        []string{"packages"},
+		Errors:      []int{http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusConflict},
+	}, func(_ context.Context, input *V1PackageUninstallInput) (*v1Empty, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		if _, err := v1FindPackage(input.ID); err != nil {
+			return nil, err
+		}
+		if err := myDice.PackageManager.Uninstall(input.ID, sealpack.UninstallMode(input.Mode)); err != nil {
+			return nil, huma.Error422UnprocessableEntity(err.Error())
+		}
+		return &v1Empty{}, nil
+	})
+}
+
+// ---------- 牌堆 ----------
+
+type V1Deck struct {
+	Filename   string   `json:"filename"`
+	Name       string   `json:"name"`
+	Enable     bool     `json:"enable"`
+	Version    string   `json:"version"`
+	Author     string   `json:"author"`
+	Format     string   `json:"format"     doc:"SinaNya / Dice! / Seal"`
+	FileFormat string   `json:"fileFormat" doc:"json / yaml / toml / jsonc"`
+	Commands   []string `json:"commands"   doc:"牌堆提供的抽取指令"`
+	ErrText    string   `json:"errText,omitempty"`
+}
+
+func v1DeckFrom(deck *dice.DeckInfo) V1Deck {
+	d := V1Deck{
+		Filename:   deck.Filename,
+		Name:       deck.Name,
+		Enable:     deck.Enable,
+		Version:    deck.Version,
+		Author:     deck.Author,
+		Format:     deck.Format,
+		FileFormat: deck.FileFormat,
+		Commands:   make([]string, 0, len(deck.Command)),
+		ErrText:    deck.ErrText,
+	}
+	for cmd := range deck.Command {
+		d.Commands = append(d.Commands, cmd)
+	}
+	sort.Strings(d.Commands)
+	return d
+}
+
+func v1FindDeck(filename string) (*dice.DeckInfo, error) {
+	for _, deck := range myDice.DeckList {
+		if deck.Filename == filename {
+			return deck, nil
+		}
+	}
+	return nil, huma.Error404NotFound("牌堆不存在")
+}
+
+type V1DeckListOutput struct {
+	Body struct {
+		Items []V1Deck `json:"items"`
+	}
+}
+
+type V1DeckPathInput struct {
+	Filename string `path:"filename" doc:"牌堆文件名（含扩展名）"`
+}
+
+type V1DeckUpdateInput struct {
+	Filename string `path:"filename"`
+	Body     struct {
+		Enable bool `json:"enable"`
+	}
+}
+
+type V1DeckOutput struct {
+	Body V1Deck
+}
+
+func registerV1Decks(api huma.API) {
+	v1Register(api, dice.WebUIPermView, huma.Operation{
+		OperationID: "list-decks",
+		Method:      http.MethodGet,
+		Path:        "/decks",
+		Summary:     "列出牌堆",
+		Tags:        []string{"decks"},
+	}, func(_ context.Context, _ *struct{}) (*V1DeckListOutput, error) {
+		out := &V1DeckListOutput{}
+		out.Body.Items = make([]V1Deck, 0, len(myDice.DeckList))
+		for _, deck := range myDice.DeckList {
+			out.Body.Items = append(out.Body.Items, v1DeckFrom(deck))
+		}
+		return out, nil
+	})
+
+	v1Register(api, dice.WebUIPermContentEdit, huma.Operation{
+		OperationID: "update-deck",
+		Method:      http.MethodPatch,
+		Path:        "/decks/{filename}",
+		Summary:     "启用或禁用牌堆",
+		Tags:        []string{"decks"},
+		Errors:      []int{http.StatusNotFound},
+	}, func(_ context.Context, input *V1DeckUpdateInput) (*V1DeckOutput, error) {
+		deck, err := v1FindDeck(input.Filename)
+		if err != nil {
+			return nil, err
+		}
+		deck.Enable = input.Body.Enable
+		myDice.MarkModified()
+		return &V1DeckOutput{Body: v1DeckFrom(deck)}, nil
+	})
+
+	v1Register(api, dice.WebUIPermContentEdit, huma.Operation{
+		OperationID: "delete-deck",
+		Method:      http.MethodDelete,
+		Path:        "/decks/{filename}",
+		Summary:     "删除牌堆文件",
+		Tags:        []string{"decks"},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	}, func(_ context.Context, input *V1DeckPathInput) (*v1Empty, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		deck, err := v1FindDeck(input.Filename)
+		if err != nil {
+			return nil, err
+		}
+		dice.DeckDelete(myDice, deck)
+		myDice.MarkModified()
+		return &v1Empty{}, nil
+	})
+
+	v1Register(api, dice.WebUIPermContentEdit, huma.Operation{
+		OperationID: "reload-decks",
+		Method:      http.MethodPost,
+		Path:        "/decks/reload",
+		Summary:     "重新加载全部牌堆",
+		Tags:        []string{"decks"},
+		Errors:      []int{http.StatusConflict},
+	}, func(_ context.Context, _ *struct{}) (*V1DeckListOutput, error) {
+		if err := v1CheckWritable(); err != nil {
+			return nil, err
+		}
+		dice.DeckReload(myDice)
+		out := &V1DeckListOutput{}
+		out.Body.Items = make([]V1Deck, 0, len(myDice.DeckList))
+		for _, deck := range myDice.DeckList {
+			out.Body.Items = append(out.Body.Items, v1DeckFrom(deck))
+		}
+		return out, nil
+	})
+}
@@ -0,0 +1,77 @@
+package api //nolint:testpackage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+)
+
+func TestV1AuthAndOpenAPI(t *testing.T) {
+	manager := &dice.DiceManager{UIPasswordHash: "ui-hash"}
+	testDice := &dice.Dice{Parent: manager}
+	manager.Dice = []*dice.Dice{testDice}
+	myDice = testDice
+	dm = manager
+	t.Cleanup(func() {
+		myDice = nil
+		dm = nil
+	})
+
+	if err := manager.WebUIAccountAdd("viewer", dice.WebUIRoleViewer, "viewer-hash"); err != nil {
+		t.Fatalf("WebUIAccountAdd() error = %v", err)
+	}
+	token, err := manager.WebUISignIn("viewer", "viewer-hash")
+	if err != nil {
+		t.Fatalf("WebUISignIn() error = %v", err)
+	}
+
+	e := echo.New()
+	bindV1(e, "/sd-api")
+	do := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/sd-api/v1/openapi.json", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("openapi status = %d", rec.Code)
+	}
+	var spec struct {
+		Paths map[string]any `json:"paths"`
+	}
+	if err = json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decode openapi: %v", err)
+	}
+	for _, path := range []string{"/groups", "/bans/{id}", "/characters/{id}", "/logs", "/packages", "/decks", "/endpoints"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Fatalf("openapi missing path %s", path)
+		}
+	}
+
+	if rec = do(http.MethodGet, "/sd-api/v1/decks", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous status = %d, want 401", rec.Code)
+	}
+
+	bearer := map[string]string{echo.HeaderAuthorization: "Bearer " + token}
+	rec = do(http.MethodGet, "/sd-api/v1/decks", "", bearer)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"items":[]`) {
+		t.Fatalf("list decks = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(http.MethodPut, "/sd-api/v1/bans/QQ:1", `{"rank":"trusted"}`, bearer)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("viewer set ban status = %d, want 403", rec.Code)
+	}
+}
@@ -3,6 +3,7 @@ package dice
 import (
 	crand "crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -10,6 +11,7 @@ import (
 	"path"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -35,6 +37,9 @@ var dismissConfirmLastCleanup atomic.Int64
 const dismissConfirmTTL = 10 * time.Minute
 const dismissConfirmCleanupInterval = 30 * time.Minute
 
+// pcExportFileTTL .pc export 生成的临时文件在发送后保留的时间
+const pcExportFileTTL = 10 * time.Minute
+
 const (
 	errGetGroupMemberInfoNil       = "get_group_member_info returned nil"
 	errGetGroupMemberInfoEmptyRole = "empty role from get_group_member_info"
@@ -241,6 +246,7 @@ func (d *Dice) executeDismissOperation(ctx *MsgContext, msg *Message, targetGrou
 		groupName, targetGroupID, userName, msg.Sender.UserID)
 	d.Logger.Info(txt)
 	ctx.Notice(txt)
+	d.AuditRecordByCtx(ctx, AuditActionBotQuit, targetGroupID, nil, nil)
 
 	time.Sleep(3 * time.Second)
 	if targetGroup != nil {
@@ -371,7 +377,10 @@ func (d *Dice) registerCoreCommands() {
 				if reason == "" {
 					reason = "骰主指令"
 				}
-				(&d.Config).BanList.AddScoreBase(uid, (&d.Config).BanList.ThresholdBan, "骰主指令", reason, ctx)
+				before, _ := (&d.Config).BanList.GetByID(uid)
+				beforeSnapshot := auditBanSnapshot(before)
+				after := (&d.Config).BanList.AddScoreBase(uid, (&d.Config).BanList.ThresholdBan, "骰主指令", reason, ctx)
+				d.AuditRecordByCtx(ctx, AuditActionBanAdd, uid, beforeSnapshot, auditBanSnapshot(after))
 				ReplyToSender(ctx, msg, fmt.Sprintf("已将用户/群组 %s 加入黑名单，原因: %s", uid, reason))
 			case "rm", "del":
 				uid = getID()
@@ -386,8 +395,10 @@ func (d *Dice) registerCoreCommands() {
 				}
 
 				ReplyToSender(ctx, msg, fmt.Sprintf("已将用户/群组 %s 移出%s列表", uid, BanRankText[item.Rank]))
+				beforeSnapshot := auditBanSnapshot(item)
 				item.Score = 0
 				item.Rank = BanRankNormal
+				d.AuditRecordByCtx(ctx, AuditActionBanRemove, uid, beforeSnapshot, auditBanSnapshot(item))
 			case "trust":
 				uid = cmdArgs.GetArgN(2)
 				if !strings.Contains(uid, ":") {
@@ -395,7 +406,11 @@ func (d *Dice) registerCoreCommands() {
 					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
 				}
 
+				before, _ := (&d.Config).BanList.GetByID(uid)
+				beforeSnapshot := auditBanSnapshot(before)
 				(&d.Config).BanList.SetTrustByID(uid, "骰主指令", "骰主指令")
+				after, _ := (&d.Config).BanList.GetByID(uid)
+				d.AuditRecordByCtx(ctx, AuditActionBanTrust, uid, beforeSnapshot, auditBanSnapshot(after))
 				ReplyToSender(ctx, msg, fmt.Sprintf("已将用户/群组 %s 加入信任列表", uid))
 			case "list", "show":
 				// ban/warn/trust
@@ -637,9 +652,10 @@ func (d *Dice) registerCoreCommands() {
 				ReplyToSender(ctx, msg, groupStr+"搜索故障: "+err.Error())
 				return CmdExecuteResult{Matched: true, Solved: true}
 			}
+			tableHint := rollTableFindHint(d, text)
 			if len(search.Hits) == 0 {
 				if total == 0 {
-					ReplyToSender(ctx, msg, groupStr+"未找到搜索结果")
+					ReplyToSender(ctx, msg, strings.TrimSpace(groupStr+"未找到搜索结果\n"+tableHint))
 				} else {
 					ReplyToSender(ctx, msg, fmt.Sprintf("%s找到%d条结果, 但在当前页码并无结果", groupStr, total))
 				}
@@ -694,7 +710,7 @@ func (d *Dice) registerCoreCommands() {
 			// pgStart是下标闭左边界, 加1以获得序号; pgEnd是下标开右边界, 无需调整就是最后一条的序号
 			rplPageNum := fmt.Sprintf("共%d条结果, 当前显示第%d页(第%d条 到 第%d条)\n", total, page, pgStart+1, pgEnd)
 			rplPageHint := "使用\".find <词条> --page=<页码> 查看更多结果\n"
-			ReplyToSender(ctx, msg, prefix+groupStr+bestResult+rplCurPage+rplDetailHint+rplPageNum+rplPageHint)
+			ReplyToSender(ctx, msg, prefix+groupStr+bestResult+rplCurPage+rplDetailHint+rplPageNum+rplPageHint+tableHint)
 			return CmdExecuteResult{Matched: true, Solved: true}
 		},
 	}
@@ -853,6 +869,7 @@ func (d *Dice) registerCoreCommands() {
 					}
 
 					SetBotOnAtGroup(ctx, msg.GroupID)
+					d.AuditRecordByCtx(ctx, AuditActionBotOn, msg.GroupID, nil, nil)
 					// TODO：ServiceAtNew此处忽略是否合理？
 					ctx.Group, _ = ctx.Session.ServiceAtNew.Load(msg.GroupID)
 					ctx.IsCurGroupBotOn = true
@@ -876,6 +893,7 @@ func (d *Dice) registerCoreCommands() {
 					}
 
 					SetBotOffAtGroup(ctx, ctx.Group.GroupID)
+					d.AuditRecordByCtx(ctx, AuditActionBotOff, ctx.Group.GroupID, nil, nil)
 					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:骰子关闭"))
 					return CmdExecuteResult{Matched: true, Solved: true}
 				} else if cmdArgs.IsArgEqual(1, "bye", "exit", "quit") {
@@ -1233,6 +1251,7 @@ func (d *Dice) registerCoreCommands() {
 				for _, uid := range readIDList(ctx, msg, cmdArgs) {
 					if uid != ctx.EndPoint.UserID {
 						ctx.Dice.MasterAdd(uid)
+						ctx.Dice.AuditRecordByCtx(ctx, AuditActionMasterAdd, uid, nil, nil)
 						count++
 					}
 				}
@@ -1242,6 +1261,7 @@ func (d *Dice) registerCoreCommands() {
 				var count int
 				for _, uid := range readIDList(ctx, msg, cmdArgs) {
 					if ctx.Dice.MasterRemove(uid) {
+						ctx.Dice.AuditRecordByCtx(ctx, AuditActionMasterRemove, uid, nil, nil)
 						count++
 					}
 				}
@@ -1520,7 +1540,7 @@ func (d *Dice) registerCoreCommands() {
 		EnableExecuteTimesParse: true,
 		Name:                    "roll",
 		ShortHelp:               helpRoll,
-		Help:                    "骰点:\n" + helpRoll,
+		Help:                    "骰点:\n" + helpRoll + "\n.r --explain <表达式> // 逐步展示计算过程",
 		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
 			var text string
 			var diceResultExists bool
@@ -1545,6 +1565,8 @@ func (d *Dice) registerCoreCommands() {
 
 			var r *VMResultV2m
 			var commandInfoItems []any
+			var explains []string
+			explainKw := cmdArgs.GetKwarg("explain")
 
 			rollOne := func() *CmdExecuteResult {
 				forWhat := ""
@@ -1575,6 +1597,12 @@ func (d *Dice) registerCoreCommands() {
 					if r != nil {
 						diceResultX = r.VMValue
 						diceResultExists = true
+						// 后续的模板渲染会复用同一个vm，需要在这里取得计算过程
+						if explainKw != nil {
+							if e := r.Explain(); e != nil {
+								explains = append(explains, e.Text())
+							}
+						}
 					}
 
 					if err == nil {
@@ -1729,6 +1757,10 @@ func (d *Dice) registerCoreCommands() {
 				}
 			}
 
+			if len(explains) > 0 {
+				text += "\n计算过程:\n" + strings.Join(explains, "\n")
+			}
+
 			if kw := cmdArgs.GetKwarg("ci"); kw != nil {
 				info, err := json.Marshal(ctx.CommandInfo)
 				if err == nil {
@@ -1837,6 +1869,22 @@ func (d *Dice) registerCoreCommands() {
 				last = len(cmdArgs.Args)
 			}
 
+			if ctx.Group != nil && cmdArgs.IsArgEqual(last, "on", "off") {
+				activatedNames := func() []string {
+					var names []string
+					for _, i := range ctx.Group.GetActivatedExtList(ctx.Dice) {
+						names = append(names, i.Name)
+					}
+					return names
+				}
+				before := activatedNames()
+				defer func() {
+					if after := activatedNames(); !slices.Equal(before, after) {
+						d.AuditRecordByCtx(ctx, AuditActionExtSwitch, ctx.Group.GroupID, before, after)
+					}
+				}()
+			}
+
 			//nolint:nestif
 			if cmdArgs.IsArgEqual(1, "list") {
 				showList()
@@ -2285,6 +2333,10 @@ func (d *Dice) registerCoreCommands() {
 		".pc save [<角色名>] // [不绑卡]保存角色，角色名可省略\n" +
 		".pc load (<角色名> | <角色序号>) // [不绑卡]加载角色\n" +
 		".pc del/rm (<角色名> | <角色序号>) // 删除角色 角色序号可用pc list查询\n" +
+		".pc export [<角色名> | <角色序号>] [--json | --yaml | --csv] [--text] // 导出角色卡文件，不填为当前卡\n" +
+		".pc import [--name=<角色名>] [--overwrite] <JSON/YAML/CSV文本> // 导入角色卡，不绑卡\n" +
+		".pc history [<角色名> | <角色序号>] // 查看角色卡最近的修改记录，不填为当前卡\n" +
+		".pc undo [<n>] // 将当前卡恢复到第n近一次修改之前(默认1)，其后的修改一并撤销，撤销本身也可再撤销\n" +
 		"> 注: 海豹各群数据独立(多张空白卡)，单群游戏不需要存角色。"
 
 	cmdChar := &CmdItemInfo{
@@ -2292,7 +2344,7 @@ func (d *Dice) registerCoreCommands() {
 		ShortHelp: helpCh,
 		Help:      "角色管理:\n" + helpCh,
 		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) (result CmdExecuteResult) {
-			cmdArgs.ChopPrefixToArgsWith("list", "lst", "load", "save", "del", "rm", "new", "tag", "untagAll", "rename")
+			cmdArgs.ChopPrefixToArgsWith("list", "lst", "load", "save", "del", "rm", "new", "tag", "untagAll", "rename", "export", "import", "history", "undo")
 			val1 := cmdArgs.GetArgN(1)
 			am := d.AttrsManager
 
@@ -2471,7 +2523,7 @@ func (d *Dice) registerCoreCommands() {
 				VarSetValueStr(ctx, "$t角色名", name)
 
 				charId := lo.Must(am.CharIdGetByName(ctx.Player.UserID, name))
-				attrsCur := lo.Must(d.AttrsManager.Load(ctx.Group.GroupID, ctx.Player.UserID))
+				attrsCur := lo.Must(d.AttrsManager.LoadByCtx(ctx))
 
 				if attrsCur == nil {
 					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:角色管理_角色不存在"))
@@ -2509,6 +2561,7 @@ func (d *Dice) registerCoreCommands() {
 							ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:角色管理_序列化失败"))
 							return CmdExecuteResult{Matched: true, Solved: true}
 						}
+						ctx.attrsTouched.add(attrsNew)
 
 						attrs.Range(func(key string, value *ds.VMValue) bool {
 							attrsNew.Store(key, value)
@@ -2537,6 +2590,7 @@ func (d *Dice) registerCoreCommands() {
 					if len(bindingGroups) == 0 {
 						attrs := lo.Must(am.Load(ctx.Group.GroupID, ctx.Player.UserID))
 						attrsExisting := lo.Must(am.LoadById(charId))
+						ctx.attrsTouched.add(attrsExisting)
 
 						attrsExisting.Clear()
 						attrs.Range(func(key string, value *ds.VMValue) bool {
@@ -2587,6 +2641,188 @@ func (d *Dice) registerCoreCommands() {
 					ReplyToSender(ctx, msg, "这张卡片并未绑定到任何群")
 				}
 				return CmdExecuteResult{Matched: true, Solved: true}
+			case "export":
+				format := CharacterSheetJSON
+				for _, k := range []string{"yaml", "yml", "csv", "json"} {
+					if cmdArgs.GetKwarg(k) != nil {
+						format, _ = ParseCharacterSheetFormat(k)
+					}
+				}
+
+				var charId string
+				if name := cmdArgs.GetArgN(2); name != "" {
+					if index, err := strconv.ParseInt(name, 10, 64); err == nil && index > 0 {
+						items, _ := am.GetCharacterList(ctx.Player.UserID)
+						if index <= int64(len(items)) {
+							name = items[index-1].Name
+						}
+					}
+					VarSetValueStr(ctx, "$t角色名", name)
+					charId, _ = am.CharIdGetByName(ctx.Player.UserID, name)
+					if charId == "" {
+						ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:角色管理_角色不存在"))
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+				}
+
+				var data []byte
+				var charName string
+				if charId != "" {
+					exported, item := lo.Must2(am.CharExport(charId, format))
+					data, charName = exported, item.Name
+				} else {
+					// 未绑卡时导出当前群的角色数据
+					attrs := lo.Must(am.Load(ctx.Group.GroupID, ctx.Player.UserID))
+					sheet := lo.Must(CharacterSheetFromAttrs(ctx.Player.Name, ctx.Group.System, attrs))
+					data = lo.Must(sheet.Encode(format))
+					charName = ctx.Player.Name
+				}
+
+				if cmdArgs.GetKwarg("text") != nil {
+					ReplyToSender(ctx, msg, string(data))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+
+				f, err := os.CreateTemp("", "sealdice-pc-*."+string(format))
+				if err != nil {
+					ReplyToSender(ctx, msg, "角色卡导出失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				tmpPath := f.Name()
+				_, err = f.Write(data)
+				if closeErr := f.Close(); err == nil {
+					err = closeErr
+				}
+				if err != nil {
+					_ = os.Remove(tmpPath)
+					ReplyToSender(ctx, msg, "角色卡导出失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				var uri string
+				if runtime.GOOS == "windows" {
+					uri = "files:///" + tmpPath
+				} else {
+					uri = "files://" + tmpPath
+				}
+				SendFileToSenderRaw(ctx, msg, uri, "skip")
+				// 部分适配器在发送后才异步读取文件，稍后再删除
+				time.AfterFunc(pcExportFileTTL, func() {
+					_ = os.Remove(tmpPath)
+				})
+				ReplyToSender(ctx, msg, fmt.Sprintf("角色<%s>已导出为 %s 文件", charName, format))
+				return CmdExecuteResult{Matched: true, Solved: true}
+
+			case "import":
+				// 选项需写在角色卡文本之前
+				text := cmdArgs.CleanArgsChopRest
+				for strings.HasPrefix(text, "--") {
+					i := strings.IndexAny(text, " \r\n")
+					if i < 0 {
+						text = ""
+						break
+					}
+					text = strings.TrimSpace(text[i:])
+				}
+				if text == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+
+				data := []byte(text)
+				sheet, err := DecodeCharacterSheet(data, DetectCharacterSheetFormat("", data))
+				if err != nil {
+					ReplyToSender(ctx, msg, "角色卡解析失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				var name string
+				if kw := cmdArgs.GetKwarg("name"); kw != nil {
+					name = kw.Value
+				}
+				item, err := am.CharImport(d, ctx.Player.UserID, name, sheet, ctx.Group.System, cmdArgs.GetKwarg("overwrite") != nil)
+				if err != nil {
+					if errors.Is(err, ErrCharacterExists) {
+						ReplyToSender(ctx, msg, "同名角色已存在，可使用 --overwrite 覆盖，或使用 --name=<角色名> 另存")
+					} else {
+						ReplyToSender(ctx, msg, "角色卡导入失败: "+err.Error())
+					}
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if attrs, loadErr := am.LoadById(item.Id); loadErr == nil {
+					ctx.attrsTouched.add(attrs)
+				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("角色<%s>导入成功，可使用 .pc tag %s 绑定到当前群", item.Name, item.Name))
+				return CmdExecuteResult{Matched: true, Solved: true}
+
+			case "history":
+				attrs := lo.Must(am.LoadByCtx(ctx))
+				cardName := attrs.Name
+				if name := getNicknameRaw(false, true); name != "" {
+					VarSetValueStr(ctx, "$t角色名", name)
+					charId, _ := am.CharIdGetByName(ctx.Player.UserID, name)
+					if charId == "" {
+						ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:角色管理_角色不存在"))
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					attrs = lo.Must(am.LoadById(charId))
+					cardName = name
+				}
+				if cardName == "" {
+					cardName = ctx.Player.Name
+				}
+
+				const historyShowNum = 10
+				total, revs := lo.Must2(am.RevisionList(attrs.ID, 0, historyShowNum))
+				if total == 0 {
+					ReplyToSender(ctx, msg, fmt.Sprintf("<%s>暂无修改记录", cardName))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				lines := []string{fmt.Sprintf("<%s>的修改记录(共%d条，序号可用于.pc undo):", cardName, total)}
+				for idx, rev := range revs {
+					editor := rev.EditorName
+					if editor == "" {
+						editor = rev.EditorID
+					}
+					if editor == "" {
+						editor = rev.EditorType
+					}
+					lines = append(lines, fmt.Sprintf("%d. %s %s %s\n   %s",
+						idx+1,
+						time.Unix(rev.CreatedAt, 0).Format("01-02 15:04"),
+						editor,
+						rev.Source,
+						formatAttrsRevisionChanges(RevisionChanges(rev), 4)))
+				}
+				ReplyToSender(ctx, msg, strings.Join(lines, "\n"))
+				return CmdExecuteResult{Matched: true, Solved: true}
+
+			case "undo":
+				n := int64(1)
+				if val := cmdArgs.GetArgN(2); val != "" {
+					var err error
+					n, err = strconv.ParseInt(val, 10, 64)
+					if err != nil || n <= 0 {
+						return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+					}
+				}
+				attrs := lo.Must(am.LoadByCtx(ctx))
+				// 先把当前未记录的改动记下，保证撤销的序号与 .pc history 一致
+				am.CommitRevisionsByCtx(ctx, attrsRevisionEditorByCtx(ctx, msg))
+				_, revs := lo.Must2(am.RevisionList(attrs.ID, int(n-1), 1))
+				if len(revs) == 0 {
+					ReplyToSender(ctx, msg, fmt.Sprintf("没有找到第%d条修改记录，可使用.pc history查看", n))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				rev, changes, err := am.RevisionRestore(attrs.ID, revs[0].ID, attrsRevisionEditorByCtx(ctx, msg))
+				if err != nil {
+					ReplyToSender(ctx, msg, "恢复失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if len(changes) == 0 {
+					ReplyToSender(ctx, msg, fmt.Sprintf("已恢复到第%d条修改(%s)之前，属性没有变化", n, rev.Source))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("已恢复到第%d条修改(%s)之前，撤销了以下改动:\n%s", n, rev.Source, formatAttrsRevisionChanges(changes, len(changes))))
+				return CmdExecuteResult{Matched: true, Solved: true}
+
 			case "del", "rm":
 				name := getNicknameRaw(false, true)
 				if name == "" {
@@ -2640,6 +2876,9 @@ func (d *Dice) registerCoreCommands() {
 	d.CmdMap["char"] = cmdChar
 	d.CmdMap["character"] = cmdChar
 	d.CmdMap["pc"] = cmdChar
+	d.CmdMap["npc"] = getCmdNpc()
+	d.CmdMap["campaign"] = getCmdCampaign()
+	d.CmdMap["inv"] = getCmdInventory()
 
 	cmdReply := &CmdItemInfo{
 		Name:      "reply",
@@ -0,0 +1,427 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+// campaignOfGroup 当前群关联的战役，没有关联时回复提示并返回 nil
+func campaignOfGroup(ctx *MsgContext, msg *Message) *model.Campaign {
+	c, err := service.CampaignGetByGroup(ctx.Dice.DBOperator, ctx.Group.GroupID)
+	if err != nil {
+		if errors.Is(err, service.ErrCampaignNotFound) {
+			ReplyToSender(ctx, msg, "当前群没有关联战役，可以使用 .campaign new <名称> 创建，或 .campaign link <名称> 关联已有战役")
+		} else {
+			ReplyToSender(ctx, msg, "读取战役失败: "+err.Error())
+		}
+		return nil
+	}
+	return c
+}
+
+// campaignCanManage 战役主持人，或战役主群、关联群的群管理可以修改战役
+func campaignCanManage(ctx *MsgContext, msg *Message, c *model.Campaign) bool {
+	if c.KPID == ctx.Player.UserID {
+		return true
+	}
+	if ctx.PrivilegeLevel >= 40 {
+		if c.MainGroupID == ctx.Group.GroupID {
+			return true
+		}
+		groups, err := service.CampaignGroups(ctx.Dice.DBOperator, c.ID)
+		if err == nil && slices.Contains(groups, ctx.Group.GroupID) {
+			return true
+		}
+	}
+	ReplyToSender(ctx, msg, fmt.Sprintf("只有战役<%s>的主持人或其所在群的群管理可以进行此操作", c.Name))
+	return false
+}
+
+// campaignAttachLog 将当前群的日志加入战役的最近一次团，群没有关联战役或战役没有团次时不做处理
+func campaignAttachLog(ctx *MsgContext, logName string) (*model.Campaign, *model.CampaignSession, error) {
+	c, err := service.CampaignGetByGroup(ctx.Dice.DBOperator, ctx.Group.GroupID)
+	if err != nil {
+		if errors.Is(err, service.ErrCampaignNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	s, err := service.CampaignSessionCurrent(ctx.Dice.DBOperator, c.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrCampaignNoSession) {
+			return c, nil, nil
+		}
+		return c, nil, err
+	}
+	err = service.CampaignSessionAddLog(ctx.Dice.DBOperator, s, ctx.Group.GroupID, logName)
+	if err != nil && !errors.Is(err, service.ErrCampaignSessionExists) {
+		return c, nil, err
+	}
+	_ = service.CampaignTouch(ctx.Dice.DBOperator, c.ID)
+	return c, s, nil
+}
+
+func campaignSessionTitle(s *model.CampaignSession) string {
+	if s.Title == "" {
+		return fmt.Sprintf("第%d团", s.No)
+	}
+	return fmt.Sprintf("第%d团 %s", s.No, s.Title)
+}
+
+// CampaignInfoText 战役概况
+func CampaignInfoText(ctx *MsgContext, c *model.Campaign) (string, error) {
+	detail, err := service.CampaignGetDetail(ctx.Dice.DBOperator, c.ID, 3)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "战役<%s>\n主持人: %s\n关联群: %s", c.Name, c.KPID, strings.Join(detail.Groups, "、"))
+	var players []string
+	for _, m := range detail.Members {
+		if m.Role == model.CampaignRoleKP {
+			continue
+		}
+		name := m.PcName
+		if name == "" {
+			name = m.UserID
+		}
+		players = append(players, name)
+	}
+	if len(players) > 0 {
+		fmt.Fprintf(&b, "\n成员(%d): %s", len(players), strings.Join(players, "、"))
+	} else {
+		b.WriteString("\n成员: 暂无，玩家可使用 .campaign join 加入")
+	}
+	if n := len(detail.Sessions); n > 0 {
+		last := detail.Sessions[n-1]
+		fmt.Fprintf(&b, "\n团次: 共%d次，当前为%s(%d份日志)", n, campaignSessionTitle(last.CampaignSession), len(last.Logs))
+	} else {
+		b.WriteString("\n团次: 暂无，使用 .campaign session new [标题] 开始第一团")
+	}
+	if len(detail.Notes) > 0 {
+		b.WriteString("\n最近笔记:")
+		for _, n := range detail.Notes {
+			fmt.Fprintf(&b, "\n- %s", n.Content)
+		}
+	}
+	return b.String(), nil
+}
+
+// CampaignStatText 战役全部团次日志的检定统计，按角色列出
+func CampaignStatText(ctx *MsgContext, c *model.Campaign) (string, error) {
+	summaries, err := ctx.Dice.CampaignStats(c.ID, true)
+	if err != nil {
+		return "", err
+	}
+	if len(summaries) == 0 {
+		return "", nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "战役<%s>检定统计:", c.Name)
+	for idx, s := range summaries {
+		if idx >= 10 {
+			fmt.Fprintf(&b, "\n……共%d名角色", len(summaries))
+			break
+		}
+		fmt.Fprintf(&b, "\n%d. [%s] %s", idx+1, s.PcName, logStatFormatLine(s))
+		if skills := logStatFormatSkills(s, 3); skills != "" {
+			b.WriteString("\n   常用技能: " + skills)
+		}
+	}
+	return b.String(), nil
+}
+
+func getCmdCampaign() *CmdItemInfo {
+	helpCampaign := ".campaign new <名称> // 新建战役，当前群为主群，发起人为主持人\n" +
+		".campaign info // 查看当前群关联的战役\n" +
+		".campaign list // 列出当前群关联、自己主持或参加的战役\n" +
+		".campaign link <名称> // 将当前群关联到战役(如副群)\n" +
+		".campaign unlink // 取消当前群的关联\n" +
+		".campaign join // 以当前绑定的角色加入战役，再次使用可更新角色\n" +
+		".campaign leave // 退出战役\n" +
+		".campaign session new [<标题>] // 开始新一团，之后 .log new 的日志会自动归入这一团\n" +
+		".campaign session add [<日志名>] // 将本群的日志归入当前团，默认为正在记录的日志\n" +
+		".campaign session list // 列出各团及其日志\n" +
+		".campaign note <内容> // 添加战役笔记\n" +
+		".campaign notes // 查看最近的笔记\n" +
+		".campaign stat // 战役全部日志的检定统计\n" +
+		".campaign del <名称> // 删除战役，日志本身不受影响"
+
+	return &CmdItemInfo{
+		Name:              "campaign",
+		ShortHelp:         helpCampaign,
+		Help:              "战役管理:\n" + helpCampaign,
+		DisabledInPrivate: true,
+		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
+			cmdArgs.ChopPrefixToArgsWith("new", "info", "list", "link", "unlink", "join", "leave",
+				"session", "notes", "note", "stat", "del", "rm")
+			operator := ctx.Dice.DBOperator
+			reply := func(text string) CmdExecuteResult {
+				ReplyToSender(ctx, msg, text)
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+
+			switch cmdArgs.GetArgN(1) {
+			case "new":
+				name := cmdArgs.GetArgN(2)
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				if len(name) > 90 {
+					return reply("战役名称过长")
+				}
+				c := &model.Campaign{
+					Name:        name,
+					KPID:        ctx.Player.UserID,
+					MainGroupID: ctx.Group.GroupID,
+					CreatorID:   ctx.Player.UserID,
+				}
+				if err := service.CampaignCreate(operator, c); err != nil {
+					return reply("新建战役失败: " + err.Error())
+				}
+				return reply(fmt.Sprintf("已新建战役<%s>，主持人为<%s>，当前群为主群。\n使用 .campaign session new 开始第一团", name, ctx.Player.Name))
+
+			case "info":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				text, err := CampaignInfoText(ctx, c)
+				if err != nil {
+					return reply("读取战役失败: " + err.Error())
+				}
+				return reply(text)
+
+			case "list":
+				list, err := service.CampaignListRelated(operator, ctx.Group.GroupID, ctx.Player.UserID)
+				if err != nil {
+					return reply("读取战役失败: " + err.Error())
+				}
+				if len(list) == 0 {
+					return reply("没有与当前群或你有关的战役，可以使用 .campaign new <名称> 创建")
+				}
+				var lines []string
+				for idx, c := range list {
+					lines = append(lines, fmt.Sprintf("%2d %s (主群%s，更新于%s)", idx+1, c.Name, c.MainGroupID,
+						time.Unix(c.UpdatedAt, 0).Format("2006-01-02")))
+				}
+				return reply("战役列表:\n" + strings.Join(lines, "\n"))
+
+			case "link":
+				name := cmdArgs.GetArgN(2)
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				c, err := service.CampaignGetByName(operator, name)
+				if err != nil {
+					return reply("关联战役失败: " + err.Error())
+				}
+				if !campaignCanManage(ctx, msg, c) {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if err = service.CampaignLinkGroup(operator, c.ID, ctx.Group.GroupID); err != nil {
+					return reply("关联战役失败: " + err.Error())
+				}
+				return reply(fmt.Sprintf("当前群已关联到战役<%s>", c.Name))
+
+			case "unlink":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil || !campaignCanManage(ctx, msg, c) {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if err := service.CampaignUnlinkGroup(operator, c.ID, ctx.Group.GroupID); err != nil {
+					return reply("取消关联失败: " + err.Error())
+				}
+				return reply(fmt.Sprintf("当前群已取消与战役<%s>的关联", c.Name))
+
+			case "join":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				m := &model.CampaignMember{CampaignID: c.ID, UserID: ctx.Player.UserID, PcName: ctx.Player.Name}
+				if am := ctx.Dice.AttrsManager; am != nil {
+					if id, _ := am.CharGetBindingId(ctx.Group.GroupID, ctx.Player.UserID); id != "" {
+						m.CharacterID = id
+						if attrs, err := am.LoadById(id); err == nil && attrs.Name != "" {
+							m.PcName = attrs.Name
+						}
+					}
+				}
+				if err := service.CampaignMemberSet(operator, m); err != nil {
+					return reply("加入战役失败: " + err.Error())
+				}
+				_ = service.CampaignTouch(operator, c.ID)
+				text := fmt.Sprintf("<%s>已加入战役<%s>", m.PcName, c.Name)
+				if m.CharacterID == "" {
+					text += "\n当前没有绑定角色卡，可以使用 .pc tag 绑定后再次 .campaign join"
+				}
+				return reply(text)
+
+			case "leave":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if c.KPID == ctx.Player.UserID {
+					return reply("主持人不能退出战役，如需结束请使用 .campaign del")
+				}
+				if err := service.CampaignMemberRemove(operator, c.ID, ctx.Player.UserID); err != nil {
+					return reply("退出战役失败: " + err.Error())
+				}
+				return reply(fmt.Sprintf("<%s>已退出战役<%s>", ctx.Player.Name, c.Name))
+
+			case "session":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				switch cmdArgs.GetArgN(2) {
+				case "new":
+					if !campaignCanManage(ctx, msg, c) {
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					title := strings.TrimSpace(strings.Join(cmdArgs.Args[2:], " "))
+					s, err := service.CampaignSessionCreate(operator, c.ID, title)
+					if err != nil {
+						return reply("新建团次失败: " + err.Error())
+					}
+					_ = service.CampaignTouch(operator, c.ID)
+					text := fmt.Sprintf("战役<%s>开始%s", c.Name, campaignSessionTitle(s))
+					// 正在记录的日志直接归入新的一团
+					if getGroupLogOn(ctx.Group) {
+						logName := getGroupLogName(ctx.Group)
+						if err = service.CampaignSessionAddLog(operator, s, ctx.Group.GroupID, logName); err == nil {
+							text += fmt.Sprintf("，正在记录的日志<%s>已归入本团", logName)
+						}
+					}
+					return reply(text)
+
+				case "add":
+					if !campaignCanManage(ctx, msg, c) {
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					logName := cmdArgs.GetArgN(3)
+					if logName == "" {
+						logName = getGroupLogName(ctx.Group)
+					}
+					if logName == "" {
+						return reply("请指定日志名")
+					}
+					if _, ok := service.LogLinesCountGet(operator, ctx.Group.GroupID, logName); !ok {
+						return reply(fmt.Sprintf("本群找不到日志<%s>", logName))
+					}
+					s, err := service.CampaignSessionCurrent(operator, c.ID)
+					if err != nil {
+						return reply("归入日志失败: " + err.Error())
+					}
+					if err = service.CampaignSessionAddLog(operator, s, ctx.Group.GroupID, logName); err != nil {
+						return reply("归入日志失败: " + err.Error())
+					}
+					return reply(fmt.Sprintf("日志<%s>已归入%s", logName, campaignSessionTitle(s)))
+
+				case "list", "":
+					detail, err := service.CampaignGetDetail(operator, c.ID, 0)
+					if err != nil {
+						return reply("读取团次失败: " + err.Error())
+					}
+					if len(detail.Sessions) == 0 {
+						return reply(fmt.Sprintf("战役<%s>还没有团次，使用 .campaign session new [标题] 开始第一团", c.Name))
+					}
+					var b strings.Builder
+					fmt.Fprintf(&b, "战役<%s>的团次:", c.Name)
+					for _, s := range detail.Sessions {
+						fmt.Fprintf(&b, "\n%s (%s)", campaignSessionTitle(s.CampaignSession), time.Unix(s.CreatedAt, 0).Format("2006-01-02"))
+						for _, l := range s.Logs {
+							suffix := fmt.Sprintf("%d条", l.Size)
+							if !l.Exists {
+								suffix = "已删除"
+							}
+							fmt.Fprintf(&b, "\n  - %s/%s (%s)", l.GroupID, l.LogName, suffix)
+						}
+					}
+					return reply(b.String())
+				}
+				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+
+			case "note":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				content := strings.TrimSpace(strings.Join(cmdArgs.Args[1:], " "))
+				if content == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				note := &model.CampaignNote{CampaignID: c.ID, AuthorID: ctx.Player.UserID, AuthorName: ctx.Player.Name, Content: content}
+				if err := service.CampaignNoteAdd(operator, note); err != nil {
+					return reply("添加笔记失败: " + err.Error())
+				}
+				_ = service.CampaignTouch(operator, c.ID)
+				return reply(fmt.Sprintf("已为战役<%s>添加笔记", c.Name))
+
+			case "notes":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				notes, err := service.CampaignNotes(operator, c.ID, 10)
+				if err != nil {
+					return reply("读取笔记失败: " + err.Error())
+				}
+				if len(notes) == 0 {
+					return reply(fmt.Sprintf("战役<%s>还没有笔记", c.Name))
+				}
+				var b strings.Builder
+				fmt.Fprintf(&b, "战役<%s>最近的笔记:", c.Name)
+				for _, n := range notes {
+					fmt.Fprintf(&b, "\n[%s] %s: %s", time.Unix(n.CreatedAt, 0).Format("01-02 15:04"), n.AuthorName, n.Content)
+				}
+				return reply(b.String())
+
+			case "stat":
+				c := campaignOfGroup(ctx, msg)
+				if c == nil {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				text, err := CampaignStatText(ctx, c)
+				if err != nil {
+					return reply("统计失败: " + err.Error())
+				}
+				if text == "" {
+					return reply(fmt.Sprintf("战役<%s>的日志中还没有检定记录", c.Name))
+				}
+				return reply(text)
+
+			case "del", "rm":
+				name := cmdArgs.GetArgN(2)
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				c, err := service.CampaignGetByName(operator, name)
+				if err != nil {
+					return reply("删除战役失败: " + err.Error())
+				}
+				if !campaignCanManage(ctx, msg, c) {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if err = service.CampaignDelete(operator, c.ID); err != nil {
+					return reply("删除战役失败: " + err.Error())
+				}
+				return reply(fmt.Sprintf("已删除战役<%s>", c.Name))
+			}
+			return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+		},
+	}
+}
+
+// CampaignStats 战役全部团次日志的检定统计，技能名的归一方式与 .stat 相同
+func (d *Dice) CampaignStats(id uint64, byCharacter bool) ([]*service.LogStatSummary, error) {
+	return service.CampaignStats(d.DBOperator, id, d.logStatSkillName(), byCharacter)
+}
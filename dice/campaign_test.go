@@ -0,0 +1,118 @@
+//nolint:testpackage
+package dice
+
+import (
+	"strings"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+func TestCampaignCommands(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	migrateTestTables(t, d,
+		&model.AttributesItemModel{}, &model.LogInfo{}, &model.LogOneItem{},
+		&model.Campaign{}, &model.CampaignMember{}, &model.CampaignGroup{},
+		&model.CampaignSession{}, &model.CampaignSessionLog{}, &model.CampaignNote{},
+	)
+
+	const mainGroup, sideGroup, kpID, playerID = "QQ-Group:555", "QQ-Group:556", "QQ:100", "QQ:200"
+	send := func(groupID, userID, text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg(groupID, userID, text))
+	}
+
+	if got := send(mainGroup, kpID, ".campaign info"); !strings.Contains(got, "没有关联战役") {
+		t.Fatalf(".campaign info = %q", got)
+	}
+	if got := send(mainGroup, kpID, ".campaign new 无光之城"); !strings.Contains(got, "已新建战役<无光之城>") {
+		t.Fatalf(".campaign new = %q", got)
+	}
+	if got := send(sideGroup, kpID, ".campaign new 无光之城"); !strings.Contains(got, service.ErrCampaignExists.Error()) {
+		t.Fatalf("重复 .campaign new = %q", got)
+	}
+	if got := send(sideGroup, kpID, ".campaign link 无光之城"); !strings.Contains(got, "已关联到战役<无光之城>") {
+		t.Fatalf(".campaign link = %q", got)
+	}
+	if got := send(sideGroup, playerID, ".campaign join"); !strings.Contains(got, "已加入战役<无光之城>") {
+		t.Fatalf(".campaign join = %q", got)
+	}
+	if got := send(mainGroup, playerID, ".campaign session new 序章"); !strings.Contains(got, "主持人") {
+		t.Fatalf("玩家 .campaign session new = %q", got)
+	}
+	if got := send(mainGroup, kpID, ".campaign session new 序章"); !strings.Contains(got, "第1团 序章") {
+		t.Fatalf(".campaign session new = %q", got)
+	}
+
+	// 副群新建的日志归入当前团
+	ctx := &MsgContext{Dice: d, Group: &GroupInfo{GroupID: sideGroup}}
+	if _, err := service.LogGetOrCreate(d.DBOperator, sideGroup, "序章-副群"); err != nil {
+		t.Fatalf("LogGetOrCreate: %v", err)
+	}
+	c, s, err := campaignAttachLog(ctx, "序章-副群")
+	if err != nil || c == nil || s == nil || s.No != 1 {
+		t.Fatalf("campaignAttachLog() = %+v, %+v, %v", c, s, err)
+	}
+	if _, err = service.LogGetOrCreate(d.DBOperator, mainGroup, "序章"); err != nil {
+		t.Fatalf("LogGetOrCreate: %v", err)
+	}
+	if got := send(mainGroup, kpID, ".campaign session add 序章"); !strings.Contains(got, "已归入第1团") {
+		t.Fatalf(".campaign session add = %q", got)
+	}
+	if got := send(mainGroup, kpID, ".campaign session add 不存在"); !strings.Contains(got, "找不到日志") {
+		t.Fatalf(".campaign session add 不存在 = %q", got)
+	}
+	got := send(mainGroup, kpID, ".campaign session list")
+	if !strings.Contains(got, sideGroup+"/序章-副群") || !strings.Contains(got, mainGroup+"/序章") {
+		t.Fatalf(".campaign session list = %q", got)
+	}
+
+	if got = send(sideGroup, playerID, ".campaign note 钥匙在钟楼"); !strings.Contains(got, "已为战役<无光之城>添加笔记") {
+		t.Fatalf(".campaign note = %q", got)
+	}
+	got = send(mainGroup, kpID, ".campaign info")
+	for _, want := range []string{sideGroup, "成员(1)", "第1团 序章(2份日志)", "钥匙在钟楼"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf(".campaign info = %q, want %q", got, want)
+		}
+	}
+	if got = send(mainGroup, kpID, ".campaign stat"); !strings.Contains(got, "还没有检定记录") {
+		t.Fatalf(".campaign stat = %q", got)
+	}
+
+	// 无关群的群管理看不到也不能管理这个战役，副群的群管理可以
+	const otherGroup, adminID = "QQ-Group:557", "QQ:300"
+	sendAsAdmin := func(groupID, text string) string {
+		t.Helper()
+		msg := newGroupMsg(groupID, adminID, text)
+		msg.Sender.GroupRole = "admin"
+		return execTestMsg(t, d, ep, adapter, msg)
+	}
+	if got = sendAsAdmin(otherGroup, ".campaign list"); strings.Contains(got, "无光之城") {
+		t.Fatalf("无关群 .campaign list = %q", got)
+	}
+	if got = send(sideGroup, playerID, ".campaign list"); !strings.Contains(got, "无光之城") {
+		t.Fatalf("成员 .campaign list = %q", got)
+	}
+	if got = sendAsAdmin(otherGroup, ".campaign link 无光之城"); !strings.Contains(got, "主持人") {
+		t.Fatalf("无关群管理 .campaign link = %q", got)
+	}
+	if got = sendAsAdmin(otherGroup, ".campaign del 无光之城"); !strings.Contains(got, "主持人") {
+		t.Fatalf("无关群管理 .campaign del = %q", got)
+	}
+	if got = sendAsAdmin(sideGroup, ".campaign session new 第二章"); !strings.Contains(got, "第2团 第二章") {
+		t.Fatalf("副群管理 .campaign session new = %q", got)
+	}
+
+	if got = send(sideGroup, playerID, ".campaign del 无光之城"); !strings.Contains(got, "主持人") {
+		t.Fatalf("玩家 .campaign del = %q", got)
+	}
+	if got = send(sideGroup, kpID, ".campaign del 无光之城"); !strings.Contains(got, "已删除战役<无光之城>") {
+		t.Fatalf(".campaign del = %q", got)
+	}
+	if got = send(sideGroup, kpID, ".campaign info"); !strings.Contains(got, "没有关联战役") {
+		t.Fatalf("删除后 .campaign info = %q", got)
+	}
+}
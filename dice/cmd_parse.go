@@ -49,6 +49,14 @@ func (i *AtInfo) CopyCtx(ctx *MsgContext) (*MsgContext, bool) {
 	}
 
 	if ctx.Group != nil {
+		if npcName, ok := npcNameFromUserID(i.UserID); ok {
+			mctx.Player = &GroupPlayerInfo{
+				Name:         npcName,
+				UserID:       i.UserID,
+				ValueMapTemp: &ds.ValueMap{},
+			}
+			return mctx, false
+		}
 		p := ctx.Group.PlayerGet(ctx.Dice.DBOperator, i.UserID)
 		if p != nil {
 			mctx.Player = p
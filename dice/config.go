@@ -311,6 +311,25 @@ func (cm *ConfigManager) UnregisterConfig(pluginName string, keys ...string) {
 	_ = cm.save()
 }
 
+// unloadPluginConfigs 插件卸载时停止其定时任务，并将配置项标记为废弃；
+// 与 Load 的处理一致，配置值保留，重新注册后恢复。
+func (cm *ConfigManager) unloadPluginConfigs(pluginName string) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	plugin, ok := cm.Plugins[pluginName]
+	if !ok {
+		return
+	}
+	for _, config := range plugin.Configs {
+		if config.task != nil {
+			config.task.Off()
+			config.task = nil
+		}
+		config.Deprecated = true
+	}
+}
+
 func (cm *ConfigManager) SetConfig(pluginName, key string, value interface{}) error {
 	cm.lock.Lock()
 	defer cm.lock.Unlock()
@@ -684,6 +703,15 @@ func setupBaseTextTemplate(d *Dice) {
 			"技能成长_批量_技能过多警告": {
 				{`试图成长{$t数量}项技能，但{核心:骰子名字}没有这么多骰子。`, 1},
 			},
+			"技能成长_标记列表": {
+				{"{$t玩家}有{$t数量}项技能可以成长：{$t技能列表}", 1},
+			},
+			"技能成长_无标记": {
+				{"{$t玩家}当前没有成长标记，检定成功后会自动标记技能", 1},
+			},
+			"技能成长_标记清除": {
+				{"已清除{$t玩家}的{$t数量}项成长标记", 1},
+			},
 			// -------------------- en end --------------------------
 			"制卡": {
 				{"{$t玩家}的七版COC人物作成:\n{$t制卡结果文本}", 1},
@@ -1071,6 +1099,21 @@ func setupBaseTextTemplate(d *Dice) {
 			"抽牌_结果前缀": {
 				{``, 1},
 			},
+			"随机表_结果": {
+				{"{$t玩家}掷随机表<{$t随机表}>:\n{$t随机表结果}", 1},
+			},
+			"随机表_分隔符": {
+				{`\n`, 1},
+			},
+			"随机表_找不到随机表": {
+				{"找不到随机表<{$t随机表}>", 1},
+			},
+			"随机表_列表": {
+				{"载入的随机表:\n{$t随机表列表}", 1},
+			},
+			"随机表_列表_没有随机表": {
+				{"没有找到任何随机表", 1},
+			},
 			"随机名字": {
 				{"为{$t玩家}生成以下名字：\n{$t随机名字文本}", 1},
 			},
@@ -1171,6 +1214,12 @@ func setupBaseTextTemplate(d *Dice) {
 			"记录_导出_成功": {
 				{`日志文件《{$t文件名字}》已上传至群文件，请自行到群文件查看。`, 1},
 			},
+			"记录_公平骰_承诺": {
+				{"公平骰已启用，本次记录的骰点均由种子推算，种子的sha256承诺为:\n{$t公平骰承诺}\n记录结束时将公布种子以供校验", 1},
+			},
+			"记录_公平骰_公布": {
+				{"公平骰种子公布:\n种子: {$t公平骰种子}\n承诺: {$t公平骰承诺}\n共{$t公平骰指令数}条指令，可使用校验工具复算日志中带有 fairDice 序号的指令", 1},
+			},
 		},
 	}
 
@@ -1395,6 +1444,15 @@ func setupBaseTextTemplate(d *Dice) {
 			"技能成长_批量_技能过多警告": {
 				SubType: ".en 批量",
 			},
+			"技能成长_标记列表": {
+				SubType: ".en list",
+			},
+			"技能成长_无标记": {
+				SubType: ".en list",
+			},
+			"技能成长_标记清除": {
+				SubType: ".en clear",
+			},
 			// -------------------- en end --------------------------
 			"制卡": {
 				SubType: ".coc 2",
@@ -1812,6 +1870,25 @@ func setupBaseTextTemplate(d *Dice) {
 				SubType:   ".draw",
 				ExtraText: "多个抽取结果之间的分隔符",
 			},
+			"随机表_结果": {
+				SubType: ".rt",
+				Vars:    []string{"$t随机表", "$t随机表结果"},
+			},
+			"随机表_分隔符": {
+				SubType:   ".rt",
+				ExtraText: "多次掷随机表时各结果之间的分隔符",
+			},
+			"随机表_找不到随机表": {
+				SubType: ".rt",
+				Vars:    []string{"$t随机表"},
+			},
+			"随机表_列表": {
+				SubType: ".rt list",
+				Vars:    []string{"$t随机表列表"},
+			},
+			"随机表_列表_没有随机表": {
+				SubType: ".rt list",
+			},
 			"随机名字": {
 				SubType: ".name/.namednd",
 			},
@@ -1927,6 +2004,14 @@ func setupBaseTextTemplate(d *Dice) {
 			"记录_导出_成功": {
 				SubType: ".log export",
 			},
+			"记录_公平骰_承诺": {
+				SubType: ".log new",
+				Vars:    []string{"$t公平骰承诺"},
+			},
+			"记录_公平骰_公布": {
+				SubType: ".log end",
+				Vars:    []string{"$t公平骰种子", "$t公平骰承诺", "$t公平骰指令数"},
+			},
 		},
 	}
 	d.TextMapRaw = texts
@@ -2055,6 +2140,13 @@ func setupTextTemplate(d *Dice) {
 
 	// 加载硬盘设置
 	loadTextTemplate(d, "configs/text-template.yaml")
+	// 补上先于文本加载的规则模板的检定文本
+	if d.GameSystemMap != nil {
+		d.GameSystemMap.Range(func(_ string, tmpl *GameSystemTemplate) bool {
+			d.registerTemplateCheckTexts(tmpl)
+			return true
+		})
+	}
 
 	d.SaveText()
 	d.GenerateTextMap()
@@ -211,6 +211,8 @@ type Dice struct {
 	Logger        *zap.SugaredLogger `yaml:"-"` // 日志
 	LogWriter     *logger.UIWriter   `yaml:"-"` // 用于api的log对象
 	IsDeckLoading bool               `yaml:"-"` // 正在加载中
+	RollTableList []*RollTable       `yaml:"-"` // 随机表，读写需持有 rollTableLock，读取用 RollTables
+	rollTableLock sync.RWMutex
 
 	// 由于被导出的原因，暂时不迁移至 config
 	DeckList      []*DeckInfo `jsbind:"deckList"      yaml:"deckList"`      // 牌堆信息
@@ -240,13 +242,24 @@ type Dice struct {
 	JsScriptCronLock *sync.Mutex     `json:"-" yaml:"-"`
 	// 重载使用的互斥锁
 	JsReloadLock sync.Mutex `json:"-" yaml:"-"`
+	// 保护 JsScriptList，单个脚本重载时会替换其中的条目
+	jsScriptListLock sync.RWMutex
 	// 内置脚本摘要表，用于判断内置脚本是否有更新
 	JsBuiltinDigestSet map[string]bool `json:"-" yaml:"-"`
 	// 当前在加载的脚本路径，用于关联 jsScriptInfo 和 ExtInfo
 	JsLoadingScript *JsScriptInfo `json:"-" yaml:"-"`
-
+	// 脚本执行看门狗，记录耗时并中断超时的调用
+	JsWatchdog *JsWatchdog `json:"-" yaml:"-"`
+	// 脚本通过 seal.http 注册的路由
+	JsHTTPRouter *JsHTTPRouter `json:"-" yaml:"-"`
+	// 扩展间通信，JS 与 Go 扩展共用
+	IPC *ExtIPC `json:"-" yaml:"-"`
+	// 扩展的结构化存储
+	ExtDocs *ExtDocManager `json:"-" yaml:"-"`
 	// 游戏系统规则模板
 	GameSystemMap *SyncMap[string, *GameSystemTemplate] `json:"-" yaml:"-"`
+	// 模板检定指令扩展，键为模板名
+	templateCheckExts SyncMap[string, *ExtInfo]
 
 	RunAfterLoaded []func() `json:"-" yaml:"-"`
 
@@ -345,6 +358,10 @@ func (d *Dice) Init(operator engine.DatabaseOperator, uiWriter *logger.UIWriter)
 		loggerInstance.Error("Failed to load plugin configs: ", err)
 	}
 
+	d.IPC = NewExtIPC(d)
+	d.JsHTTPRouter = NewJsHTTPRouter()
+	d.ExtDocs = NewExtDocManager(d)
+
 	d.registerCoreCommands()
 	d.RegisterBuiltinExt()
 	d.loads()
@@ -775,6 +792,9 @@ func (d *Dice) ExtRemove(ei *ExtInfo) bool {
 			}
 			d.JsExtRegistry.Delete(ei.Name)
 		}
+		if d.ExtDocs != nil {
+			d.ExtDocs.Close(ei.Name)
+		}
 		// 更新时间戳触发延迟更新
 		d.ExtUpdateTime = time.Now().Unix()
 	} else {
@@ -889,6 +909,7 @@ func (d *Dice) GameSystemTemplateAddEx(tmpl *GameSystemTemplate, overwrite bool)
 	if !exists || overwrite {
 		tmpl.Init()
 		d.GameSystemMap.Store(tmpl.Name, tmpl)
+		d.syncTemplateCheckExt(tmpl)
 		return true
 	}
 	return false
@@ -950,6 +971,7 @@ func (d *Dice) reloadGameSystemTemplates(files []string) error {
 			d.Logger.Infof("模板 %s 已重载", tmpl.Name)
 		}
 	}
+	d.pruneTemplateCheckExts()
 	if count > 0 {
 		d.Logger.Infof("游戏系统模板重载完成，共加载 %d 个模板", count)
 	}
@@ -0,0 +1,368 @@
+package dice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+	"github.com/xuri/excelize/v2"
+)
+
+// ---------- COC7 Excel 人物卡 ----------
+//
+// 常见的 COC7 人物卡表格各版本排版不同，这里不依赖固定坐标：
+// 逐行扫描，遇到能被 coc7 模板识别的属性/技能名（含别名），取其右侧连续数字中最大的一个。
+// 技能行一般为「基础 职业 兴趣 成长 成功率 困难 极难」，成功率是其中最大值；属性行同理。
+
+// coc7ExcelNameLabels 角色名所在单元格的标签
+var coc7ExcelNameLabels = []string{"姓名", "调查员姓名", "调查员", "角色名", "Name"}
+
+func decodeCOC7Excel(data []byte) (*CharacterSheet, error) {
+	tmpl, err := loadBuiltinTemplate("coc7.yaml")
+	if err != nil {
+		return nil, err
+	}
+	known := coc7KnownAttrs(tmpl.GameSystemTemplateV2)
+
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	sheet := &CharacterSheet{SheetType: "coc7", Attrs: map[string]any{}}
+	for _, sheetName := range f.GetSheetList() {
+		rows, err := f.GetRows(sheetName)
+		if err != nil {
+			continue
+		}
+		for _, row := range rows {
+			coc7ExcelScanRow(row, known, tmpl.GameSystemTemplateV2, sheet)
+		}
+		// 通常第一张有内容的表就是人物卡
+		if len(sheet.Attrs) > 0 {
+			break
+		}
+	}
+	return sheet, nil
+}
+
+// coc7KnownAttrs 模板中出现过的全部属性名（小写）到主名的映射
+func coc7KnownAttrs(tmpl *GameSystemTemplateV2) map[string]string {
+	known := map[string]string{}
+	for k := range tmpl.Attrs.Defaults {
+		known[strings.ToLower(k)] = k
+	}
+	for k := range tmpl.Attrs.DefaultsComputed {
+		known[strings.ToLower(k)] = k
+	}
+	for k, aliases := range tmpl.Alias {
+		known[strings.ToLower(k)] = k
+		for _, a := range aliases {
+			known[strings.ToLower(a)] = k
+		}
+	}
+	return known
+}
+
+// coc7ExcelLabel 将单元格文本规整为可能的属性名，如「侦查 Spot Hidden」「母语（汉语）」
+func coc7ExcelLabel(cell string, known map[string]string) (string, bool) {
+	text := strings.TrimSpace(cell)
+	text = strings.TrimLeft(text, "☐☑□■√✓✔*")
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", false
+	}
+	candidates := []string{text}
+	for _, sep := range []string{"（", "(", " ", "\n", "/"} {
+		if before, _, ok := strings.Cut(text, sep); ok && before != "" {
+			candidates = append(candidates, strings.TrimSpace(before))
+		}
+	}
+	for _, c := range candidates {
+		if name, ok := known[strings.ToLower(c)]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func coc7ExcelNumber(cell string) (float64, bool) {
+	text := strings.TrimSuffix(strings.TrimSpace(cell), "%")
+	if text == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	return n, err == nil
+}
+
+func coc7ExcelScanRow(row []string, known map[string]string, tmpl *GameSystemTemplateV2, sheet *CharacterSheet) {
+	for i := 0; i < len(row); i++ {
+		cell := strings.TrimSpace(row[i])
+		if sheet.Name == "" && coc7ExcelIsNameLabel(cell) {
+			for j := i + 1; j < len(row); j++ {
+				if v := strings.TrimSpace(row[j]); v != "" {
+					sheet.Name = v
+					break
+				}
+			}
+			continue
+		}
+
+		name, ok := coc7ExcelLabel(cell, known)
+		if !ok {
+			continue
+		}
+		best, found := math.Inf(-1), false
+		j := i + 1
+		for ; j < len(row); j++ {
+			next := strings.TrimSpace(row[j])
+			if next == "" {
+				continue
+			}
+			if n, ok := coc7ExcelNumber(next); ok {
+				best, found = math.Max(best, n), true
+				continue
+			}
+			// 「力量 STR 60」这种排版中，紧跟的英文缩写也是同一属性
+			if other, ok := coc7ExcelLabel(next, known); ok && other == name && !found {
+				continue
+			}
+			break
+		}
+		if found {
+			key := tmpl.GetAlias(name)
+			if _, exists := sheet.Attrs[key]; !exists {
+				sheet.Attrs[key] = int64(best)
+			}
+		}
+		i = j - 1
+	}
+}
+
+func coc7ExcelIsNameLabel(cell string) bool {
+	cell = strings.TrimRight(cell, ":：")
+	for _, label := range coc7ExcelNameLabels {
+		if strings.EqualFold(cell, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------- D&D 5e JSON ----------
+//
+// 支持 Foundry VTT 的 dnd5e 角色导出（system 或旧版 data 字段），
+// 以及 D&D Beyond 角色 JSON 中的基础属性与生命值。
+
+var dnd5eAbilityKeys = map[string]string{
+	"str": "力量",
+	"dex": "敏捷",
+	"con": "体质",
+	"int": "智力",
+	"wis": "感知",
+	"cha": "魅力",
+}
+
+// D&D Beyond 中 stats 的 id 顺序
+var dnd5eBeyondStatIDs = map[int]string{1: "力量", 2: "敏捷", 3: "体质", 4: "智力", 5: "感知", 6: "魅力"}
+
+var dnd5eFoundrySkills = map[string]string{
+	"acr": "体操",
+	"ani": "驯兽",
+	"arc": "奥秘",
+	"ath": "运动",
+	"dec": "欺瞒",
+	"his": "历史",
+	"ins": "洞悉",
+	"itm": "威吓",
+	"inv": "调查",
+	"med": "医药",
+	"nat": "自然",
+	"prc": "察觉",
+	"prf": "表演",
+	"per": "游说",
+	"rel": "宗教",
+	"slt": "巧手",
+	"ste": "隐匿",
+	"sur": "求生",
+}
+
+type dnd5eFoundryActor struct {
+	Name   string            `json:"name"`
+	System *dnd5eFoundryData `json:"system"`
+	Data   *dnd5eFoundryData `json:"data"`
+	Stats  []dnd5eBeyondStat `json:"stats"` // D&D Beyond 直接导出 data 内容时在顶层
+}
+
+type dnd5eFoundryData struct {
+	Abilities map[string]struct {
+		Value      float64 `json:"value"`
+		Proficient float64 `json:"proficient"`
+	} `json:"abilities"`
+	Attributes struct {
+		HP struct {
+			Value *float64 `json:"value"`
+			Max   *float64 `json:"max"`
+		} `json:"hp"`
+		AC struct {
+			Flat  *float64 `json:"flat"`
+			Value *float64 `json:"value"`
+		} `json:"ac"`
+		Prof *float64 `json:"prof"`
+	} `json:"attributes"`
+	Skills map[string]struct {
+		Value float64 `json:"value"`
+	} `json:"skills"`
+
+	// D&D Beyond 的 data 字段
+	Name             string            `json:"name"`
+	Stats            []dnd5eBeyondStat `json:"stats"`
+	BonusStats       []dnd5eBeyondStat `json:"bonusStats"`
+	OverrideStats    []dnd5eBeyondStat `json:"overrideStats"`
+	BaseHitPoints    *float64          `json:"baseHitPoints"`
+	BonusHitPoints   *float64          `json:"bonusHitPoints"`
+	RemovedHitPoints *float64          `json:"removedHitPoints"`
+}
+
+type dnd5eBeyondStat struct {
+	ID    int      `json:"id"`
+	Value *float64 `json:"value"`
+}
+
+func isDnD5eExport(data []byte) bool {
+	var probe struct {
+		System map[string]json.RawMessage `json:"system"`
+		Data   map[string]json.RawMessage `json:"data"`
+		Stats  json.RawMessage            `json:"stats"`
+	}
+	if json.Unmarshal(data, &probe) != nil {
+		return false
+	}
+	for _, m := range []map[string]json.RawMessage{probe.System, probe.Data} {
+		if _, ok := m["abilities"]; ok {
+			return true
+		}
+		if _, ok := m["stats"]; ok {
+			return true
+		}
+	}
+	return len(probe.Stats) > 0
+}
+
+func decodeDnD5eJSON(data []byte) (*CharacterSheet, error) {
+	var actor dnd5eFoundryActor
+	if err := json.Unmarshal(data, &actor); err != nil {
+		return nil, err
+	}
+	body := actor.System
+	if body == nil {
+		body = actor.Data
+	}
+	if body == nil {
+		body = &dnd5eFoundryData{Name: actor.Name, Stats: actor.Stats}
+	}
+
+	sheet := &CharacterSheet{Name: actor.Name, SheetType: "dnd5e", Attrs: map[string]any{}}
+	if sheet.Name == "" {
+		sheet.Name = body.Name
+	}
+
+	switch {
+	case len(body.Abilities) > 0:
+		dnd5eFromFoundry(body, sheet)
+	case len(body.Stats) > 0:
+		dnd5eFromBeyond(body, sheet)
+	default:
+		return nil, errors.New("未找到 D&D 5e 属性数据")
+	}
+	return sheet, nil
+}
+
+func dnd5eFromFoundry(body *dnd5eFoundryData, sheet *CharacterSheet) {
+	for key, ab := range body.Abilities {
+		name, ok := dnd5eAbilityKeys[key]
+		if !ok {
+			continue
+		}
+		sheet.Attrs[name] = int64(ab.Value)
+		if ab.Proficient != 0 {
+			sheet.Attrs[stpFormat(name)] = dnd5eNumber(ab.Proficient)
+		}
+	}
+	attrs := body.Attributes
+	if attrs.HP.Value != nil {
+		sheet.Attrs["hp"] = int64(*attrs.HP.Value)
+	}
+	if attrs.HP.Max != nil {
+		sheet.Attrs["hpmax"] = int64(*attrs.HP.Max)
+	}
+	if attrs.AC.Flat != nil {
+		sheet.Attrs["ac"] = int64(*attrs.AC.Flat)
+	} else if attrs.AC.Value != nil {
+		sheet.Attrs["ac"] = int64(*attrs.AC.Value)
+	}
+	if attrs.Prof != nil {
+		sheet.Attrs["熟练"] = int64(*attrs.Prof)
+	}
+	for key, skill := range body.Skills {
+		name, ok := dnd5eFoundrySkills[key]
+		if !ok || skill.Value == 0 {
+			// 未熟练的技能使用模板默认值（等于调整值）
+			continue
+		}
+		sheet.Attrs[name] = newDnd5eSkillValue(dndAttrParent[name], ds.NewIntVal(0), dnd5eVMNumber(skill.Value))
+	}
+}
+
+func dnd5eFromBeyond(body *dnd5eFoundryData, sheet *CharacterSheet) {
+	stats := map[string]float64{}
+	for _, s := range body.Stats {
+		if name, ok := dnd5eBeyondStatIDs[s.ID]; ok && s.Value != nil {
+			stats[name] = *s.Value
+		}
+	}
+	for _, s := range body.BonusStats {
+		if name, ok := dnd5eBeyondStatIDs[s.ID]; ok && s.Value != nil {
+			stats[name] += *s.Value
+		}
+	}
+	for _, s := range body.OverrideStats {
+		if name, ok := dnd5eBeyondStatIDs[s.ID]; ok && s.Value != nil {
+			stats[name] = *s.Value
+		}
+	}
+	for name, v := range stats {
+		sheet.Attrs[name] = int64(v)
+	}
+	if body.BaseHitPoints != nil {
+		hpMax := *body.BaseHitPoints
+		if body.BonusHitPoints != nil {
+			hpMax += *body.BonusHitPoints
+		}
+		hp := hpMax
+		if body.RemovedHitPoints != nil {
+			hp -= *body.RemovedHitPoints
+		}
+		sheet.Attrs["hpmax"] = int64(hpMax)
+		sheet.Attrs["hp"] = int64(hp)
+	}
+}
+
+func dnd5eNumber(v float64) any {
+	if v == math.Trunc(v) {
+		return int64(v)
+	}
+	return v
+}
+
+func dnd5eVMNumber(v float64) *ds.VMValue {
+	if v == math.Trunc(v) {
+		return ds.NewIntVal(ds.IntType(v))
+	}
+	return ds.NewFloatVal(v)
+}
@@ -18,6 +18,7 @@ import (
 )
 
 type AttrsManager struct {
+	parent *Dice
 	db     engine.DatabaseOperator
 	logger *zap.SugaredLogger
 	cancel context.CancelFunc
@@ -33,11 +34,18 @@ func (am *AttrsManager) Stop() {
 
 // LoadByCtx 获取当前角色，如有绑定，则获取绑定的角色，若无绑定，获取群内默认卡
 func (am *AttrsManager) LoadByCtx(ctx *MsgContext) (*AttributesItem, error) {
+	var i *AttributesItem
+	var err error
 	// 如果是兼容性测试环境，跳过绑定查询以避免不必要的数据库操作
 	if ctx.IsCompatibilityTest {
-		return am.LoadByIdDirect(ctx.Group.GroupID, ctx.Player.UserID)
+		i, err = am.LoadByIdDirect(ctx.Group.GroupID, ctx.Player.UserID)
+	} else {
+		i, err = am.Load(ctx.Group.GroupID, ctx.Player.UserID)
 	}
-	return am.Load(ctx.Group.GroupID, ctx.Player.UserID)
+	if err == nil {
+		ctx.attrsTouched.add(i)
+	}
+	return i, err
 }
 
 func (am *AttrsManager) Load(groupId string, userId string) (*AttributesItem, error) {
@@ -105,6 +113,9 @@ func (am *AttrsManager) CharDelete(id string) error {
 	if err := service.AttrsDeleteById(am.db, id); err != nil {
 		return err
 	}
+	if err := service.AttrsRevisionDeleteByAttrsID(am.db, id); err != nil {
+		am.logger.Errorf("删除角色卡修改记录失败 id=%s: %v", id, err)
+	}
 	// 从缓存中删除
 	am.m.Delete(id)
 	return nil
@@ -143,6 +154,10 @@ func (am *AttrsManager) LoadById(id string) (*AttributesItem, error) {
 					SheetType:    data.SheetType,
 					LastUsedTime: time.Now().Unix(),
 					IsSaved:      true,
+
+					revisionData:      data.Data,
+					revisionSheetType: data.SheetType,
+					revisionTracked:   attrsRevisionTracked(id, data.AttrsType),
 				}
 				am.m.Store(id, i)
 				return i, nil
@@ -166,12 +181,15 @@ func (am *AttrsManager) LoadById(id string) (*AttributesItem, error) {
 		LastModifiedTime: now,
 		LastUsedTime:     now,
 		IsSaved:          false,
+
+		revisionTracked: attrsRevisionTracked(id, ""),
 	}
 	am.m.Store(id, i)
 	return i, nil
 }
 
 func (am *AttrsManager) Init(d *Dice) {
+	am.parent = d
 	am.db = d.DBOperator
 	am.logger = d.Logger
 	// 创建一个 context 用于取消 goroutine
@@ -212,6 +230,9 @@ func (am *AttrsManager) CheckForSave() error {
 		// 尚未初始化
 		return errors.New("数据库尚未初始化")
 	}
+	// 指令之外产生的改动在此补记
+	am.CommitRevisions(AttrsRevisionEditor{Type: AttrsRevisionEditorSystem, Source: "后台保存"})
+
 	var resultList []*service.AttributesBatchUpsertModel
 	prepareToSave := map[string]int{}
 	am.m.Range(func(key string, value *AttributesItem) bool {
@@ -352,6 +373,12 @@ type AttributesItem struct {
 	IsSaved          bool
 	Name             string
 	SheetType        string
+
+	revisionLock      sync.Mutex // 保护以下修改记录相关字段
+	revisionData      []byte     // 上次生成修改记录时的卡数据
+	revisionSheetType string
+	revisionTracked   bool // 是否记录修改历史
+	revisionPending   bool // 自上次记录以来是否有改动
 }
 
 func (i *AttributesItem) SaveToDB(db engine.DatabaseOperator) {
@@ -396,11 +423,13 @@ func (i *AttributesItem) LoadX(name string) (*ds.VMValue, bool) {
 func (i *AttributesItem) Delete(name string) {
 	i.valueMap.Delete(name)
 	i.LastModifiedTime = time.Now().Unix()
+	i.markRevisionPending()
 }
 
 func (i *AttributesItem) SetModified() {
 	i.LastModifiedTime = time.Now().Unix()
 	i.IsSaved = false
+	i.markRevisionPending()
 }
 
 func (i *AttributesItem) Store(name string, value *ds.VMValue) {
@@ -409,6 +438,7 @@ func (i *AttributesItem) Store(name string, value *ds.VMValue) {
 	i.LastModifiedTime = now
 	i.LastUsedTime = now
 	i.IsSaved = false
+	i.markRevisionPending()
 }
 
 func (i *AttributesItem) Clear() int {
@@ -416,6 +446,7 @@ func (i *AttributesItem) Clear() int {
 	i.valueMap.Clear()
 	i.LastModifiedTime = time.Now().Unix()
 	i.IsSaved = false
+	i.markRevisionPending()
 	return size
 }
 
@@ -457,6 +488,7 @@ func (i *AttributesItem) SetSheetType(system string) {
 	i.SheetType = system
 	i.LastModifiedTime = time.Now().Unix()
 	i.IsSaved = false
+	i.markRevisionPending()
 }
 
 func (i *AttributesItem) Len() int {
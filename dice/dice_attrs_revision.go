@@ -0,0 +1,315 @@
+package dice
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+// 角色卡修改记录
+//
+// 卡片数据仍按原有方式延迟落盘，修改记录则在每条指令执行完毕后生成：
+// 对本条指令读写过的卡片，对比当前数据与上次记录时的数据，有变化就写入一条记录，保存修改前的完整数据和属性差异。
+// 指令之外的修改（如扩展的定时任务）在后台定期保存时补记，修改者记为 system。
+// 只记录角色卡与群内默认卡，群属性和用户全局属性不记录。
+
+const (
+	AttrsRevisionEditorIM     = "im"
+	AttrsRevisionEditorWebUI  = "webui"
+	AttrsRevisionEditorSystem = "system"
+)
+
+// attrsRevisionSourceMaxLen 记录的指令原文最大长度（字符）
+const attrsRevisionSourceMaxLen = 100
+
+// AttrsRevisionEditor 修改者信息
+type AttrsRevisionEditor struct {
+	Type   string
+	ID     string
+	Name   string
+	Source string // 触发修改的指令原文或接口
+}
+
+// attrsRevisionEditorByCtx 以指令发送者作为修改者
+func attrsRevisionEditorByCtx(ctx *MsgContext, msg *Message) AttrsRevisionEditor {
+	editor := AttrsRevisionEditor{Type: AttrsRevisionEditorIM}
+	if ctx.Player != nil {
+		editor.ID = ctx.Player.UserID
+		editor.Name = ctx.Player.Name
+	}
+	if msg != nil {
+		editor.Source = msg.Message
+		if utf8.RuneCountInString(editor.Source) > attrsRevisionSourceMaxLen {
+			editor.Source = string([]rune(editor.Source)[:attrsRevisionSourceMaxLen]) + "…"
+		}
+	}
+	return editor
+}
+
+// attrsRevisionTracked 判断一份属性数据是否需要记录修改历史
+func attrsRevisionTracked(id string, attrsType string) bool {
+	if attrsType == service.AttrsTypeCharacter || attrsType == service.AttrsTypeGroupUser || attrsType == service.AttrsTypeNpc {
+		return true
+	}
+	// 运行期创建的群内默认卡没有 attrs_type，按 ID 格式判断
+	_, userPart, ok := UnpackGroupUserId(id)
+	return ok && userPart != ""
+}
+
+func (am *AttrsManager) revisionLimits() (maxCount int, maxDays int) {
+	if am.parent == nil {
+		return DefaultConfig.AttrsRevisionMaxCount, DefaultConfig.AttrsRevisionMaxDays
+	}
+	return am.parent.Config.AttrsRevisionMaxCount, am.parent.Config.AttrsRevisionMaxDays
+}
+
+// attrsRevisionTouched 一条指令读写过的卡片
+type attrsRevisionTouched struct {
+	lock  sync.Mutex
+	items []*AttributesItem
+}
+
+func (t *attrsRevisionTouched) add(i *AttributesItem) {
+	if t == nil || i == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, item := range t.items {
+		if item == i {
+			return
+		}
+	}
+	t.items = append(t.items, i)
+}
+
+func (t *attrsRevisionTouched) list() []*AttributesItem {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]*AttributesItem(nil), t.items...)
+}
+
+func (i *AttributesItem) markRevisionPending() {
+	i.revisionLock.Lock()
+	i.revisionPending = true
+	i.revisionLock.Unlock()
+}
+
+// CommitRevisions 为自上次记录以来有改动的全部卡片生成修改记录，用于后台补记
+func (am *AttrsManager) CommitRevisions(editor AttrsRevisionEditor) {
+	am.m.Range(func(_ string, i *AttributesItem) bool {
+		am.commitRevision(i, editor)
+		return true
+	})
+}
+
+// CommitRevisionsByCtx 只为本条指令读写过的卡片生成修改记录。
+// 其他来源(别的群的指令、扩展的定时任务等)的改动保持待记录，由各自的指令或后台保存补记
+func (am *AttrsManager) CommitRevisionsByCtx(ctx *MsgContext, editor AttrsRevisionEditor) {
+	for _, i := range ctx.attrsTouched.list() {
+		am.commitRevision(i, editor)
+	}
+}
+
+func (am *AttrsManager) commitRevision(i *AttributesItem, editor AttrsRevisionEditor) {
+	i.revisionLock.Lock()
+	defer i.revisionLock.Unlock()
+	if !i.revisionPending {
+		return
+	}
+	i.revisionPending = false
+	if !i.revisionTracked || am.db == nil {
+		return
+	}
+	data, err := ds.NewDictVal(i.valueMap).V().ToJSON()
+	if err != nil {
+		return
+	}
+	maxCount, maxDays := am.revisionLimits()
+	if maxCount <= 0 {
+		i.revisionData, i.revisionSheetType = data, i.SheetType
+		return
+	}
+
+	changes := attrsRevisionDiff(i.revisionData, i.valueMap)
+	if len(changes) == 0 && i.revisionSheetType == i.SheetType {
+		// 仅序列化顺序不同
+		i.revisionData = data
+		return
+	}
+
+	before := i.revisionData
+	if len(before) == 0 {
+		before, _ = ds.NewDictVal(&ds.ValueMap{}).V().ToJSON()
+	}
+	diff, _ := json.Marshal(changes)
+	now := time.Now()
+	rev := &model.AttrsRevision{
+		AttrsID:    i.ID,
+		EditorType: editor.Type,
+		EditorID:   editor.ID,
+		EditorName: editor.Name,
+		Source:     editor.Source,
+		SheetType:  i.revisionSheetType,
+		Data:       before,
+		Diff:       string(diff),
+		CreatedAt:  now.Unix(),
+	}
+	if err = service.AttrsRevisionAppend(am.db, rev); err != nil {
+		// 不更新基准数据，下次记录时会包含本次的改动
+		am.logger.Errorf("写入角色卡修改记录失败 id=%s: %v", i.ID, err)
+		return
+	}
+	i.revisionData, i.revisionSheetType = data, i.SheetType
+
+	var expireBefore int64
+	if maxDays > 0 {
+		expireBefore = now.AddDate(0, 0, -maxDays).Unix()
+	}
+	if err = service.AttrsRevisionTrim(am.db, i.ID, maxCount, expireBefore); err != nil {
+		am.logger.Errorf("清理角色卡修改记录失败 id=%s: %v", i.ID, err)
+	}
+}
+
+// attrsRevisionDiff 对比旧数据与当前数据，按属性名排序返回变化
+func attrsRevisionDiff(before []byte, current *ds.ValueMap) []model.AttrsRevisionChange {
+	old := map[string]*ds.VMValue{}
+	if len(before) > 0 {
+		if v, err := ds.VMValueFromJSON(before); err == nil {
+			if dd, ok := v.ReadDictData(); ok && dd.Dict != nil {
+				dd.Dict.Range(func(key string, value *ds.VMValue) bool {
+					old[key] = value
+					return true
+				})
+			}
+		}
+	}
+
+	var changes []model.AttrsRevisionChange
+	current.Range(func(key string, value *ds.VMValue) bool {
+		if strings.HasPrefix(key, "$") {
+			// 内部字段(模板版本、成长标记等)不单独构成修改
+			delete(old, key)
+			return true
+		}
+		after := value.ToString()
+		if prev, ok := old[key]; ok {
+			delete(old, key)
+			if prev.ToRepr() == value.ToRepr() {
+				return true
+			}
+			beforeText := prev.ToString()
+			changes = append(changes, model.AttrsRevisionChange{Key: key, Before: &beforeText, After: &after})
+			return true
+		}
+		changes = append(changes, model.AttrsRevisionChange{Key: key, After: &after})
+		return true
+	})
+	for key, prev := range old {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		beforeText := prev.ToString()
+		changes = append(changes, model.AttrsRevisionChange{Key: key, Before: &beforeText})
+	}
+	sort.Slice(changes, func(a, b int) bool { return changes[a].Key < changes[b].Key })
+	return changes
+}
+
+// RevisionList 按时间倒序列出卡片的修改记录
+func (am *AttrsManager) RevisionList(attrsID string, offset int, limit int) (int64, []*model.AttrsRevision, error) {
+	return service.AttrsRevisionList(am.db, attrsID, offset, limit)
+}
+
+// RevisionChanges 解析修改记录中的属性变化
+func RevisionChanges(rev *model.AttrsRevision) []model.AttrsRevisionChange {
+	var changes []model.AttrsRevisionChange
+	_ = json.Unmarshal([]byte(rev.Diff), &changes)
+	return changes
+}
+
+// RevisionRestore 将卡片恢复到指定记录所代表的修改之前，其后的修改一并撤销，返回被撤销的全部属性变化。
+// $ 开头的内部字段(物品栏、钱包、成长标记等)不计入修改记录，恢复时保留当前值。
+// 恢复操作本身也会记录，因此可以再次撤销
+func (am *AttrsManager) RevisionRestore(attrsID string, revisionID uint64, editor AttrsRevisionEditor) (*model.AttrsRevision, []model.AttrsRevisionChange, error) {
+	rev, err := service.AttrsRevisionGet(am.db, attrsID, revisionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := ds.VMValueFromJSON(rev.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	dd, ok := v.ReadDictData()
+	if !ok {
+		return nil, nil, ErrCharacterSheetFormat
+	}
+
+	i, err := am.LoadById(attrsID)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := ds.NewDictVal(i.valueMap).V().ToJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+	internal := map[string]*ds.VMValue{}
+	i.Range(func(key string, value *ds.VMValue) bool {
+		if strings.HasPrefix(key, "$") {
+			internal[key] = value
+		}
+		return true
+	})
+	i.Clear()
+	if dd.Dict != nil {
+		dd.Dict.Range(func(key string, value *ds.VMValue) bool {
+			if !strings.HasPrefix(key, "$") {
+				i.Store(key, value)
+			}
+			return true
+		})
+	}
+	for key, value := range internal {
+		i.Store(key, value)
+	}
+	i.SetSheetType(rev.SheetType)
+	changes := attrsRevisionDiff(current, i.valueMap)
+	am.commitRevision(i, editor)
+	i.SaveToDB(am.db)
+	return rev, changes, nil
+}
+
+// formatAttrsRevisionChanges 将属性变化格式化为一行摘要，最多列出 limit 项
+func formatAttrsRevisionChanges(changes []model.AttrsRevisionChange, limit int) string {
+	var parts []string
+	for idx, c := range changes {
+		if idx >= limit {
+			parts = append(parts, fmt.Sprintf("等%d项", len(changes)))
+			break
+		}
+		switch {
+		case c.Before == nil:
+			parts = append(parts, fmt.Sprintf("%s: +%s", c.Key, *c.After))
+		case c.After == nil:
+			parts = append(parts, fmt.Sprintf("%s: 删除(%s)", c.Key, *c.Before))
+		default:
+			parts = append(parts, fmt.Sprintf("%s: %s➯%s", c.Key, *c.Before, *c.After))
+		}
+	}
+	if len(parts) == 0 {
+		return "卡片类型变更"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,111 @@
+//nolint:testpackage
+package dice
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func TestAttrsRevisionHistoryAndUndo(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	migrateTestTables(t, d, &model.AttributesItemModel{}, &model.AttrsRevision{})
+	d.Config.AttrsRevisionMaxCount = 3
+
+	const groupID, userID = "QQ-Group:111", "QQ:999"
+	send := func(text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg(groupID, userID, text))
+	}
+	// 指令在后台执行，回复先于修改记录写入，需等新记录落库
+	lastRevision := func() (id uint64) {
+		d.DBOperator.GetDataDB(constant.READ).Model(&model.AttrsRevision{}).Select("COALESCE(MAX(id), 0)").Scan(&id)
+		return id
+	}
+	sendEdit := func(text string) string {
+		t.Helper()
+		before := lastRevision()
+		reply := send(text)
+		for deadline := time.Now().Add(2 * time.Second); lastRevision() <= before; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout: expected a revision for %q", text)
+			}
+		}
+		return reply
+	}
+	strength := func() string {
+		t.Helper()
+		attrs, err := d.AttrsManager.Load(groupID, userID)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		v, ok := attrs.LoadX("力量")
+		if !ok {
+			return ""
+		}
+		return v.ToString()
+	}
+
+	sendEdit(".st 力量50")
+	sendEdit(".st 力量60")
+	if got := send(".pc history"); !strings.Contains(got, "力量: 50➯60") || !strings.Contains(got, ".st 力量60") {
+		t.Fatalf(".pc history = %q", got)
+	}
+
+	sendEdit(".pc undo")
+	if got := strength(); got != "50" {
+		t.Fatalf("撤销后 力量 = %q, want 50", got)
+	}
+	// 撤销本身也是一次修改，再撤销即恢复
+	sendEdit(".pc undo")
+	if got := strength(); got != "60" {
+		t.Fatalf("撤销撤销后 力量 = %q, want 60", got)
+	}
+	// 内部字段不进修改记录，恢复时保留当前值
+	card, _ := d.AttrsManager.Load(groupID, userID)
+	card.Store("$wallet", ds.NewIntVal(30))
+	// 仅保留 3 条，最早的 .st 力量50 已被清理；第 3 条是 .st 力量60
+	if got := sendEdit(".pc undo 3"); !strings.Contains(got, "第3条修改(.st 力量60)之前") || !strings.Contains(got, "力量: 60➯50") {
+		t.Fatalf(".pc undo 3 = %q", got)
+	}
+	if got := strength(); got != "50" {
+		t.Fatalf("撤销第3条后 力量 = %q, want 50", got)
+	}
+	if v, ok := card.LoadX("$wallet"); !ok || v.ToString() != "30" {
+		t.Fatalf("恢复后 $wallet = %v", v)
+	}
+
+	attrs, _ := d.AttrsManager.Load(groupID, userID)
+	total, _, err := d.AttrsManager.RevisionList(attrs.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("RevisionList() error = %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("保留条数 = %d, want 3", total)
+	}
+	if got := send(".pc undo 9"); !strings.Contains(got, "没有找到第9条修改记录") {
+		t.Fatalf(".pc undo 9 = %q", got)
+	}
+
+	// 指令之外对别人卡片的改动不记在本条指令名下，由后台保存补记为 system
+	other, err := d.AttrsManager.Load("QQ-Group:222", "QQ:888")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	other.Store("敏捷", ds.NewIntVal(40))
+	sendEdit(".st 力量70")
+	if total, _, _ = d.AttrsManager.RevisionList(other.ID, 0, 10); total != 0 {
+		t.Fatalf("其他卡片的记录条数 = %d, want 0", total)
+	}
+	d.AttrsManager.CommitRevisions(AttrsRevisionEditor{Type: AttrsRevisionEditorSystem})
+	_, revs, _ := d.AttrsManager.RevisionList(other.ID, 0, 10)
+	if len(revs) != 1 || revs[0].EditorType != AttrsRevisionEditorSystem {
+		t.Fatalf("其他卡片的记录 = %#v", revs)
+	}
+}
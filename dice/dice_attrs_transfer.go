@@ -0,0 +1,410 @@
+package dice
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "github.com/sealdice/dicescript"
+	"gopkg.in/yaml.v3"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+// CharacterSheetFormat 角色卡导入导出格式
+type CharacterSheetFormat string
+
+const (
+	CharacterSheetJSON      CharacterSheetFormat = "json"
+	CharacterSheetYAML      CharacterSheetFormat = "yaml"
+	CharacterSheetCSV       CharacterSheetFormat = "csv"
+	CharacterSheetCOC7Excel CharacterSheetFormat = "coc7xlsx" // 常见的 COC7 Excel 人物卡
+	CharacterSheetDnD5eJSON CharacterSheetFormat = "dnd5e"    // Foundry VTT / D&D Beyond 导出的 JSON
+)
+
+// characterSheetVMKey 无法用数字或字符串表示的属性（计算值、数组、字典等）导出为 {"$vm": <dicescript JSON>}
+const characterSheetVMKey = "$vm"
+
+var (
+	ErrCharacterSheetFormat = errors.New("不支持的角色卡格式")
+	ErrCharacterSheetEmpty  = errors.New("角色卡中没有可导入的属性")
+	ErrCharacterExists      = errors.New("同名角色已存在")
+	ErrCharacterNameEmpty   = errors.New("角色名为空")
+	ErrCharacterNotFound    = errors.New("角色不存在")
+)
+
+// CharacterSheet 与存储无关的角色卡表示。
+// Attrs 的值为 int64、float64、string、{"$vm": ...}，导入器内部也可直接放入 *ds.VMValue
+type CharacterSheet struct {
+	Name      string         `json:"name"      yaml:"name"`
+	SheetType string         `json:"sheetType" yaml:"sheetType"`
+	Attrs     map[string]any `json:"attrs"     yaml:"attrs"`
+}
+
+// ParseCharacterSheetFormat 解析格式名，兼容常见写法
+func ParseCharacterSheetFormat(s string) (CharacterSheetFormat, bool) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), ".")) {
+	case "json":
+		return CharacterSheetJSON, true
+	case "yaml", "yml":
+		return CharacterSheetYAML, true
+	case "csv":
+		return CharacterSheetCSV, true
+	case "xlsx", "excel", "coc7xlsx":
+		return CharacterSheetCOC7Excel, true
+	case "dnd5e", "dnd", "foundry", "ddb":
+		return CharacterSheetDnD5eJSON, true
+	}
+	return "", false
+}
+
+// DetectCharacterSheetFormat 根据文件名和内容猜测格式，无法判断时按 YAML 处理（JSON 也是合法的 YAML）
+func DetectCharacterSheetFormat(filename string, data []byte) CharacterSheetFormat {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return CharacterSheetCOC7Excel
+	}
+	if f, ok := ParseCharacterSheetFormat(filepath.Ext(filename)); ok && f != CharacterSheetJSON {
+		return f
+	}
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		if isDnD5eExport(trimmed) {
+			return CharacterSheetDnD5eJSON
+		}
+		return CharacterSheetJSON
+	}
+	if firstLine, _, _ := bytes.Cut(trimmed, []byte("\n")); bytes.Equal(bytes.TrimSpace(firstLine), []byte("key,value,type")) {
+		return CharacterSheetCSV
+	}
+	return CharacterSheetYAML
+}
+
+// CharacterSheetFromAttrs 将角色卡数据转为可导出的形式
+func CharacterSheetFromAttrs(name string, sheetType string, attrs *AttributesItem) (*CharacterSheet, error) {
+	sheet := &CharacterSheet{Name: name, SheetType: sheetType, Attrs: map[string]any{}}
+	var err error
+	attrs.Range(func(key string, value *ds.VMValue) bool {
+		var v any
+		v, err = characterSheetValueFrom(value)
+		if err != nil {
+			err = fmt.Errorf("属性 %s: %w", key, err)
+			return false
+		}
+		sheet.Attrs[key] = v
+		return true
+	})
+	return sheet, err
+}
+
+func characterSheetValueFrom(v *ds.VMValue) (any, error) {
+	switch v.TypeId {
+	case ds.VMTypeInt:
+		n, _ := v.ReadInt()
+		return int64(n), nil
+	case ds.VMTypeFloat:
+		n, _ := v.ReadFloat()
+		return n, nil
+	case ds.VMTypeString:
+		s, _ := v.ReadString()
+		return s, nil
+	}
+	data, err := v.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	var raw any
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return map[string]any{characterSheetVMKey: raw}, nil
+}
+
+// characterSheetValueTo 将导入的值转为 VMValue，整数形式的浮点数按整数处理
+func characterSheetValueTo(v any) (*ds.VMValue, error) {
+	switch x := v.(type) {
+	case *ds.VMValue:
+		return x, nil
+	case int:
+		return ds.NewIntVal(ds.IntType(x)), nil
+	case int64:
+		return ds.NewIntVal(ds.IntType(x)), nil
+	case uint64:
+		return ds.NewIntVal(ds.IntType(x)), nil
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < math.MaxInt32 {
+			return ds.NewIntVal(ds.IntType(x)), nil
+		}
+		return ds.NewFloatVal(x), nil
+	case bool:
+		if x {
+			return ds.NewIntVal(1), nil
+		}
+		return ds.NewIntVal(0), nil
+	case string:
+		return ds.NewStrVal(x), nil
+	case map[string]any:
+		raw, ok := x[characterSheetVMKey]
+		if !ok {
+			return nil, errors.New("对象类型的值需要 $vm 字段")
+		}
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		return ds.VMValueFromJSON(data)
+	case nil:
+		return nil, errors.New("值为空")
+	}
+	return nil, fmt.Errorf("无法识别的值类型 %T", v)
+}
+
+// Encode 按指定格式输出角色卡，只支持 JSON、YAML、CSV
+func (s *CharacterSheet) Encode(format CharacterSheetFormat) ([]byte, error) {
+	switch format {
+	case CharacterSheetJSON:
+		return json.MarshalIndent(s, "", "  ")
+	case CharacterSheetYAML:
+		return yaml.Marshal(s)
+	case CharacterSheetCSV:
+		return s.encodeCSV()
+	}
+	return nil, ErrCharacterSheetFormat
+}
+
+// CSV 每行一个属性: key,value,type。角色名和卡片类型写在 #name / #sheetType 两行
+func (s *CharacterSheet) encodeCSV() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"key", "value", "type"})
+	_ = w.Write([]string{"#name", s.Name, "str"})
+	_ = w.Write([]string{"#sheetType", s.SheetType, "str"})
+
+	keys := make([]string, 0, len(s.Attrs))
+	for k := range s.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var value, typ string
+		switch v := s.Attrs[k].(type) {
+		case int64:
+			value, typ = strconv.FormatInt(v, 10), "int"
+		case float64:
+			value, typ = strconv.FormatFloat(v, 'f', -1, 64), "float"
+		case string:
+			value, typ = v, "str"
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			value, typ = string(data), "vm"
+		}
+		_ = w.Write([]string{k, value, typ})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// DecodeCharacterSheet 解析各格式的角色卡
+func DecodeCharacterSheet(data []byte, format CharacterSheetFormat) (*CharacterSheet, error) {
+	sheet := &CharacterSheet{}
+	var err error
+	switch format {
+	case CharacterSheetJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err = dec.Decode(sheet); err == nil {
+			sheet.Attrs = normalizeJSONNumbers(sheet.Attrs)
+		}
+	case CharacterSheetYAML:
+		err = yaml.Unmarshal(data, sheet)
+	case CharacterSheetCSV:
+		sheet, err = decodeCharacterSheetCSV(data)
+	case CharacterSheetCOC7Excel:
+		sheet, err = decodeCOC7Excel(data)
+	case CharacterSheetDnD5eJSON:
+		sheet, err = decodeDnD5eJSON(data)
+	default:
+		err = ErrCharacterSheetFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(sheet.Attrs) == 0 {
+		return nil, ErrCharacterSheetEmpty
+	}
+	return sheet, nil
+}
+
+func normalizeJSONNumbers(m map[string]any) map[string]any {
+	for k, v := range m {
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				m[k] = i
+			} else if f, err := n.Float64(); err == nil {
+				m[k] = f
+			}
+		}
+	}
+	return m
+}
+
+func decodeCharacterSheetCSV(data []byte) (*CharacterSheet, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	sheet := &CharacterSheet{Attrs: map[string]any{}}
+	for line := 0; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 || (line == 0 && record[0] == "key") {
+			continue
+		}
+		key, value := strings.TrimSpace(record[0]), record[1]
+		typ := ""
+		if len(record) > 2 {
+			typ = strings.TrimSpace(record[2])
+		}
+		switch key {
+		case "":
+			continue
+		case "#name":
+			sheet.Name = value
+			continue
+		case "#sheetType":
+			sheet.SheetType = value
+			continue
+		}
+
+		switch typ {
+		case "str":
+			sheet.Attrs[key] = value
+		case "vm":
+			var v any
+			if err = json.Unmarshal([]byte(value), &v); err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", line+1, err)
+			}
+			sheet.Attrs[key] = v
+		default:
+			// 未写类型时（例如手工编辑的表格），能解析为数字的按数字处理
+			if i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+				sheet.Attrs[key] = i
+			} else if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				sheet.Attrs[key] = f
+			} else {
+				sheet.Attrs[key] = value
+			}
+		}
+	}
+	return sheet, nil
+}
+
+// ToVMValues 转为可写入角色卡的属性，属性名经过模板别名归一化（如 str -> 力量）
+func (s *CharacterSheet) ToVMValues(tmpl *GameSystemTemplateV2) (map[string]*ds.VMValue, error) {
+	result := make(map[string]*ds.VMValue, len(s.Attrs))
+	for key, value := range s.Attrs {
+		v, err := characterSheetValueTo(value)
+		if err != nil {
+			return nil, fmt.Errorf("属性 %s: %w", key, err)
+		}
+		if !strings.HasPrefix(key, "$") {
+			key = tmpl.GetAlias(key)
+		}
+		result[key] = v
+	}
+	return result, nil
+}
+
+// CharExport 导出指定角色卡
+func (am *AttrsManager) CharExport(id string, format CharacterSheetFormat) ([]byte, *model.AttributesItemModel, error) {
+	item, err := am.charGetModel(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	attrs, err := am.LoadById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	sheet, err := CharacterSheetFromAttrs(attrs.Name, attrs.SheetType, attrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := sheet.Encode(format)
+	return data, item, err
+}
+
+// CharImport 将角色卡导入为用户的角色。name 为空时使用卡片中的名字，卡片未注明类型时使用 defaultSheetType；
+// 同名角色已存在时，overwrite 为 true 则覆盖其属性，否则返回 ErrCharacterExists
+func (am *AttrsManager) CharImport(d *Dice, userID string, name string, sheet *CharacterSheet, defaultSheetType string, overwrite bool) (*model.AttributesItemModel, error) {
+	if name == "" {
+		name = strings.TrimSpace(sheet.Name)
+	}
+	if name == "" {
+		return nil, ErrCharacterNameEmpty
+	}
+	sheetType := sheet.SheetType
+	if sheetType == "" {
+		sheetType = defaultSheetType
+	}
+
+	var tmpl *GameSystemTemplateV2
+	if d != nil && d.GameSystemMap != nil {
+		if t, ok := d.GameSystemMap.Load(sheetType); ok && t != nil {
+			tmpl = t.GameSystemTemplateV2
+		}
+	}
+	values, err := sheet.ToVMValues(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := am.CharIdGetByName(userID, name)
+	if id != "" && !overwrite {
+		return nil, ErrCharacterExists
+	}
+	if id == "" {
+		item, err := am.CharNew(userID, name, sheetType)
+		if err != nil {
+			return nil, err
+		}
+		id = item.Id
+	}
+
+	attrs, err := am.LoadById(id)
+	if err != nil {
+		return nil, err
+	}
+	attrs.Clear()
+	for k, v := range values {
+		attrs.Store(k, v)
+	}
+	attrs.Name = name
+	attrs.SetSheetType(sheetType)
+	attrs.LastModifiedTime = time.Now().Unix()
+	attrs.SaveToDB(am.db)
+	return am.charGetModel(id)
+}
+
+// charGetModel 读取角色卡的数据库记录，不是角色卡时返回 ErrCharacterNotFound
+func (am *AttrsManager) charGetModel(id string) (*model.AttributesItemModel, error) {
+	item, err := service.AttrsGetById(am.db, id)
+	if err != nil || item == nil || item.AttrsType != "character" {
+		return nil, ErrCharacterNotFound
+	}
+	return item, nil
+}
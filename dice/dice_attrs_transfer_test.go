@@ -0,0 +1,182 @@
+//nolint:testpackage
+package dice
+
+import (
+	"errors"
+	"testing"
+
+	ds "github.com/sealdice/dicescript"
+	"github.com/xuri/excelize/v2"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func TestCharacterSheetEncodeRoundTrip(t *testing.T) {
+	skill := newDnd5eSkillValue("敏捷", ds.NewIntVal(0), ds.NewIntVal(1))
+	attrs := &AttributesItem{valueMap: &ds.ValueMap{}}
+	attrs.Store("力量", ds.NewIntVal(60))
+	attrs.Store("hp", ds.NewFloatVal(10.5))
+	attrs.Store("备注", ds.NewStrVal("a,b\n\"c\""))
+	attrs.Store("体操", skill)
+
+	sheet, err := CharacterSheetFromAttrs("张三", "dnd5e", attrs)
+	if err != nil {
+		t.Fatalf("CharacterSheetFromAttrs() error = %v", err)
+	}
+	for _, format := range []CharacterSheetFormat{CharacterSheetJSON, CharacterSheetYAML, CharacterSheetCSV} {
+		data, err := sheet.Encode(format)
+		if err != nil {
+			t.Fatalf("%s: Encode() error = %v", format, err)
+		}
+		if got := DetectCharacterSheetFormat("", data); got != format {
+			t.Fatalf("%s: DetectCharacterSheetFormat() = %s", format, got)
+		}
+		decoded, err := DecodeCharacterSheet(data, format)
+		if err != nil {
+			t.Fatalf("%s: DecodeCharacterSheet() error = %v", format, err)
+		}
+		if decoded.Name != "张三" || decoded.SheetType != "dnd5e" {
+			t.Fatalf("%s: name/sheetType = %q/%q", format, decoded.Name, decoded.SheetType)
+		}
+		values, err := decoded.ToVMValues(nil)
+		if err != nil {
+			t.Fatalf("%s: ToVMValues() error = %v", format, err)
+		}
+		if v := values["力量"]; v == nil || v.TypeId != ds.VMTypeInt || v.ToString() != "60" {
+			t.Fatalf("%s: 力量 = %v", format, v)
+		}
+		if v := values["hp"]; v == nil || v.TypeId != ds.VMTypeFloat {
+			t.Fatalf("%s: hp = %v", format, v)
+		}
+		if v := values["备注"]; v == nil || v.ToString() != "a,b\n\"c\"" {
+			t.Fatalf("%s: 备注 = %v", format, v)
+		}
+		if v := values["体操"]; v == nil || v.TypeId != ds.VMTypeComputedValue || v.ToRepr() != skill.ToRepr() {
+			t.Fatalf("%s: 体操 = %v, want %s", format, v, skill.ToRepr())
+		}
+	}
+}
+
+func TestCharacterSheetImportExport(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	if err := d.DBOperator.GetDataDB(constant.WRITE).AutoMigrate(&model.AttributesItemModel{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	am := d.AttrsManager
+	userID := "QQ:1919810"
+
+	sheet, err := DecodeCharacterSheet([]byte("name: 李四\nsheetType: coc7\nattrs:\n  str: 50\n  侦察: 70\n"), CharacterSheetYAML)
+	if err != nil {
+		t.Fatalf("DecodeCharacterSheet() error = %v", err)
+	}
+	item, err := am.CharImport(d, userID, "", sheet, "", false)
+	if err != nil {
+		t.Fatalf("CharImport() error = %v", err)
+	}
+	attrs, err := am.LoadById(item.Id)
+	if err != nil {
+		t.Fatalf("LoadById() error = %v", err)
+	}
+	if v, ok := attrs.LoadX("力量"); !ok || v.ToString() != "50" {
+		t.Fatalf("别名未转换为主名: 力量 = %v", v)
+	}
+	if v, ok := attrs.LoadX("侦查"); !ok || v.ToString() != "70" {
+		t.Fatalf("别名未转换为主名: 侦查 = %v", v)
+	}
+
+	if _, err = am.CharImport(d, userID, "", sheet, "", false); !errors.Is(err, ErrCharacterExists) {
+		t.Fatalf("重复导入 error = %v, want ErrCharacterExists", err)
+	}
+	sheet.Attrs = map[string]any{"力量": int64(80)}
+	if _, err = am.CharImport(d, userID, "", sheet, "", true); err != nil {
+		t.Fatalf("覆盖导入 error = %v", err)
+	}
+
+	data, exported, err := am.CharExport(item.Id, CharacterSheetJSON)
+	if err != nil {
+		t.Fatalf("CharExport() error = %v", err)
+	}
+	if exported.Name != "李四" {
+		t.Fatalf("exported name = %q", exported.Name)
+	}
+	decoded, err := DecodeCharacterSheet(data, CharacterSheetJSON)
+	if err != nil {
+		t.Fatalf("DecodeCharacterSheet() error = %v", err)
+	}
+	if len(decoded.Attrs) != 1 || decoded.Attrs["力量"] != int64(80) {
+		t.Fatalf("覆盖后属性 = %v", decoded.Attrs)
+	}
+}
+
+func TestCharacterSheetDecodeDnD5eFoundry(t *testing.T) {
+	data := []byte(`{"name":"Elf","type":"character","system":{
+		"abilities":{"str":{"value":8},"dex":{"value":16,"proficient":1}},
+		"attributes":{"hp":{"value":7,"max":9},"ac":{"flat":14},"prof":2},
+		"skills":{"acr":{"value":1},"ath":{"value":0}}}}`)
+	if f := DetectCharacterSheetFormat("elf.json", data); f != CharacterSheetDnD5eJSON {
+		t.Fatalf("DetectCharacterSheetFormat() = %s", f)
+	}
+	sheet, err := DecodeCharacterSheet(data, CharacterSheetDnD5eJSON)
+	if err != nil {
+		t.Fatalf("DecodeCharacterSheet() error = %v", err)
+	}
+	if sheet.Name != "Elf" || sheet.SheetType != "dnd5e" {
+		t.Fatalf("name/sheetType = %q/%q", sheet.Name, sheet.SheetType)
+	}
+	want := map[string]any{"力量": int64(8), "敏捷": int64(16), stpFormat("敏捷"): int64(1), "hp": int64(7), "hpmax": int64(9), "ac": int64(14), "熟练": int64(2)}
+	for k, v := range want {
+		if sheet.Attrs[k] != v {
+			t.Fatalf("%s = %v, want %v", k, sheet.Attrs[k], v)
+		}
+	}
+	if _, ok := sheet.Attrs["运动"]; ok {
+		t.Fatalf("未熟练技能不应导入")
+	}
+	values, err := sheet.ToVMValues(nil)
+	if err != nil {
+		t.Fatalf("ToVMValues() error = %v", err)
+	}
+	if v := values["体操"]; v == nil || v.TypeId != ds.VMTypeComputedValue {
+		t.Fatalf("体操 = %v", v)
+	}
+}
+
+func TestCharacterSheetDecodeCOC7Excel(t *testing.T) {
+	f := excelize.NewFile()
+	rows := [][]any{
+		{"调查员姓名", "", "王五"},
+		{"力量 STR", 45, 22, 9},
+		{"侦查 Spot Hidden", 25, 40, 0, 65, 32, 13},
+		{"母语（汉语）", 80},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatalf("SetSheetRow() error = %v", err)
+		}
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("WriteToBuffer() error = %v", err)
+	}
+
+	data := buf.Bytes()
+	if format := DetectCharacterSheetFormat("", data); format != CharacterSheetCOC7Excel {
+		t.Fatalf("DetectCharacterSheetFormat() = %s", format)
+	}
+	sheet, err := DecodeCharacterSheet(data, CharacterSheetCOC7Excel)
+	if err != nil {
+		t.Fatalf("DecodeCharacterSheet() error = %v", err)
+	}
+	if sheet.Name != "王五" {
+		t.Fatalf("name = %q", sheet.Name)
+	}
+	want := map[string]any{"力量": int64(45), "侦查": int64(65), "母语": int64(80)}
+	for k, v := range want {
+		if sheet.Attrs[k] != v {
+			t.Fatalf("%s = %v, want %v (all: %v)", k, sheet.Attrs[k], v, sheet.Attrs)
+		}
+	}
+}
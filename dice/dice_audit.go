@@ -0,0 +1,115 @@
+package dice
+
+import (
+	"encoding/json"
+	"time"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+const (
+	AuditActorIM    = "im"
+	AuditActorWebUI = "webui"
+)
+
+// 审计操作类型。IM 指令以 cmd. 开头，WebUI 以 webui. 开头，查询时可用前缀筛选
+const (
+	AuditActionMasterAdd    = "cmd.master.add"
+	AuditActionMasterRemove = "cmd.master.del"
+	AuditActionBanAdd       = "cmd.ban.add"
+	AuditActionBanRemove    = "cmd.ban.del"
+	AuditActionBanTrust     = "cmd.ban.trust"
+	AuditActionBotOn        = "cmd.bot.on"
+	AuditActionBotOff       = "cmd.bot.off"
+	AuditActionBotQuit      = "cmd.bot.bye"
+	AuditActionExtSwitch    = "cmd.ext.switch"
+	AuditActionInvGive      = "cmd.inv.give"
+
+	AuditActionWebUIRequest        = "webui.request"
+	AuditActionWebUIConfigSet      = "webui.config.set"
+	AuditActionWebUIPackageInstall = "webui.package.install"
+	AuditActionWebUIJsUpload       = "webui.js.upload"
+	AuditActionWebUIReplySave      = "webui.reply.save"
+	AuditActionWebUIExtDocsImport  = "webui.extdocs.import"
+	AuditActionWebUIPackageTrust   = "webui.package.trust"
+)
+
+// AuditEntry 一条待写入的审计记录，Before/After 为任意可 JSON 序列化的值
+type AuditEntry struct {
+	ActorType string
+	ActorID   string
+	ActorName string
+	Action    string
+	Target    string
+	Before    any
+	After     any
+	Place     string
+}
+
+func auditMarshal(v any) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// AuditRecord 写入一条审计记录，失败只记日志不影响操作本身
+func (d *Dice) AuditRecord(entry AuditEntry) {
+	if d == nil || d.DBOperator == nil {
+		return
+	}
+	item := &model.AuditLog{
+		ActorType: entry.ActorType,
+		ActorID:   entry.ActorID,
+		ActorName: entry.ActorName,
+		Action:    entry.Action,
+		Target:    entry.Target,
+		Before:    auditMarshal(entry.Before),
+		After:     auditMarshal(entry.After),
+		Place:     entry.Place,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := service.AuditAppend(d.DBOperator, item); err != nil {
+		d.Logger.Errorf("写入审计记录失败 action=%s target=%s: %v", entry.Action, entry.Target, err)
+	}
+}
+
+// AuditRecordByCtx 以指令发送者的身份写入审计记录
+func (d *Dice) AuditRecordByCtx(ctx *MsgContext, action string, target string, before any, after any) {
+	entry := AuditEntry{
+		ActorType: AuditActorIM,
+		Action:    action,
+		Target:    target,
+		Before:    before,
+		After:     after,
+	}
+	if ctx != nil {
+		if ctx.Player != nil {
+			entry.ActorID = ctx.Player.UserID
+			entry.ActorName = ctx.Player.Name
+		}
+		if ctx.Group != nil {
+			entry.Place = ctx.Group.GroupID
+		}
+	}
+	d.AuditRecord(entry)
+}
+
+// auditBanSnapshot 黑名单条目在审计记录中的快照
+func auditBanSnapshot(item *BanListInfoItem) any {
+	if item == nil {
+		return nil
+	}
+	return map[string]any{
+		"rank":  item.Rank,
+		"score": item.Score,
+	}
+}
@@ -58,6 +58,7 @@ type backupConfigDice struct {
 	CustomReply bool `json:"customReply"` // 文案模板
 	CustomText  bool `json:"customText"`  // 自定义回复
 	JSScripts   bool `json:"jsScripts"`   // JS脚本
+	ExtDocs     bool `json:"extDocs"`     // 扩展结构化存储
 }
 
 type BackupSelection uint64
@@ -69,6 +70,7 @@ const (
 	BackupSelectionCensor
 	BackupSelectionNames
 	BackupSelectionImages
+	BackupSelectionExtDocs
 
 	BackupSelectionBasic     BackupSelection = 0
 	BackupSelectionResources BackupSelection = BackupSelectionImages
@@ -78,7 +80,8 @@ const (
 		BackupSelectionHelpDoc |
 		BackupSelectionCensor |
 		BackupSelectionNames |
-		BackupSelectionResources
+		BackupSelectionResources |
+		BackupSelectionExtDocs
 )
 
 func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error) {
@@ -224,6 +227,8 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 
 	withJS := sel&BackupSelectionJS != 0
 	cfgDice.JSScripts = withJS
+	withExtDocs := sel&BackupSelectionExtDocs != 0
+	cfgDice.ExtDocs = withExtDocs
 
 	for _, d := range dm.Dice {
 		cfgGlb.Dices[d.BaseConfig.Name] = &cfgDice
@@ -319,10 +324,18 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 					}
 					return nil
 				}
+				// 结构化存储由单独的选项备份
+				if info.Name() == extDocFileName {
+					return nil
+				}
 				backup(d, path)
 				return nil
 			})
 		}
+
+		if withExtDocs {
+			backupExtDocs(d, writer)
+		}
 	}
 
 	// 写入文件信息
@@ -422,3 +435,35 @@ func (dm *DiceManager) BackupClean(fromAuto bool) (err error) {
 	}
 	return nil
 }
+
+// backupExtDocs 备份各扩展的结构化存储，已打开的存储写出一致的快照，未打开的直接复制文件
+func backupExtDocs(d *Dice, writer *zip.Writer) {
+	if d.ExtDocs == nil {
+		return
+	}
+	for _, info := range d.ExtDocs.List() {
+		if info.Size == 0 {
+			continue
+		}
+		fn := filepath.Join(d.BaseConfig.DataDir, "extensions", info.Ext, extDocFileName)
+		h := &zip.FileHeader{Name: fn, Method: zip.Deflate, Flags: 0x800}
+		fileWriter, err := writer.CreateHeader(h)
+		if err != nil {
+			d.Logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
+			continue
+		}
+		saved, err := d.ExtDocs.snapshot(info.Ext, func(s *ExtDocStore) error {
+			return s.db.Save(fileWriter)
+		})
+		if err == nil && !saved {
+			var file *os.File
+			if file, err = os.Open(fn); err == nil {
+				_, err = io.Copy(fileWriter, file)
+				_ = file.Close()
+			}
+		}
+		if err != nil {
+			d.Logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
+		}
+	}
+}
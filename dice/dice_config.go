@@ -35,6 +35,8 @@ type Config struct {
 	JsConfig `yaml:",inline"`
 	// 跑团日志设置
 	StoryLogConfig `yaml:",inline"`
+	// 角色卡修改记录设置
+	AttrsRevisionConfig `yaml:",inline"`
 	// 邮件设置
 	MailConfig `yaml:",inline"`
 	// 新闻设置
@@ -217,6 +219,10 @@ type BanConfig struct {
 type JsConfig struct {
 	JsEnable          bool            `json:"jsEnable"          yaml:"jsEnable"`
 	DisabledJsScripts map[string]bool `json:"disabledJsScripts" yaml:"disabledJsScripts"` // 作为set
+
+	JsExecTimeout         int64 `json:"jsExecTimeout"         yaml:"jsExecTimeout"`         // 单次JS调用的执行时限(毫秒)，为 0 时不限制
+	JsTimeoutDisableCount int   `json:"jsTimeoutDisableCount" yaml:"jsTimeoutDisableCount"` // 超时达到此次数后自动禁用脚本，为 0 时不自动禁用
+	JsDocStoreQuota       int64 `json:"jsDocStoreQuota"       yaml:"jsDocStoreQuota"`       // 每个扩展结构化存储的容量上限(MB)，为 0 时不限制
 }
 
 type StoryLogConfig struct {
@@ -224,6 +230,11 @@ type StoryLogConfig struct {
 	LogSizeNoticeCount  int  `json:"logSizeNoticeCount"  yaml:"LogSizeNoticeCount"`  // 日志数量提示阈值，默认500
 }
 
+type AttrsRevisionConfig struct {
+	AttrsRevisionMaxCount int `json:"attrsRevisionMaxCount" yaml:"attrsRevisionMaxCount"` // 每张卡保留的修改记录条数，为 0 时不记录
+	AttrsRevisionMaxDays  int `json:"attrsRevisionMaxDays"  yaml:"attrsRevisionMaxDays"`  // 修改记录保留天数，为 0 时不按时间清理
+}
+
 type MailConfig struct {
 	MailEnable   bool   `json:"mailEnable"   yaml:"mailEnable"`   // 是否启用
 	MailFrom     string `json:"mailFrom"     yaml:"mailFrom"`     // 邮箱来源
@@ -264,4 +275,8 @@ type DirtyConfig struct {
 type StoreConfig struct {
 	BackendUrls         []string `json:"backendUrls" yaml:"backendUrls"`
 	DisabledBackendUrls []string `json:"disabledBackendUrls" yaml:"disabledBackendUrls"`
+
+	// 扩展包签名策略与受信任的发布者公钥
+	PackageSignPolicy  string              `json:"packageSignPolicy" yaml:"packageSignPolicy"`
+	PackageTrustedKeys []PackageTrustedKey `json:"packageTrustedKeys" yaml:"packageTrustedKeys"`
 }
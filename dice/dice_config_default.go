@@ -80,11 +80,19 @@ var DefaultConfig = Config{
 	JsConfig{
 		JsEnable:          true,
 		DisabledJsScripts: make(map[string]bool),
+
+		JsExecTimeout:         3000,
+		JsTimeoutDisableCount: 3,
+		JsDocStoreQuota:       64,
 	},
 	StoryLogConfig{
 		LogSizeNoticeEnable: true,
 		LogSizeNoticeCount:  500,
 	},
+	AttrsRevisionConfig{
+		AttrsRevisionMaxCount: 30,
+		AttrsRevisionMaxDays:  30,
+	},
 	MailConfig{
 		MailEnable:   false,
 		MailFrom:     "",
@@ -110,6 +118,8 @@ var DefaultConfig = Config{
 	StoreConfig{
 		BackendUrls:         []string{},
 		DisabledBackendUrls: []string{},
+		PackageSignPolicy:   PackageSignPolicyAllow,
+		PackageTrustedKeys:  []PackageTrustedKey{},
 	},
 	DirtyConfig{
 		DeckList: nil,
@@ -0,0 +1,609 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+	ds "github.com/sealdice/dicescript"
+)
+
+// 物品栏与货币
+//
+// 物品存放在角色卡的 $inv 中，格式为 {物品名: {qty, weight, note, equipped}}，货币存放在 $wallet 中，格式为 {面额: 数量}。
+// 每次修改后同步写入 $invWeight(总重量)、$invCount(物品总件数)、$walletTotal(以最小面额计的总价值)，
+// 以便模板和 dicescript 直接用于负重等公式，如 $invWeight > 力量 * 15。
+// 注意不能用 $m / $g / $t 开头，这些前缀在取值时另有含义。
+
+const (
+	invAttrKey        = "$inv"
+	invWeightKey      = "$invWeight"
+	invCountKey       = "$invCount"
+	walletAttrKey     = "$wallet"
+	walletTotalKey    = "$walletTotal"
+	invItemNameMaxLen = 90 // 字符数
+)
+
+var (
+	ErrInventoryItemNotFound   = errors.New("没有这件物品")
+	ErrInventoryNotEnough      = errors.New("物品数量不足")
+	ErrInventoryMoneyNotEnough = errors.New("钱不够")
+	ErrInventoryBadCurrency    = errors.New("未知的货币")
+)
+
+// defaultCurrency 模板没有定义货币时使用
+var defaultCurrency = []CurrencyConfig{{Key: "钱", Name: "钱", Aliases: []string{"元", "块"}, Value: 1}}
+
+var invMoneyRe = regexp.MustCompile(`^([+-]?)(\d+)\s*(\D.*)$`)
+
+// InventoryItem 物品栏中的一项
+type InventoryItem struct {
+	Name   string  `json:"name"`
+	Qty    int64   `json:"qty"`
+	Weight float64 `json:"weight"` // 单件重量
+	Note   string  `json:"note"`
+	// Equipped 是否已装备，装备中的物品在 $inv.物品名.equipped 中为1
+	Equipped bool `json:"equipped"`
+}
+
+// InventoryLoad 读取角色卡的物品栏，按名称排序
+func InventoryLoad(attrs *AttributesItem) []*InventoryItem {
+	var items []*InventoryItem
+	v, ok := attrs.LoadX(invAttrKey)
+	if !ok || v == nil {
+		return items
+	}
+	dict, ok := v.ReadDictData()
+	if !ok {
+		return items
+	}
+	dict.Dict.Range(func(key string, value *ds.VMValue) bool {
+		item := &InventoryItem{Name: key, Qty: 1}
+		if d, ok := value.ReadDictData(); ok {
+			if q, ok := d.Dict.Load("qty"); ok {
+				if n, ok := q.ReadInt(); ok {
+					item.Qty = int64(n)
+				}
+			}
+			if w, ok := d.Dict.Load("weight"); ok {
+				item.Weight = invReadNumber(w)
+			}
+			if n, ok := d.Dict.Load("note"); ok {
+				item.Note = n.ToString()
+			}
+			if e, ok := d.Dict.Load("equipped"); ok {
+				item.Equipped = e.AsBool()
+			}
+		}
+		items = append(items, item)
+		return true
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items
+}
+
+func invReadNumber(v *ds.VMValue) float64 {
+	if n, ok := v.ReadInt(); ok {
+		return float64(n)
+	}
+	if f, ok := v.ReadFloat(); ok {
+		return f
+	}
+	return 0
+}
+
+func invNumberVal(f float64) *ds.VMValue {
+	if f == float64(int64(f)) {
+		return ds.NewIntVal(ds.IntType(f))
+	}
+	return ds.NewFloatVal(f)
+}
+
+func invFormatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// inventorySave 写回物品栏并同步总重量与件数
+func inventorySave(attrs *AttributesItem, items []*InventoryItem) {
+	m := &ds.ValueMap{}
+	var weight float64
+	var count int64
+	for _, item := range items {
+		if item.Qty <= 0 {
+			continue
+		}
+		d := &ds.ValueMap{}
+		d.Store("qty", ds.NewIntVal(ds.IntType(item.Qty)))
+		d.Store("weight", invNumberVal(item.Weight))
+		if item.Note != "" {
+			d.Store("note", ds.NewStrVal(item.Note))
+		}
+		if item.Equipped {
+			d.Store("equipped", ds.NewIntVal(1))
+		}
+		m.Store(item.Name, ds.NewDictVal(d).V())
+		weight += item.Weight * float64(item.Qty)
+		count += item.Qty
+	}
+	attrs.Store(invAttrKey, ds.NewDictVal(m).V())
+	attrs.Store(invWeightKey, invNumberVal(weight))
+	attrs.Store(invCountKey, ds.NewIntVal(ds.IntType(count)))
+}
+
+func inventoryFind(items []*InventoryItem, name string) *InventoryItem {
+	for _, item := range items {
+		if strings.EqualFold(item.Name, name) {
+			return item
+		}
+	}
+	return nil
+}
+
+// InventoryAdd 放入物品，已有同名物品时叠加数量；weight<0 或 note 为空时保留原有的值
+func InventoryAdd(attrs *AttributesItem, name string, qty int64, weight float64, note string) *InventoryItem {
+	items := InventoryLoad(attrs)
+	item := inventoryFind(items, name)
+	if item == nil {
+		item = &InventoryItem{Name: name}
+		items = append(items, item)
+	}
+	item.Qty += qty
+	if weight >= 0 {
+		item.Weight = weight
+	}
+	if note != "" {
+		item.Note = note
+	}
+	inventorySave(attrs, items)
+	return item
+}
+
+// InventoryRemove 取出物品，qty<=0 时全部取出。返回取出的物品(数量为取出的数量)与剩余数量
+func InventoryRemove(attrs *AttributesItem, name string, qty int64) (*InventoryItem, int64, error) {
+	items := InventoryLoad(attrs)
+	item := inventoryFind(items, name)
+	if item == nil {
+		return nil, 0, ErrInventoryItemNotFound
+	}
+	if qty <= 0 {
+		qty = item.Qty
+	}
+	if item.Qty < qty {
+		return nil, item.Qty, ErrInventoryNotEnough
+	}
+	item.Qty -= qty
+	inventorySave(attrs, items)
+	taken := *item
+	taken.Qty = qty
+	return &taken, item.Qty, nil
+}
+
+// InventoryEquip 装备或卸下物品
+func InventoryEquip(attrs *AttributesItem, name string, equipped bool) (*InventoryItem, error) {
+	items := InventoryLoad(attrs)
+	item := inventoryFind(items, name)
+	if item == nil {
+		return nil, ErrInventoryItemNotFound
+	}
+	item.Equipped = equipped
+	inventorySave(attrs, items)
+	return item, nil
+}
+
+// InventoryWeight 物品总重量
+func InventoryWeight(items []*InventoryItem) float64 {
+	var weight float64
+	for _, item := range items {
+		weight += item.Weight * float64(item.Qty)
+	}
+	return weight
+}
+
+// inventoryCurrency 模板定义的货币，按价值从高到低
+func inventoryCurrency(tmpl *GameSystemTemplate) []CurrencyConfig {
+	var cur []CurrencyConfig
+	if tmpl != nil && tmpl.GameSystemTemplateV2 != nil {
+		cur = append(cur, tmpl.Inventory.Currency...)
+	}
+	if len(cur) == 0 {
+		cur = append(cur, defaultCurrency...)
+	}
+	for i := range cur {
+		cur[i].Value = max(cur[i].Value, 1)
+		if cur[i].Name == "" {
+			cur[i].Name = cur[i].Key
+		}
+	}
+	sort.SliceStable(cur, func(i, j int) bool { return cur[i].Value > cur[j].Value })
+	return cur
+}
+
+// currencyFind 按面额的key、名称或别名查找
+func currencyFind(cur []CurrencyConfig, name string) *CurrencyConfig {
+	name = strings.TrimSpace(name)
+	for i := range cur {
+		c := &cur[i]
+		if strings.EqualFold(c.Key, name) || c.Name == name || lo.Contains(c.Aliases, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// WalletLoad 读取钱包，只保留模板中定义的面额
+func WalletLoad(attrs *AttributesItem, cur []CurrencyConfig) map[string]int64 {
+	wallet := map[string]int64{}
+	v, ok := attrs.LoadX(walletAttrKey)
+	if !ok || v == nil {
+		return wallet
+	}
+	dict, ok := v.ReadDictData()
+	if !ok {
+		return wallet
+	}
+	for _, c := range cur {
+		if n, ok := dict.Dict.Load(c.Key); ok {
+			if i, ok := n.ReadInt(); ok {
+				wallet[c.Key] = int64(i)
+			}
+		}
+	}
+	return wallet
+}
+
+func walletTotal(wallet map[string]int64, cur []CurrencyConfig) int64 {
+	var total int64
+	for _, c := range cur {
+		total += wallet[c.Key] * c.Value
+	}
+	return total
+}
+
+func walletSave(attrs *AttributesItem, wallet map[string]int64, cur []CurrencyConfig) {
+	m := &ds.ValueMap{}
+	for _, c := range cur {
+		if wallet[c.Key] != 0 {
+			m.Store(c.Key, ds.NewIntVal(ds.IntType(wallet[c.Key])))
+		}
+	}
+	attrs.Store(walletAttrKey, ds.NewDictVal(m).V())
+	attrs.Store(walletTotalKey, ds.NewIntVal(ds.IntType(walletTotal(wallet, cur))))
+}
+
+// WalletAdjust 增减某种面额的货币。该面额不足但总价值足够时自动找零，钱包按面额从大到小重新整理
+func WalletAdjust(attrs *AttributesItem, cur []CurrencyConfig, key string, delta int64) error {
+	c := currencyFind(cur, key)
+	if c == nil {
+		return ErrInventoryBadCurrency
+	}
+	wallet := WalletLoad(attrs, cur)
+	if delta >= 0 || wallet[c.Key] >= -delta {
+		wallet[c.Key] += delta
+		walletSave(attrs, wallet, cur)
+		return nil
+	}
+	rest := walletTotal(wallet, cur) + delta*c.Value
+	if rest < 0 {
+		return ErrInventoryMoneyNotEnough
+	}
+	for _, d := range cur {
+		wallet[d.Key] = rest / d.Value
+		rest %= d.Value
+	}
+	walletSave(attrs, wallet, cur)
+	return nil
+}
+
+// WalletSet 直接设置某种面额的数量
+func WalletSet(attrs *AttributesItem, cur []CurrencyConfig, key string, amount int64) error {
+	c := currencyFind(cur, key)
+	if c == nil {
+		return ErrInventoryBadCurrency
+	}
+	wallet := WalletLoad(attrs, cur)
+	wallet[c.Key] = amount
+	walletSave(attrs, wallet, cur)
+	return nil
+}
+
+// walletText 钱包展示文本，如 "3金币 5银币(合计350铜币)"
+func walletText(wallet map[string]int64, cur []CurrencyConfig) string {
+	var parts []string
+	for _, c := range cur {
+		if wallet[c.Key] != 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", wallet[c.Key], c.Name))
+		}
+	}
+	if len(parts) == 0 {
+		return "身无分文"
+	}
+	text := strings.Join(parts, " ")
+	if smallest := cur[len(cur)-1]; len(parts) > 1 && smallest.Value == 1 {
+		text += fmt.Sprintf("(合计%d%s)", walletTotal(wallet, cur), smallest.Name)
+	}
+	return text
+}
+
+// parseMoneyAmount 解析 +10gp / -3银币 / 5金币 这样的金额，没有符号时视为增加
+func parseMoneyAmount(cur []CurrencyConfig, text string) (*CurrencyConfig, int64, bool) {
+	m := invMoneyRe.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		return nil, 0, false
+	}
+	c := currencyFind(cur, m[3])
+	if c == nil {
+		return nil, 0, false
+	}
+	n, err := strconv.ParseInt(m[2], 10, 64)
+	if err != nil {
+		return nil, 0, false
+	}
+	if m[1] == "-" {
+		n = -n
+	}
+	return c, n, true
+}
+
+// inventoryCapacityText 负重描述，模板定义了负重上限时附带上限与超重提示
+func inventoryCapacityText(ctx *MsgContext, tmpl *GameSystemTemplate, weight float64) string {
+	unit := ""
+	capacity := ""
+	if tmpl != nil && tmpl.GameSystemTemplateV2 != nil {
+		unit = tmpl.Inventory.WeightUnit
+		capacity = tmpl.Inventory.Capacity
+	}
+	text := "负重" + invFormatNumber(weight)
+	if capacity != "" {
+		r := ctx.Eval(capacity, nil)
+		if r.vm.Error == nil {
+			limit := invReadNumber(&r.VMValue)
+			text += "/" + invFormatNumber(limit)
+			if weight > limit {
+				return text + unit + "(超重)"
+			}
+		}
+	}
+	return text + unit
+}
+
+// InventoryText 物品栏与钱包的展示文本
+func InventoryText(ctx *MsgContext, attrs *AttributesItem, tmpl *GameSystemTemplate) string {
+	items := InventoryLoad(attrs)
+	cur := inventoryCurrency(tmpl)
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%s>的物品栏:", ctx.Player.Name)
+	if len(items) == 0 {
+		b.WriteString("\n空空如也")
+	}
+	for idx, item := range items {
+		fmt.Fprintf(&b, "\n%d. %s x%d", idx+1, item.Name, item.Qty)
+		if item.Weight > 0 {
+			fmt.Fprintf(&b, " (%s)", invFormatNumber(item.Weight*float64(item.Qty)))
+		}
+		if item.Equipped {
+			b.WriteString(" [已装备]")
+		}
+		if item.Note != "" {
+			b.WriteString(" // " + item.Note)
+		}
+	}
+	fmt.Fprintf(&b, "\n%s\n钱包: %s", inventoryCapacityText(ctx, tmpl, InventoryWeight(items)), walletText(WalletLoad(attrs, cur), cur))
+	return b.String()
+}
+
+// invParseQty 解析数量参数，为空时返回 def
+func invParseQty(text string, def int64) (int64, bool) {
+	if text == "" {
+		return def, true
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func getCmdInventory() *CmdItemInfo {
+	helpInv := ".inv // 查看物品栏与钱包\n" +
+		".inv add <物品> [<数量>] [<单件重量>] [<备注>] // 放入物品\n" +
+		".inv rm <物品> [<数量>] // 丢弃物品，不写数量则全部丢弃\n" +
+		".inv use <物品> [<数量>] // 使用(消耗)物品，默认1件\n" +
+		".inv equip/unequip <物品> // 装备或卸下物品\n" +
+		".inv give @某人 <物品> [<数量>] // 将物品交给他人当前的角色\n" +
+		".inv give @某人 <金额><货币> // 付钱，如 .inv give @某人 10gp\n" +
+		".inv money [+/-<金额><货币>...] // 查看或增减钱包，如 .inv money +10gp -5sp\n" +
+		".inv money set <金额><货币> // 直接设置某种货币的数量\n" +
+		"货币面额由当前规则的模板定义；表达式中可以使用 $invWeight(总重量) $invCount(总件数) $walletTotal(钱包总价值) $inv.物品名.qty $inv.物品名.equipped"
+
+	return &CmdItemInfo{
+		Name:          "inv",
+		ShortHelp:     helpInv,
+		Help:          "物品栏:\n" + helpInv,
+		AllowDelegate: true,
+		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
+			cmdArgs.ChopPrefixToArgsWith("add", "rm", "del", "use", "unequip", "equip", "give", "money", "list", "show")
+			if !npcApplyMentions(ctx, msg, cmdArgs) {
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+			am := ctx.Dice.AttrsManager
+			var tmpl *GameSystemTemplate
+			if ctx.Group != nil {
+				tmpl = ctx.Group.GetCharTemplate(ctx.Dice)
+			}
+			cur := inventoryCurrency(tmpl)
+			reply := func(text string) CmdExecuteResult {
+				ReplyToSender(ctx, msg, text)
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+
+			sub := cmdArgs.GetArgN(1)
+			if sub == "give" {
+				mctx := GetCtxProxyFirst(ctx, cmdArgs)
+				if mctx == ctx || mctx.Player.UserID == ctx.Player.UserID {
+					return reply("请@要交给的人")
+				}
+				from := lo.Must(am.LoadByCtx(ctx))
+				to := lo.Must(am.LoadByCtx(mctx))
+				if from.ID == to.ID {
+					return reply("对方与你使用的是同一张角色卡")
+				}
+				what := cmdArgs.GetArgN(2)
+				if what == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+
+				if c, amount, ok := parseMoneyAmount(cur, what); ok && amount > 0 && cmdArgs.GetArgN(3) == "" {
+					if err := WalletAdjust(from, cur, c.Key, -amount); err != nil {
+						return reply(fmt.Sprintf("<%s>%s，现有%s", ctx.Player.Name, err.Error(), walletText(WalletLoad(from, cur), cur)))
+					}
+					lo.Must0(WalletAdjust(to, cur, c.Key, amount))
+					ctx.Dice.AuditRecordByCtx(ctx, AuditActionInvGive, mctx.Player.UserID, nil, map[string]any{
+						"from": ctx.Player.Name, "to": mctx.Player.Name, "currency": c.Key, "amount": amount,
+					})
+					return reply(fmt.Sprintf("<%s>付给<%s> %d%s", ctx.Player.Name, mctx.Player.Name, amount, c.Name))
+				}
+
+				qty, ok := invParseQty(cmdArgs.GetArgN(3), 1)
+				if !ok {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				item, left, err := InventoryRemove(from, what, qty)
+				if err != nil {
+					if errors.Is(err, ErrInventoryNotEnough) {
+						return reply(fmt.Sprintf("<%s>的%s只有%d件", ctx.Player.Name, what, left))
+					}
+					return reply(fmt.Sprintf("<%s>%s: %s", ctx.Player.Name, err.Error(), what))
+				}
+				InventoryAdd(to, item.Name, item.Qty, item.Weight, item.Note)
+				ctx.Dice.AuditRecordByCtx(ctx, AuditActionInvGive, mctx.Player.UserID, nil, map[string]any{
+					"from": ctx.Player.Name, "to": mctx.Player.Name, "item": item.Name, "qty": item.Qty,
+				})
+				ctx.Dice.Logger.Infof("物品转交: 群=%s %s -> %s %s x%d", ctx.Group.GroupID, ctx.Player.UserID, mctx.Player.UserID, item.Name, item.Qty)
+				return reply(fmt.Sprintf("<%s>将%s x%d交给了<%s>", ctx.Player.Name, item.Name, item.Qty, mctx.Player.Name))
+			}
+
+			mctx := GetCtxProxyFirst(ctx, cmdArgs)
+			attrs := lo.Must(am.LoadByCtx(mctx))
+			switch sub {
+			case "", "list", "show":
+				return reply(InventoryText(mctx, attrs, tmpl))
+
+			case "add":
+				name := cmdArgs.GetArgN(2)
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				if utf8.RuneCountInString(name) > invItemNameMaxLen {
+					return reply("物品名过长")
+				}
+				qty, ok := invParseQty(cmdArgs.GetArgN(3), 1)
+				if !ok {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				weight := -1.0
+				noteFrom := 4
+				if w := cmdArgs.GetArgN(4); w != "" {
+					if f, err := strconv.ParseFloat(w, 64); err == nil && f >= 0 {
+						weight = f
+						noteFrom = 5
+					}
+				}
+				note := ""
+				if len(cmdArgs.Args) >= noteFrom {
+					note = strings.Join(cmdArgs.Args[noteFrom-1:], " ")
+				}
+				item := InventoryAdd(attrs, name, qty, weight, note)
+				return reply(fmt.Sprintf("<%s>获得了%s x%d，现有%d件", mctx.Player.Name, item.Name, qty, item.Qty))
+
+			case "rm", "del", "use":
+				name := cmdArgs.GetArgN(2)
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				def := int64(0)
+				if sub == "use" {
+					def = 1
+				}
+				qty, ok := invParseQty(cmdArgs.GetArgN(3), def)
+				if !ok {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				item, left, err := InventoryRemove(attrs, name, qty)
+				if err != nil {
+					if errors.Is(err, ErrInventoryNotEnough) {
+						return reply(fmt.Sprintf("<%s>的%s只有%d件", mctx.Player.Name, name, left))
+					}
+					return reply(fmt.Sprintf("<%s>%s: %s", mctx.Player.Name, err.Error(), name))
+				}
+				verb := "丢弃了"
+				if sub == "use" {
+					verb = "使用了"
+				}
+				text := fmt.Sprintf("<%s>%s%s x%d，剩余%d件", mctx.Player.Name, verb, item.Name, item.Qty, left)
+				if sub == "use" && item.Note != "" {
+					text += "\n" + item.Note
+				}
+				return reply(text)
+
+			case "equip", "unequip":
+				name := cmdArgs.GetArgN(2)
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				item, err := InventoryEquip(attrs, name, sub == "equip")
+				if err != nil {
+					return reply(fmt.Sprintf("<%s>%s: %s", mctx.Player.Name, err.Error(), name))
+				}
+				if item.Equipped {
+					return reply(fmt.Sprintf("<%s>装备了%s", mctx.Player.Name, item.Name))
+				}
+				return reply(fmt.Sprintf("<%s>卸下了%s", mctx.Player.Name, item.Name))
+
+			case "money":
+				args := cmdArgs.Args[1:]
+				if len(args) == 0 {
+					return reply(fmt.Sprintf("<%s>的钱包: %s", mctx.Player.Name, walletText(WalletLoad(attrs, cur), cur)))
+				}
+				if args[0] == "set" {
+					if len(args) != 2 {
+						return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+					}
+					c, amount, ok := parseMoneyAmount(cur, args[1])
+					if !ok || amount < 0 {
+						return reply("无法识别的金额: " + args[1])
+					}
+					lo.Must0(WalletSet(attrs, cur, c.Key, amount))
+					return reply(fmt.Sprintf("<%s>的钱包: %s", mctx.Player.Name, walletText(WalletLoad(attrs, cur), cur)))
+				}
+				type change struct {
+					c      *CurrencyConfig
+					amount int64
+				}
+				var changes []change
+				for _, a := range args {
+					c, amount, ok := parseMoneyAmount(cur, a)
+					if !ok {
+						return reply("无法识别的金额: " + a)
+					}
+					changes = append(changes, change{c, amount})
+				}
+				// 先加后减，避免顺序导致的找零失败
+				sort.SliceStable(changes, func(i, j int) bool { return changes[i].amount > changes[j].amount })
+				before := WalletLoad(attrs, cur)
+				for _, ch := range changes {
+					if err := WalletAdjust(attrs, cur, ch.c.Key, ch.amount); err != nil {
+						walletSave(attrs, before, cur)
+						return reply(fmt.Sprintf("<%s>%s，现有%s", mctx.Player.Name, err.Error(), walletText(before, cur)))
+					}
+				}
+				return reply(fmt.Sprintf("<%s>的钱包: %s", mctx.Player.Name, walletText(WalletLoad(attrs, cur), cur)))
+			}
+			return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+		},
+	}
+}
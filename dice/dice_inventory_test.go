@@ -0,0 +1,176 @@
+//nolint:testpackage
+package dice
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/message"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func TestInventoryAddRemove(t *testing.T) {
+	attrs := &AttributesItem{valueMap: &ds.ValueMap{}}
+
+	InventoryAdd(attrs, "绳索", 1, 10, "50尺麻绳")
+	item := InventoryAdd(attrs, "绳索", 2, -1, "")
+	if item.Qty != 3 || item.Weight != 10 || item.Note != "50尺麻绳" {
+		t.Fatalf("InventoryAdd() = %+v", item)
+	}
+	InventoryAdd(attrs, "火把", 5, 0.5, "")
+	if w := attrs.Load(invWeightKey); w == nil || w.ToString() != "32.5" {
+		t.Fatalf("$invWeight = %v", w)
+	}
+	if c := attrs.Load(invCountKey); c == nil || c.ToString() != "8" {
+		t.Fatalf("$invCount = %v", c)
+	}
+
+	if _, left, err := InventoryRemove(attrs, "火把", 6); !errors.Is(err, ErrInventoryNotEnough) || left != 5 {
+		t.Fatalf("InventoryRemove() over = %d, %v", left, err)
+	}
+	taken, left, err := InventoryRemove(attrs, "火把", 0)
+	if err != nil || taken.Qty != 5 || left != 0 {
+		t.Fatalf("InventoryRemove() all = %+v, %d, %v", taken, left, err)
+	}
+	if items := InventoryLoad(attrs); len(items) != 1 || items[0].Name != "绳索" {
+		t.Fatalf("InventoryLoad() = %+v", items)
+	}
+	if _, _, err = InventoryRemove(attrs, "火把", 1); !errors.Is(err, ErrInventoryItemNotFound) {
+		t.Fatalf("InventoryRemove() missing error = %v", err)
+	}
+}
+
+func TestWalletAdjustMakesChange(t *testing.T) {
+	tmpl, err := loadBuiltinTemplate("dnd5e.yaml")
+	if err != nil {
+		t.Fatalf("loadBuiltinTemplate: %v", err)
+	}
+	cur := inventoryCurrency(tmpl)
+	if len(cur) != 5 || cur[0].Key != "pp" || cur[4].Key != "cp" {
+		t.Fatalf("currency = %+v", cur)
+	}
+	attrs := &AttributesItem{valueMap: &ds.ValueMap{}}
+
+	c, n, ok := parseMoneyAmount(cur, "+3金币")
+	if !ok || c.Key != "gp" || n != 3 {
+		t.Fatalf("parseMoneyAmount() = %v, %d, %v", c, n, ok)
+	}
+	if _, _, ok = parseMoneyAmount(cur, "3号钥匙"); ok {
+		t.Fatal("parseMoneyAmount() should reject item names")
+	}
+	if err = WalletAdjust(attrs, cur, "gp", 3); err != nil {
+		t.Fatalf("WalletAdjust() error = %v", err)
+	}
+	// 没有银币，用金币找零
+	if err = WalletAdjust(attrs, cur, "sp", -5); err != nil {
+		t.Fatalf("WalletAdjust() change error = %v", err)
+	}
+	wallet := WalletLoad(attrs, cur)
+	if wallet["gp"] != 2 || wallet["ep"] != 1 || wallet["sp"] != 0 {
+		t.Fatalf("wallet = %v", wallet)
+	}
+	if got := walletText(wallet, cur); got != "2金币 1琥珀金币(合计250铜币)" {
+		t.Fatalf("walletText() = %q", got)
+	}
+	if err = WalletAdjust(attrs, cur, "pp", -1); !errors.Is(err, ErrInventoryMoneyNotEnough) {
+		t.Fatalf("WalletAdjust() overdraw error = %v", err)
+	}
+	if total := attrs.Load(walletTotalKey); total == nil || total.ToString() != "250" {
+		t.Fatalf("$walletTotal = %v", total)
+	}
+}
+
+func TestInventoryCommands(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	migrateTestTables(t, d, &model.AttributesItemModel{}, &model.AuditLog{})
+
+	const groupID, aliceID, bobID = "QQ-Group:777", "QQ:300", "QQ:400"
+	send := func(userID, text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg(groupID, userID, text))
+	}
+	// 给 bob 的指令，带上@
+	give := func(args string) string {
+		t.Helper()
+		msg := newGroupMsg(groupID, aliceID, ".inv give [CQ:at,qq=400] "+args)
+		msg.Segment = []message.IMessageElement{
+			&message.TextElement{Content: ".inv give "},
+			&message.AtElement{Target: "400"},
+			&message.TextElement{Content: " " + args},
+		}
+		return execTestMsg(t, d, ep, adapter, msg)
+	}
+
+	if got := send(aliceID, ".inv add 药水 3 0.5 回复2d4+2点生命"); !strings.Contains(got, "药水 x3") {
+		t.Fatalf(".inv add = %q", got)
+	}
+	if got := send(aliceID, ".inv use 药水"); !strings.Contains(got, "剩余2件") || !strings.Contains(got, "回复2d4+2点生命") {
+		t.Fatalf(".inv use = %q", got)
+	}
+	if got := send(aliceID, ".r $invCount + $inv.药水.qty"); !strings.Contains(got, "=4") {
+		t.Fatalf(".r $invCount = %q", got)
+	}
+	if got := send(aliceID, ".inv add 长剑 1 3"); !strings.Contains(got, "长剑 x1") {
+		t.Fatalf(".inv add 长剑 = %q", got)
+	}
+	if got := send(aliceID, ".inv equip 长剑"); !strings.Contains(got, "装备了长剑") {
+		t.Fatalf(".inv equip = %q", got)
+	}
+	if got := send(aliceID, ".inv"); !strings.Contains(got, "长剑 x1 (3) [已装备]") {
+		t.Fatalf(".inv 装备后 = %q", got)
+	}
+	if got := send(aliceID, ".r $invCount * 0 + $inv.长剑.equipped"); !strings.Contains(got, "=1") {
+		t.Fatalf(".r equipped = %q", got)
+	}
+	if got := send(aliceID, ".inv unequip 长剑"); !strings.Contains(got, "卸下了长剑") {
+		t.Fatalf(".inv unequip = %q", got)
+	}
+	if got := send(aliceID, ".inv equip 盾牌"); !strings.Contains(got, "没有这件物品") {
+		t.Fatalf(".inv equip 不存在 = %q", got)
+	}
+	if got := send(aliceID, ".inv add "+strings.Repeat("剑", invItemNameMaxLen)); !strings.Contains(got, "获得了") {
+		t.Fatalf(".inv add 长名称 = %q", got)
+	}
+	if got := send(aliceID, ".inv add "+strings.Repeat("剑", invItemNameMaxLen+1)); !strings.Contains(got, "物品名过长") {
+		t.Fatalf(".inv add 超长名称 = %q", got)
+	}
+	if got := send(aliceID, ".inv add 长剑 1 3"); !strings.Contains(got, "现有2件") {
+		t.Fatalf(".inv add 叠加 = %q", got)
+	}
+	send(aliceID, ".inv rm 长剑")
+	send(aliceID, ".inv rm "+strings.Repeat("剑", invItemNameMaxLen))
+
+	if got := give("药水 5"); !strings.Contains(got, "只有2件") {
+		t.Fatalf(".inv give 超量 = %q", got)
+	}
+	if got := give("药水"); !strings.Contains(got, "交给了") {
+		t.Fatalf(".inv give = %q", got)
+	}
+	if got := send(bobID, ".inv"); !strings.Contains(got, "药水 x1") {
+		t.Fatalf("bob .inv = %q", got)
+	}
+
+	if got := send(aliceID, ".inv money +20钱"); !strings.Contains(got, "20钱") {
+		t.Fatalf(".inv money = %q", got)
+	}
+	if got := give("30钱"); !strings.Contains(got, "钱不够") {
+		t.Fatalf(".inv give 钱不够 = %q", got)
+	}
+	if got := give("15钱"); !strings.Contains(got, "付给") {
+		t.Fatalf(".inv give 付钱 = %q", got)
+	}
+	if got := send(bobID, ".inv money"); !strings.Contains(got, "15钱") {
+		t.Fatalf("bob .inv money = %q", got)
+	}
+
+	var count int64
+	d.DBOperator.GetDataDB(constant.READ).Model(&model.AuditLog{}).Where("action = ?", AuditActionInvGive).Count(&count)
+	if count != 2 {
+		t.Fatalf("audit records = %d, want 2", count)
+	}
+}
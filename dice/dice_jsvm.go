@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
@@ -12,6 +13,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -42,6 +44,9 @@ var (
 	OfficialModPublicKey = ``
 
 	signRe = regexp.MustCompile(`^// sign\s+([^\r\n]+)?[\r\n]+$`)
+
+	ErrJsNotEnabled     = errors.New("JS扩展支持未开启")
+	ErrJsScriptNotFound = errors.New("找不到该脚本")
 )
 
 var taskTimeRe = regexp.MustCompile(`^([0-1]?[0-9]|2[0-3]):([0-5][0-9])$`)
@@ -84,6 +89,19 @@ func (d *Dice) JsInit() {
 	if d.ExtLoopManager == nil {
 		d.ExtLoopManager = NewJsLoopManager()
 	}
+	// 执行统计跨重载保留
+	if d.JsWatchdog == nil {
+		d.JsWatchdog = NewJsWatchdog()
+	}
+	if d.IPC == nil {
+		d.IPC = NewExtIPC(d)
+	}
+	if d.JsHTTPRouter == nil {
+		d.JsHTTPRouter = NewJsHTTPRouter()
+	}
+	if d.ExtDocs == nil {
+		d.ExtDocs = NewExtDocManager(d)
+	}
 	// 清理目前的js相关
 	d.jsClear()
 
@@ -95,6 +113,9 @@ func (d *Dice) JsInit() {
 		eventloop.WithDebugLog(true),
 		eventloop.WithLogger(d.Logger))
 	_ = fetch.Enable(loop, goproxy.NewProxyHttpServer())
+	loop.RunOnLoop(func(vm *goja.Runtime) {
+		d.jsGuardFetch(vm, loop)
+	})
 	versionID := d.ExtLoopManager.SetLoop(loop)
 
 	printer := &PrinterFunc{d, false, []string{}}
@@ -114,6 +135,8 @@ func (d *Dice) JsInit() {
 
 		// console 模块
 		console.Enable(vm)
+		// 定时器回调同样受看门狗限制
+		d.jsGuardTimers(vm, loop)
 
 		sealws.Enable(vm, loop)
 		// require 模块
@@ -421,7 +444,11 @@ func (d *Dice) JsInit() {
 				panic(errors.New("插件cron未成功初始化")) // 按理是不会发生的
 			}
 
-			task := JsScriptTask{cron: scriptCron, key: key, task: fn, lock: ei.dice.JsScriptCronLock, logger: ei.dice.Logger}
+			owner := ei.GetRealExt()
+			if owner == nil {
+				owner = ei
+			}
+			task := JsScriptTask{cron: scriptCron, key: key, task: fn, lock: ei.dice.JsScriptCronLock, logger: ei.dice.Logger, ext: owner}
 			expr := value
 			if key != "" {
 				if config := d.ConfigManager.getConfig(ei.Name, key); config != nil {
@@ -518,7 +545,13 @@ func (d *Dice) JsInit() {
 			return &CocRuleCheckRet{}
 		})
 		_ = coc.Set("registerRule", func(rule *CocRuleInfo) bool {
-			return d.CocExtraRulesAdd(rule)
+			if !d.CocExtraRulesAdd(rule) {
+				return false
+			}
+			if jsInfo := d.jsCurrentScript(vm); jsInfo != nil {
+				jsInfo.cocRules = append(jsInfo.cocRules, rule.Index)
+			}
+			return true
 		})
 		_ = seal.Set("coc", coc)
 
@@ -540,6 +573,62 @@ func (d *Dice) JsInit() {
 		})
 		_ = seal.Set("deck", deck)
 
+		rollTable := vm.NewObject()
+		_ = rollTable.Set("roll", func(ctx *MsgContext, name string) map[string]interface{} {
+			t := d.RollTableFind(name)
+			if t == nil {
+				return map[string]interface{}{"exists": false, "err": "", "result": nil}
+			}
+			ret, err := RollTableRoll(ctx, t)
+			var errText string
+			if err != nil {
+				errText = err.Error()
+			}
+			return map[string]interface{}{"exists": true, "err": errText, "result": ret}
+		})
+		_ = rollTable.Set("find", d.RollTableFind)
+		_ = rollTable.Set("search", d.RollTableSearch)
+		_ = rollTable.Set("list", func() []string {
+			tables := d.RollTables()
+			names := make([]string, 0, len(tables))
+			for _, t := range tables {
+				names = append(names, t.Name)
+			}
+			return names
+		})
+		// 注册一个随机表，格式与 json 随机表文件相同
+		_ = rollTable.Set("register", func(data map[string]interface{}) error {
+			content, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			tables, err := parseRollTableJSON("", content)
+			if err != nil {
+				return err
+			}
+			for _, t := range tables {
+				if err = d.RollTableRegister(t); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		// 从文件内容导入随机表，按文件名后缀识别格式，可导入 Foundry VTT、Roll20 导出的表格
+		_ = rollTable.Set("load", func(filename string, content string) (int, error) {
+			tables, err := ParseRollTables(filename, []byte(content))
+			if err != nil {
+				return 0, err
+			}
+			for _, t := range tables {
+				_ = d.RollTableRegister(t)
+			}
+			return len(tables), nil
+		})
+		_ = rollTable.Set("reload", func() {
+			RollTableReload(d)
+		})
+		_ = seal.Set("rollTable", rollTable)
+
 		_ = seal.Set("replyGroup", ReplyGroup)
 		_ = seal.Set("replyPerson", ReplyPerson)
 		_ = seal.Set("replyToSender", ReplyToSender)
@@ -570,22 +659,14 @@ func (d *Dice) JsInit() {
 			if err != nil {
 				return errors.New("解析失败:" + err.Error())
 			}
-			ret := d.GameSystemTemplateAddEx(tmpl, true)
-			if !ret {
-				return errors.New("已存在同名模板")
-			}
-			return nil
+			return d.jsAddGameTemplate(vm, tmpl)
 		})
 		_ = gameSystem.Set("newTemplateByYaml", func(data string) error {
 			tmpl, err := loadGameSystemTemplateFromData([]byte(data), "yaml")
 			if err != nil {
 				return errors.New("解析失败:" + err.Error())
 			}
-			ret := d.GameSystemTemplateAddEx(tmpl, true)
-			if !ret {
-				return errors.New("已存在同名模板")
-			}
-			return nil
+			return d.jsAddGameTemplate(vm, tmpl)
 		})
 		_ = seal.Set("gameSystem", gameSystem)
 		_ = seal.Set("getCtxProxyAtPos", GetCtxProxyAtPos)
@@ -627,6 +708,77 @@ func (d *Dice) JsInit() {
 			return base64.StdEncoding.EncodeToString([]byte(s))
 		})
 		// 1.2新增结束
+		// HTTP 路由，挂载在 /sd-api/ext/<扩展名>/ 下
+		httpObj := vm.NewObject()
+		_ = seal.Set("http", httpObj)
+		_ = httpObj.Set("route", func(ei *ExtInfo, method, path string, handler goja.Callable, options map[string]interface{}) error {
+			ext, err := d.jsOwnExt(vm, ei)
+			if err != nil {
+				return err
+			}
+			auth, _ := options["auth"].(bool)
+			return d.JsHTTPRegister(ext, method, path, handler, auth)
+		})
+		_ = httpObj.Set("static", func(ei *ExtInfo, prefix, dir string, options map[string]interface{}) error {
+			ext, err := d.jsOwnExt(vm, ei)
+			if err != nil {
+				return err
+			}
+			auth, _ := options["auth"].(bool)
+			return d.JsHTTPRegisterStatic(ext, prefix, dir, auth)
+		})
+		// 扩展间通信
+		ipcObj := vm.NewObject()
+		_ = seal.Set("ipc", ipcObj)
+		_ = ipcObj.Set("provide", func(ei *ExtInfo, name string, handler goja.Callable) error {
+			ext, err := d.jsOwnExt(vm, ei)
+			if err != nil {
+				return err
+			}
+			return d.IPC.provide(&ipcEndpoint{ext: ext, name: name, jsFn: handler})
+		})
+		_ = ipcObj.Set("subscribe", func(ei *ExtInfo, target, topic string, handler goja.Callable) error {
+			ext, err := d.jsOwnExt(vm, ei)
+			if err != nil {
+				return err
+			}
+			return d.IPC.subscribe(target, &ipcEndpoint{ext: ext, name: topic, jsFn: handler})
+		})
+		_ = ipcObj.Set("publish", func(ei *ExtInfo, topic string, data goja.Value) (int, error) {
+			ext, err := d.jsOwnExt(vm, ei)
+			if err != nil {
+				return 0, err
+			}
+			return d.IPC.Publish(ext, topic, data.Export()), nil
+		})
+		_ = ipcObj.Set("call", func(ei *ExtInfo, target, name string, payload goja.Value, timeoutMs int64) (*goja.Promise, error) {
+			ext, err := d.jsOwnExt(vm, ei)
+			if err != nil {
+				return nil, err
+			}
+			p, resolve, reject := vm.NewPromise()
+			var data any
+			if payload != nil {
+				data = payload.Export()
+			}
+			d.IPC.callAsync(ext, target, name, data, time.Duration(timeoutMs)*time.Millisecond, func(v any, err error) {
+				loop.RunOnLoop(func(vm *goja.Runtime) {
+					// 结算时会执行脚本的 then 回调，同样需要计时
+					_ = d.JsRunGuarded(vm, ext, func() {
+						var errSettle error
+						if err != nil {
+							errSettle = reject(vm.NewGoError(err))
+						} else {
+							errSettle = resolve(vm.ToValue(v))
+						}
+						if isJsWatchdogInterrupt(errSettle) {
+							panic(errSettle)
+						}
+					})
+				})
+			})
+			return p, nil
+		})
 		_ = seal.Set("setPlayerGroupCard", SetPlayerGroupCardByTemplate)
 		_ = seal.Set("base64ToImage", Base64ToImageFunc())
 
@@ -657,7 +809,7 @@ func (d *Dice) JsInit() {
 		//  }
 		// }
 		// `)
-		_, _ = vm.RunString(`Object.freeze(seal);Object.freeze(seal.deck);Object.freeze(seal.coc);Object.freeze(seal.ext);Object.freeze(seal.vars);`)
+		_, _ = vm.RunString(`Object.freeze(seal);Object.freeze(seal.deck);Object.freeze(seal.rollTable);Object.freeze(seal.coc);Object.freeze(seal.ext);Object.freeze(seal.vars);Object.freeze(seal.http);Object.freeze(seal.ipc);`)
 	})
 	go func() {
 		defer func() {
@@ -693,15 +845,27 @@ func (d *Dice) jsClear() {
 			if ext != nil && ext.Storage != nil {
 				_ = ext.StorageClose()
 			}
+			if ext != nil && d.ExtDocs != nil {
+				d.ExtDocs.Close(ext.Name)
+			}
 			return true
 		})
 	}
 	d.JsExtRegistry = new(SyncMap[string, *ExtInfo])
+	// 路由表会被 API 协程并发读取，只清空不替换
+	if d.JsHTTPRouter != nil {
+		d.JsHTTPRouter.Clear()
+	}
+	if d.IPC != nil {
+		d.IPC.removeJs()
+	}
 
 	// 清理coc扩展规则
 	d.CocExtraRules = map[int]*CocRuleInfo{}
 	// 清理脚本列表
+	d.jsScriptListLock.Lock()
 	d.JsScriptList = []*JsScriptInfo{}
+	d.jsScriptListLock.Unlock()
 	// 清理规则模板
 	// Pinenutn: 由于切换成了其他的syncMap，所以初始化策略需要修改
 	d.GameSystemMap = new(SyncMap[string, *GameSystemTemplate])
@@ -718,7 +882,9 @@ func isScriptFile(filename string) bool {
 }
 
 func (d *Dice) JsLoadScripts() {
+	d.jsScriptListLock.Lock()
 	d.JsScriptList = []*JsScriptInfo{}
+	d.jsScriptListLock.Unlock()
 
 	path := filepath.Join(d.BaseConfig.DataDir, "scripts")
 	builtinPath := filepath.Join(path, "_builtin")
@@ -777,6 +943,16 @@ func (d *Dice) JsLoadScripts() {
 		if info.IsDir() && info.Name() == "_builtin" {
 			return fs.SkipDir
 		}
+		// 含 package.json 的目录是脚本工程，打包为一个脚本，其中的文件不再单独加载
+		if info.IsDir() && path != filepath.Join(d.BaseConfig.DataDir, "scripts") && isJsProjectDir(path) {
+			jsInfo, err := d.jsParseProject("./" + path)
+			if err != nil {
+				d.Logger.Error("读取脚本工程失败: ", err.Error())
+				return fs.SkipDir
+			}
+			jsInfos = append(jsInfos, jsInfo)
+			return fs.SkipDir
+		}
 		if isScriptFile(path) {
 			d.Logger.Info("正在读取脚本: ", path)
 			data, err := os.ReadFile(path) //nolint:gosec
@@ -796,10 +972,17 @@ func (d *Dice) JsLoadScripts() {
 
 	// Load scripts from enabled packages.
 	if d.PackageManager != nil {
-		for _, scriptFile := range d.PackageManager.GetEnabledContentFiles("scripts") {
-			if !isScriptFile(scriptFile.Path) {
+		scriptFiles, projects := jsPackageScripts(d.PackageManager.GetEnabledContentFiles("scripts"))
+		for _, project := range projects {
+			jsInfo, err := d.jsParseProject("./" + project.Path)
+			if err != nil {
+				d.Logger.Error("failed to bundle package script project: ", err.Error())
 				continue
 			}
+			jsInfo.PackageID = project.PackageID
+			jsInfos = append(jsInfos, jsInfo)
+		}
+		for _, scriptFile := range scriptFiles {
 			info, err := os.Stat(scriptFile.Path)
 			if err != nil {
 				continue
@@ -904,6 +1087,168 @@ func (d *Dice) JsReload() {
 	d.Logger.Infof("JsReload: 重载完成，耗时 %dms", time.Since(startTime).Milliseconds())
 }
 
+// JsUnloadScript 卸载单个脚本注册的扩展：撤销其规则模板和 coc 规则、停止其定时任务、废弃配置项并移出 JsExtRegistry。
+// 不需要进入事件循环，其余脚本不受影响；wrapper 保留在群组中，重新加载后即恢复。
+func (d *Dice) JsUnloadScript(jsInfo *JsScriptInfo) {
+	if jsInfo == nil {
+		return
+	}
+	d.jsUnloadScriptRules(jsInfo)
+	if d.JsExtRegistry == nil {
+		return
+	}
+	var exts []*ExtInfo
+	d.JsExtRegistry.Range(func(_ string, ext *ExtInfo) bool {
+		if ext != nil && ext.Source != nil && (ext.Source == jsInfo || ext.Source.Name == jsInfo.Name) {
+			exts = append(exts, ext)
+		}
+		return true
+	})
+	for _, ext := range exts {
+		for _, task := range ext.taskList {
+			task.Off()
+		}
+		ext.taskList = nil
+		if d.ConfigManager != nil {
+			d.ConfigManager.unloadPluginConfigs(ext.Name)
+		}
+		if ext.Storage != nil {
+			_ = ext.StorageClose()
+		}
+		if d.ExtDocs != nil {
+			d.ExtDocs.Close(ext.Name)
+		}
+		if d.JsHTTPRouter != nil {
+			d.JsHTTPRouter.RemoveExt(ext.Name)
+		}
+		if d.IPC != nil {
+			d.IPC.RemoveExt(ext.Name)
+		}
+		d.JsExtRegistry.Delete(ext.Name)
+		d.Logger.Infof("已卸载脚本「%s」注册的扩展<%s>", jsInfo.Name, ext.Name)
+	}
+	d.ExtUpdateTime = time.Now().Unix()
+}
+
+// jsAddGameTemplate 注册脚本提供的规则模板，并记在当前脚本名下以便卸载时撤销
+func (d *Dice) jsAddGameTemplate(vm *goja.Runtime, tmpl *GameSystemTemplate) error {
+	prev, _ := d.GameSystemMap.Load(tmpl.Name)
+	if !d.GameSystemTemplateAddEx(tmpl, true) {
+		return errors.New("已存在同名模板")
+	}
+	if jsInfo := d.jsCurrentScript(vm); jsInfo != nil {
+		if jsInfo.gameTemplates == nil {
+			jsInfo.gameTemplates = map[string]*GameSystemTemplate{}
+		}
+		// 同一脚本多次注册同名模板时，保留最初被覆盖的那个
+		if _, ok := jsInfo.gameTemplates[tmpl.Name]; !ok {
+			jsInfo.gameTemplates[tmpl.Name] = prev
+		}
+	}
+	return nil
+}
+
+// jsUnloadScriptRules 撤销脚本注册的规则模板和 coc 扩展规则，被覆盖的模板恢复原样
+func (d *Dice) jsUnloadScriptRules(jsInfo *JsScriptInfo) {
+	for name, prev := range jsInfo.gameTemplates {
+		if prev != nil {
+			d.GameSystemMap.Store(name, prev)
+		} else {
+			d.GameSystemMap.Delete(name)
+		}
+	}
+	jsInfo.gameTemplates = nil
+	for _, index := range jsInfo.cocRules {
+		delete(d.CocExtraRules, index)
+	}
+	jsInfo.cocRules = nil
+}
+
+// JsScripts 返回脚本列表的快照，列表可能在单个脚本重载时被替换
+func (d *Dice) JsScripts() []*JsScriptInfo {
+	d.jsScriptListLock.RLock()
+	defer d.jsScriptListLock.RUnlock()
+	return slices.Clone(d.JsScriptList)
+}
+
+// jsScriptLoaded 判断脚本是否已有扩展注册到 JsExtRegistry
+func (d *Dice) jsScriptLoaded(name string) bool {
+	if d.JsExtRegistry == nil {
+		return false
+	}
+	loaded := false
+	d.JsExtRegistry.Range(func(_ string, ext *ExtInfo) bool {
+		loaded = ext != nil && ext.Source != nil && ext.Source.Name == name
+		return !loaded
+	})
+	return loaded
+}
+
+// JsReloadScript 重新读取并执行单个脚本，不重建 JS 运行环境，其余脚本保持运行
+func (d *Dice) JsReloadScript(name string) error {
+	if !d.Config.JsEnable || d.ExtLoopManager == nil || d.ExtLoopManager.GetWebLoop() == nil {
+		return ErrJsNotEnabled
+	}
+	var old *JsScriptInfo
+	for _, jsInfo := range d.JsScripts() {
+		if jsInfo.Name == name {
+			old = jsInfo
+			break
+		}
+	}
+	if old == nil {
+		return ErrJsScriptNotFound
+	}
+	d.JsUnloadScript(old)
+
+	// 脚本工程先重新打包
+	filename := old.Filename
+	if old.ProjectDir != "" {
+		var err error
+		if filename, err = JsBundleProject(old.ProjectDir); err != nil {
+			old.ErrText = err.Error()
+			old.Enable = false
+			return err
+		}
+	}
+	// 文件可能已被修改，重新解析元信息
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	// 解析失败时新条目也带有错误信息，一样替换原条目
+	jsInfo, err := d.jsParseMeta(filename, stat.ModTime(), data, old.Builtin)
+	d.jsScriptListLock.Lock()
+	if idx := slices.Index(d.JsScriptList, old); idx >= 0 {
+		d.JsScriptList[idx] = jsInfo
+	} else {
+		d.JsScriptList = append(d.JsScriptList, jsInfo)
+	}
+	d.jsScriptListLock.Unlock()
+	jsInfo.InstallTime = old.InstallTime
+	jsInfo.PackageID = old.PackageID
+	jsInfo.ProjectDir = old.ProjectDir
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(jsInfo.Filename)) == ".ts" {
+		jsInfo.needCompiled = true
+	}
+
+	d.Logger.Infof("正在重载脚本「%s:%s:%s」", jsInfo.Author, jsInfo.Name, jsInfo.Version)
+	d.jsLoadScript(jsInfo, true)
+	d.ApplyExtDefaultSettings()
+	d.ExtUpdateTime = time.Now().Unix()
+	if jsInfo.ErrText != "" {
+		return errors.New(jsInfo.ErrText)
+	}
+	return nil
+}
+
 // JsExtSettingVacuum 清理已被删除的脚本对应的插件配置
 //
 // Deprecated: bug
@@ -999,10 +1344,16 @@ type JsScriptInfo struct {
 	Depends []JsScriptDepends `json:"depends"`
 	/** 需要被编译 */
 	needCompiled bool
+	// 脚本注册的规则模板及被其覆盖的原模板(原先不存在时为 nil)，卸载时恢复
+	gameTemplates map[string]*GameSystemTemplate
+	// 脚本注册的 coc 扩展规则序号
+	cocRules []int
 	/** 扩展商店唯一 ID */
 	StoreID string `json:"storeID"`
 	/** Owning package ID */
 	PackageID string `json:"packageID,omitempty"`
+	/** 脚本工程目录，非空时 Filename 为打包产物 */
+	ProjectDir string `json:"projectDir,omitempty"`
 }
 
 type JsScriptDepends struct {
@@ -1017,13 +1368,24 @@ type JsScriptDepends struct {
 }
 
 func (d *Dice) JsParseMeta(s string, installTime time.Time, rawData []byte, builtin bool) (*JsScriptInfo, error) {
+	jsInfo, err := d.jsParseMeta(s, installTime, rawData, builtin)
+	d.jsScriptListLock.Lock()
+	d.JsScriptList = append(d.JsScriptList, jsInfo)
+	d.jsScriptListLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return jsInfo, nil
+}
+
+// jsParseMeta 解析脚本元信息，解析失败时返回的条目带有错误信息
+func (d *Dice) jsParseMeta(s string, installTime time.Time, rawData []byte, builtin bool) (*JsScriptInfo, error) {
 	// 读取文件内容填空，类似油猴脚本那种形式
 	jsInfo := &JsScriptInfo{
 		Name:        filepath.Base(s),
 		Filename:    s,
 		InstallTime: installTime.Unix(),
 	}
-	d.JsScriptList = append(d.JsScriptList, jsInfo)
 
 	jsInfo.Builtin = builtin
 	jsInfo.Digest = crypto.CalculateSHA512Str(rawData)
@@ -1133,13 +1495,18 @@ func (d *Dice) JsParseMeta(s string, installTime time.Time, rawData []byte, buil
 	if len(errMsg) > 0 {
 		jsInfo.Enable = false
 		jsInfo.ErrText = strings.Join(errMsg, "\n")
-		return nil, errors.New(strings.Join(errMsg, "|"))
+		return jsInfo, errors.New(strings.Join(errMsg, "|"))
 	}
 	jsInfo.Enable = !(&d.Config).DisabledJsScripts[jsInfo.Name]
 	return jsInfo, nil
 }
 
 func (d *Dice) JsLoadScriptRaw(jsInfo *JsScriptInfo) {
+	d.jsLoadScript(jsInfo, false)
+}
+
+// jsLoadScript 执行脚本文件，fresh 为 true 时绕过 require 的模块缓存，用于单个脚本重载
+func (d *Dice) jsLoadScript(jsInfo *JsScriptInfo, fresh bool) {
 	var err error
 	if jsInfo.Enable {
 		d.JsLoadingScript = jsInfo
@@ -1154,7 +1521,11 @@ func (d *Dice) JsLoadScriptRaw(jsInfo *JsScriptInfo) {
 			targetPath = jsInfo.Filename
 		}
 		if err == nil {
-			_, err = d.ExtLoopManager.GetWebLoop().RequireModule(targetPath)
+			if fresh {
+				err = d.jsRunScriptFile(jsInfo, targetPath)
+			} else {
+				_, err = d.ExtLoopManager.GetWebLoop().RequireModule(targetPath)
+			}
 		}
 		d.JsLoadingScript = nil
 	} else {
@@ -1165,24 +1536,101 @@ func (d *Dice) JsLoadScriptRaw(jsInfo *JsScriptInfo) {
 		errText := err.Error()
 		jsInfo.ErrText = errText
 		jsInfo.Enable = false
-		d.Logger.Error("读取脚本失败(解析失败): ", errText)
+		d.jsReportError("读取脚本失败(解析失败): " + jsErrorText(err))
 	}
 }
 
+// jsRunScriptFile 按 CommonJS 模块的方式包装并执行脚本文件，等待执行完成，执行时受看门狗限时
+func (d *Dice) jsRunScriptFile(jsInfo *JsScriptInfo, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	prg, err := goja.Compile(filename, "(function(exports, require, module, __filename, __dirname) {"+string(data)+"\n})", false)
+	if err != nil {
+		return err
+	}
+	loop := d.ExtLoopManager.GetWebLoop()
+	if loop == nil {
+		return ErrJsNotEnabled
+	}
+	done := make(chan error, 1)
+	scheduled := loop.RunOnLoop(func(vm *goja.Runtime) {
+		var errRun error
+		defer func() {
+			if r := recover(); r != nil {
+				errRun = fmt.Errorf("%v", r)
+			}
+			done <- errRun
+		}()
+		errGuard := d.JsRunGuarded(vm, &ExtInfo{Name: jsInfo.Name, Source: jsInfo}, func() {
+			var fnValue goja.Value
+			fnValue, errRun = vm.RunProgram(prg)
+			if errRun == nil {
+				fn, _ := goja.AssertFunction(fnValue)
+				module := vm.NewObject()
+				exports := vm.NewObject()
+				_ = module.Set("exports", exports)
+				_, errRun = fn(goja.Undefined(), exports, vm.Get("require"), module, vm.ToValue(filename), vm.ToValue(filepath.Dir(filename)))
+			}
+			// 看门狗的中断以错误形式返回，交给 JsRunGuarded 处理
+			if isJsWatchdogInterrupt(errRun) {
+				panic(errRun)
+			}
+		})
+		if errGuard != nil {
+			errRun = errGuard
+		}
+	})
+	if !scheduled {
+		return ErrJsNotEnabled
+	}
+	return <-done
+}
+
+// jsCurrentScript 当前在 vm 上执行的脚本：执行扩展回调时为该扩展所属的脚本，否则为正在加载的脚本
+func (d *Dice) jsCurrentScript(vm *goja.Runtime) *JsScriptInfo {
+	if d.JsWatchdog != nil {
+		if ext := d.JsWatchdog.current(vm); ext != nil {
+			if realExt := ext.GetRealExt(); realExt != nil {
+				ext = realExt
+			}
+			return ext.Source
+		}
+	}
+	return d.JsLoadingScript
+}
+
+// jsOwnExt 确认脚本传入的扩展属于当前正在执行的脚本并返回实际的扩展，
+// 避免脚本用 seal.ext.find 取得其他扩展后冒用其身份
+func (d *Dice) jsOwnExt(vm *goja.Runtime, ei *ExtInfo) (*ExtInfo, error) {
+	if ei == nil || ei.dice == nil {
+		return nil, errors.New("请先完成此扩展的注册")
+	}
+	ext := ei.GetRealExt()
+	if ext == nil {
+		return nil, errors.New("扩展已被卸载")
+	}
+	if script := d.jsCurrentScript(vm); script == nil || ext.Source != script {
+		return nil, fmt.Errorf("扩展<%s>不属于当前脚本", ext.Name)
+	}
+	return ext, nil
+}
+
 func tsScriptCompile(path string) (string, error) {
 	script, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
 	compiled := esbuild.Transform(string(script), esbuild.TransformOptions{
-		Loader: esbuild.LoaderTS,
+		Loader:     esbuild.LoaderTS,
+		Sourcefile: path,
+		// 编译产物是临时文件，source map 内嵌，报错位置对应原始的 .ts 文件
+		Sourcemap:      esbuild.SourceMapInline,
+		SourcesContent: esbuild.SourcesContentExclude,
 	})
 	if len(compiled.Errors) > 0 {
-		var msg strings.Builder
-		for _, e := range compiled.Errors {
-			msg.WriteString(e.Text) // FIXME 优化错误信息展示
-		}
-		return "", errors.New(msg.String())
+		return "", errors.New(esbuildErrorText(compiled.Errors))
 	}
 	compiledPath, err := os.CreateTemp("", "compiled-*-"+filepath.Base(path))
 	if err != nil {
@@ -1229,7 +1677,10 @@ func JsDelete(d *Dice, jsInfo *JsScriptInfo) {
 	dirpath := filepath.Dir(jsInfo.Filename)
 	dirname := filepath.Base(dirpath)
 
-	if strings.HasPrefix(dirname, "_") && strings.HasSuffix(dirname, ".deck") {
+	if jsInfo.ProjectDir != "" {
+		// 脚本工程连同源码与 node_modules 一起删除
+		_ = os.RemoveAll(jsInfo.ProjectDir)
+	} else if strings.HasPrefix(dirname, "_") && strings.HasSuffix(dirname, ".deck") {
 		// 可能是zip解压出来的，那么删除目录和压缩包
 		_ = os.RemoveAll(dirpath)
 		zipFilename := filepath.Join(filepath.Dir(dirpath), dirname[1:])
@@ -1262,20 +1713,30 @@ func JsDelete(d *Dice, jsInfo *JsScriptInfo) {
 
 func JsEnable(d *Dice, jsInfoName string) {
 	delete((&d.Config).DisabledJsScripts, jsInfoName)
-	for _, jsInfo := range d.JsScriptList {
+	if d.JsWatchdog != nil {
+		d.JsWatchdog.setAutoDisabled(jsInfoName, false)
+	}
+	for _, jsInfo := range d.JsScripts() {
 		if jsInfo.Name == jsInfoName {
 			jsInfo.Enable = true
 		}
 	}
+	// JS 环境运行中时直接加载该脚本，不再需要全局重载
+	if !d.jsScriptLoaded(jsInfoName) {
+		if err := d.JsReloadScript(jsInfoName); err != nil && !errors.Is(err, ErrJsNotEnabled) {
+			d.Logger.Errorf("加载脚本「%s」失败: %v", jsInfoName, err)
+		}
+	}
 	d.LastUpdatedTime = time.Now().Unix()
 	d.Save(false)
 }
 
 func JsDisable(d *Dice, jsInfoName string) {
 	(&d.Config).DisabledJsScripts[jsInfoName] = true
-	for _, jsInfo := range d.JsScriptList {
+	for _, jsInfo := range d.JsScripts() {
 		if jsInfo.Name == jsInfoName {
 			jsInfo.Enable = false
+			d.JsUnloadScript(jsInfo)
 		}
 	}
 	d.LastUpdatedTime = time.Now().Unix()
@@ -1501,6 +1962,8 @@ type JsScriptTask struct {
 	task     func(JsScriptTaskCtx)
 	entryID  *cron.EntryID
 	lock     *sync.Mutex
+	// ext 注册任务的扩展，任务回到事件循环并以它的身份计时执行
+	ext *ExtInfo
 
 	logger *zap.SugaredLogger
 }
@@ -1522,7 +1985,9 @@ func (t *JsScriptTask) run() {
 	}
 	defer t.lock.Unlock()
 	t.lock.Lock()
-	t.task(taskCtx)
+	_ = t.ext.callWithJsCheck(t.ext.dice, func() {
+		t.task(taskCtx)
+	})
 }
 
 func (t *JsScriptTask) On() bool {
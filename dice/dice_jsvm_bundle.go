@@ -0,0 +1,385 @@
+package dice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+const (
+	// jsProjectManifestName 含有该文件的目录被视为一个脚本工程
+	jsProjectManifestName = "package.json"
+	// jsProjectBuildDir 打包产物所在目录，相对于工程目录
+	jsProjectBuildDir = ".build"
+	jsProjectBundleJS = "index.js"
+)
+
+// jsNativeModules 由 require.Registry 提供的原生模块，打包时保留 require 调用
+var jsNativeModules = []string{"console"}
+
+// jsProjectEntryCandidates 未指定入口时依次尝试的文件
+var jsProjectEntryCandidates = []string{"index.ts", "index.js", "src/index.ts", "src/index.js"}
+
+// JsProjectManifest 脚本工程的 package.json，海豹专有的元信息放在 sealdice 字段下
+type JsProjectManifest struct {
+	Name        string          `json:"name"`
+	Version     string          `json:"version"`
+	Description string          `json:"description"`
+	License     string          `json:"license"`
+	Homepage    string          `json:"homepage"`
+	Author      jsProjectAuthor `json:"author"`
+	Main        string          `json:"main"`
+	Sealdice    struct {
+		Name        string   `json:"name"`  // 显示名，省略时使用 name
+		Entry       string   `json:"entry"` // 入口文件，省略时使用 main
+		SealVersion string   `json:"sealVersion"`
+		Depends     []string `json:"depends"`
+		StoreID     string   `json:"storeID"`
+		Timestamp   string   `json:"timestamp"`
+	} `json:"sealdice"`
+}
+
+// jsProjectAuthor package.json 的 author 可以是字符串或 {"name": ...}
+type jsProjectAuthor string
+
+func (a *jsProjectAuthor) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = jsProjectAuthor(s)
+		return nil
+	}
+	var v struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = jsProjectAuthor(v.Name)
+	return nil
+}
+
+func isJsProjectDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, jsProjectManifestName))
+	return err == nil && !info.IsDir()
+}
+
+// ReadJsProjectManifest 读取脚本工程的 package.json
+func ReadJsProjectManifest(dir string) (*JsProjectManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, jsProjectManifestName))
+	if err != nil {
+		return nil, err
+	}
+	m := &JsProjectManifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s 格式错误: %w", jsProjectManifestName, err)
+	}
+	return m, nil
+}
+
+func (m *JsProjectManifest) entry(dir string) (string, error) {
+	for _, entry := range []string{m.Sealdice.Entry, m.Main} {
+		if entry != "" {
+			if !filepath.IsLocal(filepath.FromSlash(entry)) {
+				return "", fmt.Errorf("工程入口必须是工程目录内的相对路径: %q", entry)
+			}
+			return entry, nil
+		}
+	}
+	for _, entry := range jsProjectEntryCandidates {
+		if _, err := os.Stat(filepath.Join(dir, entry)); err == nil {
+			return entry, nil
+		}
+	}
+	return "", errors.New("找不到工程入口，请在 package.json 中指定 main")
+}
+
+// userScriptHeader 按单文件脚本的格式生成元信息注释，打包后放在产物开头
+func (m *JsProjectManifest) userScriptHeader() string {
+	name := m.Sealdice.Name
+	if name == "" {
+		name = m.Name
+	}
+	var sb strings.Builder
+	sb.WriteString("// ==UserScript==\n")
+	add := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&sb, "// @%s %s\n", key, strings.ReplaceAll(value, "\n", "\\n"))
+		}
+	}
+	add("name", name)
+	add("author", string(m.Author))
+	add("version", m.Version)
+	add("description", m.Description)
+	add("license", m.License)
+	add("homepageURL", m.Homepage)
+	add("timestamp", m.Sealdice.Timestamp)
+	add("sealVersion", m.Sealdice.SealVersion)
+	add("storeID", m.Sealdice.StoreID)
+	for _, dep := range m.Sealdice.Depends {
+		add("depends", dep)
+	}
+	sb.WriteString("// ==/UserScript==")
+	return sb.String()
+}
+
+// JsBundleProject 用 esbuild 把脚本工程打包为单个 CommonJS 文件，返回产物路径。
+// 第三方库从工程自带的 node_modules 中解析，产物内嵌 source map，
+// 报错时的调用栈会被映射回原始文件。
+func JsBundleProject(dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	m, err := ReadJsProjectManifest(absDir)
+	if err != nil {
+		return "", err
+	}
+	entry, err := m.entry(absDir)
+	if err != nil {
+		return "", err
+	}
+	outfile := filepath.Join(absDir, jsProjectBuildDir, jsProjectBundleJS)
+	result := esbuild.Build(esbuild.BuildOptions{
+		AbsWorkingDir:  absDir,
+		EntryPoints:    []string{entry},
+		Outfile:        outfile,
+		Bundle:         true,
+		Format:         esbuild.FormatCommonJS,
+		Platform:       esbuild.PlatformNeutral,
+		MainFields:     []string{"module", "main"},
+		External:       jsNativeModules,
+		Banner:         map[string]string{"js": m.userScriptHeader()},
+		Charset:        esbuild.CharsetUTF8,
+		Sourcemap:      esbuild.SourceMapInline,
+		SourcesContent: esbuild.SourcesContentExclude,
+		LogLevel:       esbuild.LogLevelSilent,
+		Plugins:        []esbuild.Plugin{jsProjectConfinePlugin(absDir)},
+	})
+	if len(result.Errors) > 0 {
+		return "", errors.New(esbuildErrorText(result.Errors))
+	}
+	if err = os.MkdirAll(filepath.Dir(outfile), 0o755); err != nil {
+		return "", err
+	}
+	for _, f := range result.OutputFiles {
+		if err = os.WriteFile(f.Path, f.Contents, 0o644); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, jsProjectBuildDir, jsProjectBundleJS), nil
+}
+
+// jsProjectConfineResolving 插件内部重新解析时的标记，避免递归
+type jsProjectConfineResolving struct{}
+
+// jsProjectConfinePlugin 只允许打包工程目录内的文件，
+// 防止 ../ 导入或向上查找 node_modules 把宿主上的文件内联进产物
+func jsProjectConfinePlugin(absDir string) esbuild.Plugin {
+	root := absDir
+	if real, err := filepath.EvalSymlinks(absDir); err == nil {
+		root = real
+	}
+	inside := func(p string) bool {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+		rel, err := filepath.Rel(root, p)
+		return err == nil && filepath.IsLocal(rel)
+	}
+	return esbuild.Plugin{
+		Name: "sealdice-confine",
+		Setup: func(build esbuild.PluginBuild) {
+			build.OnResolve(esbuild.OnResolveOptions{Filter: ".*", Namespace: "file"}, func(args esbuild.OnResolveArgs) (esbuild.OnResolveResult, error) {
+				if _, ok := args.PluginData.(jsProjectConfineResolving); ok {
+					return esbuild.OnResolveResult{}, nil
+				}
+				r := build.Resolve(args.Path, esbuild.ResolveOptions{
+					Importer:   args.Importer,
+					Namespace:  args.Namespace,
+					ResolveDir: args.ResolveDir,
+					Kind:       args.Kind,
+					PluginData: jsProjectConfineResolving{},
+					With:       args.With,
+				})
+				if len(r.Errors) > 0 {
+					return esbuild.OnResolveResult{Errors: r.Errors}, nil
+				}
+				if !r.External && r.Namespace == "file" && !inside(r.Path) {
+					return esbuild.OnResolveResult{}, fmt.Errorf("不能引用工程目录之外的文件: %s", args.Path)
+				}
+				return esbuild.OnResolveResult{
+					Path:        r.Path,
+					External:    r.External,
+					SideEffects: esbuildSideEffects(r.SideEffects),
+					Namespace:   r.Namespace,
+					Suffix:      r.Suffix,
+					PluginData:  r.PluginData,
+					Warnings:    r.Warnings,
+				}, nil
+			})
+		},
+	}
+}
+
+func esbuildSideEffects(v bool) esbuild.SideEffects {
+	if v {
+		return esbuild.SideEffectsTrue
+	}
+	return esbuild.SideEffectsFalse
+}
+
+func esbuildErrorText(msgs []esbuild.Message) string {
+	lines := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Location == nil {
+			lines = append(lines, msg.Text)
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column, msg.Text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// jsProjectTarget 脚本工程的安装目录 scripts/<name>，name 只能是单个普通的路径元素
+func jsProjectTarget(dataDir, name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\:`) || filepath.Base(name) != name {
+		return "", fmt.Errorf("脚本工程名不合法: %q", name)
+	}
+	scriptsDir := filepath.Join(dataDir, "scripts")
+	target := filepath.Join(scriptsDir, name)
+	if filepath.Dir(target) != scriptsDir {
+		return "", fmt.Errorf("脚本工程名不合法: %q", name)
+	}
+	return target, nil
+}
+
+// JsInstallProject 把脚本工程压缩包解压为 scripts/<name>，已存在时覆盖。
+// package.json 应位于压缩包根目录或其唯一的子目录中，安装前先打包一次，打包失败则不安装。
+func (d *Dice) JsInstallProject(zipPath, name string) (string, error) {
+	target, err := jsProjectTarget(d.BaseConfig.DataDir, name)
+	if err != nil {
+		return "", err
+	}
+	tempDir, err := os.MkdirTemp(d.BaseConfig.DataDir, ".js-project-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = os.RemoveAll(tempDir)
+	}()
+	if err = unzipSource(zipPath, tempDir); err != nil {
+		return "", err
+	}
+	root := tempDir
+	if !isJsProjectDir(root) {
+		entries, _ := os.ReadDir(root)
+		if len(entries) != 1 || !entries[0].IsDir() || !isJsProjectDir(filepath.Join(root, entries[0].Name())) {
+			return "", errors.New("压缩包中找不到 package.json")
+		}
+		root = filepath.Join(root, entries[0].Name())
+	}
+	if _, err = JsBundleProject(root); err != nil {
+		return "", err
+	}
+	if err = os.RemoveAll(target); err != nil {
+		return "", err
+	}
+	if err = os.Rename(root, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// jsParseProject 打包脚本工程并解析元信息。打包失败时仍会在脚本列表中留下带错误信息的条目
+func (d *Dice) jsParseProject(dir string) (*JsScriptInfo, error) {
+	d.Logger.Infof("正在打包脚本工程: %s", dir)
+	target, err := JsBundleProject(dir)
+	if err != nil {
+		d.jsScriptListLock.Lock()
+		d.JsScriptList = append(d.JsScriptList, &JsScriptInfo{
+			Name:       filepath.Base(dir),
+			Filename:   dir,
+			ProjectDir: dir,
+			ErrText:    err.Error(),
+		})
+		d.jsScriptListLock.Unlock()
+		return nil, err
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		return nil, err
+	}
+	// 安装时间取 package.json 的修改时间，打包产物每次重载都会重新生成
+	info, err := os.Stat(filepath.Join(dir, jsProjectManifestName))
+	if err != nil {
+		return nil, err
+	}
+	jsInfo, err := d.JsParseMeta(target, info.ModTime(), data, false)
+	if jsInfo != nil {
+		jsInfo.ProjectDir = dir
+	}
+	return jsInfo, err
+}
+
+// jsPackageScripts 把扩展包的 scripts 资源分成单文件脚本与脚本工程，工程的 Path 为其目录。
+// 工程内的文件(包括 node_modules 中库自带的 package.json)不再单独出现。
+func jsPackageScripts(files []PackageContentFile) (scripts, projects []PackageContentFile) {
+	projectDirs := map[string]bool{}
+	for _, f := range files {
+		if filepath.Base(f.Path) == jsProjectManifestName {
+			projectDirs[filepath.Dir(f.Path)] = true
+		}
+	}
+	for _, f := range files {
+		dir := filepath.Dir(f.Path)
+		switch {
+		case filepath.Base(f.Path) == jsProjectManifestName && !jsUnderProject(dir, projectDirs):
+			f.Path = dir
+			f.PackagePath = filepath.ToSlash(filepath.Dir(f.PackagePath))
+			projects = append(projects, f)
+		case jsUnderProject(f.Path, projectDirs):
+			// 由所在工程打包
+		case isScriptFile(f.Path):
+			scripts = append(scripts, f)
+		}
+	}
+	return scripts, projects
+}
+
+// jsUnderProject 判断文件是否位于某个脚本工程目录之内
+func jsUnderProject(path string, projectDirs map[string]bool) bool {
+	for dir := filepath.Dir(path); ; {
+		if projectDirs[dir] {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// jsErrorText 取 JS 异常的完整调用栈，位置已按 source map 映射回原始文件
+func jsErrorText(v any) string {
+	var ex *goja.Exception
+	if err, ok := v.(error); ok && errors.As(err, &ex) {
+		return ex.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// jsReportError 记录脚本错误，同时进入 /js/get_record 的输出
+func (d *Dice) jsReportError(s string) {
+	if d.JsPrinter != nil {
+		d.JsPrinter.Error(s)
+		return
+	}
+	d.Logger.Error(s)
+}
@@ -0,0 +1,484 @@
+package dice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	// jsHTTPBodyLimit 转交给脚本的请求体上限
+	jsHTTPBodyLimit = 8 << 20
+	// jsHTTPResponseTimeout 等待脚本异步响应的时限
+	jsHTTPResponseTimeout = 30 * time.Second
+)
+
+// jsHTTPSandboxPolicy 扩展页面运行在不透明源中，页面脚本无法读取 WebUI 的登录状态或以其身份调用接口
+const jsHTTPSandboxPolicy = "sandbox allow-scripts allow-forms allow-popups allow-downloads"
+
+// jsHTTPHiddenHeaders WebUI 的登录凭据，不转交给脚本
+var jsHTTPHiddenHeaders = map[string]bool{"token": true, "authorization": true, "cookie": true}
+
+var ErrJsHTTPNoPermission = errors.New("只有声明了HTTP服务权限(http_server)的扩展包可以提供HTTP服务")
+
+// JsHTTPRoute 脚本通过 seal.http 注册的路由，挂载在 /sd-api/ext/<扩展名>/ 下
+type JsHTTPRoute struct {
+	ExtName string `json:"extName"`
+	Method  string `json:"method"` // 大写，* 匹配任意方法
+	Path    string `json:"path"`   // 支持 :name 参数，末尾 * 匹配剩余路径
+	Auth    bool   `json:"auth"`   // 需要登录 WebUI 才能访问
+	Static  string `json:"-"`      // 非空时为静态文件路由，值为文件目录
+
+	segments []string
+	handler  goja.Callable
+	ext      *ExtInfo
+}
+
+// match 匹配请求路径，成功时返回路径参数
+func (r *JsHTTPRoute) match(method string, segments []string) (map[string]string, bool) {
+	if r.Method != "*" && r.Method != method {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, seg := range r.segments {
+		if seg == "*" && i == len(r.segments)-1 {
+			params["*"] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			params[seg[1:]] = segments[i]
+		case seg != segments[i]:
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(r.segments)
+}
+
+// JsHTTPRouter 保存各扩展注册的路由
+type JsHTTPRouter struct {
+	lock   sync.RWMutex
+	routes []*JsHTTPRoute
+}
+
+func NewJsHTTPRouter() *JsHTTPRouter {
+	return &JsHTTPRouter{}
+}
+
+func splitJsHTTPPath(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return []string{}
+	}
+	return strings.Split(p, "/")
+}
+
+// add 添加路由，同一扩展下方法与路径相同的旧路由会被替换
+func (r *JsHTTPRouter) add(route *JsHTTPRoute) {
+	route.segments = splitJsHTTPPath(route.Path)
+	route.Path = "/" + strings.Join(route.segments, "/")
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, item := range r.routes {
+		if item.ExtName == route.ExtName && item.Method == route.Method && item.Path == route.Path {
+			r.routes[i] = route
+			return
+		}
+	}
+	r.routes = append(r.routes, route)
+}
+
+// RemoveExt 移除扩展注册的全部路由
+func (r *JsHTTPRouter) RemoveExt(extName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	routes := r.routes[:0]
+	for _, item := range r.routes {
+		if item.ExtName != extName {
+			routes = append(routes, item)
+		}
+	}
+	clear(r.routes[len(routes):])
+	r.routes = routes
+}
+
+// Clear 移除全部路由，JS 环境重建时调用
+func (r *JsHTTPRouter) Clear() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.routes = nil
+}
+
+// Match 按注册顺序查找第一个匹配的路由
+func (r *JsHTTPRouter) Match(extName, method, p string) (*JsHTTPRoute, map[string]string) {
+	segments := splitJsHTTPPath(p)
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, item := range r.routes {
+		if item.ExtName != extName {
+			continue
+		}
+		if params, ok := item.match(method, segments); ok {
+			return item, params
+		}
+	}
+	return nil, nil
+}
+
+// Routes 返回已注册路由的副本
+func (r *JsHTTPRouter) Routes() []JsHTTPRoute {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	items := make([]JsHTTPRoute, 0, len(r.routes))
+	for _, item := range r.routes {
+		items = append(items, *item)
+	}
+	return items
+}
+
+// JsHTTPRequest 交给脚本处理函数的请求
+type JsHTTPRequest struct {
+	Method     string            `jsbind:"method"`
+	Path       string            `jsbind:"path"`
+	Params     map[string]string `jsbind:"params"`
+	Query      map[string]string `jsbind:"query"`
+	Headers    map[string]string `jsbind:"headers"`
+	Body       string            `jsbind:"body"`
+	RemoteAddr string            `jsbind:"remoteAddr"`
+	User       string            `jsbind:"user"` // 已登录 WebUI 时为用户名
+}
+
+// Json 按 JSON 解析请求体
+func (req *JsHTTPRequest) Json() (any, error) { //nolint:revive,stylecheck // js 侧名称
+	var v any
+	if err := json.Unmarshal([]byte(req.Body), &v); err != nil {
+		return nil, errors.New("请求体不是合法的JSON: " + err.Error())
+	}
+	return v, nil
+}
+
+// JsHTTPResponse 脚本用来写回响应的对象，send/json 之后即结束
+type JsHTTPResponse struct {
+	lock   sync.Mutex
+	status int
+	header http.Header
+	body   []byte
+	sent   bool
+	done   chan struct{}
+}
+
+func newJsHTTPResponse() *JsHTTPResponse {
+	return &JsHTTPResponse{status: http.StatusOK, header: http.Header{}, done: make(chan struct{})}
+}
+
+func (res *JsHTTPResponse) Status(code int) *JsHTTPResponse {
+	res.lock.Lock()
+	defer res.lock.Unlock()
+	if code >= 100 && code <= 999 {
+		res.status = code
+	}
+	return res
+}
+
+func (res *JsHTTPResponse) Header(key, value string) *JsHTTPResponse {
+	res.lock.Lock()
+	defer res.lock.Unlock()
+	res.header.Set(key, value)
+	return res
+}
+
+func (res *JsHTTPResponse) finish(contentType string, body []byte) {
+	res.lock.Lock()
+	defer res.lock.Unlock()
+	if res.sent {
+		return
+	}
+	if res.header.Get("Content-Type") == "" && contentType != "" {
+		res.header.Set("Content-Type", contentType)
+	}
+	res.body = body
+	res.sent = true
+	close(res.done)
+}
+
+// Send 字符串和 ArrayBuffer 原样发送，其他值按 JSON 发送
+func (res *JsHTTPResponse) Send(v goja.Value) error {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		res.finish("", nil)
+		return nil
+	}
+	switch data := v.Export().(type) {
+	case string:
+		res.finish("text/plain; charset=utf-8", []byte(data))
+	case goja.ArrayBuffer:
+		res.finish("application/octet-stream", data.Bytes())
+	case []byte:
+		res.finish("application/octet-stream", data)
+	default:
+		return res.Json(data)
+	}
+	return nil
+}
+
+func (res *JsHTTPResponse) Json(v any) error { //nolint:revive,stylecheck // js 侧名称
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	res.finish("application/json; charset=utf-8", data)
+	return nil
+}
+
+func (res *JsHTTPResponse) Redirect(url string) {
+	res.Status(http.StatusFound).Header("Location", url)
+	res.finish("", nil)
+}
+
+func (res *JsHTTPResponse) isSent() bool {
+	res.lock.Lock()
+	defer res.lock.Unlock()
+	return res.sent
+}
+
+func (res *JsHTTPResponse) writeTo(w http.ResponseWriter) {
+	res.lock.Lock()
+	defer res.lock.Unlock()
+	for k, v := range res.header {
+		w.Header()[k] = v
+	}
+	// 脚本不能放宽沙箱
+	w.Header().Set("Content-Security-Policy", jsHTTPSandboxPolicy)
+	status := res.status
+	if len(res.body) == 0 && status == http.StatusOK {
+		status = http.StatusNoContent
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(res.body)
+}
+
+// jsCheckHTTPServerPermission 只有声明了 http_server 权限的扩展包内脚本可以提供HTTP服务
+func (d *Dice) jsCheckHTTPServerPermission(ext *ExtInfo) error {
+	if ext.Source == nil || ext.Source.PackageID == "" || d.PackageManager == nil {
+		return ErrJsHTTPNoPermission
+	}
+	sandbox, err := d.PackageManager.GetSandbox(ext.Source.PackageID)
+	if err != nil {
+		return err
+	}
+	return sandbox.CheckHTTPServerPermission()
+}
+
+// JsHTTPRegister 为扩展注册路由，handler 在扩展所在的事件循环中执行。
+// ext 需要是实际注册的扩展，来自脚本时先用 jsOwnExt 确认归属
+func (d *Dice) JsHTTPRegister(ext *ExtInfo, method, p string, handler goja.Callable, auth bool) error {
+	if ext == nil || ext.dice == nil {
+		return errors.New("请先完成此扩展的注册")
+	}
+	if handler == nil {
+		return errors.New("处理函数不能为空")
+	}
+	if err := d.jsCheckHTTPServerPermission(ext); err != nil {
+		return err
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" || method == "ALL" {
+		method = "*"
+	}
+	if d.JsHTTPRouter == nil {
+		return ErrJsNotEnabled
+	}
+	d.JsHTTPRouter.add(&JsHTTPRoute{ExtName: ext.Name, Method: method, Path: p, Auth: auth, handler: handler, ext: ext})
+	return nil
+}
+
+// JsHTTPRegisterStatic 将扩展包安装目录下的 dir 挂载到 prefix，只允许扩展包内的脚本使用
+func (d *Dice) JsHTTPRegisterStatic(ext *ExtInfo, prefix, dir string, auth bool) error {
+	if ext == nil || ext.dice == nil {
+		return errors.New("请先完成此扩展的注册")
+	}
+	if err := d.jsCheckHTTPServerPermission(ext); err != nil {
+		return err
+	}
+	sandbox, err := d.PackageManager.GetSandbox(ext.Source.PackageID)
+	if err != nil {
+		return err
+	}
+	base, err := filepath.Abs(sandbox.BasePath)
+	if err != nil {
+		return err
+	}
+	root := filepath.Join(base, filepath.FromSlash(dir))
+	if rel, err := filepath.Rel(base, root); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("静态目录超出扩展包范围: %s", dir)
+	}
+	if d.JsHTTPRouter == nil {
+		return ErrJsNotEnabled
+	}
+	d.JsHTTPRouter.add(&JsHTTPRoute{
+		ExtName: ext.Name, Method: http.MethodGet, Path: path.Join("/", prefix, "*"), Auth: auth, Static: root, ext: ext,
+	})
+	return nil
+}
+
+// jsHTTPRouteActive 路由所属的扩展仍在运行：JS 已启用、脚本未被禁用且扩展没有被卸载或替换
+func (d *Dice) jsHTTPRouteActive(route *JsHTTPRoute) bool {
+	if !d.Config.JsEnable || d.ExtLoopManager == nil || d.JsExtRegistry == nil {
+		return false
+	}
+	ext, ok := d.JsExtRegistry.Load(route.ExtName)
+	return ok && ext == route.ext && ext.Source != nil && ext.Source.Enable
+}
+
+// JsHTTPServe 处理匹配到的请求：静态路由直接读文件，其余交给脚本
+func (d *Dice) JsHTTPServe(route *JsHTTPRoute, params map[string]string, user string, w http.ResponseWriter, r *http.Request) {
+	if !d.jsHTTPRouteActive(route) {
+		http.Error(w, "扩展未启用", http.StatusServiceUnavailable)
+		return
+	}
+	if route.Static != "" {
+		w.Header().Set("Content-Security-Policy", jsHTTPSandboxPolicy)
+		serveJsHTTPStatic(route.Static, params["*"], w, r)
+		return
+	}
+	loop, err := d.ExtLoopManager.GetLoop(route.ext.JSLoopVersion)
+	if err != nil {
+		http.Error(w, "扩展运行环境已经过期", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, jsHTTPBodyLimit))
+	if err != nil {
+		http.Error(w, "读取请求体失败", http.StatusBadRequest)
+		return
+	}
+	req := &JsHTTPRequest{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Params:     params,
+		Query:      map[string]string{},
+		Headers:    map[string]string{},
+		Body:       string(body),
+		RemoteAddr: r.RemoteAddr,
+		User:       user,
+	}
+	for k, v := range r.URL.Query() {
+		if k == "token" {
+			continue
+		}
+		req.Query[k] = v[0]
+	}
+	for k, v := range r.Header {
+		k = strings.ToLower(k)
+		if jsHTTPHiddenHeaders[k] {
+			continue
+		}
+		req.Headers[k] = v[0]
+	}
+
+	res := newJsHTTPResponse()
+	failed := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+	}
+	if !loop.RunOnLoop(func(vm *goja.Runtime) {
+		defer func() {
+			if r := recover(); r != nil {
+				d.Logger.Errorf("扩展<%s>处理HTTP请求异常: %v", route.ExtName, r)
+				fail(fmt.Errorf("%v", r))
+			}
+		}()
+		var ret goja.Value
+		var callErr error
+		err := d.JsRunGuarded(vm, route.ext, func() {
+			ret, callErr = route.handler(goja.Undefined(), vm.ToValue(req), vm.ToValue(res))
+			var ie *goja.InterruptedError
+			if errors.As(callErr, &ie) {
+				panic(ie)
+			}
+		})
+		if err == nil {
+			err = callErr
+		}
+		if err != nil {
+			d.Logger.Errorf("扩展<%s>处理HTTP请求异常: %v", route.ExtName, err)
+			fail(err)
+			return
+		}
+		if res.isSent() || ret == nil || goja.IsUndefined(ret) {
+			// 未返回值也未发送时，等待回调中调用 res.send
+			return
+		}
+		if _, ok := ret.Export().(*goja.Promise); !ok {
+			if err := res.Send(ret); err != nil {
+				fail(err)
+			}
+			return
+		}
+		obj := ret.ToObject(vm)
+		then, _ := goja.AssertFunction(obj.Get("then"))
+		_, _ = then(obj, vm.ToValue(func(v goja.Value) {
+			if !res.isSent() {
+				if err := res.Send(v); err != nil {
+					fail(err)
+				}
+			}
+		}), vm.ToValue(func(v goja.Value) {
+			d.Logger.Errorf("扩展<%s>处理HTTP请求异常: %v", route.ExtName, v)
+			fail(fmt.Errorf("%v", v))
+		}))
+	}) {
+		http.Error(w, "扩展运行环境已经过期", http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case <-res.done:
+		res.writeTo(w)
+	case err := <-failed:
+		if text := jsTimeoutReplyText(err); text != "" {
+			http.Error(w, text, http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "扩展处理请求出错: "+err.Error(), http.StatusInternalServerError)
+	case <-time.After(jsHTTPResponseTimeout):
+		http.Error(w, "扩展响应超时", http.StatusGatewayTimeout)
+	case <-r.Context().Done():
+	}
+}
+
+// serveJsHTTPStatic 发送 root 下的文件，目录时尝试 index.html
+func serveJsHTTPStatic(root, name string, w http.ResponseWriter, r *http.Request) {
+	full := filepath.Join(root, filepath.FromSlash(path.Clean("/"+name)))
+	info, err := os.Stat(full)
+	if err == nil && info.IsDir() {
+		full = filepath.Join(full, "index.html")
+		info, err = os.Stat(full)
+	}
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -2,6 +2,9 @@ package dice
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -267,3 +270,76 @@ func sameScriptInfo(a *JsScriptInfo, b *JsScriptInfo) bool {
 	}
 	return true
 }
+
+func TestJsPackageScripts(t *testing.T) {
+	base := filepath.FromSlash("cache/pkg/scripts")
+	var files []PackageContentFile
+	for _, p := range []string{
+		"single.js",
+		"readme.md",
+		"proj/package.json",
+		"proj/src/index.ts",
+		"proj/node_modules/lib/package.json",
+		"proj/node_modules/lib/index.js",
+		"proj/.build/index.js",
+	} {
+		files = append(files, PackageContentFile{
+			PackageID:   "a/b",
+			Path:        filepath.Join(base, filepath.FromSlash(p)),
+			PackagePath: "scripts/" + p,
+		})
+	}
+
+	scripts, projects := jsPackageScripts(files)
+	if len(scripts) != 1 || scripts[0].PackagePath != "scripts/single.js" {
+		t.Fatalf("scripts = %+v", scripts)
+	}
+	want := []PackageContentFile{{PackageID: "a/b", Path: filepath.Join(base, "proj"), PackagePath: "scripts/proj"}}
+	if !reflect.DeepEqual(projects, want) {
+		t.Fatalf("projects = %+v", projects)
+	}
+}
+
+func TestJsBundleProject_ConfinedToProject(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		fn := filepath.Join(root, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(fn), 0o755)
+		if err := os.WriteFile(fn, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("secret.json", `{"token": "host-secret"}`)
+	write("node_modules/outer/index.js", `module.exports = 'host-secret';`)
+	write("proj/node_modules/inner/index.js", `module.exports = 'ok';`)
+	dir := filepath.Join(root, "proj")
+
+	cases := []struct {
+		main, index string
+		ok          bool
+	}{
+		{"", `console.log(require('inner'));`, true},
+		{"../secret.json", ``, false},
+		{filepath.Join(root, "secret.json"), ``, false},
+		{"", `console.log(require('../secret.json'));`, false},
+		{"", `console.log(require('outer'));`, false},
+	}
+	for _, c := range cases {
+		manifest := `{"name": "p"}`
+		if c.main != "" {
+			manifest = fmt.Sprintf(`{"name": "p", "main": %q}`, c.main)
+		}
+		write("proj/package.json", manifest)
+		write("proj/index.js", c.index)
+		out, err := JsBundleProject(dir)
+		if (err == nil) != c.ok {
+			t.Fatalf("main=%q index=%q: err = %v", c.main, c.index, err)
+		}
+		if err == nil {
+			data, _ := os.ReadFile(out)
+			if strings.Contains(string(data), "host-secret") {
+				t.Fatalf("bundle contains host file: %s", data)
+			}
+		}
+	}
+}
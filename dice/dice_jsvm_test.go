@@ -1,9 +1,20 @@
 package dice //nolint:testpackage
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dop251/goja"
 	"go.uber.org/zap"
+
+	"sealdice-core/dice/sealpack"
 )
 
 func TestJsInit_WhenExtLoopManagerNil_DoesNotPanic(t *testing.T) {
@@ -34,6 +45,16 @@ func TestJsInit_WhenExtLoopManagerNil_DoesNotPanic(t *testing.T) {
 			d.JsScriptCron = nil
 		}
 		if d.ExtLoopManager != nil {
+			// loop 在后台协程中启动，尚未运行时 Terminate 不会生效，先等它跑起来
+			if loop := d.ExtLoopManager.GetWebLoop(); loop != nil {
+				started := make(chan struct{})
+				if loop.RunOnLoop(func(*goja.Runtime) { close(started) }) {
+					select {
+					case <-started:
+					case <-time.After(5 * time.Second):
+					}
+				}
+			}
 			d.ExtLoopManager.SetLoop(nil)
 		}
 	}()
@@ -47,3 +68,534 @@ func TestJsInit_WhenExtLoopManagerNil_DoesNotPanic(t *testing.T) {
 		t.Fatalf("expected JsEnable to be true after JsInit")
 	}
 }
+
+func TestJsRunGuarded_InterruptsAndAutoDisables(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	d.JsWatchdog = NewJsWatchdog()
+	d.Config.JsExecTimeout = 50
+	d.Config.JsTimeoutDisableCount = 2
+	info := &JsScriptInfo{Name: "死循环", Enable: true}
+	d.JsScriptList = []*JsScriptInfo{info}
+	ext := &ExtInfo{Name: "loop", IsJsExt: true, Source: info}
+
+	vm := goja.New()
+	var spin, quick func()
+	if _, err := vm.RunString(`function spin() { while (true) {} } function quick() { return 1 }`); err != nil {
+		t.Fatalf("RunString: %v", err)
+	}
+	_ = vm.ExportTo(vm.Get("spin"), &spin)
+	_ = vm.ExportTo(vm.Get("quick"), &quick)
+
+	if err := d.JsRunGuarded(vm, ext, quick); err != nil {
+		t.Fatalf("quick call error = %v", err)
+	}
+	var te *JsTimeoutError
+	err := d.JsRunGuarded(vm, ext, spin)
+	if !errors.As(err, &te) || te.Script != "死循环" || te.Disabled {
+		t.Fatalf("first timeout = %v", err)
+	}
+	// 中断后 vm 仍可继续使用
+	if err = d.JsRunGuarded(vm, ext, quick); err != nil {
+		t.Fatalf("call after interrupt error = %v", err)
+	}
+	err = d.JsRunGuarded(vm, ext, spin)
+	if !errors.As(err, &te) || !te.Disabled || !strings.Contains(jsTimeoutReplyText(err), "自动禁用") {
+		t.Fatalf("second timeout = %v", err)
+	}
+	if info.Enable || !d.Config.DisabledJsScripts["死循环"] {
+		t.Fatalf("script should be disabled, enable=%v", info.Enable)
+	}
+	if err = d.JsRunGuarded(vm, ext, quick); !errors.Is(err, ErrJsScriptAutoDisabled) {
+		t.Fatalf("call after auto disable error = %v", err)
+	}
+
+	stats := d.JsWatchdog.Stats()
+	if len(stats) != 1 || stats[0].Calls != 4 || stats[0].Timeouts != 2 || stats[0].CPUTime < 100 || !stats[0].AutoDisabled {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	JsEnable(d, "死循环")
+	if err = d.JsRunGuarded(vm, ext, quick); err != nil {
+		t.Fatalf("call after JsEnable error = %v", err)
+	}
+}
+
+func TestJsRunGuarded_Nested(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	d.JsWatchdog = NewJsWatchdog()
+	d.Config.JsExecTimeout = 50
+	outer := &ExtInfo{Name: "outer", IsJsExt: true, Source: &JsScriptInfo{Name: "外层", Enable: true}}
+	inner := &ExtInfo{Name: "inner", IsJsExt: true, Source: &JsScriptInfo{Name: "内层", Enable: true}}
+
+	vm := goja.New()
+	var spin, quick func()
+	if _, err := vm.RunString(`function spin() { while (true) {} } function quick() { return 1 }`); err != nil {
+		t.Fatalf("RunString: %v", err)
+	}
+	_ = vm.ExportTo(vm.Get("spin"), &spin)
+	_ = vm.ExportTo(vm.Get("quick"), &quick)
+
+	// 内层不单独计时，超时由最外层处理
+	var innerErr error
+	var te *JsTimeoutError
+	err := d.JsRunGuarded(vm, outer, func() {
+		innerErr = d.JsRunGuarded(vm, inner, spin)
+	})
+	if !errors.As(err, &te) || te.Script != "外层" || innerErr != nil {
+		t.Fatalf("nested timeout = %v, inner = %v", err, innerErr)
+	}
+
+	// 内层返回后外层仍在计时，之后不应残留中断
+	if err = d.JsRunGuarded(vm, outer, func() {
+		_ = d.JsRunGuarded(vm, inner, quick)
+		quick()
+	}); err != nil {
+		t.Fatalf("nested quick error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err = d.JsRunGuarded(vm, outer, quick); err != nil {
+		t.Fatalf("call after nested error = %v", err)
+	}
+	if len(d.JsWatchdog.running) != 0 {
+		t.Fatalf("running = %v", d.JsWatchdog.running)
+	}
+}
+
+func TestJsRunGuarded_AsyncCallbacks(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	d.JsBuiltinDigestSet = map[string]bool{}
+	d.JsInit()
+	defer func() {
+		d.JsScriptCron.Stop()
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+	d.Config.JsExecTimeout = 50
+	d.Config.JsTimeoutDisableCount = 0
+
+	fn := filepath.Join(t.TempDir(), "spin.js")
+	_ = os.WriteFile(fn, []byte(`// ==UserScript==
+// @name spin
+// @author tester
+// @version 1.0.0
+// ==/UserScript==
+let ext = seal.ext.new('spin', 'tester', '1.0.0');
+seal.ext.register(ext);
+globalThis.hits = [];
+setTimeout(() => { hits.push('timeout'); while (true) {} }, 10);
+setTimeout(() => Promise.resolve().then(() => { hits.push('then'); while (true) {} }), 20);
+seal.ext.registerTask(ext, 'cron', '@every 1s', () => { hits.push('task'); while (true) {} });
+`), 0o644)
+	data, _ := os.ReadFile(fn)
+	jsInfo, err := d.JsParseMeta(fn, time.Now(), data, false)
+	if err != nil {
+		t.Fatalf("JsParseMeta: %v", err)
+	}
+	d.JsLoadScriptRaw(jsInfo)
+
+	// 每个回调都被中断后事件循环才能继续执行后面的回调
+	loop := d.ExtLoopManager.GetWebLoop()
+	var hits string
+	for range 40 {
+		time.Sleep(100 * time.Millisecond)
+		got := make(chan string, 1)
+		loop.RunOnLoop(func(vm *goja.Runtime) { got <- vm.Get("hits").String() })
+		select {
+		case hits = <-got:
+		case <-time.After(time.Second):
+			t.Fatal("event loop is blocked")
+		}
+		if strings.Contains(hits, "task") {
+			break
+		}
+	}
+	if hits != "timeout,then,task" {
+		t.Fatalf("hits = %q", hits)
+	}
+	stats := d.JsWatchdog.Stats()
+	if len(stats) != 1 || stats[0].Name != "spin" || stats[0].Timeouts < 3 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestJsReloadScript_KeepsOtherScripts(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	d.JsBuiltinDigestSet = map[string]bool{}
+	d.JsInit()
+	defer func() {
+		d.JsScriptCron.Stop()
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+
+	scriptText := func(name, version, body string) string {
+		return "// ==UserScript==\n// @name " + name + "\n// @author tester\n// @version " + version + "\n// ==/UserScript==\n" +
+			"let ext = seal.ext.new('" + name + "', 'tester', '" + version + "');\n" +
+			"seal.ext.register(ext);\n" + body + "\n"
+	}
+	dir := t.TempDir()
+	fnA := filepath.Join(dir, "a.js")
+	fnB := filepath.Join(dir, "b.js")
+	_ = os.WriteFile(fnA, []byte(scriptText("extA", "1.0.0", "seal.ext.registerStringConfig(ext, 'greet', 'hi', '');")), 0o644)
+	_ = os.WriteFile(fnB, []byte(scriptText("extB", "1.0.0", "globalThis.bLoaded = (globalThis.bLoaded || 0) + 1;")), 0o644)
+	for _, fn := range []string{fnA, fnB} {
+		data, _ := os.ReadFile(fn)
+		jsInfo, err := d.JsParseMeta(fn, time.Now(), data, false)
+		if err != nil {
+			t.Fatalf("JsParseMeta(%s): %v", fn, err)
+		}
+		d.JsLoadScriptRaw(jsInfo)
+	}
+	realExt := func(name string) *ExtInfo {
+		ext, _ := d.JsExtRegistry.Load(name)
+		return ext
+	}
+	extB := realExt("extB")
+	if realExt("extA") == nil || extB == nil {
+		t.Fatal("scripts not loaded")
+	}
+	if err := d.ConfigManager.SetConfig("extA", "greet", "hello"); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+
+	_ = os.WriteFile(fnA, []byte(scriptText("extA", "2.0.0", "seal.ext.registerStringConfig(ext, 'greet', 'hi', '');")), 0o644)
+	if err := d.JsReloadScript("extA"); err != nil {
+		t.Fatalf("JsReloadScript() error = %v", err)
+	}
+	if ext := realExt("extA"); ext == nil || ext.Version != "2.0.0" {
+		t.Fatalf("extA after reload = %+v", ext)
+	}
+	if realExt("extB") != extB {
+		t.Fatal("extB should not be touched")
+	}
+	if c := d.ConfigManager.getConfig("extA", "greet"); c == nil || c.Value != "hello" || c.Deprecated {
+		t.Fatalf("config after reload = %+v", c)
+	}
+	if len(d.JsScriptList) != 2 || d.JsScriptList[0].Version != "2.0.0" {
+		t.Fatalf("JsScriptList = %+v", d.JsScriptList)
+	}
+
+	// 禁用立即卸载，启用立即加载，均不重建运行环境
+	loop := d.ExtLoopManager.GetWebLoop()
+	JsDisable(d, "extB")
+	if realExt("extB") != nil {
+		t.Fatal("extB should be unloaded after JsDisable")
+	}
+	JsEnable(d, "extB")
+	if realExt("extB") == nil || d.ExtLoopManager.GetWebLoop() != loop {
+		t.Fatal("extB should be loaded on the same loop after JsEnable")
+	}
+	loaded := make(chan int64, 1)
+	loop.RunOnLoop(func(vm *goja.Runtime) { loaded <- vm.Get("bLoaded").ToInteger() })
+	if n := <-loaded; n != 2 {
+		t.Fatalf("bLoaded = %d, want 2", n)
+	}
+
+	// 重载时脚本的顶层代码同样受看门狗限时
+	d.Config.JsExecTimeout = 50
+	_ = os.WriteFile(fnB, []byte(scriptText("extB", "1.0.1", "while (true) {}")), 0o644)
+	if err := d.JsReloadScript("extB"); err == nil || !strings.Contains(err.Error(), "已被中断") {
+		t.Fatalf("JsReloadScript() with endless loop error = %v", err)
+	}
+	loop.RunOnLoop(func(vm *goja.Runtime) { loaded <- vm.Get("bLoaded").ToInteger() })
+	if n := <-loaded; n != 2 {
+		t.Fatalf("bLoaded after interrupted reload = %d, want 2", n)
+	}
+
+	if err := d.JsReloadScript("不存在"); !errors.Is(err, ErrJsScriptNotFound) {
+		t.Fatalf("JsReloadScript() missing error = %v", err)
+	}
+}
+
+func TestJsUnloadScript_RemovesRules(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	d.JsBuiltinDigestSet = map[string]bool{}
+	d.JsInit()
+	defer func() {
+		d.JsScriptCron.Stop()
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+	builtinCoc, _ := d.GameSystemMap.Load("coc7")
+
+	fn := filepath.Join(t.TempDir(), "rules.js")
+	_ = os.WriteFile(fn, []byte(`// ==UserScript==
+// @name rules
+// @author tester
+// @version 1.0.0
+// ==/UserScript==
+let ext = seal.ext.new('rules', 'tester', '1.0.0');
+seal.ext.register(ext);
+seal.gameSystem.newTemplate(JSON.stringify({ name: 'testsys', fullName: '测试规则' }));
+seal.gameSystem.newTemplate(JSON.stringify({ name: 'coc7', fullName: '改版coc' }));
+let rule = seal.coc.newRule();
+rule.index = 42;
+rule.key = 'test';
+rule.name = '测试';
+seal.coc.registerRule(rule);
+`), 0o644)
+	data, _ := os.ReadFile(fn)
+	jsInfo, err := d.JsParseMeta(fn, time.Now(), data, false)
+	if err != nil {
+		t.Fatalf("JsParseMeta: %v", err)
+	}
+	d.JsLoadScriptRaw(jsInfo)
+	if tmpl, ok := d.GameSystemMap.Load("coc7"); !ok || tmpl == builtinCoc || !d.GameSystemMap.Exists("testsys") || d.CocExtraRules[42] == nil {
+		t.Fatalf("rules not registered, err = %q", jsInfo.ErrText)
+	}
+
+	JsDisable(d, "rules")
+	if d.GameSystemMap.Exists("testsys") || d.CocExtraRules[42] != nil {
+		t.Fatal("rules should be removed after JsDisable")
+	}
+	// 被脚本覆盖的模板恢复为原来的模板
+	if tmpl, _ := d.GameSystemMap.Load("coc7"); tmpl != builtinCoc {
+		t.Fatal("coc7 template should be restored")
+	}
+}
+
+func TestJsHTTPRoute(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	d.JsBuiltinDigestSet = map[string]bool{}
+	d.JsInit()
+	defer func() {
+		d.JsScriptCron.Stop()
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+
+	fn := filepath.Join(t.TempDir(), "web.js")
+	_ = os.WriteFile(fn, []byte(`// ==UserScript==
+// @name web
+// @author tester
+// @version 1.0.0
+// ==/UserScript==
+let ext = seal.ext.new('web', 'tester', '1.0.0');
+seal.ext.register(ext);
+seal.http.route(ext, 'GET', '/hello/:name', (req, res) => 'hello ' + req.params.name + req.query.mark);
+seal.http.route(ext, 'POST', '/echo', async (req) => ({ got: req.json().n + 1 }));
+seal.http.route(ext, 'GET', '/later', (req, res) => { setTimeout(() => res.status(201).send('done'), 10); });
+seal.http.route(ext, 'GET', '/admin', (req, res) => res.json({ user: req.user }), { auth: true });
+seal.http.route(ext, 'GET', '/boom', () => { throw new Error('boom'); });
+seal.http.route(ext, 'GET', '/peek', (req) => ({ query: req.query, headers: req.headers }));
+`), 0o644)
+	// 只有声明了 http_server 权限的扩展包可以注册路由
+	d.PackageManager = NewPackageManager(d)
+	d.PackageManager.packages = map[string]*sealpack.Instance{}
+	for id, httpServer := range map[string]bool{"tester/web": true, "tester/evil": true, "tester/plain": false} {
+		manifest := &sealpack.Manifest{}
+		manifest.Package.ID = id
+		manifest.Permissions.HTTPServer = httpServer
+		d.PackageManager.packages[id] = &sealpack.Instance{Manifest: manifest}
+	}
+	loadScript := func(name, pkgID, body string) *JsScriptInfo {
+		t.Helper()
+		fn := filepath.Join(t.TempDir(), name+".js")
+		_ = os.WriteFile(fn, []byte("// ==UserScript==\n// @name "+name+"\n// @author tester\n// @version 1.0.0\n// ==/UserScript==\n"+body), 0o644)
+		data, _ := os.ReadFile(fn)
+		jsInfo, err := d.JsParseMeta(fn, time.Now(), data, false)
+		if err != nil {
+			t.Fatalf("JsParseMeta: %v", err)
+		}
+		jsInfo.PackageID = pkgID
+		d.JsLoadScriptRaw(jsInfo)
+		return jsInfo
+	}
+	data, _ := os.ReadFile(fn)
+	jsInfo, err := d.JsParseMeta(fn, time.Now(), data, false)
+	if err != nil {
+		t.Fatalf("JsParseMeta: %v", err)
+	}
+	jsInfo.PackageID = "tester/web"
+	d.JsLoadScriptRaw(jsInfo)
+	if jsInfo.ErrText != "" {
+		t.Fatalf("load web.js: %s", jsInfo.ErrText)
+	}
+
+	serve := func(method, target, body, user string) *httptest.ResponseRecorder {
+		t.Helper()
+		route, params := d.JsHTTPRouter.Match("web", method, strings.SplitN(target, "?", 2)[0])
+		if route == nil {
+			t.Fatalf("no route for %s %s", method, target)
+		}
+		w := httptest.NewRecorder()
+		d.JsHTTPServe(route, params, user, w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	if w := serve("GET", "/hello/alice?mark=!", "", ""); w.Code != 200 || w.Body.String() != "hello alice!" {
+		t.Fatalf("GET /hello = %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("POST", "/echo", `{"n":1}`, ""); w.Code != 200 || w.Body.String() != `{"got":2}` {
+		t.Fatalf("POST /echo = %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/later", "", ""); w.Code != 201 || w.Body.String() != "done" {
+		t.Fatalf("GET /later = %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/admin", "", "admin"); w.Code != 200 || w.Body.String() != `{"user":"admin"}` {
+		t.Fatalf("GET /admin = %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/boom", "", ""); w.Code != 500 {
+		t.Fatalf("GET /boom = %d %q", w.Code, w.Body.String())
+	}
+	// WebUI 的登录凭据不交给脚本，页面以沙箱方式提供
+	route, params := d.JsHTTPRouter.Match("web", "GET", "/peek")
+	peekReq := httptest.NewRequest("GET", "/peek?token=sdat_query&page=2", nil)
+	peekReq.Header.Set("token", "sdat_header")
+	peekReq.Header.Set("Authorization", "Bearer sdat_bearer")
+	peekReq.Header.Set("Cookie", "session=sdat_cookie")
+	peekReq.Header.Set("X-Mark", "ok")
+	w := httptest.NewRecorder()
+	d.JsHTTPServe(route, params, "admin", w, peekReq)
+	if w.Code != 200 || strings.Contains(w.Body.String(), "sdat_") || !strings.Contains(w.Body.String(), `"x-mark":"ok"`) || !strings.Contains(w.Body.String(), `"page":"2"`) {
+		t.Fatalf("GET /peek = %d %q", w.Code, w.Body.String())
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.HasPrefix(csp, "sandbox") {
+		t.Fatalf("Content-Security-Policy = %q", csp)
+	}
+	if route, _ := d.JsHTTPRouter.Match("web", "GET", "/admin"); route == nil || !route.Auth {
+		t.Fatalf("admin route = %+v", route)
+	}
+	if route, _ := d.JsHTTPRouter.Match("web", "DELETE", "/echo"); route != nil {
+		t.Fatal("method should not match")
+	}
+
+	routeBody := "let ext = seal.ext.new('%s', 'tester', '1.0.0');\nseal.ext.register(ext);\nseal.http.route(%s, 'GET', '/%s', () => 'hi');\n"
+	for _, c := range []struct{ name, pkgID, target, want string }{
+		{"loose", "", "ext", "http_server"},
+		{"plain", "tester/plain", "ext", "http_server"},
+		// 借用其他扩展的身份注册路由
+		{"evil", "tester/evil", "seal.ext.find('web')", "不属于当前脚本"},
+	} {
+		info := loadScript(c.name, c.pkgID, fmt.Sprintf(routeBody, c.name, c.target, c.name))
+		if !strings.Contains(info.ErrText, c.want) {
+			t.Fatalf("%s: ErrText = %q, want %q", c.name, info.ErrText, c.want)
+		}
+	}
+	if route, _ := d.JsHTTPRouter.Match("web", "GET", "/evil"); route != nil {
+		t.Fatal("route registered under another extension")
+	}
+
+	// 脚本被禁用后不再提供服务
+	route, params = d.JsHTTPRouter.Match("web", "GET", "/hello/alice")
+	jsInfo.Enable = false
+	w = httptest.NewRecorder()
+	d.JsHTTPServe(route, params, "", w, httptest.NewRequest("GET", "/hello/alice", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("disabled script served = %d %q", w.Code, w.Body.String())
+	}
+
+	d.JsUnloadScript(jsInfo)
+	if route, _ := d.JsHTTPRouter.Match("web", "GET", "/hello/alice"); route != nil {
+		t.Fatal("routes should be removed after unload")
+	}
+}
+
+func TestExtIPC(t *testing.T) {
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	d.JsBuiltinDigestSet = map[string]bool{}
+	d.JsInit()
+	defer func() {
+		d.JsScriptCron.Stop()
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+
+	// Go 内置扩展提供服务并订阅 JS 扩展的主题
+	bank := &ExtInfo{Name: "bank", dice: d}
+	_ = d.IPC.Provide(bank, "balance", func(caller string, payload any) (any, error) {
+		return map[string]any{"caller": caller, "user": payload}, nil
+	})
+	events := make(chan any, 1)
+	_ = d.IPC.Subscribe(bank, "shop", "sold", func(publisher string, data any) { events <- data })
+
+	fn := filepath.Join(t.TempDir(), "shop.js")
+	_ = os.WriteFile(fn, []byte(`// ==UserScript==
+// @name shop
+// @author tester
+// @version 1.0.0
+// ==/UserScript==
+let ext = seal.ext.new('shop', 'tester', '1.0.0');
+seal.ext.register(ext);
+seal.ipc.provide(ext, 'price', async (item, caller) => ({ item, price: 10, caller }));
+seal.ipc.call(ext, 'bank', 'balance', 'QQ:1').then((v) => { globalThis.balance = v.caller + ':' + v.user; });
+seal.ipc.call(ext, 'bank', 'missing').catch((e) => { globalThis.missing = String(e); });
+seal.ipc.publish(ext, 'sold', { item: 'sword' });
+`), 0o644)
+	data, _ := os.ReadFile(fn)
+	jsInfo, err := d.JsParseMeta(fn, time.Now(), data, false)
+	if err != nil {
+		t.Fatalf("JsParseMeta: %v", err)
+	}
+	d.JsLoadScriptRaw(jsInfo)
+
+	v, err := d.IPC.Call(bank, "shop", "price", "sword", time.Second)
+	if m, ok := v.(map[string]any); err != nil || !ok || m["price"] != int64(10) || m["caller"] != "bank" {
+		t.Fatalf("Call(shop.price) = %#v, %v", v, err)
+	}
+	select {
+	case got := <-events:
+		if m, ok := got.(map[string]any); !ok || m["item"] != "sword" {
+			t.Fatalf("sold event = %#v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("sold event not delivered")
+	}
+	// JS 侧的调用结果异步写回，轮询等待
+	var g [2]string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		globals := make(chan [2]string, 1)
+		d.ExtLoopManager.GetWebLoop().RunOnLoop(func(vm *goja.Runtime) {
+			globals <- [2]string{vm.Get("balance").String(), vm.Get("missing").String()}
+		})
+		if g = <-globals; g[0] == "shop:QQ:1" && strings.Contains(g[1], ErrIPCServiceNotFound.Error()) {
+			break
+		}
+	}
+	if g[0] != "shop:QQ:1" || !strings.Contains(g[1], ErrIPCServiceNotFound.Error()) {
+		t.Fatalf("js globals = %q", g)
+	}
+
+	// 不能借用其他扩展的身份提供服务或发起调用
+	for _, body := range []string{
+		"seal.ipc.provide(seal.ext.find('shop'), 'price', () => 0);",
+		"seal.ipc.call(seal.ext.find('shop'), 'bank', 'balance', 'QQ:2');",
+	} {
+		evilFn := filepath.Join(t.TempDir(), "evil.js")
+		_ = os.WriteFile(evilFn, []byte("// ==UserScript==\n// @name evil\n// @author tester\n// @version 1.0.0\n// ==/UserScript==\n"+body), 0o644)
+		data, _ = os.ReadFile(evilFn)
+		evil, err := d.JsParseMeta(evilFn, time.Now(), data, false)
+		if err != nil {
+			t.Fatalf("JsParseMeta: %v", err)
+		}
+		d.JsLoadScriptRaw(evil)
+		if !strings.Contains(evil.ErrText, "不属于当前脚本") {
+			t.Fatalf("impersonation %q ErrText = %q", body, evil.ErrText)
+		}
+		d.JsScriptList = d.JsScriptList[:len(d.JsScriptList)-1]
+	}
+	v, err = d.IPC.Call(bank, "shop", "price", "sword", time.Second)
+	if m, ok := v.(map[string]any); err != nil || !ok || m["price"] != int64(10) {
+		t.Fatalf("Call(shop.price) after impersonation = %#v, %v", v, err)
+	}
+
+	// 扩展包内的扩展需要声明 ipc 权限
+	pkgExt := &ExtInfo{Name: "pkg", dice: d, Source: &JsScriptInfo{Name: "pkg", PackageID: "tester/pkg"}}
+	if _, err = d.IPC.Call(pkgExt, "bank", "balance", nil, time.Second); err == nil {
+		t.Fatal("package extension without ipc permission should be rejected")
+	}
+
+	_ = d.IPC.Provide(&ExtInfo{Name: "slow", dice: d}, "wait", func(string, any) (any, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	if _, err = d.IPC.Call(bank, "slow", "wait", nil, 50*time.Millisecond); !errors.Is(err, ErrIPCTimeout) {
+		t.Fatalf("Call() timeout error = %v", err)
+	}
+
+	d.JsUnloadScript(jsInfo)
+	if _, err = d.IPC.Call(bank, "shop", "price", nil, time.Second); !errors.Is(err, ErrIPCServiceNotFound) {
+		t.Fatalf("Call() after unload error = %v", err)
+	}
+}
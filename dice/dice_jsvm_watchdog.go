@@ -0,0 +1,364 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/eventloop"
+)
+
+// ErrJsScriptAutoDisabled 脚本已因多次超时被自动禁用，不再执行
+var ErrJsScriptAutoDisabled = errors.New("脚本已因多次执行超时被自动禁用")
+
+// jsInterruptReason 看门狗中断 vm 时使用的标记，用于区分其他来源的中断
+var jsInterruptReason = &struct{ name string }{"js-watchdog"}
+
+// JsTimeoutError 脚本单次执行超过时限后被中断
+type JsTimeoutError struct {
+	Script   string
+	Budget   time.Duration
+	Timeouts int
+	Disabled bool // 本次超时后脚本被自动禁用
+}
+
+func (e *JsTimeoutError) Error() string {
+	return fmt.Sprintf("脚本<%s>执行超过%dms，已被中断(累计%d次)", e.Script, e.Budget.Milliseconds(), e.Timeouts)
+}
+
+// JsScriptStat 单个脚本的执行统计，按脚本名记录，重载后保留
+type JsScriptStat struct {
+	Name         string `json:"name"`
+	Calls        int64  `json:"calls"`        // 执行次数
+	CPUTime      int64  `json:"cpuTime"`      // 累计占用事件循环的时间，毫秒
+	MaxTime      int64  `json:"maxTime"`      // 单次最长耗时，毫秒
+	Timeouts     int    `json:"timeouts"`     // 超时次数
+	LastTimeout  int64  `json:"lastTimeout"`  // 最近一次超时的时间戳
+	AutoDisabled bool   `json:"autoDisabled"` // 是否因多次超时被自动禁用
+
+	cpuTime time.Duration
+	maxTime time.Duration
+}
+
+// JsWatchdog 记录各脚本的执行耗时与超时情况
+type JsWatchdog struct {
+	lock    sync.Mutex
+	stats   map[string]*JsScriptStat
+	running map[*goja.Runtime][]*ExtInfo // 各 vm 上嵌套执行中的扩展，只有最外层计时
+}
+
+func NewJsWatchdog() *JsWatchdog {
+	return &JsWatchdog{stats: map[string]*JsScriptStat{}, running: map[*goja.Runtime][]*ExtInfo{}}
+}
+
+// enter 进入一层 JsRunGuarded，返回进入前的层数
+func (w *JsWatchdog) enter(vm *goja.Runtime, ext *ExtInfo) int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n := len(w.running[vm])
+	w.running[vm] = append(w.running[vm], ext)
+	return n
+}
+
+func (w *JsWatchdog) leave(vm *goja.Runtime) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if n := len(w.running[vm]); n > 1 {
+		w.running[vm] = w.running[vm][:n-1]
+	} else {
+		delete(w.running, vm)
+	}
+}
+
+// current 返回 vm 上正在执行的最内层扩展
+func (w *JsWatchdog) current(vm *goja.Runtime) *ExtInfo {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if exts := w.running[vm]; len(exts) > 0 {
+		return exts[len(exts)-1]
+	}
+	return nil
+}
+
+func (w *JsWatchdog) getStat(name string) *JsScriptStat {
+	stat := w.stats[name]
+	if stat == nil {
+		stat = &JsScriptStat{Name: name}
+		w.stats[name] = stat
+	}
+	return stat
+}
+
+// record 累加一次执行，返回累计超时次数
+func (w *JsWatchdog) record(name string, elapsed time.Duration, timeout bool) int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	stat := w.getStat(name)
+	stat.Calls++
+	stat.cpuTime += elapsed
+	stat.maxTime = max(stat.maxTime, elapsed)
+	if timeout {
+		stat.Timeouts++
+		stat.LastTimeout = time.Now().Unix()
+	}
+	return stat.Timeouts
+}
+
+func (w *JsWatchdog) isAutoDisabled(name string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	stat := w.stats[name]
+	return stat != nil && stat.AutoDisabled
+}
+
+func (w *JsWatchdog) setAutoDisabled(name string, disabled bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	stat := w.getStat(name)
+	stat.AutoDisabled = disabled
+	if !disabled {
+		// 手动重新启用后重新计数
+		stat.Timeouts = 0
+	}
+}
+
+// Stats 返回各脚本统计的副本，按累计耗时降序
+func (w *JsWatchdog) Stats() []JsScriptStat {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	items := make([]JsScriptStat, 0, len(w.stats))
+	for _, stat := range w.stats {
+		item := *stat
+		item.CPUTime = stat.cpuTime.Milliseconds()
+		item.MaxTime = stat.maxTime.Milliseconds()
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].cpuTime != items[j].cpuTime {
+			return items[i].cpuTime > items[j].cpuTime
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// jsScriptName 取扩展所属的脚本名，找不到脚本信息时退回扩展名
+func jsScriptName(ext *ExtInfo) string {
+	if ext == nil {
+		return ""
+	}
+	if realExt := ext.GetRealExt(); realExt != nil {
+		ext = realExt
+	}
+	if ext.Source != nil {
+		return ext.Source.Name
+	}
+	return ext.Name
+}
+
+func isJsWatchdogInterrupt(r any) bool {
+	var ie *goja.InterruptedError
+	if err, ok := r.(error); ok && errors.As(err, &ie) {
+		return ie.Value() == jsInterruptReason
+	}
+	return false
+}
+
+// JsRunGuarded 在事件循环内执行 f，超过 JsExecTimeout 时中断 vm。
+// 超时返回 *JsTimeoutError，脚本已被自动禁用时不执行并返回 ErrJsScriptAutoDisabled，其余 panic 原样抛出。
+// 嵌套调用时只有最外层计时，超时计入最外层的脚本。
+func (d *Dice) JsRunGuarded(vm *goja.Runtime, ext *ExtInfo, f func()) (err error) {
+	w := d.JsWatchdog
+	if w == nil || vm == nil {
+		f()
+		return nil
+	}
+	name := jsScriptName(ext)
+	if name == "" {
+		name = "未知脚本"
+	}
+	if w.isAutoDisabled(name) {
+		return ErrJsScriptAutoDisabled
+	}
+	defer w.leave(vm)
+	if w.enter(vm, ext) > 0 {
+		// 嵌套调用(如脚本中触发了其他扩展的回调)由最外层负责计时和清除中断，
+		// 否则内层返回时会清掉外层已经发出的中断，外层的计时器也会在之后误触发
+		f()
+		return nil
+	}
+
+	budget := time.Duration(d.Config.JsExecTimeout) * time.Millisecond
+	var (
+		timerLock sync.Mutex
+		finished  bool
+		timer     *time.Timer
+	)
+	if budget > 0 {
+		timer = time.AfterFunc(budget, func() {
+			timerLock.Lock()
+			defer timerLock.Unlock()
+			if !finished {
+				vm.Interrupt(jsInterruptReason)
+			}
+		})
+	}
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		if timer != nil {
+			timer.Stop()
+			timerLock.Lock()
+			finished = true
+			timerLock.Unlock()
+			// 计时器可能在 f 返回后才触发，清掉残留的中断标记
+			vm.ClearInterrupt()
+		}
+
+		r := recover()
+		timeout := r != nil && isJsWatchdogInterrupt(r)
+		timeouts := w.record(name, elapsed, timeout)
+		if r != nil && !timeout {
+			panic(r)
+		}
+		if timeout {
+			err = d.jsOnTimeout(name, budget, timeouts)
+		}
+	}()
+
+	f()
+	return nil
+}
+
+// jsOnTimeout 记录超时，超时次数达到 JsTimeoutDisableCount 时自动禁用脚本
+func (d *Dice) jsOnTimeout(name string, budget time.Duration, timeouts int) error {
+	e := &JsTimeoutError{Script: name, Budget: budget, Timeouts: timeouts}
+	d.Logger.Warnf("脚本<%s>执行超过%dms被中断，累计%d次", name, budget.Milliseconds(), timeouts)
+
+	limit := d.Config.JsTimeoutDisableCount
+	if limit > 0 && timeouts >= limit {
+		e.Disabled = true
+		d.JsWatchdog.setAutoDisabled(name, true)
+		for _, jsInfo := range d.JsScripts() {
+			if jsInfo.Name == name {
+				jsInfo.ErrText = fmt.Sprintf("执行超时%d次，已被自动禁用", timeouts)
+			}
+		}
+		JsDisable(d, name)
+		d.Logger.Errorf("脚本<%s>执行超时%d次，已被自动禁用", name, timeouts)
+	}
+	return e
+}
+
+// jsTimeoutReplyText 生成给触发者的超时提示，不是超时错误时返回空串
+func jsTimeoutReplyText(err error) string {
+	var te *JsTimeoutError
+	if !errors.As(err, &te) {
+		return ""
+	}
+	text := fmt.Sprintf("扩展脚本<%s>执行超过%dms，已被中断，请反馈给该扩展的作者", te.Script, te.Budget.Milliseconds())
+	if te.Disabled {
+		text += "\n该脚本多次超时，已被自动禁用"
+	}
+	return text
+}
+
+// jsGuardCallback 包装脚本登记的异步回调，触发时以登记时正在执行的扩展(或正在加载的脚本)计时。
+// 回调由事件循环直接调用，其中产生的 promise 任务也在同一次计时内执行完
+func (d *Dice) jsGuardCallback(vm *goja.Runtime, fn goja.Callable) func(args ...goja.Value) {
+	var ext *ExtInfo
+	if d.JsWatchdog != nil {
+		ext = d.JsWatchdog.current(vm)
+	}
+	if ext == nil && d.JsLoadingScript != nil {
+		ext = &ExtInfo{Name: d.JsLoadingScript.Name, Source: d.JsLoadingScript}
+	}
+	return func(args ...goja.Value) {
+		_ = d.JsRunGuarded(vm, ext, func() {
+			_, err := fn(goja.Undefined(), args...)
+			if isJsWatchdogInterrupt(err) {
+				panic(err)
+			}
+			if err != nil {
+				d.Logger.Errorf("脚本异步回调执行失败: %v", err)
+			}
+		})
+	}
+}
+
+// jsGuardTimers 替换事件循环自带的定时器函数，使定时器回调受看门狗限制
+func (d *Dice) jsGuardTimers(vm *goja.Runtime, loop *eventloop.EventLoop) {
+	schedule := func(call goja.FunctionCall, argStart int) (func(*goja.Runtime), time.Duration, bool) {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			return nil, 0, false
+		}
+		var args []goja.Value
+		if len(call.Arguments) > argStart {
+			args = append(args, call.Arguments[argStart:]...)
+		}
+		cb := d.jsGuardCallback(vm, fn)
+		delay := time.Duration(call.Argument(1).ToInteger()) * time.Millisecond
+		return func(*goja.Runtime) { cb(args...) }, delay, true
+	}
+
+	_ = vm.Set("setTimeout", func(call goja.FunctionCall) goja.Value {
+		if f, delay, ok := schedule(call, 2); ok {
+			return vm.ToValue(loop.SetTimeout(f, delay))
+		}
+		return goja.Undefined()
+	})
+	_ = vm.Set("setInterval", func(call goja.FunctionCall) goja.Value {
+		if f, delay, ok := schedule(call, 2); ok {
+			return vm.ToValue(loop.SetInterval(f, delay))
+		}
+		return goja.Undefined()
+	})
+	_ = vm.Set("setImmediate", func(call goja.FunctionCall) goja.Value {
+		if f, _, ok := schedule(call, 1); ok {
+			return vm.ToValue(loop.SetTimeout(f, 0))
+		}
+		return goja.Undefined()
+	})
+	clearTimeout := func(t *eventloop.Timer) {
+		if t != nil {
+			loop.ClearTimeout(t)
+		}
+	}
+	_ = vm.Set("clearTimeout", clearTimeout)
+	_ = vm.Set("clearImmediate", clearTimeout)
+	_ = vm.Set("clearInterval", func(i *eventloop.Interval) {
+		if i != nil {
+			loop.ClearInterval(i)
+		}
+	})
+}
+
+// jsGuardFetch 让 fetch 的结果回到事件循环后再以发起请求的扩展计时处理，
+// 否则 then 中的代码会在没有看门狗的情况下执行
+func (d *Dice) jsGuardFetch(vm *goja.Runtime, loop *eventloop.EventLoop) {
+	request, ok := goja.AssertFunction(vm.Get("__fetch__"))
+	if !ok {
+		return
+	}
+	_ = vm.Set("__fetch__", func(call goja.FunctionCall) goja.Value {
+		args := append([]goja.Value{}, call.Arguments...)
+		if fn, ok := goja.AssertFunction(call.Argument(2)); ok {
+			cb := d.jsGuardCallback(vm, fn)
+			args[2] = vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				resp := append([]goja.Value{}, call.Arguments...)
+				loop.RunOnLoop(func(*goja.Runtime) { cb(resp...) })
+				return goja.Undefined()
+			})
+		}
+		ret, err := request(call.This, args...)
+		if err != nil {
+			panic(err)
+		}
+		return ret
+	})
+}
@@ -50,6 +50,10 @@ type DiceManager struct { //nolint:revive
 	AccessTokens   SyncMap[string, bool]
 	IsReady        bool
 
+	WebUIAccounts     []*WebUIAccount         // 多用户 WebUI 账号，为空时仅使用 UI 密码
+	AccessTokenOwners SyncMap[string, string] // 会话令牌 -> 账号名，不在其中的令牌属于内置管理员
+	webUIAccountsLock sync.RWMutex
+
 	AutoBackupEnable    bool
 	AutoBackupTime      string
 	AutoBackupSelection BackupSelection
@@ -96,6 +100,9 @@ type Configs struct { //nolint:revive
 	UIPasswordHash string   `yaml:"uiPasswordHash"`
 	AccessTokens   []string `yaml:"accessTokens"` //nolint:gosec
 
+	WebUIAccounts     []*WebUIAccount   `yaml:"webUIAccounts"`
+	AccessTokenOwners map[string]string `yaml:"accessTokenOwners"`
+
 	AutoBackupEnable    bool   `yaml:"autoBackupEnable"`
 	AutoBackupTime      string `yaml:"autoBackupTime"`
 	AutoBackupSelection uint64 `yaml:"autoBackupSelection"`
@@ -113,6 +120,17 @@ type Configs struct { //nolint:revive
 	ConfigVersion int `yaml:"configVersion"`
 }
 
+// diceManagerConfigVersion dice.yaml 的版本，9915 起扩展数据与扩展文档分开勾选备份
+const diceManagerConfigVersion = 9915
+
+// migrateBackupSelection 旧版设置中勾选了扩展的，同时备份扩展文档(docs.db)，保持升级前的备份内容
+func migrateBackupSelection(sel BackupSelection, version int) BackupSelection {
+	if version < 9915 && sel&BackupSelectionJS != 0 {
+		sel |= BackupSelectionExtDocs
+	}
+	return sel
+}
+
 func (dm *DiceManager) InitHelp() {
 	_ = dm.reloadHelp(false)
 }
@@ -163,6 +181,7 @@ func (dm *DiceManager) LoadDice() {
 	_ = os.MkdirAll(BackupDir, 0755)
 	_ = os.MkdirAll("./data/images", 0755)
 	_ = os.MkdirAll("./data/decks", 0755)
+	_ = os.MkdirAll("./data/tables", 0755)
 	_ = os.MkdirAll("./data/names", 0755)
 	_ = os.WriteFile("./data/images/sealdice.png", IconPNG, 0644)
 
@@ -205,7 +224,7 @@ func (dm *DiceManager) LoadDice() {
 
 	dm.AutoBackupTime = dc.AutoBackupTime
 	dm.AutoBackupEnable = dc.AutoBackupEnable
-	dm.AutoBackupSelection = BackupSelection(dc.AutoBackupSelection)
+	dm.AutoBackupSelection = migrateBackupSelection(BackupSelection(dc.AutoBackupSelection), dc.ConfigVersion)
 
 	if dc.AutoBackupTime == "" {
 		// 从旧版升级
@@ -222,6 +241,12 @@ func (dm *DiceManager) LoadDice() {
 	for _, i := range dc.AccessTokens {
 		dm.AccessTokens.Store(i, true)
 	}
+	for token, owner := range dc.AccessTokenOwners {
+		if dm.AccessTokens.Exists(token) {
+			dm.AccessTokenOwners.Store(token, owner)
+		}
+	}
+	dm.WebUIAccounts = dc.WebUIAccounts
 
 	for _, i := range dc.DiceConfigs {
 		newDice := new(Dice)
@@ -247,18 +272,27 @@ func (dm *DiceManager) Save() {
 	dc.BackupClean.Trigger = int(dm.BackupCleanTrigger)
 	dc.BackupClean.Cron = dm.BackupCleanCron
 	dc.ServiceName = dm.ServiceName
-	dc.ConfigVersion = 9914
+	dc.ConfigVersion = diceManagerConfigVersion
 
 	dm.AccessTokens.Range(func(k string, v bool) bool {
 		dc.AccessTokens = append(dc.AccessTokens, k)
 		return true
 	})
+	dc.AccessTokenOwners = map[string]string{}
+	dm.AccessTokenOwners.Range(func(k string, v string) bool {
+		dc.AccessTokenOwners[k] = v
+		return true
+	})
 
 	for _, i := range dm.Dice {
 		dc.DiceConfigs = append(dc.DiceConfigs, i.BaseConfig)
 	}
 
+	dm.webUIAccountsLock.RLock()
+	dc.WebUIAccounts = dm.WebUIAccounts
 	data, err := yaml.Marshal(dc) //nolint:gosec
+	dm.webUIAccountsLock.RUnlock()
+
 	if err == nil {
 		_ = os.WriteFile("./data/dice.yaml", data, 0644)
 	}
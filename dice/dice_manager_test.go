@@ -0,0 +1,22 @@
+package dice //nolint:testpackage
+
+import "testing"
+
+func TestMigrateBackupSelection(t *testing.T) {
+	cases := []struct {
+		sel     BackupSelection
+		version int
+		want    BackupSelection
+	}{
+		{BackupSelectionJS | BackupSelectionDecks, 9914, BackupSelectionJS | BackupSelectionDecks | BackupSelectionExtDocs},
+		{BackupSelectionDecks, 9914, BackupSelectionDecks},
+		{BackupSelectionJS, 0, BackupSelectionJS | BackupSelectionExtDocs},
+		// 新版本保存的设置中取消勾选扩展文档，不应再被改回来
+		{BackupSelectionJS, diceManagerConfigVersion, BackupSelectionJS},
+	}
+	for _, c := range cases {
+		if got := migrateBackupSelection(c.sel, c.version); got != c.want {
+			t.Errorf("migrateBackupSelection(%b, %d) = %b, want %b", c.sel, c.version, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+// NPC 角色库
+//
+// NPC 卡以群为单位保存: 卡片的 attrs_type 为 npc、owner_id 为群号，并绑定到虚拟用户 NPC:<名字> 上，
+// 因此可以像普通玩家的卡一样通过 AttrsManager.Load(群号, "NPC:<名字>") 读取。绑定记录的 owner_id 存放创建者。
+// 支持代骰的指令中写 @npc:<名字> 即以该 NPC 的身份执行，如 .ra @npc:守卫 侦查。
+// 只有创建者和群管理(权限40以上)可以修改 NPC 或以 NPC 身份掷骰。
+
+const npcUserIDPrefix = "NPC:"
+
+var npcMentionRe = regexp.MustCompile(`@(?i:npc)[:：](\S+)`)
+
+// npcUserID NPC 对应的虚拟用户ID
+func npcUserID(name string) string {
+	return npcUserIDPrefix + name
+}
+
+// npcNameFromUserID 从虚拟用户ID中取出 NPC 名字
+func npcNameFromUserID(userID string) (string, bool) {
+	return strings.CutPrefix(userID, npcUserIDPrefix)
+}
+
+func npcBindingId(groupId string, name string) string {
+	return fmt.Sprintf("%s-%s", groupId, npcUserID(name))
+}
+
+// NpcNew 在群内新建 NPC 卡
+func (am *AttrsManager) NpcNew(groupId string, name string, sheetType string, creator string) (*model.AttributesItemModel, error) {
+	json, err := ds.NewDictVal(nil).V().ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	item, err := service.AttrsNewItem(am.db, &model.AttributesItemModel{
+		Name:      name,
+		OwnerId:   groupId,
+		AttrsType: service.AttrsTypeNpc,
+		SheetType: sheetType,
+		Data:      json,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bindingId := npcBindingId(groupId, name)
+	if err = service.AttrsBindCharacter(am.db, item.Id, bindingId); err != nil {
+		return nil, err
+	}
+	if err = service.AttrsSetOwnerById(am.db, bindingId, am.UIDConvert(creator)); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// NpcIdGetByName 获取群内 NPC 卡的ID，不存在时返回空字符串
+func (am *AttrsManager) NpcIdGetByName(groupId string, name string) (string, error) {
+	return service.AttrsGetNpcIdByName(am.db, groupId, name)
+}
+
+// NpcList 获取群内的 NPC 卡列表
+func (am *AttrsManager) NpcList(groupId string) ([]*model.AttributesItemModel, error) {
+	return service.AttrsGetNpcListByGroupId(am.db, groupId)
+}
+
+// NpcCreator 获取 NPC 的创建者
+func (am *AttrsManager) NpcCreator(groupId string, name string) string {
+	item, err := service.AttrsGetById(am.db, npcBindingId(groupId, name))
+	if err != nil {
+		return ""
+	}
+	return item.OwnerId
+}
+
+// NpcDelete 删除群内的 NPC 卡及其绑定
+func (am *AttrsManager) NpcDelete(groupId string, name string) error {
+	id, err := am.NpcIdGetByName(groupId, name)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("NPC不存在: " + name)
+	}
+	if err = am.CharDelete(id); err != nil {
+		return err
+	}
+	return service.AttrsDeleteById(am.db, npcBindingId(groupId, name))
+}
+
+// npcCheckAccess 检查当前用户能否操作 NPC，不能时返回提示文本
+func npcCheckAccess(ctx *MsgContext, name string) string {
+	am := ctx.Dice.AttrsManager
+	id, err := am.NpcIdGetByName(ctx.Group.GroupID, name)
+	if err != nil {
+		return "读取NPC失败: " + err.Error()
+	}
+	if id == "" {
+		return fmt.Sprintf("当前群没有名为<%s>的NPC，可以使用 .npc new %s 创建", name, name)
+	}
+	if ctx.PrivilegeLevel < 40 && am.NpcCreator(ctx.Group.GroupID, name) != am.UIDConvert(ctx.Player.UserID) {
+		return fmt.Sprintf("只有NPC<%s>的创建者或群管理可以操作它", name)
+	}
+	return ""
+}
+
+// npcTakeMentions 从参数中取出 @npc:<名字>，返回其中的 NPC 名字
+func (cmdArgs *CmdArgs) npcTakeMentions() []string {
+	matches := npcMentionRe.FindAllStringSubmatch(cmdArgs.RawArgs, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, m[1])
+	}
+	cmdArgs.RawArgs = strings.TrimSpace(npcMentionRe.ReplaceAllString(cmdArgs.RawArgs, " "))
+	a := ArgsParse(cmdArgs.RawArgs)
+	cmdArgs.Args = a.Args
+	cmdArgs.CleanArgs = strings.TrimSpace(strings.Join(a.Args, " "))
+	return names
+}
+
+// npcApplyMentions 将指令中的 @npc:<名字> 转换为对 NPC 虚拟用户的@，无权操作时回复并返回 false
+func npcApplyMentions(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) bool {
+	if ctx.Group == nil || ctx.Dice.AttrsManager == nil {
+		return true
+	}
+	for _, name := range cmdArgs.npcTakeMentions() {
+		if text := npcCheckAccess(ctx, name); text != "" {
+			ReplyToSender(ctx, msg, text)
+			return false
+		}
+		cmdArgs.At = append(cmdArgs.At, &AtInfo{UserID: npcUserID(name)})
+	}
+	return true
+}
+
+// npcFindCmd 在当前群可用的指令中查找指定指令
+func npcFindCmd(ctx *MsgContext, name string) *CmdItemInfo {
+	if item := ctx.Dice.CmdMap[name]; item != nil {
+		return item
+	}
+	for _, wrapper := range ctx.Group.GetActivatedExtList(ctx.Dice) {
+		if item := wrapper.GetCmdMap()[name]; item != nil && !item.IsJsSolveFunc {
+			return item
+		}
+	}
+	return nil
+}
+
+// npcRunSt 以 NPC 的身份执行当前规则的 st 指令，prefix 会附加在回复开头
+func npcRunSt(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs, name string, args []string, prefix string) CmdExecuteResult {
+	item := npcFindCmd(ctx, "st")
+	if item == nil {
+		ReplyToSender(ctx, msg, prefix+"当前群没有可用的st指令，请先使用 .set 设置规则")
+		return CmdExecuteResult{Matched: true, Solved: true}
+	}
+	sub := *cmdArgs
+	sub.Command = "st"
+	sub.Args = args
+	sub.RawArgs = strings.Join(args, " ")
+	sub.CleanArgs = sub.RawArgs
+	sub.At = []*AtInfo{{UserID: npcUserID(name)}}
+	// 回复使用NPC的ctx，而它复制自当前ctx，借用代骰文本的位置放前缀
+	ctx.DelegateText = prefix
+	ret := item.Solve(ctx, msg, &sub)
+	ctx.DelegateText = ""
+	if ret.ShowHelp {
+		ret.ShowHelp = false
+		ReplyToSender(ctx, msg, item.Help)
+	}
+	return ret
+}
+
+func getCmdNpc() *CmdItemInfo {
+	helpNpc := ".npc new <名字> [<属性><值>...] // 新建NPC，可同时录入属性\n" +
+		".npc st <名字> <属性><值>... // 修改NPC属性，格式同.st\n" +
+		".npc st <名字> show // 查看NPC属性\n" +
+		".npc list // 列出当前群的NPC\n" +
+		".npc del <名字> // 删除NPC\n" +
+		"在支持代骰的指令中使用 @npc:<名字> 即以NPC身份执行，如 .ra @npc:守卫 侦查、.sc @npc:守卫 1/1d6"
+
+	return &CmdItemInfo{
+		Name:              "npc",
+		ShortHelp:         helpNpc,
+		Help:              "NPC管理:\n" + helpNpc,
+		DisabledInPrivate: true,
+		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
+			cmdArgs.ChopPrefixToArgsWith("new", "st", "list", "lst", "del", "rm")
+			am := ctx.Dice.AttrsManager
+			name := cmdArgs.GetArgN(2)
+
+			switch cmdArgs.GetArgN(1) {
+			case "list", "lst":
+				list := lo.Must(am.NpcList(ctx.Group.GroupID))
+				if len(list) == 0 {
+					ReplyToSender(ctx, msg, "当前群还没有NPC，可以使用 .npc new <名字> 创建")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				var lines []string
+				for idx, item := range list {
+					suffix := ""
+					if item.SheetType != "" {
+						suffix = " #" + item.SheetType
+					}
+					lines = append(lines, fmt.Sprintf("%2d %s%s", idx+1, item.Name, suffix))
+				}
+				ReplyToSender(ctx, msg, "当前群的NPC列表:\n"+strings.Join(lines, "\n"))
+				return CmdExecuteResult{Matched: true, Solved: true}
+
+			case "new":
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				if len(name) > 90 {
+					ReplyToSender(ctx, msg, "NPC名字过长")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if id, _ := am.NpcIdGetByName(ctx.Group.GroupID, name); id != "" {
+					ReplyToSender(ctx, msg, fmt.Sprintf("当前群已存在NPC<%s>", name))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				lo.Must(am.NpcNew(ctx.Group.GroupID, name, ctx.Group.System, ctx.Player.UserID))
+				text := fmt.Sprintf("已创建NPC<%s>", name)
+				if len(cmdArgs.Args) > 2 {
+					return npcRunSt(ctx, msg, cmdArgs, name, cmdArgs.Args[2:], text+"\n")
+				}
+				ReplyToSender(ctx, msg, text+fmt.Sprintf("，使用 .npc st %s <属性><值> 录入属性", name))
+				return CmdExecuteResult{Matched: true, Solved: true}
+
+			case "st":
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				if text := npcCheckAccess(ctx, name); text != "" {
+					ReplyToSender(ctx, msg, text)
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				args := cmdArgs.Args[2:]
+				if len(args) == 0 {
+					args = []string{"show"}
+				}
+				return npcRunSt(ctx, msg, cmdArgs, name, args, "")
+
+			case "del", "rm":
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				if text := npcCheckAccess(ctx, name); text != "" {
+					ReplyToSender(ctx, msg, text)
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if err := am.NpcDelete(ctx.Group.GroupID, name); err != nil {
+					ReplyToSender(ctx, msg, "删除NPC失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("已删除NPC<%s>", name))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+			return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+		},
+	}
+}
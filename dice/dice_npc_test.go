@@ -0,0 +1,82 @@
+//nolint:testpackage
+package dice
+
+import (
+	"strings"
+	"testing"
+
+	"sealdice-core/model"
+)
+
+func TestNpcTakeMentions(t *testing.T) {
+	cmdArgs := &CmdArgs{RawArgs: "困难侦查 @npc:守卫 --push"}
+	a := ArgsParse(cmdArgs.RawArgs)
+	cmdArgs.Args, cmdArgs.Kwargs = a.Args, a.Kwargs
+
+	names := cmdArgs.npcTakeMentions()
+	if len(names) != 1 || names[0] != "守卫" {
+		t.Fatalf("names = %v", names)
+	}
+	if cmdArgs.CleanArgs != "困难侦查" || cmdArgs.GetKwarg("push") == nil {
+		t.Fatalf("cmdArgs = %+v", cmdArgs)
+	}
+	if names = (&CmdArgs{RawArgs: "侦查 @某人"}).npcTakeMentions(); names != nil {
+		t.Fatalf("names = %v, want nil", names)
+	}
+}
+
+func TestNpcCommands(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	migrateTestTables(t, d, &model.AttributesItemModel{})
+
+	const groupID, kpID, playerID = "QQ-Group:444", "QQ:100", "QQ:200"
+	send := func(userID, text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg(groupID, userID, text))
+	}
+
+	if got := send(kpID, ".npc new 守卫 侦查60"); !strings.HasPrefix(got, "已创建NPC<守卫>\n") {
+		t.Fatalf(".npc new = %q", got)
+	}
+	if got := send(kpID, ".npc new 守卫"); !strings.Contains(got, "已存在") {
+		t.Fatalf("重复 .npc new = %q", got)
+	}
+	if got := send(kpID, ".npc list"); !strings.Contains(got, "守卫") {
+		t.Fatalf(".npc list = %q", got)
+	}
+
+	if got := send(kpID, ".ra @npc:守卫 侦查"); !strings.Contains(got, "守卫") || !strings.Contains(got, "/60") || strings.Contains(got, "代骰") {
+		t.Fatalf(".ra @npc:守卫 侦查 = %q", got)
+	}
+	if got := send(playerID, ".ra @npc:守卫 侦查"); !strings.Contains(got, "创建者") {
+		t.Fatalf("非创建者 .ra = %q", got)
+	}
+	if got := send(kpID, ".ra @npc:路人 侦查"); !strings.Contains(got, "没有名为<路人>的NPC") {
+		t.Fatalf("不存在的NPC .ra = %q", got)
+	}
+
+	send(kpID, ".npc st 守卫 侦查+10")
+	attrs, err := d.AttrsManager.Load(groupID, npcUserID("守卫"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if v := attrs.Load("侦查"); v == nil || v.ToString() != "70" {
+		t.Fatalf("侦查 = %v, want 70", v)
+	}
+	// 玩家自己的卡不受影响
+	own, _ := d.AttrsManager.Load(groupID, kpID)
+	if own.Load("侦查") != nil {
+		t.Fatalf("KP的卡被修改: %v", own.Load("侦查"))
+	}
+
+	if got := send(playerID, ".npc del 守卫"); !strings.Contains(got, "创建者") {
+		t.Fatalf("非创建者 .npc del = %q", got)
+	}
+	if got := send(kpID, ".npc del 守卫"); !strings.Contains(got, "已删除") {
+		t.Fatalf(".npc del = %q", got)
+	}
+	if got := send(kpID, ".npc list"); !strings.Contains(got, "还没有NPC") {
+		t.Fatalf("删除后 .npc list = %q", got)
+	}
+}
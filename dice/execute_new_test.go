@@ -25,7 +25,6 @@ import (
 	"time"
 
 	"github.com/panjf2000/ants/v2"
-	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gorm.io/gorm"
@@ -167,65 +166,10 @@ func newExecuteNewTestDice(t *testing.T) (*Dice, *EndPointInfo, *mockPlatformAda
 		t.Fatalf("newMockDatabaseOperator: %v", err)
 	}
 
-	// Minimal DiceManager – Cron is created but NOT started to avoid background
-	// goroutines that would trigger goleak.
-	dm := &DiceManager{Cron: cron.New()}
-
-	d := &Dice{
-		BaseConfig: BaseConfig{
-			DataDir: tmpDir,
-			Name:    "test",
-		},
-		Logger:        sealdiceLogger.M(),
-		LogWriter:     sealdiceLogger.NewUIWriter(),
-		DBOperator:    mockDB,
-		CmdMap:        CmdMapCls{},
-		ExtRegistry:   new(SyncMap[string, *ExtInfo]),
-		GameSystemMap: new(SyncMap[string, *GameSystemTemplate]),
-		DirtyGroups:   new(SyncMap[string, int64]),
-		CocExtraRules: map[int]*CocRuleInfo{},
-		// Cron is not started to keep the goroutine count predictable.
-		Cron:   cron.New(),
-		Parent: dm,
-	}
-	dm.Dice = []*Dice{d}
-
-	// Config – use defaults so CommandCompatibleMode, BanList, rate-limit
-	// settings, and other fields are properly initialised.
-	d.Config = NewConfig(d)
-	// Disable the per-message QQ sleep so tests are fast.
-	d.Config.MessageDelayRangeEnd = 0
-
-	// Command prefix used by CommandParseNew.
-	d.CommandPrefix = DefaultConfig.CommandPrefix
-
-	// IMSession
-	d.ImSession = &IMSession{}
-	d.ImSession.Parent = d
-	d.ImSession.ServiceAtNew = new(SyncMap[string, *GroupInfo])
-	d.ImSession.PendingQuits = new(SyncMap[string, *PendingQuitInfo])
-
-	// AttrsManager – starts one background goroutine; stopped in cleanup.
-	d.AttrsManager = &AttrsManager{}
-	d.AttrsManager.Init(d)
-
-	// ConfigManager
-	d.ConfigManager = NewConfigManager(filepath.Join(tmpDir, "configs", "plugin-configs.json"))
-	_ = d.ConfigManager.Load()
-
-	// Register all core commands and built-in extensions.
-	initVerify()
-	d.registerCoreCommands()
-	d.RegisterBuiltinExt()
-
-	// Text templates (setupBaseTextTemplate initialises d.TextMapRaw).
-	setupBaseTextTemplate(d)
-	// loadTextTemplate is a no-op when the file doesn't exist, which is fine.
-	loadTextTemplate(d, "configs/text-template.yaml")
-	d.GenerateTextMap()
-
-	d.ApplyExtDefaultSettings()
-	d.IsAlreadyLoadConfig = true
+	// Same construction as the extension harness: default config, core
+	// commands, built-in extensions and text templates. Cron is not started
+	// to keep the goroutine count predictable.
+	d := newOfflineDice(mockDB, tmpDir, "test")
 
 	// Mock platform adapter + endpoint
 	adapter := newMockPlatformAdapter()
@@ -262,6 +206,27 @@ func newExecuteNewTestDice(t *testing.T) (*Dice, *EndPointInfo, *mockPlatformAda
 	return d, ep, adapter, cleanup
 }
 
+// migrateTestTables creates the tables a test needs on top of the empty
+// database provided by newExecuteNewTestDice.
+func migrateTestTables(t *testing.T, d *Dice, models ...any) {
+	t.Helper()
+	if err := d.DBOperator.GetDataDB(constant.WRITE).AutoMigrate(models...); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+}
+
+// execTestMsg runs msg through ExecuteNew and returns the bot's reply,
+// failing the test when nothing is sent back in time.
+func execTestMsg(t *testing.T, d *Dice, ep *EndPointInfo, adapter *mockPlatformAdapter, msg *Message) string {
+	t.Helper()
+	d.ImSession.ExecuteNew(ep, msg)
+	reply, ok := adapter.waitForMsg(2 * time.Second)
+	if !ok {
+		t.Fatalf("timeout: expected a reply to %q", msg.Message)
+	}
+	return reply
+}
+
 // ---------------------------------------------------------------------------
 // Message helpers
 // ---------------------------------------------------------------------------
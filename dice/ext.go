@@ -321,32 +321,35 @@ func (i *ExtInfo) CallOnGroupLeave(d *Dice, ctx *MsgContext, event *events.Group
 }
 
 // callWithJsCheck 保留旧行为：JS 扩展需要切回事件循环，避免并发问题。
-func (i *ExtInfo) callWithJsCheck(d *Dice, f func()) {
+// 执行超时或脚本已被自动禁用时返回看门狗的错误，调用方可据此提示触发者。
+func (i *ExtInfo) callWithJsCheck(d *Dice, f func()) error {
 	if i.IsJsExt {
 		if d.Config.JsEnable {
 			loop, err := d.ExtLoopManager.GetLoop(i.JSLoopVersion)
 			if err != nil {
 				i.dice.Logger.Errorf("扩展<%s>运行环境已经过期: %v", i.Name, err)
-				return
+				return nil
 			}
+			var runErr error
 			waitRun := make(chan int, 1)
 			loop.RunOnLoop(func(vm *goja.Runtime) {
 				defer func() {
 					if r := recover(); r != nil {
-						d.Logger.Error("JS脚本异常:", r)
+						d.jsReportError("JS脚本异常: " + jsErrorText(r))
 					}
 					waitRun <- 1
 				}()
 
-				f()
+				runErr = d.JsRunGuarded(vm, i, f)
 			})
 			<-waitRun
-		} else {
-			d.Logger.Infof("当前已关闭js扩展<%v>", i.Name)
+			return runErr
 		}
+		d.Logger.Infof("当前已关闭js扩展<%v>", i.Name)
 	} else {
 		f()
 	}
+	return nil
 }
 
 // StorageInit 与旧版一致：使用互斥锁确保只初始化一次。
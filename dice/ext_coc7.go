@@ -71,6 +71,7 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 		".ra p2 <属性表达式> // 多个奖励骰或惩罚骰\n" +
 		".ra 3#p <属性表达式> // 多重检定\n" +
 		".ra <属性表达式> @某人 // 对某人做检定(使用他的属性)\n" +
+		".ra <属性表达式> --push/--luck // 孤注一掷或花费幸运的检定，成功时不标记成长\n" +
 		".rch/rah // 暗中检定，和检定指令用法相同"
 
 	cmdRc := &CmdItemInfo{
@@ -143,6 +144,9 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 				cocRule = 0
 			}
 
+			// 孤注一掷与花费幸运的检定不标记成长
+			growthExcluded := cmdArgs.GetKwarg("push") != nil || cmdArgs.GetKwarg("luck") != nil
+
 			var reason string
 			var commandInfoItems []interface{}
 			rollOne := func(manyTimes bool) *CmdExecuteResult {
@@ -213,6 +217,12 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 				var attrVal = int64(r2.Value.(ds.IntType))
 
 				successRank, criticalSuccessValue := ResultCheck(mctx, cocRule, outcome, attrVal, difficultyRequire)
+				if successRank >= max(difficultyRequire, 1) && !growthExcluded {
+					// 成功的技能检定自动标记成长
+					if name := cocGrowthSkillName(tmpl, expr2Text); name != "" {
+						cocGrowthMark(lo.Must(mctx.Dice.AttrsManager.LoadByCtx(mctx)), name)
+					}
+				}
 				// 根据难度需求，修改判定值
 				checkVal := attrVal
 				switch difficultyRequire {
@@ -682,7 +692,10 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 .en <技能名称>[<技能点数>] // 骰D100，若点数大于技能点数，属性=技能点数+1d10
 .en <技能名称>[<技能点数>] +<成功成长值> // 骰D100，若点数大于当前值，属性成长成功成长值点
 .en <技能名称>[<技能点数>] +<失败成长值>/<成功成长值> // 骰D100，若点数大于当前值，属性成长成功成长值点，否则增加失败
-.en <技能名称1> <技能名称2> // 批量技能成长，支持上述多种格式，复杂情况建议用|隔开每个技能`
+.en <技能名称1> <技能名称2> // 批量技能成长，支持上述多种格式，复杂情况建议用|隔开每个技能
+.en all // 对检定成功时自动标记的全部技能进行成长检定
+.en list [@某人] // 查看成长标记
+.en clear // 清除成长标记`
 
 	cmdEn := &CmdItemInfo{
 		Name:          "en",
@@ -694,11 +707,40 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
 			}
 
+			var growthMarks []string
+			subCmd := strings.ToLower(cmdArgs.GetArgN(1))
+			if subCmd == "all" || subCmd == "list" || subCmd == "clear" {
+				tctx := mctx
+				if subCmd == "list" {
+					// 允许KP查看他人的成长标记
+					tctx = GetCtxProxyFirst(mctx, cmdArgs)
+				}
+				attrs := lo.Must(tctx.Dice.AttrsManager.LoadByCtx(tctx))
+				growthMarks = cocGrowthMarksLoad(attrs)
+				VarSetValueInt64(tctx, "$t数量", int64(len(growthMarks)))
+				switch {
+				case len(growthMarks) == 0:
+					ReplyToSender(mctx, msg, DiceFormatTmpl(tctx, "COC:技能成长_无标记"))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				case subCmd == "list":
+					VarSetValueStr(tctx, "$t技能列表", strings.Join(growthMarks, "、"))
+					ReplyToSender(mctx, msg, DiceFormatTmpl(tctx, "COC:技能成长_标记列表"))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				case subCmd == "clear":
+					cocGrowthMarksStore(attrs, nil)
+					ReplyToSender(mctx, msg, DiceFormatTmpl(tctx, "COC:技能成长_标记清除"))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+			}
+
 			// .en [技能名称]([技能值])+(([失败成长值]/)[成功成长值])
 			// FIXME: 实在是被正则绕晕了，把多组和每组的正则分开了
 			re := regexp.MustCompile(`([a-zA-Z_\p{Han}]+)\s*(\d+)?\s*(\+\s*([-+\ddD]+\s*/)?\s*([-+\ddD]+))?[^|]*?`)
 			// 支持多组技能成长
 			skills := re.FindAllString(cmdArgs.CleanArgs, -1)
+			if subCmd == "all" {
+				skills = growthMarks
+			}
 
 			type enCheckResult struct {
 				valid         bool
@@ -832,6 +874,8 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 						checkResult.newVarValue = varValue + increment
 					}
 				}
+				// 已进行成长检定，移除该技能的成长标记
+				cocGrowthUnmark(lo.Must(mctx.Dice.AttrsManager.LoadByCtx(mctx)), tmpl.GetAlias(varName))
 				return checkResult
 			}
 
@@ -839,7 +883,7 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 			if len(skills) < 1 { //nolint:nestif
 				ReplyToSender(mctx, msg, "指令格式不匹配")
 				return CmdExecuteResult{Matched: true, Solved: true}
-			} else if len(skills) > 10 {
+			} else if len(skills) > 10 && subCmd != "all" {
 				ReplyToSender(mctx, msg, DiceFormatTmpl(mctx, "COC:技能成长_批量_技能过多警告"))
 				return CmdExecuteResult{Matched: true, Solved: true}
 			} else if len(skills) == 1 {
@@ -0,0 +1,94 @@
+package dice
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+// COC7 成长标记
+//
+// 检定成功时自动在当前卡片上标记技能，.en all 一次性成长全部标记的技能。
+// 标记随卡片数据保存，以 $ 开头不会出现在 .st show 中。
+
+const cocGrowthMarksKey = "$enMarks"
+
+// cocGrowthUnmarkable 规则书中不能通过成长检定提升的属性
+var cocGrowthUnmarkable = map[string]bool{
+	"力量": true, "敏捷": true, "意志": true, "体质": true, "外貌": true, "教育": true, "体型": true, "智力": true,
+	"幸运": true, "理智": true, "克苏鲁神话": true, "信用评级": true, "hp": true, "mp": true,
+}
+
+var cocGrowthSkillNameRe = regexp.MustCompile(`^[a-zA-Z_\p{Han}]+`)
+
+// cocGrowthSkillName 从检定的属性表达式中取出技能名，如“困难侦查+10”取出“侦查”
+func cocGrowthSkillName(tmpl *GameSystemTemplate, expr string) string {
+	name := cocGrowthSkillNameRe.FindString(strings.TrimSpace(expr))
+	for prefix := range difficultyPrefixMap {
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			name = name[len(prefix):]
+			break
+		}
+	}
+	if name == "" {
+		return ""
+	}
+	name = tmpl.GetAlias(name)
+	if cocGrowthUnmarkable[strings.ToLower(name)] {
+		return ""
+	}
+	return name
+}
+
+// cocGrowthMarksLoad 读取卡片上的成长标记
+func cocGrowthMarksLoad(attrs *AttributesItem) []string {
+	v := attrs.Load(cocGrowthMarksKey)
+	if v == nil {
+		return nil
+	}
+	arr, ok := v.ReadArray()
+	if !ok {
+		return nil
+	}
+	marks := make([]string, 0, len(arr.List))
+	for _, item := range arr.List {
+		marks = append(marks, item.ToString())
+	}
+	return marks
+}
+
+func cocGrowthMarksStore(attrs *AttributesItem, marks []string) {
+	if len(marks) == 0 {
+		if attrs.Load(cocGrowthMarksKey) != nil {
+			attrs.Delete(cocGrowthMarksKey)
+		}
+		return
+	}
+	values := make([]*ds.VMValue, 0, len(marks))
+	for _, name := range marks {
+		values = append(values, ds.NewStrVal(name))
+	}
+	attrs.Store(cocGrowthMarksKey, ds.NewArrayVal(values...))
+}
+
+// cocGrowthMark 标记技能，已标记时不做改动
+func cocGrowthMark(attrs *AttributesItem, name string) {
+	marks := cocGrowthMarksLoad(attrs)
+	if slices.Contains(marks, name) {
+		return
+	}
+	cocGrowthMarksStore(attrs, append(marks, name))
+}
+
+// cocGrowthUnmark 移除技能的标记
+func cocGrowthUnmark(attrs *AttributesItem, names ...string) {
+	marks := cocGrowthMarksLoad(attrs)
+	remain := slices.DeleteFunc(slices.Clone(marks), func(name string) bool {
+		return slices.Contains(names, name)
+	})
+	if len(remain) != len(marks) {
+		cocGrowthMarksStore(attrs, remain)
+	}
+}
@@ -0,0 +1,97 @@
+//nolint:testpackage
+package dice
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"sealdice-core/model"
+)
+
+func TestCocGrowthSkillName(t *testing.T) {
+	tmpl, err := loadBuiltinTemplate("coc7.yaml")
+	if err != nil {
+		t.Fatalf("loadBuiltinTemplate() error = %v", err)
+	}
+	tmpl.Init()
+	cases := map[string]string{
+		"侦查":      "侦查",
+		"困难侦察+10": "侦查",
+		"力量":      "",
+		"str":     "",
+		"50":      "",
+	}
+	for expr, want := range cases {
+		if got := cocGrowthSkillName(tmpl, expr); got != want {
+			t.Errorf("cocGrowthSkillName(%q) = %q, want %q", expr, got, want)
+		}
+	}
+}
+
+func TestCocGrowthMarks(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	migrateTestTables(t, d, &model.AttributesItemModel{})
+
+	const groupID, userID = "QQ-Group:333", "QQ:777"
+	send := func(text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg(groupID, userID, text))
+	}
+	marks := func() []string {
+		t.Helper()
+		attrs, err := d.AttrsManager.Load(groupID, userID)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		return cocGrowthMarksLoad(attrs)
+	}
+
+	send(".st 侦查99 力量99 图书馆90")
+	if got := send(".en list"); !strings.Contains(got, "没有成长标记") {
+		t.Fatalf(".en list = %q", got)
+	}
+	send(".ra 力量")
+	send(".ra 图书馆 --push")
+	// 99 点仅出目 100 时失败
+	for range 5 {
+		send(".ra 侦查")
+		if len(marks()) > 0 {
+			break
+		}
+	}
+	if got := marks(); !slices.Equal(got, []string{"侦查"}) {
+		t.Fatalf("marks = %v, want [侦查]", got)
+	}
+	if got := send(".en list"); !strings.Contains(got, "侦查") {
+		t.Fatalf(".en list = %q", got)
+	}
+
+	// KP 通过队伍查看成长标记
+	group, _ := d.ImSession.ServiceAtNew.Load(groupID)
+	group.PlayerGroups = new(SyncMap[string, []string])
+	group.PlayerGroups.Store("调查员", []string{userID})
+	if got := send(".team 调查员 侦查"); !strings.Contains(got, "侦查 99 [CQ:at,qq=777] (待成长)") {
+		t.Fatalf(".team 调查员 侦查 = %q", got)
+	}
+	if got := send(".team 调查员 en"); !strings.Contains(got, "[CQ:at,qq=777] 侦查") {
+		t.Fatalf(".team 调查员 en = %q", got)
+	}
+
+	if got := send(".en all"); !strings.Contains(got, "侦查") {
+		t.Fatalf(".en all = %q", got)
+	}
+	if got := marks(); len(got) != 0 {
+		t.Fatalf("成长后 marks = %v", got)
+	}
+
+	attrs, _ := d.AttrsManager.Load(groupID, userID)
+	cocGrowthMark(attrs, "图书馆")
+	if got := send(".en clear"); !strings.Contains(got, "1项") {
+		t.Fatalf(".en clear = %q", got)
+	}
+	if got := marks(); len(got) != 0 {
+		t.Fatalf("清除后 marks = %v", got)
+	}
+}
@@ -25,7 +25,8 @@ var cmdTeam = &CmdItemInfo{
 .team <团队名> clear // 清空队伍
 .team <团队名> call // 艾特队伍
 .team <团队名> draw [数量] // 随机抽取队伍成员
-.team <团队名> <属性> // 列出队内成员属性`,
+.team <团队名> en // 列出队内成员的成长标记
+.team <团队名> <属性> // 列出队内成员属性，带成长标记的注明“待成长”`,
 	DisabledInPrivate: true,
 	AllowDelegate:     true,
 	Solve: func(context *MsgContext, message *Message, arguments *CmdArgs) CmdExecuteResult {
@@ -153,6 +154,25 @@ var cmdTeam = &CmdItemInfo{
 			} else {
 				ReplyToSender(context, message, fmt.Sprintf("从团队%s中随机抽取%d名成员：%s", groupName, count, strings.Join(cqCodes, " ")))
 			}
+		case "en":
+			if !groupExists {
+				ReplyToSender(context, message, fmt.Sprintf("没有名叫%s的团队", groupName))
+				break
+			}
+			formatList := make([]string, 0, len(playerGroup))
+			for _, userID := range playerGroup {
+				characterAttributes, err := context.Dice.AttrsManager.Load(group.GroupID, userID)
+				if err != nil {
+					context.Dice.Logger.Error(err)
+					continue
+				}
+				marks := "无"
+				if list := cocGrowthMarksLoad(characterAttributes); len(list) > 0 {
+					marks = strings.Join(list, "、")
+				}
+				formatList = append(formatList, fmt.Sprintf("[CQ:at,qq=%s] %s", teamStripPlatformPrefix(userID), marks))
+			}
+			ReplyToSender(context, message, fmt.Sprintf("队伍%s的成长标记：\n%s", groupName, strings.Join(formatList, "\n")))
 		default:
 			if !groupExists {
 				ReplyToSender(context, message, fmt.Sprintf("没有名叫%s的团队", groupName))
@@ -170,6 +190,8 @@ var cmdTeam = &CmdItemInfo{
 			defaultAttributeValue := currentGameSystem.GetDefaultValueEx(context, attributeName)
 
 			containers := make([]attributeContainer, 0, len(playerGroup))
+			growthMarked := map[string]bool{} // 该属性带有 COC7 成长标记的成员，供 KP 核对
+
 			for _, userID := range playerGroup {
 				characterAttributes, err := attributeManager.Load(group.GroupID, userID)
 				if err != nil {
@@ -179,6 +201,9 @@ var cmdTeam = &CmdItemInfo{
 					ReplyToSender(context, message, tmpl)
 					break
 				}
+				if slices.Contains(cocGrowthMarksLoad(characterAttributes), attributeName) {
+					growthMarked[userID] = true
+				}
 				attr := characterAttributes.Load(attributeName)
 				if attr == nil || ds.ValueEqual(attr, ds.NewIntVal(0), false) {
 					if defaultAttributeValue != nil {
@@ -219,6 +244,9 @@ var cmdTeam = &CmdItemInfo{
 				// STR 50 @木落 SAN65 HP11/11 DEX50
 				// This format postpones username, which can be long and irregular
 				s := fmt.Sprintf("%s %s [CQ:at,qq=%s]", attributeName, c.Value.ToString(), teamStripPlatformPrefix(c.UserID)) // ToString is by no means Go-idiomatic
+				if growthMarked[c.UserID] {
+					s += " (待成长)"
+				}
 				formatList = append(formatList, s)
 			}
 
@@ -860,18 +860,20 @@ func RegisterBuiltinExtDeck(d *Dice) {
 		Name:       "deck", // 扩展的名称，需要用于开启和关闭指令中，写简短点
 		Version:    "1.0.0",
 		Author:     "木落",
-		Brief:      "牌堆扩展，提供.deck指令支持，兼容Dice!和塔系牌堆",
+		Brief:      "牌堆扩展，提供.deck指令支持，兼容Dice!和塔系牌堆，以及.rt随机表",
 		Official:   true,
 		AutoActive: true, // 是否自动开启
 		OnCommandReceived: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) {
 		},
 		OnLoad: func() {
 			DeckReload(d)
+			RollTableReload(d)
 		},
 		GetDescText: GetExtensionDesc,
 		CmdMap: CmdMapCls{
 			"draw": cmdDraw,
 			"deck": cmdDraw,
+			"rt":   getCmdRollTable(d),
 		},
 	}
 
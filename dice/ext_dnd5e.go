@@ -82,6 +82,20 @@ func stpFormat(attrName string) string {
 	return "$stp_" + attrName
 }
 
+// newDnd5eSkillValue 构造技能值：base 为技能基础加值，factor 为熟练系数，为 nil 时不记录
+func newDnd5eSkillValue(parent string, base *ds.VMValue, factor *ds.VMValue) *ds.VMValue {
+	m := ds.ValueMap{}
+	m.Store("base", base)
+	if factor != nil {
+		m.Store("factor", factor)
+	}
+	return ds.NewComputedValRaw(&ds.ComputedData{
+		// Expr: fmt.Sprintf("this.base + ((%s)??0)/2 - 5 + (熟练??0) * this.factor", parent)
+		Expr:  fmt.Sprintf("pbCalc(this.base, this.factor, %s)", parent),
+		Attrs: &m,
+	})
+}
+
 func RegisterBuiltinExtDnd5e(self *Dice) {
 	deathSavingStable := func(ctx *MsgContext) {
 		VarDelValue(ctx, "DSS")
@@ -262,15 +276,7 @@ func RegisterBuiltinExtDnd5e(self *Dice) {
 
 				if val == nil {
 					// 如果不存在，先创建
-					m := ds.ValueMap{}
-					m.Store("base", ds.NewIntVal(0))
-					m.Store("factor", ds.NewIntVal(0))
-
-					val = ds.NewComputedValRaw(&ds.ComputedData{
-						// Expr: fmt.Sprintf("this.base + ((%s)??0)/2 - 5 + (熟练??0) * this.factor", parent)
-						Expr:  fmt.Sprintf("pbCalc(this.base, this.factor, %s)", parent),
-						Attrs: &m,
-					})
+					val = newDnd5eSkillValue(parent, ds.NewIntVal(0), ds.NewIntVal(0))
 					attrs.Store(attrName, val)
 				}
 
@@ -367,19 +373,7 @@ func RegisterBuiltinExtDnd5e(self *Dice) {
 			attrName := tmpl.GetAlias(i.name)
 			parent := dndAttrParent[attrName]
 			if parent != "" {
-				m := ds.ValueMap{}
-				m.Store("base", i.value)
-
-				if i.extra != nil {
-					m.Store("factor", i.extra)
-				} else {
-					m.Delete("factor")
-				}
-				i.value = ds.NewComputedValRaw(&ds.ComputedData{
-					// Expr: fmt.Sprintf("this.base + ((%s)??0)/2 - 5 + (熟练??0) * this.factor", parent)
-					Expr:  fmt.Sprintf("pbCalc(this.base, this.factor, %s)", parent),
-					Attrs: &m,
-				})
+				i.value = newDnd5eSkillValue(parent, i.value, i.extra)
 			} else if isAbilityScores(attrName) {
 				// 如果为主要属性，同时读取豁免值
 				if i.extra != nil {
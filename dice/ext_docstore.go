@@ -0,0 +1,1145 @@
+package dice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/tidwall/buntdb"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 扩展结构化存储
+//
+// 每个扩展一个 buntdb 文件 extensions/<扩展名>/docs.db，与旧的 storage.db 互不影响。
+// 文档是 JSON 对象，按集合存放，键为 doc:<集合>:<ID>，文档中的 _id 字段即为 ID。
+// 集合上可以为字段建立索引，索引定义存放在 meta:indexes 中，打开时重建。
+
+const (
+	extDocFileName     = "docs.db"
+	extDocKeyPrefix    = "doc:"
+	extDocIndexMetaKey = "meta:indexes"
+	// extDocUpdateRetry Update 回调期间文档被其他调用修改时的重试次数
+	extDocUpdateRetry = 5
+	// ExtDocLegacyCollection 旧版 storage.db 迁移到的默认集合
+	ExtDocLegacyCollection = "storage"
+)
+
+var (
+	ErrExtDocQuotaExceeded = errors.New("扩展存储已超出容量上限")
+	ErrExtDocConflict      = errors.New("文档被同时修改，请重试")
+	ErrExtDocStoreClosed   = errors.New("扩展存储已关闭")
+)
+
+// ExtDocQuery 查询条件。Filter 的键为字段路径(a.b.c)，值为要相等的值，
+// 或由 $eq $ne $gt $gte $lt $lte $in $nin $exists $contains 组成的对象。
+// Sort 为字段路径，前缀 - 表示降序。
+type ExtDocQuery struct {
+	Filter map[string]any `json:"filter" jsbind:"filter"`
+	Sort   string         `json:"sort"   jsbind:"sort"`
+	Limit  int            `json:"limit"  jsbind:"limit"`
+	Offset int            `json:"offset" jsbind:"offset"`
+}
+
+// ExtDocCollection 集合概况
+type ExtDocCollection struct {
+	Name    string   `json:"name"`
+	Count   int      `json:"count"`
+	Size    int64    `json:"size"`
+	Indexes []string `json:"indexes"`
+}
+
+// ExtDocDump 导出格式，也用于导入
+type ExtDocDump struct {
+	Ext         string                           `json:"ext"`
+	ExportedAt  int64                            `json:"exportedAt"`
+	Collections map[string]*ExtDocDumpCollection `json:"collections"`
+}
+
+type ExtDocDumpCollection struct {
+	Indexes []string         `json:"indexes"`
+	Docs    []ExtDocDumpItem `json:"docs"`
+}
+
+type ExtDocDumpItem struct {
+	ID        string          `json:"id"`
+	Doc       json.RawMessage `json:"doc"`
+	ExpiresAt int64           `json:"expiresAt,omitempty"` // 过期时间(unix秒)，0 为不过期
+}
+
+// ExtDocStore 一个扩展的结构化存储
+type ExtDocStore struct {
+	Ext   string
+	Quota int64 // 容量上限(字节)，为 0 时不限制
+
+	db      *buntdb.DB
+	size    atomic.Int64
+	lock    sync.Mutex
+	indexes map[string][]string // 集合 -> 已建立索引的字段
+}
+
+func checkExtDocCollection(collection string) error {
+	if collection == "" || strings.ContainsAny(collection, ":*?") {
+		return fmt.Errorf("集合名无效: %q", collection)
+	}
+	return nil
+}
+
+// checkExtDocKey 集合名和文档ID都不能含有 buntdb 的通配符和键分隔符
+func checkExtDocKey(collection, id string) error {
+	if err := checkExtDocCollection(collection); err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("文档ID不能为空")
+	}
+	if strings.ContainsAny(id, ":*?") {
+		return fmt.Errorf("文档ID无效: %q", id)
+	}
+	return nil
+}
+
+func extDocKey(collection, id string) string {
+	return extDocKeyPrefix + collection + ":" + id
+}
+
+func extDocIndexName(collection, field string) string {
+	return collection + "/" + field
+}
+
+func openExtDocStore(ext, fn string) (*ExtDocStore, error) {
+	db, err := buntdb.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	s := &ExtDocStore{Ext: ext, db: db, indexes: map[string][]string{}}
+
+	var cfg buntdb.Config
+	if err = db.ReadConfig(&cfg); err == nil {
+		// 过期文档由这里删除，以便同步扣减已用容量
+		cfg.OnExpiredSync = s.onExpired
+		err = db.SetConfig(cfg)
+	}
+	if err == nil {
+		err = db.View(func(tx *buntdb.Tx) error {
+			var size int64
+			if err := tx.AscendKeys(extDocKeyPrefix+"*", func(key, value string) bool {
+				size += int64(len(key) + len(value))
+				return true
+			}); err != nil {
+				return err
+			}
+			s.size.Store(size)
+			meta, err := tx.Get(extDocIndexMetaKey)
+			if err != nil {
+				if errors.Is(err, buntdb.ErrNotFound) {
+					return nil
+				}
+				return err
+			}
+			return json.Unmarshal([]byte(meta), &s.indexes)
+		})
+	}
+	if err == nil {
+		for collection, fields := range s.indexes {
+			for _, field := range fields {
+				if err = s.createIndex(collection, field); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ExtDocStore) onExpired(key, value string, tx *buntdb.Tx) error {
+	if _, err := tx.Delete(key); err != nil && !errors.Is(err, buntdb.ErrNotFound) {
+		return err
+	}
+	s.size.Add(-int64(len(key) + len(value)))
+	return nil
+}
+
+func (s *ExtDocStore) createIndex(collection, field string) error {
+	err := s.db.CreateIndex(extDocIndexName(collection, field), extDocKey(collection, "*"), buntdb.IndexJSONCaseSensitive(field))
+	if errors.Is(err, buntdb.ErrIndexExists) {
+		return nil
+	}
+	return err
+}
+
+func (s *ExtDocStore) close() error {
+	return s.db.Close()
+}
+
+// Size 已用容量(字节)
+func (s *ExtDocStore) Size() int64 {
+	return s.size.Load()
+}
+
+// update 在写事务中执行 f，f 通过 delta 累计容量变化，事务提交后才计入
+func (s *ExtDocStore) update(f func(tx *buntdb.Tx, delta *int64) error) error {
+	var delta int64
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		return f(tx, &delta)
+	})
+	if errors.Is(err, buntdb.ErrDatabaseClosed) {
+		return ErrExtDocStoreClosed
+	}
+	if err == nil {
+		s.size.Add(delta)
+	}
+	return err
+}
+
+func (s *ExtDocStore) view(f func(tx *buntdb.Tx) error) error {
+	err := s.db.View(f)
+	if errors.Is(err, buntdb.ErrDatabaseClosed) {
+		return ErrExtDocStoreClosed
+	}
+	return err
+}
+
+// setTx 写入并检查容量，ttl 为秒
+func (s *ExtDocStore) setTx(tx *buntdb.Tx, delta *int64, key, value string, ttl int64) error {
+	d := int64(len(key) + len(value))
+	old, err := tx.Get(key, true)
+	switch {
+	case err == nil:
+		d -= int64(len(key) + len(old))
+	case !errors.Is(err, buntdb.ErrNotFound):
+		return err
+	}
+	if s.Quota > 0 && d > 0 && s.size.Load()+*delta+d > s.Quota {
+		return fmt.Errorf("%w: %d 字节", ErrExtDocQuotaExceeded, s.Quota)
+	}
+	var opts *buntdb.SetOptions
+	if ttl > 0 {
+		opts = &buntdb.SetOptions{Expires: true, TTL: time.Duration(ttl) * time.Second}
+	}
+	if _, _, err = tx.Set(key, value, opts); err != nil {
+		return err
+	}
+	*delta += d
+	return nil
+}
+
+func (s *ExtDocStore) deleteTx(tx *buntdb.Tx, delta *int64, key string) (bool, error) {
+	old, err := tx.Delete(key)
+	if errors.Is(err, buntdb.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	*delta -= int64(len(key) + len(old))
+	return true, nil
+}
+
+// marshalExtDoc 文档必须是 JSON 对象，写入时附上 _id
+func marshalExtDoc(id string, doc any) (string, error) {
+	var data []byte
+	switch v := doc.(type) {
+	case json.RawMessage:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return "", err
+		}
+	}
+	if !gjson.ValidBytes(data) || !gjson.ParseBytes(data).IsObject() {
+		return "", errors.New("文档必须是对象")
+	}
+	data, err := sjson.SetBytes(data, "_id", id)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func unmarshalExtDoc(raw string) map[string]any {
+	doc := map[string]any{}
+	_ = json.Unmarshal([]byte(raw), &doc)
+	return doc
+}
+
+// Insert 以新生成的 ID 写入文档并返回该 ID，ttl 为秒，0 表示不过期
+func (s *ExtDocStore) Insert(collection string, doc any, ttl int64) (string, error) {
+	id := ulid.Make().String()
+	return id, s.Put(collection, id, doc, ttl)
+}
+
+// Put 写入(覆盖)文档，ttl 为秒，0 表示不过期
+func (s *ExtDocStore) Put(collection, id string, doc any, ttl int64) error {
+	if err := checkExtDocKey(collection, id); err != nil {
+		return err
+	}
+	value, err := marshalExtDoc(id, doc)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *buntdb.Tx, delta *int64) error {
+		return s.setTx(tx, delta, extDocKey(collection, id), value, ttl)
+	})
+}
+
+// Get 读取文档，不存在时返回 nil
+func (s *ExtDocStore) Get(collection, id string) (map[string]any, error) {
+	if err := checkExtDocKey(collection, id); err != nil {
+		return nil, err
+	}
+	var raw string
+	err := s.view(func(tx *buntdb.Tx) error {
+		var err error
+		raw, err = tx.Get(extDocKey(collection, id))
+		return err
+	})
+	if errors.Is(err, buntdb.ErrNotFound) {
+		return nil, nil //nolint:nilnil // 不存在不是错误
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalExtDoc(raw), nil
+}
+
+// Delete 删除文档，返回文档是否存在
+func (s *ExtDocStore) Delete(collection, id string) (bool, error) {
+	if err := checkExtDocKey(collection, id); err != nil {
+		return false, err
+	}
+	var deleted bool
+	err := s.update(func(tx *buntdb.Tx, delta *int64) error {
+		var err error
+		deleted, err = s.deleteTx(tx, delta, extDocKey(collection, id))
+		return err
+	})
+	return deleted, err
+}
+
+// Patch 原子地合并顶层字段，文档不存在时创建，返回合并后的文档
+func (s *ExtDocStore) Patch(collection, id string, fields map[string]any) (map[string]any, error) {
+	if err := checkExtDocKey(collection, id); err != nil {
+		return nil, err
+	}
+	key := extDocKey(collection, id)
+	var raw string
+	err := s.update(func(tx *buntdb.Tx, delta *int64) error {
+		old, err := tx.Get(key)
+		if errors.Is(err, buntdb.ErrNotFound) {
+			old = "{}"
+		} else if err != nil {
+			return err
+		}
+		raw = old
+		for k, v := range fields {
+			if raw, err = sjson.Set(raw, gjsonEscape(k), v); err != nil {
+				return err
+			}
+		}
+		if raw, err = marshalExtDoc(id, raw); err != nil {
+			return err
+		}
+		return s.setTx(tx, delta, key, raw, extDocRemainTTL(tx, key))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalExtDoc(raw), nil
+}
+
+// Update 读出文档交给 fn 修改后写回，文档不存在时 fn 收到 nil，fn 返回 nil 时删除文档。
+// fn 在事务外执行，可以访问本存储；写回前发现文档已被修改则重新执行 fn。
+func (s *ExtDocStore) Update(collection, id string, fn func(doc map[string]any) (map[string]any, error)) (map[string]any, error) {
+	if err := checkExtDocKey(collection, id); err != nil {
+		return nil, err
+	}
+	key := extDocKey(collection, id)
+	for range extDocUpdateRetry {
+		var old string
+		var exists bool
+		err := s.view(func(tx *buntdb.Tx) error {
+			var err error
+			old, err = tx.Get(key)
+			if errors.Is(err, buntdb.ErrNotFound) {
+				return nil
+			}
+			exists = err == nil
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		var cur map[string]any
+		if exists {
+			cur = unmarshalExtDoc(old)
+		}
+		next, err := fn(cur)
+		if err != nil {
+			return nil, err
+		}
+		var value string
+		if next != nil {
+			if value, err = marshalExtDoc(id, next); err != nil {
+				return nil, err
+			}
+		}
+
+		conflict := false
+		err = s.update(func(tx *buntdb.Tx, delta *int64) error {
+			now, err := tx.Get(key)
+			if errors.Is(err, buntdb.ErrNotFound) {
+				conflict = exists
+			} else if err != nil {
+				return err
+			} else {
+				conflict = !exists || now != old
+			}
+			if conflict {
+				return nil
+			}
+			if next == nil {
+				_, err = s.deleteTx(tx, delta, key)
+				return err
+			}
+			return s.setTx(tx, delta, key, value, extDocRemainTTL(tx, key))
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !conflict {
+			if next == nil {
+				return nil, nil //nolint:nilnil // 文档已删除
+			}
+			return unmarshalExtDoc(value), nil
+		}
+	}
+	return nil, ErrExtDocConflict
+}
+
+// extDocRemainTTL 覆盖写入时保留原有的剩余有效期(秒)
+func extDocRemainTTL(tx *buntdb.Tx, key string) int64 {
+	ttl, err := tx.TTL(key)
+	if err != nil || ttl <= 0 {
+		return 0
+	}
+	return max(int64(ttl/time.Second), 1)
+}
+
+// gjsonEscape 顶层字段名中的路径字符需要转义
+func gjsonEscape(k string) string {
+	r := strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`)
+	return r.Replace(k)
+}
+
+// EnsureIndex 为集合的字段建立索引，已存在时不做处理
+func (s *ExtDocStore) EnsureIndex(collection, field string) error {
+	if err := checkExtDocCollection(collection); err != nil {
+		return err
+	}
+	if field == "" {
+		return errors.New("索引字段不能为空")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, f := range s.indexes[collection] {
+		if f == field {
+			return nil
+		}
+	}
+	if err := s.createIndex(collection, field); err != nil {
+		return err
+	}
+	s.indexes[collection] = append(s.indexes[collection], field)
+	return s.saveIndexMeta()
+}
+
+// DropIndex 删除字段索引
+func (s *ExtDocStore) DropIndex(collection, field string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	fields := s.indexes[collection]
+	for i, f := range fields {
+		if f != field {
+			continue
+		}
+		if err := s.db.DropIndex(extDocIndexName(collection, field)); err != nil && !errors.Is(err, buntdb.ErrNotFound) {
+			return err
+		}
+		s.indexes[collection] = append(fields[:i:i], fields[i+1:]...)
+		if len(s.indexes[collection]) == 0 {
+			delete(s.indexes, collection)
+		}
+		return s.saveIndexMeta()
+	}
+	return nil
+}
+
+// saveIndexMeta 调用方需持有 s.lock
+func (s *ExtDocStore) saveIndexMeta() error {
+	data, err := json.Marshal(s.indexes)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *buntdb.Tx, _ *int64) error {
+		_, _, err := tx.Set(extDocIndexMetaKey, string(data), nil)
+		return err
+	})
+}
+
+func (s *ExtDocStore) hasIndex(collection, field string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, f := range s.indexes[collection] {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Find 按条件查询集合中的文档
+func (s *ExtDocStore) Find(collection string, q ExtDocQuery) ([]map[string]any, error) {
+	raws, err := s.find(collection, q)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]map[string]any, 0, len(raws))
+	for _, raw := range raws {
+		docs = append(docs, unmarshalExtDoc(raw))
+	}
+	return docs, nil
+}
+
+// Count 统计满足过滤条件的文档数
+func (s *ExtDocStore) Count(collection string, filter map[string]any) (int, error) {
+	raws, err := s.find(collection, ExtDocQuery{Filter: filter})
+	return len(raws), err
+}
+
+func (s *ExtDocStore) find(collection string, q ExtDocQuery) ([]string, error) {
+	if err := checkExtDocCollection(collection); err != nil {
+		return nil, err
+	}
+	sortField, desc := strings.CutPrefix(q.Sort, "-")
+	// 能用索引排序时，凑够 offset+limit 条就可以停下
+	sorted := sortField != "" && s.hasIndex(collection, sortField)
+	want := -1
+	if sorted && q.Limit > 0 {
+		want = q.Offset + q.Limit
+	}
+
+	var raws []string
+	var matchErr error
+	iter := func(_, value string) bool {
+		ok, err := extDocMatch(value, q.Filter)
+		if err != nil {
+			matchErr = err
+			return false
+		}
+		if ok {
+			raws = append(raws, value)
+		}
+		return want < 0 || len(raws) < want
+	}
+	// 有索引的相等条件可以只遍历索引中相等的部分
+	eqIndex, eqPivot := "", ""
+	if !sorted {
+		for field, cond := range q.Filter {
+			if _, isOp := extDocOperators(cond); isOp || !s.hasIndex(collection, field) {
+				continue
+			}
+			if pivot, err := sjson.Set("{}", field, cond); err == nil {
+				eqIndex, eqPivot = extDocIndexName(collection, field), pivot
+				break
+			}
+		}
+	}
+	err := s.view(func(tx *buntdb.Tx) error {
+		switch {
+		case sorted && desc:
+			return tx.Descend(extDocIndexName(collection, sortField), iter)
+		case sorted:
+			return tx.Ascend(extDocIndexName(collection, sortField), iter)
+		case eqIndex != "":
+			return tx.AscendEqual(eqIndex, eqPivot, iter)
+		}
+		return tx.AscendKeys(extDocKey(collection, "*"), iter)
+	})
+	if err == nil {
+		err = matchErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !sorted && sortField != "" {
+		sort.SliceStable(raws, func(i, j int) bool {
+			a, b := gjson.Get(raws[i], sortField), gjson.Get(raws[j], sortField)
+			if desc {
+				return b.Less(a, true)
+			}
+			return a.Less(b, true)
+		})
+	}
+	if q.Offset > 0 {
+		if q.Offset >= len(raws) {
+			return nil, nil
+		}
+		raws = raws[q.Offset:]
+	}
+	if q.Limit > 0 && len(raws) > q.Limit {
+		raws = raws[:q.Limit]
+	}
+	return raws, nil
+}
+
+// extDocOperators cond 是否为 {$op: value} 形式
+func extDocOperators(cond any) (map[string]any, bool) {
+	m, ok := cond.(map[string]any)
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+func extDocMatch(raw string, filter map[string]any) (bool, error) {
+	for field, cond := range filter {
+		v := gjson.Get(raw, field)
+		ops, isOp := extDocOperators(cond)
+		if !isOp {
+			ops = map[string]any{"$eq": cond}
+		}
+		for op, arg := range ops {
+			ok, err := extDocCompare(v, op, arg)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+func extDocCompare(v gjson.Result, op string, arg any) (bool, error) {
+	switch op {
+	case "$eq":
+		return extDocEqual(v, arg), nil
+	case "$ne":
+		return !extDocEqual(v, arg), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		c, ok := extDocOrder(v, arg)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "$gt":
+			return c > 0, nil
+		case "$gte":
+			return c >= 0, nil
+		case "$lt":
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	case "$in", "$nin":
+		items, ok := extDocList(arg)
+		if !ok {
+			return false, fmt.Errorf("%s 需要数组", op)
+		}
+		found := false
+		for _, item := range items {
+			if extDocEqual(v, item) {
+				found = true
+				break
+			}
+		}
+		return found == (op == "$in"), nil
+	case "$exists":
+		want, _ := arg.(bool)
+		return v.Exists() == want, nil
+	case "$contains":
+		if v.IsArray() {
+			for _, item := range v.Array() {
+				if extDocEqual(item, arg) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		s, ok := arg.(string)
+		return ok && v.Type == gjson.String && strings.Contains(v.Str, s), nil
+	}
+	return false, fmt.Errorf("不支持的查询条件: %s", op)
+}
+
+func extDocList(arg any) ([]any, bool) {
+	rv := reflect.ValueOf(arg)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]any, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+func extDocNumber(arg any) (float64, bool) {
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func extDocEqual(v gjson.Result, arg any) bool {
+	if arg == nil {
+		return !v.Exists() || v.Type == gjson.Null
+	}
+	if n, ok := extDocNumber(arg); ok {
+		return v.Type == gjson.Number && v.Num == n
+	}
+	switch a := arg.(type) {
+	case string:
+		return v.Type == gjson.String && v.Str == a
+	case bool:
+		return v.IsBool() && v.Bool() == a
+	}
+	data, err := json.Marshal(arg)
+	if err != nil || !v.Exists() {
+		return false
+	}
+	var x, y any
+	_ = json.Unmarshal(data, &x)
+	_ = json.Unmarshal([]byte(v.Raw), &y)
+	return reflect.DeepEqual(x, y)
+}
+
+// extDocOrder 比较 v 与 arg，只支持数字与数字、字符串与字符串
+func extDocOrder(v gjson.Result, arg any) (int, bool) {
+	if n, ok := extDocNumber(arg); ok {
+		if v.Type != gjson.Number {
+			return 0, false
+		}
+		switch {
+		case v.Num < n:
+			return -1, true
+		case v.Num > n:
+			return 1, true
+		}
+		return 0, true
+	}
+	if s, ok := arg.(string); ok && v.Type == gjson.String {
+		return strings.Compare(v.Str, s), true
+	}
+	return 0, false
+}
+
+// Drop 删除整个集合及其索引，返回删除的文档数
+func (s *ExtDocStore) Drop(collection string) (int, error) {
+	if err := checkExtDocCollection(collection); err != nil {
+		return 0, err
+	}
+	s.lock.Lock()
+	fields := append([]string(nil), s.indexes[collection]...)
+	s.lock.Unlock()
+	for _, field := range fields {
+		if err := s.DropIndex(collection, field); err != nil {
+			return 0, err
+		}
+	}
+	return s.deleteByPattern(extDocKey(collection, "*"))
+}
+
+func (s *ExtDocStore) deleteByPattern(pattern string) (int, error) {
+	n := 0
+	err := s.update(func(tx *buntdb.Tx, delta *int64) error {
+		var keys []string
+		if err := tx.AscendKeys(pattern, func(key, _ string) bool {
+			keys = append(keys, key)
+			return true
+		}); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, err := s.deleteTx(tx, delta, key); err != nil {
+				return err
+			}
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
+
+// Collections 列出全部集合
+func (s *ExtDocStore) Collections() ([]ExtDocCollection, error) {
+	found := map[string]*ExtDocCollection{}
+	err := s.view(func(tx *buntdb.Tx) error {
+		return tx.AscendKeys(extDocKeyPrefix+"*", func(key, value string) bool {
+			name, _, _ := strings.Cut(strings.TrimPrefix(key, extDocKeyPrefix), ":")
+			c := found[name]
+			if c == nil {
+				c = &ExtDocCollection{Name: name}
+				found[name] = c
+			}
+			c.Count++
+			c.Size += int64(len(key) + len(value))
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	for name, fields := range s.indexes {
+		c := found[name]
+		if c == nil {
+			c = &ExtDocCollection{Name: name}
+			found[name] = c
+		}
+		c.Indexes = append([]string(nil), fields...)
+	}
+	s.lock.Unlock()
+
+	items := make([]ExtDocCollection, 0, len(found))
+	for _, c := range found {
+		if c.Indexes == nil {
+			c.Indexes = []string{}
+		}
+		items = append(items, *c)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+// Export 导出全部集合，已过期的文档不导出
+func (s *ExtDocStore) Export() (*ExtDocDump, error) {
+	now := time.Now()
+	dump := &ExtDocDump{Ext: s.Ext, ExportedAt: now.Unix(), Collections: map[string]*ExtDocDumpCollection{}}
+	getCollection := func(name string) *ExtDocDumpCollection {
+		c := dump.Collections[name]
+		if c == nil {
+			c = &ExtDocDumpCollection{Indexes: []string{}, Docs: []ExtDocDumpItem{}}
+			dump.Collections[name] = c
+		}
+		return c
+	}
+	err := s.view(func(tx *buntdb.Tx) error {
+		var iterErr error
+		err := tx.AscendKeys(extDocKeyPrefix+"*", func(key, value string) bool {
+			name, id, _ := strings.Cut(strings.TrimPrefix(key, extDocKeyPrefix), ":")
+			item := ExtDocDumpItem{ID: id, Doc: json.RawMessage(value)}
+			ttl, err := tx.TTL(key)
+			if err != nil {
+				iterErr = err
+				return false
+			}
+			if ttl > 0 {
+				item.ExpiresAt = now.Add(ttl).Unix()
+			}
+			c := getCollection(name)
+			c.Docs = append(c.Docs, item)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return iterErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	for name, fields := range s.indexes {
+		getCollection(name).Indexes = append([]string(nil), fields...)
+	}
+	s.lock.Unlock()
+	return dump, nil
+}
+
+// Import 导入 Export 的结果，replace 时先清空全部文档，返回导入的文档数
+func (s *ExtDocStore) Import(dump *ExtDocDump, replace bool) (int, error) {
+	if dump == nil {
+		return 0, errors.New("导入内容为空")
+	}
+	for name := range dump.Collections {
+		if err := checkExtDocCollection(name); err != nil {
+			return 0, err
+		}
+	}
+	if replace {
+		if _, err := s.deleteByPattern(extDocKeyPrefix + "*"); err != nil {
+			return 0, err
+		}
+	}
+	for name, c := range dump.Collections {
+		for _, field := range c.Indexes {
+			if err := s.EnsureIndex(name, field); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	now := time.Now().Unix()
+	n := 0
+	err := s.update(func(tx *buntdb.Tx, delta *int64) error {
+		for name, c := range dump.Collections {
+			for _, item := range c.Docs {
+				var ttl int64
+				if item.ExpiresAt > 0 {
+					if ttl = item.ExpiresAt - now; ttl <= 0 {
+						continue
+					}
+				}
+				if err := checkExtDocKey(name, item.ID); err != nil {
+					return fmt.Errorf("集合 %s: %w", name, err)
+				}
+				value, err := marshalExtDoc(item.ID, item.Doc)
+				if err != nil {
+					return fmt.Errorf("文档 %s/%s: %w", name, item.ID, err)
+				}
+				if err = s.setTx(tx, delta, extDocKey(name, item.ID), value, ttl); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ImportLegacy 把旧版 storage.db 的键值复制为 collection 中的文档，键即 ID。
+// 值是 JSON 对象时原样作为文档，其余包装为 {"value": ...}。旧存储不做改动。
+func (s *ExtDocStore) ImportLegacy(legacy *buntdb.DB, collection string) (int, error) {
+	if err := checkExtDocCollection(collection); err != nil {
+		return 0, err
+	}
+	type kv struct{ k, v string }
+	var items []kv
+	err := legacy.View(func(tx *buntdb.Tx) error {
+		return tx.Ascend("", func(key, value string) bool {
+			items = append(items, kv{key, value})
+			return true
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		if err = checkExtDocKey(collection, item.k); err != nil {
+			return 0, fmt.Errorf("旧存储的键不能作为文档ID: %w", err)
+		}
+	}
+	err = s.update(func(tx *buntdb.Tx, delta *int64) error {
+		for _, item := range items {
+			doc := item.v
+			if parsed := gjson.Parse(item.v); !gjson.Valid(item.v) || !parsed.IsObject() {
+				var value any = item.v
+				if gjson.Valid(item.v) {
+					value = json.RawMessage(item.v)
+				}
+				data, err := json.Marshal(map[string]any{"value": value})
+				if err != nil {
+					return err
+				}
+				doc = string(data)
+			}
+			value, err := marshalExtDoc(item.k, doc)
+			if err != nil {
+				return err
+			}
+			if err = s.setTx(tx, delta, extDocKey(collection, item.k), value, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// ExtDocStoreInfo 存储文件概况，供 WebUI 列出
+type ExtDocStoreInfo struct {
+	Ext    string `json:"ext"`
+	Size   int64  `json:"size"`   // 文件大小
+	Loaded bool   `json:"loaded"` // 是否已打开
+	Legacy bool   `json:"legacy"` // 是否存在旧版 storage.db
+}
+
+// ExtDocManager 管理各扩展的结构化存储，按需打开，扩展卸载时关闭
+type ExtDocManager struct {
+	d      *Dice
+	lock   sync.Mutex
+	stores map[string]*ExtDocStore
+}
+
+func NewExtDocManager(d *Dice) *ExtDocManager {
+	return &ExtDocManager{d: d, stores: map[string]*ExtDocStore{}}
+}
+
+func checkExtDocExtName(extName string) error {
+	if extName == "" || extName == "." || extName == ".." || strings.ContainsAny(extName, `/\`) {
+		return fmt.Errorf("扩展名无效: %q", extName)
+	}
+	return nil
+}
+
+// Open 打开扩展的存储，已打开时直接返回
+func (m *ExtDocManager) Open(extName string) (*ExtDocStore, error) {
+	if err := checkExtDocExtName(extName); err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s := m.stores[extName]
+	if s == nil {
+		var err error
+		s, err = openExtDocStore(extName, filepath.Join(m.d.GetExtDataDir(extName), extDocFileName))
+		if err != nil {
+			m.d.Logger.Errorf("[扩展]:打开扩展<%s>的结构化存储失败: %v", extName, err)
+			return nil, err
+		}
+		m.stores[extName] = s
+	}
+	s.Quota = m.d.Config.JsDocStoreQuota << 20
+	return s, nil
+}
+
+// Close 关闭扩展的存储
+func (m *ExtDocManager) Close(extName string) {
+	m.lock.Lock()
+	s := m.stores[extName]
+	delete(m.stores, extName)
+	m.lock.Unlock()
+	if s != nil {
+		if err := s.close(); err != nil {
+			m.d.Logger.Errorf("[扩展]:关闭扩展<%s>的结构化存储失败: %v", extName, err)
+		}
+	}
+}
+
+// CloseAll 关闭全部存储，程序退出时调用
+func (m *ExtDocManager) CloseAll() {
+	m.lock.Lock()
+	names := make([]string, 0, len(m.stores))
+	for name := range m.stores {
+		names = append(names, name)
+	}
+	m.lock.Unlock()
+	for _, name := range names {
+		m.Close(name)
+	}
+}
+
+// snapshot 已打开的存储写出一致的快照，未打开时返回 false
+func (m *ExtDocManager) snapshot(extName string, f func(s *ExtDocStore) error) (bool, error) {
+	m.lock.Lock()
+	s := m.stores[extName]
+	m.lock.Unlock()
+	if s == nil {
+		return false, nil
+	}
+	return true, f(s)
+}
+
+// List 列出数据目录下有结构化存储或旧版存储的扩展
+func (m *ExtDocManager) List() []ExtDocStoreInfo {
+	extDir := filepath.Join(m.d.BaseConfig.DataDir, "extensions")
+	entries, _ := os.ReadDir(extDir)
+	items := []ExtDocStoreInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info := ExtDocStoreInfo{Ext: entry.Name()}
+		if stat, err := os.Stat(filepath.Join(extDir, entry.Name(), extDocFileName)); err == nil {
+			info.Size = stat.Size()
+		} else if _, err = os.Stat(filepath.Join(extDir, entry.Name(), "storage.db")); err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(extDir, entry.Name(), "storage.db")); err == nil {
+			info.Legacy = true
+		}
+		m.lock.Lock()
+		info.Loaded = m.stores[entry.Name()] != nil
+		m.lock.Unlock()
+		items = append(items, info)
+	}
+	return items
+}
+
+// MigrateLegacy 把扩展旧版 storage.db 的内容复制到结构化存储的 collection 集合中
+func (m *ExtDocManager) MigrateLegacy(extName, collection string) (int, error) {
+	if collection == "" {
+		collection = ExtDocLegacyCollection
+	}
+	s, err := m.Open(extName)
+	if err != nil {
+		return 0, err
+	}
+	// 扩展已加载时复用它打开的旧存储，避免同一文件被打开两次
+	if ext := m.d.ExtFind(extName, false); ext != nil {
+		if target := ext.GetRealExt(); target != nil {
+			if err = target.StorageInit(); err != nil {
+				return 0, err
+			}
+			return s.ImportLegacy(target.Storage, collection)
+		}
+	}
+	fn := filepath.Join(m.d.GetExtDataDir(extName), "storage.db")
+	if _, err = os.Stat(fn); err != nil {
+		return 0, fmt.Errorf("扩展<%s>没有旧版存储", extName)
+	}
+	legacy, err := buntdb.Open(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer legacy.Close()
+	return s.ImportLegacy(legacy, collection)
+}
+
+// Docs 扩展的结构化存储
+func (i *ExtInfo) Docs() (*ExtDocStore, error) {
+	target := i.GetRealExt()
+	if target == nil || target.dice == nil {
+		return nil, errors.New("[扩展]:目标扩展不存在")
+	}
+	if target.dice.ExtDocs == nil {
+		return nil, errors.New("[扩展]:结构化存储未初始化")
+	}
+	return target.dice.ExtDocs.Open(target.Name)
+}
@@ -0,0 +1,246 @@
+package dice //nolint:testpackage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tidwall/buntdb"
+)
+
+func newTestExtDocStore(t *testing.T) *ExtDocStore {
+	t.Helper()
+	s, err := openExtDocStore("test", filepath.Join(t.TempDir(), extDocFileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.close()
+		// buntdb 的后台协程在下一次 tick 才退出
+		time.Sleep(1500 * time.Millisecond)
+	})
+	return s
+}
+
+func extDocIDs(docs []map[string]any) []string {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc["_id"].(string))
+	}
+	return ids
+}
+
+func TestExtDocStore_FindWithIndexAndFilter(t *testing.T) {
+	s := newTestExtDocStore(t)
+	players := map[string]map[string]any{
+		"a": {"name": "Alice", "hp": 10, "tags": []any{"pc"}},
+		"b": {"name": "Bob", "hp": 3, "tags": []any{"npc"}},
+		"c": {"name": "Carol", "hp": 7, "tags": []any{"pc", "gm"}},
+		"d": {"name": "Dave"},
+	}
+	for id, doc := range players {
+		if err := s.Put("players", id, doc, 0); err != nil {
+			t.Fatalf("put %s: %v", id, err)
+		}
+	}
+
+	for _, indexed := range []bool{false, true} {
+		if indexed {
+			if err := s.EnsureIndex("players", "hp"); err != nil {
+				t.Fatalf("index: %v", err)
+			}
+		}
+		docs, err := s.Find("players", ExtDocQuery{
+			Filter: map[string]any{"hp": map[string]any{"$gte": 5}},
+			Sort:   "-hp",
+		})
+		if err != nil {
+			t.Fatalf("find: %v", err)
+		}
+		if got := extDocIDs(docs); len(got) != 2 || got[0] != "a" || got[1] != "c" {
+			t.Fatalf("indexed=%v: unexpected result %v", indexed, got)
+		}
+	}
+
+	docs, err := s.Find("players", ExtDocQuery{Sort: "hp", Limit: 2})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	// 缺少排序字段的文档排在最前
+	if got := extDocIDs(docs); len(got) != 2 || got[0] != "d" || got[1] != "b" {
+		t.Fatalf("sorted with limit: %v", got)
+	}
+
+	cases := []struct {
+		filter map[string]any
+		want   int
+	}{
+		{map[string]any{"name": "Bob"}, 1},
+		{map[string]any{"hp": map[string]any{"$exists": false}}, 1},
+		{map[string]any{"tags": map[string]any{"$contains": "pc"}}, 2},
+		{map[string]any{"name": map[string]any{"$in": []any{"Alice", "Dave"}}}, 2},
+		{map[string]any{"name": map[string]any{"$nin": []any{"Alice", "Dave"}}}, 2},
+		{map[string]any{"hp": map[string]any{"$gt": 3, "$lt": 10}}, 1},
+		{map[string]any{"name": map[string]any{"$ne": "Bob"}}, 3},
+	}
+	for _, tc := range cases {
+		n, err := s.Count("players", tc.filter)
+		if err != nil {
+			t.Fatalf("count %v: %v", tc.filter, err)
+		}
+		if n != tc.want {
+			t.Errorf("count %v = %d, want %d", tc.filter, n, tc.want)
+		}
+	}
+
+	if _, err = s.Count("players", map[string]any{"hp": map[string]any{"$regex": "x"}}); err == nil {
+		t.Fatal("unknown operator should fail")
+	}
+}
+
+func TestExtDocStore_UpdateAndPatch(t *testing.T) {
+	s := newTestExtDocStore(t)
+	doc, err := s.Update("counters", "hits", func(doc map[string]any) (map[string]any, error) {
+		if doc != nil {
+			t.Fatalf("expected missing doc, got %v", doc)
+		}
+		return map[string]any{"n": 1}, nil
+	})
+	if err != nil || doc["n"] != float64(1) {
+		t.Fatalf("create via update: %v %v", doc, err)
+	}
+
+	// 回调期间文档被修改时应重试，并基于新值计算
+	retried := false
+	doc, err = s.Update("counters", "hits", func(doc map[string]any) (map[string]any, error) {
+		if !retried {
+			retried = true
+			if err := s.Put("counters", "hits", map[string]any{"n": 10}, 0); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+		}
+		doc["n"] = doc["n"].(float64) + 1
+		return doc, nil
+	})
+	if err != nil || doc["n"] != float64(11) {
+		t.Fatalf("update after conflict: %v %v", doc, err)
+	}
+
+	doc, err = s.Patch("counters", "hits", map[string]any{"label": "x"})
+	if err != nil || doc["n"] != float64(11) || doc["label"] != "x" {
+		t.Fatalf("patch: %v %v", doc, err)
+	}
+
+	if _, err = s.Update("counters", "hits", func(map[string]any) (map[string]any, error) { return nil, nil }); err != nil {
+		t.Fatalf("delete via update: %v", err)
+	}
+	if doc, _ = s.Get("counters", "hits"); doc != nil {
+		t.Fatalf("doc should be deleted, got %v", doc)
+	}
+}
+
+func TestExtDocStore_RejectsPatternIDs(t *testing.T) {
+	s := newTestExtDocStore(t)
+	if err := s.Put("c", "a", map[string]any{"v": 1}, 0); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	for _, id := range []string{"", "*", "a?", "x:y"} {
+		if err := s.Put("c", id, map[string]any{"v": 2}, 0); err == nil {
+			t.Fatalf("put %q should fail", id)
+		}
+		if _, err := s.Get("c", id); err == nil {
+			t.Fatalf("get %q should fail", id)
+		}
+		if _, err := s.Delete("c", id); err == nil {
+			t.Fatalf("delete %q should fail", id)
+		}
+		if _, err := s.Patch("c", id, map[string]any{"v": 3}); err == nil {
+			t.Fatalf("patch %q should fail", id)
+		}
+	}
+	if doc, _ := s.Get("c", "a"); doc == nil || doc["v"] != float64(1) {
+		t.Fatalf("doc should be untouched, got %v", doc)
+	}
+}
+
+func TestExtDocStore_QuotaAndTTL(t *testing.T) {
+	s := newTestExtDocStore(t)
+	s.Quota = 64
+	if err := s.Put("c", "1", map[string]any{"v": "short"}, 0); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err := s.Put("c", "2", map[string]any{"v": "this document is long enough to exceed the quota"}, 0)
+	if !errors.Is(err, ErrExtDocQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if doc, _ := s.Get("c", "2"); doc != nil {
+		t.Fatal("rejected doc should not be stored")
+	}
+	if _, err = s.Delete("c", "1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if s.Size() != 0 {
+		t.Fatalf("size after delete = %d", s.Size())
+	}
+
+	s.Quota = 0
+	if err = s.Put("c", "tmp", map[string]any{"v": 1}, 1); err != nil {
+		t.Fatalf("put ttl: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if doc, _ := s.Get("c", "tmp"); doc != nil {
+		t.Fatalf("doc should be expired, got %v", doc)
+	}
+}
+
+func TestExtDocStore_ExportImportAndLegacy(t *testing.T) {
+	s := newTestExtDocStore(t)
+	if err := s.EnsureIndex("notes", "author"); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	for _, id := range []string{"n1", "n2"} {
+		if err := s.Put("notes", id, map[string]any{"author": id}, 0); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	dump, err := s.Export()
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst := newTestExtDocStore(t)
+	if err = dst.Put("notes", "old", map[string]any{"author": "old"}, 0); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	n, err := dst.Import(dump, true)
+	if err != nil || n != 2 {
+		t.Fatalf("import: %d %v", n, err)
+	}
+	if doc, _ := dst.Get("notes", "old"); doc != nil {
+		t.Fatal("replace import should clear old docs")
+	}
+	if !dst.hasIndex("notes", "author") {
+		t.Fatal("index should be imported")
+	}
+
+	legacy, err := buntdb.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open legacy: %v", err)
+	}
+	defer legacy.Close()
+	_ = legacy.Update(func(tx *buntdb.Tx) error {
+		_, _, _ = tx.Set("obj", `{"x":1}`, nil)
+		_, _, _ = tx.Set("plain", "hello", nil)
+		return nil
+	})
+	if n, err = dst.ImportLegacy(legacy, ExtDocLegacyCollection); err != nil || n != 2 {
+		t.Fatalf("legacy import: %d %v", n, err)
+	}
+	if doc, _ := dst.Get(ExtDocLegacyCollection, "obj"); doc["x"] != float64(1) {
+		t.Fatalf("legacy object: %v", doc)
+	}
+	if doc, _ := dst.Get(ExtDocLegacyCollection, "plain"); doc["value"] != "hello" {
+		t.Fatalf("legacy string: %v", doc)
+	}
+}
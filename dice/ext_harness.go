@@ -0,0 +1,516 @@
+package dice
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+
+	"sealdice-core/dice/sealpack"
+	"sealdice-core/logger"
+	"sealdice-core/message"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+// 扩展离线测试台
+//
+// 不连接任何平台，在给定的数据库(一般是内存库)上启动一个骰子，通过 UI 端点逐条发送消息并同步收集回复，
+// 骰点使用固定种子，便于脚本作者写出可重复的剧本。剧本格式见 HarnessScenario。
+
+// ExtHarness 离线测试台
+type ExtHarness struct {
+	Dice     *Dice
+	EndPoint *EndPointInfo
+	Adapter  *PlatformAdapterHTTP
+}
+
+// ExtHarnessOptions 测试台选项
+type ExtHarnessOptions struct {
+	DataDir string // 数据目录，扩展存储、配置等写在这里
+	Seed    uint64 // 骰点随机种子，为 0 时取 1
+}
+
+// NewExtHarness 在 op 上启动一个只有 UI 端点的骰子，op 之后由测试台负责关闭
+func NewExtHarness(op engine.DatabaseOperator, opts ExtHarnessOptions) (*ExtHarness, error) {
+	if opts.DataDir == "" {
+		return nil, errors.New("测试台需要数据目录")
+	}
+	for _, sub := range []string{"configs", "extensions", "scripts", "extra", "log-exports"} {
+		if err := os.MkdirAll(filepath.Join(opts.DataDir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	d := newOfflineDice(op, opts.DataDir, "harness")
+	d.IPC = NewExtIPC(d)
+	d.JsHTTPRouter = NewJsHTTPRouter()
+	d.ExtDocs = NewExtDocManager(d)
+
+	ep := &EndPointInfo{
+		EndPointInfoBase: EndPointInfoBase{
+			ID:       "1",
+			UserID:   "UI:1000",
+			Nickname: "海豹",
+			Platform: "UI",
+			State:    1,
+			Enable:   true,
+		},
+	}
+	adapter := &PlatformAdapterHTTP{EndPoint: ep}
+	ep.Adapter = adapter
+	ep.Session = d.ImSession
+	d.UIEndpoint = ep
+
+	h := &ExtHarness{Dice: d, EndPoint: ep, Adapter: adapter}
+	h.SetSeed(opts.Seed)
+
+	// 脚本的执行时限与正式环境一致，死循环会被中断而不是卡住测试台
+	d.ExtLoopManager = NewJsLoopManager()
+	d.JsWatchdog = NewJsWatchdog()
+	d.JsBuiltinDigestSet = map[string]bool{}
+	d.JsInit()
+	return h, nil
+}
+
+// newOfflineDice 在 op 上构建一个不连接任何平台的骰子：载入默认配置、内置指令与扩展和文本模板。
+// Cron 只创建不启动，避免多出后台协程
+func newOfflineDice(op engine.DatabaseOperator, dataDir, name string) *Dice {
+	dm := &DiceManager{Cron: cron.New()}
+	d := &Dice{
+		BaseConfig: BaseConfig{
+			DataDir: dataDir,
+			Name:    name,
+		},
+		Logger:        logger.M(),
+		LogWriter:     logger.NewUIWriter(),
+		DBOperator:    op,
+		CmdMap:        CmdMapCls{},
+		ExtRegistry:   new(SyncMap[string, *ExtInfo]),
+		GameSystemMap: new(SyncMap[string, *GameSystemTemplate]),
+		DirtyGroups:   new(SyncMap[string, int64]),
+		CocExtraRules: map[int]*CocRuleInfo{},
+		Cron:          cron.New(),
+		Parent:        dm,
+	}
+	dm.Dice = []*Dice{d}
+
+	d.Config = NewConfig(d)
+	// 不模拟发送间隔
+	d.Config.MessageDelayRangeEnd = 0
+	d.CommandPrefix = DefaultConfig.CommandPrefix
+
+	d.ImSession = &IMSession{}
+	d.ImSession.Parent = d
+	d.ImSession.ServiceAtNew = new(SyncMap[string, *GroupInfo])
+	d.ImSession.PendingQuits = new(SyncMap[string, *PendingQuitInfo])
+
+	d.AttrsManager = &AttrsManager{}
+	d.AttrsManager.Init(d)
+	d.ConfigManager = NewConfigManager(filepath.Join(dataDir, "configs", "plugin-configs.json"))
+	_ = d.ConfigManager.Load()
+
+	initVerify()
+	d.registerCoreCommands()
+	d.RegisterBuiltinExt()
+	setupBaseTextTemplate(d)
+	loadTextTemplate(d, "configs/text-template.yaml")
+	d.GenerateTextMap()
+	d.ApplyExtDefaultSettings()
+	d.IsAlreadyLoadConfig = true
+	return d
+}
+
+// SetSeed 重设骰点与文本模板抽取的随机种子。
+// 指令的骰点都取自全局随机源，测试台逐条处理消息，重设后结果可以复现
+func (h *ExtHarness) SetSeed(seed uint64) {
+	if seed == 0 {
+		seed = 1
+	}
+	randSource.Seed(seed)
+	randSourceDrawAndTmplSelect.Seed(int64(seed)) //nolint:gosec
+}
+
+// Close 关闭 JS 环境与数据库
+func (h *ExtHarness) Close() {
+	d := h.Dice
+	if d.JsScriptCron != nil {
+		d.JsScriptCron.Stop()
+	}
+	d.jsClear()
+	d.ExtLoopManager.SetLoop(nil)
+	d.ExtDocs.CloseAll()
+	d.AttrsManager.Stop()
+	if d.DBOperator != nil {
+		d.DBOperator.Close()
+	}
+	harnessWaitStorageClosed(3 * time.Second)
+}
+
+// harnessWaitStorageClosed 等待已关闭的扩展存储(buntdb)的后台协程退出，
+// 它们要到关闭后的下一次 tick 才会结束
+func harnessWaitStorageClosed(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		buf := make([]byte, 64<<10)
+		for {
+			n := runtime.Stack(buf, true)
+			if n < len(buf) {
+				buf = buf[:n]
+				break
+			}
+			buf = make([]byte, 2*len(buf))
+		}
+		if !bytes.Contains(buf, []byte("buntdb.(*DB).backgroundManager")) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// LoadScript 加载单个 js/ts 脚本或含 package.json 的脚本工程目录，失败时返回脚本的错误信息
+func (h *ExtHarness) LoadScript(path string) error {
+	d := h.Dice
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		jsInfo, err := d.jsParseProject(path)
+		if err != nil {
+			return err
+		}
+		return h.loadScript(jsInfo)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	jsInfo, err := d.JsParseMeta(path, info.ModTime(), data, false)
+	if err != nil {
+		return err
+	}
+	return h.loadScript(jsInfo)
+}
+
+func (h *ExtHarness) loadScript(jsInfo *JsScriptInfo) error {
+	d := h.Dice
+	if strings.ToLower(filepath.Ext(jsInfo.Filename)) == ".ts" {
+		jsInfo.needCompiled = true
+	}
+	d.JsLoadScriptRaw(jsInfo)
+	if jsInfo.ErrText != "" {
+		return fmt.Errorf("脚本「%s」加载失败: %s", jsInfo.Name, jsInfo.ErrText)
+	}
+	d.ApplyExtDefaultSettings()
+	return nil
+}
+
+// LoadPackage 安装并启用扩展包，然后加载其中的脚本
+func (h *ExtHarness) LoadPackage(path string) error {
+	d := h.Dice
+	archive, err := sealpack.InspectArchive(path)
+	if err != nil {
+		return err
+	}
+	if archive.Manifest == nil {
+		return errors.New("扩展包缺少 manifest")
+	}
+	pkgID := archive.Manifest.Package.ID
+
+	if d.PackageManager == nil {
+		d.PackageSetup()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = d.PackageManager.InstallFromStream(f); err != nil {
+		return err
+	}
+	if _, err = d.PackageManager.Enable(pkgID); err != nil {
+		return err
+	}
+
+	scriptFiles, projects := jsPackageScripts(d.PackageManager.GetEnabledContentFiles("scripts"))
+	for _, project := range projects {
+		if project.PackageID != pkgID {
+			continue
+		}
+		jsInfo, err := d.jsParseProject(project.Path)
+		if err != nil {
+			return err
+		}
+		jsInfo.PackageID = pkgID
+		if err = h.loadScript(jsInfo); err != nil {
+			return err
+		}
+	}
+	for _, scriptFile := range scriptFiles {
+		if scriptFile.PackageID != pkgID {
+			continue
+		}
+		info, err := os.Stat(scriptFile.Path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(scriptFile.Path)
+		if err != nil {
+			return err
+		}
+		jsInfo, err := d.JsParseMeta(scriptFile.Path, info.ModTime(), data, false)
+		if err != nil {
+			return err
+		}
+		jsInfo.PackageID = pkgID
+		if err = h.loadScript(jsInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// harnessUserID 补全平台前缀，1001 -> UI:1001
+func harnessUserID(prefix, id string) string {
+	if id == "" || strings.Contains(id, ":") {
+		return id
+	}
+	return prefix + id
+}
+
+func (h *ExtHarness) newMessage(userID, groupID, text string) *Message {
+	msg := &Message{
+		Platform:    "UI",
+		MessageType: "private",
+		Time:        time.Now().Unix(),
+		Message:     text,
+		Segment:     []message.IMessageElement{&message.TextElement{Content: text}},
+		Sender: SenderBase{
+			UserID:   harnessUserID("UI:", userID),
+			Nickname: "用户" + strings.TrimPrefix(harnessUserID("UI:", userID), "UI:"),
+		},
+	}
+	if groupID != "" {
+		msg.MessageType = "group"
+		msg.GroupID = harnessUserID("UI-Group:", groupID)
+		msg.GroupName = msg.GroupID
+	}
+	return msg
+}
+
+// harnessQuietPeriod 回复是异步发出的，连续这么久没有新回复即认为这条消息处理完毕
+const harnessQuietPeriod = 80 * time.Millisecond
+
+// Send 以 userID 的身份发送消息，groupID 为空时为私聊，返回这条消息引起的全部回复。
+// wait 大于 0 时至少等待这么久，用于收集定时器等延后发出的回复。
+func (h *ExtHarness) Send(userID, groupID, text string, wait time.Duration) []string {
+	h.Adapter.TakeRecentMessages()
+	start := time.Now()
+	h.Dice.ImSession.Execute(h.EndPoint, h.newMessage(userID, groupID, text), true)
+
+	count, quietSince := 0, time.Now()
+	for {
+		time.Sleep(10 * time.Millisecond)
+		h.Adapter.lock.Lock()
+		n := len(h.Adapter.RecentMessage)
+		h.Adapter.lock.Unlock()
+		if n != count {
+			count, quietSince = n, time.Now()
+			continue
+		}
+		if time.Since(quietSince) >= harnessQuietPeriod && time.Since(start) >= wait {
+			break
+		}
+	}
+
+	var replies []string
+	for _, msg := range h.Adapter.TakeRecentMessages() {
+		replies = append(replies, msg.Message)
+	}
+	return replies
+}
+
+// Var 读取变量，与在该用户、该群中执行指令时看到的一致
+func (h *ExtHarness) Var(userID, groupID, name string) (string, bool) {
+	ctx := CreateTempCtx(h.EndPoint, h.newMessage(userID, groupID, ""))
+	v, ok := VarGetValue(ctx, name)
+	if !ok || v == nil {
+		return "", false
+	}
+	return v.ToString(), true
+}
+
+// Storage 读取扩展存储
+func (h *ExtHarness) Storage(extName, key string) (string, error) {
+	ext := h.Dice.ExtFind(extName, true)
+	if ext == nil {
+		return "", fmt.Errorf("找不到扩展<%s>", extName)
+	}
+	return ext.StorageGet(key)
+}
+
+// HarnessScenario 测试剧本，脚本与扩展包路径相对于剧本文件
+type HarnessScenario struct {
+	Name     string        `yaml:"name"`
+	Seed     uint64        `yaml:"seed"`
+	Scripts  []string      `yaml:"scripts"`
+	Packages []string      `yaml:"packages"`
+	Steps    []HarnessStep `yaml:"steps"`
+}
+
+// HarnessStep 剧本中的一句话及对它的期望
+type HarnessStep struct {
+	User  string `yaml:"user"`  // 发送者，省略时为 1001
+	Group string `yaml:"group"` // 群号，省略时为私聊
+	Send  string `yaml:"send"`
+	Wait  int64  `yaml:"wait"` // 额外等待异步回复的毫秒数
+
+	Reply      []string                     `yaml:"reply"`      // 第 i 条回复应包含 Reply[i]
+	ReplyRegex string                       `yaml:"replyRegex"` // 全部回复拼接后应匹配
+	NoReply    bool                         `yaml:"noReply"`    // 不应有回复
+	Vars       map[string]string            `yaml:"vars"`       // 变量名 -> 期望值
+	Storage    map[string]map[string]string `yaml:"storage"`    // 扩展名 -> 键 -> 期望值
+}
+
+// HarnessStepResult 一步的执行结果
+type HarnessStepResult struct {
+	Index   int      `json:"index"`
+	Send    string   `json:"send"`
+	Replies []string `json:"replies"`
+	Errors  []string `json:"errors"`
+}
+
+// HarnessResult 剧本的执行结果
+type HarnessResult struct {
+	Name  string              `json:"name"`
+	Steps []HarnessStepResult `json:"steps"`
+}
+
+func (r *HarnessResult) Failed() bool {
+	for _, step := range r.Steps {
+		if len(step.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Report 生成可读的结果报告
+func (r *HarnessResult) Report() string {
+	var sb strings.Builder
+	failed := 0
+	for _, step := range r.Steps {
+		if len(step.Errors) == 0 {
+			continue
+		}
+		failed++
+		fmt.Fprintf(&sb, "第%d步 %q:\n", step.Index+1, step.Send)
+		for _, e := range step.Errors {
+			fmt.Fprintf(&sb, "  - %s\n", e)
+		}
+		for _, reply := range step.Replies {
+			fmt.Fprintf(&sb, "  > %s\n", strings.ReplaceAll(reply, "\n", "\n    "))
+		}
+	}
+	fmt.Fprintf(&sb, "剧本<%s>: %d步，失败%d步", r.Name, len(r.Steps), failed)
+	return sb.String()
+}
+
+// LoadHarnessScenario 读取 YAML 剧本，并将其中的相对路径换算为相对剧本文件
+func LoadHarnessScenario(path string) (*HarnessScenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc := &HarnessScenario{}
+	if err = yaml.Unmarshal(data, sc); err != nil {
+		return nil, fmt.Errorf("剧本解析失败: %w", err)
+	}
+	if sc.Name == "" {
+		sc.Name = filepath.Base(path)
+	}
+	base := filepath.Dir(path)
+	for _, paths := range [][]string{sc.Scripts, sc.Packages} {
+		for i, p := range paths {
+			if !filepath.IsAbs(p) {
+				paths[i] = filepath.Join(base, p)
+			}
+		}
+	}
+	return sc, nil
+}
+
+// RunScenario 加载剧本中的脚本和扩展包，然后逐步执行并检查
+func (h *ExtHarness) RunScenario(sc *HarnessScenario) (*HarnessResult, error) {
+	if sc.Seed != 0 {
+		h.SetSeed(sc.Seed)
+	}
+	for _, p := range sc.Packages {
+		if err := h.LoadPackage(p); err != nil {
+			return nil, fmt.Errorf("加载扩展包 %s 失败: %w", p, err)
+		}
+	}
+	for _, p := range sc.Scripts {
+		if err := h.LoadScript(p); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &HarnessResult{Name: sc.Name}
+	for i, step := range sc.Steps {
+		user := step.User
+		if user == "" {
+			user = "1001"
+		}
+		replies := h.Send(user, step.Group, step.Send, time.Duration(step.Wait)*time.Millisecond)
+		sr := HarnessStepResult{Index: i, Send: step.Send, Replies: replies}
+		fail := func(format string, args ...any) {
+			sr.Errors = append(sr.Errors, fmt.Sprintf(format, args...))
+		}
+
+		if step.NoReply && len(replies) > 0 {
+			fail("不应有回复，实际收到%d条", len(replies))
+		}
+		for j, want := range step.Reply {
+			switch {
+			case j >= len(replies):
+				fail("缺少第%d条回复，期望包含 %q", j+1, want)
+			case !strings.Contains(replies[j], want):
+				fail("第%d条回复不包含 %q", j+1, want)
+			}
+		}
+		if step.ReplyRegex != "" {
+			re, err := regexp.Compile(step.ReplyRegex)
+			if err != nil {
+				fail("replyRegex 无效: %v", err)
+			} else if !re.MatchString(strings.Join(replies, "\n")) {
+				fail("回复不匹配 %q", step.ReplyRegex)
+			}
+		}
+		for name, want := range step.Vars {
+			if got, _ := h.Var(user, step.Group, name); got != want {
+				fail("变量 %s = %q，期望 %q", name, got, want)
+			}
+		}
+		for extName, kv := range step.Storage {
+			for key, want := range kv {
+				got, err := h.Storage(extName, key)
+				if err != nil {
+					fail("读取扩展<%s>存储 %s 失败: %v", extName, key, err)
+				} else if got != want {
+					fail("扩展<%s>存储 %s = %q，期望 %q", extName, key, got, want)
+				}
+			}
+		}
+		result.Steps = append(result.Steps, sr)
+	}
+	return result, nil
+}
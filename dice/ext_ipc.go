@@ -0,0 +1,315 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// ipcDefaultTimeout 未指定时限时 IPC 调用的等待时间
+const ipcDefaultTimeout = 5 * time.Second
+
+var (
+	ErrIPCServiceNotFound = errors.New("找不到对应的IPC服务")
+	ErrIPCTimeout         = errors.New("IPC调用超时")
+)
+
+// IPCHandler Go 扩展提供的 IPC 服务，caller 为调用方扩展名
+type IPCHandler func(caller string, payload any) (any, error)
+
+// IPCSubscriber Go 扩展订阅主题的回调，publisher 为发布方扩展名
+type IPCSubscriber func(publisher string, data any)
+
+// ipcEndpoint 一个服务或一个订阅，Go 回调与 JS 函数二选一
+type ipcEndpoint struct {
+	ext   *ExtInfo
+	name  string
+	goFn  IPCHandler
+	goSub IPCSubscriber
+	jsFn  goja.Callable
+}
+
+// IPCService 已注册的服务，供 WebUI 展示
+type IPCService struct {
+	ExtName string `json:"extName"`
+	Name    string `json:"name"`
+	IsJs    bool   `json:"isJs"`
+}
+
+// ExtIPC 扩展间通信：具名的请求/响应调用与发布/订阅主题。
+// 服务和主题都属于注册它的扩展，扩展包内的扩展访问其他扩展时需要在 permissions.ipc 中声明对方。
+type ExtIPC struct {
+	d        *Dice
+	lock     sync.RWMutex
+	services map[string]*ipcEndpoint   // 扩展名/服务名
+	subs     map[string][]*ipcEndpoint // 扩展名/主题名
+}
+
+func NewExtIPC(d *Dice) *ExtIPC {
+	return &ExtIPC{d: d, services: map[string]*ipcEndpoint{}, subs: map[string][]*ipcEndpoint{}}
+}
+
+func ipcKey(extName, name string) string {
+	return extName + "/" + name
+}
+
+// ipcPackageID 扩展对应的权限标识：扩展包内为包 ID，其余为扩展名
+func ipcPackageID(ext *ExtInfo) string {
+	if ext.Source != nil && ext.Source.PackageID != "" {
+		return ext.Source.PackageID
+	}
+	return ext.Name
+}
+
+// checkPermission 只约束扩展包内的调用方，内置扩展与独立脚本不受限制
+func (ipc *ExtIPC) checkPermission(caller *ExtInfo, target *ExtInfo) error {
+	if caller == nil || caller.Source == nil || caller.Source.PackageID == "" || caller.Name == target.Name {
+		return nil
+	}
+	if caller.Source.PackageID == ipcPackageID(target) {
+		return nil
+	}
+	if ipc.d.PackageManager == nil {
+		return fmt.Errorf("扩展<%s>没有访问<%s>的IPC权限", caller.Name, target.Name)
+	}
+	sandbox, err := ipc.d.PackageManager.GetSandbox(caller.Source.PackageID)
+	if err != nil {
+		return err
+	}
+	return sandbox.CheckIPCPermission(ipcPackageID(target))
+}
+
+func (ipc *ExtIPC) provide(ep *ipcEndpoint) error {
+	if ep.ext == nil || ep.name == "" {
+		return errors.New("IPC服务需要扩展和名称")
+	}
+	ipc.lock.Lock()
+	defer ipc.lock.Unlock()
+	ipc.services[ipcKey(ep.ext.Name, ep.name)] = ep
+	return nil
+}
+
+// Provide 以 Go 函数注册服务，同名服务会被替换
+func (ipc *ExtIPC) Provide(ext *ExtInfo, name string, handler IPCHandler) error {
+	return ipc.provide(&ipcEndpoint{ext: ext, name: name, goFn: handler})
+}
+
+func (ipc *ExtIPC) subscribe(target string, ep *ipcEndpoint) error {
+	if ep.ext == nil || target == "" || ep.name == "" {
+		return errors.New("IPC订阅需要扩展、目标扩展和主题")
+	}
+	ipc.lock.Lock()
+	defer ipc.lock.Unlock()
+	key := ipcKey(target, ep.name)
+	ipc.subs[key] = append(ipc.subs[key], ep)
+	return nil
+}
+
+// Subscribe 以 Go 函数订阅 target 扩展发布的主题，权限在投递时检查，目标扩展可以晚于订阅方加载
+func (ipc *ExtIPC) Subscribe(ext *ExtInfo, target, topic string, fn IPCSubscriber) error {
+	return ipc.subscribe(target, &ipcEndpoint{ext: ext, name: topic, goSub: fn})
+}
+
+// RemoveExt 移除扩展注册的服务与订阅，脚本卸载时调用
+func (ipc *ExtIPC) RemoveExt(extName string) {
+	ipc.removeIf(func(ep *ipcEndpoint) bool { return ep.ext.Name == extName })
+}
+
+// removeJs 移除全部 JS 扩展的服务与订阅，JS 环境重建时调用
+func (ipc *ExtIPC) removeJs() {
+	ipc.removeIf(func(ep *ipcEndpoint) bool { return ep.jsFn != nil })
+}
+
+func (ipc *ExtIPC) removeIf(f func(ep *ipcEndpoint) bool) {
+	ipc.lock.Lock()
+	defer ipc.lock.Unlock()
+	for key, ep := range ipc.services {
+		if f(ep) {
+			delete(ipc.services, key)
+		}
+	}
+	for key, eps := range ipc.subs {
+		kept := eps[:0]
+		for _, ep := range eps {
+			if !f(ep) {
+				kept = append(kept, ep)
+			}
+		}
+		if len(kept) == 0 {
+			delete(ipc.subs, key)
+		} else {
+			ipc.subs[key] = kept
+		}
+	}
+}
+
+// Services 列出已注册的服务
+func (ipc *ExtIPC) Services() []IPCService {
+	ipc.lock.RLock()
+	defer ipc.lock.RUnlock()
+	items := make([]IPCService, 0, len(ipc.services))
+	for _, ep := range ipc.services {
+		items = append(items, IPCService{ExtName: ep.ext.Name, Name: ep.name, IsJs: ep.jsFn != nil})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return ipcKey(items[i].ExtName, items[i].Name) < ipcKey(items[j].ExtName, items[j].Name)
+	})
+	return items
+}
+
+// callAsync 调用 target 扩展的服务，结果通过 settle 恰好回传一次
+func (ipc *ExtIPC) callAsync(caller *ExtInfo, target, name string, payload any, timeout time.Duration, settle func(any, error)) {
+	var once sync.Once
+	done := func(v any, err error) {
+		once.Do(func() { settle(v, err) })
+	}
+
+	ipc.lock.RLock()
+	ep := ipc.services[ipcKey(target, name)]
+	ipc.lock.RUnlock()
+	if ep == nil {
+		done(nil, fmt.Errorf("%w: %s", ErrIPCServiceNotFound, ipcKey(target, name)))
+		return
+	}
+	if err := ipc.checkPermission(caller, ep.ext); err != nil {
+		done(nil, err)
+		return
+	}
+	callerName := ""
+	if caller != nil {
+		callerName = caller.Name
+	}
+
+	if timeout <= 0 {
+		timeout = ipcDefaultTimeout
+	}
+	timer := time.AfterFunc(timeout, func() {
+		done(nil, fmt.Errorf("%w: %s", ErrIPCTimeout, ipcKey(target, name)))
+	})
+	settleAndStop := func(v any, err error) {
+		timer.Stop()
+		done(v, err)
+	}
+
+	if ep.goFn != nil {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					settleAndStop(nil, fmt.Errorf("IPC服务<%s>异常: %v", ipcKey(target, name), r))
+				}
+			}()
+			settleAndStop(ep.goFn(callerName, payload))
+		}()
+		return
+	}
+	ipc.runJs(ep, func(vm *goja.Runtime) (goja.Value, error) {
+		return ep.jsFn(goja.Undefined(), vm.ToValue(payload), vm.ToValue(callerName))
+	}, settleAndStop)
+}
+
+// runJs 在服务方扩展的事件循环中执行 JS 函数，返回 Promise 时等待其完成
+func (ipc *ExtIPC) runJs(ep *ipcEndpoint, call func(vm *goja.Runtime) (goja.Value, error), settle func(any, error)) {
+	d := ipc.d
+	if !d.Config.JsEnable || d.ExtLoopManager == nil {
+		settle(nil, ErrJsNotEnabled)
+		return
+	}
+	loop, err := d.ExtLoopManager.GetLoop(ep.ext.JSLoopVersion)
+	if err != nil {
+		settle(nil, fmt.Errorf("扩展<%s>运行环境已经过期", ep.ext.Name))
+		return
+	}
+	ok := loop.RunOnLoop(func(vm *goja.Runtime) {
+		defer func() {
+			if r := recover(); r != nil {
+				settle(nil, fmt.Errorf("%v", r))
+			}
+		}()
+		var ret goja.Value
+		var callErr error
+		err := d.JsRunGuarded(vm, ep.ext, func() {
+			ret, callErr = call(vm)
+			var ie *goja.InterruptedError
+			if errors.As(callErr, &ie) {
+				panic(ie)
+			}
+		})
+		if err == nil {
+			err = callErr
+		}
+		if err != nil {
+			settle(nil, err)
+			return
+		}
+		if ret == nil {
+			settle(nil, nil)
+			return
+		}
+		if _, isPromise := ret.Export().(*goja.Promise); !isPromise {
+			settle(ret.Export(), nil)
+			return
+		}
+		obj := ret.ToObject(vm)
+		then, _ := goja.AssertFunction(obj.Get("then"))
+		_, _ = then(obj, vm.ToValue(func(v goja.Value) {
+			settle(v.Export(), nil)
+		}), vm.ToValue(func(v goja.Value) {
+			settle(nil, fmt.Errorf("%v", v))
+		}))
+	})
+	if !ok {
+		settle(nil, fmt.Errorf("扩展<%s>运行环境已经过期", ep.ext.Name))
+	}
+}
+
+// Call 同步调用 target 扩展的服务，供 Go 扩展使用。不要在 JS 事件循环中调用，否则会阻塞 JS 服务方。
+func (ipc *ExtIPC) Call(caller *ExtInfo, target, name string, payload any, timeout time.Duration) (any, error) {
+	type result struct {
+		v   any
+		err error
+	}
+	ch := make(chan result, 1)
+	ipc.callAsync(caller, target, name, payload, timeout, func(v any, err error) {
+		ch <- result{v, err}
+	})
+	r := <-ch
+	return r.v, r.err
+}
+
+// Publish 向订阅了 publisher 扩展某主题的扩展异步投递数据，返回投递的订阅数
+func (ipc *ExtIPC) Publish(publisher *ExtInfo, topic string, data any) int {
+	ipc.lock.RLock()
+	eps := append([]*ipcEndpoint(nil), ipc.subs[ipcKey(publisher.Name, topic)]...)
+	ipc.lock.RUnlock()
+	delivered := 0
+	for _, ep := range eps {
+		if err := ipc.checkPermission(ep.ext, publisher); err != nil {
+			ipc.d.Logger.Warnf("扩展<%s>订阅主题<%s>被拒绝: %v", ep.ext.Name, ipcKey(publisher.Name, topic), err)
+			continue
+		}
+		delivered++
+		if ep.goSub != nil {
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						ipc.d.Logger.Errorf("扩展<%s>处理主题<%s>异常: %v", ep.ext.Name, ipcKey(publisher.Name, topic), r)
+					}
+				}()
+				ep.goSub(publisher.Name, data)
+			}()
+			continue
+		}
+		ipc.runJs(ep, func(vm *goja.Runtime) (goja.Value, error) {
+			return ep.jsFn(goja.Undefined(), vm.ToValue(data), vm.ToValue(publisher.Name))
+		}, func(_ any, err error) {
+			if err != nil {
+				ipc.d.Logger.Errorf("扩展<%s>处理主题<%s>异常: %v", ep.ext.Name, ipcKey(publisher.Name, topic), err)
+			}
+		})
+	}
+	return delivered
+}
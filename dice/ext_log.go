@@ -185,6 +185,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 .log del <日志名> // 删除一份日志
 .log stat [<日志名>] // 查看统计
 .log stat [<日志名>] --all // 查看统计(全团)，--all前必须有空格
+.log fair on/off // 开关公平骰(需管理权限)，开启后每次log new公布种子承诺，log end公布种子以供复算骰点
 .log list <群号> // 查看指定群的日志列表(无法取得日志时，找骰主做这个操作)
 .log masterget <群号> <日志名> // 重新上传日志，并获取链接(无法取得日志时，找骰主做这个操作)
 .log export <日志名> // 直接取得日志txt(服务出问题或有其他需要时使用)
@@ -199,7 +200,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
 			group := ctx.Group
 			cmdArgs.ChopPrefixToArgsWith("on", "off", "del", "rm", "masterget",
-				"get", "end", "halt", "list", "new", "stat", "export")
+				"get", "end", "halt", "list", "new", "stat", "export", "fair")
 
 			groupNotActiveCheck := func() bool {
 				if !group.IsActive(ctx) {
@@ -424,6 +425,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 				//	 text = strings.TrimRightFunc(DiceFormatTmpl(ctx, "日志:记录_关闭_失败"), unicode.IsSpace) + "\n" + text
 				// }
 				ReplyToSender(ctx, msg, text)
+				fairDiceReveal(ctx, msg)
 				group.SetLogOn(false)
 				group.MarkDirty(ctx.Dice)
 				ctx.Dice.Logger.Infof("日志状态切换: 群=%s 结束日志 name=%s id=%d，准备上传", group.GroupID, state.Name, state.ID)
@@ -445,6 +447,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 				}
 				text := DiceFormatTmpl(ctx, "日志:记录_结束")
 				ReplyToSender(ctx, msg, text)
+				fairDiceReveal(ctx, msg)
 				group.ClearLogState()
 				group.MarkDirty(ctx.Dice)
 				ctx.Dice.Logger.Infof("日志状态切换: 群=%s 强制终止当前日志", group.GroupID)
@@ -514,6 +517,52 @@ func RegisterBuiltinExtLog(self *Dice) {
 				ctx.Dice.Logger.Infof("日志状态切换: 群=%s 新建并开启日志 name=%s id=%d", group.GroupID, name, logID)
 
 				ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "日志:记录_新建"))
+				if c, s, err := campaignAttachLog(ctx, name); err != nil {
+					ctx.Dice.Logger.Warnf("日志归入战役失败: group=%s name=%s err=%v", group.GroupID, name, err)
+				} else if s != nil {
+					ReplyToSender(ctx, msg, fmt.Sprintf("日志<%s>已归入战役<%s>%s", name, c.Name, campaignSessionTitle(s)))
+				}
+				if group.FairDiceOn {
+					// 上一段记录的会话在这里一并公布
+					fairDiceReveal(ctx, msg)
+					session, err := group.FairDiceStart(name)
+					if err != nil {
+						ReplyToSender(ctx, msg, "公平骰开启失败: "+err.Error())
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					VarSetValueStr(ctx, "$t公平骰承诺", session.Commitment)
+					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "日志:记录_公平骰_承诺"))
+				}
+				return CmdExecuteResult{Matched: true, Solved: true}
+			} else if cmdArgs.IsArgEqual(1, "fair") {
+				if ctx.IsPrivate {
+					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:提示_私聊不可用"))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				op := strings.ToLower(cmdArgs.GetArgN(2))
+				if (op == "on" || op == "off") && ctx.PrivilegeLevel < 40 {
+					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:提示_无权限_非master/管理"))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				switch op {
+				case "on":
+					group.FairDiceOn = true
+					group.MarkDirty(ctx.Dice)
+					ReplyToSender(ctx, msg, "已开启公平骰，将在下次 .log new 时生效")
+				case "off":
+					group.FairDiceOn = false
+					group.MarkDirty(ctx.Dice)
+					ReplyToSender(ctx, msg, "已关闭公平骰，进行中的会话将在 .log end 时公布种子")
+				default:
+					text := "公平骰: 关闭"
+					if group.FairDiceOn {
+						text = "公平骰: 开启"
+					}
+					if session, ok := group.FairDiceCurrent(); ok {
+						text += fmt.Sprintf("\n进行中的记录: %s\n承诺: %s\n已分配指令序号: %d", session.LogName, session.Commitment, session.Counter)
+					}
+					ReplyToSender(ctx, msg, text)
+				}
 				return CmdExecuteResult{Matched: true, Solved: true}
 			} else if cmdArgs.IsArgEqual(1, "stat") {
 				// group := ctx.Group
@@ -647,6 +696,8 @@ func RegisterBuiltinExtLog(self *Dice) {
 
 	helpStat := `.stat log [<日志名>] // 查看当前或指定日志的骰点统计
 .stat log [<日志名>] --all // 查看全团
+.stat me // 查看自己在所有日志中的检定统计(按角色)
+.stat group // 查看本群所有日志中各角色的检定统计与欧非排名
 .stat help // 帮助
 `
 	cmdStat := &CmdItemInfo{
@@ -701,6 +752,31 @@ func RegisterBuiltinExtLog(self *Dice) {
 				if err != nil || len(items) == 0 {
 					ReplyToSender(ctx, msg, "没有发现可供统计的信息，请确保记录名正确，且有进行骰点/检定行为")
 				}
+			case "me", "group":
+				if err := ctx.Dice.LogStatsUpdate(); err != nil {
+					ctx.Dice.Logger.Errorf("检定统计更新失败: %v", err)
+					ReplyToSender(ctx, msg, "统计更新失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				var text string
+				var err error
+				if strings.EqualFold(val, "me") {
+					text, err = LogStatTextForUser(ctx, ctx.Player.UserID, ctx.Player.Name)
+				} else {
+					if ctx.IsPrivate {
+						ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:提示_私聊不可用"))
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					text, err = LogStatTextForGroup(ctx, ctx.Group.GroupID)
+				}
+				switch {
+				case err != nil:
+					ReplyToSender(ctx, msg, "获取统计出错: "+err.Error())
+				case text == "":
+					ReplyToSender(ctx, msg, "还没有可供统计的检定记录，统计只包含日志开启期间的非暗骰检定")
+				default:
+					ReplyToSender(ctx, msg, text)
+				}
 			default:
 				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
 			}
@@ -987,7 +1063,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 						Message:     msg.Message,
 						IsDice:      true,
 						CommandID:   ctx.CommandID,
-						CommandInfo: ctx.CommandInfo,
+						CommandInfo: fairDiceCommandInfo(ctx),
 						RawMsgID:    msg.RawID,
 					}
 
@@ -1018,7 +1094,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 						Message:     msg.Message,
 						IsDice:      true,
 						CommandID:   ctx.CommandID,
-						CommandInfo: ctx.CommandInfo,
+						CommandInfo: fairDiceCommandInfo(ctx),
 						RawMsgID:    msg.RawID,
 					}
 					LogAppend(ctx, groupInfo.GroupID, logState.ID, logState.Name, &a)
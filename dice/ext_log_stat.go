@@ -0,0 +1,128 @@
+package dice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sealdice-core/dice/service"
+)
+
+// logStatMinChecks 参与欧非排名所需的最少检定次数
+const logStatMinChecks = 10
+
+var logStatSkillNameRe = regexp.MustCompile(`^([^\d\s]+)(\d+)?$`)
+
+// LogStatsUpdate 增量更新跨日志检定统计，目前只统计coc7，技能名按coc7模板的同义词归一
+func (d *Dice) LogStatsUpdate() error {
+	_, err := service.LogStatsUpdate(d.DBOperator, d.logStatSkillName())
+	return err
+}
+
+// logStatSkillName 去掉技能名后的数值，并按coc7模板的同义词归一
+func (d *Dice) logStatSkillName() func(string) string {
+	tmpl, _ := d.GameSystemMap.Load("coc7")
+	return func(s string) string {
+		if m := logStatSkillNameRe.FindStringSubmatch(s); len(m) > 0 {
+			s = m[1]
+		}
+		if tmpl != nil {
+			s = tmpl.GetAlias(s)
+		}
+		return s
+	}
+}
+
+// logStatFormatLine 一行统计概要
+func logStatFormatLine(s *service.LogStatSummary) string {
+	t := &s.Total
+	var b strings.Builder
+	fmt.Fprintf(&b, "检定%d次 成功率%.0f%%", t.Checks, s.SuccessRate*100)
+	if t.Crits > 0 || t.Fumbles > 0 {
+		fmt.Fprintf(&b, " 大成功%d 大失败%d", t.Crits, t.Fumbles)
+	}
+	if t.D100Count > 0 {
+		fmt.Fprintf(&b, " 出目均值%.1f(期望50.5)", s.D100Avg)
+	}
+	if t.SanChecks > 0 {
+		fmt.Fprintf(&b, " 理智损失%d", t.SanLoss)
+	}
+	if t.BestRun > 1 || t.WorstRun > 1 {
+		fmt.Fprintf(&b, " 最长连成%d 最长连败%d", t.BestRun, t.WorstRun)
+	}
+	return b.String()
+}
+
+// logStatFormatSkills 列出检定次数最多的几项技能
+func logStatFormatSkills(s *service.LogStatSummary, limit int) string {
+	var parts []string
+	for _, sk := range s.Skills {
+		if sk.Skill == service.LogStatSanSkill {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s%d(%.0f%%)", sk.Skill, sk.Checks, sk.SuccessRate()*100))
+		if len(parts) >= limit {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// LogStatTextForUser 某个用户在所有群的检定统计，按角色列出
+func LogStatTextForUser(ctx *MsgContext, userID string, name string) (string, error) {
+	rows, err := service.LogStatsQuery(ctx.Dice.DBOperator, &service.QueryLogStat{UserID: userID})
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	var b strings.Builder
+	all := service.LogStatsSummarize(rows, false)[0]
+	fmt.Fprintf(&b, "<%s>的跨日志检定统计(%d个群):\n%s", name, all.Groups, logStatFormatLine(all))
+	for _, s := range service.LogStatsSummarize(rows, true) {
+		fmt.Fprintf(&b, "\n\n[%s] %s", s.PcName, logStatFormatLine(s))
+		if skills := logStatFormatSkills(s, 5); skills != "" {
+			b.WriteString("\n常用技能: " + skills)
+		}
+	}
+	return b.String(), nil
+}
+
+// LogStatTextForGroup 群内各角色的检定统计，附带欧非排名
+func LogStatTextForGroup(ctx *MsgContext, groupID string) (string, error) {
+	rows, err := service.LogStatsQuery(ctx.Dice.DBOperator, &service.QueryLogStat{GroupID: groupID})
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	summaries := service.LogStatsSummarize(rows, true)
+	var b strings.Builder
+	b.WriteString("本群跨日志检定统计:")
+	var luckiest, unluckiest *service.LogStatSummary
+	for idx, s := range summaries {
+		if idx < 10 {
+			fmt.Fprintf(&b, "\n%d. [%s] %s", idx+1, s.PcName, logStatFormatLine(s))
+		}
+		if s.Total.D100Count < logStatMinChecks {
+			continue
+		}
+		// coc规则下出目越小越好
+		if luckiest == nil || s.D100Avg < luckiest.D100Avg {
+			luckiest = s
+		}
+		if unluckiest == nil || s.D100Avg > unluckiest.D100Avg {
+			unluckiest = s
+		}
+	}
+	if len(summaries) > 10 {
+		fmt.Fprintf(&b, "\n……共%d名角色", len(summaries))
+	}
+	if luckiest != nil && luckiest != unluckiest {
+		fmt.Fprintf(&b, "\n\n最欧: [%s] 出目均值%.1f\n最非: [%s] 出目均值%.1f",
+			luckiest.PcName, luckiest.D100Avg, unluckiest.PcName, unluckiest.D100Avg)
+	}
+	return b.String(), nil
+}
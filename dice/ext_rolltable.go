@@ -0,0 +1,698 @@
+package dice
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 随机表
+//
+// 与牌堆按权重抽取不同，随机表按骰子点数区间取结果，如 d100: 01-15 食尸鬼，16-40 ...
+// 表项文本中可以用 [[表名]] 引用其他随机表，用 {表达式} 嵌入掷骰。
+// 文件放在 data/tables 下，支持 yaml/json/csv，json 还能识别 Foundry VTT 与 Roll20 导出的表格。
+
+const rollTableMaxDepth = 10
+
+// RollTableRange 表项的点数区间，可以写作 "01-15"、"96-00"、5 或 [1, 15]
+type RollTableRange struct {
+	Min int64 `jsbind:"min"`
+	Max int64 `jsbind:"max"`
+}
+
+// RollTableEntry 随机表的一项
+type RollTableEntry struct {
+	Range  RollTableRange `json:"range"            jsbind:"range"`
+	Weight int64          `json:"weight,omitempty" jsbind:"weight"` // 未给出区间时，按权重顺延分配区间
+	Text   string         `json:"text"             jsbind:"text"`
+}
+
+// RollTable 随机表
+type RollTable struct {
+	Name    string            `json:"name"             jsbind:"name"`
+	Desc    string            `json:"desc,omitempty"   jsbind:"desc"`
+	Author  string            `json:"author,omitempty" jsbind:"author"`
+	Tags    []string          `json:"tags,omitempty"   jsbind:"tags"`
+	Dice    string            `json:"dice,omitempty"   jsbind:"dice"` // 掷骰表达式，为空时按最大点数推算，如 d100
+	Entries []*RollTableEntry `json:"entries"          jsbind:"entries"`
+
+	Format    string `json:"format"              jsbind:"format"`    // seal / foundry / roll20 / csv
+	Filename  string `json:"filename,omitempty"  jsbind:"filename"`  // 来源文件，脚本注册的为空
+	PackageID string `json:"packageId,omitempty" jsbind:"packageId"` // 所属扩展包ID，空表示独立安装
+}
+
+// RollTableResult 一次掷表的结果
+type RollTableResult struct {
+	Roll  int64           `json:"roll"  jsbind:"roll"`
+	Text  string          `json:"text"  jsbind:"text"`
+	Entry *RollTableEntry `json:"entry" jsbind:"entry"` // 点数没有对应表项时为空
+}
+
+var (
+	rollTableRangeSepRe = regexp.MustCompile(`\s*[-–—~～]\s*`)
+	rollTableRefRe      = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+	rollTableDiceRe     = regexp.MustCompile(`^(?i)\d*d\d+$`)
+)
+
+// parseRollTableRangeNum 解析区间端点，全零表示满值，如 d100 表中的 00
+func parseRollTableRangeNum(s string) (int64, error) {
+	if s != "" && strings.Trim(s, "0") == "" && len(s) > 1 {
+		n, _ := strconv.ParseInt("1"+s, 10, 64)
+		return n, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ParseRollTableRange 解析 "01-15" "96-00" "7" 形式的区间
+func ParseRollTableRange(s string) (RollTableRange, error) {
+	s = strings.TrimSpace(s)
+	parts := rollTableRangeSepRe.Split(s, 2)
+	lo, err := parseRollTableRangeNum(parts[0])
+	if err != nil {
+		return RollTableRange{}, fmt.Errorf("无效的区间: %s", s)
+	}
+	hi := lo
+	if len(parts) == 2 {
+		if hi, err = parseRollTableRangeNum(parts[1]); err != nil {
+			return RollTableRange{}, fmt.Errorf("无效的区间: %s", s)
+		}
+	}
+	if lo > hi {
+		return RollTableRange{}, fmt.Errorf("区间下限大于上限: %s", s)
+	}
+	return RollTableRange{Min: lo, Max: hi}, nil
+}
+
+func (r RollTableRange) IsZero() bool {
+	return r.Min == 0 && r.Max == 0
+}
+
+func (r RollTableRange) String() string {
+	if r.Min == r.Max {
+		return strconv.FormatInt(r.Min, 10)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+func (r RollTableRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *RollTableRange) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case nil:
+		*r = RollTableRange{}
+	case float64:
+		*r = RollTableRange{Min: int64(val), Max: int64(val)}
+	case string:
+		if val == "" {
+			*r = RollTableRange{}
+			return nil
+		}
+		parsed, err := ParseRollTableRange(val)
+		if err != nil {
+			return err
+		}
+		*r = parsed
+	case []any:
+		if len(val) != 2 {
+			return errors.New("区间数组需要两个元素")
+		}
+		a, ok1 := val[0].(float64)
+		b, ok2 := val[1].(float64)
+		if !ok1 || !ok2 || a > b {
+			return fmt.Errorf("无效的区间: %v", val)
+		}
+		*r = RollTableRange{Min: int64(a), Max: int64(b)}
+	default:
+		return fmt.Errorf("无效的区间: %v", val)
+	}
+	return nil
+}
+
+// UnmarshalJSON 表项可以直接写成字符串，此时权重为1
+func (e *RollTableEntry) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*e = RollTableEntry{Text: text}
+		return nil
+	}
+	type entryRaw RollTableEntry
+	var raw entryRaw
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = RollTableEntry(raw)
+	return nil
+}
+
+// normalize 为没有区间的表项按权重分配区间，并推算掷骰表达式
+func (t *RollTable) normalize() error {
+	if t.Name == "" {
+		return errors.New("随机表缺少名称")
+	}
+	if len(t.Entries) == 0 {
+		return fmt.Errorf("随机表<%s>没有任何表项", t.Name)
+	}
+	var next int64 = 1
+	var maxValue int64
+	for _, e := range t.Entries {
+		if e.Range.IsZero() {
+			weight := max(e.Weight, 1)
+			e.Range = RollTableRange{Min: next, Max: next + weight - 1}
+		}
+		next = e.Range.Max + 1
+		maxValue = max(maxValue, e.Range.Max)
+	}
+	if t.Dice == "" {
+		t.Dice = "d" + strconv.FormatInt(maxValue, 10)
+	}
+	return nil
+}
+
+// Find 查找点数所在的表项
+func (t *RollTable) Find(value int64) *RollTableEntry {
+	for _, e := range t.Entries {
+		if e.Range.Min <= value && value <= e.Range.Max {
+			return e
+		}
+	}
+	return nil
+}
+
+type rollTableSealFormat struct {
+	Tables []*RollTable `json:"tables"`
+}
+
+// rollTableFoundryFormat Foundry VTT 的 RollTable 导出格式
+type rollTableFoundryFormat struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Formula     string `json:"formula"`
+	Results     []struct {
+		Text               string         `json:"text"`
+		Name               string         `json:"name"`
+		Description        string         `json:"description"`
+		Range              RollTableRange `json:"range"`
+		Weight             int64          `json:"weight"`
+		DocumentCollection string         `json:"documentCollection"`
+	} `json:"results"`
+}
+
+// rollTableRoll20Format Roll20 可掷骰表格的导出格式，按权重抽取
+type rollTableRoll20Format struct {
+	Name  string `json:"name"`
+	Items []struct {
+		Name   string `json:"name"`
+		Weight int64  `json:"weight"`
+	} `json:"items"`
+}
+
+var (
+	rollTableHTMLTagRe     = regexp.MustCompile(`<[^>]+>`)
+	rollTableVTTInlineRe   = regexp.MustCompile(`\[\[(?:/(?:r|roll|gmroll|br|blindroll|sr|selfroll)\s+)?([^\[\]]+?)\]\]`)
+	rollTableVTTDocumentRe = regexp.MustCompile(`@(?:UUID|RollTable)\[[^\]]*\]\{([^}]+)\}`)
+)
+
+// convertVTTText 将 VTT 文本中的内联掷骰 [[/r 1d6]] 转换为 {1d6}，表格链接转换为 [[表名]]
+func convertVTTText(text string) string {
+	text = rollTableHTMLTagRe.ReplaceAllString(text, "")
+	text = rollTableVTTInlineRe.ReplaceAllString(text, "{$1}")
+	text = rollTableVTTDocumentRe.ReplaceAllString(text, "[[$1]]")
+	return strings.TrimSpace(text)
+}
+
+// ParseRollTables 解析随机表文件内容，name 为文件名，用于推断格式和缺省的表名
+func ParseRollTables(name string, content []byte) ([]*RollTable, error) {
+	if isPrefixWithUtf8Bom(content) {
+		content = content[3:]
+	}
+	baseName := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+
+	var tables []*RollTable
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		var t *RollTable
+		t, err = parseRollTableCSV(baseName, content)
+		tables = []*RollTable{t}
+	case ".yaml", ".yml":
+		var v any
+		if err = yaml.Unmarshal(content, &v); err != nil {
+			return nil, err
+		}
+		if content, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+		tables, err = parseRollTableJSON(baseName, content)
+	default:
+		tables, err = parseRollTableJSON(baseName, content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		if err = t.normalize(); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+func parseRollTableJSON(baseName string, content []byte) ([]*RollTable, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, err
+	}
+
+	if _, ok := keys["tables"]; ok {
+		var f rollTableSealFormat
+		if err := json.Unmarshal(content, &f); err != nil {
+			return nil, err
+		}
+		for _, t := range f.Tables {
+			t.Format = "seal"
+		}
+		return f.Tables, nil
+	}
+
+	if _, ok := keys["results"]; ok {
+		var f rollTableFoundryFormat
+		if err := json.Unmarshal(content, &f); err != nil {
+			return nil, err
+		}
+		t := &RollTable{Name: f.Name, Desc: convertVTTText(f.Description), Format: "foundry"}
+		if f.Formula != "" {
+			t.Dice = strings.TrimSpace(f.Formula)
+		}
+		for _, r := range f.Results {
+			text := r.Text
+			if text == "" {
+				text = r.Description
+			}
+			if text == "" {
+				text = r.Name
+			}
+			text = convertVTTText(text)
+			if strings.Contains(r.DocumentCollection, "RollTable") {
+				text = "[[" + text + "]]"
+			}
+			t.Entries = append(t.Entries, &RollTableEntry{Range: r.Range, Weight: r.Weight, Text: text})
+		}
+		if t.Name == "" {
+			t.Name = baseName
+		}
+		return []*RollTable{t}, nil
+	}
+
+	if _, ok := keys["items"]; ok {
+		var f rollTableRoll20Format
+		if err := json.Unmarshal(content, &f); err != nil {
+			return nil, err
+		}
+		t := &RollTable{Name: f.Name, Format: "roll20"}
+		for _, item := range f.Items {
+			t.Entries = append(t.Entries, &RollTableEntry{Weight: item.Weight, Text: convertVTTText(item.Name)})
+		}
+		if t.Name == "" {
+			t.Name = baseName
+		}
+		return []*RollTable{t}, nil
+	}
+
+	t := new(RollTable)
+	if err := json.Unmarshal(content, t); err != nil {
+		return nil, err
+	}
+	if t.Name == "" {
+		t.Name = baseName
+	}
+	t.Format = "seal"
+	return []*RollTable{t}, nil
+}
+
+// parseRollTableCSV 每行为 区间,文本 或仅有文本，首行可以是表头，表头第一格形如 d100 时作为掷骰表达式
+func parseRollTableCSV(baseName string, content []byte) (*RollTable, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	t := &RollTable{Name: baseName, Format: "csv"}
+	for idx, rec := range records {
+		if len(rec) == 0 || (len(rec) == 1 && strings.TrimSpace(rec[0]) == "") {
+			continue
+		}
+		first := strings.TrimSpace(rec[0])
+		if len(rec) == 1 {
+			t.Entries = append(t.Entries, &RollTableEntry{Text: first})
+			continue
+		}
+		rng, err := ParseRollTableRange(first)
+		if err != nil {
+			if idx == 0 {
+				// 表头
+				if rollTableDiceRe.MatchString(first) {
+					t.Dice = first
+				}
+				continue
+			}
+			return nil, fmt.Errorf("第%d行: %w", idx+1, err)
+		}
+		t.Entries = append(t.Entries, &RollTableEntry{Range: rng, Text: strings.TrimSpace(strings.Join(rec[1:], ","))})
+	}
+	return t, nil
+}
+
+// RollTableFind 按名称查找随机表，忽略大小写
+func (d *Dice) RollTableFind(name string) *RollTable {
+	name = strings.TrimSpace(name)
+	for _, t := range d.RollTables() {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// RollTables 当前全部随机表的快照
+func (d *Dice) RollTables() []*RollTable {
+	d.rollTableLock.RLock()
+	defer d.rollTableLock.RUnlock()
+	return slices.Clone(d.RollTableList)
+}
+
+// rollTableMatch 按最长的表名匹配参数，表名可以含空格，返回匹配的表和表名之后的剩余部分
+func (d *Dice) rollTableMatch(text string) (*RollTable, string) {
+	text = strings.TrimSpace(text)
+	var matched *RollTable
+	var rest string
+	for _, t := range d.RollTables() {
+		if len(text) < len(t.Name) || !strings.EqualFold(text[:len(t.Name)], t.Name) {
+			continue
+		}
+		tail := text[len(t.Name):]
+		if tail != "" && tail[0] != ' ' {
+			continue
+		}
+		if matched == nil || len(t.Name) > len(matched.Name) {
+			matched, rest = t, strings.TrimSpace(tail)
+		}
+	}
+	return matched, rest
+}
+
+func rollTablePrepare(t *RollTable) error {
+	if err := t.normalize(); err != nil {
+		return err
+	}
+	if t.Format == "" {
+		t.Format = "seal"
+	}
+	return nil
+}
+
+// rollTableUpsert 把表加入列表，同名的表会被替换
+func rollTableUpsert(list []*RollTable, t *RollTable) []*RollTable {
+	for idx, old := range list {
+		if strings.EqualFold(old.Name, t.Name) {
+			list[idx] = t
+			return list
+		}
+	}
+	return append(list, t)
+}
+
+// RollTableRegister 注册随机表，同名的表会被替换
+func (d *Dice) RollTableRegister(t *RollTable) error {
+	if err := rollTablePrepare(t); err != nil {
+		return err
+	}
+	d.rollTableLock.Lock()
+	defer d.rollTableLock.Unlock()
+	d.RollTableList = rollTableUpsert(d.RollTableList, t)
+	return nil
+}
+
+// RollTableSearch 按名称、描述和标签搜索随机表
+func (d *Dice) RollTableSearch(keyword string) []*RollTable {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return nil
+	}
+	var result []*RollTable
+	for _, t := range d.RollTables() {
+		text := strings.ToLower(t.Name + "\n" + t.Desc + "\n" + strings.Join(t.Tags, "\n"))
+		if strings.Contains(text, keyword) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// rollTableFindHint .find 结果中附带的随机表搜索结果
+func rollTableFindHint(d *Dice, keyword string) string {
+	tables := d.RollTableSearch(keyword)
+	if len(tables) == 0 {
+		return ""
+	}
+	names := make([]string, 0, 5)
+	for _, t := range tables[:min(len(tables), 5)] {
+		names = append(names, t.Name)
+	}
+	return fmt.Sprintf("相关随机表: %s\n使用\".rt <表名>\"掷随机表\n", strings.Join(names, "、"))
+}
+
+func rollTableTryLoad(d *Dice, fn string, packageID string) []*RollTable {
+	content, err := os.ReadFile(fn)
+	if err != nil {
+		d.Logger.Infof("随机表文件“%s”加载失败", fn)
+		return nil
+	}
+	tables, err := ParseRollTables(fn, content)
+	if err != nil {
+		d.Logger.Errorf("随机表文件“%s”解析失败 %v", fn, err)
+		return nil
+	}
+	loaded := make([]*RollTable, 0, len(tables))
+	for _, t := range tables {
+		t.Filename = fn
+		t.PackageID = packageID
+		if rollTablePrepare(t) == nil {
+			loaded = append(loaded, t)
+		}
+	}
+	return loaded
+}
+
+func isRollTableFile(fn string) bool {
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".json", ".yaml", ".yml", ".csv":
+		return true
+	}
+	return false
+}
+
+// RollTableReload 从 data/tables 和扩展包重新加载随机表，脚本注册的表保留
+func RollTableReload(d *Dice) {
+	// 先读完全部文件再整体替换，加载期间 .rt 仍能使用旧的列表
+	var loaded []*RollTable
+	_ = filepath.WalkDir("data/tables", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if isRollTableFile(path) {
+			loaded = append(loaded, rollTableTryLoad(d, path, "")...)
+		}
+		return nil
+	})
+	if d.PackageManager != nil {
+		for _, f := range d.PackageManager.GetEnabledContentFiles("tables") {
+			if isRollTableFile(f.Path) {
+				d.Logger.Infof("正在加载扩展包 %s 的随机表: %s", f.PackageID, f.Path)
+				loaded = append(loaded, rollTableTryLoad(d, f.Path, f.PackageID)...)
+			}
+		}
+	}
+
+	d.rollTableLock.Lock()
+	var tables []*RollTable
+	for _, t := range d.RollTableList {
+		if t.Filename == "" {
+			tables = append(tables, t)
+		}
+	}
+	for _, t := range loaded {
+		tables = rollTableUpsert(tables, t)
+	}
+	d.RollTableList = tables
+	d.rollTableLock.Unlock()
+	d.Logger.Infof("加载完成，现有随机表 %d 个", len(tables))
+}
+
+// RollTableRoll 掷一次随机表，表项中的引用和表达式会被展开
+func RollTableRoll(ctx *MsgContext, t *RollTable) (*RollTableResult, error) {
+	return rollTableRoll(ctx, t, 0)
+}
+
+func rollTableRoll(ctx *MsgContext, t *RollTable, depth int) (*RollTableResult, error) {
+	if depth >= rollTableMaxDepth {
+		return nil, fmt.Errorf("随机表<%s>引用层数过多", t.Name)
+	}
+	r := ctx.Eval(t.Dice, nil)
+	if r.vm.Error != nil {
+		return nil, fmt.Errorf("随机表<%s>的掷骰表达式错误: %s", t.Name, r.vm.Error.Error())
+	}
+	value, ok := r.ReadInt()
+	if !ok {
+		return nil, fmt.Errorf("随机表<%s>的掷骰结果不是数字", t.Name)
+	}
+	entry := t.Find(int64(value))
+	if entry == nil {
+		return &RollTableResult{Roll: int64(value)}, nil
+	}
+
+	var err error
+	text := rollTableRefRe.ReplaceAllStringFunc(entry.Text, func(s string) string {
+		if err != nil {
+			return s
+		}
+		name := rollTableRefRe.FindStringSubmatch(s)[1]
+		sub := ctx.Dice.RollTableFind(name)
+		if sub == nil {
+			err = fmt.Errorf("随机表<%s>引用的表<%s>不存在", t.Name, name)
+			return s
+		}
+		var ret *RollTableResult
+		ret, err = rollTableRoll(ctx, sub, depth+1)
+		if err != nil {
+			return s
+		}
+		return ret.Text
+	})
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(text, "{") {
+		if formatted, err2 := DiceFormatV2(ctx, text); err2 == nil {
+			text = formatted
+		}
+	}
+	return &RollTableResult{Roll: int64(value), Text: text, Entry: entry}, nil
+}
+
+func getCmdRollTable(d *Dice) *CmdItemInfo {
+	helpRt := "" +
+		".rt <表名> [次数] // 掷随机表，次数最多10次\n" +
+		".rt list // 查看已载入的随机表\n" +
+		".rt search <关键字> // 搜索随机表\n" +
+		".rt show <表名> // 查看随机表内容\n" +
+		".rt reload // 从硬盘重新装载随机表，仅Master可用"
+
+	return &CmdItemInfo{
+		Name:      "rt",
+		ShortHelp: helpRt,
+		Help:      "随机表:\n" + helpRt,
+		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
+			val := cmdArgs.GetArgN(1)
+			switch strings.ToLower(val) {
+			case "", "help":
+				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+			case "list", "search":
+				tables := d.RollTables()
+				if strings.EqualFold(val, "search") {
+					tables = d.RollTableSearch(cmdArgs.GetArgN(2))
+				}
+				var lines []string
+				for _, t := range tables {
+					line := fmt.Sprintf("- %s (%s, %d项)", t.Name, t.Dice, len(t.Entries))
+					if t.Desc != "" {
+						line += " " + t.Desc
+					}
+					lines = append(lines, line)
+				}
+				VarSetValueStr(ctx, "$t随机表列表", strings.Join(lines, "\n"))
+				if len(lines) == 0 {
+					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "其它:随机表_列表_没有随机表"))
+				} else {
+					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "其它:随机表_列表"))
+				}
+				return CmdExecuteResult{Matched: true, Solved: true}
+			case "show":
+				name := cmdArgs.GetRestArgsFrom(2)
+				t := d.RollTableFind(name)
+				if t == nil {
+					VarSetValueStr(ctx, "$t随机表", name)
+					ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "其它:随机表_找不到随机表"))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				var sb strings.Builder
+				fmt.Fprintf(&sb, "随机表: %s (%s)\n", t.Name, t.Dice)
+				if t.Desc != "" {
+					sb.WriteString(t.Desc + "\n")
+				}
+				for _, e := range t.Entries {
+					fmt.Fprintf(&sb, "%s: %s\n", e.Range, e.Text)
+				}
+				ReplyToSender(ctx, msg, strings.TrimSpace(sb.String()))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			case "reload":
+				if ctx.PrivilegeLevel < 100 {
+					ReplyToSender(ctx, msg, "你不具备Master权限")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				RollTableReload(d)
+				ReplyToSender(ctx, msg, fmt.Sprintf("随机表已重新装载，现有 %d 个", len(d.RollTables())))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+
+			// 表名可以含空格，先按最长表名匹配，剩余部分才是次数
+			t, rest := d.rollTableMatch(cmdArgs.GetRestArgsFrom(1))
+			VarSetValueStr(ctx, "$t随机表", val)
+			if t == nil {
+				ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "其它:随机表_找不到随机表"))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+			VarSetValueStr(ctx, "$t随机表", t.Name)
+
+			times := 1
+			if n, err := strconv.Atoi(rest); err == nil {
+				times = min(max(n, 1), 10)
+			}
+			var lines []string
+			for range times {
+				ret, err := RollTableRoll(ctx, t)
+				if err != nil {
+					ReplyToSender(ctx, msg, err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				text := ret.Text
+				if ret.Entry == nil {
+					text = "(无对应表项)"
+				}
+				lines = append(lines, fmt.Sprintf("[%d] %s", ret.Roll, text))
+			}
+			VarSetValueStr(ctx, "$t随机表结果", strings.Join(lines, DiceFormatTmpl(ctx, "其它:随机表_分隔符")))
+			ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "其它:随机表_结果"))
+			return CmdExecuteResult{Matched: true, Solved: true}
+		},
+	}
+}
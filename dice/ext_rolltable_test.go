@@ -0,0 +1,153 @@
+//nolint:testpackage
+package dice
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRollTableRange(t *testing.T) {
+	cases := map[string]RollTableRange{
+		"01-15":   {1, 15},
+		"16 – 40": {16, 40},
+		"96-00":   {96, 100},
+		"00":      {100, 100},
+		"7":       {7, 7},
+	}
+	for s, want := range cases {
+		got, err := ParseRollTableRange(s)
+		if err != nil || got != want {
+			t.Errorf("ParseRollTableRange(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "abc", "40-16"} {
+		if _, err := ParseRollTableRange(s); err == nil {
+			t.Errorf("ParseRollTableRange(%q) 应当报错", s)
+		}
+	}
+}
+
+func TestParseRollTables(t *testing.T) {
+	yamlContent := `
+tables:
+  - name: 遭遇
+    dice: d100
+    tags: [怪物]
+    entries:
+      - range: 01-15
+        text: 食尸鬼
+      - range: 16-00
+        text: "[[宝物]]"
+  - name: 宝物
+    entries: [金币, 银币, 铜币]
+`
+	tables, err := ParseRollTables("encounter.yaml", []byte(yamlContent))
+	if err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	if len(tables) != 2 || tables[0].Entries[1].Range != (RollTableRange{16, 100}) {
+		t.Fatalf("yaml tables = %+v", tables)
+	}
+	if tables[1].Dice != "d3" || tables[1].Entries[2].Range != (RollTableRange{3, 3}) {
+		t.Fatalf("权重表 = %+v", tables[1])
+	}
+
+	foundry := `{"name":"Wild Magic","formula":"1d4","description":"<p>Surge</p>","results":[
+		{"text":"<p>Take [[/r 1d6]] damage</p>","range":[1,2]},
+		{"text":"Sub","range":[3,4],"documentCollection":"RollTable"}]}`
+	tables, err = ParseRollTables("wild.json", []byte(foundry))
+	if err != nil {
+		t.Fatalf("foundry: %v", err)
+	}
+	ft := tables[0]
+	if ft.Format != "foundry" || ft.Dice != "1d4" || ft.Desc != "Surge" ||
+		ft.Entries[0].Text != "Take {1d6} damage" || ft.Entries[1].Text != "[[Sub]]" {
+		t.Fatalf("foundry table = %+v %+v %+v", ft, ft.Entries[0], ft.Entries[1])
+	}
+
+	roll20 := `{"name":"Loot","items":[{"name":"Sword","weight":3},{"name":"Shield","weight":1}]}`
+	tables, err = ParseRollTables("loot.json", []byte(roll20))
+	if err != nil {
+		t.Fatalf("roll20: %v", err)
+	}
+	if tables[0].Dice != "d4" || tables[0].Entries[1].Range != (RollTableRange{4, 4}) {
+		t.Fatalf("roll20 table = %+v", tables[0])
+	}
+
+	csvContent := "d6,结果\n1-3,逃跑\n4-6,\"战斗, 然后逃跑\"\n"
+	tables, err = ParseRollTables("反应.csv", []byte(csvContent))
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if tables[0].Name != "反应" || tables[0].Dice != "d6" || tables[0].Entries[1].Text != "战斗, 然后逃跑" {
+		t.Fatalf("csv table = %+v", tables[0])
+	}
+
+	if _, err = ParseRollTables("bad.csv", []byte("1-3,a\nx-y,b\n")); err == nil {
+		t.Fatalf("csv 区间错误应当报错")
+	}
+}
+
+func TestRollTableCommand(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+
+	tables, err := ParseRollTables("t.yaml", []byte(`
+tables:
+  - name: 遭遇
+    dice: "1"
+    desc: 野外遭遇
+    entries:
+      - range: 1
+        text: "[[数量]]只食尸鬼"
+  - name: 数量
+    dice: "2"
+    entries:
+      - range: 2
+        text: "{1+1}"
+  - name: 循环
+    entries: ["[[循环]]"]
+  - name: 古堡
+    dice: "1"
+    entries: ["大厅"]
+  - name: 古堡 地下室
+    dice: "1"
+    entries: ["酒窖"]
+`))
+	if err != nil {
+		t.Fatalf("ParseRollTables() error = %v", err)
+	}
+	for _, tbl := range tables {
+		if err = d.RollTableRegister(tbl); err != nil {
+			t.Fatalf("RollTableRegister() error = %v", err)
+		}
+	}
+
+	send := func(text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg("QQ-Group:555", "QQ:999", text))
+	}
+
+	if got := send(".rt 遭遇 2"); strings.Count(got, "[1] 2只食尸鬼") != 2 {
+		t.Fatalf(".rt 遭遇 2 = %q", got)
+	}
+	// 表名含空格时按最长表名匹配，之后才是次数
+	if got := send(".rt 古堡 地下室 2"); strings.Count(got, "[1] 酒窖") != 2 {
+		t.Fatalf(".rt 古堡 地下室 2 = %q", got)
+	}
+	if got := send(".rt 古堡 3"); strings.Count(got, "[1] 大厅") != 3 {
+		t.Fatalf(".rt 古堡 3 = %q", got)
+	}
+	if got := send(".rt show 古堡 地下室"); !strings.Contains(got, "酒窖") {
+		t.Fatalf(".rt show 古堡 地下室 = %q", got)
+	}
+	if got := send(".rt 不存在"); !strings.Contains(got, "找不到随机表<不存在>") {
+		t.Fatalf(".rt 不存在 = %q", got)
+	}
+	if got := send(".rt 循环"); !strings.Contains(got, "引用层数过多") {
+		t.Fatalf(".rt 循环 = %q", got)
+	}
+	if got := send(".rt search 野外"); !strings.Contains(got, "遭遇") || strings.Contains(got, "数量") {
+		t.Fatalf(".rt search = %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+// Package exttest 扩展离线测试：在内存数据库上启动 dice.ExtHarness，供 go test 与命令行 --ext-test 使用。
+package exttest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"sealdice-core/dice"
+	v2 "sealdice-core/migrate/v2"
+	"sealdice-core/utils/dboperator/engine/sqlite"
+)
+
+// NewHarness 创建内存数据库并执行全部迁移，然后启动测试台，dataDir 用于存放扩展存储等文件
+func NewHarness(dataDir string, seed uint64) (*dice.ExtHarness, error) {
+	op := &sqlite.SQLiteEngine{DataDir: dataDir}
+	if err := op.InitMemory(context.Background()); err != nil {
+		return nil, err
+	}
+	if err := v2.InitUpgrader(op); err != nil {
+		op.Close()
+		return nil, fmt.Errorf("数据库迁移失败: %w", err)
+	}
+	return dice.NewExtHarness(op, dice.ExtHarnessOptions{DataDir: dataDir, Seed: seed})
+}
+
+// New 在测试中创建测试台，测试结束时自动关闭
+func New(t testing.TB, seed uint64) *dice.ExtHarness {
+	t.Helper()
+	h, err := NewHarness(t.TempDir(), seed)
+	if err != nil {
+		t.Fatalf("启动测试台失败: %v", err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+// RunScenarioFile 在新的测试台上执行剧本，每个失败的步骤都会报告为测试错误
+func RunScenarioFile(t testing.TB, path string) *dice.HarnessResult {
+	t.Helper()
+	sc, err := dice.LoadHarnessScenario(path)
+	if err != nil {
+		t.Fatalf("读取剧本失败: %v", err)
+	}
+	result, err := New(t, sc.Seed).RunScenario(sc)
+	if err != nil {
+		t.Fatalf("执行剧本失败: %v", err)
+	}
+	if result.Failed() {
+		t.Error(result.Report())
+	}
+	return result
+}
+
+func runFile(path string, out io.Writer) bool {
+	sc, err := dice.LoadHarnessScenario(path)
+	if err != nil {
+		fmt.Fprintf(out, "%s: %v\n", path, err)
+		return false
+	}
+	dataDir, err := os.MkdirTemp("", "sealdice-exttest-")
+	if err != nil {
+		fmt.Fprintf(out, "%s: %v\n", path, err)
+		return false
+	}
+	defer os.RemoveAll(dataDir)
+
+	h, err := NewHarness(dataDir, sc.Seed)
+	if err != nil {
+		fmt.Fprintf(out, "%s: %v\n", path, err)
+		return false
+	}
+	defer h.Close()
+	result, err := h.RunScenario(sc)
+	if err != nil {
+		fmt.Fprintf(out, "%s: %v\n", path, err)
+		return false
+	}
+	fmt.Fprintln(out, result.Report())
+	return !result.Failed()
+}
+
+// RunCLI 依次执行剧本文件并输出报告，全部通过时返回 0
+func RunCLI(paths []string, out io.Writer) int {
+	code := 0
+	for _, path := range paths {
+		if !runFile(path, out) {
+			code = 1
+		}
+	}
+	return code
+}
@@ -0,0 +1,174 @@
+package dice
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	rand2 "golang.org/x/exp/rand"
+
+	"sealdice-core/utils/fairdice"
+)
+
+// 公平骰(承诺-揭示)
+//
+// 群内开启后，每次 .log new 会生成新的种子并公布其承诺，此后该群每条指令分配一个递增序号，
+// 指令中的骰点全部由 种子+序号 推算，序号会写进日志的 CommandInfo(fairDice 字段)。
+// .log end 时公布种子，任何人都可以用 utils/fairdice/verify 核对承诺并复算骰点。
+
+// FairDiceSession 公平骰会话
+type FairDiceSession struct {
+	LogName    string `json:"logName"`
+	Seed       string `json:"seed"`       // 十六进制种子，结束前不公开
+	Commitment string `json:"commitment"` // 种子的 sha256
+	Counter    uint64 `json:"counter"`    // 已分配的指令序号
+}
+
+// FairDicePublic 公平骰会话中可以在种子公布前展示的部分
+type FairDicePublic struct {
+	LogName    string `json:"logName"`
+	Commitment string `json:"commitment"`
+	Counter    uint64 `json:"counter"`
+}
+
+// GroupInfoView 用于 WebUI 等接口输出的群信息，公平骰会话不含种子
+type GroupInfoView struct {
+	*GroupInfo
+}
+
+func (v GroupInfoView) MarshalJSON() ([]byte, error) {
+	g := v.GroupInfo
+	var public *FairDicePublic
+	if session, ok := g.FairDiceCurrent(); ok {
+		public = &FairDicePublic{LogName: session.LogName, Commitment: session.Commitment, Counter: session.Counter}
+	}
+	// 外层的 FairDice 字段会覆盖内嵌 GroupInfo 中的同名字段
+	return json.Marshal(&struct {
+		*groupInfoJSON
+		FairDice *FairDicePublic `json:"fairDice,omitempty"`
+	}{
+		groupInfoJSON: g.toJSON(),
+		FairDice:      public,
+	})
+}
+
+var fairDiceMu sync.Mutex
+
+// FairDiceStart 为群开始新的公平骰会话，会覆盖进行中的会话
+func (g *GroupInfo) FairDiceStart(logName string) (*FairDiceSession, error) {
+	seed, err := fairdice.NewSeed()
+	if err != nil {
+		return nil, err
+	}
+	commitment, err := fairdice.Commitment(seed)
+	if err != nil {
+		return nil, err
+	}
+	session := &FairDiceSession{LogName: logName, Seed: seed, Commitment: commitment}
+
+	fairDiceMu.Lock()
+	defer fairDiceMu.Unlock()
+	g.FairDice = session
+	return session, nil
+}
+
+// FairDiceEnd 结束群内的公平骰会话并返回它，没有会话时返回nil
+func (g *GroupInfo) FairDiceEnd() *FairDiceSession {
+	fairDiceMu.Lock()
+	defer fairDiceMu.Unlock()
+	session := g.FairDice
+	g.FairDice = nil
+	return session
+}
+
+// FairDiceCurrent 获取群内进行中的公平骰会话的副本
+func (g *GroupInfo) FairDiceCurrent() (FairDiceSession, bool) {
+	fairDiceMu.Lock()
+	defer fairDiceMu.Unlock()
+	if g.FairDice == nil {
+		return FairDiceSession{}, false
+	}
+	return *g.FairDice, true
+}
+
+// fairDiceAttach 若群内有进行中的公平骰会话，为本条指令分配序号并替换随机源
+func fairDiceAttach(ctx *MsgContext) {
+	if ctx.Group == nil || ctx.fairDiceCounter != 0 {
+		return
+	}
+	fairDiceMu.Lock()
+	session := ctx.Group.FairDice
+	if session == nil {
+		fairDiceMu.Unlock()
+		return
+	}
+	session.Counter++
+	counter, seed := session.Counter, session.Seed
+	fairDiceMu.Unlock()
+
+	src, err := fairdice.Source(seed, counter)
+	if err != nil {
+		ctx.Dice.Logger.Errorf("公平骰随机源生成失败: 群=%s err=%v", ctx.Group.GroupID, err)
+		return
+	}
+	ctx.Group.MarkDirty(ctx.Dice)
+	ctx.fairDiceCounter = counter
+	ctx._v1Rand = src
+	if ctx.vm != nil {
+		ctx.vm.RandSrc = src
+	}
+}
+
+// fairDiceCommandInfo 在指令信息中附上公平骰序号
+func fairDiceCommandInfo(ctx *MsgContext) any {
+	if ctx.fairDiceCounter == 0 {
+		return ctx.CommandInfo
+	}
+	info := map[string]any{}
+	if m, ok := ctx.CommandInfo.(map[string]any); ok {
+		for k, v := range m {
+			info[k] = v
+		}
+	} else if ctx.CommandInfo != nil {
+		info["info"] = ctx.CommandInfo
+	}
+	info["fairDice"] = ctx.fairDiceCounter
+	return info
+}
+
+// fairDiceReveal 结束群内的公平骰会话并公布种子
+func fairDiceReveal(ctx *MsgContext, msg *Message) {
+	session := ctx.Group.FairDiceEnd()
+	if session == nil {
+		return
+	}
+	ctx.Group.MarkDirty(ctx.Dice)
+	VarSetValueStr(ctx, "$t公平骰种子", session.Seed)
+	VarSetValueStr(ctx, "$t公平骰承诺", session.Commitment)
+	VarSetValueInt64(ctx, "$t公平骰指令数", int64(session.Counter))
+	ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "日志:记录_公平骰_公布"))
+}
+
+// FairDiceReplayOptions 返回与指令掷骰一致的公平骰复算方式
+//
+// sides 为掷骰时生效的默认骰子面数(个人或群设置)，不大于0时为100；
+// v1 为 true 时按 v1 引擎复算，用于自定义回复等仍在使用 v1 的场合。
+func FairDiceReplayOptions(sides int64, v1 bool) fairdice.ReplayOptions {
+	if sides <= 0 {
+		sides = 100
+	}
+	config := rollSyntaxConfig()
+	config.DefaultDiceSideExpr = strconv.FormatInt(sides, 10)
+	opts := fairdice.ReplayOptions{Config: config}
+	if v1 {
+		opts.Eval = func(src *rand2.PCGSource, expr string) (string, error) {
+			ctx := &MsgContext{Dice: &Dice{}, _v1Rand: src}
+			val, detail, err := ctx.Dice._ExprEvalBaseV1(expr, ctx, RollExtraFlags{DefaultDiceSideNum: sides})
+			if err != nil {
+				return "", err
+			}
+			return fairdice.ResultText(val.ToString(), detail), nil
+		}
+	}
+	return opts
+}
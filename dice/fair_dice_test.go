@@ -0,0 +1,89 @@
+//nolint:testpackage
+package dice
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sealdice-core/utils/fairdice"
+)
+
+func TestFairDiceRollMatchesReplay(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+
+	const groupID = "QQ-Group:666"
+	send := func(text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg(groupID, "QQ:999", text))
+	}
+
+	send(".r d100")
+	group, ok := d.ImSession.ServiceAtNew.Load(groupID)
+	if !ok {
+		t.Fatalf("group not found")
+	}
+	session, err := group.FairDiceStart("测试")
+	if err != nil {
+		t.Fatalf("FairDiceStart() error = %v", err)
+	}
+
+	for counter := uint64(1); counter <= 3; counter++ {
+		got := send(".r d1000000")
+		want, err := fairdice.Replay(session.Seed, counter, "d1000000")
+		if err != nil {
+			t.Fatalf("Replay() error = %v", err)
+		}
+		value := want[0][strings.LastIndex(want[0], "=")+1:]
+		if !strings.HasSuffix(strings.TrimSpace(got), "="+value) {
+			t.Fatalf("第%d条: 回复 %q 与复算结果 %q 不符", counter, got, want[0])
+		}
+	}
+
+	// 复算需要使用群内设置的默认面数
+	group.DiceSideNum = 20
+	got := send(".r d")
+	want, err := fairdice.ReplayWithOptions(session.Seed, 4, FairDiceReplayOptions(group.DiceSideNum, false), "d")
+	if err != nil {
+		t.Fatalf("ReplayWithOptions() error = %v", err)
+	}
+	if value := want[0][strings.LastIndex(want[0], "=")+1:]; !strings.HasSuffix(strings.TrimSpace(got), "="+value) {
+		t.Fatalf("默认面数: 回复 %q 与复算结果 %q 不符", got, want[0])
+	}
+
+	// 展示给 WebUI 的群信息不含种子，落盘的数据仍然保留
+	view, err := json.Marshal(GroupInfoView{GroupInfo: group})
+	if err != nil || strings.Contains(string(view), session.Seed) || !strings.Contains(string(view), session.Commitment) {
+		t.Fatalf("GroupInfoView = %s, %v", view, err)
+	}
+	if saved, _ := json.Marshal(group); !strings.Contains(string(saved), session.Seed) {
+		t.Fatalf("persisted group info lost seed: %s", saved)
+	}
+
+	ended := group.FairDiceEnd()
+	if ended == nil || ended.Counter != 4 || fairdice.Verify(ended.Seed, ended.Commitment) != nil {
+		t.Fatalf("FairDiceEnd() = %+v", ended)
+	}
+}
+
+func TestFairDiceReplayV1(t *testing.T) {
+	seed, err := fairdice.NewSeed()
+	if err != nil {
+		t.Fatalf("NewSeed() error = %v", err)
+	}
+	src, err := fairdice.Source(seed, 1)
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+	want := DiceRoll64x(src, 20)
+
+	got, err := fairdice.ReplayWithOptions(seed, 1, FairDiceReplayOptions(20, true), "d")
+	if err != nil {
+		t.Fatalf("ReplayWithOptions() error = %v", err)
+	}
+	if !strings.HasSuffix(got[0], "="+strconv.FormatInt(want, 10)) {
+		t.Fatalf("v1 replay = %q, want %d", got[0], want)
+	}
+}
@@ -58,9 +58,10 @@ func writeAttrsTemplateVersion(attrs *AttributesItem, version string) {
 
 // Commands wraps command-related configuration.
 type Commands struct {
-	Set SetConfig `yaml:"set"`
-	Sn  SnConfig  `yaml:"sn"`
-	St  StConfig  `yaml:"st"`
+	Set    SetConfig            `yaml:"set"`
+	Sn     SnConfig             `yaml:"sn"`
+	St     StConfig             `yaml:"st"`
+	Checks []CheckCommandConfig `yaml:"checks"`
 }
 
 // SetConfig configures the set command.
@@ -96,6 +97,43 @@ type StShowConfig struct {
 	ItemsPerLine      int               `yaml:"itemsPerLine"`
 }
 
+// InventoryConfig configures the inventory command.
+type InventoryConfig struct {
+	WeightUnit string           `yaml:"weightUnit"` // 重量单位，仅用于展示
+	Capacity   string           `yaml:"capacity"`   // 负重上限表达式，可留空
+	Currency   []CurrencyConfig `yaml:"currency"`   // 货币面额，按价值从高到低排列
+}
+
+// CurrencyConfig describes a currency denomination, Value is counted in the smallest unit.
+type CurrencyConfig struct {
+	Key     string   `yaml:"key"`
+	Name    string   `yaml:"name"`
+	Aliases []string `yaml:"aliases"`
+	Value   int64    `yaml:"value"`
+}
+
+// CheckCommandConfig declares a check command provided by the template.
+type CheckCommandConfig struct {
+	Name     string               `yaml:"name"`
+	Aliases  []string             `yaml:"aliases"`
+	Help     string               `yaml:"help"`
+	Roll     string               `yaml:"roll"`   // 掷骰表达式，默认 d20 + $t属性值
+	Target   string               `yaml:"target"` // 目标值表达式，可留空
+	Before   string               `yaml:"before"` // 掷骰前执行的 dicescript
+	After    string               `yaml:"after"`  // 判定后、输出前执行的 dicescript
+	Outcomes []CheckOutcomeConfig `yaml:"outcomes"`
+	Text     string               `yaml:"text"`    // 输出文本
+	TextKey  string               `yaml:"textKey"` // 输出文本对应的文本模板，分类为模板名
+}
+
+// CheckOutcomeConfig describes an outcome band, bands are matched in order.
+type CheckOutcomeConfig struct {
+	Name    string `yaml:"name"`
+	When    string `yaml:"when"` // 判定条件，留空视为总是成立
+	Text    string `yaml:"text"`
+	TextKey string `yaml:"textKey"`
+}
+
 // NameTemplateItem describes an sn template entry.
 type NameTemplateItem struct {
 	Template string `json:"template" yaml:"template"`
@@ -114,16 +152,17 @@ type LegacySetConfig struct {
 // GameSystemTemplate is the core template definition compatible with the smallseal format.
 // GameSystemTemplateV2 mirrors the template definition used in smallseal.
 type GameSystemTemplateV2 struct {
-	Name        string   `yaml:"name"`
-	FullName    string   `yaml:"fullName"`
-	Authors     []string `yaml:"authors"`
-	Version     string   `yaml:"version"`
-	UpdatedTime string   `yaml:"updatedTime"`
-	TemplateVer string   `yaml:"templateVer"`
-	InitScript  string   `yaml:"initScript"`
-	Attrs       Attrs    `yaml:"attrs"`
-	Alias       Alias    `yaml:"alias"`
-	Commands    Commands `yaml:"commands"`
+	Name        string          `yaml:"name"`
+	FullName    string          `yaml:"fullName"`
+	Authors     []string        `yaml:"authors"`
+	Version     string          `yaml:"version"`
+	UpdatedTime string          `yaml:"updatedTime"`
+	TemplateVer string          `yaml:"templateVer"`
+	InitScript  string          `yaml:"initScript"`
+	Attrs       Attrs           `yaml:"attrs"`
+	Alias       Alias           `yaml:"alias"`
+	Commands    Commands        `yaml:"commands"`
+	Inventory   InventoryConfig `yaml:"inventory"`
 
 	AliasMap          *SyncMap[string, string]                                                                                                                  `json:"-" yaml:"-"`
 	HookValueLoadPost func(ctx *ds.Context, name string, curVal *ds.VMValue, doCompute func(curVal *ds.VMValue) *ds.VMValue, detail *ds.BufferSpan) *ds.VMValue `json:"-" yaml:"-"`
@@ -373,6 +412,10 @@ func (t *GameSystemTemplate) Init() {
 		Keys:         append([]string(nil), t.Commands.Set.Keys...),
 		RelatedExt:   append([]string(nil), t.Commands.Set.RelatedExt...),
 	}
+	if len(t.Commands.Checks) > 0 {
+		// 切换到本规则时一并开启模板自带的检定指令
+		t.SetConfig.RelatedExt = append(t.SetConfig.RelatedExt, templateCheckExtName(t.Name))
+	}
 	if v, err := strconv.ParseInt(strings.TrimSpace(t.SetConfig.DiceSideExpr), 10, 64); err == nil {
 		t.SetConfig.DiceSides = v
 	}
@@ -0,0 +1,285 @@
+package dice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+// 模板检定指令
+//
+// 规则模板可以在 commands.checks 中声明检定指令，加载模板时会为其生成一个名为 tmpl-<模板名> 的扩展，
+// .set 切换到该规则时自动开启。检定流程:
+//  1. 执行模板的 initScript，解析参数，设置 $t属性 $t属性值 $t难度 $t原因
+//  2. 执行 before，再按 roll 掷骰得到 $t出目 和 $t检定过程，target 不为空时计算 $t目标值
+//  3. 依次判断 outcomes 的 when，第一个成立的档位写入 $t判定结果 与 $t结果文本
+//  4. 执行 after，输出 text
+//
+// 文本指定了 textKey 时会注册到以模板名为分类的自定义文本中，可以在UI中修改。
+
+const (
+	templateCheckDefaultRoll = "d20 + $t属性值"
+	templateCheckDefaultText = "{$t原因}<{$t玩家}>的{$t属性}检定: {$t检定过程}={$t出目} {$t结果文本}"
+)
+
+// templateCheckExtName 模板检定指令所在的扩展名
+func templateCheckExtName(tmplName string) string {
+	return "tmpl-" + tmplName
+}
+
+// syncTemplateCheckExt 按模板内容注册或更新检定扩展，模板不再声明检定时清空指令
+func (d *Dice) syncTemplateCheckExt(tmpl *GameSystemTemplate) {
+	extName := templateCheckExtName(tmpl.Name)
+	ext, exists := d.templateCheckExts.Load(tmpl.Name)
+	if !exists && len(tmpl.Commands.Checks) == 0 {
+		return
+	}
+
+	cmdMap := CmdMapCls{}
+	for idx := range tmpl.Commands.Checks {
+		check := &tmpl.Commands.Checks[idx]
+		if check.Name == "" {
+			d.Logger.Warnf("模板 %s 的第%d个检定指令缺少名称，已跳过", tmpl.Name, idx+1)
+			continue
+		}
+		item := d.newTemplateCheckCmd(tmpl.Name, check)
+		cmdMap[check.Name] = item
+		for _, alias := range check.Aliases {
+			if _, ok := cmdMap[alias]; !ok {
+				cmdMap[alias] = item
+			}
+		}
+	}
+	d.registerTemplateCheckTexts(tmpl)
+
+	if exists {
+		// 群组持有的是同一个扩展对象，原地替换指令即可生效
+		ext.CmdMap = cmdMap
+		ext.Version = tmpl.Version
+		ext.Brief = fmt.Sprintf("%s 规则模板提供的检定指令", tmpl.FullName)
+		d.applyTemplateCheckExtSettings()
+		return
+	}
+	if collide := d.ExtFind(extName, false); collide != nil {
+		d.Logger.Warnf("模板 %s 的检定扩展名 %s 与扩展<%s>冲突，检定指令未注册", tmpl.Name, extName, collide.Name)
+		return
+	}
+	ext = &ExtInfo{
+		Name:       extName,
+		Version:    tmpl.Version,
+		Brief:      fmt.Sprintf("%s 规则模板提供的检定指令", tmpl.FullName),
+		Author:     strings.Join(tmpl.Authors, ", "),
+		AutoActive: false,
+		CmdMap:     cmdMap,
+	}
+	d.RegisterExtension(ext)
+	d.templateCheckExts.Store(tmpl.Name, ext)
+	d.applyTemplateCheckExtSettings()
+}
+
+// applyTemplateCheckExtSettings 刷新扩展默认配置(禁用指令列表等)，启动阶段交给配置加载完成后统一处理
+func (d *Dice) applyTemplateCheckExtSettings() {
+	if d.IsAlreadyLoadConfig {
+		d.ApplyExtDefaultSettings()
+	}
+	d.ExtUpdateTime = time.Now().Unix()
+}
+
+// pruneTemplateCheckExts 清空已不存在的模板的检定指令
+func (d *Dice) pruneTemplateCheckExts() {
+	d.templateCheckExts.Range(func(name string, ext *ExtInfo) bool {
+		if _, ok := d.GameSystemMap.Load(name); !ok && len(ext.CmdMap) > 0 {
+			ext.CmdMap = CmdMapCls{}
+			d.ExtUpdateTime = time.Now().Unix()
+		}
+		return true
+	})
+}
+
+// registerTemplateCheckTexts 将检定文本注册为自定义文本，已存在的条目保留用户的修改
+func (d *Dice) registerTemplateCheckTexts(tmpl *GameSystemTemplate) {
+	if d.TextMapRaw == nil || d.TextMapHelpInfo == nil {
+		return
+	}
+	existing := d.TextMapRaw[tmpl.Name]
+	texts := TextTemplateWithWeight{}
+	subTypes := map[string]string{}
+	add := func(key, text, cmdName string) {
+		if key == "" || text == "" {
+			return
+		}
+		if _, ok := existing[key]; ok {
+			return
+		}
+		texts[key] = []TextTemplateItem{{text, 1}}
+		subTypes[key] = "." + cmdName
+	}
+	for _, check := range tmpl.Commands.Checks {
+		add(check.TextKey, check.Text, check.Name)
+		for _, outcome := range check.Outcomes {
+			add(outcome.TextKey, outcome.Text, check.Name)
+		}
+	}
+	if len(texts) == 0 {
+		return
+	}
+
+	SetupTextHelpInfo(d, d.TextMapHelpInfo, TextTemplateWithWeightDict{tmpl.Name: texts}, "game-templates/"+tmpl.Name)
+	for key, subType := range subTypes {
+		if item := d.TextMapHelpInfo[tmpl.Name][key]; item != nil {
+			item.SubType = subType
+		}
+	}
+	d.GenerateTextMap()
+}
+
+// templateCheckFormat 优先使用自定义文本，其次为模板中的文本
+func templateCheckFormat(ctx *MsgContext, category, key, text string) string {
+	if key != "" {
+		if _, ok := ctx.Dice.TextMap[category+":"+key]; ok {
+			return DiceFormatTmpl(ctx, category+":"+key)
+		}
+	}
+	ret, _ := DiceFormatV2(ctx, text)
+	return ret
+}
+
+func (d *Dice) newTemplateCheckCmd(tmplName string, check *CheckCommandConfig) *CmdItemInfo {
+	help := check.Help
+	if help == "" {
+		help = fmt.Sprintf(".%s <属性>[调整值] [难度] [原因] // 按 %s 规则进行检定", check.Name, tmplName)
+	}
+	return &CmdItemInfo{
+		Name:          check.Name,
+		ShortHelp:     help,
+		Help:          fmt.Sprintf("%s 检定:\n%s", tmplName, help),
+		AllowDelegate: true,
+		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
+			if cmdArgs.IsArgEqual(1, "help") {
+				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+			}
+			tmpl, ok := ctx.Dice.GameSystemMap.Load(tmplName)
+			if !ok {
+				ReplyToSender(ctx, msg, fmt.Sprintf("规则模板 %s 已卸载，无法检定", tmplName))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+
+			mctx := GetCtxProxyFirst(ctx, cmdArgs)
+			mctx.DelegateText = ctx.DelegateText
+			mctx.CreateVmIfNotExists()
+			tmpl.runInitScript(mctx)
+
+			text, err := templateCheckRun(mctx, tmpl, check, cmdArgs.CleanArgs)
+			if err != nil {
+				ReplyToSender(ctx, msg, err.Error())
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+			ReplyToSender(mctx, msg, text)
+			return CmdExecuteResult{Matched: true, Solved: true}
+		},
+	}
+}
+
+// templateCheckRun 执行一次检定并返回输出文本
+func templateCheckRun(mctx *MsgContext, tmpl *GameSystemTemplate, check *CheckCommandConfig, argText string) (string, error) {
+	eval := func(stage, expr string) (*VMResultV2, error) {
+		r := mctx.Eval(expr, nil)
+		if r.vm.Error != nil {
+			return nil, fmt.Errorf("%s检定的%s执行出错: %s", check.Name, stage, r.vm.Error.Error())
+		}
+		return r, nil
+	}
+
+	// 参数: <属性>[调整值] [难度|原因]
+	exprText, restText, _ := strings.Cut(strings.TrimSpace(argText), " ")
+	var attrName string
+	var attrValue ds.IntType
+	if exprText != "" {
+		r := mctx.Eval(exprText, nil)
+		if r.vm.Error != nil {
+			return "", fmt.Errorf("无法解析表达式: %s", exprText)
+		}
+		v, ok := r.ReadInt()
+		if !ok {
+			return "", fmt.Errorf("属性非数字类型，无法用于检定: %s", exprText)
+		}
+		attrName, attrValue = strings.TrimSpace(r.vm.Matched), v
+		restText = strings.TrimSpace(r.vm.RestInput + " " + restText)
+	}
+	var difficulty int64
+	if first, rest, _ := strings.Cut(restText, " "); first != "" {
+		if n, err := strconv.ParseInt(first, 10, 64); err == nil {
+			difficulty = n
+			restText = strings.TrimSpace(rest)
+		}
+	}
+	reason := ""
+	if restText != "" {
+		reason = "由于" + LimitCommandReasonText(restText) + "，"
+	}
+	VarSetValueStr(mctx, "$t属性", attrName)
+	VarSetValueInt64(mctx, "$t属性值", int64(attrValue))
+	VarSetValueInt64(mctx, "$t难度", difficulty)
+	VarSetValueStr(mctx, "$t原因", reason)
+
+	if check.Before != "" {
+		if _, err := eval("before", check.Before); err != nil {
+			return "", err
+		}
+	}
+
+	rollExpr := check.Roll
+	if rollExpr == "" {
+		rollExpr = templateCheckDefaultRoll
+	}
+	r, err := eval("roll", rollExpr)
+	if err != nil {
+		return "", err
+	}
+	detail := r.vm.GetDetailText()
+	if detail == "" {
+		detail = r.ToString()
+	}
+	VarSetValue(mctx, "$t出目", &r.VMValue)
+	VarSetValueStr(mctx, "$t检定过程", detail)
+
+	if check.Target != "" {
+		r, err = eval("target", check.Target)
+		if err != nil {
+			return "", err
+		}
+		VarSetValue(mctx, "$t目标值", &r.VMValue)
+	}
+
+	VarSetValueStr(mctx, "$t判定结果", "")
+	VarSetValueStr(mctx, "$t结果文本", "")
+	for _, outcome := range check.Outcomes {
+		if outcome.When != "" {
+			r, err = eval("outcomes", outcome.When)
+			if err != nil {
+				return "", err
+			}
+			if !r.AsBool() {
+				continue
+			}
+		}
+		VarSetValueStr(mctx, "$t判定结果", outcome.Name)
+		VarSetValueStr(mctx, "$t结果文本", templateCheckFormat(mctx, tmpl.Name, outcome.TextKey, outcome.Text))
+		break
+	}
+
+	if check.After != "" {
+		if _, err = eval("after", check.After); err != nil {
+			return "", err
+		}
+	}
+
+	text := check.Text
+	if text == "" {
+		text = templateCheckDefaultText
+	}
+	return templateCheckFormat(mctx, tmpl.Name, check.TextKey, text), nil
+}
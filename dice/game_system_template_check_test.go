@@ -0,0 +1,85 @@
+//nolint:testpackage
+package dice
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sealdice-core/model"
+)
+
+const testPbtATemplate = `name: pbta
+fullName: 测试PbtA
+version: 1.0.0
+commands:
+  set:
+    diceSides: 6
+    keys: [pbta]
+  checks:
+    - name: move
+      aliases: [mv]
+      roll: 2d6 + $t属性值 - $t难度
+      outcomes:
+        - name: 完全成功
+          when: $t出目 >= 10
+          textKey: 行动_完全成功
+          text: 完全成功
+        - name: 部分成功
+          when: $t出目 >= 7
+          text: 部分成功
+        - name: 失败
+          text: 失败
+      text: "{$t原因}{$t属性}={$t出目} {$t判定结果}/{$t结果文本}"
+`
+
+func TestTemplateCheckCommands(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	migrateTestTables(t, d, &model.AttributesItemModel{})
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pbta.yaml"), []byte(testPbtATemplate), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := d.GameSystemTemplateReload(dir); err != nil {
+		t.Fatalf("GameSystemTemplateReload() error = %v", err)
+	}
+	ext := d.ExtFind(templateCheckExtName("pbta"), false)
+	if ext == nil || ext.CmdMap["move"] == nil || ext.CmdMap["mv"] == nil {
+		t.Fatalf("检定扩展未注册: %+v", ext)
+	}
+
+	const groupID, userID = "QQ-Group:222", "QQ:888"
+	send := func(text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg(groupID, userID, text))
+	}
+
+	send(".set pbta")
+	send(".st 酷10")
+	if got := send(".move 酷"); !strings.HasPrefix(got, "酷=") || !strings.HasSuffix(got, "完全成功/完全成功") {
+		t.Fatalf(".move 酷 = %q", got)
+	}
+	if got := send(".mv 酷 30 下雨"); !strings.HasPrefix(got, "由于下雨，酷=") || !strings.HasSuffix(got, "失败/失败") {
+		t.Fatalf(".mv 酷 30 下雨 = %q", got)
+	}
+
+	// 重载后保留自定义文本，指令原地更新
+	d.TextMapRaw["pbta"]["行动_完全成功"] = []TextTemplateItem{{"大获全胜", 1}}
+	d.GenerateTextMap()
+	if err := d.GameSystemTemplateReload(dir); err != nil {
+		t.Fatalf("GameSystemTemplateReload() error = %v", err)
+	}
+	if got := send(".move 酷"); !strings.HasSuffix(got, "完全成功/大获全胜") {
+		t.Fatalf("自定义文本后 .move 酷 = %q", got)
+	}
+
+	if err := d.GameSystemTemplateReload(t.TempDir()); err != nil {
+		t.Fatalf("GameSystemTemplateReload() error = %v", err)
+	}
+	if len(ext.CmdMap) != 0 {
+		t.Fatalf("模板移除后检定指令未清空: %v", ext.CmdMap)
+	}
+}
@@ -96,6 +96,9 @@ type GroupInfo struct {
 	LogCurName   string        `jsbind:"logCurName"   json:"logCurName"   yaml:"logCurFile"`
 	LogOn        bool          `jsbind:"logOn"        json:"logOn"        yaml:"logOn"`
 
+	FairDiceOn bool             `jsbind:"fairDiceOn" json:"fairDiceOn"         yaml:"fairDiceOn"` // 是否在新建日志时启用公平骰
+	FairDice   *FairDiceSession `json:"fairDice,omitempty" yaml:"-"`                              // 进行中的公平骰会话
+
 	QuitMarkAutoClean   bool   `json:"-"                     yaml:"-"` // 自动清群 - 播报，即将自动退出群组
 	QuitMarkMaster      bool   `json:"-"                     yaml:"-"` // 骰主命令退群 - 播报，即将自动退出群组
 	RecentDiceSendTime  int64  `jsbind:"recentDiceSendTime"  json:"recentDiceSendTime"`
@@ -247,6 +250,10 @@ type groupInfoJSON struct {
 // MarshalJSON 自定义序列化，处理私有字段 activatedExtList
 // 同时过滤掉已删除的 wrapper（IsDeleted=true）
 func (g *GroupInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.toJSON())
+}
+
+func (g *GroupInfo) toJSON() *groupInfoJSON {
 	g.extInitMu.Lock()
 	// 过滤掉已删除的 wrapper
 	var filteredList []*ExtInfo
@@ -257,10 +264,10 @@ func (g *GroupInfo) MarshalJSON() ([]byte, error) {
 	}
 	g.extInitMu.Unlock()
 
-	return json.Marshal(&groupInfoJSON{
+	return &groupInfoJSON{
 		groupInfoAlias:   (*groupInfoAlias)(g),
 		ActivatedExtList: filteredList,
-	})
+	}
 }
 
 // UnmarshalJSON 自定义反序列化，处理私有字段 activatedExtList
@@ -786,6 +793,10 @@ type MsgContext struct {
 	splitKey   string
 	vm         *ds.Context
 	_v1Rand    *rand2.PCGSource
+	// 公平骰模式下当前指令的序号，0为未启用
+	fairDiceCounter uint64
+	// 本条指令读写过的角色卡，指令结束后只为这些卡生成修改记录
+	attrsTouched *attrsRevisionTouched
 }
 
 // fillPrivilege 填写MsgContext中的权限字段, 并返回填写的权限等级
@@ -1175,7 +1186,12 @@ func (s *IMSession) Execute(ep *EndPointInfo, msg *Message, runInSync bool) {
 											waitRun <- 1
 										}()
 
-										i.OnNotCommandReceived(mctx, msg)
+										err := d.JsRunGuarded(runtime, i, func() {
+											i.OnNotCommandReceived(mctx, msg)
+										})
+										if text := jsTimeoutReplyText(err); text != "" {
+											ReplyToSender(mctx, msg, text)
+										}
 									})
 									<-waitRun
 								} else {
@@ -1317,9 +1333,12 @@ func (s *IMSession) ExecuteNew(ep *EndPointInfo, msg *Message) {
 					continue
 				}
 				if ext.OnMessageReceived != nil {
-					ext.callWithJsCheck(mctx.Dice, func() {
+					err := ext.callWithJsCheck(mctx.Dice, func() {
 						ext.OnMessageReceived(mctx, msg)
 					})
+					if text := jsTimeoutReplyText(err); text != "" {
+						ReplyToSender(mctx, msg, text)
+					}
 				}
 			}
 		}
@@ -1464,7 +1483,12 @@ func (s *IMSession) ExecuteNew(ep *EndPointInfo, msg *Message) {
 										}
 										waitRun <- 1
 									}()
-									i.OnNotCommandReceived(mctx, msg)
+									err := d.JsRunGuarded(runtime, i, func() {
+										i.OnNotCommandReceived(mctx, msg)
+									})
+									if text := jsTimeoutReplyText(err); text != "" {
+										ReplyToSender(mctx, msg, text)
+									}
 								})
 								<-waitRun
 							} else {
@@ -2122,12 +2146,21 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 			}
 
 			if item.AllowDelegate {
+				// @npc:<名字> 视为对NPC的@
+				if !npcApplyMentions(ctx, msg, cmdArgs) {
+					return true
+				}
+
 				// 允许代骰时，发一句话
 				cur := -1
 				for index, i := range cmdArgs.At {
 					if i.UserID == ctx.EndPoint.UserID || (cmdArgs.uidForAtInfo != "" && i.UserID == cmdArgs.uidForAtInfo) {
 						continue
 					}
+					if _, isNpc := npcNameFromUserID(i.UserID); isNpc {
+						// 以NPC身份掷骰不算代骰
+						continue
+					}
 					cur = index
 				}
 
@@ -2145,6 +2178,12 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 			}
 		}
 
+		// 代骰等派生的 ctx 共用同一份记录
+		ctx.attrsTouched = &attrsRevisionTouched{}
+
+		// 公平骰: 本条指令的骰点由会话种子推算
+		fairDiceAttach(ctx)
+
 		// 加载规则模板
 		// TODO: 注意一下这里使用群模板还是个人卡模板，目前群模板，可有情况特殊？
 		tmpl := ctx.SystemTemplate
@@ -2169,18 +2208,30 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 			loop.RunOnLoop(func(vm *goja.Runtime) {
 				defer func() {
 					if r := recover(); r != nil {
-						// log.Errorf("异常: %v 堆栈: %v", r, string(debug.Stack()))
+						s.Parent.jsReportError(fmt.Sprintf("扩展指令<%s>执行异常: %s", item.Name, jsErrorText(r)))
 						ReplyToSender(ctx, msg, fmt.Sprintf("JS执行异常，请反馈给该扩展的作者：\n%v", r))
 					}
 					waitRun <- 1
 				}()
 
-				ret = item.Solve(ctx, msg, cmdArgs)
+				err := s.Parent.JsRunGuarded(vm, ext, func() {
+					ret = item.Solve(ctx, msg, cmdArgs)
+				})
+				if errors.Is(err, ErrJsScriptAutoDisabled) {
+					ReplyToSender(ctx, msg, "该指令所属的扩展脚本已因多次执行超时被自动禁用，请联系骰主处理")
+					ret = CmdExecuteResult{Matched: true, Solved: true}
+				} else if text := jsTimeoutReplyText(err); text != "" {
+					ReplyToSender(ctx, msg, text)
+					ret = CmdExecuteResult{Matched: true, Solved: true}
+				}
 			})
 			<-waitRun
 		} else {
 			ret = item.Solve(ctx, msg, cmdArgs)
 		}
+		if am := s.Parent.AttrsManager; am != nil {
+			am.CommitRevisionsByCtx(ctx, attrsRevisionEditorByCtx(ctx, msg))
+		}
 
 		if ret.Solved {
 			if ret.ShowHelp {
@@ -2233,9 +2284,12 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 				continue
 			}
 			if ext.OnCommandReceived != nil {
-				ext.callWithJsCheck(ctx.Dice, func() {
+				err := ext.callWithJsCheck(ctx.Dice, func() {
 					ext.OnCommandReceived(ctx, msg, cmdArgs)
 				})
+				if text := jsTimeoutReplyText(err); text != "" {
+					ReplyToSender(ctx, msg, text)
+				}
 			}
 		}
 	}
@@ -2683,6 +2737,8 @@ func (ctx *MsgContext) ShallowCopy() *MsgContext {
 		UITestReplySplitLen: ctx.UITestReplySplitLen,
 		vm:                  ctx.vm,
 		_v1Rand:             ctx._v1Rand,
+		fairDiceCounter:     ctx.fairDiceCounter,
+		attrsTouched:        ctx.attrsTouched,
 	}
 	copyCtx.SetSplitKey(ctx.getSplitKey())
 	return copyCtx
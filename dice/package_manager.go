@@ -72,6 +72,8 @@ type PackageUploadPreview struct {
 	ContentCounts   map[string]int     `json:"contentCounts"`
 	ExistingVersion string             `json:"existingVersion,omitempty"`
 	InstallAction   string             `json:"installAction"`
+	Signature       *PackageSignStatus `json:"signature"`
+	SignPolicy      string             `json:"signPolicy"`
 }
 
 // NewPackageManager 创建包管理器
@@ -751,6 +753,9 @@ func (pm *PackageManager) installFromSource(pkgPath string) error {
 	if checkErr := sealpack.CheckSealVersion(manifest, VERSION.String()); checkErr != nil {
 		return checkErr
 	}
+	if signErr := pm.enforceSignPolicyLocked(pkgPath, manifest); signErr != nil {
+		return signErr
+	}
 	if satisfied, missing := pm.CheckDependencies(manifest); !satisfied {
 		return &DependencyError{
 			PackageID:   pkgID,
@@ -985,6 +990,8 @@ func (pm *PackageManager) previewFromSource(pkgPath string) (*PackageUploadPrevi
 
 	pm.lock.RLock()
 	defer pm.lock.RUnlock()
+	preview.Signature = pm.checkSignatureLocked(pkgPath)
+	preview.SignPolicy, _ = normalizePackageSignPolicy(pm.parent.Config.PackageSignPolicy)
 	if existing, ok := pm.packages[manifest.Package.ID]; ok && existing != nil && existing.Manifest != nil {
 		preview.ExistingVersion = existing.Manifest.Package.Version
 		preview.InstallAction = "upgrade"
@@ -1000,6 +1007,7 @@ func packageUploadContentCounts(files []string) map[string]int {
 		"reply":     0,
 		"helpdoc":   0,
 		"templates": 0,
+		"tables":    0,
 		"assets":    0,
 	}
 	for _, file := range files {
@@ -1589,6 +1597,8 @@ func packageContentPatterns(manifest *sealpack.Manifest, contentType string) []s
 		return manifest.Contents.Helpdoc
 	case "templates":
 		return manifest.Contents.Templates
+	case "tables":
+		return manifest.Contents.Tables
 	default:
 		return nil
 	}
@@ -1709,6 +1719,10 @@ func (pm *PackageManager) generateReloadHints(manifest *sealpack.Manifest) *seal
 		hints = append(hints, "游戏系统模板 - 可通过重载接口生效")
 	}
 
+	if len(manifest.Contents.Tables) > 0 {
+		hints = append(hints, "随机表 - 可通过重载接口生效")
+	}
+
 	return &sealpack.OperationResult{
 		ReloadNeeded: len(hints) > 0,
 		ReloadHints:  hints,
@@ -1809,6 +1823,7 @@ type packageReloadContentFlags struct {
 	reply     bool
 	helpdoc   bool
 	templates bool
+	tables    bool
 }
 
 type packageReloadExecution struct {
@@ -1827,6 +1842,7 @@ func packageReloadContentFlagsFromManifest(manifest *sealpack.Manifest) packageR
 		reply:     len(manifest.Contents.Reply) > 0,
 		helpdoc:   len(manifest.Contents.Helpdoc) > 0,
 		templates: len(manifest.Contents.Templates) > 0,
+		tables:    len(manifest.Contents.Tables) > 0,
 	}
 }
 
@@ -1842,6 +1858,8 @@ func packageReloadContentFlagsFromContentType(contentType string) (packageReload
 		return packageReloadContentFlags{helpdoc: true}, nil
 	case "templates":
 		return packageReloadContentFlags{templates: true}, nil
+	case "tables":
+		return packageReloadContentFlags{tables: true}, nil
 	default:
 		return packageReloadContentFlags{}, errors.New("unsupported reload content type: " + contentType)
 	}
@@ -1853,6 +1871,7 @@ func (flags packageReloadContentFlags) merge(other packageReloadContentFlags) pa
 	flags.reply = flags.reply || other.reply
 	flags.helpdoc = flags.helpdoc || other.helpdoc
 	flags.templates = flags.templates || other.templates
+	flags.tables = flags.tables || other.tables
 	return flags
 }
 
@@ -1868,6 +1887,8 @@ func (flags packageReloadContentFlags) contains(kind string) bool {
 		return flags.helpdoc
 	case "templates":
 		return flags.templates
+	case "tables":
+		return flags.tables
 	default:
 		return false
 	}
@@ -1890,6 +1911,9 @@ func (flags packageReloadContentFlags) count() int {
 	if flags.templates {
 		count++
 	}
+	if flags.tables {
+		count++
+	}
 	return count
 }
 
@@ -1927,6 +1951,8 @@ func reloadHintMatchesContentType(hint, kind string) bool {
 		return hint == "helpdoc" || strings.HasPrefix(hint, "帮助文档")
 	case "templates":
 		return hint == "templates" || strings.HasPrefix(hint, "游戏系统模板")
+	case "tables":
+		return hint == "tables" || strings.HasPrefix(hint, "随机表")
 	default:
 		return false
 	}
@@ -1941,7 +1967,7 @@ func (pm *PackageManager) clearPendingReloadLocked(pkg *sealpack.Instance, succe
 	changed := false
 	for _, hint := range pkg.PendingReload {
 		shouldClear := false
-		for _, kind := range []string{"scripts", "decks", "reply", "helpdoc", "templates"} {
+		for _, kind := range []string{"scripts", "decks", "reply", "helpdoc", "templates", "tables"} {
 			if succeeded.contains(kind) && reloadHintMatchesContentType(hint, kind) {
 				shouldClear = true
 				break
@@ -2017,6 +2043,11 @@ func (pm *PackageManager) reloadPackageContent(flags packageReloadContentFlags)
 			exec.succeeded.templates = true
 		}
 	}
+	if flags.tables {
+		RollTableReload(pm.parent)
+		result.ReloadedItems["tables"] = "随机表已重载"
+		exec.succeeded.tables = true
+	}
 
 	result.Message = buildReloadResultMessage(exec.succeeded.count(), exec.failed.count(), result.NeedRestart)
 	return exec
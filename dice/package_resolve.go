@@ -0,0 +1,542 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"sealdice-core/dice/sealpack"
+)
+
+// packageResolveMaxSteps 依赖求解的回溯步数上限，防止病态的约束组合耗尽时间
+const packageResolveMaxSteps = 10000
+
+// 安装计划中每一步的操作
+const (
+	PackagePlanInstall = "install"
+	PackagePlanUpgrade = "upgrade"
+)
+
+// PackageVersionSource 提供包的全部可安装版本，通常为当前商店后端
+type PackageVersionSource interface {
+	StoreQueryPackageVersions(id string) ([]*StorePackage, error)
+}
+
+// PackagePlanItem 安装计划中的一步，计划按依赖的拓扑顺序排列
+type PackagePlanItem struct {
+	ID          string `json:"id"`
+	Version     string `json:"version"`
+	FromVersion string `json:"fromVersion,omitempty"` // 升级前的版本
+	Action      string `json:"action"`
+	Requested   bool   `json:"requested"` // 为 false 表示作为依赖被带入
+
+	pkg *StorePackage
+}
+
+// PackageUpdateInfo 一个已安装包的更新情况
+type PackageUpdateInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`            // 商店中的最新版本
+	Target  string `json:"target,omitempty"`  // 一并更新时会升级到的版本
+	Blocked string `json:"blocked,omitempty"` // 无法升级到最新版本的原因
+}
+
+type packageResolveCandidate struct {
+	id      string
+	version *semver.Version
+	deps    map[string]string
+	pkg     *StorePackage // 为 nil 表示保留已安装的版本
+}
+
+type packageConstraint struct {
+	from       string
+	constraint string
+}
+
+type packageResolver struct {
+	source      PackageVersionSource
+	sealVersion string
+	installed   map[string]*packageResolveCandidate
+	prefer      map[string]bool // 优先选择最新版本的包，其余包尽量保持不动
+	requested   map[string]bool
+
+	queried     map[string][]*StorePackage
+	queryErrs   map[string]error
+	versions    map[string][]*packageResolveCandidate
+	selected    map[string]*packageResolveCandidate
+	constraints map[string][]packageConstraint
+	steps       int
+	failure     error
+}
+
+func (pm *PackageManager) newPackageResolver(source PackageVersionSource) *packageResolver {
+	r := &packageResolver{
+		source:      source,
+		sealVersion: VERSION.String(),
+		installed:   map[string]*packageResolveCandidate{},
+		prefer:      map[string]bool{},
+		requested:   map[string]bool{},
+		queried:     map[string][]*StorePackage{},
+		queryErrs:   map[string]error{},
+		versions:    map[string][]*packageResolveCandidate{},
+		selected:    map[string]*packageResolveCandidate{},
+		constraints: map[string][]packageConstraint{},
+	}
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	for id, pkg := range pm.packages {
+		if pkg == nil || pkg.Manifest == nil {
+			continue
+		}
+		version, err := semver.NewVersion(pkg.Manifest.Package.Version)
+		if err != nil {
+			continue
+		}
+		r.installed[id] = &packageResolveCandidate{id: id, version: version, deps: pkg.Manifest.Dependencies}
+	}
+	return r
+}
+
+// query 查询商店中包的全部版本，结果在一次求解中复用
+func (r *packageResolver) query(id string) ([]*StorePackage, error) {
+	if packages, ok := r.queried[id]; ok {
+		return packages, r.queryErrs[id]
+	}
+	packages, err := r.source.StoreQueryPackageVersions(id)
+	r.queried[id], r.queryErrs[id] = packages, err
+	return packages, err
+}
+
+// candidates 列出包的可选版本：不低于已安装版本，且满足海豹版本要求
+func (r *packageResolver) candidates(id string) []*packageResolveCandidate {
+	if list, ok := r.versions[id]; ok {
+		return list
+	}
+	installed := r.installed[id]
+	var list []*packageResolveCandidate
+	packages, err := r.query(id)
+	if err != nil && installed == nil {
+		r.fail(fmt.Errorf("查询 %s 的版本失败: %w", id, err))
+	}
+	for _, pkg := range packages {
+		version, parseErr := semver.NewVersion(pkg.Version)
+		if parseErr != nil || (installed != nil && !version.GreaterThan(installed.version)) {
+			continue
+		}
+		if sealErr := sealpack.CheckSealVersion(&sealpack.Manifest{Package: sealpack.PackageInfo{Seal: pkg.Seal}}, r.sealVersion); sealErr != nil {
+			continue
+		}
+		list = append(list, &packageResolveCandidate{id: id, version: version, deps: pkg.Dependencies, pkg: pkg})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].version.GreaterThan(list[j].version)
+	})
+	if installed != nil {
+		if r.prefer[id] {
+			list = append(list, installed)
+		} else {
+			list = append([]*packageResolveCandidate{installed}, list...)
+		}
+	}
+	r.versions[id] = list
+	return list
+}
+
+func (r *packageResolver) fail(err error) {
+	if r.failure == nil {
+		r.failure = err
+	}
+}
+
+func (r *packageResolver) satisfies(id string, version *semver.Version) bool {
+	for _, c := range r.constraints[id] {
+		if ok, _ := sealpack.CheckDependencyConstraint(c.constraint, version.String()); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *packageResolver) describeConstraints(id string) string {
+	parts := make([]string, 0, len(r.constraints[id]))
+	for _, c := range r.constraints[id] {
+		parts = append(parts, c.constraint+" (来自 "+c.from+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// solve 依次为队列中的包选择版本，冲突时回溯
+func (r *packageResolver) solve(queue []string) bool {
+	for len(queue) > 0 && r.selected[queue[0]] != nil {
+		queue = queue[1:]
+	}
+	if len(queue) == 0 {
+		return true
+	}
+	r.steps++
+	if r.steps > packageResolveMaxSteps {
+		r.fail(errors.New("依赖关系过于复杂，无法求解"))
+		return false
+	}
+
+	id := queue[0]
+	tried := false
+	for _, cand := range r.candidates(id) {
+		if !r.satisfies(id, cand.version) {
+			continue
+		}
+		tried = true
+		if !r.compatible(cand) {
+			continue
+		}
+		r.selected[id] = cand
+		depIDs := make([]string, 0, len(cand.deps))
+		for depID, constraint := range cand.deps {
+			r.constraints[depID] = append(r.constraints[depID], packageConstraint{from: id + "@" + cand.version.String(), constraint: constraint})
+			depIDs = append(depIDs, depID)
+		}
+		sort.Strings(depIDs)
+		next := make([]string, 0, len(queue)-1+len(depIDs))
+		next = append(append(next, depIDs...), queue[1:]...)
+		if r.solve(next) {
+			return true
+		}
+		for depID := range cand.deps {
+			list := r.constraints[depID]
+			r.constraints[depID] = list[:len(list)-1]
+		}
+		delete(r.selected, id)
+		if r.failure != nil && r.steps > packageResolveMaxSteps {
+			return false
+		}
+	}
+	if !tried {
+		r.fail(fmt.Errorf("找不到满足约束的 %s 版本: %s", id, r.describeConstraints(id)))
+	}
+	return false
+}
+
+// compatible 检查候选版本的依赖与已选定的版本是否冲突
+func (r *packageResolver) compatible(cand *packageResolveCandidate) bool {
+	for depID, constraint := range cand.deps {
+		if selected := r.selected[depID]; selected != nil {
+			if ok, _ := sealpack.CheckDependencyConstraint(constraint, selected.version.String()); !ok {
+				r.fail(fmt.Errorf("%s@%s 需要 %s %s，但已选定 %s", cand.id, cand.version, depID, constraint, selected.version))
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resolve 求解后按拓扑顺序返回需要安装或升级的包
+func (r *packageResolver) resolve(requests map[string]string) ([]*PackagePlanItem, error) {
+	queue := make([]string, 0, len(requests)+len(r.installed))
+	for id, constraint := range requests {
+		r.requested[id] = true
+		if constraint != "" {
+			r.constraints[id] = append(r.constraints[id], packageConstraint{from: "请求", constraint: constraint})
+		}
+		queue = append(queue, id)
+	}
+	sort.Strings(queue)
+	// 已安装的包也参与求解，其依赖约束同样要满足
+	installedIDs := make([]string, 0, len(r.installed))
+	for id := range r.installed {
+		installedIDs = append(installedIDs, id)
+	}
+	sort.Strings(installedIDs)
+	queue = append(queue, installedIDs...)
+
+	if !r.solve(queue) {
+		if r.failure == nil {
+			r.failure = errors.New("无法找到满足全部依赖约束的版本组合")
+		}
+		return nil, r.failure
+	}
+
+	pending := map[string]*packageResolveCandidate{}
+	for id, cand := range r.selected {
+		if cand.pkg != nil {
+			pending[id] = cand
+		}
+	}
+	plan := make([]*PackagePlanItem, 0, len(pending))
+	for len(pending) > 0 {
+		ready := make([]string, 0, len(pending))
+		for id, cand := range pending {
+			blocked := false
+			for depID := range cand.deps {
+				if pending[depID] != nil {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ready = append(ready, id)
+			}
+		}
+		if len(ready) == 0 {
+			ids := make([]string, 0, len(pending))
+			for id := range pending {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			return nil, errors.New("存在循环依赖: " + strings.Join(ids, ", "))
+		}
+		sort.Strings(ready)
+		for _, id := range ready {
+			cand := pending[id]
+			item := &PackagePlanItem{ID: id, Version: cand.pkg.Version, Action: PackagePlanInstall, Requested: r.requested[id], pkg: cand.pkg}
+			if installed := r.installed[id]; installed != nil {
+				item.Action = PackagePlanUpgrade
+				item.FromVersion = installed.version.String()
+			}
+			plan = append(plan, item)
+			delete(pending, id)
+		}
+	}
+	return plan, nil
+}
+
+// ResolveInstall 为安装指定版本的包求解完整的依赖关系，version 为空时选择最新的可用版本。
+// 依赖会尽量沿用已安装的版本，必要时升级，已安装的其他包的依赖约束同样会被满足。
+func (pm *PackageManager) ResolveInstall(source PackageVersionSource, id, version string) ([]*PackagePlanItem, error) {
+	constraint := ""
+	if version = strings.TrimSpace(version); version != "" {
+		if _, err := semver.NewVersion(version); err != nil {
+			return nil, fmt.Errorf("无效的版本号: %w", err)
+		}
+		constraint = "=" + version
+	}
+	r := pm.newPackageResolver(source)
+	r.prefer[id] = true
+	return r.resolve(map[string]string{id: constraint})
+}
+
+// CheckUpdates 检查已安装包的更新，同时给出一并更新全部包的安装计划
+func (pm *PackageManager) CheckUpdates(source PackageVersionSource) ([]*PackageUpdateInfo, []*PackagePlanItem, error) {
+	r := pm.newPackageResolver(source)
+	names := map[string]string{}
+	pm.lock.RLock()
+	for id, pkg := range pm.packages {
+		if pkg != nil && pkg.Manifest != nil {
+			names[id] = pkg.Manifest.Package.Name
+		}
+	}
+	pm.lock.RUnlock()
+
+	ids := make([]string, 0, len(r.installed))
+	for id := range r.installed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	updates := []*PackageUpdateInfo{}
+	requests := map[string]string{}
+	latestPkgs := map[string]*StorePackage{}
+	for _, id := range ids {
+		packages, err := r.query(id)
+		if err != nil {
+			// 商店中没有的包(例如手动安装的)不参与更新
+			continue
+		}
+		var latest *StorePackage
+		var latestVersion *semver.Version
+		for _, pkg := range packages {
+			if v, parseErr := semver.NewVersion(pkg.Version); parseErr == nil && (latestVersion == nil || v.GreaterThan(latestVersion)) {
+				latest, latestVersion = pkg, v
+			}
+		}
+		if latest == nil || !latestVersion.GreaterThan(r.installed[id].version) {
+			continue
+		}
+		latestPkgs[id] = latest
+		updates = append(updates, &PackageUpdateInfo{ID: id, Name: names[id], Current: r.installed[id].version.String(), Latest: latest.Version})
+		requests[id] = ""
+		r.prefer[id] = true
+	}
+	if len(updates) == 0 {
+		return updates, []*PackagePlanItem{}, nil
+	}
+
+	plan, err := r.resolve(requests)
+	if err != nil {
+		return updates, nil, err
+	}
+	targets := map[string]string{}
+	for _, item := range plan {
+		targets[item.ID] = item.Version
+	}
+	for _, info := range updates {
+		info.Target = targets[info.ID]
+		if info.Target == info.Latest {
+			continue
+		}
+		latest := latestPkgs[info.ID]
+		if sealErr := sealpack.CheckSealVersion(&sealpack.Manifest{Package: sealpack.PackageInfo{Seal: latest.Seal}}, VERSION.String()); sealErr != nil {
+			info.Blocked = sealErr.Error()
+		} else {
+			info.Blocked = "受其他扩展包的依赖约束限制"
+		}
+	}
+	return updates, plan, nil
+}
+
+// ResolveUpdates 求解把指定包升级到可用最新版本的安装计划，ids 为空时更新全部
+func (pm *PackageManager) ResolveUpdates(source PackageVersionSource, ids []string) ([]*PackagePlanItem, error) {
+	if len(ids) == 0 {
+		_, plan, err := pm.CheckUpdates(source)
+		return plan, err
+	}
+	r := pm.newPackageResolver(source)
+	requests := map[string]string{}
+	for _, id := range ids {
+		if r.installed[id] == nil {
+			return nil, errors.New("扩展包不存在: " + id)
+		}
+		requests[id] = ""
+		r.prefer[id] = true
+	}
+	return r.resolve(requests)
+}
+
+type packageApplyStep struct {
+	item     *PackagePlanItem
+	previous *sealpack.Instance
+	backup   string
+}
+
+// ApplyPlan 按顺序执行安装计划，任何一步失败都会把已完成的步骤回滚
+func (pm *PackageManager) ApplyPlan(plan []*PackagePlanItem) error {
+	tmpDir := pm.getPackageTempDir()
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return err
+	}
+	done := make([]*packageApplyStep, 0, len(plan))
+	defer func() {
+		for _, step := range done {
+			if step.backup != "" {
+				_ = os.Remove(step.backup)
+			}
+		}
+	}()
+
+	for _, item := range plan {
+		if item.pkg == nil {
+			return fmt.Errorf("安装计划中的 %s 缺少下载信息", item.ID)
+		}
+		step := &packageApplyStep{item: item}
+		if item.Action == PackagePlanUpgrade {
+			previous, backup, err := pm.backupInstalled(item.ID, tmpDir)
+			if err != nil {
+				pm.rollbackPlan(done)
+				return fmt.Errorf("备份 %s 失败: %w", item.ID, err)
+			}
+			step.previous, step.backup = previous, backup
+		}
+		if err := pm.InstallFromURL(item.pkg.Download.URL, item.pkg.Download.Hash); err != nil {
+			if step.backup != "" {
+				_ = os.Remove(step.backup)
+			}
+			pm.rollbackPlan(done)
+			return fmt.Errorf("安装 %s@%s 失败，已回滚之前的步骤: %w", item.ID, item.Version, err)
+		}
+		done = append(done, step)
+	}
+	return nil
+}
+
+// backupInstalled 复制已安装包的源文件，升级失败时据此恢复
+func (pm *PackageManager) backupInstalled(pkgID, tmpDir string) (*sealpack.Instance, string, error) {
+	pm.lock.RLock()
+	current, ok := pm.packages[pkgID]
+	var previous sealpack.Instance
+	if ok && current != nil {
+		previous = *current
+		previous.Config = sealpack.MergeConfig(map[string]interface{}{}, current.Config)
+	}
+	pm.lock.RUnlock()
+	if !ok || current == nil || previous.SourcePath == "" {
+		return nil, "", errors.New("找不到已安装的源文件")
+	}
+	tmpFile, err := os.CreateTemp(tmpDir, "package_backup_*"+sealpack.Extension)
+	if err != nil {
+		return nil, "", err
+	}
+	backup := tmpFile.Name()
+	_ = tmpFile.Close()
+	if err = pm.copyFile(previous.SourcePath, backup); err != nil {
+		_ = os.Remove(backup)
+		return nil, "", err
+	}
+	return &previous, backup, nil
+}
+
+// rollbackPlan 倒序撤销已完成的步骤：新装的包卸载(保留用户数据)，升级的包恢复原版本
+func (pm *PackageManager) rollbackPlan(done []*packageApplyStep) {
+	for i := len(done) - 1; i >= 0; i-- {
+		step := done[i]
+		var err error
+		if step.previous == nil {
+			err = pm.Uninstall(step.item.ID, sealpack.UninstallModeKeepData)
+		} else {
+			err = pm.restorePackage(step.previous, step.backup)
+		}
+		if err != nil {
+			pm.parent.Logger.Errorf("回滚扩展包 %s 失败: %v", step.item.ID, err)
+		}
+	}
+}
+
+// restorePackage 用备份的源文件恢复升级前的版本，状态与配置一并恢复
+func (pm *PackageManager) restorePackage(previous *sealpack.Instance, backup string) error {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	pkgID := previous.Manifest.Package.ID
+	destDir := filepath.Dir(previous.SourcePath)
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return err
+	}
+	stagedSourcePath, err := pm.stageSourceArtifact(backup, destDir)
+	if err != nil {
+		return err
+	}
+	stagedCachePath, err := pm.stageExtractPackage(backup, pkgID)
+	if err != nil {
+		_ = os.Remove(stagedSourcePath)
+		return err
+	}
+	if err = os.Rename(stagedSourcePath, previous.SourcePath); err != nil {
+		_ = os.Remove(stagedSourcePath)
+		_ = os.RemoveAll(stagedCachePath)
+		return err
+	}
+	if err = pm.swapInstallDir(stagedCachePath, previous.InstallPath); err != nil {
+		return err
+	}
+	if current := pm.packages[pkgID]; current != nil && current.SourcePath != "" && !samePackagePath(current.SourcePath, previous.SourcePath) {
+		_ = os.Remove(current.SourcePath)
+		pm.removeEmptyParents(filepath.Dir(current.SourcePath), pm.getSourcePackagesPath())
+	}
+
+	restored := *previous
+	restored.PendingReload = nil
+	if restored.State == sealpack.PackageStateEnabled {
+		restored.PendingReload = pm.generateReloadHints(restored.Manifest).ReloadHints
+	}
+	pm.packages[pkgID] = &restored
+	pm.buildDependencyGraph()
+	if err = pm.saveState(); err != nil {
+		pm.parent.Logger.Warnf("failed to save package state: %v", err)
+	}
+	pm.parent.Logger.Infof("扩展包 %s 已回滚到 v%s", pkgID, restored.Manifest.Package.Version)
+	return nil
+}
@@ -0,0 +1,241 @@
+package dice //nolint:testpackage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sealdice-core/dice/sealpack"
+)
+
+type testPackageVersionSource map[string][]*StorePackage
+
+func (s testPackageVersionSource) StoreQueryPackageVersions(id string) ([]*StorePackage, error) {
+	packages, ok := s[id]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found", id)
+	}
+	return packages, nil
+}
+
+func withTestDependencies(deps map[string]string) manifestOption {
+	return func(b *strings.Builder) {
+		b.WriteString("\n[dependencies]\n")
+		for id, constraint := range deps {
+			fmt.Fprintf(b, "%q = %q\n", id, constraint)
+		}
+	}
+}
+
+func withTestSealMinVersion(version string) manifestOption {
+	return func(b *strings.Builder) {
+		fmt.Fprintf(b, "\n[package.seal]\nmin_version = %q\n", version)
+	}
+}
+
+// testPackageStore 用 httptest 提供扩展包下载，并记录对应的商店条目
+type testPackageStore struct {
+	t      *testing.T
+	files  map[string]string
+	source testPackageVersionSource
+	server *httptest.Server
+}
+
+func newTestPackageStore(t *testing.T) *testPackageStore {
+	t.Helper()
+	s := &testPackageStore{t: t, files: map[string]string{}, source: testPackageVersionSource{}}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, file)
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *testPackageStore) add(pkgID, version string, deps map[string]string, opts ...manifestOption) *StorePackage {
+	s.t.Helper()
+	name := path.Base(pkgID)
+	opts = append(opts, withTestDependencies(deps))
+	file := createTestSealPack(s.t, filepath.Join("temp", "store"), pkgID, version,
+		map[string][]string{"scripts": {"scripts/*.js"}},
+		map[string]string{"scripts/" + name + ".js": "// " + name + " " + version},
+		opts...)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		s.t.Fatalf("ReadFile() error = %v", err)
+	}
+	sum := sha256.Sum256(data)
+	urlPath := "/" + pkgID + "/" + version + "/" + sealpack.PackageSourceFileName(pkgID, version)
+	s.files[urlPath] = file
+
+	archiveInfo, err := sealpack.InspectArchive(file)
+	if err != nil {
+		s.t.Fatalf("InspectArchive() error = %v", err)
+	}
+	pkg := &StorePackage{
+		ID:           pkgID,
+		Version:      version,
+		FullID:       BuildStorePackageFullID(pkgID, version),
+		Name:         name,
+		Seal:         archiveInfo.Manifest.Package.Seal,
+		Dependencies: deps,
+		Download: StorePackageDownload{
+			URL:  s.server.URL + urlPath,
+			Hash: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		},
+	}
+	s.source[pkgID] = append(s.source[pkgID], pkg)
+	return pkg
+}
+
+func (s *testPackageStore) install(pm *PackageManager, pkg *StorePackage) {
+	s.t.Helper()
+	if err := pm.InstallFromURL(pkg.Download.URL, pkg.Download.Hash); err != nil {
+		s.t.Fatalf("InstallFromURL(%s) error = %v", pkg.FullID, err)
+	}
+}
+
+func assertInstalledPackageVersion(t *testing.T, pm *PackageManager, pkgID, version string) {
+	t.Helper()
+	inst, ok := pm.Get(pkgID)
+	if !ok || inst.Manifest.Package.Version != version {
+		t.Fatalf("%s installed = %v, want v%s", pkgID, inst, version)
+	}
+	name := path.Base(pkgID)
+	data, err := os.ReadFile(filepath.Join(inst.InstallPath, "scripts", name+".js"))
+	if err != nil || string(data) != "// "+name+" "+version {
+		t.Fatalf("%s installed content = %q, %v", pkgID, data, err)
+	}
+	if _, err = os.Stat(inst.SourcePath); err != nil {
+		t.Fatalf("%s source missing: %v", pkgID, err)
+	}
+}
+
+func planSummary(plan []*PackagePlanItem) string {
+	parts := make([]string, 0, len(plan))
+	for _, item := range plan {
+		parts = append(parts, item.Action+":"+item.ID+"@"+item.Version)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestPackageManagerResolveInstall(t *testing.T) {
+	_, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	store := newTestPackageStore(t)
+	lib1 := store.add("alice/lib", "1.0.0", nil)
+	store.add("alice/lib", "1.5.0", nil)
+	store.add("alice/lib", "2.0.0", nil)
+	app1 := store.add("alice/app", "1.0.0", map[string]string{"alice/lib": "^1.0.0"})
+	store.add("alice/tool", "1.0.0", map[string]string{"alice/lib": ">=1.5.0"})
+	store.add("alice/broken", "1.0.0", map[string]string{"alice/lib": ">=2.0.0"})
+	store.install(pm, lib1)
+	store.install(pm, app1)
+
+	// tool 需要 lib >=1.5.0，而已安装的 app 限制 lib ^1.0.0，只能升级到 1.5.0
+	plan, err := pm.ResolveInstall(store.source, "alice/tool", "")
+	if err != nil {
+		t.Fatalf("ResolveInstall() error = %v", err)
+	}
+	if got := planSummary(plan); got != "upgrade:alice/lib@1.5.0,install:alice/tool@1.0.0" {
+		t.Fatalf("plan = %s", got)
+	}
+	if plan[0].FromVersion != "1.0.0" || plan[0].Requested || !plan[1].Requested {
+		t.Fatalf("plan items = %#v, %#v", plan[0], plan[1])
+	}
+
+	if _, err = pm.ResolveInstall(store.source, "alice/broken", ""); err == nil {
+		t.Fatal("ResolveInstall(broken) error = nil")
+	}
+	if _, err = pm.ResolveInstall(store.source, "alice/missing", ""); err == nil {
+		t.Fatal("ResolveInstall(missing) error = nil")
+	}
+
+	// tool 下载失败时，已升级的 lib 应回滚到原版本
+	store.files["/alice/tool/1.0.0/"+sealpack.PackageSourceFileName("alice/tool", "1.0.0")] = filepath.Join("temp", "nonexistent")
+	if err = pm.ApplyPlan(plan); err == nil {
+		t.Fatal("ApplyPlan() with failing download error = nil")
+	}
+	assertInstalledPackageVersion(t, pm, "alice/lib", "1.0.0")
+	if _, ok := pm.Get("alice/tool"); ok {
+		t.Fatal("alice/tool installed after rollback")
+	}
+}
+
+func TestPackageManagerCheckAndApplyUpdates(t *testing.T) {
+	_, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	store := newTestPackageStore(t)
+	lib1 := store.add("alice/lib", "1.0.0", nil)
+	store.add("alice/lib", "1.5.0", nil)
+	store.add("alice/lib", "2.0.0", nil)
+	app1 := store.add("alice/app", "1.0.0", map[string]string{"alice/lib": "^1.0.0"})
+	app2 := store.add("alice/app", "2.0.0", map[string]string{"alice/lib": "^1.5.0"})
+	store.add("alice/app", "3.0.0", map[string]string{"alice/lib": "^2.0.0"}, withTestSealMinVersion("99.0.0"))
+	store.install(pm, lib1)
+	store.install(pm, app1)
+
+	updates, plan, err := pm.CheckUpdates(store.source)
+	if err != nil {
+		t.Fatalf("CheckUpdates() error = %v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("updates = %#v", updates)
+	}
+	for _, info := range updates {
+		switch info.ID {
+		case "alice/app":
+			if info.Latest != "3.0.0" || info.Target != "2.0.0" || !strings.Contains(info.Blocked, "99.0.0") {
+				t.Fatalf("app update = %#v", info)
+			}
+		case "alice/lib":
+			if info.Latest != "2.0.0" || info.Target != "1.5.0" || info.Blocked == "" {
+				t.Fatalf("lib update = %#v", info)
+			}
+		default:
+			t.Fatalf("unexpected update %#v", info)
+		}
+	}
+	if got := planSummary(plan); got != "upgrade:alice/lib@1.5.0,upgrade:alice/app@2.0.0" {
+		t.Fatalf("plan = %s", got)
+	}
+
+	// app 校验失败时 lib 回滚
+	goodHash := app2.Download.Hash
+	app2.Download.Hash = map[string]string{"sha256": strings.Repeat("0", 64)}
+	if err = pm.ApplyPlan(plan); err == nil {
+		t.Fatal("ApplyPlan() with bad hash error = nil")
+	}
+	assertInstalledPackageVersion(t, pm, "alice/lib", "1.0.0")
+	assertInstalledPackageVersion(t, pm, "alice/app", "1.0.0")
+
+	app2.Download.Hash = goodHash
+	plan, err = pm.ResolveUpdates(store.source, []string{"alice/app"})
+	if err != nil {
+		t.Fatalf("ResolveUpdates() error = %v", err)
+	}
+	if err = pm.ApplyPlan(plan); err != nil {
+		t.Fatalf("ApplyPlan() error = %v", err)
+	}
+	assertInstalledPackageVersion(t, pm, "alice/lib", "1.5.0")
+	assertInstalledPackageVersion(t, pm, "alice/app", "2.0.0")
+
+	if _, err = pm.ResolveUpdates(store.source, []string{"alice/missing"}); err == nil {
+		t.Fatal("ResolveUpdates(missing) error = nil")
+	}
+}
@@ -0,0 +1,191 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"sealdice-core/dice/sealpack"
+)
+
+// 扩展包签名策略。签名存在但与包内容不符时，无论哪种策略都拒绝安装
+const (
+	PackageSignPolicyAllow   = "allow"   // 不检查签名者
+	PackageSignPolicyWarn    = "warn"    // 未经受信任者签名时记录警告
+	PackageSignPolicyRequire = "require" // 只安装受信任者签名的包
+)
+
+// PackageTrustedKey 信任库中的发布者公钥
+type PackageTrustedKey struct {
+	Name      string `json:"name"      yaml:"name"`
+	KeyID     string `json:"keyId"     yaml:"keyId"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
+	AddedAt   int64  `json:"addedAt"   yaml:"addedAt"`
+	Builtin   bool   `json:"builtin"   yaml:"-"` // 官方公钥，不能删除
+}
+
+// PackageSignStatus 扩展包的签名检查结果
+type PackageSignStatus struct {
+	Signed         bool   `json:"signed"`
+	Valid          bool   `json:"valid"`   // 签名与包内容一致，且能用包内公钥验证
+	Trusted        bool   `json:"trusted"` // 公钥在信任库中
+	Signer         string `json:"signer,omitempty"`
+	DeclaredSigner string `json:"declaredSigner,omitempty"` // 签名中自称的签名者
+	KeyID          string `json:"keyId,omitempty"`
+	SignedAt       int64  `json:"signedAt,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+func normalizePackageSignPolicy(policy string) (string, error) {
+	switch policy {
+	case "", PackageSignPolicyAllow:
+		return PackageSignPolicyAllow, nil
+	case PackageSignPolicyWarn, PackageSignPolicyRequire:
+		return policy, nil
+	}
+	return "", fmt.Errorf("未知的签名策略: %s", policy)
+}
+
+// trustedKeysLocked 配置中的公钥，加上官方 Mod 公钥(如果有)
+func (pm *PackageManager) trustedKeysLocked() []PackageTrustedKey {
+	keys := make([]PackageTrustedKey, 0, len(pm.parent.Config.PackageTrustedKeys)+1)
+	if OfficialModPublicKey != "" {
+		if keyID, err := sealpack.KeyFingerprint(OfficialModPublicKey); err == nil {
+			keys = append(keys, PackageTrustedKey{Name: "海豹官方", KeyID: keyID, PublicKey: OfficialModPublicKey, Builtin: true})
+		}
+	}
+	return append(keys, pm.parent.Config.PackageTrustedKeys...)
+}
+
+// TrustedKeys 返回信任库中的全部公钥
+func (pm *PackageManager) TrustedKeys() []PackageTrustedKey {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	return pm.trustedKeysLocked()
+}
+
+// AddTrustedKey 把发布者公钥加入信任库，公钥已存在时更新名字
+func (pm *PackageManager) AddTrustedKey(name, publicKey string) (*PackageTrustedKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("发布者名称不能为空")
+	}
+	publicKey = strings.TrimSpace(publicKey) + "\n"
+	keyID, err := sealpack.KeyFingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	for _, key := range pm.trustedKeysLocked() {
+		if key.KeyID == keyID && key.Builtin {
+			return nil, errors.New("该公钥为内置公钥")
+		}
+	}
+	keys := pm.parent.Config.PackageTrustedKeys
+	for i := range keys {
+		if keys[i].KeyID == keyID {
+			keys[i].Name = name
+			pm.parent.MarkModified()
+			key := keys[i]
+			return &key, nil
+		}
+	}
+	key := PackageTrustedKey{Name: name, KeyID: keyID, PublicKey: publicKey, AddedAt: time.Now().Unix()}
+	pm.parent.Config.PackageTrustedKeys = append(keys, key)
+	pm.parent.MarkModified()
+	return &key, nil
+}
+
+// RemoveTrustedKey 从信任库中删除公钥
+func (pm *PackageManager) RemoveTrustedKey(keyID string) error {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	keys := pm.parent.Config.PackageTrustedKeys
+	for i := range keys {
+		if keys[i].KeyID == keyID {
+			pm.parent.Config.PackageTrustedKeys = append(keys[:i:i], keys[i+1:]...)
+			pm.parent.MarkModified()
+			return nil
+		}
+	}
+	return errors.New("信任库中没有该公钥")
+}
+
+// SignPolicy 当前的签名策略
+func (pm *PackageManager) SignPolicy() string {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	policy, _ := normalizePackageSignPolicy(pm.parent.Config.PackageSignPolicy)
+	return policy
+}
+
+// SetSignPolicy 修改签名策略，只影响之后的安装
+func (pm *PackageManager) SetSignPolicy(policy string) error {
+	policy, err := normalizePackageSignPolicy(policy)
+	if err != nil {
+		return err
+	}
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	pm.parent.Config.PackageSignPolicy = policy
+	pm.parent.MarkModified()
+	return nil
+}
+
+// checkSignatureLocked 检查扩展包的签名，并在信任库中查找签名者
+func (pm *PackageManager) checkSignatureLocked(pkgPath string) *PackageSignStatus {
+	status := &PackageSignStatus{}
+	sig, err := sealpack.ReadArchiveSignature(pkgPath)
+	if sig == nil && err == nil {
+		return status
+	}
+	status.Signed = true
+	if sig != nil {
+		status.DeclaredSigner = sig.Signer
+		status.KeyID = sig.KeyID
+		status.SignedAt = sig.SignedAt
+	}
+	if err == nil {
+		err = sig.Verify(sig.PublicKey)
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Valid = true
+	for _, key := range pm.trustedKeysLocked() {
+		if key.KeyID == sig.KeyID {
+			status.Trusted = true
+			status.Signer = key.Name
+			break
+		}
+	}
+	return status
+}
+
+// enforceSignPolicyLocked 按签名策略决定是否允许安装
+func (pm *PackageManager) enforceSignPolicyLocked(pkgPath string, manifest *sealpack.Manifest) error {
+	status := pm.checkSignatureLocked(pkgPath)
+	if status.Signed && !status.Valid {
+		return fmt.Errorf("扩展包签名校验失败: %s", status.Error)
+	}
+	if status.Trusted {
+		pm.parent.Logger.Infof("扩展包 %s 由 %s 签名", manifest.Package.ID, status.Signer)
+		return nil
+	}
+	policy, _ := normalizePackageSignPolicy(pm.parent.Config.PackageSignPolicy)
+	switch policy {
+	case PackageSignPolicyRequire:
+		return errors.New("当前只允许安装受信任发布者签名的扩展包")
+	case PackageSignPolicyWarn:
+		if status.Signed {
+			pm.parent.Logger.Warnf("扩展包 %s 的签名者 %s(%s) 不在信任库中", manifest.Package.ID, status.DeclaredSigner, status.KeyID)
+		} else {
+			pm.parent.Logger.Warnf("扩展包 %s 未签名", manifest.Package.ID)
+		}
+	}
+	return nil
+}
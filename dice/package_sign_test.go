@@ -0,0 +1,134 @@
+package dice //nolint:testpackage
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"sealdice-core/dice/sealpack"
+)
+
+func generateTestSigningKey(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+func readTestArchiveEntry(t *testing.T, pkgPath, name string) string {
+	t.Helper()
+	reader, err := zip.OpenReader(pkgPath)
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	defer reader.Close()
+	for _, file := range reader.File {
+		if file.Name == name {
+			rc, _ := file.Open()
+			defer rc.Close()
+			data, _ := io.ReadAll(rc)
+			return string(data)
+		}
+	}
+	t.Fatalf("%s not found in %s", name, pkgPath)
+	return ""
+}
+
+func TestPackageManagerSignPolicy(t *testing.T) {
+	_, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	privateKey, publicKey := generateTestSigningKey(t)
+	pkgID := "alice/signed"
+	contents := map[string][]string{"scripts": {"scripts/*.js"}}
+
+	if err := pm.SetSignPolicy("strict"); err == nil {
+		t.Fatal("SetSignPolicy(strict) error = nil")
+	}
+	if err := pm.SetSignPolicy(PackageSignPolicyRequire); err != nil {
+		t.Fatalf("SetSignPolicy() error = %v", err)
+	}
+
+	unsigned := createTestSealPack(t, filepath.Join("temp", "unsigned"), pkgID, "1.0.0", contents, map[string]string{
+		"scripts/main.js": "// v1",
+	})
+	if err := pm.Install(unsigned); err == nil {
+		t.Fatal("Install(unsigned) under require policy error = nil")
+	}
+
+	signed := createTestSealPack(t, filepath.Join("temp", "signed"), pkgID, "1.0.0", contents, map[string]string{
+		"scripts/main.js": "// v1",
+	})
+	if _, err := sealpack.SignArchive(signed, signed, "Alice", privateKey); err != nil {
+		t.Fatalf("SignArchive() error = %v", err)
+	}
+	preview, err := pm.Preview(signed)
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	if s := preview.Signature; !s.Signed || !s.Valid || s.Trusted || s.DeclaredSigner != "Alice" || preview.SignPolicy != PackageSignPolicyRequire {
+		t.Fatalf("untrusted preview = %#v, policy %s", s, preview.SignPolicy)
+	}
+	if err = pm.Install(signed); err == nil {
+		t.Fatal("Install() with untrusted signer error = nil")
+	}
+
+	key, err := pm.AddTrustedKey("Alice Studio", publicKey)
+	if err != nil {
+		t.Fatalf("AddTrustedKey() error = %v", err)
+	}
+	preview, err = pm.Preview(signed)
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	if s := preview.Signature; !s.Trusted || s.Signer != "Alice Studio" || s.KeyID != key.KeyID {
+		t.Fatalf("trusted preview = %#v", s)
+	}
+	if err = pm.Install(signed); err != nil {
+		t.Fatalf("Install() with trusted signer error = %v", err)
+	}
+
+	// 把 v2 的签名放进内容不同的包里，即便策略为 allow 也应拒绝
+	if err = pm.SetSignPolicy(PackageSignPolicyAllow); err != nil {
+		t.Fatalf("SetSignPolicy() error = %v", err)
+	}
+	v2 := createTestSealPack(t, filepath.Join("temp", "v2"), pkgID, "2.0.0", contents, map[string]string{
+		"scripts/main.js": "// v2",
+	})
+	if _, err = sealpack.SignArchive(v2, v2, "Alice", privateKey); err != nil {
+		t.Fatalf("SignArchive() error = %v", err)
+	}
+	tampered := createTestSealPack(t, filepath.Join("temp", "tampered"), pkgID, "2.0.0", contents, map[string]string{
+		"scripts/main.js":      "// evil",
+		sealpack.SignatureFile: readTestArchiveEntry(t, v2, sealpack.SignatureFile),
+	})
+	if err = pm.Install(tampered); err == nil {
+		t.Fatal("Install(tampered) error = nil")
+	}
+
+	if err = pm.RemoveTrustedKey(key.KeyID); err != nil {
+		t.Fatalf("RemoveTrustedKey() error = %v", err)
+	}
+	for _, k := range pm.TrustedKeys() {
+		if k.KeyID == key.KeyID {
+			t.Fatalf("key %s still trusted", key.KeyID)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package dice
 import (
 	"fmt"
 	"path/filepath"
+	"sync"
 
 	"sealdice-core/message"
 	"sealdice-core/utils"
@@ -24,6 +25,26 @@ type HTTPSimpleMessage struct {
 type PlatformAdapterHTTP struct {
 	EndPoint      *EndPointInfo
 	RecentMessage []HTTPSimpleMessage
+
+	lock sync.Mutex
+}
+
+func (pa *PlatformAdapterHTTP) appendRecent(uid, text, messageType string) {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+	pa.RecentMessage = append(pa.RecentMessage, HTTPSimpleMessage{uid, text, messageType})
+}
+
+// TakeRecentMessages 取出并清空最近发送的消息
+func (pa *PlatformAdapterHTTP) TakeRecentMessages() []HTTPSimpleMessage {
+	pa.lock.Lock()
+	defer pa.lock.Unlock()
+	msgs := pa.RecentMessage
+	pa.RecentMessage = []HTTPSimpleMessage{}
+	if msgs == nil {
+		msgs = []HTTPSimpleMessage{}
+	}
+	return msgs
 }
 
 func (pa *PlatformAdapterHTTP) SendSegmentToGroup(ctx *MsgContext, groupID string, msg []message.IMessageElement, flag string) {
@@ -54,7 +75,7 @@ func getUITestReplySplitLen(ctx *MsgContext) int {
 func (pa *PlatformAdapterHTTP) SendToPerson(ctx *MsgContext, uid string, text string, flag string) {
 	sp := utils.SplitLongText(text, getUITestReplySplitLen(ctx), utils.DefaultSplitPaginationHint)
 	for _, sub := range sp {
-		pa.RecentMessage = append(pa.RecentMessage, HTTPSimpleMessage{uid, sub, "private"})
+		pa.appendRecent(uid, sub, "private")
 	}
 	pa.EndPoint.Session.OnMessageSend(ctx, &Message{
 		MessageType: "private",
@@ -70,7 +91,7 @@ func (pa *PlatformAdapterHTTP) SendToPerson(ctx *MsgContext, uid string, text st
 func (pa *PlatformAdapterHTTP) SendToGroup(ctx *MsgContext, uid string, text string, flag string) {
 	sp := utils.SplitLongText(text, getUITestReplySplitLen(ctx), utils.DefaultSplitPaginationHint)
 	for _, sub := range sp {
-		pa.RecentMessage = append(pa.RecentMessage, HTTPSimpleMessage{uid, sub, "group"})
+		pa.appendRecent(uid, sub, "group")
 	}
 	pa.EndPoint.Session.OnMessageSend(ctx, &Message{
 		MessageType: "group",
@@ -0,0 +1,347 @@
+package dice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+// V2 表达式的计算过程解释
+//
+// dicescript 只提供最终的 detail 文本，这里根据字节码(GetAsmText)和执行时记录的 DetailSpans
+// 回放一遍栈，得到每个骰子、属性读取和运算的中间结果。
+// 回放只支持不含跳转、调用、赋值的表达式，遇到不支持的指令时退化为只列出骰子和属性。
+
+// DiceExplainNode 计算过程中的一个节点
+type DiceExplainNode struct {
+	Kind     string             `json:"kind"`             // expr 整体 / dice 骰子 / load 属性 / op 运算 / const 常量
+	Expr     string             `json:"expr"`             // 对应的表达式文本
+	Value    string             `json:"value"`            // 计算结果
+	Op       string             `json:"op,omitempty"`     // 运算符
+	Detail   string             `json:"detail,omitempty"` // 骰子的各个出目，| 之后为舍弃的骰子
+	Tag      string             `json:"tag,omitempty"`    // dicescript 中的来源标记，如 dice-coc-bonus
+	Children []*DiceExplainNode `json:"children,omitempty"`
+
+	val *ds.VMValue
+}
+
+// DiceExplainResult 一次计算的解释
+type DiceExplainResult struct {
+	Expr     string           `json:"expr"`     // 参与计算的表达式
+	Rest     string           `json:"rest"`     // 表达式之后的剩余文本
+	Value    string           `json:"value"`    // 最终结果
+	Detail   string           `json:"detail"`   // 原本的计算过程文本
+	Complete bool             `json:"complete"` // 是否完整回放，否则 Root 下只有骰子和属性节点
+	Root     *DiceExplainNode `json:"root"`
+	Asm      string           `json:"asm,omitempty"`
+}
+
+var diceExplainBinOps = map[string]string{
+	"add": "+", "sub": "-", "mul": "*", "div": "/", "mod": "%", "pow": "**",
+	"nullCoalescing": "??", "&": "&", "|": "|",
+	"comp.lt": "<", "comp.le": "<=", "comp.eq": "==", "comp.ne": "!=", "comp.ge": ">=", "comp.gt": ">",
+}
+
+// 只消耗一个参数的骰子设置指令
+var diceExplainDiceParams = map[string]bool{
+	"dice.setTimes": true, "dice.setKeepHigh": true, "dice.setKeepLow": true,
+	"dice.setDropLow": true, "dice.setDropHigh": true, "dice.setMin": true, "dice.setMax": true,
+	"wod.pool": true, "wod.points": true, "wod.threshold": true, "wod.thresholdQ": true,
+	"dc.setPool": true, "dc.setPoints": true,
+}
+
+// 出骰的指令，消耗一个参数(面数或骰数)
+var diceExplainDiceOps = map[string]bool{
+	"dice": true, "coc.bonus": true, "coc.penalty": true, "dice.wod": true, "dice.dc": true,
+}
+
+func diceExplainBinOp(vm *ds.Context, op string, a, b *ds.VMValue) *ds.VMValue {
+	switch op {
+	case "add":
+		return a.OpAdd(vm, b)
+	case "sub":
+		return a.OpSub(vm, b)
+	case "mul":
+		return a.OpMultiply(vm, b)
+	case "div":
+		return a.OpDivide(vm, b)
+	case "mod":
+		return a.OpModulus(vm, b)
+	case "pow":
+		return a.OpPower(vm, b)
+	case "nullCoalescing":
+		return a.OpNullCoalescing(vm, b)
+	case "&":
+		return a.OpBitwiseAnd(vm, b)
+	case "|":
+		return a.OpBitwiseOr(vm, b)
+	case "comp.lt":
+		return a.OpCompLT(vm, b)
+	case "comp.le":
+		return a.OpCompLE(vm, b)
+	case "comp.eq":
+		return a.OpCompEQ(vm, b)
+	case "comp.ne":
+		return a.OpCompNE(vm, b)
+	case "comp.ge":
+		return a.OpCompGE(vm, b)
+	case "comp.gt":
+		return a.OpCompGT(vm, b)
+	}
+	return nil
+}
+
+func diceExplainValueText(v *ds.VMValue) string {
+	if v == nil {
+		return ""
+	}
+	return v.ToString()
+}
+
+// diceExplainSpanNode 由 DetailSpan 生成骰子或属性节点
+func diceExplainSpanNode(span ds.BufferSpan, source string) *DiceExplainNode {
+	kind := "dice"
+	if strings.HasPrefix(span.Tag, "load") {
+		kind = "load"
+	}
+	expr := span.Expr
+	if expr == "" && int(span.Begin) >= 0 && int(span.End) <= len(source) && span.Begin < span.End {
+		expr = source[span.Begin:span.End]
+	}
+	node := &DiceExplainNode{Kind: kind, Expr: strings.TrimSpace(expr), Value: diceExplainValueText(span.Ret), Tag: span.Tag, val: span.Ret}
+	if span.Text != "" && span.Text != node.Value {
+		node.Detail = span.Text
+	}
+	return node
+}
+
+// diceExplainReplay 回放字节码，失败时返回 nil
+func diceExplainReplay(vm *ds.Context, source string) *DiceExplainNode {
+	spans := map[[2]ds.IntType][]ds.BufferSpan{}
+	for _, s := range vm.DetailSpans {
+		k := [2]ds.IntType{s.Begin, s.End}
+		spans[k] = append(spans[k], s)
+	}
+
+	var stack []*DiceExplainNode
+	var params []*DiceExplainNode
+	var pending *ds.BufferSpan
+	pop := func() *DiceExplainNode {
+		if len(stack) == 0 {
+			return nil
+		}
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return n
+	}
+	takeSpan := func() *DiceExplainNode {
+		if pending == nil {
+			return nil
+		}
+		node := diceExplainSpanNode(*pending, source)
+		pending = nil
+		return node
+	}
+
+	for _, line := range strings.Split(vm.GetAsmText(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "===") || line == "nop" || line == "dice.init" ||
+			line == "wod.init" || line == "dc.setInit" {
+			continue
+		}
+		op, arg, _ := strings.Cut(line, " ")
+		switch {
+		case line == "halt":
+			if len(stack) != 1 {
+				return nil
+			}
+			return stack[0]
+		case op == "push.int":
+			i, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil
+			}
+			stack = append(stack, &DiceExplainNode{Kind: "const", Expr: arg, Value: arg, val: ds.NewIntVal(ds.IntType(i))})
+		case op == "push.flt":
+			f, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil
+			}
+			v := ds.NewFloatVal(f)
+			stack = append(stack, &DiceExplainNode{Kind: "const", Expr: v.ToString(), Value: v.ToString(), val: v})
+		case op == "push.str":
+			stack = append(stack, &DiceExplainNode{Kind: "const", Expr: strconv.Quote(arg), Value: arg, val: ds.NewStrVal(arg)})
+		case op == "mark.detail":
+			var b, e int64
+			if _, err := fmt.Sscanf(arg, "%d, %d", &b, &e); err != nil {
+				return nil
+			}
+			k := [2]ds.IntType{ds.IntType(b), ds.IntType(e)}
+			if len(spans[k]) == 0 {
+				return nil
+			}
+			s := spans[k][0]
+			spans[k] = spans[k][1:]
+			pending = &s
+		case op == "ld.d":
+			node := takeSpan()
+			if node == nil {
+				return nil
+			}
+			node.Kind = "load"
+			stack = append(stack, node)
+		case diceExplainDiceParams[op]:
+			p := pop()
+			if p == nil {
+				return nil
+			}
+			params = append(params, p)
+		case diceExplainDiceOps[op] || op == "dice.fate":
+			if op != "dice.fate" {
+				p := pop()
+				if p == nil {
+					return nil
+				}
+				params = append(params, p)
+			}
+			node := takeSpan()
+			if node == nil {
+				return nil
+			}
+			for _, p := range params {
+				// 常量参数已经体现在表达式文本里了
+				if p.Kind != "const" {
+					node.Children = append(node.Children, p)
+				}
+			}
+			params = nil
+			stack = append(stack, node)
+		case diceExplainBinOps[op] != "":
+			b, a := pop(), pop()
+			if a == nil || b == nil || a.val == nil || b.val == nil {
+				return nil
+			}
+			ret := diceExplainBinOp(vm, op, a.val, b.val)
+			if ret == nil {
+				return nil
+			}
+			sym := diceExplainBinOps[op]
+			stack = append(stack, &DiceExplainNode{
+				Kind:     "op",
+				Op:       sym,
+				Expr:     diceExplainWrap(a) + sym + diceExplainWrap(b),
+				Value:    diceExplainValueText(ret),
+				Children: []*DiceExplainNode{a, b},
+				val:      ret,
+			})
+		case op == "neg" || op == "pos":
+			a := pop()
+			if a == nil || a.val == nil {
+				return nil
+			}
+			ret, sym := a.val.OpPositive(), "+"
+			if op == "neg" {
+				ret, sym = a.val.OpNegation(), "-"
+			}
+			if ret == nil {
+				return nil
+			}
+			stack = append(stack, &DiceExplainNode{
+				Kind: "op", Op: sym, Expr: sym + diceExplainWrap(a), Value: diceExplainValueText(ret),
+				Children: []*DiceExplainNode{a}, val: ret,
+			})
+		default:
+			// 跳转、调用、赋值等，无法简单回放
+			return nil
+		}
+	}
+	return nil
+}
+
+func diceExplainWrap(n *DiceExplainNode) string {
+	if n.Kind == "op" && len(n.Children) > 1 {
+		return "(" + n.Expr + ")"
+	}
+	return n.Expr
+}
+
+// Explain 解释最近一次 V2 计算的过程，V1 结果返回 nil
+func (r *VMResultV2m) Explain() *DiceExplainResult {
+	if r == nil || r.legacy != nil || r.vm == nil {
+		return nil
+	}
+	vm := r.vm
+	source := vm.Matched
+	ret := &DiceExplainResult{
+		Expr:   strings.TrimSpace(source),
+		Rest:   vm.RestInput,
+		Value:  diceExplainValueText(vm.Ret),
+		Detail: vm.GetDetailText(),
+		Asm:    vm.GetAsmText(),
+	}
+
+	root := diceExplainReplay(vm, source)
+	if root != nil && root.Value == ret.Value {
+		ret.Complete = true
+	} else {
+		root = &DiceExplainNode{Kind: "expr", Expr: ret.Expr, Value: ret.Value}
+		for _, s := range vm.DetailSpans {
+			root.Children = append(root.Children, diceExplainSpanNode(s, source))
+		}
+	}
+	if root.Kind == "const" || root.Kind == "op" {
+		root.Expr = ret.Expr
+	}
+	ret.Root = root
+	return ret
+}
+
+var diceExplainKindNames = map[string]string{
+	"dice": "骰子",
+	"load": "属性",
+	"op":   "运算",
+}
+
+// Text 以树状文本展示计算过程
+func (e *DiceExplainResult) Text() string {
+	var b strings.Builder
+	var walk func(n *DiceExplainNode, prefix string, isLast bool, isRoot bool)
+	walk = func(n *DiceExplainNode, prefix string, isLast bool, isRoot bool) {
+		line := n.Expr
+		if n.Kind != "const" || isRoot {
+			line += " = " + n.Value
+		}
+		if name := diceExplainKindNames[n.Kind]; name != "" {
+			note := name
+			switch {
+			case n.Kind == "op" && len(n.Children) == 2:
+				note += ": " + n.Children[0].Value + " " + n.Op + " " + n.Children[1].Value
+			case n.Detail != "":
+				note += ": " + n.Detail
+			}
+			line += " (" + note + ")"
+		}
+		childPrefix := prefix
+		if isRoot {
+			b.WriteString(line)
+		} else {
+			branch := "├ "
+			childPrefix += "│ "
+			if isLast {
+				branch = "└ "
+				childPrefix = prefix + "  "
+			}
+			b.WriteString("\n" + prefix + branch + line)
+		}
+		for i, c := range n.Children {
+			walk(c, childPrefix, i == len(n.Children)-1, false)
+		}
+	}
+	walk(e.Root, "", true, true)
+	if !e.Complete {
+		b.WriteString("\n(表达式含有流程控制或函数调用，仅列出骰子与属性)")
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+//nolint:testpackage
+package dice
+
+import (
+	"strings"
+	"testing"
+
+	"sealdice-core/model"
+)
+
+func TestDiceExplainTree(t *testing.T) {
+	_, ep, _, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	ctx := CreateTempCtx(ep, newGroupMsg("QQ-Group:1", "QQ:1", ".r"))
+
+	r, _, err := DiceExprEvalBase(ctx, "4d6k3 + (1d6 * 2) 攻击", RollExtraFlags{V2Only: true})
+	if err != nil {
+		t.Fatalf("DiceExprEvalBase() error = %v", err)
+	}
+	e := r.Explain()
+	if e == nil || !e.Complete {
+		t.Fatalf("Explain() = %+v", e)
+	}
+	if e.Root.Kind != "op" || e.Root.Op != "+" || e.Root.Value != r.ToString() || len(e.Root.Children) != 2 {
+		t.Fatalf("root = %+v", e.Root)
+	}
+	pool := e.Root.Children[0]
+	if pool.Kind != "dice" || pool.Expr != "4d6k3" || !strings.Contains(pool.Detail, "|") {
+		t.Fatalf("dice pool = %+v", pool)
+	}
+	mul := e.Root.Children[1]
+	if mul.Op != "*" || mul.Children[0].Kind != "dice" || mul.Children[1].Kind != "const" {
+		t.Fatalf("mul = %+v", mul)
+	}
+
+	// 含有三元运算(跳转)的表达式只能列出骰子
+	r, _, err = DiceExprEvalBase(ctx, "1d20 > 10 ? 1 : 2", RollExtraFlags{V2Only: true})
+	if err != nil {
+		t.Fatalf("DiceExprEvalBase() error = %v", err)
+	}
+	e = r.Explain()
+	if e == nil || e.Complete || len(e.Root.Children) != 1 || e.Root.Children[0].Kind != "dice" {
+		t.Fatalf("Explain() = %+v", e)
+	}
+}
+
+func TestDiceExplainCommand(t *testing.T) {
+	d, ep, adapter, cleanup := newExecuteNewTestDice(t)
+	defer cleanup()
+	migrateTestTables(t, d, &model.AttributesItemModel{})
+
+	send := func(text string) string {
+		t.Helper()
+		return execTestMsg(t, d, ep, adapter, newGroupMsg("QQ-Group:777", "QQ:777", text))
+	}
+
+	send(".st 力量60")
+	reply := send(".r --explain 1d20+力量*2")
+	if !strings.Contains(reply, "计算过程:") || !strings.Contains(reply, "力量 = 60 (属性)") ||
+		!strings.Contains(reply, "力量*2 = 120 (运算: 60 * 2)") {
+		t.Fatalf("reply = %q", reply)
+	}
+}
@@ -17,7 +17,8 @@ import (
 	"sealdice-core/logger"
 )
 
-func (ctx *MsgContext) GenDefaultRollVmConfig() *ds.RollConfig {
+// rollSyntaxConfig 指令掷骰启用的语法和算力限制，不依赖具体消息
+func rollSyntaxConfig() ds.RollConfig {
 	config := ds.RollConfig{}
 
 	// 根据当前规则开语法 - 暂时是都开
@@ -27,6 +28,11 @@ func (ctx *MsgContext) GenDefaultRollVmConfig() *ds.RollConfig {
 	config.EnableDiceDoubleCross = true
 	config.OpCountLimit = 30000
 	config.ParseExprLimit = 10000000 // kenichiLyon: 限制解析算力，防止递归过深，这里以建议值1000万设置。
+	return config
+}
+
+func (ctx *MsgContext) GenDefaultRollVmConfig() *ds.RollConfig {
+	config := rollSyntaxConfig()
 
 	am := ctx.Dice.AttrsManager
 	config.HookValueStore = func(vm *ds.Context, name string, v *ds.VMValue) (overwrite *ds.VMValue, solved bool) {
@@ -769,6 +775,13 @@ func (ctx *MsgContext) CreateVmIfNotExists() {
 	ctx.vm = ds.NewVM()
 
 	ctx.vm.Config = *ctx.GenDefaultRollVmConfig()
+	if ctx.fairDiceCounter != 0 {
+		// 公平骰: 与v1共用本条指令的随机源
+		ctx.vm.RandSrc = ctx._v1Rand
+	} else {
+		// 与v1共用全局随机源
+		ctx.vm.RandSrc = randSource
+	}
 
 	am := ctx.Dice.AttrsManager
 	ctx.vm.GlobalValueLoadOverwriteFunc = func(name string, curVal *ds.VMValue) *ds.VMValue {
@@ -892,8 +905,6 @@ func TextMapCompatibleCheck(d *Dice, category, k string, textItems []TextTemplat
 	key := fmt.Sprintf("%s:%s", category, k)
 	x, _ := d.TextMapCompatible.LoadOrStore(key, &SyncMap[string, TextItemCompatibleInfo]{})
 
-	am := d.AttrsManager
-
 	for _, textItem := range textItems {
 		formatExpr := textItem[0].(string)
 
@@ -903,32 +914,9 @@ func TextMapCompatibleCheck(d *Dice, category, k string, textItems []TextTemplat
 		tmpSeed2 := uint64(time.Now().UnixMicro())
 		randSourceDrawAndTmplSelect.Seed(int64(tmpSeed2))
 
-		setupTestAttrs := func(ctx *MsgContext) {
-			// 标记为兼容性测试环境，跳过不必要的数据库查询
-			ctx.IsCompatibilityTest = true
-			// $g
-			if attrs, _ := am.LoadById("UI-Group:2101"); attrs != nil {
-				attrs.Clear()
-				attrs.IsSaved = true
-			}
-			// $m
-			if attrs, _ := am.LoadById("UI:1101"); attrs != nil {
-				attrs.Clear()
-				attrs.IsSaved = true
-			}
-			// 群内临时人物卡
-			if attrs, _ := am.LoadById("UI-Group:2101-UI:1101"); attrs != nil {
-				attrs.Clear()
-				attrs.IsSaved = true
-			}
-
-			// $t
-			ctx.Player.ValueMapTemp = &ds.ValueMap{}
-		}
-
 		// v2 部分
 		ctx := CreateTempCtx(d.UIEndpoint, msg)
-		setupTestAttrs(ctx)
+		setupCompatibleTestAttrs(ctx)
 		ctx.CreateVmIfNotExists()
 		ctx.vm.Seed = tmpSeed
 		ctx.vm.Init()
@@ -947,7 +935,7 @@ func TextMapCompatibleCheck(d *Dice, category, k string, textItems []TextTemplat
 
 		// v1 部分
 		ctx = CreateTempCtx(d.UIEndpoint, msg)
-		setupTestAttrs(ctx)
+		setupCompatibleTestAttrs(ctx)
 		ctx.CreateVmIfNotExists() // 也要设置，因为牌堆要用
 		ctx.vm.Seed = tmpSeed
 		ctx.vm.Init()
@@ -969,7 +957,7 @@ func TextMapCompatibleCheck(d *Dice, category, k string, textItems []TextTemplat
 		if err1 != nil {
 			text1 = "" // 因为 formatV1 没有值的时候会返回东西，这样使得两版本一致
 		}
-		setupTestAttrs(ctx) // 清理
+		setupCompatibleTestAttrs(ctx) // 清理
 
 		var ver string
 		if err2 == nil {
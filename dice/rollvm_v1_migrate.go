@@ -0,0 +1,666 @@
+package dice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+// V1 表达式迁移
+//
+// 用V1的解析器(DiceRollParser)解析自定义回复、牌堆和自定义文案中的表达式，
+// 按语法树把两版含义不同、或V2不支持的写法改写为等价的V2形式，改写不了的给出提示。
+// 改写后在相同的随机种子下分别用V1执行原文、V2执行改写结果，输出不一致的同样会被标出，供人工确认。
+
+const (
+	V1MigrateKindReply      = "reply"
+	V1MigrateKindDeck       = "deck"
+	V1MigrateKindCustomText = "custom-text"
+)
+
+// V1MigrateNote 迁移时发现的问题
+type V1MigrateNote struct {
+	Level string `json:"level"` // rewrite 已自动改写 / warn 行为可能不同，需人工确认 / error V1无法解析
+	Text  string `json:"text"`
+}
+
+// V1MigrateItem 一处表达式的迁移结果
+type V1MigrateItem struct {
+	Location string          `json:"location"` // 在文件中的位置
+	Source   string          `json:"source"`
+	Target   string          `json:"target"`
+	Notes    []V1MigrateNote `json:"notes"`
+	OutputV1 string          `json:"outputV1"`
+	OutputV2 string          `json:"outputV2"`
+	Same     bool            `json:"same"` // 相同随机种子下两版输出是否一致
+
+	textMode bool
+	apply    func(target string) // 写回原处
+}
+
+// Changed 改写结果与原文不同
+func (i *V1MigrateItem) Changed() bool {
+	return i.Source != i.Target
+}
+
+// V1MigrateFile 一个文件的迁移报告，只包含需要关注的表达式
+type V1MigrateFile struct {
+	Kind      string           `json:"kind"`
+	Filename  string           `json:"filename"`
+	Name      string           `json:"name"`
+	Items     []*V1MigrateItem `json:"items"`
+	Changed   int              `json:"changed"`  // 可自动改写的数量
+	Warnings  int              `json:"warnings"` // 需人工确认的数量
+	Total     int              `json:"total"`    // 检查过的表达式总数
+	ReadOnly  string           `json:"readOnly,omitempty"`
+	VMVersion string           `json:"vmVersion"` // 该类内容当前使用的引擎版本，为v1时不能写回V2语法
+	Diff      string           `json:"diff"`
+	CheckedAt int64            `json:"checkedAt"`
+}
+
+// 全角比较符号，V2只认半角
+var v1MigrateFullWidthOps = strings.NewReplacer("＜＝", "<=", "＞＝", ">=", "＝＝", "==", "！＝", "!=", "＜", "<", "＞", ">")
+
+// V1ExprToV2 将V1表达式改写为V2形式，textMode 为 true 时按文本模板处理(即 {} 中为表达式)
+func V1ExprToV2(expr string, textMode bool) (string, []V1MigrateNote, error) {
+	buffer := expr
+	if textMode {
+		buffer = "\x1e" + expr + "\x1e"
+	}
+	p := &DiceRollParser{Buffer: buffer}
+	_ = p.Init()
+	p.RollExpression.Init(512)
+	if err := p.Parse(); err != nil {
+		return expr, []V1MigrateNote{{Level: "error", Text: "V1无法解析该表达式"}}, err
+	}
+
+	runes := []rune(buffer)
+	type edit struct {
+		begin, end int
+		text       string
+	}
+	var edits []edit
+	var notes []V1MigrateNote
+	noted := map[string]bool{}
+	note := func(level, text string) {
+		if !noted[text] {
+			noted[text] = true
+			notes = append(notes, V1MigrateNote{Level: level, Text: text})
+		}
+	}
+	hasPrefix := func(n *node32, s string) bool {
+		return strings.HasPrefix(string(runes[n.begin:n.end]), s)
+	}
+
+	var walk func(n *node32, inBacktick bool)
+	walk = func(n *node32, inBacktick bool) {
+		for ; n != nil; n = n.next {
+			begin, end := int(n.begin), int(n.end)
+			child := inBacktick
+			switch n.pegRule {
+			case rulelogicOr:
+				edits = append(edits, edit{begin, begin + 2, "|"})
+				note("rewrite", "V1中 || 是按位或，已改为 |")
+			case rulelogicAnd:
+				l := 2
+				if hasPrefix(n, "&amp;&amp;") {
+					l = 10
+				}
+				edits = append(edits, edit{begin, begin + l, "&"})
+				note("rewrite", "V1中 && 是按位与，已改为 &")
+			case rulebitwiseAnd:
+				if hasPrefix(n, "&amp;") {
+					edits = append(edits, edit{begin, begin + 5, "&"})
+					note("rewrite", "转义的 &amp; 已还原为 &")
+				}
+			case rulelt, rulegt, rulele, rulege, ruleeq, rulene:
+				op := strings.TrimRight(string(runes[begin:end]), " \t\r\n")
+				if ascii := v1MigrateFullWidthOps.Replace(op); ascii != op {
+					edits = append(edits, edit{begin, begin + len([]rune(op)), ascii})
+					note("rewrite", "V2不支持全角比较符号，已改为半角")
+				}
+			case rulesp:
+				text := string(runes[begin:end])
+				if idx := strings.Index(text, "//"); idx >= 0 {
+					// sp 中只有空白和注释，注释到行尾为止，直接去掉注释部分
+					b := begin + len([]rune(text[:idx]))
+					e := b
+					for e < end && runes[e] != '\n' {
+						e++
+					}
+					edits = append(edits, edit{b, e, ""})
+					note("rewrite", "V2不支持表达式内的 // 注释，已删除")
+				}
+			case rulevalue:
+				switch {
+				case hasPrefix(n, "int("):
+					edits = append(edits, edit{begin, begin + 3, "toInt"})
+					note("rewrite", "int() 已改为 toInt()")
+				case hasPrefix(n, "str("):
+					edits = append(edits, edit{begin, begin + 3, "toStr"})
+					note("rewrite", "str() 已改为 toStr()")
+				case hasPrefix(n, "this"):
+					note("warn", "this.xxx 在V1中未设置时为0，V2中为null")
+				}
+			case rulestmtIf:
+				note("warn", "if 语句的值在V1中为空字符串，V2中为null")
+			case rulefstring:
+				child = runes[begin] == '`'
+			case rulee:
+				// V1 的 `...` 字符串中允许 {{ }}，V2 只认 { }
+				if inBacktick {
+					b := begin - 1
+					for b > 0 && strings.ContainsRune(" \t\r\n", runes[b]) {
+						b--
+					}
+					if b > 0 && runes[b] == '{' && runes[b-1] == '{' && end+1 < len(runes) &&
+						runes[end] == '}' && runes[end+1] == '}' {
+						edits = append(edits, edit{b - 1, b, ""}, edit{end + 1, end + 2, ""})
+						note("rewrite", "字符串中的 {{ }} 已改为 { }")
+					}
+				}
+				child = false
+			}
+			walk(n.up, child)
+		}
+	}
+	walk(p.AST(), false)
+
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].begin > edits[j].begin })
+	for _, e := range edits {
+		runes = append(runes[:e.begin], append([]rune(e.text), runes[e.end:]...)...)
+	}
+	ret := string(runes)
+	if textMode {
+		ret = strings.TrimSuffix(strings.TrimPrefix(ret, "\x1e"), "\x1e")
+	} else if strings.TrimRight(expr, " \t\r\n") == expr {
+		// 删掉行尾注释后留下的空白
+		ret = strings.TrimRight(ret, " \t\r\n")
+	}
+	return ret, notes, nil
+}
+
+// setupCompatibleTestAttrs 兼容性测试环境，清空测试用户的各类变量
+func setupCompatibleTestAttrs(ctx *MsgContext) {
+	am := ctx.Dice.AttrsManager
+	// 标记为兼容性测试环境，跳过不必要的数据库查询
+	ctx.IsCompatibilityTest = true
+	// $g
+	if attrs, _ := am.LoadById("UI-Group:2101"); attrs != nil {
+		attrs.Clear()
+		attrs.IsSaved = true
+	}
+	// $m
+	if attrs, _ := am.LoadById("UI:1101"); attrs != nil {
+		attrs.Clear()
+		attrs.IsSaved = true
+	}
+	// 群内临时人物卡
+	if attrs, _ := am.LoadById("UI-Group:2101-UI:1101"); attrs != nil {
+		attrs.Clear()
+		attrs.IsSaved = true
+	}
+
+	// $t
+	ctx.Player.ValueMapTemp = &ds.ValueMap{}
+}
+
+// v1MigrateVerify 在相同的随机种子下执行原文(V1)和改写结果(V2)，比较输出
+func (d *Dice) v1MigrateVerify(item *V1MigrateItem) {
+	seed := []byte("1234567890ABCDEF")
+	newCtx := func() *MsgContext {
+		ctx := CreateTempCtx(d.UIEndpoint, _MsgCreate("group", ""))
+		setupCompatibleTestAttrs(ctx)
+		ctx.CreateVmIfNotExists()
+		ctx.vm.Seed = seed
+		ctx.vm.Init()
+		ctx.SetSplitKey("###SPLIT-KEY###")
+		ctx._v1Rand = ctx.vm.RandSrc
+		return ctx
+	}
+
+	var err1, err2 error
+	ctx := newCtx()
+	if item.textMode {
+		item.OutputV1, err1 = DiceFormatV1(ctx, item.Source)
+	} else {
+		var r *VMResult
+		if r, _, err1 = d._ExprEvalBaseV1(item.Source, ctx, RollExtraFlags{}); err1 == nil {
+			item.OutputV1 = r.ToString()
+		}
+	}
+	setupCompatibleTestAttrs(ctx)
+
+	ctx = newCtx()
+	if item.textMode {
+		item.OutputV2, err2 = DiceFormatV2(ctx, item.Target)
+	} else {
+		var r *VMResultV2m
+		if r, _, err2 = DiceExprEvalBase(ctx, item.Target, RollExtraFlags{V2Only: true}); err2 == nil {
+			item.OutputV2 = r.ToString()
+		}
+	}
+	setupCompatibleTestAttrs(ctx)
+
+	if err1 != nil {
+		item.OutputV1 = "执行出错: " + err1.Error()
+	}
+	if err2 != nil {
+		item.OutputV2 = "执行出错: " + err2.Error()
+	}
+	item.Same = item.OutputV1 == item.OutputV2
+	switch {
+	case err2 != nil && err1 == nil:
+		item.Notes = append(item.Notes, V1MigrateNote{Level: "warn", Text: "改写后V2执行出错"})
+	case !item.Same && err1 == nil:
+		item.Notes = append(item.Notes, V1MigrateNote{Level: "warn", Text: "相同随机种子下两版输出不一致(含骰点时可能只是取随机数的方式不同)"})
+	}
+}
+
+// v1MigrateCheck 检查一处表达式，需要关注时加入报告
+func (d *Dice) v1MigrateCheck(f *V1MigrateFile, location, source string, textMode bool, apply func(string)) {
+	if strings.TrimSpace(source) == "" {
+		return
+	}
+	f.Total++
+	item := &V1MigrateItem{Location: location, Source: source, textMode: textMode, apply: apply}
+	target, notes, err := V1ExprToV2(source, textMode)
+	item.Target, item.Notes = target, notes
+	if err == nil {
+		d.v1MigrateVerify(item)
+	}
+	if !item.Changed() && len(item.Notes) == 0 {
+		return
+	}
+	if item.Changed() {
+		f.Changed++
+	}
+	for _, n := range item.Notes {
+		if n.Level != "rewrite" {
+			f.Warnings++
+			break
+		}
+	}
+	f.Items = append(f.Items, item)
+}
+
+// checkVMVersion 记录该类内容当前的引擎版本，仍为v1时写回的V2语法会被v1执行，因此禁止应用
+func (f *V1MigrateFile) checkVMVersion(d *Dice) {
+	f.VMVersion = d.getTargetVmEngineVersion(f.Kind)
+	if f.VMVersion == "v1" && f.ReadOnly == "" {
+		f.ReadOnly = "当前仍使用v1引擎执行，需先在设置中切换为v2再应用改写"
+	}
+}
+
+func (f *V1MigrateFile) buildDiff() {
+	var b strings.Builder
+	for _, item := range f.Items {
+		if !item.Changed() {
+			continue
+		}
+		fmt.Fprintf(&b, "@@ %s\n- %s\n+ %s\n", item.Location,
+			strings.ReplaceAll(item.Source, "\n", "\n- "), strings.ReplaceAll(item.Target, "\n", "\n+ "))
+	}
+	f.Diff = b.String()
+	f.CheckedAt = time.Now().Unix()
+}
+
+// V1MigrateAnalyzeReply 分析一个自定义回复文件
+func (d *Dice) V1MigrateAnalyzeReply(rc *ReplyConfig) *V1MigrateFile {
+	f := &V1MigrateFile{Kind: V1MigrateKindReply, Filename: rc.Filename, Name: rc.Name}
+	if rc.PackageID != "" {
+		f.ReadOnly = "来自扩展包，需在扩展包中修改"
+	}
+	checkConds := func(prefix string, conds ReplyConditions) {
+		for idx, cond := range conds {
+			if c, ok := cond.(*ReplyConditionExprTrue); ok {
+				d.v1MigrateCheck(f, fmt.Sprintf("%s条件%d", prefix, idx+1), c.Value, false, func(s string) { c.Value = s })
+			}
+		}
+	}
+	checkList := func(prefix string, list TextTemplateItemList) {
+		for idx, t := range list {
+			if len(t) == 0 {
+				continue
+			}
+			if s, ok := t[0].(string); ok {
+				d.v1MigrateCheck(f, fmt.Sprintf("%s第%d项", prefix, idx+1), s, true, func(s string) { t[0] = s })
+			}
+		}
+	}
+
+	checkConds("文件", rc.Conditions)
+	for i, item := range rc.Items {
+		prefix := fmt.Sprintf("第%d条 ", i+1)
+		checkConds(prefix, item.Conditions)
+		for j, result := range item.Results {
+			p := fmt.Sprintf("%s结果%d ", prefix, j+1)
+			switch r := result.(type) {
+			case *ReplyResultReplyToSender:
+				checkList(p, r.Message)
+			case *ReplyResultReplyPrivate:
+				checkList(p, r.Message)
+			case *ReplyResultReplyGroup:
+				if r.Message != nil {
+					checkList(p, *r.Message)
+				}
+			}
+		}
+	}
+	f.checkVMVersion(d)
+	f.buildDiff()
+	return f
+}
+
+var (
+	reV1MigrateDeckExec = regexp.MustCompile(`\[.+?]`)
+	reV1MigrateDeckRef  = regexp.MustCompile(`{[$%].+?}`)
+)
+
+// v1MigrateDeckSpans 找出牌堆文本中会被当作表达式执行的 [...] 片段，规则同 deckStringFormat，
+// 返回各片段内容(不含方括号)的起止下标，嵌套在前一片段内的不再单独列出
+func v1MigrateDeckSpans(s string) [][2]int {
+	var ret [][2]int
+	for _, i := range reV1MigrateDeckExec.FindAllStringIndex(s, -1) {
+		if len(ret) > 0 && i[0] < ret[len(ret)-1][1] {
+			continue
+		}
+		text := s[i[0]:i[1]]
+		if text == "[name]" || strings.HasPrefix(text, "[CQ:") ||
+			strings.HasPrefix(text, "[图:") || strings.HasPrefix(text, "[img:") ||
+			strings.HasPrefix(text, "[文本:") || strings.HasPrefix(text, "[语音:") {
+			continue
+		}
+		if content, _ := extractExecuteContent(s[i[0]:]); content != "" {
+			ret = append(ret, [2]int{i[0] + 1, i[0] + 1 + len(content)})
+		}
+	}
+	return ret
+}
+
+// v1MigrateDeckSegments 牌堆文本中会被当作表达式执行的 [...] 片段内容
+func v1MigrateDeckSegments(s string) []string {
+	spans := v1MigrateDeckSpans(s)
+	ret := make([]string, 0, len(spans))
+	for _, span := range spans {
+		ret = append(ret, s[span[0]:span[1]])
+	}
+	return ret
+}
+
+// v1MigrateDeckRewrite 只替换 s 中表达式片段的内容，片段外的相同文本不受影响。
+// targets 为 原片段内容 -> 改写后内容，applied 记录用到的原片段
+func v1MigrateDeckRewrite(s string, targets map[string]string, applied map[string]bool) string {
+	spans := v1MigrateDeckSpans(s)
+	for idx := len(spans) - 1; idx >= 0; idx-- {
+		span := spans[idx]
+		seg := s[span[0]:span[1]]
+		if target, ok := targets[seg]; ok {
+			s = s[:span[0]] + target + s[span[1]:]
+			applied[seg] = true
+		}
+	}
+	return s
+}
+
+// V1MigrateAnalyzeDeck 分析一个牌堆文件中的 [...] 表达式。
+// {$...}、{%...} 只用于引用本文件的牌组，其中的内容不会作为变量或表达式执行，引用不存在的牌组时给出提示
+func (d *Dice) V1MigrateAnalyzeDeck(deck *DeckInfo) *V1MigrateFile {
+	f := &V1MigrateFile{Kind: V1MigrateKindDeck, Filename: deck.Filename, Name: deck.Name}
+	switch {
+	case deck.PackageID != "":
+		f.ReadOnly = "来自扩展包，需在扩展包中修改"
+	case deck.Cloud:
+		f.ReadOnly = "含有云端内容，需修改原始牌堆"
+	}
+
+	names := make([]string, 0, len(deck.DeckItems))
+	for name := range deck.DeckItems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	seen := map[string]bool{}
+	for _, name := range names {
+		for _, s := range deck.DeckItems[name] {
+			for _, ref := range reV1MigrateDeckRef.FindAllString(s, -1) {
+				if seen[ref] || deck.DeckItems[ref[2:len(ref)-1]] != nil {
+					continue
+				}
+				seen[ref] = true
+				f.Total++
+				f.Warnings++
+				f.Items = append(f.Items, &V1MigrateItem{Location: "牌组 " + name, Source: ref, Target: ref, Same: true, Notes: []V1MigrateNote{{
+					Level: "warn",
+					Text:  "{$...}只用于引用本文件的牌组，找不到该牌组时会输出“未知牌组”，其中的变量或表达式不会执行，需要时请改用[...]",
+				}}})
+			}
+			for _, seg := range v1MigrateDeckSegments(s) {
+				if seen[seg] {
+					continue
+				}
+				seen[seg] = true
+				// 执行时 [...] 会变成 {...} 交给文本格式化
+				before := len(f.Items)
+				d.v1MigrateCheck(f, "牌组 "+name, "{"+seg+"}", true, nil)
+				if len(f.Items) > before {
+					item := f.Items[before]
+					item.Source = "[" + strings.TrimSuffix(strings.TrimPrefix(item.Source, "{"), "}") + "]"
+					item.Target = "[" + strings.TrimSuffix(strings.TrimPrefix(item.Target, "{"), "}") + "]"
+				}
+			}
+		}
+	}
+	f.checkVMVersion(d)
+	f.buildDiff()
+	return f
+}
+
+// V1MigrateAnalyzeCustomText 分析一个分类下的自定义文案，文件名即分类名
+func (d *Dice) V1MigrateAnalyzeCustomText(category string) *V1MigrateFile {
+	f := &V1MigrateFile{Kind: V1MigrateKindCustomText, Filename: category, Name: category}
+	texts := d.TextMapRaw[category]
+	keys := make([]string, 0, len(texts))
+	for k := range texts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for idx, t := range texts[k] {
+			if len(t) == 0 {
+				continue
+			}
+			if s, ok := t[0].(string); ok {
+				d.v1MigrateCheck(f, fmt.Sprintf("%s 第%d项", k, idx+1), s, true, func(s string) { t[0] = s })
+			}
+		}
+	}
+	f.checkVMVersion(d)
+	f.buildDiff()
+	return f
+}
+
+// V1MigrateAnalyze 分析某一类(为空时为全部)文件，只返回有需要关注内容的文件
+func (d *Dice) V1MigrateAnalyze(kind string) []*V1MigrateFile {
+	var ret []*V1MigrateFile
+	add := func(f *V1MigrateFile) {
+		if len(f.Items) > 0 {
+			ret = append(ret, f)
+		}
+	}
+	if kind == "" || kind == V1MigrateKindReply {
+		for _, rc := range d.CustomReplyConfig {
+			add(d.V1MigrateAnalyzeReply(rc))
+		}
+	}
+	if kind == "" || kind == V1MigrateKindDeck {
+		for _, deck := range d.DeckList {
+			add(d.V1MigrateAnalyzeDeck(deck))
+		}
+	}
+	if kind == "" || kind == V1MigrateKindCustomText {
+		categories := make([]string, 0, len(d.TextMapRaw))
+		for category := range d.TextMapRaw {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			add(d.V1MigrateAnalyzeCustomText(category))
+		}
+	}
+	return ret
+}
+
+// V1MigrateApply 将一个文件中可自动改写的表达式写回，返回改写数量
+// 牌堆只改写文件中 [...] 片段的内容，未能在文件中找到原文的片段会跳过，数量在第二个返回值中
+func (d *Dice) V1MigrateApply(kind string, filename string) (int, int, error) {
+	switch kind {
+	case V1MigrateKindReply:
+		for _, rc := range d.CustomReplyConfig {
+			if rc.Filename != filename {
+				continue
+			}
+			f := d.V1MigrateAnalyzeReply(rc)
+			if f.ReadOnly != "" {
+				return 0, 0, errors.New(f.ReadOnly)
+			}
+			n := 0
+			for _, item := range f.Items {
+				if item.Changed() {
+					item.apply(item.Target)
+					n++
+				}
+			}
+			if n > 0 {
+				rc.UpdateTimestamp = time.Now().Unix()
+				rc.Save(d)
+			}
+			return n, 0, nil
+		}
+	case V1MigrateKindDeck:
+		for _, deck := range d.DeckList {
+			if deck.Filename != filename {
+				continue
+			}
+			f := d.V1MigrateAnalyzeDeck(deck)
+			if f.ReadOnly != "" {
+				return 0, 0, errors.New(f.ReadOnly)
+			}
+			return d.v1MigrateApplyDeck(deck, f)
+		}
+	case V1MigrateKindCustomText:
+		if _, ok := d.TextMapRaw[filename]; !ok {
+			break
+		}
+		f := d.V1MigrateAnalyzeCustomText(filename)
+		if f.ReadOnly != "" {
+			return 0, 0, errors.New(f.ReadOnly)
+		}
+		n := 0
+		for _, item := range f.Items {
+			if item.Changed() {
+				item.apply(item.Target)
+				n++
+			}
+		}
+		if n > 0 {
+			SetupTextHelpInfo(d, d.TextMapHelpInfo, d.TextMapRaw, "configs/text-template.yaml")
+			d.GenerateTextMap()
+			d.SaveText()
+			for k, v := range d.TextMapRaw[filename] {
+				TextMapCompatibleCheck(d, filename, k, v)
+			}
+		}
+		return n, 0, nil
+	default:
+		return 0, 0, errors.New("未知的类型: " + kind)
+	}
+	return 0, 0, errors.New("找不到文件: " + filename)
+}
+
+func (d *Dice) v1MigrateApplyDeck(deck *DeckInfo, f *V1MigrateFile) (int, int, error) {
+	if strings.EqualFold(filepath.Ext(deck.Filename), ".zip") {
+		return 0, 0, errors.New("压缩包牌堆不能直接修改")
+	}
+	content, err := os.ReadFile(deck.Filename)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	targets := map[string]string{}
+	for _, item := range f.Items {
+		if item.Changed() {
+			targets[item.Source[1:len(item.Source)-1]] = item.Target[1 : len(item.Target)-1]
+		}
+	}
+	if len(targets) == 0 {
+		return 0, 0, nil
+	}
+	applied := map[string]bool{}
+	var text string
+	if strings.HasPrefix(strings.ToLower(filepath.Ext(deck.Filename)), ".json") {
+		text = v1MigrateRewriteJSONStrings(string(content), func(s string) string {
+			return v1MigrateDeckRewrite(s, targets, applied)
+		})
+	} else {
+		// yaml/toml 中带转义的字符串找不到原文，会计入跳过的数量
+		text = v1MigrateDeckRewrite(string(content), targets, applied)
+	}
+
+	n := len(applied)
+	if n > 0 {
+		if err = os.WriteFile(deck.Filename, []byte(text), 0o644); err != nil { //nolint:gosec
+			return 0, 0, err
+		}
+		DeckReload(d)
+	}
+	return n, len(targets) - n, nil
+}
+
+// v1MigrateRewriteJSONStrings 对 JSON 文本中的每个字符串值(不含键)解码后调用 rewrite，有变化的重新编码写回，其余内容保持原样
+func v1MigrateRewriteJSONStrings(text string, rewrite func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '"' {
+			continue
+		}
+		j := i + 1
+		for j < len(text) && text[j] != '"' {
+			if text[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(text) {
+			break
+		}
+		literal := text[i : j+1]
+		isKey := strings.HasPrefix(strings.TrimLeft(text[j+1:], " \t\r\n"), ":")
+		var value string
+		if !isKey && json.Unmarshal([]byte(literal), &value) == nil {
+			if rewritten := rewrite(value); rewritten != value {
+				var buf bytes.Buffer
+				enc := json.NewEncoder(&buf)
+				// 保留 & < > 原样，否则 && 等会被转义成 \u0026
+				enc.SetEscapeHTML(false)
+				if enc.Encode(rewritten) == nil {
+					b.WriteString(text[last:i])
+					b.WriteString(strings.TrimSuffix(buf.String(), "\n"))
+					last = j + 1
+				}
+			}
+		}
+		i = j
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,162 @@
+//nolint:testpackage
+package dice
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sealdice-core/model"
+)
+
+func TestV1ExprToV2(t *testing.T) {
+	cases := []struct {
+		src      string
+		textMode bool
+		want     string
+		level    string
+	}{
+		{"1d20", false, "1d20", ""},
+		{"1 || 2", false, "1 | 2", "rewrite"},
+		{"$t1 && 6 // 注释", false, "$t1 & 6", "rewrite"},
+		{"int(3) + str(4)", false, "toInt(3) + toStr(4)", "rewrite"},
+		{"`a{{1+1}}b{2}`", false, "`a{1+1}b{2}`", "rewrite"},
+		{"结果:{1＞0 ? 'x' : 'y'} 网址 http://a.b", true, "结果:{1>0 ? 'x' : 'y'} 网址 http://a.b", "rewrite"},
+		{"{this.x}", true, "{this.x}", "warn"},
+	}
+	for _, c := range cases {
+		got, notes, err := V1ExprToV2(c.src, c.textMode)
+		if err != nil {
+			t.Fatalf("V1ExprToV2(%q) error = %v", c.src, err)
+		}
+		if got != c.want {
+			t.Errorf("V1ExprToV2(%q) = %q, want %q", c.src, got, c.want)
+		}
+		if c.level == "" && len(notes) > 0 || c.level != "" && (len(notes) == 0 || notes[0].Level != c.level) {
+			t.Errorf("V1ExprToV2(%q) notes = %v, want level %q", c.src, notes, c.level)
+		}
+	}
+}
+
+// newV1MigrateTestDice 对比两版输出需要 UI 端点来创建临时上下文
+func newV1MigrateTestDice(t *testing.T) (*Dice, func()) {
+	t.Helper()
+	d, _, _, cleanup := newExecuteNewTestDice(t)
+	migrateTestTables(t, d, &model.AttributesItemModel{})
+	d.UIEndpoint = &EndPointInfo{
+		EndPointInfoBase: EndPointInfoBase{ID: "1", UserID: "UI:1000", Platform: "UI", Enable: true, State: StateConnected},
+		Adapter:          &PlatformAdapterHTTP{},
+	}
+	d.UIEndpoint.BindRuntime(d.ImSession)
+	return d, cleanup
+}
+
+func TestV1MigrateReplyApply(t *testing.T) {
+	d, cleanup := newV1MigrateTestDice(t)
+	defer cleanup()
+
+	cond := &ReplyConditionExprTrue{CondType: "exprTrue", Value: "3 || 4"}
+	result := &ReplyResultReplyToSender{ResultType: "replyToSender", Message: TextTemplateItemList{
+		{"值为{3 && 6}", 1},
+		{"不需要修改{1+1}", 1},
+	}}
+	rc := &ReplyConfig{Enable: true, Filename: "test.yaml", Items: []*ReplyItem{
+		{Enable: true, Conditions: ReplyConditions{cond}, Results: []ReplyResultBase{result}},
+	}}
+	d.CustomReplyConfig = []*ReplyConfig{rc}
+
+	files := d.V1MigrateAnalyze(V1MigrateKindReply)
+	if len(files) != 1 || files[0].Changed != 2 || files[0].Total != 3 {
+		t.Fatalf("V1MigrateAnalyze() = %+v", files)
+	}
+	for _, item := range files[0].Items {
+		if !item.Same {
+			t.Errorf("%s: 输出不一致 v1=%q v2=%q", item.Location, item.OutputV1, item.OutputV2)
+		}
+	}
+	if !strings.Contains(files[0].Diff, "- 3 || 4\n+ 3 | 4") {
+		t.Fatalf("diff = %q", files[0].Diff)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.GetExtConfigFilePath("reply", rc.Filename)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// 自定义回复默认仍使用v1执行，需切换到v2后才能写回
+	if files[0].VMVersion != "v1" || files[0].ReadOnly == "" {
+		t.Fatalf("v1 report = %+v", files[0])
+	}
+	if _, _, err := d.V1MigrateApply(V1MigrateKindReply, "test.yaml"); err == nil || cond.Value != "3 || 4" {
+		t.Fatalf("V1MigrateApply() on v1 err = %v, cond=%q", err, cond.Value)
+	}
+	d.Config.VMVersionForReply = "v2"
+	applied, _, err := d.V1MigrateApply(V1MigrateKindReply, "test.yaml")
+	if err != nil || applied != 2 {
+		t.Fatalf("V1MigrateApply() = %d, %v", applied, err)
+	}
+	if cond.Value != "3 | 4" || result.Message[0][0] != "值为{3 & 6}" || result.Message[1][0] != "不需要修改{1+1}" {
+		t.Fatalf("after apply: cond=%q msg=%v", cond.Value, result.Message)
+	}
+	if _, err = os.Stat(d.GetExtConfigFilePath("reply", rc.Filename)); err != nil {
+		t.Fatalf("file not saved: %v", err)
+	}
+	if files = d.V1MigrateAnalyze(V1MigrateKindReply); len(files) != 0 {
+		t.Fatalf("再次分析应无需修改, got %+v", files[0].Items)
+	}
+}
+
+func TestV1MigrateDeckApply(t *testing.T) {
+	d, cleanup := newV1MigrateTestDice(t)
+	defer cleanup()
+	// 写回后会从 data/decks 重新加载牌堆
+	t.Chdir(d.BaseConfig.DataDir)
+	if err := os.MkdirAll(filepath.Join("data", "decks"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	fn := filepath.Join(t.TempDir(), "deck.json")
+	content := `{
+  "骰子": ["结果[1 && 2]", "表达式外的 1 && 2 不改", "{$不存在}", "{$其他}"],
+  "其他": ["[1 && 2]<b>"]
+}`
+	if err := os.WriteFile(fn, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deck := &DeckInfo{Enable: true, Filename: fn, Name: "测试牌堆", DeckItems: map[string][]string{
+		"骰子": {"结果[1 && 2]", "表达式外的 1 && 2 不改", "{$不存在}", "{$其他}"},
+		"其他": {"[1 && 2]<b>"},
+	}}
+	d.DeckList = []*DeckInfo{deck}
+
+	files := d.V1MigrateAnalyze(V1MigrateKindDeck)
+	if len(files) != 1 || files[0].Changed != 1 || files[0].Warnings != 1 {
+		t.Fatalf("V1MigrateAnalyze() = %+v", files)
+	}
+	if !strings.Contains(files[0].Diff, "- [1 && 2]\n+ [1 & 2]") {
+		t.Fatalf("diff = %q", files[0].Diff)
+	}
+	// 引用不存在的牌组给出提示
+	var refNote bool
+	for _, item := range files[0].Items {
+		refNote = refNote || item.Source == "{$不存在}" && !item.Changed() && len(item.Notes) > 0
+	}
+	if !refNote {
+		t.Fatalf("{$不存在} 没有提示: %+v", files[0].Items)
+	}
+
+	applied, skipped, err := d.V1MigrateApply(V1MigrateKindDeck, fn)
+	if err != nil || applied != 1 || skipped != 0 {
+		t.Fatalf("V1MigrateApply() = %d, %d, %v", applied, skipped, err)
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "骰子": ["结果[1 & 2]", "表达式外的 1 && 2 不改", "{$不存在}", "{$其他}"],
+  "其他": ["[1 & 2]<b>"]
+}`
+	if string(data) != want {
+		t.Fatalf("after apply:\n%s", data)
+	}
+}
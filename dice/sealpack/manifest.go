@@ -119,6 +119,7 @@ func validateManifestKnownKeys(data []byte) (*manifestParseMeta, error) {
 		"reply":     {},
 		"helpdoc":   {},
 		"templates": {},
+		"tables":    {},
 	}
 	var unknown []string
 	for key := range contents {
@@ -255,6 +256,7 @@ func validateContents(contents Contents) error {
 		"reply":     contents.Reply,
 		"helpdoc":   contents.Helpdoc,
 		"templates": contents.Templates,
+		"tables":    contents.Tables,
 	}
 
 	for dir, patterns := range checks {
@@ -0,0 +1,279 @@
+package sealpack
+
+import (
+	"archive/zip"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	sealcrypto "sealdice-core/utils/crypto"
+)
+
+// SignatureFormat 当前的签名格式
+const SignatureFormat = "sealpack-signature/1"
+
+// Signature 内嵌于扩展包根目录 signature.json 的签名。
+// 签名覆盖包 ID、版本、签名者信息以及除 signature.json 外全部文件的 sha256，
+// 算法与官方 Mod 签名相同(RSA-PSS + SHA256)。
+type Signature struct {
+	Format    string            `json:"format"`
+	PackageID string            `json:"packageId"`
+	Version   string            `json:"version"`
+	Signer    string            `json:"signer"`    // 签名者自称的名字，仅供展示
+	KeyID     string            `json:"keyId"`     // 公钥指纹
+	PublicKey string            `json:"publicKey"` // PEM 格式公钥，未受信任时也能展示指纹
+	SignedAt  int64             `json:"signedAt"`
+	Files     map[string]string `json:"files"` // 包内路径 -> sha256
+	Sig       string            `json:"signature,omitempty"`
+}
+
+// payload 被签名的内容，即去掉 signature 字段后的 JSON。map 的键按字典序输出，结果是确定的
+func (s *Signature) payload() ([]byte, error) {
+	c := *s
+	c.Sig = ""
+	return json.Marshal(&c)
+}
+
+// Verify 用给定公钥验证签名，公钥指纹须与 KeyID 一致
+func (s *Signature) Verify(publicKeyPEM string) error {
+	keyID, err := KeyFingerprint(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+	if keyID != s.KeyID {
+		return errors.New("签名公钥与 keyId 不符")
+	}
+	data, err := s.payload()
+	if err != nil {
+		return err
+	}
+	if err = sealcrypto.RSAVerify256(data, s.Sig, publicKeyPEM); err != nil {
+		return fmt.Errorf("签名无效: %w", err)
+	}
+	return nil
+}
+
+// KeyFingerprint 计算 PEM 公钥的指纹(DER 编码的 sha256)，只支持 RSA 公钥
+func KeyFingerprint(publicKeyPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return "", errors.New("公钥不是 PEM 格式")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("无法解析公钥: %w", err)
+	}
+	if _, ok := key.(*rsa.PublicKey); !ok {
+		return "", errors.New("只支持 RSA 公钥")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func publicKeyFromPrivate(privateKeyPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return "", errors.New("私钥不是 PEM 格式")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("无法解析私钥，需要 PKCS#8 格式: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("只支持 RSA 私钥")
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// archiveDigests 计算包内除签名文件外全部文件的 sha256
+func archiveDigests(files []*zip.File) (map[string]string, error) {
+	digests := map[string]string{}
+	for _, file := range files {
+		normalized, isDir, err := normalizeArchiveEntryName(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		if normalized == "" || isDir || normalized == SignatureFile {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		if closeErr := rc.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		digests[normalized] = hex.EncodeToString(h.Sum(nil))
+	}
+	return digests, nil
+}
+
+// ReadArchiveSignature 读取扩展包内嵌的签名并核对包内容，未签名时返回 nil, nil。
+// 返回的签名已确认与包内文件一致，但尚未验证签名本身，需要调用方用受信任的公钥 Verify。
+func ReadArchiveSignature(pkgPath string) (*Signature, error) {
+	archiveInfo, err := InspectArchive(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.OpenReader(pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extension package: %w", err)
+	}
+	defer reader.Close()
+
+	var sigFile *zip.File
+	for _, file := range reader.File {
+		if normalized, _, _ := normalizeArchiveEntryName(file.Name); normalized == SignatureFile {
+			sigFile = file
+			break
+		}
+	}
+	if sigFile == nil {
+		return nil, nil
+	}
+	rc, err := sigFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, 4<<20))
+	_ = rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	sig := &Signature{}
+	if err = json.Unmarshal(data, sig); err != nil {
+		return nil, fmt.Errorf("%s 格式错误: %w", SignatureFile, err)
+	}
+	if sig.Format != SignatureFormat {
+		return sig, fmt.Errorf("不支持的签名格式: %s", sig.Format)
+	}
+	manifest := archiveInfo.Manifest
+	if sig.PackageID != manifest.Package.ID || sig.Version != manifest.Package.Version {
+		return sig, fmt.Errorf("签名对应的是 %s@%s", sig.PackageID, sig.Version)
+	}
+	if keyID, keyErr := KeyFingerprint(sig.PublicKey); keyErr != nil || keyID != sig.KeyID {
+		return sig, errors.New("签名中的公钥与 keyId 不符")
+	}
+
+	digests, err := archiveDigests(reader.File)
+	if err != nil {
+		return nil, err
+	}
+	for name, digest := range digests {
+		signed, ok := sig.Files[name]
+		if !ok {
+			return sig, fmt.Errorf("文件 %s 未被签名", name)
+		}
+		if signed != digest {
+			return sig, fmt.Errorf("文件 %s 在签名后被修改", name)
+		}
+	}
+	for name := range sig.Files {
+		if _, ok := digests[name]; !ok {
+			return sig, fmt.Errorf("已签名的文件 %s 不存在", name)
+		}
+	}
+	return sig, nil
+}
+
+// SignArchive 为扩展包签名，把 signature.json 写入包内(已有签名会被替换)后保存到 dstPath，dstPath 可以与 srcPath 相同。
+// 私钥须为 PKCS#8 PEM 格式的 RSA 私钥，例如 openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:3072 生成的私钥。
+func SignArchive(srcPath, dstPath, signer, privateKeyPEM string) (*Signature, error) {
+	publicKey, err := publicKeyFromPrivate(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := KeyFingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	archiveInfo, err := InspectArchive(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.OpenReader(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extension package: %w", err)
+	}
+	defer reader.Close()
+	digests, err := archiveDigests(reader.File)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &Signature{
+		Format:    SignatureFormat,
+		PackageID: archiveInfo.Manifest.Package.ID,
+		Version:   archiveInfo.Manifest.Package.Version,
+		Signer:    signer,
+		KeyID:     keyID,
+		PublicKey: publicKey,
+		SignedAt:  time.Now().Unix(),
+		Files:     digests,
+	}
+	payload, err := sig.payload()
+	if err != nil {
+		return nil, err
+	}
+	if sig.Sig, err = sealcrypto.RSASign256(payload, privateKeyPEM); err != nil {
+		return nil, err
+	}
+	sigData, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), ".signing-*"+Extension)
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	zw := zip.NewWriter(tmp)
+	for _, file := range reader.File {
+		if normalized, _, _ := normalizeArchiveEntryName(file.Name); normalized == SignatureFile {
+			continue
+		}
+		if err = zw.Copy(file); err != nil {
+			_ = tmp.Close()
+			return nil, err
+		}
+	}
+	w, err := zw.Create(SignatureFile)
+	if err == nil {
+		_, err = w.Write(sigData)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = reader.Close()
+	if err = os.Rename(tmpPath, dstPath); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
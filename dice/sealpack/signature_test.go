@@ -0,0 +1,130 @@
+package sealpack //nolint:testpackage
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func generateSigningKeyForTest(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	publicKey, err := publicKeyFromPrivate(privateKey)
+	if err != nil {
+		t.Fatalf("publicKeyFromPrivate() error = %v", err)
+	}
+	return privateKey, publicKey
+}
+
+// rewriteArchiveForTest 原样复制扩展包，只替换指定文件的内容
+func rewriteArchiveForTest(t *testing.T, pkgPath string, replace map[string]string) {
+	t.Helper()
+	reader, err := zip.OpenReader(pkgPath)
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range reader.File {
+		rc, _ := file.Open()
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[file.Name] = string(data)
+	}
+	_ = reader.Close()
+	for name, body := range replace {
+		files[name] = body
+	}
+	rewritten := createArchiveForTest(t, files)
+	data, _ := os.ReadFile(rewritten)
+	if err = os.WriteFile(pkgPath, data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func TestSignArchiveRoundTrip(t *testing.T) {
+	privateKey, publicKey := generateSigningKeyForTest(t)
+	archivePath := createArchiveForTest(t, map[string]string{
+		"info.toml":       minimalManifestForArchiveTest("alice/demo", "1.0.0"),
+		"scripts/main.js": "console.log('x')",
+	})
+
+	if sig, err := ReadArchiveSignature(archivePath); sig != nil || err != nil {
+		t.Fatalf("ReadArchiveSignature(unsigned) = %v, %v", sig, err)
+	}
+	if _, err := SignArchive(archivePath, archivePath, "Alice", privateKey); err != nil {
+		t.Fatalf("SignArchive() error = %v", err)
+	}
+	// 重复签名时替换旧的 signature.json
+	signed, err := SignArchive(archivePath, archivePath, "Alice", privateKey)
+	if err != nil {
+		t.Fatalf("SignArchive() again error = %v", err)
+	}
+	if len(signed.Files) != 2 || signed.Files[SignatureFile] != "" {
+		t.Fatalf("signed files = %v", signed.Files)
+	}
+
+	sig, err := ReadArchiveSignature(archivePath)
+	if err != nil {
+		t.Fatalf("ReadArchiveSignature() error = %v", err)
+	}
+	if sig.Signer != "Alice" || sig.PackageID != "alice/demo" {
+		t.Fatalf("signature = %#v", sig)
+	}
+	if err = sig.Verify(publicKey); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	_, otherKey := generateSigningKeyForTest(t)
+	if err = sig.Verify(otherKey); err == nil {
+		t.Fatal("Verify() with another key error = nil")
+	}
+
+	forged := *sig
+	forged.Signer = "Mallory"
+	if err = forged.Verify(publicKey); err == nil {
+		t.Fatal("Verify() after changing signer error = nil")
+	}
+}
+
+func TestReadArchiveSignatureDetectsTampering(t *testing.T) {
+	privateKey, _ := generateSigningKeyForTest(t)
+	cases := map[string]map[string]string{
+		"modified": {"scripts/main.js": "console.log('evil')"},
+		"added":    {"scripts/extra.js": "console.log('evil')"},
+		"manifest": {"info.toml": minimalManifestForArchiveTest("alice/demo", "1.0.1")},
+	}
+	for name, replace := range cases {
+		archivePath := createArchiveForTest(t, map[string]string{
+			"info.toml":       minimalManifestForArchiveTest("alice/demo", "1.0.0"),
+			"scripts/main.js": "console.log('x')",
+		})
+		if _, err := SignArchive(archivePath, archivePath, "Alice", privateKey); err != nil {
+			t.Fatalf("SignArchive() error = %v", err)
+		}
+		rewriteArchiveForTest(t, archivePath, replace)
+		if _, err := ReadArchiveSignature(archivePath); err == nil {
+			t.Errorf("%s: ReadArchiveSignature() error = nil, want tampering rejection", name)
+		}
+	}
+}
+
+func TestKeyFingerprintRejectsInvalidKeys(t *testing.T) {
+	for _, key := range []string{"", "not a key", strings.Repeat("-", 10)} {
+		if _, err := KeyFingerprint(key); err == nil {
+			t.Errorf("KeyFingerprint(%q) error = nil", key)
+		}
+	}
+}
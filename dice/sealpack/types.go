@@ -88,6 +88,7 @@ type Contents struct {
 	Reply     []string `toml:"reply" json:"reply"`
 	Helpdoc   []string `toml:"helpdoc" json:"helpdoc"`
 	Templates []string `toml:"templates" json:"templates"`
+	Tables    []string `toml:"tables" json:"tables"`
 }
 
 // StoreInfo 商店展示资源信息
@@ -206,4 +207,7 @@ const (
 
 	// ManifestFile keeps the historical constant name mapped to info.toml.
 	ManifestFile = InfoFile
+
+	// SignatureFile 包内嵌签名的文件名，位于包根目录
+	SignatureFile = "signature.json"
 )
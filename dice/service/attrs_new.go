@@ -20,6 +20,7 @@ const (
 	AttrsTypeGroupUser = "group_user"
 	AttrsTypeGroup     = "group"
 	AttrsTypeUser      = "user"
+	AttrsTypeNpc       = "npc"
 )
 
 // 注: 角色表有用sheet也有用sheets的，这里数据结构中使用sheet
@@ -82,6 +83,44 @@ func AttrsGetIdByUidAndName(operator engine2.DatabaseOperator, userId string, na
 	return item.Id, nil
 }
 
+// AttrsGetNpcIdByName 获取群内指定名字的NPC卡ID，NPC卡的owner_id为群号
+func AttrsGetNpcIdByName(operator engine2.DatabaseOperator, groupId string, name string) (string, error) {
+	var item model.AttributesItemModel
+	db := operator.GetDataDB(constant.READ)
+	err := db.Model(&model.AttributesItemModel{}).
+		Select("id").
+		Where("attrs_type = ? AND owner_id = ? AND name = ?", AttrsTypeNpc, groupId, name).
+		Limit(1).
+		Find(&item).Error
+	if err != nil {
+		return "", err
+	}
+	return item.Id, nil
+}
+
+// AttrsGetNpcListByGroupId 获取群内的NPC卡列表，按创建时间排序
+func AttrsGetNpcListByGroupId(operator engine2.DatabaseOperator, groupId string) ([]*model.AttributesItemModel, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.AttributesItemModel
+	err := db.Model(&model.AttributesItemModel{}).
+		Select("id, name, sheet_type, owner_id, created_at, updated_at").
+		Where("attrs_type = ? AND owner_id = ?", AttrsTypeNpc, groupId).
+		Order("created_at ASC").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AttrsSetOwnerById 修改一条记录的owner_id
+func AttrsSetOwnerById(operator engine2.DatabaseOperator, id string, ownerId string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Model(&model.AttributesItemModel{}).
+		Where("id = ?", id).
+		Update("owner_id", ownerId).Error
+}
+
 func AttrsPutById(operator engine2.DatabaseOperator, id string, data []byte, name, sheetType string) error {
 	db := operator.GetDataDB(constant.WRITE)
 	now := time.Now().Unix() // 获取当前时间
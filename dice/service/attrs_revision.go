@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+var ErrAttrsRevisionNotFound = errors.New("修改记录不存在")
+
+// AttrsRevisionAppend 追加一条角色卡修改记录
+func AttrsRevisionAppend(operator engine2.DatabaseOperator, item *model.AttrsRevision) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Create(item).Error
+}
+
+// AttrsRevisionList 按时间倒序分页列出某张卡的修改记录，返回的记录不含 Data
+func AttrsRevisionList(operator engine2.DatabaseOperator, attrsID string, offset, limit int) (int64, []*model.AttrsRevision, error) {
+	db := operator.GetDataDB(constant.READ)
+	query := db.Model(&model.AttrsRevision{}).Where("attrs_id = ?", attrsID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+	var items []*model.AttrsRevision
+	err := query.
+		Omit("data").
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&items).Error
+	return total, items, err
+}
+
+// AttrsRevisionGet 获取一条修改记录（含 Data）
+func AttrsRevisionGet(operator engine2.DatabaseOperator, attrsID string, id uint64) (*model.AttrsRevision, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.AttrsRevision
+	if err := db.Where("attrs_id = ? AND id = ?", attrsID, id).Limit(1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrAttrsRevisionNotFound
+	}
+	return items[0], nil
+}
+
+// AttrsRevisionTrim 清理超出保留范围的记录：每张卡只保留最新的 keep 条（keep<=0 不限），
+// 并删除早于 before 的记录（before<=0 不限）
+func AttrsRevisionTrim(operator engine2.DatabaseOperator, attrsID string, keep int, before int64) error {
+	db := operator.GetDataDB(constant.WRITE)
+	if keep > 0 {
+		var ids []uint64
+		if err := db.Model(&model.AttrsRevision{}).
+			Where("attrs_id = ?", attrsID).
+			Order("id DESC").
+			Offset(keep).
+			Limit(1).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			if err := db.Where("attrs_id = ? AND id <= ?", attrsID, ids[0]).Delete(&model.AttrsRevision{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if before > 0 {
+		if err := db.Where("attrs_id = ? AND created_at < ?", attrsID, before).Delete(&model.AttrsRevision{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AttrsRevisionDeleteByAttrsID 删除某张卡的全部修改记录
+func AttrsRevisionDeleteByAttrsID(operator engine2.DatabaseOperator, attrsID string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Where("attrs_id = ?", attrsID).Delete(&model.AttrsRevision{}).Error
+}
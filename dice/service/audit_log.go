@@ -0,0 +1,83 @@
+package service
+
+import (
+	"gorm.io/gorm"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+// AuditAppend 追加一条审计记录。审计表只追加，不提供修改和删除
+func AuditAppend(operator engine2.DatabaseOperator, item *model.AuditLog) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Create(item).Error
+}
+
+// QueryAuditLog 是审计记录查询的参数
+type QueryAuditLog struct {
+	PageNum   int    `query:"pageNum"`   // 当前页码
+	PageSize  int    `query:"pageSize"`  // 每页条数
+	ActorID   string `query:"actorId"`   // 操作者
+	ActorType string `query:"actorType"` // im / webui
+	Action    string `query:"action"`    // 操作类型，以 . 结尾时按前缀匹配
+	Target    string `query:"target"`    // 操作对象
+	TimeStart int64  `query:"timeStart"` // 起始时间（含）
+	TimeEnd   int64  `query:"timeEnd"`   // 结束时间（含）
+}
+
+func auditQuery(operator engine2.DatabaseOperator, params QueryAuditLog) *gorm.DB {
+	db := operator.GetDataDB(constant.READ)
+	query := db.Model(&model.AuditLog{})
+	if params.ActorID != "" {
+		query = query.Where("actor_id = ?", params.ActorID)
+	}
+	if params.ActorType != "" {
+		query = query.Where("actor_type = ?", params.ActorType)
+	}
+	if params.Action != "" {
+		if params.Action[len(params.Action)-1] == '.' {
+			query = query.Where("action LIKE ?", params.Action+"%")
+		} else {
+			query = query.Where("action = ?", params.Action)
+		}
+	}
+	if params.Target != "" {
+		query = query.Where("target = ?", params.Target)
+	}
+	if params.TimeStart > 0 {
+		query = query.Where("created_at >= ?", params.TimeStart)
+	}
+	if params.TimeEnd > 0 {
+		query = query.Where("created_at <= ?", params.TimeEnd)
+	}
+	return query
+}
+
+// AuditGetLogPage 分页查询审计记录，按时间倒序
+func AuditGetLogPage(operator engine2.DatabaseOperator, params QueryAuditLog) (int64, []model.AuditLog, error) {
+	var total int64
+	var logs []model.AuditLog
+
+	query := auditQuery(operator, params)
+	if err := query.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+
+	if err := query.
+		Order("created_at DESC, id DESC").
+		Limit(params.PageSize).
+		Offset((params.PageNum - 1) * params.PageSize).
+		Find(&logs).
+		Error; err != nil {
+		return 0, nil, err
+	}
+	return total, logs, nil
+}
+
+// AuditExport 导出符合条件的全部审计记录（忽略分页参数），按时间正序
+func AuditExport(operator engine2.DatabaseOperator, params QueryAuditLog) ([]model.AuditLog, error) {
+	var logs []model.AuditLog
+	err := auditQuery(operator, params).Order("created_at ASC, id ASC").Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func TestAuditLogQueryAndExport(t *testing.T) {
+	db, err := openLogInfoTestDB(filepath.ToSlash(filepath.Join(t.TempDir(), "audit.db")))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	if err = db.AutoMigrate(&model.AuditLog{}); err != nil {
+		t.Fatalf("migrate audit table: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	operator := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+
+	items := []model.AuditLog{
+		{ActorType: "im", ActorID: "QQ:1", Action: "cmd.ban.add", Target: "QQ:9", CreatedAt: 100},
+		{ActorType: "im", ActorID: "QQ:1", Action: "cmd.master.add", Target: "QQ:2", CreatedAt: 200},
+		{ActorType: "webui", ActorID: "@admin", Action: "webui.config.set", Target: "dice.config", CreatedAt: 300},
+	}
+	for i := range items {
+		if err = service.AuditAppend(operator, &items[i]); err != nil {
+			t.Fatalf("AuditAppend() error = %v", err)
+		}
+	}
+
+	total, page, err := service.AuditGetLogPage(operator, service.QueryAuditLog{PageNum: 1, PageSize: 10, Action: "cmd."})
+	if err != nil {
+		t.Fatalf("AuditGetLogPage() error = %v", err)
+	}
+	if total != 2 || len(page) != 2 || page[0].Action != "cmd.master.add" {
+		t.Fatalf("AuditGetLogPage() = %d %+v, want 2 cmd items newest first", total, page)
+	}
+
+	logs, err := service.AuditExport(operator, service.QueryAuditLog{TimeStart: 150, ActorID: "@admin"})
+	if err != nil {
+		t.Fatalf("AuditExport() error = %v", err)
+	}
+	if len(logs) != 1 || logs[0].Target != "dice.config" {
+		t.Fatalf("AuditExport() = %+v, want only the webui item", logs)
+	}
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+// 战役
+//
+// 战役存放在 data 库，团次日志以 群号+日志名 指向 log 库中的日志，两者不在同一个库中，
+// 因此删除日志时不会联动删除团次日志记录，读取时找不到的日志直接跳过。
+
+var (
+	ErrCampaignNotFound      = errors.New("战役不存在")
+	ErrCampaignExists        = errors.New("战役名称已存在")
+	ErrCampaignGroupLinked   = errors.New("该群已关联其他战役")
+	ErrCampaignNoSession     = errors.New("战役还没有团次")
+	ErrCampaignNotMember     = errors.New("不是战役成员")
+	ErrCampaignSessionExists = errors.New("日志已在该团次中")
+)
+
+// CampaignDetail 战役的完整信息
+type CampaignDetail struct {
+	Campaign *model.Campaign          `json:"campaign"`
+	Members  []*model.CampaignMember  `json:"members"`
+	Groups   []string                 `json:"groups"`
+	Sessions []*CampaignSessionDetail `json:"sessions"`
+	Notes    []*model.CampaignNote    `json:"notes"`
+}
+
+// CampaignSessionDetail 团次及其日志，日志不存在时 Exists 为 false
+type CampaignSessionDetail struct {
+	*model.CampaignSession
+	Logs []*CampaignSessionLogInfo `json:"logs"`
+}
+
+type CampaignSessionLogInfo struct {
+	*model.CampaignSessionLog
+	LogID  uint64 `json:"logId"`
+	Size   int    `json:"size"`
+	Exists bool   `json:"exists"`
+}
+
+// CampaignCreate 新建战役，同时把主群关联到战役、把主持人加为成员
+func CampaignCreate(operator engine2.DatabaseOperator, c *model.Campaign) error {
+	db := operator.GetDataDB(constant.WRITE)
+	now := time.Now().Unix()
+	c.CreatedAt, c.UpdatedAt = now, now
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Campaign{}).Where("name = ?", c.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCampaignExists
+		}
+		if c.MainGroupID != "" {
+			if err := tx.Model(&model.CampaignGroup{}).Where("group_id = ?", c.MainGroupID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrCampaignGroupLinked
+			}
+		}
+		if err := tx.Create(c).Error; err != nil {
+			return err
+		}
+		if c.MainGroupID != "" {
+			if err := tx.Create(&model.CampaignGroup{CampaignID: c.ID, GroupID: c.MainGroupID, CreatedAt: now}).Error; err != nil {
+				return err
+			}
+		}
+		if c.KPID != "" {
+			m := &model.CampaignMember{CampaignID: c.ID, UserID: c.KPID, Role: model.CampaignRoleKP, JoinedAt: now}
+			if err := tx.Create(m).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func campaignTake(query *gorm.DB) (*model.Campaign, error) {
+	var items []*model.Campaign
+	if err := query.Limit(1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrCampaignNotFound
+	}
+	return items[0], nil
+}
+
+// CampaignGet 按ID获取战役
+func CampaignGet(operator engine2.DatabaseOperator, id uint64) (*model.Campaign, error) {
+	db := operator.GetDataDB(constant.READ)
+	return campaignTake(db.Where("id = ?", id))
+}
+
+// CampaignGetByName 按名称获取战役
+func CampaignGetByName(operator engine2.DatabaseOperator, name string) (*model.Campaign, error) {
+	db := operator.GetDataDB(constant.READ)
+	return campaignTake(db.Where("name = ?", name))
+}
+
+// CampaignGetByGroup 获取群关联的战役
+func CampaignGetByGroup(operator engine2.DatabaseOperator, groupID string) (*model.Campaign, error) {
+	db := operator.GetDataDB(constant.READ)
+	var ids []uint64
+	if err := db.Model(&model.CampaignGroup{}).Where("group_id = ?", groupID).Limit(1).Pluck("campaign_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrCampaignNotFound
+	}
+	return campaignTake(db.Where("id = ?", ids[0]))
+}
+
+// CampaignList 列出全部战役，按更新时间倒序
+func CampaignList(operator engine2.DatabaseOperator) ([]*model.Campaign, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.Campaign
+	err := db.Order("updated_at DESC, id DESC").Find(&items).Error
+	return items, err
+}
+
+// CampaignListRelated 列出与群或用户有关的战役: 群关联的、用户主持或参加的，按更新时间倒序
+func CampaignListRelated(operator engine2.DatabaseOperator, groupID string, userID string) ([]*model.Campaign, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.Campaign
+	err := db.Where("main_group_id = ? OR kp_id = ?", groupID, userID).
+		Or("id IN (?)", db.Model(&model.CampaignGroup{}).Select("campaign_id").Where("group_id = ?", groupID)).
+		Or("id IN (?)", db.Model(&model.CampaignMember{}).Select("campaign_id").Where("user_id = ?", userID)).
+		Order("updated_at DESC, id DESC").Find(&items).Error
+	return items, err
+}
+
+// CampaignTouch 更新战役的修改时间
+func CampaignTouch(operator engine2.DatabaseOperator, id uint64) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Model(&model.Campaign{}).Where("id = ?", id).Update("updated_at", time.Now().Unix()).Error
+}
+
+// CampaignDelete 删除战役及其成员、关联群、团次与笔记，不影响日志本身
+func CampaignDelete(operator engine2.DatabaseOperator, id uint64) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range []any{
+			&model.CampaignMember{}, &model.CampaignGroup{}, &model.CampaignSessionLog{},
+			&model.CampaignSession{}, &model.CampaignNote{},
+		} {
+			if err := tx.Where("campaign_id = ?", id).Delete(t).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", id).Delete(&model.Campaign{}).Error
+	})
+}
+
+// CampaignLinkGroup 将群关联到战役，已关联到本战役时不做处理
+func CampaignLinkGroup(operator engine2.DatabaseOperator, id uint64, groupID string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	var items []*model.CampaignGroup
+	if err := db.Where("group_id = ?", groupID).Limit(1).Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		if items[0].CampaignID == id {
+			return nil
+		}
+		return ErrCampaignGroupLinked
+	}
+	return db.Create(&model.CampaignGroup{CampaignID: id, GroupID: groupID, CreatedAt: time.Now().Unix()}).Error
+}
+
+// CampaignUnlinkGroup 取消群与战役的关联
+func CampaignUnlinkGroup(operator engine2.DatabaseOperator, id uint64, groupID string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Where("campaign_id = ? AND group_id = ?", id, groupID).Delete(&model.CampaignGroup{}).Error
+}
+
+// CampaignGroups 战役关联的群
+func CampaignGroups(operator engine2.DatabaseOperator, id uint64) ([]string, error) {
+	db := operator.GetDataDB(constant.READ)
+	var groups []string
+	err := db.Model(&model.CampaignGroup{}).Where("campaign_id = ?", id).Order("id ASC").Pluck("group_id", &groups).Error
+	return groups, err
+}
+
+// CampaignMemberSet 加入战役或更新成员绑定的角色，已是主持人的成员保留其身份
+func CampaignMemberSet(operator engine2.DatabaseOperator, m *model.CampaignMember) error {
+	db := operator.GetDataDB(constant.WRITE)
+	var items []*model.CampaignMember
+	if err := db.Where("campaign_id = ? AND user_id = ?", m.CampaignID, m.UserID).Limit(1).Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		old := items[0]
+		m.ID, m.JoinedAt = old.ID, old.JoinedAt
+		if old.Role == model.CampaignRoleKP {
+			m.Role = model.CampaignRoleKP
+		}
+	} else {
+		m.JoinedAt = time.Now().Unix()
+	}
+	if m.Role == "" {
+		m.Role = model.CampaignRolePlayer
+	}
+	return db.Save(m).Error
+}
+
+// CampaignMemberRemove 移出战役成员
+func CampaignMemberRemove(operator engine2.DatabaseOperator, id uint64, userID string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	ret := db.Where("campaign_id = ? AND user_id = ?", id, userID).Delete(&model.CampaignMember{})
+	if ret.Error != nil {
+		return ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		return ErrCampaignNotMember
+	}
+	return nil
+}
+
+// CampaignMembers 战役成员，主持人在前
+func CampaignMembers(operator engine2.DatabaseOperator, id uint64) ([]*model.CampaignMember, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.CampaignMember
+	if err := db.Where("campaign_id = ?", id).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Role == model.CampaignRoleKP && items[j].Role != model.CampaignRoleKP
+	})
+	return items, nil
+}
+
+// CampaignSessionCreate 新建一次团，编号接在最后一次之后
+func CampaignSessionCreate(operator engine2.DatabaseOperator, id uint64, title string) (*model.CampaignSession, error) {
+	db := operator.GetDataDB(constant.WRITE)
+	s := &model.CampaignSession{CampaignID: id, Title: title, CreatedAt: time.Now().Unix()}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var nos []int
+		if err := tx.Model(&model.CampaignSession{}).Where("campaign_id = ?", id).
+			Order("no DESC").Limit(1).Pluck("no", &nos).Error; err != nil {
+			return err
+		}
+		s.No = 1
+		if len(nos) > 0 {
+			s.No = nos[0] + 1
+		}
+		return tx.Create(s).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CampaignSessionCurrent 战役的最近一次团
+func CampaignSessionCurrent(operator engine2.DatabaseOperator, id uint64) (*model.CampaignSession, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.CampaignSession
+	if err := db.Where("campaign_id = ?", id).Order("no DESC").Limit(1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrCampaignNoSession
+	}
+	return items[0], nil
+}
+
+// CampaignSessions 战役的全部团次，按编号升序
+func CampaignSessions(operator engine2.DatabaseOperator, id uint64) ([]*model.CampaignSession, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.CampaignSession
+	err := db.Where("campaign_id = ?", id).Order("no ASC").Find(&items).Error
+	return items, err
+}
+
+// CampaignSessionAddLog 将日志加入团次
+func CampaignSessionAddLog(operator engine2.DatabaseOperator, s *model.CampaignSession, groupID string, logName string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	var count int64
+	if err := db.Model(&model.CampaignSessionLog{}).
+		Where("session_id = ? AND group_id = ? AND log_name = ?", s.ID, groupID, logName).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCampaignSessionExists
+	}
+	return db.Create(&model.CampaignSessionLog{
+		CampaignID: s.CampaignID,
+		SessionID:  s.ID,
+		GroupID:    groupID,
+		LogName:    logName,
+		CreatedAt:  time.Now().Unix(),
+	}).Error
+}
+
+// CampaignSessionLogs 战役全部团次的日志
+func CampaignSessionLogs(operator engine2.DatabaseOperator, id uint64) ([]*model.CampaignSessionLog, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.CampaignSessionLog
+	err := db.Where("campaign_id = ?", id).Order("id ASC").Find(&items).Error
+	return items, err
+}
+
+// CampaignNoteAdd 添加战役笔记
+func CampaignNoteAdd(operator engine2.DatabaseOperator, note *model.CampaignNote) error {
+	db := operator.GetDataDB(constant.WRITE)
+	note.CreatedAt = time.Now().Unix()
+	return db.Create(note).Error
+}
+
+// CampaignNotes 战役笔记，按时间倒序，limit<=0 不限
+func CampaignNotes(operator engine2.DatabaseOperator, id uint64, limit int) ([]*model.CampaignNote, error) {
+	db := operator.GetDataDB(constant.READ)
+	query := db.Where("campaign_id = ?", id).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var items []*model.CampaignNote
+	err := query.Find(&items).Error
+	return items, err
+}
+
+// campaignResolveLogs 查出团次日志在 log 库中的信息
+func campaignResolveLogs(operator engine2.DatabaseOperator, logs []*model.CampaignSessionLog) ([]*CampaignSessionLogInfo, error) {
+	db := operator.GetLogDB(constant.READ)
+	ret := make([]*CampaignSessionLogInfo, 0, len(logs))
+	for _, l := range logs {
+		var infos []*model.LogInfo
+		if err := db.Model(&model.LogInfo{}).Select("id, size").
+			Where("group_id = ? AND name = ?", l.GroupID, l.LogName).
+			Limit(1).Find(&infos).Error; err != nil {
+			return nil, err
+		}
+		info := &CampaignSessionLogInfo{CampaignSessionLog: l}
+		if len(infos) > 0 {
+			info.LogID, info.Exists = infos[0].ID, true
+			if infos[0].Size != nil {
+				info.Size = *infos[0].Size
+			}
+		}
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
+
+// CampaignGetDetail 获取战役的成员、关联群、团次日志与最近的笔记
+func CampaignGetDetail(operator engine2.DatabaseOperator, id uint64, noteLimit int) (*CampaignDetail, error) {
+	c, err := CampaignGet(operator, id)
+	if err != nil {
+		return nil, err
+	}
+	detail := &CampaignDetail{Campaign: c}
+	if detail.Members, err = CampaignMembers(operator, id); err != nil {
+		return nil, err
+	}
+	if detail.Groups, err = CampaignGroups(operator, id); err != nil {
+		return nil, err
+	}
+	if detail.Notes, err = CampaignNotes(operator, id, noteLimit); err != nil {
+		return nil, err
+	}
+	sessions, err := CampaignSessions(operator, id)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := CampaignSessionLogs(operator, id)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := campaignResolveLogs(operator, logs)
+	if err != nil {
+		return nil, err
+	}
+	bySession := map[uint64]*CampaignSessionDetail{}
+	for _, s := range sessions {
+		sd := &CampaignSessionDetail{CampaignSession: s, Logs: []*CampaignSessionLogInfo{}}
+		bySession[s.ID] = sd
+		detail.Sessions = append(detail.Sessions, sd)
+	}
+	for _, info := range infos {
+		if sd := bySession[info.SessionID]; sd != nil {
+			sd.Logs = append(sd.Logs, info)
+		}
+	}
+	return detail, nil
+}
+
+// CampaignStats 汇总战役全部团次日志中的检定，byCharacter 含义同 LogStatsSummarize
+func CampaignStats(operator engine2.DatabaseOperator, id uint64, skillName func(string) string, byCharacter bool) ([]*LogStatSummary, error) {
+	logs, err := CampaignSessionLogs(operator, id)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := campaignResolveLogs(operator, logs)
+	if err != nil {
+		return nil, err
+	}
+	var logIDs []uint64
+	seen := map[uint64]bool{}
+	for _, info := range infos {
+		if info.Exists && !seen[info.LogID] {
+			seen[info.LogID] = true
+			logIDs = append(logIDs, info.LogID)
+		}
+	}
+	rows, err := LogStatsCompute(operator, logIDs, skillName)
+	if err != nil {
+		return nil, err
+	}
+	return LogStatsSummarize(rows, byCharacter), nil
+}
@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func TestCampaignSessionsAndStats(t *testing.T) {
+	db, err := openLogInfoTestDB(filepath.ToSlash(filepath.Join(t.TempDir(), "campaign.db")))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	if err = db.AutoMigrate(
+		&model.LogInfo{}, &model.LogOneItem{},
+		&model.Campaign{}, &model.CampaignMember{}, &model.CampaignGroup{},
+		&model.CampaignSession{}, &model.CampaignSessionLog{}, &model.CampaignNote{},
+	); err != nil {
+		t.Fatalf("migrate tables: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	operator := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+
+	c := &model.Campaign{Name: "无光之城", KPID: "QQ:100", MainGroupID: "main"}
+	if err = service.CampaignCreate(operator, c); err != nil {
+		t.Fatalf("CampaignCreate() error = %v", err)
+	}
+	if err = service.CampaignLinkGroup(operator, c.ID, "side"); err != nil {
+		t.Fatalf("CampaignLinkGroup() error = %v", err)
+	}
+	other := &model.Campaign{Name: "另一个", MainGroupID: "side"}
+	if err = service.CampaignCreate(operator, other); !errors.Is(err, service.ErrCampaignGroupLinked) {
+		t.Fatalf("CampaignCreate() on linked group error = %v", err)
+	}
+	if got, err := service.CampaignGetByGroup(operator, "side"); err != nil || got.ID != c.ID {
+		t.Fatalf("CampaignGetByGroup() = %+v, %v", got, err)
+	}
+
+	if err = service.CampaignMemberSet(operator, &model.CampaignMember{CampaignID: c.ID, UserID: "QQ:100", PcName: "主持人"}); err != nil {
+		t.Fatalf("CampaignMemberSet() error = %v", err)
+	}
+	if err = service.CampaignMemberSet(operator, &model.CampaignMember{CampaignID: c.ID, UserID: "QQ:1", PcName: "艾琳"}); err != nil {
+		t.Fatalf("CampaignMemberSet() error = %v", err)
+	}
+	members, err := service.CampaignMembers(operator, c.ID)
+	if err != nil || len(members) != 2 || members[0].Role != model.CampaignRoleKP || members[1].Role != model.CampaignRolePlayer {
+		t.Fatalf("CampaignMembers() = %+v, %v", members, err)
+	}
+
+	if _, err = service.CampaignSessionCurrent(operator, c.ID); !errors.Is(err, service.ErrCampaignNoSession) {
+		t.Fatalf("CampaignSessionCurrent() error = %v", err)
+	}
+	s1, _ := service.CampaignSessionCreate(operator, c.ID, "序章")
+	s2, _ := service.CampaignSessionCreate(operator, c.ID, "")
+	if s1.No != 1 || s2.No != 2 {
+		t.Fatalf("session no = %d, %d", s1.No, s2.No)
+	}
+
+	// 两个群的日志，外加一份不属于战役的日志
+	commandID := int64(0)
+	appendCheck := func(groupID, logName, userID string, outcome, rank int) {
+		t.Helper()
+		logID, err := service.LogGetOrCreate(operator, groupID, logName)
+		if err != nil {
+			t.Fatalf("LogGetOrCreate: %v", err)
+		}
+		commandID++
+		info := map[string]any{"cmd": "ra", "rule": "coc7", "pcName": "艾琳", "items": []any{
+			map[string]any{"expr2": "侦查", "outcome": outcome, "rank": rank},
+		}}
+		for _, item := range []*model.LogOneItem{
+			{LogID: logID, GroupID: groupID, UniformID: userID, CommandID: commandID, Message: ".ra 侦查"},
+			{LogID: logID, GroupID: groupID, UniformID: "QQ:bot", CommandID: commandID, IsDice: true, CommandInfo: info},
+		} {
+			if err := db.Create(item).Error; err != nil {
+				t.Fatalf("create log item: %v", err)
+			}
+		}
+	}
+	appendCheck("main", "第一团", "QQ:1", 5, 4)
+	appendCheck("side", "第一团-副群", "QQ:1", 90, -1)
+	appendCheck("main", "闲聊", "QQ:1", 100, -2)
+
+	if err = service.CampaignSessionAddLog(operator, s1, "main", "第一团"); err != nil {
+		t.Fatalf("CampaignSessionAddLog() error = %v", err)
+	}
+	if err = service.CampaignSessionAddLog(operator, s1, "main", "第一团"); !errors.Is(err, service.ErrCampaignSessionExists) {
+		t.Fatalf("CampaignSessionAddLog() twice error = %v", err)
+	}
+	_ = service.CampaignSessionAddLog(operator, s2, "side", "第一团-副群")
+	_ = service.CampaignSessionAddLog(operator, s2, "side", "已删除的日志")
+
+	detail, err := service.CampaignGetDetail(operator, c.ID, 0)
+	if err != nil || len(detail.Sessions) != 2 || len(detail.Groups) != 2 {
+		t.Fatalf("CampaignGetDetail() = %+v, %v", detail, err)
+	}
+	if logs := detail.Sessions[1].Logs; len(logs) != 2 || !logs[0].Exists || logs[1].Exists {
+		t.Fatalf("session 2 logs = %+v", logs)
+	}
+
+	summaries, err := service.CampaignStats(operator, c.ID, nil, true)
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("CampaignStats() = %+v, %v", summaries, err)
+	}
+	total := summaries[0].Total
+	if summaries[0].UserID != "QQ:1" || total.Checks != 2 || total.Successes != 1 || total.Crits != 1 || total.Fumbles != 0 || total.D100Sum != 95 {
+		t.Fatalf("campaign stat total = %+v", total)
+	}
+
+	if err = service.CampaignDelete(operator, c.ID); err != nil {
+		t.Fatalf("CampaignDelete() error = %v", err)
+	}
+	if _, err = service.CampaignGetByGroup(operator, "main"); !errors.Is(err, service.ErrCampaignNotFound) {
+		t.Fatalf("CampaignGetByGroup() after delete error = %v", err)
+	}
+	if logs, _ := service.CampaignSessionLogs(operator, c.ID); len(logs) != 0 {
+		t.Fatalf("session logs after delete = %+v", logs)
+	}
+}
@@ -526,7 +526,13 @@ func LogDelete(operator engine2.DatabaseOperator, groupID string, logName string
 	if err != nil {
 		return err
 	}
+	// 与统计汇总互斥，避免汇总读到一半的日志被删后又写回统计
+	logStatMu.Lock()
+	defer logStatMu.Unlock()
 	err = db.Transaction(func(tx *gorm.DB) error {
+		if err = logStatInvalidateLog(tx, logID); err != nil {
+			return err
+		}
 		// 删除 log_id 相关的 log_items 记录
 		if err = tx.Where("log_id = ?", logID).Delete(&model.LogOneItem{}).Error; err != nil {
 			return err
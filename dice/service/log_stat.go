@@ -0,0 +1,438 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+// 跨日志检定统计
+//
+// 统计来自骰子回复中记录的指令信息(目前为 coc7 的 ra/sc)，暗骰不计入。
+// 指令信息里只有角色名，发出指令的用户由同一日志中 command_id 相同的玩家发言取得。
+// log_stat_cursor 记录已汇总到的 log_items.id，每次只处理新增的条目。
+
+const logStatBatchSize = 500
+
+var logStatMu sync.Mutex
+
+// LogStatSanSkill 理智检定在统计中使用的技能名
+const LogStatSanSkill = "理智"
+
+type logStatKey struct {
+	GroupID string
+	UserID  string
+	PcName  string
+	Skill   string
+}
+
+type logStatBatch struct {
+	db        *gorm.DB
+	skillName func(string) string
+	stats     map[logStatKey]*model.LogStat
+	users     map[[2]uint64]string
+	now       int64
+	memory    bool // 只在内存中汇总，不读取统计缓存
+}
+
+func (b *logStatBatch) get(key logStatKey) (*model.LogStat, error) {
+	if s, ok := b.stats[key]; ok {
+		return s, nil
+	}
+	if b.memory {
+		s := &model.LogStat{GroupID: key.GroupID, UserID: key.UserID, PcName: key.PcName, Skill: key.Skill}
+		b.stats[key] = s
+		return s, nil
+	}
+	var rows []*model.LogStat
+	err := b.db.Where("group_id = ? AND user_id = ? AND pc_name = ? AND skill = ?", key.GroupID, key.UserID, key.PcName, key.Skill).
+		Limit(1).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	s := &model.LogStat{GroupID: key.GroupID, UserID: key.UserID, PcName: key.PcName, Skill: key.Skill}
+	if len(rows) > 0 {
+		s = rows[0]
+	}
+	b.stats[key] = s
+	return s, nil
+}
+
+// userOf 取得发出指令的用户
+func (b *logStatBatch) userOf(item *model.LogOneItem) (string, error) {
+	if item.CommandID == 0 {
+		return "", nil
+	}
+	k := [2]uint64{item.LogID, uint64(item.CommandID)}
+	if uid, ok := b.users[k]; ok {
+		return uid, nil
+	}
+	var ids []string
+	err := b.db.Model(&model.LogOneItem{}).
+		Where("log_id = ? AND command_id = ? AND is_dice = ?", item.LogID, item.CommandID, false).
+		Order("id DESC").Limit(1).
+		Pluck("user_uniform_id", &ids).Error
+	if err != nil {
+		return "", err
+	}
+	uid := ""
+	if len(ids) > 0 {
+		uid = ids[0]
+	}
+	b.users[k] = uid
+	return uid, nil
+}
+
+// record 计入一次检定，rank 与 coc7 检定结果一致: 4大成功 -2大失败 >0成功 <0失败
+func (b *logStatBatch) record(key logStatKey, rank int64, outcome int64, sanLoss int64, isSan bool) error {
+	total, err := b.get(logStatKey{GroupID: key.GroupID, UserID: key.UserID, PcName: key.PcName})
+	if err != nil {
+		return err
+	}
+	skill, err := b.get(key)
+	if err != nil {
+		return err
+	}
+	for _, s := range []*model.LogStat{total, skill} {
+		s.Checks++
+		if rank > 0 {
+			s.Successes++
+		}
+		if rank == 4 {
+			s.Crits++
+		}
+		if rank == -2 {
+			s.Fumbles++
+		}
+		if outcome > 0 {
+			s.D100Sum += outcome
+			s.D100Count++
+		}
+		if isSan {
+			s.SanChecks++
+			s.SanLoss += max(sanLoss, 0)
+		}
+		s.UpdatedAt = b.now
+	}
+
+	switch {
+	case rank > 0:
+		total.Streak = max(total.Streak, 0) + 1
+		total.BestRun = max(total.BestRun, total.Streak)
+	case rank < 0:
+		total.Streak = min(total.Streak, 0) - 1
+		total.WorstRun = max(total.WorstRun, -total.Streak)
+	}
+	return nil
+}
+
+func logStatReadInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func (b *logStatBatch) add(item *model.LogOneItem) error {
+	info, ok := item.CommandInfo.(map[string]any)
+	if !ok || info["rule"] != "coc7" || info["hide"] == true {
+		return nil
+	}
+	cmd, _ := info["cmd"].(string)
+	if cmd != "ra" && cmd != "sc" {
+		return nil
+	}
+	items, _ := info["items"].([]any)
+	if len(items) == 0 {
+		return nil
+	}
+
+	uid, err := b.userOf(item)
+	if err != nil {
+		return err
+	}
+	key := logStatKey{GroupID: item.GroupID, UserID: uid, PcName: fmt.Sprintf("%v", info["pcName"])}
+
+	for _, raw := range items {
+		j, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		rank, ok := logStatReadInt(j["rank"])
+		if !ok {
+			continue
+		}
+		outcome, _ := logStatReadInt(j["outcome"])
+		if cmd == "sc" {
+			sanOld, _ := logStatReadInt(j["sanOld"])
+			sanNew, _ := logStatReadInt(j["sanNew"])
+			key.Skill = LogStatSanSkill
+			err = b.record(key, rank, outcome, sanOld-sanNew, true)
+		} else {
+			key.Skill = fmt.Sprintf("%v", j["expr2"])
+			if b.skillName != nil {
+				key.Skill = b.skillName(key.Skill)
+			}
+			err = b.record(key, rank, outcome, 0, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogStatsUpdate 将上次汇总后新增的日志条目计入统计，返回本次处理的条目数
+// skillName 用于规范技能名(如同义词)，可以为 nil
+func LogStatsUpdate(operator engine2.DatabaseOperator, skillName func(string) string) (int, error) {
+	logStatMu.Lock()
+	defer logStatMu.Unlock()
+
+	db := operator.GetLogDB(constant.WRITE)
+	cursor := model.LogStatCursor{ID: 1}
+	if err := db.FirstOrCreate(&cursor, model.LogStatCursor{ID: 1}).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		var items []*model.LogOneItem
+		err := db.Model(&model.LogOneItem{}).
+			Select("id, log_id, group_id, command_id, command_info, removed").
+			Where("id > ? AND is_dice = ? AND command_info <> ''", cursor.LastItemID, true).
+			Order("id ASC").Limit(logStatBatchSize).
+			Find(&items).Error
+		if err != nil {
+			return count, err
+		}
+		if len(items) == 0 {
+			return count, nil
+		}
+
+		batch := &logStatBatch{
+			db:        db,
+			skillName: skillName,
+			stats:     map[logStatKey]*model.LogStat{},
+			users:     map[[2]uint64]string{},
+			now:       time.Now().Unix(),
+		}
+		for _, item := range items {
+			if item.Removed != nil {
+				continue
+			}
+			if err = batch.add(item); err != nil {
+				return count, err
+			}
+		}
+
+		cursor.LastItemID = items[len(items)-1].ID
+		cursor.UpdatedAt = batch.now
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, s := range batch.stats {
+				if err := tx.Save(s).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Save(&cursor).Error
+		})
+		if err != nil {
+			return count, err
+		}
+		count += len(items)
+		if len(items) < logStatBatchSize {
+			return count, nil
+		}
+	}
+}
+
+// LogStatsCompute 不经过统计缓存，直接汇总指定日志中的检定，用于战役等按日志挑选的统计
+func LogStatsCompute(operator engine2.DatabaseOperator, logIDs []uint64, skillName func(string) string) ([]*model.LogStat, error) {
+	if len(logIDs) == 0 {
+		return nil, nil
+	}
+	db := operator.GetLogDB(constant.READ)
+	batch := &logStatBatch{
+		db:        db,
+		skillName: skillName,
+		stats:     map[logStatKey]*model.LogStat{},
+		users:     map[[2]uint64]string{},
+		now:       time.Now().Unix(),
+		memory:    true,
+	}
+	lastID := uint64(0)
+	for {
+		var items []*model.LogOneItem
+		err := db.Model(&model.LogOneItem{}).
+			Select("id, log_id, group_id, command_id, command_info, removed").
+			Where("id > ? AND log_id IN ? AND is_dice = ? AND command_info <> ''", lastID, logIDs, true).
+			Order("id ASC").Limit(logStatBatchSize).
+			Find(&items).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if item.Removed != nil {
+				continue
+			}
+			if err = batch.add(item); err != nil {
+				return nil, err
+			}
+		}
+		if len(items) < logStatBatchSize {
+			break
+		}
+		lastID = items[len(items)-1].ID
+	}
+
+	rows := make([]*model.LogStat, 0, len(batch.stats))
+	for _, s := range batch.stats {
+		rows = append(rows, s)
+	}
+	return rows, nil
+}
+
+// LogStatsReset 清空统计缓存，下次更新时从头汇总
+func LogStatsReset(operator engine2.DatabaseOperator) error {
+	logStatMu.Lock()
+	defer logStatMu.Unlock()
+
+	db := operator.GetLogDB(constant.WRITE)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&model.LogStat{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("1 = 1").Delete(&model.LogStatCursor{}).Error
+	})
+}
+
+// logStatInvalidateLog 删除日志前调用，日志中有已计入统计的条目时清空统计缓存，下次更新时从头汇总。
+// 连续成功等记录无法按日志扣除，因此不做增量扣减
+func logStatInvalidateLog(tx *gorm.DB, logID uint64) error {
+	if !tx.Migrator().HasTable(&model.LogStatCursor{}) {
+		return nil
+	}
+	var cursors []*model.LogStatCursor
+	if err := tx.Where("id = ?", 1).Limit(1).Find(&cursors).Error; err != nil {
+		return err
+	}
+	if len(cursors) == 0 || cursors[0].LastItemID == 0 {
+		return nil
+	}
+	var counted int64
+	err := tx.Model(&model.LogOneItem{}).
+		Where("log_id = ? AND id <= ? AND is_dice = ? AND command_info <> ''", logID, cursors[0].LastItemID, true).
+		Count(&counted).Error
+	if err != nil || counted == 0 {
+		return err
+	}
+	if err = tx.Where("1 = 1").Delete(&model.LogStat{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("1 = 1").Delete(&model.LogStatCursor{}).Error
+}
+
+// QueryLogStat 统计查询条件，为空的条件不限制
+type QueryLogStat struct {
+	GroupID string `json:"groupId" query:"groupId"`
+	UserID  string `json:"userId"  query:"userId"`
+	PcName  string `json:"pcName"  query:"pcName"`
+}
+
+// LogStatsQuery 查询统计缓存中的原始行
+func LogStatsQuery(operator engine2.DatabaseOperator, q *QueryLogStat) ([]*model.LogStat, error) {
+	db := operator.GetLogDB(constant.READ)
+	query := db.Model(&model.LogStat{})
+	if q.GroupID != "" {
+		query = query.Where("group_id = ?", q.GroupID)
+	}
+	if q.UserID != "" {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.PcName != "" {
+		query = query.Where("pc_name = ?", q.PcName)
+	}
+	var rows []*model.LogStat
+	err := query.Order("id ASC").Find(&rows).Error
+	return rows, err
+}
+
+// LogStatSummary 一名角色(或一名用户的全部角色)的统计汇总
+type LogStatSummary struct {
+	UserID      string           `json:"userId"`
+	PcName      string           `json:"pcName"` // 按用户汇总时为空
+	Groups      int              `json:"groups"` // 涉及的群数
+	Total       model.LogStat    `json:"total"`
+	Skills      []*model.LogStat `json:"skills"` // 按检定次数降序
+	SuccessRate float64          `json:"successRate"`
+	D100Avg     float64          `json:"d100Avg"`
+}
+
+// LogStatsSummarize 将原始行按 用户-角色(byCharacter) 或 用户 汇总，结果按检定次数降序
+func LogStatsSummarize(rows []*model.LogStat, byCharacter bool) []*LogStatSummary {
+	type sumKey struct{ UserID, PcName string }
+	summaries := map[sumKey]*LogStatSummary{}
+	skills := map[sumKey]map[string]*model.LogStat{}
+	groups := map[sumKey]map[string]bool{}
+
+	for _, row := range rows {
+		k := sumKey{UserID: row.UserID}
+		if byCharacter {
+			k.PcName = row.PcName
+		}
+		s, ok := summaries[k]
+		if !ok {
+			s = &LogStatSummary{UserID: k.UserID, PcName: k.PcName}
+			summaries[k] = s
+			skills[k] = map[string]*model.LogStat{}
+			groups[k] = map[string]bool{}
+		}
+		groups[k][row.GroupID] = true
+		if row.Skill == "" {
+			s.Total.Merge(row)
+			continue
+		}
+		sk, ok := skills[k][row.Skill]
+		if !ok {
+			sk = &model.LogStat{UserID: k.UserID, PcName: k.PcName, Skill: row.Skill}
+			skills[k][row.Skill] = sk
+		}
+		sk.Merge(row)
+	}
+
+	ret := make([]*LogStatSummary, 0, len(summaries))
+	for k, s := range summaries {
+		for _, sk := range skills[k] {
+			s.Skills = append(s.Skills, sk)
+		}
+		sort.Slice(s.Skills, func(i, j int) bool {
+			if s.Skills[i].Checks != s.Skills[j].Checks {
+				return s.Skills[i].Checks > s.Skills[j].Checks
+			}
+			return s.Skills[i].Skill < s.Skills[j].Skill
+		})
+		s.Groups = len(groups[k])
+		s.Total.UserID, s.Total.PcName = s.UserID, s.PcName
+		s.SuccessRate = s.Total.SuccessRate()
+		s.D100Avg = s.Total.D100Avg()
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Total.Checks != ret[j].Total.Checks {
+			return ret[i].Total.Checks > ret[j].Total.Checks
+		}
+		return ret[i].UserID+ret[i].PcName < ret[j].UserID+ret[j].PcName
+	})
+	return ret
+}
@@ -0,0 +1,121 @@
+package service_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func TestLogStatsUpdateIncremental(t *testing.T) {
+	db, err := openLogInfoTestDB(filepath.ToSlash(filepath.Join(t.TempDir(), "stat.db")))
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	if err = db.AutoMigrate(&model.LogInfo{}, &model.LogOneItem{}, &model.LogStat{}, &model.LogStatCursor{}); err != nil {
+		t.Fatalf("migrate tables: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	operator := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+
+	commandID := int64(0)
+	// 玩家发言 + 骰子回复，回复中带有指令信息
+	appendCheck := func(logID uint64, userID string, info map[string]any) {
+		t.Helper()
+		commandID++
+		items := []*model.LogOneItem{
+			{LogID: logID, GroupID: "group", UniformID: userID, CommandID: commandID, Message: ".ra"},
+			{LogID: logID, GroupID: "group", UniformID: "QQ:bot", CommandID: commandID, IsDice: true, CommandInfo: info},
+		}
+		for _, item := range items {
+			if err := db.Create(item).Error; err != nil {
+				t.Fatalf("create log item: %v", err)
+			}
+		}
+	}
+	ra := func(pc string, skill string, outcome, rank int) map[string]any {
+		return map[string]any{"cmd": "ra", "rule": "coc7", "pcName": pc, "items": []any{
+			map[string]any{"expr2": skill, "outcome": outcome, "rank": rank},
+		}}
+	}
+
+	appendCheck(1, "QQ:1", ra("艾琳", "侦查60", 5, 4))
+	appendCheck(1, "QQ:1", ra("艾琳", "侦查", 30, 1))
+	appendCheck(1, "QQ:1", ra("艾琳", "图书馆", 99, -2))
+	appendCheck(1, "QQ:2", ra("鲍勃", "聆听", 70, -1))
+	hidden := ra("鲍勃", "聆听", 1, 4)
+	hidden["hide"] = true
+	appendCheck(1, "QQ:2", hidden)
+
+	skillName := func(s string) string {
+		if s == "侦查60" {
+			return "侦查"
+		}
+		return s
+	}
+	if _, err = service.LogStatsUpdate(operator, skillName); err != nil {
+		t.Fatalf("LogStatsUpdate() error = %v", err)
+	}
+
+	// 第二个日志中的理智检定，增量计入
+	appendCheck(2, "QQ:1", map[string]any{"cmd": "sc", "rule": "coc7", "pcName": "艾琳", "items": []any{
+		map[string]any{"outcome": 80, "rank": -1, "sanOld": 60, "sanNew": 55},
+	}})
+	n, err := service.LogStatsUpdate(operator, skillName)
+	if err != nil || n != 1 {
+		t.Fatalf("LogStatsUpdate() = %d, %v, want 1 new item", n, err)
+	}
+
+	rows, err := service.LogStatsQuery(operator, &service.QueryLogStat{UserID: "QQ:1"})
+	if err != nil {
+		t.Fatalf("LogStatsQuery() error = %v", err)
+	}
+	summaries := service.LogStatsSummarize(rows, true)
+	if len(summaries) != 1 {
+		t.Fatalf("summaries = %+v", summaries)
+	}
+	s := summaries[0]
+	total := s.Total
+	if s.PcName != "艾琳" || total.Checks != 4 || total.Successes != 2 || total.Crits != 1 || total.Fumbles != 1 {
+		t.Fatalf("total = %+v", total)
+	}
+	if total.D100Count != 4 || s.D100Avg != 53.5 || total.SanLoss != 5 || total.SanChecks != 1 {
+		t.Fatalf("d100/san = %+v avg=%v", total, s.D100Avg)
+	}
+	if total.BestRun != 2 || total.WorstRun != 2 {
+		t.Fatalf("runs = best %d worst %d, want 2 2", total.BestRun, total.WorstRun)
+	}
+	if len(s.Skills) != 3 || s.Skills[0].Skill != "侦查" || s.Skills[0].Checks != 2 {
+		t.Fatalf("skills = %+v", s.Skills)
+	}
+
+	rows, _ = service.LogStatsQuery(operator, &service.QueryLogStat{UserID: "QQ:2"})
+	if bob := service.LogStatsSummarize(rows, false); len(bob) != 1 || bob[0].Total.Checks != 1 {
+		t.Fatalf("暗骰不应计入: %+v", bob)
+	}
+
+	// 删除已计入统计的日志后，统计随之更新
+	if err = db.Create(&model.LogInfo{ID: 2, GroupID: "group", Name: "第二章"}).Error; err != nil {
+		t.Fatalf("create log: %v", err)
+	}
+	if err = service.LogDelete(operator, "group", "第二章"); err != nil {
+		t.Fatalf("LogDelete() error = %v", err)
+	}
+	if n, _ = service.LogStatsUpdate(operator, skillName); n != 5 {
+		t.Fatalf("删除日志后应从头汇总, got %d", n)
+	}
+	rows, _ = service.LogStatsQuery(operator, &service.QueryLogStat{UserID: "QQ:1"})
+	if s := service.LogStatsSummarize(rows, true); len(s) != 1 || s[0].Total.Checks != 3 || s[0].Total.SanChecks != 0 {
+		t.Fatalf("删除日志后 = %+v", s)
+	}
+
+	if err = service.LogStatsReset(operator); err != nil {
+		t.Fatalf("LogStatsReset() error = %v", err)
+	}
+	if n, _ = service.LogStatsUpdate(operator, skillName); n != 5 {
+		t.Fatalf("重置后应从头汇总, got %d", n)
+	}
+}
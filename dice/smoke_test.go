@@ -1,12 +1,16 @@
 package dice_test
 
 import (
+	"archive/zip"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"unicode/utf8"
 
 	"sealdice-core/dice"
+	"sealdice-core/dice/exttest"
 )
 
 // --- Smoke tests for LimitCommandReasonText ---
@@ -186,3 +190,214 @@ func BenchmarkShuffleRandomPool_Pick(b *testing.B) {
 		_ = pool.Pick()
 	}
 }
+
+// --- Smoke tests for ExtHarness ---
+
+const harnessScript = `// ==UserScript==
+// @name 问候
+// @author tester
+// @version 1.0.0
+// ==/UserScript==
+let ext = seal.ext.new('greeter', 'tester', '1.0.0');
+const cmd = seal.ext.newCmdItemInfo();
+cmd.name = 'greet';
+cmd.solve = (ctx, msg, cmdArgs) => {
+  const n = Number(ext.storageGet('count') || '0') + 1;
+  ext.storageSet('count', String(n));
+  seal.vars.intSet(ctx, '$m问候次数', n);
+  seal.replyToSender(ctx, msg, '你好，' + (cmdArgs.getArgN(1) || '陌生人') + '（第' + n + '次）');
+  return seal.ext.newCmdExecuteResult(true);
+};
+ext.cmdMap['greet'] = cmd;
+seal.ext.register(ext);
+`
+
+const harnessScenario = `name: 问候
+seed: 42
+scripts: [greeter.js]
+steps:
+  - send: .greet 木落
+    reply: ["你好，木落（第1次）"]
+  - group: "2001"
+    send: .greet
+    reply: ["你好，陌生人（第2次）"]
+    vars: {"$m问候次数": "2"}
+    storage: {greeter: {count: "2"}}
+  - send: 随便说点什么
+    noReply: true
+  - send: .r d100
+    replyRegex: "d100=\\d+"
+`
+
+func TestExtHarness_Scenario(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "greeter.js"), []byte(harnessScript), 0o644)
+	scenario := filepath.Join(dir, "greet.yaml")
+	_ = os.WriteFile(scenario, []byte(harnessScenario), 0o644)
+
+	result := exttest.RunScenarioFile(t, scenario)
+	if len(result.Steps) != 4 {
+		t.Fatalf("steps = %d, want 4", len(result.Steps))
+	}
+
+	// 相同种子的骰点结果一致
+	roll := result.Steps[3].Replies
+	again := exttest.New(t, 42).Send("1001", "", ".r d100", 0)
+	if len(roll) != 1 || len(again) != 1 || roll[0] != again[0] {
+		t.Fatalf("same seed rolled %q and %q", roll, again)
+	}
+}
+
+const docStoreScript = `// ==UserScript==
+// @name 笔记
+// @author tester
+// @version 1.0.0
+// ==/UserScript==
+let ext = seal.ext.new('notes', 'tester', '1.0.0');
+const cmd = seal.ext.newCmdItemInfo();
+cmd.name = 'note';
+cmd.solve = (ctx, msg, cmdArgs) => {
+  const docs = ext.docs();
+  docs.ensureIndex('notes', 'user');
+  const text = cmdArgs.getArgN(1);
+  if (text) {
+    docs.insert('notes', {user: ctx.player.userId, text: text});
+  }
+  const mine = docs.find('notes', {filter: {user: ctx.player.userId}, sort: 'text'});
+  seal.replyToSender(ctx, msg, mine.map(d => d.text).join(','));
+  return seal.ext.newCmdExecuteResult(true);
+};
+ext.cmdMap['note'] = cmd;
+seal.ext.register(ext);
+`
+
+func TestExtDocStore_JsBinding(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "notes.js")
+	_ = os.WriteFile(script, []byte(docStoreScript), 0o644)
+
+	h := exttest.New(t, 1)
+	if err := h.LoadScript(script); err != nil {
+		t.Fatal(err)
+	}
+	h.Send("1001", "", ".note b", 0)
+	h.Send("1002", "", ".note x", 0)
+	replies := h.Send("1001", "", ".note a", 0)
+	if len(replies) != 1 || replies[0] != "a,b" {
+		t.Fatalf("replies = %q", replies)
+	}
+}
+
+func TestExtHarness_ScriptTimeout(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "spin.js")
+	_ = os.WriteFile(script, []byte(`// ==UserScript==
+// @name 死循环
+// @author tester
+// @version 1.0.0
+// ==/UserScript==
+let ext = seal.ext.new('spin', 'tester', '1.0.0');
+const cmd = seal.ext.newCmdItemInfo();
+cmd.name = 'spin';
+cmd.solve = () => { while (true) {} };
+ext.cmdMap['spin'] = cmd;
+seal.ext.register(ext);
+`), 0o644)
+
+	h := exttest.New(t, 1)
+	h.Dice.Config.JsExecTimeout = 100
+	if err := h.LoadScript(script); err != nil {
+		t.Fatal(err)
+	}
+	replies := h.Send("1001", "", ".spin", 0)
+	if len(replies) != 1 || !strings.Contains(replies[0], "已被中断") {
+		t.Fatalf("replies = %q", replies)
+	}
+}
+
+var jsProjectFiles = map[string]string{
+	"package.json": `{
+  "name": "proj-demo",
+  "version": "1.2.0",
+  "author": {"name": "tester"},
+  "main": "src/index.ts",
+  "sealdice": {"name": "工程示例"}
+}`,
+	"src/index.ts": `import { shout } from './util';
+import pad from 'tiny-pad';
+
+const ext = seal.ext.new('proj', 'tester', '1.2.0');
+const cmd = seal.ext.newCmdItemInfo();
+cmd.name = 'proj';
+cmd.solve = (ctx, msg, cmdArgs) => {
+  const arg: string = cmdArgs.getArgN(1);
+  seal.replyToSender(ctx, msg, pad(shout(arg)));
+  return seal.ext.newCmdExecuteResult(true);
+};
+ext.cmdMap['proj'] = cmd;
+seal.ext.register(ext);
+`,
+	"src/util.ts": `export function shout(s: string): string {
+  if (!s) {
+    throw new Error('nothing to shout');
+  }
+  return s.toUpperCase();
+}
+`,
+	"node_modules/tiny-pad/package.json": `{"name": "tiny-pad", "main": "index.js"}`,
+	"node_modules/tiny-pad/index.js":     `module.exports = function (s) { return '[' + s + ']'; };`,
+}
+
+func TestJsProject_BundleAndSourceMap(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "proj")
+	for name, content := range jsProjectFiles {
+		_ = os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+		_ = os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+	}
+
+	h := exttest.New(t, 1)
+	if err := h.LoadScript(dir); err != nil {
+		t.Fatal(err)
+	}
+	info := h.Dice.JsScriptList[len(h.Dice.JsScriptList)-1]
+	if info.Name != "工程示例" || info.Version != "1.2.0" || info.Author != "tester" || info.ProjectDir != dir {
+		t.Fatalf("meta = %+v", info)
+	}
+	if replies := h.Send("1001", "", ".proj hi", 0); len(replies) != 1 || replies[0] != "[HI]" {
+		t.Fatalf("replies = %q", replies)
+	}
+
+	h.Dice.JsPrinter.RecordStart()
+	h.Send("1001", "", ".proj", 0)
+	outputs := strings.Join(h.Dice.JsPrinter.RecordEnd(), "\n")
+	if !strings.Contains(outputs, "nothing to shout") || !strings.Contains(outputs, "util.ts:3") {
+		t.Fatalf("error should map to original source, got %q", outputs)
+	}
+
+	// 上传的压缩包中工程位于子目录
+	zipPath := filepath.Join(t.TempDir(), "proj.zip")
+	f, _ := os.Create(zipPath)
+	zw := zip.NewWriter(f)
+	for name, content := range jsProjectFiles {
+		w, _ := zw.Create("proj-main/" + name)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = zw.Close()
+	_ = f.Close()
+	target, err := h.Dice.JsInstallProject(zipPath, "proj")
+	if err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(target, "src", "util.ts")); err != nil {
+		t.Fatalf("installed project: %v", err)
+	}
+	// 工程名来自上传的文件名，不能指向 scripts 目录本身或其外部
+	for _, name := range []string{"", ".", "..", "../proj", "a/b", `a\b`} {
+		if _, err = h.Dice.JsInstallProject(zipPath, name); err == nil {
+			t.Fatalf("install %q should be rejected", name)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(target, "src", "util.ts")); err != nil {
+		t.Fatalf("project removed by rejected install: %v", err)
+	}
+}
@@ -153,6 +153,13 @@ type storePackageFilesResponse struct {
 	Err           string                  `json:"err"`
 }
 
+type storePackageVersionsResponse struct {
+	FormatVersion string          `json:"formatVersion"`
+	Result        bool            `json:"result"`
+	Data          []*StorePackage `json:"data"`
+	Err           string          `json:"err"`
+}
+
 type storeBackendTarget struct {
 	rawURL      string
 	id          string
@@ -167,6 +174,7 @@ var storeAllowedContents = map[string]struct{}{
 	"reply":     {},
 	"helpdoc":   {},
 	"templates": {},
+	"tables":    {},
 }
 
 func BuildStorePackageFullID(id, version string) string {
@@ -822,6 +830,59 @@ func (m *StoreManager) StoreQueryPackageFiles(namespace, packageName, version st
 	return sanitizeStorePackageFileEntries(respResult.Data)
 }
 
+// StoreQueryPackageVersions 查询包在当前商店中的全部版本。
+// /versions 是可选接口，后端不支持时退回按 ID 搜索与已缓存的列表，通常只能得到最新版本。
+func (m *StoreManager) StoreQueryPackageVersions(id string) ([]*StorePackage, error) {
+	namespace, packageName, err := sealpack.ParsePackageID(strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	id = namespace + "/" + packageName
+	backend, err := m.currentBackend()
+	if err != nil {
+		return nil, err
+	}
+	if !backend.Health {
+		return nil, fmt.Errorf("当前扩展商店后端不可用: %s", backend.Url)
+	}
+
+	var packages []*StorePackage
+	requestURL, err := buildStoreBackendResourceURL(backend.Url, "versions", namespace, packageName)
+	if err != nil {
+		return nil, err
+	}
+	if respResult, fetchErr := fetchStoreJSON[storePackageVersionsResponse](requestURL); fetchErr == nil && respResult.Result {
+		packages = respResult.Data
+	} else {
+		page, pageErr := m.StoreQueryPage(StoreQueryPageParams{Name: id, PageSize: 100})
+		if pageErr != nil {
+			return nil, pageErr
+		}
+		packages = page.Data
+		m.lock.RLock()
+		for _, pkg := range m.packageCache {
+			packages = append(packages, pkg)
+		}
+		m.lock.RUnlock()
+	}
+
+	sanitized, err := sanitizeStorePackages(packages, backend.Url)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	result := make([]*StorePackage, 0, len(sanitized))
+	for _, pkg := range sanitized {
+		if pkg.ID != id || seen[pkg.FullID] {
+			continue
+		}
+		seen[pkg.FullID] = true
+		result = append(result, pkg)
+	}
+	m.RefreshInstalled(result)
+	return result, nil
+}
+
 func (m *StoreManager) StorePreviewPackageFile(ctx context.Context, namespace, packageName, version, filePath string) (*http.Response, error) {
 	backend, err := m.currentBackend()
 	if err != nil {
@@ -0,0 +1,726 @@
+// Package storeserver 按 api/store_backend_api.md 的协议，把一个目录下的 .sealpack 文件作为扩展商店后端对外提供，
+// 供社群在局域网或私有服务器上共享审核过的扩展包。
+package storeserver
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"sealdice-core/dice"
+	"sealdice-core/dice/sealpack"
+)
+
+const (
+	// BasePath 商店接口的根路径，客户端填写的后端地址为 <host>/dice/api/store
+	BasePath        = "/dice/api/store"
+	ProtocolVersion = "2.0"
+
+	// ConfigFileName 商店名称、公告、推荐列表等设置，位于扩展包目录下，可省略
+	ConfigFileName = "store.json"
+	// StatsFileName 下载次数与评分，由服务端维护
+	StatsFileName = "stats.json"
+
+	rescanInterval  = 10 * time.Second
+	pageSizeDefault = 20
+	pageSizeMax     = 100
+	recommendMax    = 10
+	// previewSizeMax 预览单个文件的大小上限，超出时拒绝预览，需下载整个扩展包查看
+	previewSizeMax = 4 << 20
+)
+
+// contentKinds 商店协议允许的内容类型，与扩展包的顶层目录同名
+var contentKinds = []string{"scripts", "decks", "reply", "helpdoc", "templates", "tables"}
+
+// Config store.json 的内容
+type Config struct {
+	Name         string   `json:"name"`
+	Announcement string   `json:"announcement"`
+	Sign         string   `json:"sign"`      // 官方签发的域名签名，有则客户端显示为可信商店
+	Recommend    []string `json:"recommend"` // 推荐的包 ID，为空时按下载量推荐
+	// TrustedProxies 前置反向代理的 IP 或 CIDR，仅来自这些地址的请求才采信 X-Forwarded-For，为空时按直连地址识别评分者
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+// Stats stats.json 的内容
+type Stats struct {
+	Downloads map[string]uint64         `json:"downloads"` // 包 ID -> 下载次数
+	Ratings   map[string]map[string]int `json:"ratings"`   // 包 ID -> 评分者 IP -> 1~5 分
+}
+
+// Package 商店返回的包信息，在协议 DTO 之外附带评分
+type Package struct {
+	*dice.StorePackage
+	Rating      float64 `json:"rating"`
+	RatingCount int     `json:"ratingCount"`
+}
+
+type packageFile struct {
+	pkg     *dice.StorePackage // 不含下载次数与首发时间，由查询时填充
+	path    string
+	version *semver.Version
+	modTime time.Time
+	size    int64
+}
+
+// Server 扩展商店后端
+type Server struct {
+	Dir    string
+	Logger *zap.SugaredLogger
+
+	lock      sync.RWMutex
+	config    Config
+	stats     Stats
+	files     map[string]*packageFile   // 文件路径 -> 包，用于增量扫描
+	versions  map[string][]*packageFile // 包 ID -> 各版本，新版本在前
+	scannedAt time.Time
+
+	ipExtractor echo.IPExtractor // 识别评分者 IP，由 Config.TrustedProxies 决定
+}
+
+// New 读取设置与统计数据并扫描一次扩展包目录
+func New(dir string, logger *zap.SugaredLogger) (*Server, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", dir)
+	}
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	s := &Server{
+		Dir:    dir,
+		Logger: logger,
+		stats:  Stats{Downloads: map[string]uint64{}, Ratings: map[string]map[string]int{}},
+		files:  map[string]*packageFile{},
+	}
+	if err = readJSONFile(filepath.Join(dir, ConfigFileName), &s.config); err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", ConfigFileName, err)
+	}
+	if err = readJSONFile(filepath.Join(dir, StatsFileName), &s.stats); err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", StatsFileName, err)
+	}
+	if s.stats.Downloads == nil {
+		s.stats.Downloads = map[string]uint64{}
+	}
+	if s.stats.Ratings == nil {
+		s.stats.Ratings = map[string]map[string]int{}
+	}
+	if s.config.Name == "" {
+		s.config.Name = "私有扩展商店"
+	}
+	if s.ipExtractor, err = newIPExtractor(s.config.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("%s 中 trustedProxies 有误: %w", ConfigFileName, err)
+	}
+	if err = s.Scan(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newIPExtractor 未配置代理时只认直连地址，否则仅信任所列代理转发的 X-Forwarded-For，防止伪造请求头刷评分
+func newIPExtractor(proxies []string) (echo.IPExtractor, error) {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的地址 %q", proxy)
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("无效的地址 %q", proxy)
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+func readJSONFile(name string, v any) error {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Scan 扫描目录(含子目录)下的全部 .sealpack，未变化的文件沿用上次的解析结果。
+// 无法解析的包只记录日志，不影响其他包。
+func (s *Server) Scan() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.scanLocked()
+}
+
+func (s *Server) scanLocked() error {
+	files := map[string]*packageFile{}
+	err := filepath.WalkDir(s.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != s.Dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(p), sealpack.Extension) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if old := s.files[p]; old != nil && old.modTime.Equal(info.ModTime()) && old.size == info.Size() {
+			files[p] = old
+			return nil
+		}
+		f, err := loadPackageFile(p, info)
+		if err != nil {
+			s.Logger.Warnf("商店: 跳过扩展包 %s: %v", p, err)
+			return nil
+		}
+		files[p] = f
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	versions := map[string][]*packageFile{}
+	for _, f := range files {
+		versions[f.pkg.ID] = append(versions[f.pkg.ID], f)
+	}
+	for id, list := range versions {
+		sort.Slice(list, func(i, j int) bool {
+			if c := list[i].version.Compare(list[j].version); c != 0 {
+				return c > 0
+			}
+			return list[i].modTime.After(list[j].modTime)
+		})
+		// 同一版本出现多次时只保留最新修改的文件
+		deduped := list[:0]
+		for _, f := range list {
+			if n := len(deduped); n > 0 && deduped[n-1].version.Equal(f.version) {
+				s.Logger.Warnf("商店: %s 与 %s 版本相同，已忽略前者", f.path, deduped[n-1].path)
+				continue
+			}
+			deduped = append(deduped, f)
+		}
+		versions[id] = deduped
+	}
+	s.files = files
+	s.versions = versions
+	s.scannedAt = time.Now()
+	return nil
+}
+
+func loadPackageFile(p string, info fs.FileInfo) (*packageFile, error) {
+	archive, err := sealpack.InspectArchive(p)
+	if err != nil {
+		return nil, err
+	}
+	m := archive.Manifest
+	if issues := sealpack.ValidateManifest(m); len(issues) > 0 {
+		return nil, errors.New(strings.Join(issues, "; "))
+	}
+	version, err := semver.NewVersion(m.Package.Version)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := fileSHA256(p)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, name, _ := sealpack.ParsePackageID(m.Package.ID)
+	roots := map[string]bool{}
+	for _, f := range archive.Files {
+		roots[strings.SplitN(f, "/", 2)[0]] = true
+	}
+	contents := []string{}
+	for _, kind := range contentKinds {
+		if roots[kind] {
+			contents = append(contents, kind)
+		}
+	}
+	dependencies := m.Dependencies
+	if dependencies == nil {
+		dependencies = map[string]string{}
+	}
+	storeAssets := m.Store
+	if storeAssets.Screenshots == nil {
+		storeAssets.Screenshots = []string{}
+	}
+	updateTime := uint64(info.ModTime().Unix()) //nolint:gosec // 文件时间不会早于 1970 年
+
+	return &packageFile{
+		pkg: &dice.StorePackage{
+			ID:            m.Package.ID,
+			FormatVersion: m.FormatVersion,
+			Version:       m.Package.Version,
+			Name:          m.Package.Name,
+			Authors:       nonNil(m.Package.Authors),
+			Description:   m.Package.Description,
+			License:       m.Package.License,
+			Homepage:      m.Package.Homepage,
+			Repository:    m.Package.Repository,
+			Keywords:      nonNil(m.Package.Keywords),
+			Contents:      contents,
+			Seal:          m.Package.Seal,
+			Dependencies:  dependencies,
+			StoreAssets:   storeAssets,
+			Download: dice.StorePackageDownload{
+				URL:        path.Join(BasePath, "packages", namespace, name, m.Package.Version, sealpack.PackageSourceFileName(m.Package.ID, m.Package.Version)),
+				Hash:       map[string]string{"sha256": hash},
+				Size:       uint64(info.Size()), //nolint:gosec // 文件大小非负
+				UpdateTime: updateTime,
+			},
+		},
+		path:    p,
+		version: version,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}, nil
+}
+
+func nonNil(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// refresh 距上次扫描超过 rescanInterval 时重新扫描，新放入目录的包无需重启即可上架
+func (s *Server) refresh() {
+	s.lock.RLock()
+	fresh := time.Since(s.scannedAt) < rescanInterval
+	s.lock.RUnlock()
+	if fresh {
+		return
+	}
+	if err := s.Scan(); err != nil {
+		s.Logger.Errorf("商店: 扫描扩展包目录失败: %v", err)
+	}
+}
+
+// packageLocked 组装对外返回的包信息，首发时间取该包最早版本的文件时间
+func (s *Server) packageLocked(f *packageFile) *Package {
+	pkg := *f.pkg
+	list := s.versions[pkg.ID]
+	pkg.Download.ReleaseTime = pkg.Download.UpdateTime
+	if n := len(list); n > 0 {
+		pkg.Download.ReleaseTime = list[n-1].pkg.Download.UpdateTime
+	}
+	pkg.Download.DownloadCount = s.stats.Downloads[pkg.ID]
+	result := &Package{StorePackage: &pkg}
+	result.Rating, result.RatingCount = s.ratingLocked(pkg.ID)
+	return result
+}
+
+func (s *Server) ratingLocked(id string) (float64, int) {
+	votes := s.stats.Ratings[id]
+	if len(votes) == 0 {
+		return 0, 0
+	}
+	sum := 0
+	for _, score := range votes {
+		sum += score
+	}
+	return float64(sum) / float64(len(votes)), len(votes)
+}
+
+// latestLocked 每个包的最新版本
+func (s *Server) latestLocked() []*Package {
+	result := make([]*Package, 0, len(s.versions))
+	for _, list := range s.versions {
+		result = append(result, s.packageLocked(list[0]))
+	}
+	return result
+}
+
+func (s *Server) findLocked(namespace, name, version string) *packageFile {
+	for _, f := range s.versions[namespace+"/"+name] {
+		if f.pkg.Version == version {
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *Server) saveStatsLocked() error {
+	data, err := json.MarshalIndent(s.stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(s.Dir, StatsFileName)
+	tmp := target + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// Register 把商店接口挂载到 BasePath 下
+func (s *Server) Register(e *echo.Echo) {
+	g := e.Group(BasePath)
+	g.GET("/info", s.info)
+	g.GET("/recommend", s.recommend)
+	g.GET("/page", s.page)
+	g.GET("/versions/:namespace/:package", s.versionList)
+	g.GET("/files/:namespace/:package/:version", s.fileList)
+	g.GET("/file/:namespace/:package/:version", s.filePreview)
+	g.GET("/packages/:namespace/:package/:version/:file", s.download)
+	g.POST("/rate", s.rate)
+}
+
+func fail(c echo.Context, err string) error {
+	return c.JSON(http.StatusOK, map[string]any{"result": false, "err": err})
+}
+
+func success(c echo.Context, data any) error {
+	return c.JSON(http.StatusOK, map[string]any{"result": true, "data": data, "err": ""})
+}
+
+func (s *Server) info(c echo.Context) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return c.JSON(http.StatusOK, map[string]any{
+		"name":             s.config.Name,
+		"protocolVersions": []string{ProtocolVersion},
+		"announcement":     s.config.Announcement,
+		"sign":             s.config.Sign,
+	})
+}
+
+func (s *Server) recommend(c echo.Context) error {
+	s.refresh()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	result := []*Package{}
+	if len(s.config.Recommend) > 0 {
+		for _, id := range s.config.Recommend {
+			if list := s.versions[id]; len(list) > 0 {
+				result = append(result, s.packageLocked(list[0]))
+			}
+		}
+		return success(c, result)
+	}
+	result = s.latestLocked()
+	sortPackages(result, "downloadCount", "desc")
+	if len(result) > recommendMax {
+		result = result[:recommendMax]
+	}
+	return success(c, result)
+}
+
+// page 分页查询。name 同时匹配名称、ID、简介与关键词，author 与 name 均为不区分大小写的子串匹配
+func (s *Server) page(c echo.Context) error {
+	var params dice.StoreQueryPageParams
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil {
+		return fail(c, "查询参数错误: "+err.Error())
+	}
+	if params.PageNum <= 0 {
+		params.PageNum = 1
+	}
+	if params.PageSize <= 0 {
+		params.PageSize = pageSizeDefault
+	}
+	params.PageSize = min(params.PageSize, pageSizeMax)
+	switch params.SortBy {
+	case "", "updateTime", "releaseTime", "downloadCount", "name", "rating":
+	default:
+		return fail(c, "不支持的排序方式: "+params.SortBy)
+	}
+
+	s.refresh()
+	s.lock.RLock()
+	all := s.latestLocked()
+	s.lock.RUnlock()
+
+	matched := make([]*Package, 0, len(all))
+	for _, pkg := range all {
+		if matchPackage(pkg.StorePackage, &params) {
+			matched = append(matched, pkg)
+		}
+	}
+	sortPackages(matched, params.SortBy, params.Order)
+
+	start := min((params.PageNum-1)*params.PageSize, len(matched))
+	end := min(start+params.PageSize, len(matched))
+	return success(c, map[string]any{
+		"data":     matched[start:end],
+		"pageNum":  params.PageNum,
+		"pageSize": params.PageSize,
+		"next":     end < len(matched),
+	})
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func matchPackage(pkg *dice.StorePackage, params *dice.StoreQueryPageParams) bool {
+	if params.Content != "" {
+		found := false
+		for _, kind := range pkg.Contents {
+			found = found || kind == params.Content
+		}
+		if !found {
+			return false
+		}
+	}
+	if params.Category != "" && !strings.EqualFold(pkg.StoreAssets.Category, params.Category) {
+		return false
+	}
+	if author := strings.TrimSpace(params.Author); author != "" {
+		found := false
+		for _, a := range pkg.Authors {
+			found = found || containsFold(a, author)
+		}
+		if !found {
+			return false
+		}
+	}
+	if keyword := strings.TrimSpace(params.Name); keyword != "" {
+		found := containsFold(pkg.Name, keyword) || containsFold(pkg.ID, keyword) || containsFold(pkg.Description, keyword)
+		for _, k := range pkg.Keywords {
+			found = found || containsFold(k, keyword)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sortPackages 未指定 order 时，名称升序，其余字段降序
+func sortPackages(list []*Package, sortBy, order string) {
+	if sortBy == "" {
+		sortBy = "updateTime"
+	}
+	desc := order == "desc" || (order == "" && sortBy != "name")
+	key := func(p *Package) float64 {
+		switch sortBy {
+		case "releaseTime":
+			return float64(p.Download.ReleaseTime)
+		case "downloadCount":
+			return float64(p.Download.DownloadCount)
+		case "rating":
+			return p.Rating
+		default:
+			return float64(p.Download.UpdateTime)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if desc {
+			a, b = b, a
+		}
+		if sortBy == "name" {
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		} else if ka, kb := key(a), key(b); ka != kb {
+			return ka < kb
+		}
+		return list[i].ID < list[j].ID
+	})
+}
+
+// versionList 包的全部版本，新版本在前，供客户端解析依赖时选择版本
+func (s *Server) versionList(c echo.Context) error {
+	s.refresh()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	list := s.versions[c.Param("namespace")+"/"+c.Param("package")]
+	if len(list) == 0 {
+		return fail(c, "扩展包不存在")
+	}
+	result := make([]*Package, 0, len(list))
+	for _, f := range list {
+		result = append(result, s.packageLocked(f))
+	}
+	return success(c, result)
+}
+
+func (s *Server) lookup(c echo.Context) *packageFile {
+	s.refresh()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.findLocked(c.Param("namespace"), c.Param("package"), c.Param("version"))
+}
+
+func (s *Server) fileList(c echo.Context) error {
+	f := s.lookup(c)
+	if f == nil {
+		return fail(c, "扩展包不存在")
+	}
+	reader, err := zip.OpenReader(f.path)
+	if err != nil {
+		return fail(c, err.Error())
+	}
+	defer reader.Close()
+	entries := make([]dice.StorePackageFileEntry, 0, len(reader.File))
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, dice.StorePackageFileEntry{Path: file.Name, Size: file.UncompressedSize64})
+	}
+	return success(c, entries)
+}
+
+func (s *Server) filePreview(c echo.Context) error {
+	filePath := c.QueryParam("path")
+	if err := sealpack.ValidateRelativePackagePath(filePath); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	f := s.lookup(c)
+	if f == nil {
+		return c.String(http.StatusNotFound, "扩展包不存在")
+	}
+	reader, err := zip.OpenReader(f.path)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	defer reader.Close()
+	for _, file := range reader.File {
+		if file.Name != filePath || file.FileInfo().IsDir() {
+			continue
+		}
+		if file.UncompressedSize64 > previewSizeMax {
+			return c.String(http.StatusRequestEntityTooLarge, "文件过大，无法预览")
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		// 声明的大小可被篡改，读取时同样限制
+		data, err := io.ReadAll(io.LimitReader(rc, previewSizeMax+1))
+		_ = rc.Close()
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		if len(data) > previewSizeMax {
+			return c.String(http.StatusRequestEntityTooLarge, "文件过大，无法预览")
+		}
+		contentType := mime.TypeByExtension(path.Ext(filePath))
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		return c.Blob(http.StatusOK, contentType, data)
+	}
+	return c.String(http.StatusNotFound, "文件不存在")
+}
+
+func (s *Server) download(c echo.Context) error {
+	f := s.lookup(c)
+	if f == nil || c.Param("file") != sealpack.PackageSourceFileName(f.pkg.ID, f.pkg.Version) {
+		return c.String(http.StatusNotFound, "扩展包不存在")
+	}
+	s.lock.Lock()
+	s.stats.Downloads[f.pkg.ID]++
+	if err := s.saveStatsLocked(); err != nil {
+		s.Logger.Errorf("商店: 保存统计数据失败: %v", err)
+	}
+	s.lock.Unlock()
+	return c.Attachment(f.path, c.Param("file"))
+}
+
+// rate 为包评分，score 为 1~5，同一 IP 重复评分时覆盖之前的分数。IP 只在请求经由 trustedProxies 转发时才取自 X-Forwarded-For
+func (s *Server) rate(c echo.Context) error {
+	v := struct {
+		ID    string `json:"id"`
+		Score int    `json:"score"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return fail(c, err.Error())
+	}
+	if v.Score < 1 || v.Score > 5 {
+		return fail(c, "评分须为 1~5 的整数")
+	}
+	s.refresh()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.versions[v.ID]) == 0 {
+		return fail(c, "扩展包不存在")
+	}
+	votes := s.stats.Ratings[v.ID]
+	if votes == nil {
+		votes = map[string]int{}
+		s.stats.Ratings[v.ID] = votes
+	}
+	votes[s.ipExtractor(c.Request())] = v.Score
+	if err := s.saveStatsLocked(); err != nil {
+		return fail(c, "保存评分失败: "+err.Error())
+	}
+	rating, count := s.ratingLocked(v.ID)
+	return success(c, map[string]any{"rating": rating, "ratingCount": count})
+}
+
+// Serve 在 addr 上启动商店服务，直到出错才返回
+func Serve(dir, addr string, logger *zap.SugaredLogger) error {
+	s, err := New(dir, logger)
+	if err != nil {
+		return err
+	}
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.IPExtractor = s.ipExtractor
+	s.Register(e)
+
+	s.lock.RLock()
+	count := len(s.versions)
+	s.lock.RUnlock()
+	s.Logger.Infof("扩展商店已启动: http://%s%s，共 %d 个扩展包，目录 %s", addr, BasePath, count, dir)
+	return e.Start(addr)
+}
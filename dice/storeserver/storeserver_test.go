@@ -0,0 +1,290 @@
+package storeserver //nolint:testpackage
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+)
+
+func writePackForTest(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	file, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%s) error = %v", name, err)
+		}
+		if _, err = w.Write([]byte(body)); err != nil {
+			t.Fatalf("Write(%s) error = %v", name, err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return p
+}
+
+func manifestForTest(id, name, version, extra string) string {
+	return "[package]\n" +
+		"id = \"" + id + "\"\n" +
+		"name = \"" + name + "\"\n" +
+		"version = \"" + version + "\"\n" +
+		extra
+}
+
+func newTestStore(t *testing.T) (string, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	packs := map[string]map[string]string{
+		"demo@1.0.0.sealpack": {
+			"info.toml":       manifestForTest("alice/demo", "Demo", "1.0.0", ""),
+			"scripts/main.js": "// v1",
+		},
+		"nested/demo@1.1.0.sealpack": {
+			"info.toml": manifestForTest("alice/demo", "Demo", "1.1.0",
+				"authors = [\"Alice\"]\nkeywords = [\"coc\"]\n[store]\ncategory = \"rules\"\nicon = \"assets/icon.png\"\n"),
+			"scripts/main.js": "// v2",
+			"assets/icon.png": "png-data",
+		},
+		"tool@0.1.0.sealpack": {
+			"info.toml":       manifestForTest("bob/tool", "Tool Box", "0.1.0", "authors = [\"Bob\"]\n"),
+			"decks/tool.json": "{}",
+			"decks/big.json":  strings.Repeat("a", previewSizeMax+1),
+		},
+		"broken.sealpack": {
+			"scripts/main.js": "// 缺少 info.toml",
+		},
+	}
+	for name, files := range packs {
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.Rename(writePackForTest(t, filepath.Base(name), files), target); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(`{"name":"社群商店","recommend":["bob/tool"]}`), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	s, err := New(dir, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	e := echo.New()
+	s.Register(e)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return dir, server
+}
+
+func queryPage(t *testing.T, server *httptest.Server, query string) []Package {
+	t.Helper()
+	resp, err := http.Get(server.URL + BasePath + "/page?" + query)
+	if err != nil {
+		t.Fatalf("GET /page error = %v", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Result bool `json:"result"`
+		Data   struct {
+			Data []Package `json:"data"`
+		} `json:"data"`
+		Err string `json:"err"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode /page error = %v", err)
+	}
+	if !result.Result {
+		t.Fatalf("/page?%s err = %s", query, result.Err)
+	}
+	return result.Data.Data
+}
+
+func TestStoreServerWithStoreManager(t *testing.T) {
+	dir, server := newTestStore(t)
+	manager := dice.NewStoreManager(&dice.Dice{Config: dice.Config{StoreConfig: dice.StoreConfig{
+		BackendUrls: []string{server.URL + BasePath},
+	}}})
+
+	backends := manager.StoreBackendList()
+	if len(backends) < 2 || backends[1].Name != "社群商店" || !backends[1].Health {
+		t.Fatalf("backends = %#v", backends)
+	}
+
+	recommend, err := manager.StoreQueryRecommend()
+	if err != nil || len(recommend) != 1 || recommend[0].ID != "bob/tool" {
+		t.Fatalf("StoreQueryRecommend() = %v, %v", recommend, err)
+	}
+
+	page, err := manager.StoreQueryPage(dice.StoreQueryPageParams{Name: "COC"})
+	if err != nil {
+		t.Fatalf("StoreQueryPage() error = %v", err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Version != "1.1.0" || page.Next {
+		t.Fatalf("page = %#v", page)
+	}
+	pkg := page.Data[0]
+	if strings.Join(pkg.Contents, ",") != "scripts" || pkg.StoreAssets.Category != "rules" {
+		t.Fatalf("package = %#v", pkg)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "nested", "demo@1.1.0.sealpack"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if pkg.Download.Hash["sha256"] != hex.EncodeToString(sum[:]) || pkg.Download.Size != uint64(len(data)) {
+		t.Fatalf("download = %#v", pkg.Download)
+	}
+	resolved, err := manager.ResolvePackage("alice/demo", "1.0.0")
+	if err != nil {
+		t.Fatalf("ResolvePackage() error = %v", err)
+	}
+	versions, err := manager.StoreQueryPackageVersions("alice/demo")
+	if err != nil || len(versions) != 2 || versions[0].Version != "1.1.0" || versions[1].Version != "1.0.0" {
+		t.Fatalf("StoreQueryPackageVersions() = %v, %v", versions, err)
+	}
+
+	for _, u := range []string{pkg.Download.URL, resolved.Download.URL} {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", u, err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s status = %d", u, resp.StatusCode)
+		}
+	}
+	stats := Stats{}
+	if err = readJSONFile(filepath.Join(dir, StatsFileName), &stats); err != nil || stats.Downloads["alice/demo"] != 2 {
+		t.Fatalf("stats = %#v, %v", stats, err)
+	}
+
+	files, err := manager.StoreQueryPackageFiles("alice", "demo", "1.1.0")
+	if err != nil || len(files) != 3 {
+		t.Fatalf("StoreQueryPackageFiles() = %v, %v", files, err)
+	}
+	preview, err := manager.StorePreviewPackageFile(context.Background(), "alice", "demo", "1.1.0", "assets/icon.png")
+	if err != nil {
+		t.Fatalf("StorePreviewPackageFile() error = %v", err)
+	}
+	defer preview.Body.Close()
+	body, _ := io.ReadAll(preview.Body)
+	if preview.Header.Get("Content-Type") != "image/png" || string(body) != "png-data" {
+		t.Fatalf("preview = %s %q", preview.Header.Get("Content-Type"), body)
+	}
+
+	manifest, err := manager.StoreQueryPackageManifest(context.Background(), "alice/demo", "1.0.0")
+	if err != nil || manifest.Package.Name != "Demo" {
+		t.Fatalf("StoreQueryPackageManifest() = %v, %v", manifest, err)
+	}
+}
+
+func TestStoreServerSearchAndRating(t *testing.T) {
+	_, server := newTestStore(t)
+
+	if got := queryPage(t, server, "category=RULES"); len(got) != 1 || got[0].ID != "alice/demo" {
+		t.Fatalf("category filter = %v", got)
+	}
+	if got := queryPage(t, server, "content=decks"); len(got) != 1 || got[0].ID != "bob/tool" {
+		t.Fatalf("content filter = %v", got)
+	}
+	if got := queryPage(t, server, "author=bo"); len(got) != 1 || got[0].ID != "bob/tool" {
+		t.Fatalf("author filter = %v", got)
+	}
+	if got := queryPage(t, server, "sortBy=name"); len(got) != 2 || got[0].Name != "Demo" {
+		t.Fatalf("name sort = %v", got)
+	}
+	if got := queryPage(t, server, "pageSize=1&pageNum=2&sortBy=name"); len(got) != 1 || got[0].Name != "Tool Box" {
+		t.Fatalf("second page = %v", got)
+	}
+
+	rate := func(id string, score int, forwardedFor string) bool {
+		data, _ := json.Marshal(map[string]any{"id": id, "score": score})
+		req, _ := http.NewRequest(http.MethodPost, server.URL+BasePath+"/rate", strings.NewReader(string(data)))
+		req.Header.Set("Content-Type", "application/json")
+		if forwardedFor != "" {
+			req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /rate error = %v", err)
+		}
+		defer resp.Body.Close()
+		var result struct {
+			Result bool `json:"result"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return result.Result
+	}
+	if rate("bob/tool", 6, "") || rate("nobody/none", 3, "") {
+		t.Fatal("invalid ratings should be rejected")
+	}
+	// 同一来源再次评分时覆盖之前的分数，未配置 trustedProxies 时伪造的 X-Forwarded-For 不算新来源
+	if !rate("bob/tool", 2, "") || !rate("bob/tool", 3, "1.2.3.4") || !rate("bob/tool", 4, "5.6.7.8") {
+		t.Fatal("rating should succeed")
+	}
+	got := queryPage(t, server, "sortBy=rating")
+	if len(got) != 2 || got[0].ID != "bob/tool" || got[0].Rating != 4 || got[0].RatingCount != 1 {
+		t.Fatalf("rating sort = %v", got)
+	}
+
+	resp, err := http.Get(server.URL + BasePath + "/packages/alice/demo/1.1.0/other.sealpack")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("non-canonical download status = %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + BasePath + "/file/bob/tool/0.1.0?path=decks/big.json")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized preview status = %d", resp.StatusCode)
+	}
+}
+
+func TestNewIPExtractor(t *testing.T) {
+	extract, err := newIPExtractor([]string{"10.0.0.1", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatalf("newIPExtractor() error = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(echo.HeaderXForwardedFor, "1.2.3.4")
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got := extract(req); got != "1.2.3.4" {
+		t.Fatalf("trusted proxy ip = %s", got)
+	}
+	req.RemoteAddr = "10.0.0.2:1234"
+	if got := extract(req); got != "10.0.0.2" {
+		t.Fatalf("untrusted proxy ip = %s", got)
+	}
+	if _, err = newIPExtractor([]string{"not-an-ip"}); err == nil {
+		t.Fatal("invalid proxy should be rejected")
+	}
+}
@@ -0,0 +1,401 @@
+package dice
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// WebUIRole WebUI 账号的角色，决定该账号拥有的权限集合
+type WebUIRole string
+
+const (
+	WebUIRoleViewer        WebUIRole = "viewer"         // 只读查看
+	WebUIRoleLogReader     WebUIRole = "log_reader"     // 可查看、下载跑团日志
+	WebUIRoleContentEditor WebUIRole = "content_editor" // 可编辑牌堆、自定义回复、帮助文档、自定义文案
+	WebUIRoleAdmin         WebUIRole = "admin"          // 全部权限
+)
+
+// WebUIPermission WebUI 接口权限
+type WebUIPermission string
+
+const (
+	WebUIPermView             WebUIPermission = "view"              // 查看状态、列表、配置
+	WebUIPermLogRead          WebUIPermission = "log.read"          // 查看和下载跑团日志
+	WebUIPermContentEdit      WebUIPermission = "content.edit"      // 牌堆、自定义回复、帮助文档、自定义文案
+	WebUIPermConfigEdit       WebUIPermission = "config.edit"       // 修改骰子设置、黑名单、群组、拦截
+	WebUIPermConnectionManage WebUIPermission = "connection.manage" // 增删改账号连接
+	WebUIPermExtensionManage  WebUIPermission = "extension.manage"  // JS 插件、扩展包、扩展商店
+	WebUIPermSystemManage     WebUIPermission = "system.manage"     // 升级、备份、指令测试、调试
+	WebUIPermAccountManage    WebUIPermission = "account.manage"    // 管理 WebUI 账号与令牌
+	WebUIPermAuditRead        WebUIPermission = "audit.read"        // 查看和导出审计记录
+)
+
+const (
+	// WebUIBuiltinAdminName 使用 UI 密码（或未设密码）登录时的身份，账号名不允许包含 @ 因此不会冲突
+	WebUIBuiltinAdminName = "@admin"
+
+	webUIAPITokenPrefix           = "sdat_"
+	webUIAPITokenRandomLength     = 48
+	webUISessionTokenRandomLength = 64
+)
+
+// WebUIAllPermissions 全部权限，顺序即前端展示顺序
+var WebUIAllPermissions = []WebUIPermission{
+	WebUIPermView,
+	WebUIPermLogRead,
+	WebUIPermContentEdit,
+	WebUIPermConfigEdit,
+	WebUIPermConnectionManage,
+	WebUIPermExtensionManage,
+	WebUIPermSystemManage,
+	WebUIPermAccountManage,
+	WebUIPermAuditRead,
+}
+
+// WebUIRolePermissions 各角色的权限集合
+var WebUIRolePermissions = map[WebUIRole][]WebUIPermission{
+	WebUIRoleViewer:        {WebUIPermView},
+	WebUIRoleLogReader:     {WebUIPermView, WebUIPermLogRead},
+	WebUIRoleContentEditor: {WebUIPermView, WebUIPermLogRead, WebUIPermContentEdit},
+	WebUIRoleAdmin:         WebUIAllPermissions,
+}
+
+var webUIAccountNameRe = regexp.MustCompile(`^[A-Za-z0-9_.\-\p{Han}]{1,32}$`)
+
+var (
+	ErrWebUIAccountNotFound    = errors.New("账号不存在")
+	ErrWebUIAccountExists      = errors.New("账号已存在")
+	ErrWebUIAccountInvalidName = errors.New("账号名只能包含字母、数字、汉字、下划线、点和横线，且不超过32个字符")
+	ErrWebUIAccountInvalidRole = errors.New("未知的角色")
+	ErrWebUIAccountEmptyPass   = errors.New("密码不能为空")
+	ErrWebUIAPITokenNotFound   = errors.New("令牌不存在")
+	ErrWebUIAccountLastAdmin   = errors.New("不能移除最后一个管理员账号，除非已设置 UI 密码")
+	ErrWebUIAccountNeedAdmin   = errors.New("未设置 UI 密码时，第一个账号必须是管理员")
+	ErrWebUIAccountWrongPass   = errors.New("账号或密码错误")
+	ErrWebUIAccountDisabled    = errors.New("账号已停用")
+)
+
+func (r WebUIRole) Valid() bool {
+	_, ok := WebUIRolePermissions[r]
+	return ok
+}
+
+// Permissions 返回角色拥有的权限
+func (r WebUIRole) Permissions() []WebUIPermission {
+	return WebUIRolePermissions[r]
+}
+
+// Can 角色是否拥有某项权限
+func (r WebUIRole) Can(perm WebUIPermission) bool {
+	return slices.Contains(WebUIRolePermissions[r], perm)
+}
+
+// WebUIAPIToken 供自动化脚本使用的长期令牌，仅保存哈希
+type WebUIAPIToken struct {
+	ID         string `json:"id"         yaml:"id"`
+	Name       string `json:"name"       yaml:"name"`
+	TokenHash  string `json:"-"          yaml:"tokenHash"`
+	CreatedAt  int64  `json:"createdAt"  yaml:"createdAt"`
+	LastUsedAt int64  `json:"lastUsedAt" yaml:"lastUsedAt"`
+}
+
+// WebUIAccount WebUI 账号
+type WebUIAccount struct {
+	Username     string           `json:"username"  yaml:"username"`
+	Role         WebUIRole        `json:"role"      yaml:"role"`
+	PasswordHash string           `json:"-"         yaml:"passwordHash"` // 与 UIPasswordHash 相同，为前端加盐后的哈希
+	Disabled     bool             `json:"disabled"  yaml:"disabled"`
+	CreatedAt    int64            `json:"createdAt" yaml:"createdAt"`
+	APITokens    []*WebUIAPIToken `json:"apiTokens" yaml:"apiTokens"`
+}
+
+// WebUIIdentity 一次请求所对应的身份
+type WebUIIdentity struct {
+	Username    string    `json:"username"`
+	Role        WebUIRole `json:"role"`
+	ViaAPIToken bool      `json:"viaApiToken"`
+	TokenID     string    `json:"tokenId,omitempty"` // 通过长期令牌访问时的令牌 ID 与名称，用于审计
+	TokenName   string    `json:"tokenName,omitempty"`
+}
+
+// AuditActorName 审计记录中的操作者名称，长期令牌调用时注明令牌及其所属账号
+func (i *WebUIIdentity) AuditActorName() string {
+	if i.ViaAPIToken {
+		return fmt.Sprintf("%s(令牌 %s/%s)", i.Username, i.TokenName, i.TokenID)
+	}
+	return i.Username
+}
+
+func (i *WebUIIdentity) Can(perm WebUIPermission) bool {
+	return i != nil && i.Role.Can(perm)
+}
+
+func hashWebUIAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// WebUIAccountList 返回账号列表的副本
+func (dm *DiceManager) WebUIAccountList() []WebUIAccount {
+	dm.webUIAccountsLock.RLock()
+	defer dm.webUIAccountsLock.RUnlock()
+	ret := make([]WebUIAccount, 0, len(dm.WebUIAccounts))
+	for _, i := range dm.WebUIAccounts {
+		ret = append(ret, *i)
+	}
+	return ret
+}
+
+func (dm *DiceManager) webUIAccountFind(username string) *WebUIAccount {
+	for _, i := range dm.WebUIAccounts {
+		if i.Username == username {
+			return i
+		}
+	}
+	return nil
+}
+
+// webUIHasOtherAdmin 除 username 外是否还有可用的管理员入口
+func (dm *DiceManager) webUIHasOtherAdmin(username string) bool {
+	if dm.UIPasswordHash != "" {
+		return true
+	}
+	for _, i := range dm.WebUIAccounts {
+		if i.Username != username && i.Role == WebUIRoleAdmin && !i.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// WebUIAccountAdd 新增账号，passwordHash 为前端加盐哈希后的密码
+func (dm *DiceManager) WebUIAccountAdd(username string, role WebUIRole, passwordHash string) error {
+	if !webUIAccountNameRe.MatchString(username) {
+		return ErrWebUIAccountInvalidName
+	}
+	if !role.Valid() {
+		return ErrWebUIAccountInvalidRole
+	}
+	if passwordHash == "" {
+		return ErrWebUIAccountEmptyPass
+	}
+
+	dm.webUIAccountsLock.Lock()
+	defer dm.webUIAccountsLock.Unlock()
+	if dm.webUIAccountFind(username) != nil {
+		return ErrWebUIAccountExists
+	}
+	if role != WebUIRoleAdmin && !dm.webUIHasOtherAdmin(username) {
+		// 一旦存在账号，空 UI 密码就不再能登录，必须保证仍有管理员入口
+		return ErrWebUIAccountNeedAdmin
+	}
+	dm.WebUIAccounts = append(dm.WebUIAccounts, &WebUIAccount{
+		Username:     username,
+		Role:         role,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now().Unix(),
+	})
+	return nil
+}
+
+// WebUIAccountUpdate 修改账号，nil 参数表示不修改。角色变化或停用时会注销该账号的登录会话
+func (dm *DiceManager) WebUIAccountUpdate(username string, role *WebUIRole, passwordHash *string, disabled *bool) error {
+	if role != nil && !role.Valid() {
+		return ErrWebUIAccountInvalidRole
+	}
+	if passwordHash != nil && *passwordHash == "" {
+		return ErrWebUIAccountEmptyPass
+	}
+
+	dm.webUIAccountsLock.Lock()
+	defer dm.webUIAccountsLock.Unlock()
+	account := dm.webUIAccountFind(username)
+	if account == nil {
+		return ErrWebUIAccountNotFound
+	}
+	losesAdmin := (role != nil && *role != WebUIRoleAdmin) || (disabled != nil && *disabled)
+	if account.Role == WebUIRoleAdmin && losesAdmin && !dm.webUIHasOtherAdmin(username) {
+		return ErrWebUIAccountLastAdmin
+	}
+
+	kick := false
+	if role != nil && *role != account.Role {
+		account.Role = *role
+		kick = true
+	}
+	if passwordHash != nil {
+		account.PasswordHash = *passwordHash
+		kick = true
+	}
+	if disabled != nil && *disabled != account.Disabled {
+		account.Disabled = *disabled
+		kick = true
+	}
+	if kick {
+		dm.revokeSessionsOf(username)
+	}
+	return nil
+}
+
+// WebUIAccountDelete 删除账号及其全部会话、令牌
+func (dm *DiceManager) WebUIAccountDelete(username string) error {
+	dm.webUIAccountsLock.Lock()
+	defer dm.webUIAccountsLock.Unlock()
+	account := dm.webUIAccountFind(username)
+	if account == nil {
+		return ErrWebUIAccountNotFound
+	}
+	if account.Role == WebUIRoleAdmin && !dm.webUIHasOtherAdmin(username) {
+		return ErrWebUIAccountLastAdmin
+	}
+	dm.WebUIAccounts = slices.DeleteFunc(dm.WebUIAccounts, func(i *WebUIAccount) bool {
+		return i.Username == username
+	})
+	dm.revokeSessionsOf(username)
+	return nil
+}
+
+// WebUIAccountCreateAPIToken 为账号签发长期令牌，明文只在此处返回一次
+func (dm *DiceManager) WebUIAccountCreateAPIToken(username string, name string) (string, *WebUIAPIToken, error) {
+	dm.webUIAccountsLock.Lock()
+	defer dm.webUIAccountsLock.Unlock()
+	account := dm.webUIAccountFind(username)
+	if account == nil {
+		return "", nil, ErrWebUIAccountNotFound
+	}
+	plain := webUIAPITokenPrefix + RandStringBytesMaskImprSrcSB2(webUIAPITokenRandomLength)
+	token := &WebUIAPIToken{
+		ID:        RandStringBytesMaskImprSrcSB2(12),
+		Name:      name,
+		TokenHash: hashWebUIAPIToken(plain),
+		CreatedAt: time.Now().Unix(),
+	}
+	account.APITokens = append(account.APITokens, token)
+	return plain, token, nil
+}
+
+// WebUIAccountRevokeAPIToken 吊销账号的某个长期令牌
+func (dm *DiceManager) WebUIAccountRevokeAPIToken(username string, tokenID string) error {
+	dm.webUIAccountsLock.Lock()
+	defer dm.webUIAccountsLock.Unlock()
+	account := dm.webUIAccountFind(username)
+	if account == nil {
+		return ErrWebUIAccountNotFound
+	}
+	n := len(account.APITokens)
+	account.APITokens = slices.DeleteFunc(account.APITokens, func(i *WebUIAPIToken) bool {
+		return i.ID == tokenID
+	})
+	if n == len(account.APITokens) {
+		return ErrWebUIAPITokenNotFound
+	}
+	return nil
+}
+
+// WebUISignIn 登录并签发会话令牌。username 为空时使用 UI 密码登录，获得内置管理员身份
+func (dm *DiceManager) WebUISignIn(username string, passwordHash string) (string, error) {
+	owner := WebUIBuiltinAdminName
+	if username == "" {
+		if dm.UIPasswordHash == "" {
+			// 未设置 UI 密码时任何人都能以管理员身份进入，但建立账号后就必须使用账号登录
+			dm.webUIAccountsLock.RLock()
+			hasAccount := len(dm.WebUIAccounts) > 0
+			dm.webUIAccountsLock.RUnlock()
+			if hasAccount {
+				return "", ErrWebUIAccountWrongPass
+			}
+		} else if subtle.ConstantTimeCompare([]byte(dm.UIPasswordHash), []byte(passwordHash)) != 1 {
+			return "", ErrWebUIAccountWrongPass
+		}
+	} else {
+		dm.webUIAccountsLock.RLock()
+		account := dm.webUIAccountFind(username)
+		var ok, disabled bool
+		if account != nil {
+			ok = subtle.ConstantTimeCompare([]byte(account.PasswordHash), []byte(passwordHash)) == 1
+			disabled = account.Disabled
+		}
+		dm.webUIAccountsLock.RUnlock()
+		if !ok {
+			return "", ErrWebUIAccountWrongPass
+		}
+		if disabled {
+			return "", ErrWebUIAccountDisabled
+		}
+		owner = username
+	}
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(time.Now().Unix()))
+	head := hex.EncodeToString(buf)
+	token := RandStringBytesMaskImprSrcSB2(webUISessionTokenRandomLength) + ":" + head
+	dm.AccessTokens.Store(token, true)
+	if owner != WebUIBuiltinAdminName {
+		dm.AccessTokenOwners.Store(token, owner)
+	}
+	return token, nil
+}
+
+// WebUIResolveToken 将会话令牌或长期令牌解析为身份
+func (dm *DiceManager) WebUIResolveToken(token string) (*WebUIIdentity, bool) {
+	if token == "" {
+		return nil, false
+	}
+	if dm.AccessTokens.Exists(token) {
+		owner, ok := dm.AccessTokenOwners.Load(token)
+		if !ok {
+			// 旧版令牌与 UI 密码登录的令牌
+			return &WebUIIdentity{Username: WebUIBuiltinAdminName, Role: WebUIRoleAdmin}, true
+		}
+		dm.webUIAccountsLock.RLock()
+		defer dm.webUIAccountsLock.RUnlock()
+		account := dm.webUIAccountFind(owner)
+		if account == nil || account.Disabled {
+			return nil, false
+		}
+		return &WebUIIdentity{Username: account.Username, Role: account.Role}, true
+	}
+
+	if len(token) <= len(webUIAPITokenPrefix) || token[:len(webUIAPITokenPrefix)] != webUIAPITokenPrefix {
+		return nil, false
+	}
+	hash := hashWebUIAPIToken(token)
+	dm.webUIAccountsLock.Lock()
+	defer dm.webUIAccountsLock.Unlock()
+	for _, account := range dm.WebUIAccounts {
+		if account.Disabled {
+			continue
+		}
+		for _, t := range account.APITokens {
+			if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(hash)) == 1 {
+				t.LastUsedAt = time.Now().Unix()
+				return &WebUIIdentity{Username: account.Username, Role: account.Role, ViaAPIToken: true, TokenID: t.ID, TokenName: t.Name}, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ResetAccessTokens 注销全部登录会话（长期令牌不受影响）
+func (dm *DiceManager) ResetAccessTokens() {
+	dm.AccessTokens = SyncMap[string, bool]{}
+	dm.AccessTokenOwners = SyncMap[string, string]{}
+}
+
+// revokeSessionsOf 注销某账号的全部登录会话，调用者需持有 webUIAccountsLock
+func (dm *DiceManager) revokeSessionsOf(username string) {
+	dm.AccessTokenOwners.Range(func(token string, owner string) bool {
+		if owner == username {
+			dm.AccessTokens.Delete(token)
+			dm.AccessTokenOwners.Delete(token)
+		}
+		return true
+	})
+}
@@ -0,0 +1,87 @@
+package dice_test
+
+import (
+	"errors"
+	"testing"
+
+	"sealdice-core/dice"
+)
+
+func TestWebUIAccountSignInAndResolve(t *testing.T) {
+	dm := &dice.DiceManager{UIPasswordHash: "ui-hash"}
+	if err := dm.WebUIAccountAdd("gm", dice.WebUIRoleContentEditor, "gm-hash"); err != nil {
+		t.Fatalf("WebUIAccountAdd() error = %v", err)
+	}
+
+	if _, err := dm.WebUISignIn("gm", "wrong"); !errors.Is(err, dice.ErrWebUIAccountWrongPass) {
+		t.Fatalf("WebUISignIn() with wrong password error = %v", err)
+	}
+	token, err := dm.WebUISignIn("gm", "gm-hash")
+	if err != nil {
+		t.Fatalf("WebUISignIn() error = %v", err)
+	}
+	identity, ok := dm.WebUIResolveToken(token)
+	if !ok || identity.Username != "gm" {
+		t.Fatalf("WebUIResolveToken() = %+v, %v", identity, ok)
+	}
+	if !identity.Can(dice.WebUIPermContentEdit) || identity.Can(dice.WebUIPermExtensionManage) {
+		t.Fatalf("content editor permissions = %v", identity.Role.Permissions())
+	}
+
+	adminToken, err := dm.WebUISignIn("", "ui-hash")
+	if err != nil {
+		t.Fatalf("WebUISignIn() with UI password error = %v", err)
+	}
+	admin, ok := dm.WebUIResolveToken(adminToken)
+	if !ok || admin.Username != dice.WebUIBuiltinAdminName || !admin.Can(dice.WebUIPermAccountManage) {
+		t.Fatalf("builtin admin identity = %+v, %v", admin, ok)
+	}
+
+	disabled := true
+	if err := dm.WebUIAccountUpdate("gm", nil, nil, &disabled); err != nil {
+		t.Fatalf("WebUIAccountUpdate() error = %v", err)
+	}
+	if _, ok := dm.WebUIResolveToken(token); ok {
+		t.Fatal("session of disabled account should be revoked")
+	}
+}
+
+func TestWebUIAccountAPIToken(t *testing.T) {
+	dm := &dice.DiceManager{UIPasswordHash: "ui-hash"}
+	if err := dm.WebUIAccountAdd("bot", dice.WebUIRoleLogReader, "bot-hash"); err != nil {
+		t.Fatalf("WebUIAccountAdd() error = %v", err)
+	}
+	plain, item, err := dm.WebUIAccountCreateAPIToken("bot", "export")
+	if err != nil {
+		t.Fatalf("WebUIAccountCreateAPIToken() error = %v", err)
+	}
+	identity, ok := dm.WebUIResolveToken(plain)
+	if !ok || !identity.ViaAPIToken || identity.Role != dice.WebUIRoleLogReader {
+		t.Fatalf("WebUIResolveToken() = %+v, %v", identity, ok)
+	}
+	if got := identity.AuditActorName(); got != "bot(令牌 export/"+item.ID+")" {
+		t.Fatalf("AuditActorName() = %s", got)
+	}
+	if err := dm.WebUIAccountRevokeAPIToken("bot", item.ID); err != nil {
+		t.Fatalf("WebUIAccountRevokeAPIToken() error = %v", err)
+	}
+	if _, ok := dm.WebUIResolveToken(plain); ok {
+		t.Fatal("revoked token should not resolve")
+	}
+}
+
+func TestWebUIAccountKeepsAdminEntry(t *testing.T) {
+	dm := &dice.DiceManager{}
+	if err := dm.WebUIAccountAdd("viewer", dice.WebUIRoleViewer, "hash"); !errors.Is(err, dice.ErrWebUIAccountNeedAdmin) {
+		t.Fatalf("first non-admin account without UI password error = %v", err)
+	}
+	if err := dm.WebUIAccountAdd("root", dice.WebUIRoleAdmin, "hash"); err != nil {
+		t.Fatalf("WebUIAccountAdd() error = %v", err)
+	}
+	if _, err := dm.WebUISignIn("", ""); err == nil {
+		t.Fatal("password-less builtin sign-in should be rejected once accounts exist")
+	}
+	if err := dm.WebUIAccountDelete("root"); !errors.Is(err, dice.ErrWebUIAccountLastAdmin) {
+		t.Fatalf("deleting last admin error = %v", err)
+	}
+}
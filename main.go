@@ -26,7 +26,10 @@ import (
 
 	"sealdice-core/api"
 	"sealdice-core/dice"
+	"sealdice-core/dice/exttest"
+	"sealdice-core/dice/sealpack"
 	"sealdice-core/dice/service"
+	"sealdice-core/dice/storeserver"
 	"sealdice-core/logger"
 	v2 "sealdice-core/migrate/v2"
 	"sealdice-core/static"
@@ -89,6 +92,9 @@ func cleanupCreate(diceManager *dice.DiceManager) func() {
 						}
 					}
 				}
+				if i.ExtDocs != nil {
+					i.ExtDocs.CloseAll()
+				}
 				i.IsAlreadyLoadConfig = false
 			}
 		}
@@ -152,27 +158,33 @@ func fixTimezone() {
 
 func main() {
 	var opts struct {
-		Version                bool   `description:"显示版本号"                                                           long:"version"`
-		Install                bool   `description:"安装为系统服务"                                                         long:"install"          short:"i"`
-		Uninstall              bool   `description:"删除系统服务"                                                          long:"uninstall"`
-		ShowConsole            bool   `description:"Windows上显示控制台界面"                                                 long:"show-console"`
-		HideUIWhenBoot         bool   `description:"启动时不弹出UI"                                                        long:"hide-ui"`
-		ServiceUser            string `description:"用于启动服务的用户"                                                       long:"service-user"`
-		ServiceName            string `description:"自定义服务名，默认为sealdice"                                              long:"service-name"`
-		MultiInstanceOnWindows bool   `description:"允许在Windows上运行多个海豹"                                               long:"multi-instance"   short:"m"`
-		Address                string `description:"将UI的http服务地址改为此值，例: 0.0.0.0:3211"                                long:"address"`
-		DoUpdateWin            bool   `description:"windows自动升级用，不要在任何情况下主动调用"                                       long:"do-update-win"`
-		DoUpdateOthers         bool   `description:"linux/mac自动升级用，不要在任何情况下主动调用"                                     long:"do-update-others"`
-		Delay                  int64  `long:"delay"`
-		JustForTest            bool   `long:"just-for-test"`
-		DBCheck                bool   `description:"检查数据库是否有问题"                                                      long:"db-check"`
-		ShowEnv                bool   `description:"显示环境变量"                                                          long:"show-env"`
-		VacuumDB               bool   `description:"对数据库进行整理, 使其收缩到最小尺寸"                                             long:"vacuum"`
-		UpdateTest             bool   `description:"更新测试"                                                            long:"update-test"`
-		LogLevel               int8   `choice:"-1"                                                                   choice:"0"              choice:"1" choice:"2" choice:"3" choice:"4" choice:"5" default:"0" description:"设置日志等级"             long:"log-level"`
-		ContainerMode          bool   `description:"容器模式，该模式下禁用内置客户端"                                                long:"container-mode"`
-		MutexProfileRate       int    `description:"对互斥锁竞用的采样速率，小于等于0=关闭，1=所有，其他N=N分之1采样率" long:"mutex-profile" default:"5"`
-		BlockProfileRate       int    `description:"对阻塞事件的采样速率，小于等于0=关闭，1=所有，其他N=每N纳秒1次采样" long:"block-profile" default:"5000"`
+		Version                bool     `description:"显示版本号"                                                           long:"version"`
+		Install                bool     `description:"安装为系统服务"                                                         long:"install"          short:"i"`
+		Uninstall              bool     `description:"删除系统服务"                                                          long:"uninstall"`
+		ShowConsole            bool     `description:"Windows上显示控制台界面"                                                 long:"show-console"`
+		HideUIWhenBoot         bool     `description:"启动时不弹出UI"                                                        long:"hide-ui"`
+		ServiceUser            string   `description:"用于启动服务的用户"                                                       long:"service-user"`
+		ServiceName            string   `description:"自定义服务名，默认为sealdice"                                              long:"service-name"`
+		MultiInstanceOnWindows bool     `description:"允许在Windows上运行多个海豹"                                               long:"multi-instance"   short:"m"`
+		Address                string   `description:"将UI的http服务地址改为此值，例: 0.0.0.0:3211"                                long:"address"`
+		DoUpdateWin            bool     `description:"windows自动升级用，不要在任何情况下主动调用"                                       long:"do-update-win"`
+		DoUpdateOthers         bool     `description:"linux/mac自动升级用，不要在任何情况下主动调用"                                     long:"do-update-others"`
+		Delay                  int64    `long:"delay"`
+		JustForTest            bool     `long:"just-for-test"`
+		DBCheck                bool     `description:"检查数据库是否有问题"                                                      long:"db-check"`
+		ShowEnv                bool     `description:"显示环境变量"                                                          long:"show-env"`
+		VacuumDB               bool     `description:"对数据库进行整理, 使其收缩到最小尺寸"                                             long:"vacuum"`
+		UpdateTest             bool     `description:"更新测试"                                                            long:"update-test"`
+		LogLevel               int8     `choice:"-1"                                                                   choice:"0"              choice:"1" choice:"2" choice:"3" choice:"4" choice:"5" default:"0" description:"设置日志等级"             long:"log-level"`
+		ContainerMode          bool     `description:"容器模式，该模式下禁用内置客户端"                                                long:"container-mode"`
+		MutexProfileRate       int      `description:"对互斥锁竞用的采样速率，小于等于0=关闭，1=所有，其他N=N分之1采样率" long:"mutex-profile" default:"5"`
+		BlockProfileRate       int      `description:"对阻塞事件的采样速率，小于等于0=关闭，1=所有，其他N=每N纳秒1次采样" long:"block-profile" default:"5000"`
+		ExtTest                []string `description:"离线执行扩展测试剧本(YAML)后退出，可多次指定"                                   long:"ext-test"`
+		StoreServe             string   `description:"以该目录下的 .sealpack 文件运行扩展商店后端，不启动骰子"                     long:"store-serve"`
+		StoreServeAddr         string   `description:"扩展商店后端的监听地址"                                                    long:"store-serve-addr" default:"0.0.0.0:3213"`
+		SignPack               []string `description:"用 --sign-key 指定的私钥为 .sealpack 签名后退出，可多次指定"                long:"sign-pack"`
+		SignKey                string   `description:"签名用的 RSA 私钥文件(PKCS#8 PEM)"                                         long:"sign-key"`
+		Signer                 string   `description:"写入签名的发布者名称"                                                      long:"signer"`
 	}
 
 	// 读取命令行传参
@@ -224,6 +236,37 @@ func main() {
 		log.Infof("阻塞采样率: 1 every %dns", opts.BlockProfileRate)
 	}
 
+	// 扩展离线测试不读写正式数据，无需加锁
+	if len(opts.ExtTest) > 0 {
+		os.Exit(exttest.RunCLI(opts.ExtTest, os.Stdout))
+	}
+
+	// 扩展包签名只改写指定的文件
+	if len(opts.SignPack) > 0 {
+		key, err := os.ReadFile(opts.SignKey)
+		if err != nil {
+			log.Errorf("读取签名私钥失败: %v", err)
+			os.Exit(1)
+		}
+		for _, p := range opts.SignPack {
+			sig, err := sealpack.SignArchive(p, p, opts.Signer, string(key))
+			if err != nil {
+				log.Errorf("签名 %s 失败: %v", p, err)
+				os.Exit(1)
+			}
+			log.Infof("已签名 %s，公钥指纹 %s", p, sig.KeyID)
+		}
+		return
+	}
+
+	// 商店后端只读写扩展包目录，与骰子互不影响
+	if opts.StoreServe != "" {
+		if err := storeserver.Serve(opts.StoreServe, opts.StoreServeAddr, log); err != nil {
+			log.Errorf("扩展商店后端退出: %v", err)
+		}
+		return
+	}
+
 	// 初始化文件加锁系统
 	locked, err := sealLock.TryLock()
 	// 如果有错误，或者未能取到锁
@@ -40,6 +40,10 @@ func InitUpgrader(operator operator.DatabaseOperator) error {
 	mgr.Register(v160.V160LogIDZeroCleanMigration)
 	mgr.Register(v160.V160LogRawMsgIDIndexMigration)
 	mgr.Register(v160.V160LogSizeRepairMigration)
+	mgr.Register(v160.V160AuditLogTableMigration)
+	mgr.Register(v160.V160AttrsRevisionTableMigration)
+	mgr.Register(v160.V160LogStatTableMigration)
+	mgr.Register(v160.V160CampaignTableMigration)
 	err := mgr.ApplyAll()
 	if err != nil {
 		return err
@@ -0,0 +1,40 @@
+package v160
+
+import (
+	"fmt"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	operator "sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+func V160AttrsRevisionTableMigrate(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetDataDB(constant.WRITE)
+	if db.Migrator().HasTable(&model.AttrsRevision{}) {
+		logf("数据表 - attrs_revision 已存在，无需处理")
+		return nil
+	}
+	if err := db.AutoMigrate(&model.AttrsRevision{}); err != nil {
+		return err
+	}
+	logf("数据表 - 已创建 attrs_revision 角色卡修改记录表")
+	return nil
+}
+
+var V160AttrsRevisionTableMigration = upgrade.Upgrade{
+	ID: "012_V160AttrsRevisionTableMigration",
+	Description: `
+# 升级说明
+新增角色卡修改记录表 attrs_revision，用于 .pc history / .pc undo
+`,
+	Apply: func(logf func(string), operator operator.DatabaseOperator) error {
+		logf(fmt.Sprintf("[INFO] V160角色卡修改记录表创建开始 type=%s", operator.Type()))
+		err := V160AttrsRevisionTableMigrate(operator, logf)
+		if err != nil {
+			return err
+		}
+		logf("[INFO] V160角色卡修改记录表创建完毕")
+		return nil
+	},
+}
@@ -0,0 +1,40 @@
+package v160
+
+import (
+	"fmt"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	operator "sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+func V160AuditLogTableMigrate(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetDataDB(constant.WRITE)
+	if db.Migrator().HasTable(&model.AuditLog{}) {
+		logf("数据表 - audit_log 已存在，无需处理")
+		return nil
+	}
+	if err := db.AutoMigrate(&model.AuditLog{}); err != nil {
+		return err
+	}
+	logf("数据表 - 已创建 audit_log 审计表")
+	return nil
+}
+
+var V160AuditLogTableMigration = upgrade.Upgrade{
+	ID: "011_V160AuditLogTableMigration",
+	Description: `
+# 升级说明
+新增管理操作审计表 audit_log
+`,
+	Apply: func(logf func(string), operator operator.DatabaseOperator) error {
+		logf(fmt.Sprintf("[INFO] V160审计表创建开始 type=%s", operator.Type()))
+		err := V160AuditLogTableMigrate(operator, logf)
+		if err != nil {
+			return err
+		}
+		logf("[INFO] V160审计表创建完毕")
+		return nil
+	},
+}
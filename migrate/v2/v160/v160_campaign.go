@@ -0,0 +1,56 @@
+package v160
+
+import (
+	"fmt"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	operator "sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+var campaignTables = []any{
+	&model.Campaign{},
+	&model.CampaignMember{},
+	&model.CampaignGroup{},
+	&model.CampaignSession{},
+	&model.CampaignSessionLog{},
+	&model.CampaignNote{},
+}
+
+func V160CampaignTableMigrate(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetDataDB(constant.WRITE)
+	exists := true
+	for _, t := range campaignTables {
+		if !db.Migrator().HasTable(t) {
+			exists = false
+			break
+		}
+	}
+	if exists {
+		logf("数据表 - campaign 已存在，无需处理")
+		return nil
+	}
+	if err := db.AutoMigrate(campaignTables...); err != nil {
+		return err
+	}
+	logf("数据表 - 已创建 campaign 战役相关表")
+	return nil
+}
+
+var V160CampaignTableMigration = upgrade.Upgrade{
+	ID: "014_V160CampaignTableMigration",
+	Description: `
+# 升级说明
+新增战役相关表 campaign / campaign_member / campaign_group / campaign_session / campaign_session_log / campaign_note，用于 .campaign
+`,
+	Apply: func(logf func(string), operator operator.DatabaseOperator) error {
+		logf(fmt.Sprintf("[INFO] V160战役表创建开始 type=%s", operator.Type()))
+		err := V160CampaignTableMigrate(operator, logf)
+		if err != nil {
+			return err
+		}
+		logf("[INFO] V160战役表创建完毕")
+		return nil
+	},
+}
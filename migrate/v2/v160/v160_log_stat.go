@@ -0,0 +1,40 @@
+package v160
+
+import (
+	"fmt"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	operator "sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+func V160LogStatTableMigrate(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetLogDB(constant.WRITE)
+	if db.Migrator().HasTable(&model.LogStat{}) && db.Migrator().HasTable(&model.LogStatCursor{}) {
+		logf("数据表 - log_stat 已存在，无需处理")
+		return nil
+	}
+	if err := db.AutoMigrate(&model.LogStat{}, &model.LogStatCursor{}); err != nil {
+		return err
+	}
+	logf("数据表 - 已创建 log_stat 跨日志检定统计表")
+	return nil
+}
+
+var V160LogStatTableMigration = upgrade.Upgrade{
+	ID: "013_V160LogStatTableMigration",
+	Description: `
+# 升级说明
+新增跨日志检定统计缓存表 log_stat 与 log_stat_cursor，用于 .stat me / .stat group
+`,
+	Apply: func(logf func(string), operator operator.DatabaseOperator) error {
+		logf(fmt.Sprintf("[INFO] V160检定统计表创建开始 type=%s", operator.Type()))
+		err := V160LogStatTableMigrate(operator, logf)
+		if err != nil {
+			return err
+		}
+		logf("[INFO] V160检定统计表创建完毕")
+		return nil
+	},
+}
@@ -86,6 +86,10 @@ func NewTestManager(t *testing.T, op engine.DatabaseOperator) *upgrade.Manager {
 	mgr.Register(v160.V160LogIDZeroCleanMigration)
 	mgr.Register(v160.V160LogRawMsgIDIndexMigration)
 	mgr.Register(v160.V160LogSizeRepairMigration)
+	mgr.Register(v160.V160AuditLogTableMigration)
+	mgr.Register(v160.V160AttrsRevisionTableMigration)
+	mgr.Register(v160.V160LogStatTableMigration)
+	mgr.Register(v160.V160CampaignTableMigration)
 	return mgr
 }
 
@@ -5,7 +5,7 @@ package model
 type AttributesItemModel struct {
 	Id        string `gorm:"column:id"                                                    json:"id"`        // 如果是群内，那么是类似 QQ-Group:12345-QQ:678910，群外是nanoid
 	Data      []byte `gorm:"column:data"                                                  json:"data"`      // 序列化后的卡数据，理论上[]byte不会进入字符串缓存，要更好些？
-	AttrsType string `gorm:"column:attrs_type;index:idx_attrs_attrs_type_id;default:NULL" json:"attrsType"` // 分为: 角色卡(character)、组内用户(group_user)、群组(group)、用户(user)
+	AttrsType string `gorm:"column:attrs_type;index:idx_attrs_attrs_type_id;default:NULL" json:"attrsType"` // 分为: 角色卡(character)、组内用户(group_user)、群组(group)、用户(user)、NPC卡(npc)
 
 	// 这些是群组内置卡专用的，其实就是替代了绑卡关系表，作为群组内置卡时，这个字段用于存放绑卡关系
 	BindingSheetId string `gorm:"column:binding_sheet_id;default:'';index:idx_attrs_binding_sheet_id" json:"bindingSheetId"` // 绑定的卡片ID
@@ -0,0 +1,26 @@
+package model
+
+// AttrsRevision 角色卡修改记录。Data 为本次修改前的完整卡数据，用于撤销
+type AttrsRevision struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement;column:id"                   json:"id"`
+	AttrsID    string `gorm:"index:idx_attrs_revision_attrs_id;column:attrs_id"     json:"attrsId"`
+	EditorType string `gorm:"column:editor_type"                                   json:"editorType"` // im / webui / system
+	EditorID   string `gorm:"column:editor_id"                                     json:"editorId"`   // IM 统一ID 或 WebUI 账号名
+	EditorName string `gorm:"column:editor_name"                                   json:"editorName"`
+	Source     string `gorm:"column:source"                                        json:"source"`    // 触发修改的指令或接口
+	SheetType  string `gorm:"column:sheet_type"                                    json:"sheetType"` // 修改前的卡片类型
+	Data       []byte `gorm:"column:data"                                          json:"-"`
+	Diff       string `gorm:"column:diff"                                          json:"diff"` // JSON，见 AttrsRevisionChange
+	CreatedAt  int64  `gorm:"index:idx_attrs_revision_created_at;column:created_at" json:"createdAt"`
+}
+
+// AttrsRevisionChange 一次修改中单个属性的变化，值为展示用的字符串，nil 表示不存在
+type AttrsRevisionChange struct {
+	Key    string  `json:"key"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+func (AttrsRevision) TableName() string {
+	return "attrs_revision"
+}
@@ -0,0 +1,19 @@
+package model
+
+// AuditLog 管理操作审计记录，只追加不修改
+type AuditLog struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement;column:id"               json:"id"`
+	ActorType string `gorm:"column:actor_type"                                json:"actorType"` // im / webui
+	ActorID   string `gorm:"index:idx_audit_log_actor_id;column:actor_id"     json:"actorId"`   // IM 统一ID 或 WebUI 账号名
+	ActorName string `gorm:"column:actor_name"                                json:"actorName"`
+	Action    string `gorm:"index:idx_audit_log_action;column:action"         json:"action"`
+	Target    string `gorm:"index:idx_audit_log_target;column:target"         json:"target"`
+	Before    string `gorm:"column:before_data"                               json:"before"` // JSON
+	After     string `gorm:"column:after_data"                                json:"after"`  // JSON
+	Place     string `gorm:"column:place"                                     json:"place"`  // 操作发生的群组或接口路径
+	CreatedAt int64  `gorm:"index:idx_audit_log_created_at;column:created_at" json:"createdAt"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
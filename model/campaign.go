@@ -0,0 +1,89 @@
+package model
+
+// Campaign 跑团战役，跨群组织成员、角色、日志与团次
+type Campaign struct {
+	ID          uint64 `gorm:"primaryKey;autoIncrement;column:id"         json:"id"`
+	Name        string `gorm:"column:name;uniqueIndex:idx_campaign_name"  json:"name"`
+	KPID        string `gorm:"column:kp_id"                               json:"kpId"`        // 主持人的统一ID
+	MainGroupID string `gorm:"column:main_group_id"                       json:"mainGroupId"` // 创建战役的群
+	CreatorID   string `gorm:"column:creator_id"                          json:"creatorId"`
+	CreatedAt   int64  `gorm:"column:created_at"                          json:"createdAt"`
+	UpdatedAt   int64  `gorm:"column:updated_at"                          json:"updatedAt"`
+}
+
+func (*Campaign) TableName() string {
+	return "campaign"
+}
+
+const (
+	CampaignRoleKP     = "kp"
+	CampaignRolePlayer = "player"
+)
+
+// CampaignMember 战役成员，CharacterID 为加入时在该群绑定的角色卡
+type CampaignMember struct {
+	ID          uint64 `gorm:"primaryKey;autoIncrement;column:id"                      json:"id"`
+	CampaignID  uint64 `gorm:"column:campaign_id;uniqueIndex:idx_campaign_member,priority:1" json:"campaignId"`
+	UserID      string `gorm:"column:user_id;uniqueIndex:idx_campaign_member,priority:2"     json:"userId"`
+	Role        string `gorm:"column:role"                                             json:"role"` // kp / player
+	CharacterID string `gorm:"column:character_id"                                     json:"characterId"`
+	PcName      string `gorm:"column:pc_name"                                          json:"pcName"`
+	JoinedAt    int64  `gorm:"column:joined_at"                                        json:"joinedAt"`
+}
+
+func (*CampaignMember) TableName() string {
+	return "campaign_member"
+}
+
+// CampaignGroup 战役关联的群，一个群同时只能属于一个战役
+type CampaignGroup struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement;column:id"           json:"id"`
+	CampaignID uint64 `gorm:"column:campaign_id;index:idx_campaign_group_cid" json:"campaignId"`
+	GroupID    string `gorm:"column:group_id;uniqueIndex:idx_campaign_group_gid" json:"groupId"`
+	CreatedAt  int64  `gorm:"column:created_at"                            json:"createdAt"`
+}
+
+func (*CampaignGroup) TableName() string {
+	return "campaign_group"
+}
+
+// CampaignSession 一次团，No 从1开始在战役内递增
+type CampaignSession struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement;column:id"             json:"id"`
+	CampaignID uint64 `gorm:"column:campaign_id;index:idx_campaign_session_cid" json:"campaignId"`
+	No         int    `gorm:"column:no"                                      json:"no"`
+	Title      string `gorm:"column:title"                                   json:"title"`
+	CreatedAt  int64  `gorm:"column:created_at"                              json:"createdAt"`
+}
+
+func (*CampaignSession) TableName() string {
+	return "campaign_session"
+}
+
+// CampaignSessionLog 团次对应的日志，以 群号+日志名 指向 log 库中的 logs
+type CampaignSessionLog struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement;column:id"                                json:"id"`
+	CampaignID uint64 `gorm:"column:campaign_id;index:idx_campaign_session_log_cid"             json:"campaignId"`
+	SessionID  uint64 `gorm:"column:session_id;uniqueIndex:idx_campaign_session_log,priority:1" json:"sessionId"`
+	GroupID    string `gorm:"column:group_id;uniqueIndex:idx_campaign_session_log,priority:2"   json:"groupId"`
+	LogName    string `gorm:"column:log_name;uniqueIndex:idx_campaign_session_log,priority:3"   json:"logName"`
+	CreatedAt  int64  `gorm:"column:created_at"                                                 json:"createdAt"`
+}
+
+func (*CampaignSessionLog) TableName() string {
+	return "campaign_session_log"
+}
+
+// CampaignNote 战役笔记
+type CampaignNote struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement;column:id"          json:"id"`
+	CampaignID uint64 `gorm:"column:campaign_id;index:idx_campaign_note_cid" json:"campaignId"`
+	AuthorID   string `gorm:"column:author_id"                            json:"authorId"`
+	AuthorName string `gorm:"column:author_name"                          json:"authorName"`
+	Content    string `gorm:"column:content"                              json:"content"`
+	CreatedAt  int64  `gorm:"column:created_at"                           json:"createdAt"`
+}
+
+func (*CampaignNote) TableName() string {
+	return "campaign_note"
+}
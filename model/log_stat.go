@@ -0,0 +1,69 @@
+package model
+
+// LogStat 跨日志的检定统计缓存，由 log_items 中的指令信息(command_info)增量汇总
+// 以 群-用户-角色-技能 为一行，Skill 为空字符串的行是该角色在群内的合计
+type LogStat struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement;column:id"                json:"-"`
+	GroupID   string `gorm:"column:group_id;index:idx_log_stat_key,priority:1" json:"groupId"`
+	UserID    string `gorm:"column:user_id;index:idx_log_stat_key,priority:2"  json:"userId"` // 发出指令的用户，取不到时为空
+	PcName    string `gorm:"column:pc_name;index:idx_log_stat_key,priority:3"  json:"pcName"`
+	Skill     string `gorm:"column:skill;index:idx_log_stat_key,priority:4"    json:"skill"`
+	Checks    int64  `gorm:"column:checks"                                     json:"checks"`
+	Successes int64  `gorm:"column:successes"                                  json:"successes"`
+	Crits     int64  `gorm:"column:crits"                                      json:"crits"`   // 大成功
+	Fumbles   int64  `gorm:"column:fumbles"                                    json:"fumbles"` // 大失败
+	D100Sum   int64  `gorm:"column:d100_sum"                                   json:"d100Sum"`
+	D100Count int64  `gorm:"column:d100_count"                                 json:"d100Count"`
+	SanChecks int64  `gorm:"column:san_checks"                                 json:"sanChecks"`
+	SanLoss   int64  `gorm:"column:san_loss"                                   json:"sanLoss"`
+	Streak    int64  `gorm:"column:streak"                                     json:"streak"`   // 当前连续成功(正)或失败(负)次数，仅合计行
+	BestRun   int64  `gorm:"column:best_run"                                   json:"bestRun"`  // 最长连续成功
+	WorstRun  int64  `gorm:"column:worst_run"                                  json:"worstRun"` // 最长连续失败
+	UpdatedAt int64  `gorm:"column:updated_at"                                 json:"updatedAt"`
+}
+
+func (*LogStat) TableName() string {
+	return "log_stat"
+}
+
+// Merge 累加另一行统计，连续记录取较大者
+func (s *LogStat) Merge(o *LogStat) {
+	s.Checks += o.Checks
+	s.Successes += o.Successes
+	s.Crits += o.Crits
+	s.Fumbles += o.Fumbles
+	s.D100Sum += o.D100Sum
+	s.D100Count += o.D100Count
+	s.SanChecks += o.SanChecks
+	s.SanLoss += o.SanLoss
+	s.BestRun = max(s.BestRun, o.BestRun)
+	s.WorstRun = max(s.WorstRun, o.WorstRun)
+	s.UpdatedAt = max(s.UpdatedAt, o.UpdatedAt)
+}
+
+// SuccessRate 成功率，没有检定时为0
+func (s *LogStat) SuccessRate() float64 {
+	if s.Checks == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Checks)
+}
+
+// D100Avg d100平均出目，期望值为50.5，没有记录时为0
+func (s *LogStat) D100Avg() float64 {
+	if s.D100Count == 0 {
+		return 0
+	}
+	return float64(s.D100Sum) / float64(s.D100Count)
+}
+
+// LogStatCursor 统计的增量进度，只有一行
+type LogStatCursor struct {
+	ID         uint64 `gorm:"primaryKey;column:id" json:"id"`
+	LastItemID uint64 `gorm:"column:last_item_id"  json:"lastItemId"` // 已汇总到的 log_items.id
+	UpdatedAt  int64  `gorm:"column:updated_at"    json:"updatedAt"`
+}
+
+func (*LogStatCursor) TableName() string {
+	return "log_stat_cursor"
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -56,8 +57,11 @@ func (s *SQLiteEngine) Close() {
 		}
 	}
 
-	// 关闭 writeList 中的连接
+	// 关闭 writeList 中的连接，内存库的读写共用同一连接，跳过已关闭的
 	for name, db := range s.writeList {
+		if s.readList[name] == db {
+			continue
+		}
 		sqlDB, err := db.DB()
 		if err != nil {
 			log.Errorf("failed to get sql.DB for %s: %v", name, err)
@@ -135,6 +139,34 @@ func (s *SQLiteEngine) Init(ctx context.Context) error {
 	return nil
 }
 
+var memoryDBSeq atomic.Int64
+
+// InitMemory 使用内存数据库初始化，不落盘，读写共用同一连接，供离线测试使用
+func (s *SQLiteEngine) InitMemory(ctx context.Context) error {
+	if ctx == nil {
+		return errors.New("ctx is missing")
+	}
+	s.ctx = ctx
+	s.readList = make(map[dbName]*gorm.DB)
+	s.writeList = make(map[dbName]*gorm.DB)
+	seq := memoryDBSeq.Add(1)
+	for key, cacheKey := range map[dbName]any{
+		DataDBKey:    cache.DataDBCacheKey,
+		LogsDBKey:    cache.LogsDBCacheKey,
+		CensorsDBKey: cache.CensorsDBCacheKey,
+	} {
+		db, err := SQLiteMemoryDBInit(fmt.Sprintf("sealdice-%d-%s", seq, key))
+		if err != nil {
+			s.Close()
+			return err
+		}
+		db = db.WithContext(context.WithValue(s.ctx, cache.CacheKey, cacheKey))
+		s.readList[key] = db
+		s.writeList[key] = db
+	}
+	return nil
+}
+
 func (s *SQLiteEngine) DBCheck() {
 	dataDir := s.DataDir
 	checkDB := func(db *gorm.DB) bool {
@@ -39,6 +39,25 @@ func SQLiteDBInit(path string, useWAL bool) (*gorm.DB, error) {
 	return open, err
 }
 
+// SQLiteMemoryDBInit 打开一个内存数据库，只保留一个连接，读写共用
+func SQLiteMemoryDBInit(name string) (*gorm.DB, error) {
+	path := fmt.Sprintf("file:%v?mode=memory&cache=shared&_txlock=immediate", name)
+	open, err := gorm.Open(sqlite.Open(path), &gorm.Config{
+		Logger:                 logger.DefaultSealLogger,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pool, err := open.DB()
+	if err != nil {
+		return nil, err
+	}
+	// 内存库随最后一个连接关闭而消失，只用一个连接也避免了读写连接互相锁表
+	pool.SetMaxOpenConns(1)
+	return open, nil
+}
+
 func createReadDB(path string, gormConf gorm.Config) (*gorm.DB, error) {
 	// _txlock=immediate 解决BEGIN IMMEDIATELY
 	path = fmt.Sprintf("file:%v?_txlock=immediate", path)
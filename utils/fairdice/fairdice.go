@@ -0,0 +1,133 @@
+// Package fairdice 可验证骰点(承诺-揭示)
+//
+// 开始时随机生成一个种子，公布其 sha256 作为承诺；之后第 n 条指令的骰点全部来自
+// HMAC-SHA256(种子, n) 的前16字节初始化的 PCGSource。结束时公布种子，
+// 任何人都可以核对承诺，并用同样的方式重算每一条指令的骰点。
+package fairdice
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+	rand2 "golang.org/x/exp/rand"
+)
+
+// SeedSize 种子长度(字节)
+const SeedSize = 32
+
+// NewSeed 生成新的随机种子，返回其十六进制形式
+func NewSeed() (string, error) {
+	seed := make([]byte, SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(seed), nil
+}
+
+// Commitment 计算种子的承诺值，即种子原始字节的 sha256
+func Commitment(seedHex string) (string, error) {
+	seed, err := decodeSeed(seedHex)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(seed)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify 检查种子是否与承诺相符
+func Verify(seedHex string, commitment string) error {
+	c, err := Commitment(seedHex)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(c), []byte(strings.ToLower(strings.TrimSpace(commitment)))) {
+		return errors.New("种子与承诺不符")
+	}
+	return nil
+}
+
+// Source 取得第 counter 条指令使用的随机源
+func Source(seedHex string, counter uint64) (*rand2.PCGSource, error) {
+	seed, err := decodeSeed(seedHex)
+	if err != nil {
+		return nil, err
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], counter)
+	mac := hmac.New(sha256.New, seed)
+	mac.Write(buf[:])
+	src := &rand2.PCGSource{}
+	if err = src.UnmarshalBinary(mac.Sum(nil)[:16]); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// ReplayOptions 复算时的求值方式，需要与骰子执行指令时一致
+type ReplayOptions struct {
+	// Config dicescript 配置，如启用的骰点语法和默认骰子面数
+	Config ds.RollConfig
+	// Eval 不为空时代替 dicescript 求值，例如复算 v1 引擎掷出的骰点
+	Eval func(src *rand2.PCGSource, expr string) (string, error)
+}
+
+// Replay 依次执行表达式重算第 counter 条指令的骰点，返回每个表达式的结果和过程
+//
+// 同一条指令中的多次掷骰共用一个随机源，因此表达式的顺序需要与指令中掷骰的顺序一致。
+func Replay(seedHex string, counter uint64, exprs ...string) ([]string, error) {
+	return ReplayWithOptions(seedHex, counter, ReplayOptions{}, exprs...)
+}
+
+// ReplayWithOptions 同 Replay，但使用指定的求值方式
+func ReplayWithOptions(seedHex string, counter uint64, opts ReplayOptions, exprs ...string) ([]string, error) {
+	src, err := Source(seedHex, counter)
+	if err != nil {
+		return nil, err
+	}
+	eval := opts.Eval
+	if eval == nil {
+		eval = func(src *rand2.PCGSource, expr string) (string, error) {
+			vm := ds.NewVM()
+			vm.Config = opts.Config
+			vm.RandSrc = src
+			if err := vm.Run(expr); err != nil {
+				return "", err
+			}
+			return ResultText(vm.Ret.ToString(), vm.GetDetailText()), nil
+		}
+	}
+	results := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		text, err := eval(src, expr)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, expr+"="+text)
+	}
+	return results, nil
+}
+
+// ResultText 拼接结果与过程，过程为空或与结果相同时只保留结果
+func ResultText(value, detail string) string {
+	if detail != "" && detail != value {
+		return detail + "=" + value
+	}
+	return value
+}
+
+func decodeSeed(seedHex string) ([]byte, error) {
+	seed, err := hex.DecodeString(strings.TrimSpace(seedHex))
+	if err != nil {
+		return nil, errors.New("种子格式错误: " + err.Error())
+	}
+	if len(seed) == 0 {
+		return nil, errors.New("种子为空")
+	}
+	return seed, nil
+}
@@ -0,0 +1,54 @@
+//nolint:testpackage
+package fairdice
+
+import (
+	"testing"
+)
+
+func TestCommitmentVerify(t *testing.T) {
+	seed, err := NewSeed()
+	if err != nil {
+		t.Fatalf("NewSeed() error = %v", err)
+	}
+	if len(seed) != SeedSize*2 {
+		t.Fatalf("len(seed) = %d", len(seed))
+	}
+	c, err := Commitment(seed)
+	if err != nil {
+		t.Fatalf("Commitment() error = %v", err)
+	}
+	if err = Verify(seed, c); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	other, _ := NewSeed()
+	if err = Verify(other, c); err == nil {
+		t.Fatalf("Verify() 应当校验失败")
+	}
+	if _, err = Commitment("xyz"); err == nil {
+		t.Fatalf("Commitment(xyz) 应当报错")
+	}
+}
+
+func TestReplayDeterministic(t *testing.T) {
+	const seed = "00112233445566778899aabbccddeeff"
+	a, err := Replay(seed, 3, "d100", "3d6")
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	b, _ := Replay(seed, 3, "d100", "3d6")
+	if len(a) != 2 || a[0] != b[0] || a[1] != b[1] {
+		t.Fatalf("Replay() 结果不一致: %v %v", a, b)
+	}
+
+	differ := false
+	for counter := uint64(1); counter <= 10; counter++ {
+		c, _ := Replay(seed, counter, "d1000000")
+		if c[0] != a[0] {
+			differ = true
+			break
+		}
+	}
+	if !differ {
+		t.Fatalf("不同序号的骰点应当不同")
+	}
+}
@@ -0,0 +1,52 @@
+// 公平骰校验工具
+//
+// 用法: go run ./utils/fairdice/verify -seed <种子> -commit <承诺> -counter <序号> [-sides <面数>] [-v1] <表达式>...
+// 种子和承诺来自 .log new/.log end 的回复，序号来自日志中指令信息的 fairDice 字段。
+// -sides 为掷骰时的默认骰子面数(.set 设置)，-v1 用于复算 v1 引擎掷出的骰点(如 VM 版本为 v1 的自定义回复)。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"sealdice-core/dice"
+	"sealdice-core/utils/fairdice"
+)
+
+func main() {
+	seed := flag.String("seed", "", "日志结束时公布的种子")
+	commit := flag.String("commit", "", "日志开始时公布的承诺，留空则不校验")
+	counter := flag.Uint64("counter", 0, "指令序号")
+	sides := flag.Int64("sides", 100, "默认骰子面数")
+	v1 := flag.Bool("v1", false, "使用 v1 引擎复算")
+	flag.Parse()
+
+	if *seed == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *commit != "" {
+		if err := fairdice.Verify(*seed, *commit); err != nil {
+			fmt.Println("校验失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println("承诺校验通过")
+	}
+	if flag.NArg() == 0 {
+		return
+	}
+	if *counter == 0 {
+		fmt.Println("请使用 -counter 指定指令序号")
+		os.Exit(2)
+	}
+	results, err := fairdice.ReplayWithOptions(*seed, *counter, dice.FairDiceReplayOptions(*sides, *v1), flag.Args()...)
+	if err != nil {
+		fmt.Println("重算失败:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("第%d条指令:\n", *counter)
+	for _, r := range results {
+		fmt.Println(r)
+	}
+}